@@ -1,7 +1,6 @@
 package api
 
 import (
-	"archive/zip"
 	"bytes"
 	"context"
 	"encoding/base64"
@@ -9,8 +8,12 @@ import (
 	"errors"
 	"fmt"
 	"io"
+	"math"
+	"mime/multipart"
 	"net/http"
 	"os"
+	"strconv"
+	"time"
 
 	"github.com/0xcafe-io/iz"
 	appErrors "github.com/fatali-fataliyev/budget_tracker/customErrors"
@@ -22,7 +25,9 @@ import (
 	ocr "github.com/ranghetto/go_ocr_space"
 )
 
-const MAX_IMAGE_UPLOAD_SIZE = 1 << 20 // 1mib
+const MAX_IMAGE_UPLOAD_SIZE = 1 << 20     // 1mib
+const MAX_IMPORT_UPLOAD_SIZE = 32 << 20   // 32mib
+const MAX_STATEMENT_UPLOAD_SIZE = 5 << 20 // 5mib
 const SUCCESS_CODE = "SUCCESS"
 const FAIL_CODE = "FAIL"
 
@@ -118,12 +123,23 @@ func (api *Api) SaveTransactionHandler(r *iz.Request) iz.Responder {
 		})
 	}
 
+	occurredAt, err := ParseDateTime("occurred_at", newTransactionReq.OccurredAt)
+	if err != nil {
+		return RespondError(err)
+	}
+
 	newTransaction := budget.TransactionRequest{
 		CategoryId:   newTransactionReq.CategoryId,
 		CategoryType: newTransactionReq.CategoryType,
 		Amount:       newTransactionReq.Amount,
 		Currency:     newTransactionReq.Currency,
 		Note:         newTransactionReq.Note,
+		OccurredAt:   occurredAt,
+		Splits:       newTransactionReq.SplitsToBudget(),
+		WalletId:     newTransactionReq.WalletId,
+		ToWalletId:   newTransactionReq.ToWalletId,
+		Tags:         newTransactionReq.Tags,
+		PayeeId:      newTransactionReq.PayeeId,
 	}
 
 	if err := api.Service.SaveTransaction(ctx, userId, newTransaction); err != nil {
@@ -141,7 +157,7 @@ func (api *Api) ProcessImageHandler(r *iz.Request) iz.Responder {
 	traceID := uuid.NewString()
 	ctx := context.WithValue(r.Context(), contextutil.TraceIDKey, traceID)
 
-	_, ok := r.Context().Value(userIdKey).(string)
+	userId, ok := r.Context().Value(userIdKey).(string)
 	if !ok {
 		return iz.Respond().Status(500).JSON(appErrors.ErrorResponse{
 			Code:    appErrors.ErrAuth,
@@ -185,7 +201,7 @@ func (api *Api) ProcessImageHandler(r *iz.Request) iz.Responder {
 		return RespondError(err)
 	}
 
-	processResultRaw, err := api.Service.ProcessImage(ctx, imageRawText)
+	processResultRaw, err := api.Service.ProcessImage(ctx, userId, imageRawText)
 	if err != nil {
 		return RespondError(err)
 	}
@@ -260,6 +276,7 @@ func (api *Api) SaveExpenseCategoryHandler(r *iz.Request) iz.Responder {
 		PeriodDay: newExpCategoryReq.PeriodDay,
 		Note:      newExpCategoryReq.Note,
 		Type:      "-",
+		ParentId:  newExpCategoryReq.ParentId,
 	}
 
 	if err := api.Service.SaveExpenseCategory(ctx, userId, newExpCategory); err != nil {
@@ -305,6 +322,7 @@ func (api *Api) SaveIncomeCategoryHandler(r *iz.Request) iz.Responder {
 		TargetAmount: newIncCategoryReq.TargetAmount,
 		Note:         newIncCategoryReq.Note,
 		Type:         "+",
+		ParentId:     newIncCategoryReq.ParentId,
 	}
 
 	if err := api.Service.SaveIncomeCategory(ctx, userId, newIncCategory); err != nil {
@@ -399,11 +417,16 @@ func (api *Api) GetFilteredIncomeCategoriesHandler(r *iz.Request) iz.Responder {
 	}
 
 	params := r.URL.Query()
+	tree := params.Get("tree") == "true"
+	includeArchived := params.Get("include_archived") == "true"
+	params.Del("tree")
+	params.Del("include_archived")
 
 	filter, err := IncomeCategoryCheckParams(params)
 	if err != nil {
 		return RespondError(err)
 	}
+	filter.IncludeArchived = includeArchived
 
 	categories, err := api.Service.GetFilteredIncomeCategories(ctx, userId, filter)
 
@@ -411,6 +434,9 @@ func (api *Api) GetFilteredIncomeCategoriesHandler(r *iz.Request) iz.Responder {
 		logging.Logger.Errorf("[TraceID=%s] | failed to get filtered income categories | Error: %v", traceID, err)
 		return RespondError(err)
 	}
+	if tree {
+		categories = budget.IncomeCategoryTree(categories)
+	}
 
 	var categoryList ListIncomeCategories
 	categoryList.Categories = make([]IncomeCategoryResponseItem, 0, len(categories))
@@ -434,17 +460,25 @@ func (api *Api) GetFilteredExpenseCategoriesHandler(r *iz.Request) iz.Responder
 	}
 
 	params := r.URL.Query()
+	tree := params.Get("tree") == "true"
+	includeArchived := params.Get("include_archived") == "true"
+	params.Del("tree")
+	params.Del("include_archived")
 
 	filter, err := ExpenseCategoryCheckParams(params)
 	if err != nil {
 		return RespondError(err)
 	}
+	filter.IncludeArchived = includeArchived
 
 	categories, err := api.Service.GetFilteredExpenseCategories(ctx, userId, filter)
 	if err != nil {
 		logging.Logger.Errorf("[TraceID=%s] | failed to get filtered expense categories | Error: %v", traceID, err)
 		return RespondError(err)
 	}
+	if tree {
+		categories = budget.ExpenseCategoryTree(categories)
+	}
 
 	var categoryList ListExpenseCategories
 	categoryList.Categories = make([]ExpenseCategoryResponseItem, 0, len(categories))
@@ -584,14 +618,18 @@ func (api *Api) DeleteExpenseCategoryHandler(r *iz.Request) iz.Responder {
 		})
 	}
 
-	if err := api.Service.DeleteExpenseCategory(ctx, userId, categoryId); err != nil {
+	moveTo := r.URL.Query().Get("move_to")
+	affected, err := api.Service.DeleteExpenseCategory(ctx, userId, categoryId, moveTo)
+	if err != nil {
 		logging.Logger.Errorf("[TraceID=%s] | failed to delete expense category | Error: %v", traceID, err)
 		return RespondError(err)
 	}
 
-	return iz.Respond().Status(200).JSON(OperationResponse{
-		Code:    SUCCESS_CODE,
-		Message: "Category deleted successfully.",
+	return iz.Respond().Status(200).JSON(DeleteCategoryResponse{
+		Code:                 SUCCESS_CODE,
+		Message:              "Category deleted successfully.",
+		AffectedTransactions: affected,
+		MovedTo:              moveTo,
 	})
 }
 
@@ -662,14 +700,18 @@ func (api *Api) DeleteIncomeCategoryHandler(r *iz.Request) iz.Responder {
 		})
 	}
 
-	if err := api.Service.DeleteIncomeCategory(ctx, userId, categoryId); err != nil {
+	moveTo := r.URL.Query().Get("move_to")
+	affected, err := api.Service.DeleteIncomeCategory(ctx, userId, categoryId, moveTo)
+	if err != nil {
 		logging.Logger.Errorf("[TraceID=%s] | failed to delete income category | Error: %v", traceID, err)
 		return RespondError(err)
 	}
 
-	return iz.Respond().Status(200).JSON(OperationResponse{
-		Code:    SUCCESS_CODE,
-		Message: "Category deleted successfully.",
+	return iz.Respond().Status(200).JSON(DeleteCategoryResponse{
+		Code:                 SUCCESS_CODE,
+		Message:              "Category deleted successfully.",
+		AffectedTransactions: affected,
+		MovedTo:              moveTo,
 	})
 }
 
@@ -761,91 +803,240 @@ func (api *Api) DownloadUserData(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	data, err := api.Service.DownloadUserData(ctx, userId)
-	if err != nil {
+	// the archive goes to the client as it is built, headers are sent with
+	// the first byte.
+	started := false
+	open := func() io.Writer {
+		started = true
+		w.Header().Set("Content-Type", "application/zip")
+		w.Header().Set("Content-Disposition", `attachment; filename="budget_tracker_my_data.zip"`)
+		w.WriteHeader(http.StatusOK)
+		return w
+	}
+
+	if err := api.Service.ExportUserData(ctx, userId, open); err != nil {
+		if started {
+			// too late for an error response, the client sees a truncated ZIP
+			logging.Logger.Errorf("[TraceID=%s] | Failed to stream user data: %v", traceID, err)
+			return
+		}
 		logging.Logger.Errorf("[TraceID=%s] | Failed to get user data: %v", traceID, err)
 		http.Error(w, "Failed to get user data", http.StatusInternalServerError)
-		return
 	}
+}
 
-	var buf bytes.Buffer
-	zipWriter := zip.NewWriter(&buf)
+func (api *Api) ImportUserDataHandler(r *iz.Request) iz.Responder {
+	traceID := uuid.NewString()
+	ctx := context.WithValue(r.Context(), contextutil.TraceIDKey, traceID)
 
-	writeJSON := func(filename string, payload interface{}) error {
-		file, err := zipWriter.Create(filename)
-		if err != nil {
-			logging.Logger.Errorf("[TraceID=%s] | Failed to create ZIP file %s: %v", traceID, filename, err)
-			return err
-		}
-		jsonBytes, err := json.MarshalIndent(payload, "", "  ")
-		if err != nil {
-			logging.Logger.Errorf("[TraceID=%s] | Failed to marshal JSON for %s: %v", traceID, filename, err)
-			return err
-		}
-		_, err = file.Write(jsonBytes)
-		if err != nil {
-			logging.Logger.Errorf("[TraceID=%s] | Failed to write JSON to ZIP for %s: %v", traceID, filename, err)
-		}
-		return err
+	userId, ok := r.Context().Value(userIdKey).(string)
+	if !ok {
+		return iz.Respond().Status(500).JSON(appErrors.ErrorResponse{
+			Code:    appErrors.ErrAuth,
+			Message: "UserID not found",
+		})
 	}
 
-	if err := writeJSON("transactions.json", data.Transactions); err != nil {
-		http.Error(w, "Failed to write transactions", http.StatusInternalServerError)
-		return
+	r.Body = http.MaxBytesReader(r.ResponseWriter, r.Body, MAX_IMPORT_UPLOAD_SIZE)
+	if err := r.ParseMultipartForm(MAX_IMPORT_UPLOAD_SIZE); err != nil {
+		return iz.Respond().Status(400).JSON(appErrors.ErrorResponse{
+			Code:    appErrors.ErrInvalidInput,
+			Message: "Maximum archive size is 32MB",
+		})
 	}
-	if err := writeJSON("expense_categories.json", data.ExpenseCategories); err != nil {
-		http.Error(w, "Failed to write expense categories", http.StatusInternalServerError)
-		return
+
+	file, header, err := r.FormFile("file")
+	if err != nil {
+		return iz.Respond().Status(400).JSON(appErrors.ErrorResponse{
+			Code:    appErrors.ErrInvalidInput,
+			Message: "Invalid archive",
+		})
 	}
-	if err := writeJSON("income_categories.json", data.IncomeCategories); err != nil {
-		http.Error(w, "Failed to write income categories", http.StatusInternalServerError)
-		return
+	defer file.Close()
+
+	data, err := budget.ReadUserDataArchive(file, header.Size)
+	if err != nil {
+		return RespondError(err)
 	}
 
-	if err := zipWriter.Close(); err != nil {
-		logging.Logger.Errorf("[TraceID=%s] | Failed to finalize ZIP: %v", traceID, err)
-		http.Error(w, "Failed to finalize ZIP file", http.StatusInternalServerError)
-		return
+	opts := budget.ImportOptions{
+		DryRun:     r.URL.Query().Get("dry_run") == "true",
+		OnConflict: r.URL.Query().Get("conflict"),
+	}
+
+	report, err := api.Service.ImportUserData(ctx, userId, data, opts)
+	if err != nil {
+		return RespondError(err)
+	}
+
+	return iz.Respond().Status(200).JSON(ImportReportToHttp(report))
+}
+
+func (api *Api) ImportCSVStatementHandler(r *iz.Request) iz.Responder {
+	traceID := uuid.NewString()
+	ctx := context.WithValue(r.Context(), contextutil.TraceIDKey, traceID)
+
+	userId, ok := r.Context().Value(userIdKey).(string)
+	if !ok {
+		return iz.Respond().Status(500).JSON(appErrors.ErrorResponse{
+			Code:    appErrors.ErrAuth,
+			Message: "UserID not found",
+		})
+	}
+
+	file, header, err := openStatementUpload(r)
+	if err != nil {
+		return RespondError(err)
+	}
+	defer file.Close()
+
+	var mappingReq CSVImportMappingRequest
+	if err := json.Unmarshal([]byte(r.FormValue("mapping")), &mappingReq); err != nil {
+		return iz.Respond().Status(400).JSON(appErrors.ErrorResponse{
+			Code:    appErrors.ErrInvalidInput,
+			Message: "Invalid mapping",
+		})
+	}
+
+	delimiter, err := budget.ParseCSVDelimiter(mappingReq.Delimiter)
+	if err != nil {
+		return RespondError(err)
 	}
 
-	w.Header().Set("Content-Type", "application/zip")
-	w.Header().Set("Content-Disposition", `attachment; filename="budget_tracker_my_data.zip"`)
-	w.WriteHeader(http.StatusOK)
+	rows, rowErrors, err := budget.ParseCSVStatement(file, budget.CSVMapping{
+		HasHeader:         mappingReq.HasHeader,
+		SkipRows:          mappingReq.SkipRows,
+		Delimiter:         delimiter,
+		DateColumn:        mappingReq.DateColumn,
+		AmountColumn:      mappingReq.AmountColumn,
+		DebitColumn:       mappingReq.DebitColumn,
+		CreditColumn:      mappingReq.CreditColumn,
+		CurrencyColumn:    mappingReq.CurrencyColumn,
+		DescriptionColumn: mappingReq.DescriptionColumn,
+		DateFormat:        mappingReq.DateFormat,
+		DecimalSeparator:  mappingReq.DecimalSeparator,
+		SignConvention:    mappingReq.SignConvention,
+		Currency:          mappingReq.Currency,
+	})
+	if err != nil {
+		return RespondError(err)
+	}
 
-	_, err = w.Write(buf.Bytes())
+	preview := r.URL.Query().Get("preview") == "true"
+	report, err := api.Service.ImportStatement(ctx, userId, header.Filename, rows, rowErrors, mappingReq.ToBudget(), preview)
 	if err != nil {
-		logging.Logger.Errorf("[TraceID=%s] | Failed to write ZIP to response: %v", traceID, err)
+		return RespondError(err)
 	}
+
+	return iz.Respond().Status(200).JSON(StatementImportToHttp(report))
 }
 
-func (api *Api) CheckToken(r *iz.Request) iz.Responder {
+func (api *Api) ImportStatementHandler(r *iz.Request) iz.Responder {
 	traceID := uuid.NewString()
 	ctx := context.WithValue(r.Context(), contextutil.TraceIDKey, traceID)
 
-	token := r.Header.Get("Authorization")
-	if token == "" {
-		return iz.Respond().Status(401).JSON(appErrors.ErrorResponse{
+	userId, ok := r.Context().Value(userIdKey).(string)
+	if !ok {
+		return iz.Respond().Status(500).JSON(appErrors.ErrorResponse{
 			Code:    appErrors.ErrAuth,
-			Message: "Authorization header is required.",
+			Message: "UserID not found",
 		})
 	}
 
-	validToken, err := api.Service.CheckSession(ctx, token)
+	file, header, err := openStatementUpload(r)
 	if err != nil {
 		return RespondError(err)
 	}
+	defer file.Close()
 
-	if validToken == "" {
-		return iz.Respond().Status(401).JSON(appErrors.ErrorResponse{
+	var mappingReq StatementImportMappingRequest
+	if err := json.Unmarshal([]byte(r.FormValue("mapping")), &mappingReq); err != nil {
+		return iz.Respond().Status(400).JSON(appErrors.ErrorResponse{
+			Code:    appErrors.ErrInvalidInput,
+			Message: "Invalid mapping",
+		})
+	}
+
+	var rows []budget.StatementRow
+	var rowErrors []budget.ImportRowError
+	switch r.PathValue("format") {
+	case "ofx", "qfx":
+		rows, rowErrors, err = budget.ParseOFXStatement(file)
+	case "qif":
+		rows, rowErrors, err = budget.ParseQIFStatement(file, mappingReq.Currency, mappingReq.DateOrder, mappingReq.DecimalSeparator)
+	case "camt053":
+		rows, rowErrors, err = budget.ParseCamt053Statement(file)
+	case "mt940":
+		rows, rowErrors, err = budget.ParseMT940Statement(file)
+	default:
+		return iz.Respond().Status(400).JSON(appErrors.ErrorResponse{
+			Code:    appErrors.ErrInvalidInput,
+			Message: "Unsupported statement format, allowed values: ofx, qfx, qif, camt053, mt940",
+		})
+	}
+	if err != nil {
+		return RespondError(err)
+	}
+
+	preview := r.URL.Query().Get("preview") == "true"
+	report, err := api.Service.ImportStatement(ctx, userId, header.Filename, rows, rowErrors, mappingReq.ToBudget(), preview)
+	if err != nil {
+		return RespondError(err)
+	}
+
+	return iz.Respond().Status(200).JSON(StatementImportToHttp(report))
+}
+
+// openStatementUpload returns the "file" part of a statement import request.
+func openStatementUpload(r *iz.Request) (multipart.File, *multipart.FileHeader, error) {
+	r.Body = http.MaxBytesReader(r.ResponseWriter, r.Body, MAX_STATEMENT_UPLOAD_SIZE)
+	if err := r.ParseMultipartForm(MAX_STATEMENT_UPLOAD_SIZE); err != nil {
+		return nil, nil, appErrors.ErrorResponse{
+			Code:    appErrors.ErrInvalidInput,
+			Message: "Maximum statement size is 5MB",
+		}
+	}
+
+	file, header, err := r.FormFile("file")
+	if err != nil {
+		return nil, nil, appErrors.ErrorResponse{
+			Code:    appErrors.ErrInvalidInput,
+			Message: "Statement file is required",
+		}
+	}
+	return file, header, nil
+}
+
+func (api *Api) ExportJournalHandler(r *iz.Request) iz.Responder {
+	traceID := uuid.NewString()
+	ctx := context.WithValue(r.Context(), contextutil.TraceIDKey, traceID)
+
+	userId, ok := r.Context().Value(userIdKey).(string)
+	if !ok {
+		return iz.Respond().Status(500).JSON(appErrors.ErrorResponse{
 			Code:    appErrors.ErrAuth,
-			Message: "Authorization failed.",
+			Message: "UserID not found",
 		})
 	}
 
-	return iz.Respond().Status(200).Text(validToken)
+	opts := budget.JournalOptions{
+		Format:        r.URL.Query().Get("format"),
+		AssetsAccount: r.URL.Query().Get("account"),
+	}
+
+	var buf bytes.Buffer
+	if err := api.Service.ExportJournal(ctx, userId, opts, &buf); err != nil {
+		return RespondError(err)
+	}
+
+	return iz.Respond().
+		Status(200).
+		Header("Content-Type", "text/plain; charset=utf-8").
+		Header("Content-Disposition", fmt.Sprintf(`attachment; filename="budget_tracker.%s"`, opts.Format)).
+		Text(buf.String())
 }
 
-func (api *Api) DeleteUserHandler(r *iz.Request) iz.Responder {
+func (api *Api) ExportTransactionsHandler(r *iz.Request) iz.Responder {
 	traceID := uuid.NewString()
 	ctx := context.WithValue(r.Context(), contextutil.TraceIDKey, traceID)
 
@@ -857,30 +1048,88 @@ func (api *Api) DeleteUserHandler(r *iz.Request) iz.Responder {
 		})
 	}
 
-	var deleteReqRaw DeleteUserRequest
-	if err := json.NewDecoder(r.Body).Decode(&deleteReqRaw); err != nil {
+	// the remaining parameters are the same filters GET /api/transaction takes
+	params := r.URL.Query()
+	opts := budget.TransactionExportOptions{
+		Format: params.Get("format"),
+		Locale: params.Get("locale"),
+	}
+	if d := params.Get("delimiter"); d != "" {
+		delimiter, err := budget.ParseCSVDelimiter(d)
+		if err != nil {
+			return RespondError(err)
+		}
+		opts.Delimiter = delimiter
+	}
+	params.Del("format")
+	params.Del("locale")
+	params.Del("delimiter")
+
+	filter, err := TransactionCheckParams(params)
+	if err != nil {
+		return RespondError(err)
+	}
+
+	contentType := "text/csv; charset=utf-8"
+	if opts.Format == budget.TRANSACTION_EXPORT_XLSX {
+		contentType = "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet"
+	}
+
+	// rows are written straight to the client, headers go out with the first one
+	started := false
+	open := func() io.Writer {
+		started = true
+		w := r.ResponseWriter
+		w.Header().Set("Content-Type", contentType)
+		w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="transactions.%s"`, opts.Format))
+		w.WriteHeader(http.StatusOK)
+		return w
+	}
+
+	if err := api.Service.ExportTransactions(ctx, userId, filter, opts, open); err != nil {
+		if !started {
+			return RespondError(err)
+		}
+		logging.Logger.Errorf("[TraceID=%s] | failed to stream transaction export | Error: %v", traceID, err)
+	}
+	return iz.Done()
+}
+
+func (api *Api) SaveRecurringTransactionHandler(r *iz.Request) iz.Responder {
+	traceID := uuid.NewString()
+	ctx := context.WithValue(r.Context(), contextutil.TraceIDKey, traceID)
+
+	userId, ok := r.Context().Value(userIdKey).(string)
+	if !ok {
+		return iz.Respond().Status(500).JSON(appErrors.ErrorResponse{
+			Code:    appErrors.ErrAuth,
+			Message: "UserID not found",
+		})
+	}
+
+	var req RecurringTransactionRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
 		return iz.Respond().Status(400).JSON(appErrors.ErrorResponse{
 			Code:    appErrors.ErrInvalidInput,
-			Message: fmt.Sprintf("Invalid request body: %v", err.Error()),
+			Message: "Invalid request body",
 		})
 	}
 
-	deleteReq := auth.DeleteUser{
-		Password: deleteReqRaw.Password,
-		Reason:   deleteReqRaw.Reason,
+	recurringReq, err := req.ToBudget()
+	if err != nil {
+		return RespondError(err)
 	}
 
-	if err := api.Service.DeleteUser(ctx, userId, deleteReq); err != nil {
+	recurring, err := api.Service.SaveRecurringTransaction(ctx, userId, recurringReq)
+	if err != nil {
+		logging.Logger.Errorf("[TraceID=%s] | failed to save recurring transaction | Error: %v", traceID, err)
 		return RespondError(err)
 	}
 
-	return iz.Respond().Status(200).JSON(OperationResponse{
-		Code:    SUCCESS_CODE,
-		Message: "Account deleted successfully.",
-	})
+	return iz.Respond().Status(201).JSON(RecurringTransactionToHttp(recurring))
 }
 
-func (api *Api) GetAccountInfo(r *iz.Request) iz.Responder {
+func (api *Api) GetRecurringTransactionsHandler(r *iz.Request) iz.Responder {
 	traceID := uuid.NewString()
 	ctx := context.WithValue(r.Context(), contextutil.TraceIDKey, traceID)
 
@@ -892,24 +1141,2001 @@ func (api *Api) GetAccountInfo(r *iz.Request) iz.Responder {
 		})
 	}
 
-	accInfoRaw, err := api.Service.GetAccountInfo(ctx, userId)
+	recurring, err := api.Service.GetRecurringTransactions(ctx, userId)
 	if err != nil {
+		logging.Logger.Errorf("[TraceID=%s] | failed to get recurring transactions | Error: %v", traceID, err)
 		return RespondError(err)
 	}
-	accInfo := AccountInfoToHttp(accInfoRaw)
 
-	return iz.Respond().Status(200).JSON(accInfo)
+	var list ListRecurringTransactionResponse
+	list.RecurringTransactions = make([]RecurringTransactionItem, 0, len(recurring))
+	for _, rt := range recurring {
+		list.RecurringTransactions = append(list.RecurringTransactions, RecurringTransactionToHttp(rt))
+	}
+
+	return iz.Respond().Status(200).JSON(list)
 }
 
-func RespondError(err error) iz.Responder {
-	var errResp appErrors.ErrorResponse
-	if errors.As(err, &errResp) {
-		return iz.Respond().Status(HttpStatusFromErrorCode(errResp.Code)).JSON(errResp)
+func (api *Api) GetUpcomingTransactionsHandler(r *iz.Request) iz.Responder {
+	traceID := uuid.NewString()
+	ctx := context.WithValue(r.Context(), contextutil.TraceIDKey, traceID)
+
+	userId, ok := r.Context().Value(userIdKey).(string)
+	if !ok {
+		return iz.Respond().Status(500).JSON(appErrors.ErrorResponse{
+			Code:    appErrors.ErrAuth,
+			Message: "UserID not found",
+		})
 	}
 
-	return iz.Respond().Status(500).JSON(appErrors.ErrorResponse{
-		Code:    "UNKNOWN",
-		Message: "UNEXPECTED ERROR",
-	})
+	days := 0
+	if d := r.URL.Query().Get("days"); d != "" {
+		n, err := strconv.Atoi(d)
+		if err != nil {
+			return iz.Respond().Status(400).JSON(appErrors.ErrorResponse{
+				Code:    appErrors.ErrInvalidInput,
+				Message: "Days must be a number",
+			})
+		}
+		days = n
+	}
+
+	upcoming, err := api.Service.GetUpcomingTransactions(ctx, userId, days)
+	if err != nil {
+		logging.Logger.Errorf("[TraceID=%s] | failed to get upcoming transactions | Error: %v", traceID, err)
+		return RespondError(err)
+	}
+
+	var list ListUpcomingTransactionResponse
+	list.Upcoming = make([]UpcomingTransactionItem, 0, len(upcoming))
+	for _, u := range upcoming {
+		list.Upcoming = append(list.Upcoming, UpcomingTransactionToHttp(u))
+	}
+
+	return iz.Respond().Status(200).JSON(list)
+}
+
+// RecurringTransactionActionHandler pauses, resumes or skips the next
+// occurrence of a recurring transaction.
+func (api *Api) RecurringTransactionActionHandler(r *iz.Request) iz.Responder {
+	traceID := uuid.NewString()
+	ctx := context.WithValue(r.Context(), contextutil.TraceIDKey, traceID)
+
+	userId, ok := r.Context().Value(userIdKey).(string)
+	if !ok {
+		return iz.Respond().Status(500).JSON(appErrors.ErrorResponse{
+			Code:    appErrors.ErrAuth,
+			Message: "UserID not found",
+		})
+	}
+
+	id := r.PathValue("id")
+	var recurring budget.RecurringTransaction
+	var err error
+	switch r.PathValue("action") {
+	case "pause":
+		recurring, err = api.Service.PauseRecurringTransaction(ctx, userId, id)
+	case "resume":
+		recurring, err = api.Service.ResumeRecurringTransaction(ctx, userId, id)
+	case "skip":
+		recurring, err = api.Service.SkipRecurringTransaction(ctx, userId, id)
+	default:
+		return iz.Respond().Status(404).JSON(appErrors.ErrorResponse{
+			Code:    appErrors.ErrNotFound,
+			Message: "Unknown action, allowed values: pause, resume, skip",
+		})
+	}
+	if err != nil {
+		logging.Logger.Errorf("[TraceID=%s] | failed to %s recurring transaction | Error: %v", traceID, r.PathValue("action"), err)
+		return RespondError(err)
+	}
+
+	return iz.Respond().Status(200).JSON(RecurringTransactionToHttp(recurring))
+}
+
+func (api *Api) DeleteRecurringTransactionHandler(r *iz.Request) iz.Responder {
+	traceID := uuid.NewString()
+	ctx := context.WithValue(r.Context(), contextutil.TraceIDKey, traceID)
+
+	userId, ok := r.Context().Value(userIdKey).(string)
+	if !ok {
+		return iz.Respond().Status(500).JSON(appErrors.ErrorResponse{
+			Code:    appErrors.ErrAuth,
+			Message: "UserID not found",
+		})
+	}
+
+	if err := api.Service.DeleteRecurringTransaction(ctx, userId, r.PathValue("id")); err != nil {
+		logging.Logger.Errorf("[TraceID=%s] | failed to delete recurring transaction | Error: %v", traceID, err)
+		return RespondError(err)
+	}
+
+	return iz.Respond().Status(200).JSON(OperationResponse{
+		Code:    SUCCESS_CODE,
+		Message: "Recurring transaction deleted.",
+	})
+}
+
+func (api *Api) SaveWalletHandler(r *iz.Request) iz.Responder {
+	traceID := uuid.NewString()
+	ctx := context.WithValue(r.Context(), contextutil.TraceIDKey, traceID)
+
+	userId, ok := r.Context().Value(userIdKey).(string)
+	if !ok {
+		return iz.Respond().Status(500).JSON(appErrors.ErrorResponse{
+			Code:    appErrors.ErrAuth,
+			Message: "UserID not found",
+		})
+	}
+
+	var req WalletRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		return iz.Respond().Status(400).JSON(appErrors.ErrorResponse{
+			Code:    appErrors.ErrInvalidInput,
+			Message: "Invalid request body",
+		})
+	}
+
+	wallet, err := api.Service.SaveWallet(ctx, userId, req.ToBudget())
+	if err != nil {
+		logging.Logger.Errorf("[TraceID=%s] | failed to save wallet | Error: %v", traceID, err)
+		return RespondError(err)
+	}
+
+	return iz.Respond().Status(201).JSON(WalletToHttp(wallet))
+}
+
+func (api *Api) GetWalletsHandler(r *iz.Request) iz.Responder {
+	traceID := uuid.NewString()
+	ctx := context.WithValue(r.Context(), contextutil.TraceIDKey, traceID)
+
+	userId, ok := r.Context().Value(userIdKey).(string)
+	if !ok {
+		return iz.Respond().Status(500).JSON(appErrors.ErrorResponse{
+			Code:    appErrors.ErrAuth,
+			Message: "UserID not found",
+		})
+	}
+
+	wallets, err := api.Service.GetWallets(ctx, userId)
+	if err != nil {
+		logging.Logger.Errorf("[TraceID=%s] | failed to get wallets | Error: %v", traceID, err)
+		return RespondError(err)
+	}
+
+	var list ListWalletResponse
+	list.Wallets = make([]WalletItem, 0, len(wallets))
+	for _, w := range wallets {
+		list.Wallets = append(list.Wallets, WalletToHttp(w))
+	}
+
+	return iz.Respond().Status(200).JSON(list)
+}
+
+func (api *Api) UpdateWalletHandler(r *iz.Request) iz.Responder {
+	traceID := uuid.NewString()
+	ctx := context.WithValue(r.Context(), contextutil.TraceIDKey, traceID)
+
+	userId, ok := r.Context().Value(userIdKey).(string)
+	if !ok {
+		return iz.Respond().Status(500).JSON(appErrors.ErrorResponse{
+			Code:    appErrors.ErrAuth,
+			Message: "UserID not found",
+		})
+	}
+
+	var req WalletRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		return iz.Respond().Status(400).JSON(appErrors.ErrorResponse{
+			Code:    appErrors.ErrInvalidInput,
+			Message: "Invalid request body",
+		})
+	}
+
+	wallet, err := api.Service.UpdateWallet(ctx, userId, r.PathValue("id"), req.ToBudget())
+	if err != nil {
+		logging.Logger.Errorf("[TraceID=%s] | failed to update wallet | Error: %v", traceID, err)
+		return RespondError(err)
+	}
+
+	return iz.Respond().Status(200).JSON(WalletToHttp(wallet))
+}
+
+func (api *Api) DeleteWalletHandler(r *iz.Request) iz.Responder {
+	traceID := uuid.NewString()
+	ctx := context.WithValue(r.Context(), contextutil.TraceIDKey, traceID)
+
+	userId, ok := r.Context().Value(userIdKey).(string)
+	if !ok {
+		return iz.Respond().Status(500).JSON(appErrors.ErrorResponse{
+			Code:    appErrors.ErrAuth,
+			Message: "UserID not found",
+		})
+	}
+
+	if err := api.Service.DeleteWallet(ctx, userId, r.PathValue("id")); err != nil {
+		logging.Logger.Errorf("[TraceID=%s] | failed to delete wallet | Error: %v", traceID, err)
+		return RespondError(err)
+	}
+
+	return iz.Respond().Status(200).JSON(OperationResponse{
+		Code:    SUCCESS_CODE,
+		Message: "Wallet deleted.",
+	})
+}
+
+// GetWalletRegisterHandler lists the transactions of a wallet with the
+// running balance after each one.
+func (api *Api) GetWalletRegisterHandler(r *iz.Request) iz.Responder {
+	traceID := uuid.NewString()
+	ctx := context.WithValue(r.Context(), contextutil.TraceIDKey, traceID)
+
+	userId, ok := r.Context().Value(userIdKey).(string)
+	if !ok {
+		return iz.Respond().Status(500).JSON(appErrors.ErrorResponse{
+			Code:    appErrors.ErrAuth,
+			Message: "UserID not found",
+		})
+	}
+
+	wallet, entries, err := api.Service.GetWalletRegister(ctx, userId, r.PathValue("id"))
+	if err != nil {
+		logging.Logger.Errorf("[TraceID=%s] | failed to get wallet register | Error: %v", traceID, err)
+		return RespondError(err)
+	}
+
+	response := WalletRegisterResponse{
+		Wallet:  WalletToHttp(wallet),
+		Entries: make([]WalletEntryItem, 0, len(entries)),
+	}
+	for _, e := range entries {
+		response.Entries = append(response.Entries, WalletEntryItem{
+			Transaction: TransactionToHttp(e.Transaction),
+			Amount:      e.Amount,
+			Balance:     e.Balance,
+		})
+	}
+
+	return iz.Respond().Status(200).JSON(response)
+}
+
+// StartReconciliationHandler opens a reconciliation of a wallet against a
+// statement, or changes the statement of the open one.
+func (api *Api) StartReconciliationHandler(r *iz.Request) iz.Responder {
+	traceID := uuid.NewString()
+	ctx := context.WithValue(r.Context(), contextutil.TraceIDKey, traceID)
+
+	userId, ok := r.Context().Value(userIdKey).(string)
+	if !ok {
+		return iz.Respond().Status(500).JSON(appErrors.ErrorResponse{
+			Code:    appErrors.ErrAuth,
+			Message: "UserID not found",
+		})
+	}
+
+	var req ReconciliationRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		return iz.Respond().Status(400).JSON(appErrors.ErrorResponse{
+			Code:    appErrors.ErrInvalidInput,
+			Message: "Invalid request body",
+		})
+	}
+
+	reconciliationReq, err := req.ToBudget()
+	if err != nil {
+		return RespondError(err)
+	}
+
+	status, err := api.Service.StartReconciliation(ctx, userId, r.PathValue("id"), reconciliationReq)
+	if err != nil {
+		logging.Logger.Errorf("[TraceID=%s] | failed to start reconciliation | Error: %v", traceID, err)
+		return RespondError(err)
+	}
+
+	return iz.Respond().Status(200).JSON(ReconciliationStatusToHttp(status))
+}
+
+// GetReconciliationHandler returns the difference to the open statement and
+// the unreconciled transactions of a wallet.
+func (api *Api) GetReconciliationHandler(r *iz.Request) iz.Responder {
+	traceID := uuid.NewString()
+	ctx := context.WithValue(r.Context(), contextutil.TraceIDKey, traceID)
+
+	userId, ok := r.Context().Value(userIdKey).(string)
+	if !ok {
+		return iz.Respond().Status(500).JSON(appErrors.ErrorResponse{
+			Code:    appErrors.ErrAuth,
+			Message: "UserID not found",
+		})
+	}
+
+	status, err := api.Service.GetReconciliation(ctx, userId, r.PathValue("id"))
+	if err != nil {
+		logging.Logger.Errorf("[TraceID=%s] | failed to get reconciliation | Error: %v", traceID, err)
+		return RespondError(err)
+	}
+
+	return iz.Respond().Status(200).JSON(ReconciliationStatusToHttp(status))
+}
+
+func (api *Api) ClearTransactionsHandler(r *iz.Request) iz.Responder {
+	traceID := uuid.NewString()
+	ctx := context.WithValue(r.Context(), contextutil.TraceIDKey, traceID)
+
+	userId, ok := r.Context().Value(userIdKey).(string)
+	if !ok {
+		return iz.Respond().Status(500).JSON(appErrors.ErrorResponse{
+			Code:    appErrors.ErrAuth,
+			Message: "UserID not found",
+		})
+	}
+
+	var req ClearTransactionsRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		return iz.Respond().Status(400).JSON(appErrors.ErrorResponse{
+			Code:    appErrors.ErrInvalidInput,
+			Message: "Invalid request body",
+		})
+	}
+
+	status, err := api.Service.SetTransactionsCleared(ctx, userId, r.PathValue("id"), req.TransactionIds, req.Cleared)
+	if err != nil {
+		logging.Logger.Errorf("[TraceID=%s] | failed to mark transactions as cleared | Error: %v", traceID, err)
+		return RespondError(err)
+	}
+
+	return iz.Respond().Status(200).JSON(ReconciliationStatusToHttp(status))
+}
+
+func (api *Api) FinishReconciliationHandler(r *iz.Request) iz.Responder {
+	traceID := uuid.NewString()
+	ctx := context.WithValue(r.Context(), contextutil.TraceIDKey, traceID)
+
+	userId, ok := r.Context().Value(userIdKey).(string)
+	if !ok {
+		return iz.Respond().Status(500).JSON(appErrors.ErrorResponse{
+			Code:    appErrors.ErrAuth,
+			Message: "UserID not found",
+		})
+	}
+
+	reconciliation, err := api.Service.FinishReconciliation(ctx, userId, r.PathValue("id"))
+	if err != nil {
+		logging.Logger.Errorf("[TraceID=%s] | failed to finish reconciliation | Error: %v", traceID, err)
+		return RespondError(err)
+	}
+
+	return iz.Respond().Status(200).JSON(ReconciliationToHttp(reconciliation))
+}
+
+func (api *Api) CheckToken(r *iz.Request) iz.Responder {
+	traceID := uuid.NewString()
+	ctx := context.WithValue(r.Context(), contextutil.TraceIDKey, traceID)
+
+	token := r.Header.Get("Authorization")
+	if token == "" {
+		return iz.Respond().Status(401).JSON(appErrors.ErrorResponse{
+			Code:    appErrors.ErrAuth,
+			Message: "Authorization header is required.",
+		})
+	}
+
+	validToken, err := api.Service.CheckSession(ctx, token)
+	if err != nil {
+		return RespondError(err)
+	}
+
+	if validToken == "" {
+		return iz.Respond().Status(401).JSON(appErrors.ErrorResponse{
+			Code:    appErrors.ErrAuth,
+			Message: "Authorization failed.",
+		})
+	}
+
+	return iz.Respond().Status(200).Text(validToken)
+}
+
+func (api *Api) DeleteUserHandler(r *iz.Request) iz.Responder {
+	traceID := uuid.NewString()
+	ctx := context.WithValue(r.Context(), contextutil.TraceIDKey, traceID)
+
+	userId, ok := r.Context().Value(userIdKey).(string)
+	if !ok {
+		return iz.Respond().Status(500).JSON(appErrors.ErrorResponse{
+			Code:    appErrors.ErrAuth,
+			Message: "UserID not found",
+		})
+	}
+
+	var deleteReqRaw DeleteUserRequest
+	if err := json.NewDecoder(r.Body).Decode(&deleteReqRaw); err != nil {
+		return iz.Respond().Status(400).JSON(appErrors.ErrorResponse{
+			Code:    appErrors.ErrInvalidInput,
+			Message: fmt.Sprintf("Invalid request body: %v", err.Error()),
+		})
+	}
+
+	deleteReq := auth.DeleteUser{
+		Password: deleteReqRaw.Password,
+		Reason:   deleteReqRaw.Reason,
+	}
+
+	purgeAt, err := api.Service.DeleteUser(ctx, userId, deleteReq)
+	if err != nil {
+		return RespondError(err)
+	}
+
+	return iz.Respond().Status(200).JSON(OperationResponse{
+		Code:    SUCCESS_CODE,
+		Message: "Account scheduled for deletion, log in before the date to restore it.",
+		Extra:   purgeAt.Format(time.RFC3339),
+	})
+}
+
+func (api *Api) RestoreUserHandler(r *iz.Request) iz.Responder {
+	traceID := uuid.NewString()
+	ctx := context.WithValue(r.Context(), contextutil.TraceIDKey, traceID)
+
+	var restoreRequest UserLoginRequest
+	if err := json.NewDecoder(r.Body).Decode(&restoreRequest); err != nil {
+		return iz.Respond().Status(400).JSON(appErrors.ErrorResponse{
+			Code:    appErrors.ErrInvalidInput,
+			Message: fmt.Sprintf("Invalid request body: %v", err.Error()),
+		})
+	}
+
+	credentials := auth.UserCredentialsPure{
+		UserName:      restoreRequest.UserName,
+		PasswordPlain: restoreRequest.Password,
+	}
+
+	token, err := api.Service.RestoreUser(ctx, credentials)
+	if err != nil {
+		return RespondError(err)
+	}
+
+	return iz.Respond().Status(200).JSON(OperationResponse{
+		Code:    SUCCESS_CODE,
+		Message: "Account restored, welcome back",
+		Extra:   token,
+	})
+}
+
+func (api *Api) GetAccountInfo(r *iz.Request) iz.Responder {
+	traceID := uuid.NewString()
+	ctx := context.WithValue(r.Context(), contextutil.TraceIDKey, traceID)
+
+	userId, ok := r.Context().Value(userIdKey).(string)
+	if !ok {
+		return iz.Respond().Status(500).JSON(appErrors.ErrorResponse{
+			Code:    appErrors.ErrAuth,
+			Message: "UserID not found",
+		})
+	}
+
+	accInfoRaw, err := api.Service.GetAccountInfo(ctx, userId)
+	if err != nil {
+		return RespondError(err)
+	}
+	accInfo := AccountInfoToHttp(accInfoRaw)
+
+	return iz.Respond().Status(200).JSON(accInfo)
+}
+
+func (api *Api) UpdateAccountHandler(r *iz.Request) iz.Responder {
+	traceID := uuid.NewString()
+	ctx := context.WithValue(r.Context(), contextutil.TraceIDKey, traceID)
+
+	userId, ok := r.Context().Value(userIdKey).(string)
+	if !ok {
+		return iz.Respond().Status(500).JSON(appErrors.ErrorResponse{
+			Code:    appErrors.ErrAuth,
+			Message: "UserID not found",
+		})
+	}
+
+	var updateReq UpdateAccountRequest
+	if err := json.NewDecoder(r.Body).Decode(&updateReq); err != nil {
+		return iz.Respond().Status(400).JSON(appErrors.ErrorResponse{
+			Code:    appErrors.ErrInvalidInput,
+			Message: fmt.Sprintf("Invalid request body: %v", err.Error()),
+		})
+	}
+
+	update := auth.UpdateUser{
+		UserName: updateReq.UserName,
+		FullName: updateReq.FullName,
+		Email:    updateReq.Email,
+	}
+
+	accInfoRaw, err := api.Service.UpdateAccount(ctx, userId, update)
+	if err != nil {
+		logging.Logger.Errorf("[TraceID=%s] | failed to update account | Error: %v", traceID, err)
+		return RespondError(err)
+	}
+	accInfo := AccountInfoToHttp(accInfoRaw)
+
+	return iz.Respond().Status(200).JSON(accInfo)
+}
+
+func (api *Api) ConfirmEmailHandler(r *iz.Request) iz.Responder {
+	traceID := uuid.NewString()
+	ctx := context.WithValue(r.Context(), contextutil.TraceIDKey, traceID)
+
+	userId, ok := r.Context().Value(userIdKey).(string)
+	if !ok {
+		return iz.Respond().Status(500).JSON(appErrors.ErrorResponse{
+			Code:    appErrors.ErrAuth,
+			Message: "UserID not found",
+		})
+	}
+
+	var confirmReq ConfirmEmailRequest
+	if err := json.NewDecoder(r.Body).Decode(&confirmReq); err != nil {
+		return iz.Respond().Status(400).JSON(appErrors.ErrorResponse{
+			Code:    appErrors.ErrInvalidInput,
+			Message: fmt.Sprintf("Invalid request body: %v", err.Error()),
+		})
+	}
+
+	if err := api.Service.ConfirmEmailChange(ctx, userId, confirmReq.Code); err != nil {
+		logging.Logger.Errorf("[TraceID=%s] | failed to confirm email change | Error: %v", traceID, err)
+		return RespondError(err)
+	}
+
+	return iz.Respond().Status(200).JSON(OperationResponse{
+		Code:    SUCCESS_CODE,
+		Message: "Email confirmed successfully.",
+	})
+}
+
+func (api *Api) ChangePasswordHandler(r *iz.Request) iz.Responder {
+	traceID := uuid.NewString()
+	ctx := context.WithValue(r.Context(), contextutil.TraceIDKey, traceID)
+
+	userId, ok := r.Context().Value(userIdKey).(string)
+	if !ok {
+		return iz.Respond().Status(500).JSON(appErrors.ErrorResponse{
+			Code:    appErrors.ErrAuth,
+			Message: "UserID not found",
+		})
+	}
+
+	var changeReq ChangePasswordRequest
+	if err := json.NewDecoder(r.Body).Decode(&changeReq); err != nil {
+		return iz.Respond().Status(400).JSON(appErrors.ErrorResponse{
+			Code:    appErrors.ErrInvalidInput,
+			Message: fmt.Sprintf("Invalid request body: %v", err.Error()),
+		})
+	}
+
+	change := auth.ChangePassword{
+		CurrentPassword: changeReq.CurrentPassword,
+		NewPassword:     changeReq.NewPassword,
+	}
+
+	if err := api.Service.ChangePassword(ctx, userId, change); err != nil {
+		logging.Logger.Errorf("[TraceID=%s] | failed to change password | Error: %v", traceID, err)
+		return RespondError(err)
+	}
+
+	return iz.Respond().Status(200).JSON(OperationResponse{
+		Code:    SUCCESS_CODE,
+		Message: "Password changed successfully.",
+	})
+}
+
+func (api *Api) ForgotPasswordHandler(r *iz.Request) iz.Responder {
+	traceID := uuid.NewString()
+	ctx := context.WithValue(r.Context(), contextutil.TraceIDKey, traceID)
+
+	var forgotReq ForgotPasswordRequest
+	if err := json.NewDecoder(r.Body).Decode(&forgotReq); err != nil {
+		return iz.Respond().Status(400).JSON(appErrors.ErrorResponse{
+			Code:    appErrors.ErrInvalidInput,
+			Message: fmt.Sprintf("Invalid request body: %v", err.Error()),
+		})
+	}
+
+	if err := api.Service.RequestPasswordReset(ctx, forgotReq.Email); err != nil {
+		logging.Logger.Errorf("[TraceID=%s] | failed to request password reset | Error: %v", traceID, err)
+		return RespondError(err)
+	}
+
+	return iz.Respond().Status(200).JSON(OperationResponse{
+		Code:    SUCCESS_CODE,
+		Message: "If an account uses this email, a reset code has been sent to it.",
+	})
+}
+
+func (api *Api) ResetPasswordHandler(r *iz.Request) iz.Responder {
+	traceID := uuid.NewString()
+	ctx := context.WithValue(r.Context(), contextutil.TraceIDKey, traceID)
+
+	var resetReq ResetPasswordRequest
+	if err := json.NewDecoder(r.Body).Decode(&resetReq); err != nil {
+		return iz.Respond().Status(400).JSON(appErrors.ErrorResponse{
+			Code:    appErrors.ErrInvalidInput,
+			Message: fmt.Sprintf("Invalid request body: %v", err.Error()),
+		})
+	}
+
+	reset := auth.ResetPassword{
+		Token:       resetReq.Code,
+		NewPassword: resetReq.NewPassword,
+	}
+
+	if err := api.Service.ResetPassword(ctx, reset); err != nil {
+		logging.Logger.Errorf("[TraceID=%s] | failed to reset password | Error: %v", traceID, err)
+		return RespondError(err)
+	}
+
+	return iz.Respond().Status(200).JSON(OperationResponse{
+		Code:    SUCCESS_CODE,
+		Message: "Password reset successfully, please log in again.",
+	})
+}
+
+func RespondError(err error) iz.Responder {
+	var errResp appErrors.ErrorResponse
+	if errors.As(err, &errResp) {
+		return iz.Respond().Status(HttpStatusFromErrorCode(errResp.Code)).JSON(errResp)
+	}
+
+	return iz.Respond().Status(500).JSON(appErrors.ErrorResponse{
+		Code:    "UNKNOWN",
+		Message: "UNEXPECTED ERROR",
+	})
+
+}
+
+func (api *Api) SaveTagHandler(r *iz.Request) iz.Responder {
+	traceID := uuid.NewString()
+	ctx := context.WithValue(r.Context(), contextutil.TraceIDKey, traceID)
+
+	userId, ok := r.Context().Value(userIdKey).(string)
+	if !ok {
+		return iz.Respond().Status(500).JSON(appErrors.ErrorResponse{
+			Code:    appErrors.ErrAuth,
+			Message: "UserID not found",
+		})
+	}
+
+	var req TagRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		return iz.Respond().Status(400).JSON(appErrors.ErrorResponse{
+			Code:    appErrors.ErrInvalidInput,
+			Message: "Invalid request body",
+		})
+	}
+
+	tag, err := api.Service.SaveTag(ctx, userId, req.Name)
+	if err != nil {
+		logging.Logger.Errorf("[TraceID=%s] | failed to save tag | Error: %v", traceID, err)
+		return RespondError(err)
+	}
+
+	return iz.Respond().Status(201).JSON(TagToHttp(tag))
+}
+
+func (api *Api) GetTagsHandler(r *iz.Request) iz.Responder {
+	traceID := uuid.NewString()
+	ctx := context.WithValue(r.Context(), contextutil.TraceIDKey, traceID)
+
+	userId, ok := r.Context().Value(userIdKey).(string)
+	if !ok {
+		return iz.Respond().Status(500).JSON(appErrors.ErrorResponse{
+			Code:    appErrors.ErrAuth,
+			Message: "UserID not found",
+		})
+	}
+
+	tags, err := api.Service.GetTags(ctx, userId)
+	if err != nil {
+		logging.Logger.Errorf("[TraceID=%s] | failed to get tags | Error: %v", traceID, err)
+		return RespondError(err)
+	}
+
+	var list ListTagResponse
+	list.Tags = make([]TagItem, 0, len(tags))
+	for _, t := range tags {
+		list.Tags = append(list.Tags, TagToHttp(t))
+	}
+
+	return iz.Respond().Status(200).JSON(list)
+}
+
+func (api *Api) UpdateTagHandler(r *iz.Request) iz.Responder {
+	traceID := uuid.NewString()
+	ctx := context.WithValue(r.Context(), contextutil.TraceIDKey, traceID)
+
+	userId, ok := r.Context().Value(userIdKey).(string)
+	if !ok {
+		return iz.Respond().Status(500).JSON(appErrors.ErrorResponse{
+			Code:    appErrors.ErrAuth,
+			Message: "UserID not found",
+		})
+	}
+
+	var req TagRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		return iz.Respond().Status(400).JSON(appErrors.ErrorResponse{
+			Code:    appErrors.ErrInvalidInput,
+			Message: "Invalid request body",
+		})
+	}
+
+	tag, err := api.Service.UpdateTag(ctx, userId, r.PathValue("id"), req.Name)
+	if err != nil {
+		logging.Logger.Errorf("[TraceID=%s] | failed to update tag | Error: %v", traceID, err)
+		return RespondError(err)
+	}
+
+	return iz.Respond().Status(200).JSON(TagToHttp(tag))
+}
+
+func (api *Api) DeleteTagHandler(r *iz.Request) iz.Responder {
+	traceID := uuid.NewString()
+	ctx := context.WithValue(r.Context(), contextutil.TraceIDKey, traceID)
+
+	userId, ok := r.Context().Value(userIdKey).(string)
+	if !ok {
+		return iz.Respond().Status(500).JSON(appErrors.ErrorResponse{
+			Code:    appErrors.ErrAuth,
+			Message: "UserID not found",
+		})
+	}
+
+	if err := api.Service.DeleteTag(ctx, userId, r.PathValue("id")); err != nil {
+		logging.Logger.Errorf("[TraceID=%s] | failed to delete tag | Error: %v", traceID, err)
+		return RespondError(err)
+	}
+
+	return iz.Respond().Status(200).JSON(OperationResponse{
+		Code:    SUCCESS_CODE,
+		Message: "Tag deleted.",
+	})
+}
+
+// SetTransactionTagsHandler replaces the tags of a transaction, missing tags
+// are created.
+func (api *Api) SetTransactionTagsHandler(r *iz.Request) iz.Responder {
+	traceID := uuid.NewString()
+	ctx := context.WithValue(r.Context(), contextutil.TraceIDKey, traceID)
+
+	userId, ok := r.Context().Value(userIdKey).(string)
+	if !ok {
+		return iz.Respond().Status(500).JSON(appErrors.ErrorResponse{
+			Code:    appErrors.ErrAuth,
+			Message: "UserID not found",
+		})
+	}
+
+	var req TransactionTagsRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		return iz.Respond().Status(400).JSON(appErrors.ErrorResponse{
+			Code:    appErrors.ErrInvalidInput,
+			Message: "Invalid request body",
+		})
+	}
+
+	transaction, err := api.Service.SetTransactionTags(ctx, userId, r.PathValue("id"), req.Tags)
+	if err != nil {
+		logging.Logger.Errorf("[TraceID=%s] | failed to set transaction tags | Error: %v", traceID, err)
+		return RespondError(err)
+	}
+
+	return iz.Respond().Status(200).JSON(TransactionToHttp(transaction))
+}
+
+// MoveCategoryHandler moves a category with its subcategories under another
+// category of the same type.
+func (api *Api) MoveCategoryHandler(r *iz.Request) iz.Responder {
+	traceID := uuid.NewString()
+	ctx := context.WithValue(r.Context(), contextutil.TraceIDKey, traceID)
+
+	userId, ok := r.Context().Value(userIdKey).(string)
+	if !ok {
+		return iz.Respond().Status(500).JSON(appErrors.ErrorResponse{
+			Code:    appErrors.ErrAuth,
+			Message: "UserID not found",
+		})
+	}
+
+	categoryType, err := CategoryTypeFromPath(r.PathValue("type"))
+	if err != nil {
+		return RespondError(err)
+	}
+
+	var req MoveCategoryRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		return iz.Respond().Status(400).JSON(appErrors.ErrorResponse{
+			Code:    appErrors.ErrInvalidInput,
+			Message: "Invalid request body",
+		})
+	}
+
+	if err := api.Service.MoveCategory(ctx, userId, categoryType, r.PathValue("id"), req.ParentId); err != nil {
+		logging.Logger.Errorf("[TraceID=%s] | failed to move category | Error: %v", traceID, err)
+		return RespondError(err)
+	}
+
+	return iz.Respond().Status(200).JSON(OperationResponse{
+		Code:    SUCCESS_CODE,
+		Message: "Category moved.",
+	})
+}
+
+func (api *Api) ArchiveCategoryHandler(r *iz.Request) iz.Responder {
+	traceID := uuid.NewString()
+	ctx := context.WithValue(r.Context(), contextutil.TraceIDKey, traceID)
+
+	userId, ok := r.Context().Value(userIdKey).(string)
+	if !ok {
+		return iz.Respond().Status(500).JSON(appErrors.ErrorResponse{
+			Code:    appErrors.ErrAuth,
+			Message: "UserID not found",
+		})
+	}
+
+	categoryType, err := CategoryTypeFromPath(r.PathValue("type"))
+	if err != nil {
+		return RespondError(err)
+	}
+
+	var req ArchiveCategoryRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		return iz.Respond().Status(400).JSON(appErrors.ErrorResponse{
+			Code:    appErrors.ErrInvalidInput,
+			Message: "Invalid request body",
+		})
+	}
+
+	if err := api.Service.SetCategoryArchived(ctx, userId, categoryType, r.PathValue("id"), req.Archived); err != nil {
+		logging.Logger.Errorf("[TraceID=%s] | failed to archive category | Error: %v", traceID, err)
+		return RespondError(err)
+	}
+
+	message := "Category archived."
+	if !req.Archived {
+		message = "Category unarchived."
+	}
+	return iz.Respond().Status(200).JSON(OperationResponse{
+		Code:    SUCCESS_CODE,
+		Message: message,
+	})
+}
+
+func (api *Api) MergeCategoriesHandler(r *iz.Request) iz.Responder {
+	traceID := uuid.NewString()
+	ctx := context.WithValue(r.Context(), contextutil.TraceIDKey, traceID)
+
+	userId, ok := r.Context().Value(userIdKey).(string)
+	if !ok {
+		return iz.Respond().Status(500).JSON(appErrors.ErrorResponse{
+			Code:    appErrors.ErrAuth,
+			Message: "UserID not found",
+		})
+	}
+
+	categoryType, err := CategoryTypeFromPath(r.PathValue("type"))
+	if err != nil {
+		return RespondError(err)
+	}
+
+	var req CategoryMergeRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		return iz.Respond().Status(400).JSON(appErrors.ErrorResponse{
+			Code:    appErrors.ErrInvalidInput,
+			Message: "Invalid request body",
+		})
+	}
+
+	merge, err := api.Service.MergeCategories(ctx, userId, categoryType, budget.CategoryMergeRequest{
+		TargetId:      req.TargetId,
+		SourceIds:     req.SourceIds,
+		LimitStrategy: req.LimitStrategy,
+		NoteStrategy:  req.NoteStrategy,
+	})
+	if err != nil {
+		logging.Logger.Errorf("[TraceID=%s] | failed to merge categories | Error: %v", traceID, err)
+		return RespondError(err)
+	}
+
+	return iz.Respond().Status(200).JSON(CategoryMergeToHttp(merge))
+}
+
+func (api *Api) GetCategoryMergesHandler(r *iz.Request) iz.Responder {
+	traceID := uuid.NewString()
+	ctx := context.WithValue(r.Context(), contextutil.TraceIDKey, traceID)
+
+	userId, ok := r.Context().Value(userIdKey).(string)
+	if !ok {
+		return iz.Respond().Status(500).JSON(appErrors.ErrorResponse{
+			Code:    appErrors.ErrAuth,
+			Message: "UserID not found",
+		})
+	}
+
+	categoryType, err := CategoryTypeFromPath(r.PathValue("type"))
+	if err != nil {
+		return RespondError(err)
+	}
+
+	merges, err := api.Service.GetCategoryMerges(ctx, userId, categoryType)
+	if err != nil {
+		logging.Logger.Errorf("[TraceID=%s] | failed to get category merges | Error: %v", traceID, err)
+		return RespondError(err)
+	}
+
+	response := ListCategoryMergeResponse{Merges: make([]CategoryMergeItem, 0, len(merges))}
+	for _, m := range merges {
+		response.Merges = append(response.Merges, CategoryMergeToHttp(m))
+	}
+	return iz.Respond().Status(200).JSON(response)
+}
+
+func (api *Api) SaveSavingsGoalHandler(r *iz.Request) iz.Responder {
+	traceID := uuid.NewString()
+	ctx := context.WithValue(r.Context(), contextutil.TraceIDKey, traceID)
+
+	userId, ok := r.Context().Value(userIdKey).(string)
+	if !ok {
+		return iz.Respond().Status(500).JSON(appErrors.ErrorResponse{
+			Code:    appErrors.ErrAuth,
+			Message: "UserID not found",
+		})
+	}
+
+	var req SavingsGoalRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		return iz.Respond().Status(400).JSON(appErrors.ErrorResponse{
+			Code:    appErrors.ErrInvalidInput,
+			Message: "Invalid request body",
+		})
+	}
+	goalReq, err := req.ToBudget()
+	if err != nil {
+		return RespondError(err)
+	}
+
+	goal, err := api.Service.SaveSavingsGoal(ctx, userId, goalReq)
+	if err != nil {
+		logging.Logger.Errorf("[TraceID=%s] | failed to save goal | Error: %v", traceID, err)
+		return RespondError(err)
+	}
+
+	return iz.Respond().Status(201).JSON(SavingsGoalToHttp(goal))
+}
+
+func (api *Api) GetSavingsGoalsHandler(r *iz.Request) iz.Responder {
+	traceID := uuid.NewString()
+	ctx := context.WithValue(r.Context(), contextutil.TraceIDKey, traceID)
+
+	userId, ok := r.Context().Value(userIdKey).(string)
+	if !ok {
+		return iz.Respond().Status(500).JSON(appErrors.ErrorResponse{
+			Code:    appErrors.ErrAuth,
+			Message: "UserID not found",
+		})
+	}
+
+	goals, err := api.Service.GetSavingsGoals(ctx, userId)
+	if err != nil {
+		logging.Logger.Errorf("[TraceID=%s] | failed to get goals | Error: %v", traceID, err)
+		return RespondError(err)
+	}
+
+	response := ListSavingsGoalResponse{Goals: make([]SavingsGoalItem, 0, len(goals))}
+	for _, g := range goals {
+		response.Goals = append(response.Goals, SavingsGoalToHttp(g))
+	}
+	return iz.Respond().Status(200).JSON(response)
+}
+
+func (api *Api) GetSavingsGoalHandler(r *iz.Request) iz.Responder {
+	traceID := uuid.NewString()
+	ctx := context.WithValue(r.Context(), contextutil.TraceIDKey, traceID)
+
+	userId, ok := r.Context().Value(userIdKey).(string)
+	if !ok {
+		return iz.Respond().Status(500).JSON(appErrors.ErrorResponse{
+			Code:    appErrors.ErrAuth,
+			Message: "UserID not found",
+		})
+	}
+
+	goal, err := api.Service.GetSavingsGoal(ctx, userId, r.PathValue("id"))
+	if err != nil {
+		logging.Logger.Errorf("[TraceID=%s] | failed to get goal | Error: %v", traceID, err)
+		return RespondError(err)
+	}
+
+	return iz.Respond().Status(200).JSON(SavingsGoalToHttp(goal))
+}
+
+func (api *Api) UpdateSavingsGoalHandler(r *iz.Request) iz.Responder {
+	traceID := uuid.NewString()
+	ctx := context.WithValue(r.Context(), contextutil.TraceIDKey, traceID)
+
+	userId, ok := r.Context().Value(userIdKey).(string)
+	if !ok {
+		return iz.Respond().Status(500).JSON(appErrors.ErrorResponse{
+			Code:    appErrors.ErrAuth,
+			Message: "UserID not found",
+		})
+	}
+
+	var req SavingsGoalRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		return iz.Respond().Status(400).JSON(appErrors.ErrorResponse{
+			Code:    appErrors.ErrInvalidInput,
+			Message: "Invalid request body",
+		})
+	}
+	goalReq, err := req.ToBudget()
+	if err != nil {
+		return RespondError(err)
+	}
+
+	goal, err := api.Service.UpdateSavingsGoal(ctx, userId, r.PathValue("id"), goalReq)
+	if err != nil {
+		logging.Logger.Errorf("[TraceID=%s] | failed to update goal | Error: %v", traceID, err)
+		return RespondError(err)
+	}
+
+	return iz.Respond().Status(200).JSON(SavingsGoalToHttp(goal))
+}
+
+func (api *Api) DeleteSavingsGoalHandler(r *iz.Request) iz.Responder {
+	traceID := uuid.NewString()
+	ctx := context.WithValue(r.Context(), contextutil.TraceIDKey, traceID)
+
+	userId, ok := r.Context().Value(userIdKey).(string)
+	if !ok {
+		return iz.Respond().Status(500).JSON(appErrors.ErrorResponse{
+			Code:    appErrors.ErrAuth,
+			Message: "UserID not found",
+		})
+	}
+
+	if err := api.Service.DeleteSavingsGoal(ctx, userId, r.PathValue("id")); err != nil {
+		logging.Logger.Errorf("[TraceID=%s] | failed to delete goal | Error: %v", traceID, err)
+		return RespondError(err)
+	}
+
+	return iz.Respond().Status(200).JSON(OperationResponse{
+		Code:    SUCCESS_CODE,
+		Message: "Goal deleted successfully.",
+	})
+}
+
+func (api *Api) AddSavingsContributionHandler(r *iz.Request) iz.Responder {
+	traceID := uuid.NewString()
+	ctx := context.WithValue(r.Context(), contextutil.TraceIDKey, traceID)
+
+	userId, ok := r.Context().Value(userIdKey).(string)
+	if !ok {
+		return iz.Respond().Status(500).JSON(appErrors.ErrorResponse{
+			Code:    appErrors.ErrAuth,
+			Message: "UserID not found",
+		})
+	}
+
+	var req SavingsContributionRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		return iz.Respond().Status(400).JSON(appErrors.ErrorResponse{
+			Code:    appErrors.ErrInvalidInput,
+			Message: "Invalid request body",
+		})
+	}
+	contributionReq, err := req.ToBudget()
+	if err != nil {
+		return RespondError(err)
+	}
+
+	goal, err := api.Service.AddSavingsContribution(ctx, userId, r.PathValue("id"), contributionReq)
+	if err != nil {
+		logging.Logger.Errorf("[TraceID=%s] | failed to add contribution | Error: %v", traceID, err)
+		return RespondError(err)
+	}
+
+	return iz.Respond().Status(201).JSON(SavingsGoalToHttp(goal))
+}
+
+func (api *Api) GetSavingsGoalHistoryHandler(r *iz.Request) iz.Responder {
+	traceID := uuid.NewString()
+	ctx := context.WithValue(r.Context(), contextutil.TraceIDKey, traceID)
+
+	userId, ok := r.Context().Value(userIdKey).(string)
+	if !ok {
+		return iz.Respond().Status(500).JSON(appErrors.ErrorResponse{
+			Code:    appErrors.ErrAuth,
+			Message: "UserID not found",
+		})
+	}
+
+	goal, entries, err := api.Service.GetSavingsGoalHistory(ctx, userId, r.PathValue("id"))
+	if err != nil {
+		logging.Logger.Errorf("[TraceID=%s] | failed to get goal history | Error: %v", traceID, err)
+		return RespondError(err)
+	}
+
+	response := SavingsGoalHistoryResponse{
+		Goal:    SavingsGoalToHttp(goal),
+		Entries: make([]SavingsContributionItem, 0, len(entries)),
+	}
+	for _, e := range entries {
+		response.Entries = append(response.Entries, SavingsContributionItem{
+			ID:         e.Contribution.ID,
+			Amount:     e.Contribution.Amount,
+			OccurredAt: e.Contribution.OccurredAt.Format(time.RFC3339),
+			Note:       e.Contribution.Note,
+			Saved:      e.Saved,
+			CreatedAt:  e.Contribution.CreatedAt.Format(time.RFC3339),
+		})
+	}
+	return iz.Respond().Status(200).JSON(response)
+}
+
+func (api *Api) SaveDebtHandler(r *iz.Request) iz.Responder {
+	traceID := uuid.NewString()
+	ctx := context.WithValue(r.Context(), contextutil.TraceIDKey, traceID)
+
+	userId, ok := r.Context().Value(userIdKey).(string)
+	if !ok {
+		return iz.Respond().Status(500).JSON(appErrors.ErrorResponse{
+			Code:    appErrors.ErrAuth,
+			Message: "UserID not found",
+		})
+	}
+
+	var req DebtRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		return iz.Respond().Status(400).JSON(appErrors.ErrorResponse{
+			Code:    appErrors.ErrInvalidInput,
+			Message: "Invalid request body",
+		})
+	}
+	debtReq, err := req.ToBudget()
+	if err != nil {
+		return RespondError(err)
+	}
+
+	debt, err := api.Service.SaveDebt(ctx, userId, debtReq)
+	if err != nil {
+		logging.Logger.Errorf("[TraceID=%s] | failed to save debt | Error: %v", traceID, err)
+		return RespondError(err)
+	}
+
+	return iz.Respond().Status(201).JSON(DebtToHttp(debt))
+}
+
+func (api *Api) GetDebtsHandler(r *iz.Request) iz.Responder {
+	traceID := uuid.NewString()
+	ctx := context.WithValue(r.Context(), contextutil.TraceIDKey, traceID)
+
+	userId, ok := r.Context().Value(userIdKey).(string)
+	if !ok {
+		return iz.Respond().Status(500).JSON(appErrors.ErrorResponse{
+			Code:    appErrors.ErrAuth,
+			Message: "UserID not found",
+		})
+	}
+
+	debts, err := api.Service.GetDebts(ctx, userId)
+	if err != nil {
+		logging.Logger.Errorf("[TraceID=%s] | failed to get debts | Error: %v", traceID, err)
+		return RespondError(err)
+	}
+
+	response := ListDebtResponse{Debts: make([]DebtItem, 0, len(debts))}
+	for _, d := range debts {
+		response.Debts = append(response.Debts, DebtToHttp(d))
+	}
+	return iz.Respond().Status(200).JSON(response)
+}
+
+func (api *Api) GetDebtHandler(r *iz.Request) iz.Responder {
+	traceID := uuid.NewString()
+	ctx := context.WithValue(r.Context(), contextutil.TraceIDKey, traceID)
+
+	userId, ok := r.Context().Value(userIdKey).(string)
+	if !ok {
+		return iz.Respond().Status(500).JSON(appErrors.ErrorResponse{
+			Code:    appErrors.ErrAuth,
+			Message: "UserID not found",
+		})
+	}
+
+	debt, err := api.Service.GetDebt(ctx, userId, r.PathValue("id"))
+	if err != nil {
+		logging.Logger.Errorf("[TraceID=%s] | failed to get debt | Error: %v", traceID, err)
+		return RespondError(err)
+	}
+
+	return iz.Respond().Status(200).JSON(DebtToHttp(debt))
+}
+
+func (api *Api) UpdateDebtHandler(r *iz.Request) iz.Responder {
+	traceID := uuid.NewString()
+	ctx := context.WithValue(r.Context(), contextutil.TraceIDKey, traceID)
+
+	userId, ok := r.Context().Value(userIdKey).(string)
+	if !ok {
+		return iz.Respond().Status(500).JSON(appErrors.ErrorResponse{
+			Code:    appErrors.ErrAuth,
+			Message: "UserID not found",
+		})
+	}
+
+	var req DebtRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		return iz.Respond().Status(400).JSON(appErrors.ErrorResponse{
+			Code:    appErrors.ErrInvalidInput,
+			Message: "Invalid request body",
+		})
+	}
+	debtReq, err := req.ToBudget()
+	if err != nil {
+		return RespondError(err)
+	}
+
+	debt, err := api.Service.UpdateDebt(ctx, userId, r.PathValue("id"), debtReq)
+	if err != nil {
+		logging.Logger.Errorf("[TraceID=%s] | failed to update debt | Error: %v", traceID, err)
+		return RespondError(err)
+	}
+
+	return iz.Respond().Status(200).JSON(DebtToHttp(debt))
+}
+
+func (api *Api) DeleteDebtHandler(r *iz.Request) iz.Responder {
+	traceID := uuid.NewString()
+	ctx := context.WithValue(r.Context(), contextutil.TraceIDKey, traceID)
+
+	userId, ok := r.Context().Value(userIdKey).(string)
+	if !ok {
+		return iz.Respond().Status(500).JSON(appErrors.ErrorResponse{
+			Code:    appErrors.ErrAuth,
+			Message: "UserID not found",
+		})
+	}
+
+	if err := api.Service.DeleteDebt(ctx, userId, r.PathValue("id")); err != nil {
+		logging.Logger.Errorf("[TraceID=%s] | failed to delete debt | Error: %v", traceID, err)
+		return RespondError(err)
+	}
+
+	return iz.Respond().Status(200).JSON(OperationResponse{
+		Code:    SUCCESS_CODE,
+		Message: "Debt deleted successfully.",
+	})
+}
+
+func (api *Api) AddDebtPaymentHandler(r *iz.Request) iz.Responder {
+	traceID := uuid.NewString()
+	ctx := context.WithValue(r.Context(), contextutil.TraceIDKey, traceID)
+
+	userId, ok := r.Context().Value(userIdKey).(string)
+	if !ok {
+		return iz.Respond().Status(500).JSON(appErrors.ErrorResponse{
+			Code:    appErrors.ErrAuth,
+			Message: "UserID not found",
+		})
+	}
+
+	var req DebtPaymentRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		return iz.Respond().Status(400).JSON(appErrors.ErrorResponse{
+			Code:    appErrors.ErrInvalidInput,
+			Message: "Invalid request body",
+		})
+	}
+	paymentReq, err := req.ToBudget()
+	if err != nil {
+		return RespondError(err)
+	}
+
+	payment, debt, err := api.Service.AddDebtPayment(ctx, userId, r.PathValue("id"), paymentReq)
+	if err != nil {
+		logging.Logger.Errorf("[TraceID=%s] | failed to add debt payment | Error: %v", traceID, err)
+		return RespondError(err)
+	}
+
+	return iz.Respond().Status(201).JSON(DebtPaymentResponse{
+		Payment: DebtPaymentToHttp(payment),
+		Debt:    DebtToHttp(debt),
+	})
+}
+
+func (api *Api) GetDebtPaymentsHandler(r *iz.Request) iz.Responder {
+	traceID := uuid.NewString()
+	ctx := context.WithValue(r.Context(), contextutil.TraceIDKey, traceID)
+
+	userId, ok := r.Context().Value(userIdKey).(string)
+	if !ok {
+		return iz.Respond().Status(500).JSON(appErrors.ErrorResponse{
+			Code:    appErrors.ErrAuth,
+			Message: "UserID not found",
+		})
+	}
+
+	payments, err := api.Service.GetDebtPayments(ctx, userId, r.PathValue("id"))
+	if err != nil {
+		logging.Logger.Errorf("[TraceID=%s] | failed to get debt payments | Error: %v", traceID, err)
+		return RespondError(err)
+	}
+
+	response := ListDebtPaymentResponse{Payments: make([]DebtPaymentItem, 0, len(payments))}
+	for _, p := range payments {
+		response.Payments = append(response.Payments, DebtPaymentToHttp(p))
+	}
+	return iz.Respond().Status(200).JSON(response)
+}
+
+func (api *Api) GetDebtScheduleHandler(r *iz.Request) iz.Responder {
+	traceID := uuid.NewString()
+	ctx := context.WithValue(r.Context(), contextutil.TraceIDKey, traceID)
+
+	userId, ok := r.Context().Value(userIdKey).(string)
+	if !ok {
+		return iz.Respond().Status(500).JSON(appErrors.ErrorResponse{
+			Code:    appErrors.ErrAuth,
+			Message: "UserID not found",
+		})
+	}
+
+	extra := 0.0
+	if e := r.URL.Query().Get("extra_monthly"); e != "" {
+		n, err := strconv.ParseFloat(e, 64)
+		if err != nil {
+			return iz.Respond().Status(400).JSON(appErrors.ErrorResponse{
+				Code:    appErrors.ErrInvalidInput,
+				Message: "Extra monthly payment must be a number",
+			})
+		}
+		extra = n
+	}
+
+	schedule, err := api.Service.GetDebtSchedule(ctx, userId, r.PathValue("id"), extra)
+	if err != nil {
+		logging.Logger.Errorf("[TraceID=%s] | failed to get debt schedule | Error: %v", traceID, err)
+		return RespondError(err)
+	}
+
+	response := DebtScheduleResponse{
+		Debt:          DebtToHttp(schedule.Status),
+		ExtraMonthly:  schedule.ExtraMonthly,
+		PayoffDate:    formatOptionalTime(schedule.PayoffDate),
+		TotalInterest: schedule.TotalInterest,
+		InterestSaved: schedule.InterestSaved,
+		PaymentsSaved: schedule.PaymentsSaved,
+		Rows:          make([]AmortizationRowItem, 0, len(schedule.Rows)),
+	}
+	for _, row := range schedule.Rows {
+		response.Rows = append(response.Rows, AmortizationRowItem{
+			Number:    row.Number,
+			DueAt:     row.DueAt.Format(time.RFC3339),
+			Payment:   row.Payment,
+			Principal: row.Principal,
+			Interest:  row.Interest,
+			Balance:   row.Balance,
+		})
+	}
+	return iz.Respond().Status(200).JSON(response)
+}
+
+func (api *Api) PlanDebtPayoffHandler(r *iz.Request) iz.Responder {
+	traceID := uuid.NewString()
+	ctx := context.WithValue(r.Context(), contextutil.TraceIDKey, traceID)
+
+	userId, ok := r.Context().Value(userIdKey).(string)
+	if !ok {
+		return iz.Respond().Status(500).JSON(appErrors.ErrorResponse{
+			Code:    appErrors.ErrAuth,
+			Message: "UserID not found",
+		})
+	}
+
+	var req DebtPlanRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		return iz.Respond().Status(400).JSON(appErrors.ErrorResponse{
+			Code:    appErrors.ErrInvalidInput,
+			Message: "Invalid request body",
+		})
+	}
+
+	plan, err := api.Service.PlanDebtPayoff(ctx, userId, budget.DebtPlanRequest{
+		Strategy:     req.Strategy,
+		ExtraMonthly: req.ExtraMonthly,
+		DebtIds:      req.DebtIds,
+	})
+	if err != nil {
+		logging.Logger.Errorf("[TraceID=%s] | failed to plan debt payoff | Error: %v", traceID, err)
+		return RespondError(err)
+	}
+
+	response := DebtPlanResponse{
+		Strategy:      plan.Strategy,
+		Currency:      plan.Currency,
+		ExtraMonthly:  plan.ExtraMonthly,
+		Months:        plan.Months,
+		PayoffDate:    plan.PayoffDate.Format(time.RFC3339),
+		TotalInterest: plan.TotalInterest,
+		InterestSaved: plan.InterestSaved,
+		MonthsSaved:   plan.MonthsSaved,
+		Debts:         make([]DebtPlanItem, 0, len(plan.Debts)),
+	}
+	for _, d := range plan.Debts {
+		response.Debts = append(response.Debts, DebtPlanItem{
+			ID:            d.Debt.ID,
+			Name:          d.Debt.Name,
+			InterestRate:  d.Debt.InterestRate,
+			Balance:       d.Balance,
+			Months:        d.Months,
+			PayoffDate:    d.PayoffDate.Format(time.RFC3339),
+			TotalInterest: d.TotalInterest,
+		})
+	}
+	return iz.Respond().Status(200).JSON(response)
+}
+
+func (api *Api) DetectSubscriptionsHandler(r *iz.Request) iz.Responder {
+	traceID := uuid.NewString()
+	ctx := context.WithValue(r.Context(), contextutil.TraceIDKey, traceID)
+
+	userId, ok := r.Context().Value(userIdKey).(string)
+	if !ok {
+		return iz.Respond().Status(500).JSON(appErrors.ErrorResponse{
+			Code:    appErrors.ErrAuth,
+			Message: "UserID not found",
+		})
+	}
+
+	months := 0
+	if m := r.URL.Query().Get("months"); m != "" {
+		n, err := strconv.Atoi(m)
+		if err != nil {
+			return iz.Respond().Status(400).JSON(appErrors.ErrorResponse{
+				Code:    appErrors.ErrInvalidInput,
+				Message: "Months must be a number",
+			})
+		}
+		months = n
+	}
+
+	detected, err := api.Service.DetectSubscriptions(ctx, userId, months)
+	if err != nil {
+		logging.Logger.Errorf("[TraceID=%s] | failed to detect subscriptions | Error: %v", traceID, err)
+		return RespondError(err)
+	}
+
+	response := ListDetectedSubscriptionResponse{
+		Subscriptions: make([]DetectedSubscriptionItem, 0, len(detected)),
+		MonthlyTotal:  map[string]float64{},
+	}
+	for _, s := range detected {
+		response.Subscriptions = append(response.Subscriptions, DetectedSubscriptionToHttp(s))
+		if s.Active {
+			response.MonthlyTotal[s.Currency] = math.Round((response.MonthlyTotal[s.Currency]+s.MonthlyCost)*100) / 100
+		}
+	}
+	return iz.Respond().Status(200).JSON(response)
+}
+
+func (api *Api) ConvertSubscriptionHandler(r *iz.Request) iz.Responder {
+	traceID := uuid.NewString()
+	ctx := context.WithValue(r.Context(), contextutil.TraceIDKey, traceID)
+
+	userId, ok := r.Context().Value(userIdKey).(string)
+	if !ok {
+		return iz.Respond().Status(500).JSON(appErrors.ErrorResponse{
+			Code:    appErrors.ErrAuth,
+			Message: "UserID not found",
+		})
+	}
+
+	months := 0
+	if m := r.URL.Query().Get("months"); m != "" {
+		n, err := strconv.Atoi(m)
+		if err != nil {
+			return iz.Respond().Status(400).JSON(appErrors.ErrorResponse{
+				Code:    appErrors.ErrInvalidInput,
+				Message: "Months must be a number",
+			})
+		}
+		months = n
+	}
+
+	recurring, err := api.Service.ConvertSubscription(ctx, userId, r.PathValue("id"), months)
+	if err != nil {
+		logging.Logger.Errorf("[TraceID=%s] | failed to convert subscription | Error: %v", traceID, err)
+		return RespondError(err)
+	}
+
+	return iz.Respond().Status(201).JSON(RecurringTransactionToHttp(recurring))
+}
+
+func (api *Api) SavePayeeHandler(r *iz.Request) iz.Responder {
+	traceID := uuid.NewString()
+	ctx := context.WithValue(r.Context(), contextutil.TraceIDKey, traceID)
+
+	userId, ok := r.Context().Value(userIdKey).(string)
+	if !ok {
+		return iz.Respond().Status(500).JSON(appErrors.ErrorResponse{
+			Code:    appErrors.ErrAuth,
+			Message: "UserID not found",
+		})
+	}
+
+	var req PayeeRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		return iz.Respond().Status(400).JSON(appErrors.ErrorResponse{
+			Code:    appErrors.ErrInvalidInput,
+			Message: "Invalid request body",
+		})
+	}
+	payeeReq, err := req.ToBudget()
+	if err != nil {
+		return RespondError(err)
+	}
+
+	payee, err := api.Service.SavePayee(ctx, userId, payeeReq)
+	if err != nil {
+		logging.Logger.Errorf("[TraceID=%s] | failed to save payee | Error: %v", traceID, err)
+		return RespondError(err)
+	}
+
+	return iz.Respond().Status(201).JSON(PayeeToHttp(payee))
+}
+
+func (api *Api) GetPayeesHandler(r *iz.Request) iz.Responder {
+	traceID := uuid.NewString()
+	ctx := context.WithValue(r.Context(), contextutil.TraceIDKey, traceID)
+
+	userId, ok := r.Context().Value(userIdKey).(string)
+	if !ok {
+		return iz.Respond().Status(500).JSON(appErrors.ErrorResponse{
+			Code:    appErrors.ErrAuth,
+			Message: "UserID not found",
+		})
+	}
+
+	payees, err := api.Service.GetPayees(ctx, userId)
+	if err != nil {
+		logging.Logger.Errorf("[TraceID=%s] | failed to get payees | Error: %v", traceID, err)
+		return RespondError(err)
+	}
+
+	var list ListPayeeResponse
+	list.Payees = make([]PayeeItem, 0, len(payees))
+	for _, p := range payees {
+		list.Payees = append(list.Payees, PayeeToHttp(p))
+	}
+
+	return iz.Respond().Status(200).JSON(list)
+}
+
+func (api *Api) GetPayeeHandler(r *iz.Request) iz.Responder {
+	traceID := uuid.NewString()
+	ctx := context.WithValue(r.Context(), contextutil.TraceIDKey, traceID)
+
+	userId, ok := r.Context().Value(userIdKey).(string)
+	if !ok {
+		return iz.Respond().Status(500).JSON(appErrors.ErrorResponse{
+			Code:    appErrors.ErrAuth,
+			Message: "UserID not found",
+		})
+	}
+
+	payee, err := api.Service.GetPayee(ctx, userId, r.PathValue("id"))
+	if err != nil {
+		logging.Logger.Errorf("[TraceID=%s] | failed to get payee | Error: %v", traceID, err)
+		return RespondError(err)
+	}
+
+	return iz.Respond().Status(200).JSON(PayeeToHttp(payee))
+}
+
+func (api *Api) UpdatePayeeHandler(r *iz.Request) iz.Responder {
+	traceID := uuid.NewString()
+	ctx := context.WithValue(r.Context(), contextutil.TraceIDKey, traceID)
+
+	userId, ok := r.Context().Value(userIdKey).(string)
+	if !ok {
+		return iz.Respond().Status(500).JSON(appErrors.ErrorResponse{
+			Code:    appErrors.ErrAuth,
+			Message: "UserID not found",
+		})
+	}
+
+	var req PayeeRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		return iz.Respond().Status(400).JSON(appErrors.ErrorResponse{
+			Code:    appErrors.ErrInvalidInput,
+			Message: "Invalid request body",
+		})
+	}
+	payeeReq, err := req.ToBudget()
+	if err != nil {
+		return RespondError(err)
+	}
+
+	payee, err := api.Service.UpdatePayee(ctx, userId, r.PathValue("id"), payeeReq)
+	if err != nil {
+		logging.Logger.Errorf("[TraceID=%s] | failed to update payee | Error: %v", traceID, err)
+		return RespondError(err)
+	}
+
+	return iz.Respond().Status(200).JSON(PayeeToHttp(payee))
+}
+
+func (api *Api) DeletePayeeHandler(r *iz.Request) iz.Responder {
+	traceID := uuid.NewString()
+	ctx := context.WithValue(r.Context(), contextutil.TraceIDKey, traceID)
+
+	userId, ok := r.Context().Value(userIdKey).(string)
+	if !ok {
+		return iz.Respond().Status(500).JSON(appErrors.ErrorResponse{
+			Code:    appErrors.ErrAuth,
+			Message: "UserID not found",
+		})
+	}
+
+	if err := api.Service.DeletePayee(ctx, userId, r.PathValue("id")); err != nil {
+		logging.Logger.Errorf("[TraceID=%s] | failed to delete payee | Error: %v", traceID, err)
+		return RespondError(err)
+	}
+
+	return iz.Respond().Status(200).JSON(OperationResponse{
+		Code:    SUCCESS_CODE,
+		Message: "Payee deleted.",
+	})
+}
+
+// LinkPayeeTransactionsHandler links the transactions without a payee whose
+// notes match the aliases of the payee.
+func (api *Api) LinkPayeeTransactionsHandler(r *iz.Request) iz.Responder {
+	traceID := uuid.NewString()
+	ctx := context.WithValue(r.Context(), contextutil.TraceIDKey, traceID)
+
+	userId, ok := r.Context().Value(userIdKey).(string)
+	if !ok {
+		return iz.Respond().Status(500).JSON(appErrors.ErrorResponse{
+			Code:    appErrors.ErrAuth,
+			Message: "UserID not found",
+		})
+	}
+
+	linked, err := api.Service.LinkPayeeTransactions(ctx, userId, r.PathValue("id"))
+	if err != nil {
+		logging.Logger.Errorf("[TraceID=%s] | failed to link payee transactions | Error: %v", traceID, err)
+		return RespondError(err)
+	}
+
+	return iz.Respond().Status(200).JSON(LinkPayeeResponse{Linked: linked})
+}
+
+func (api *Api) GetPayeeStatsHandler(r *iz.Request) iz.Responder {
+	traceID := uuid.NewString()
+	ctx := context.WithValue(r.Context(), contextutil.TraceIDKey, traceID)
+
+	userId, ok := r.Context().Value(userIdKey).(string)
+	if !ok {
+		return iz.Respond().Status(500).JSON(appErrors.ErrorResponse{
+			Code:    appErrors.ErrAuth,
+			Message: "UserID not found",
+		})
+	}
+
+	months := budget.DEFAULT_PAYEE_STATS_MONTHS
+	if m := r.URL.Query().Get("months"); m != "" {
+		n, err := strconv.Atoi(m)
+		if err != nil {
+			return iz.Respond().Status(400).JSON(appErrors.ErrorResponse{
+				Code:    appErrors.ErrInvalidInput,
+				Message: "Months must be a number",
+			})
+		}
+		months = n
+	}
+
+	stats, err := api.Service.GetPayeeStats(ctx, userId, r.URL.Query().Get("payee_id"), months)
+	if err != nil {
+		logging.Logger.Errorf("[TraceID=%s] | failed to get payee stats | Error: %v", traceID, err)
+		return RespondError(err)
+	}
+
+	response := PayeeStatsResponse{Months: months, Payees: make([]PayeeStatsItem, 0, len(stats))}
+	for _, s := range stats {
+		response.Payees = append(response.Payees, PayeeStatsToHttp(s))
+	}
+	return iz.Respond().Status(200).JSON(response)
+}
+
+// SetTransactionPayeeHandler links a transaction to a payee, or unlinks it.
+func (api *Api) SetTransactionPayeeHandler(r *iz.Request) iz.Responder {
+	traceID := uuid.NewString()
+	ctx := context.WithValue(r.Context(), contextutil.TraceIDKey, traceID)
+
+	userId, ok := r.Context().Value(userIdKey).(string)
+	if !ok {
+		return iz.Respond().Status(500).JSON(appErrors.ErrorResponse{
+			Code:    appErrors.ErrAuth,
+			Message: "UserID not found",
+		})
+	}
+
+	var req TransactionPayeeRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		return iz.Respond().Status(400).JSON(appErrors.ErrorResponse{
+			Code:    appErrors.ErrInvalidInput,
+			Message: "Invalid request body",
+		})
+	}
+
+	transaction, err := api.Service.SetTransactionPayee(ctx, userId, r.PathValue("id"), req.PayeeId)
+	if err != nil {
+		logging.Logger.Errorf("[TraceID=%s] | failed to set transaction payee | Error: %v", traceID, err)
+		return RespondError(err)
+	}
+
+	return iz.Respond().Status(200).JSON(TransactionToHttp(transaction))
+}
+
+func (api *Api) SaveRuleHandler(r *iz.Request) iz.Responder {
+	traceID := uuid.NewString()
+	ctx := context.WithValue(r.Context(), contextutil.TraceIDKey, traceID)
+
+	userId, ok := r.Context().Value(userIdKey).(string)
+	if !ok {
+		return iz.Respond().Status(500).JSON(appErrors.ErrorResponse{
+			Code:    appErrors.ErrAuth,
+			Message: "UserID not found",
+		})
+	}
+
+	var req RuleRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		return iz.Respond().Status(400).JSON(appErrors.ErrorResponse{
+			Code:    appErrors.ErrInvalidInput,
+			Message: "Invalid request body",
+		})
+	}
+	ruleReq, err := req.ToBudget()
+	if err != nil {
+		return RespondError(err)
+	}
+
+	rule, err := api.Service.SaveRule(ctx, userId, ruleReq)
+	if err != nil {
+		logging.Logger.Errorf("[TraceID=%s] | failed to save rule | Error: %v", traceID, err)
+		return RespondError(err)
+	}
+
+	return iz.Respond().Status(201).JSON(RuleToHttp(rule))
+}
+
+func (api *Api) GetRulesHandler(r *iz.Request) iz.Responder {
+	traceID := uuid.NewString()
+	ctx := context.WithValue(r.Context(), contextutil.TraceIDKey, traceID)
+
+	userId, ok := r.Context().Value(userIdKey).(string)
+	if !ok {
+		return iz.Respond().Status(500).JSON(appErrors.ErrorResponse{
+			Code:    appErrors.ErrAuth,
+			Message: "UserID not found",
+		})
+	}
+
+	rules, err := api.Service.GetRules(ctx, userId)
+	if err != nil {
+		logging.Logger.Errorf("[TraceID=%s] | failed to get rules | Error: %v", traceID, err)
+		return RespondError(err)
+	}
+
+	var list ListRuleResponse
+	list.Rules = make([]RuleItem, 0, len(rules))
+	for _, rule := range rules {
+		list.Rules = append(list.Rules, RuleToHttp(rule))
+	}
+
+	return iz.Respond().Status(200).JSON(list)
+}
+
+func (api *Api) GetRuleHandler(r *iz.Request) iz.Responder {
+	traceID := uuid.NewString()
+	ctx := context.WithValue(r.Context(), contextutil.TraceIDKey, traceID)
+
+	userId, ok := r.Context().Value(userIdKey).(string)
+	if !ok {
+		return iz.Respond().Status(500).JSON(appErrors.ErrorResponse{
+			Code:    appErrors.ErrAuth,
+			Message: "UserID not found",
+		})
+	}
+
+	rule, err := api.Service.GetRule(ctx, userId, r.PathValue("id"))
+	if err != nil {
+		logging.Logger.Errorf("[TraceID=%s] | failed to get rule | Error: %v", traceID, err)
+		return RespondError(err)
+	}
+
+	return iz.Respond().Status(200).JSON(RuleToHttp(rule))
+}
+
+func (api *Api) UpdateRuleHandler(r *iz.Request) iz.Responder {
+	traceID := uuid.NewString()
+	ctx := context.WithValue(r.Context(), contextutil.TraceIDKey, traceID)
+
+	userId, ok := r.Context().Value(userIdKey).(string)
+	if !ok {
+		return iz.Respond().Status(500).JSON(appErrors.ErrorResponse{
+			Code:    appErrors.ErrAuth,
+			Message: "UserID not found",
+		})
+	}
+
+	var req RuleRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		return iz.Respond().Status(400).JSON(appErrors.ErrorResponse{
+			Code:    appErrors.ErrInvalidInput,
+			Message: "Invalid request body",
+		})
+	}
+	ruleReq, err := req.ToBudget()
+	if err != nil {
+		return RespondError(err)
+	}
+
+	rule, err := api.Service.UpdateRule(ctx, userId, r.PathValue("id"), ruleReq)
+	if err != nil {
+		logging.Logger.Errorf("[TraceID=%s] | failed to update rule | Error: %v", traceID, err)
+		return RespondError(err)
+	}
+
+	return iz.Respond().Status(200).JSON(RuleToHttp(rule))
+}
+
+func (api *Api) DeleteRuleHandler(r *iz.Request) iz.Responder {
+	traceID := uuid.NewString()
+	ctx := context.WithValue(r.Context(), contextutil.TraceIDKey, traceID)
+
+	userId, ok := r.Context().Value(userIdKey).(string)
+	if !ok {
+		return iz.Respond().Status(500).JSON(appErrors.ErrorResponse{
+			Code:    appErrors.ErrAuth,
+			Message: "UserID not found",
+		})
+	}
+
+	if err := api.Service.DeleteRule(ctx, userId, r.PathValue("id")); err != nil {
+		logging.Logger.Errorf("[TraceID=%s] | failed to delete rule | Error: %v", traceID, err)
+		return RespondError(err)
+	}
+
+	return iz.Respond().Status(200).JSON(OperationResponse{
+		Code:    SUCCESS_CODE,
+		Message: "Rule deleted.",
+	})
+}
+
+// TestRuleHandler runs an unsaved rule over the transaction history and
+// reports what it would change, nothing is saved.
+func (api *Api) TestRuleHandler(r *iz.Request) iz.Responder {
+	traceID := uuid.NewString()
+	ctx := context.WithValue(r.Context(), contextutil.TraceIDKey, traceID)
+
+	userId, ok := r.Context().Value(userIdKey).(string)
+	if !ok {
+		return iz.Respond().Status(500).JSON(appErrors.ErrorResponse{
+			Code:    appErrors.ErrAuth,
+			Message: "UserID not found",
+		})
+	}
+
+	var req RuleRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		return iz.Respond().Status(400).JSON(appErrors.ErrorResponse{
+			Code:    appErrors.ErrInvalidInput,
+			Message: "Invalid request body",
+		})
+	}
+	ruleReq, err := req.ToBudget()
+	if err != nil {
+		return RespondError(err)
+	}
+
+	report, err := api.Service.TestRule(ctx, userId, ruleReq)
+	if err != nil {
+		logging.Logger.Errorf("[TraceID=%s] | failed to test rule | Error: %v", traceID, err)
+		return RespondError(err)
+	}
+
+	return iz.Respond().Status(200).JSON(RuleApplyReportToHttp(report))
+}
+
+// ApplyRulesHandler runs the rules over the whole transaction history.
+func (api *Api) ApplyRulesHandler(r *iz.Request) iz.Responder {
+	traceID := uuid.NewString()
+	ctx := context.WithValue(r.Context(), contextutil.TraceIDKey, traceID)
+
+	userId, ok := r.Context().Value(userIdKey).(string)
+	if !ok {
+		return iz.Respond().Status(500).JSON(appErrors.ErrorResponse{
+			Code:    appErrors.ErrAuth,
+			Message: "UserID not found",
+		})
+	}
+
+	var req RuleApplyRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		return iz.Respond().Status(400).JSON(appErrors.ErrorResponse{
+			Code:    appErrors.ErrInvalidInput,
+			Message: "Invalid request body",
+		})
+	}
+
+	report, err := api.Service.ApplyRules(ctx, userId, req.RuleIds, req.DryRun)
+	if err != nil {
+		logging.Logger.Errorf("[TraceID=%s] | failed to apply rules | Error: %v", traceID, err)
+		return RespondError(err)
+	}
+
+	return iz.Respond().Status(200).JSON(RuleApplyReportToHttp(report))
+}
+
+// SuggestCategoriesHandler takes the same body as creating a transaction and
+// ranks the categories of its type by how likely they are, learned from the
+// user's history.
+func (api *Api) SuggestCategoriesHandler(r *iz.Request) iz.Responder {
+	traceID := uuid.NewString()
+	ctx := context.WithValue(r.Context(), contextutil.TraceIDKey, traceID)
+
+	userId, ok := r.Context().Value(userIdKey).(string)
+	if !ok {
+		return iz.Respond().Status(500).JSON(appErrors.ErrorResponse{
+			Code:    appErrors.ErrAuth,
+			Message: "UserID not found",
+		})
+	}
+
+	limit := 0
+	if l := r.URL.Query().Get("limit"); l != "" {
+		n, err := strconv.Atoi(l)
+		if err != nil {
+			return iz.Respond().Status(400).JSON(appErrors.ErrorResponse{
+				Code:    appErrors.ErrInvalidInput,
+				Message: "Limit must be a number",
+			})
+		}
+		limit = n
+	}
+
+	var req CreateTransactionRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		return iz.Respond().Status(400).JSON(appErrors.ErrorResponse{
+			Code:    appErrors.ErrInvalidInput,
+			Message: "Invalid request body",
+		})
+	}
+	occurredAt, err := ParseDateTime("occurred_at", req.OccurredAt)
+	if err != nil {
+		return RespondError(err)
+	}
+
+	suggestions, err := api.Service.SuggestCategories(ctx, userId, budget.TransactionRequest{
+		CategoryType: req.CategoryType,
+		Amount:       req.Amount,
+		Currency:     req.Currency,
+		Note:         req.Note,
+		OccurredAt:   occurredAt,
+	}, limit)
+	if err != nil {
+		logging.Logger.Errorf("[TraceID=%s] | failed to suggest categories | Error: %v", traceID, err)
+		return RespondError(err)
+	}
 
+	return iz.Respond().Status(200).JSON(CategorySuggestionResponse{Suggestions: CategorySuggestionsToHttp(suggestions)})
 }
@@ -2,6 +2,7 @@ package api
 
 import (
 	"fmt"
+	"math"
 	"net/url"
 	"strconv"
 	"strings"
@@ -14,10 +15,26 @@ import (
 
 // REQUESTS START:
 type CreateTransactionRequest struct {
+	CategoryId   string                 `json:"category_id"`
+	CategoryType string                 `json:"category_type"`
+	Amount       float64                `json:"amount"`
+	Currency     string                 `json:"currency"`
+	Note         string                 `json:"note"`
+	OccurredAt   string                 `json:"occurred_at"`      // RFC 3339 or YYYY-MM-DD, optional
+	Splits       []TransactionSplitItem `json:"splits,omitempty"` // instead of category_id
+	WalletId     string                 `json:"wallet_id"`        // optional, required for transfers
+	ToWalletId   string                 `json:"to_wallet_id"`     // destination of a transfer
+	Tags         []string               `json:"tags,omitempty"`   // created when missing
+	PayeeId      string                 `json:"payee_id"`         // optional, matched from the note when empty
+}
+
+// TransactionSplitItem is a split line in requests and responses, and a
+// receipt line from image processing, where category_id is still empty.
+type TransactionSplitItem struct {
+	ID           string  `json:"id,omitempty"`
 	CategoryId   string  `json:"category_id"`
-	CategoryType string  `json:"category_type"`
+	CategoryName string  `json:"category_name,omitempty"`
 	Amount       float64 `json:"amount"`
-	Currency     string  `json:"currency"`
 	Note         string  `json:"note"`
 }
 
@@ -33,6 +50,60 @@ type DeleteUserRequest struct {
 	Reason   string `json:"reason"`
 }
 
+type ChangePasswordRequest struct {
+	CurrentPassword string `json:"current_password"`
+	NewPassword     string `json:"new_password"`
+}
+
+type ForgotPasswordRequest struct {
+	Email string `json:"email"`
+}
+
+type ResetPasswordRequest struct {
+	Code        string `json:"code"`
+	NewPassword string `json:"new_password"`
+}
+
+type UpdateAccountRequest struct {
+	UserName string `json:"username"`
+	FullName string `json:"fullname"`
+	Email    string `json:"email"`
+}
+
+type ConfirmEmailRequest struct {
+	Code string `json:"code"`
+}
+
+type StatementCategoryMappingRequest struct {
+	ExpenseCategoryId string         `json:"expense_category_id"`
+	IncomeCategoryId  string         `json:"income_category_id"`
+	RowCategories     map[int]string `json:"row_categories"`
+}
+
+type CSVImportMappingRequest struct {
+	StatementCategoryMappingRequest
+	HasHeader         bool   `json:"has_header"`
+	SkipRows          int    `json:"skip_rows"`
+	Delimiter         string `json:"delimiter"`
+	DateColumn        string `json:"date_column"`
+	AmountColumn      string `json:"amount_column"`
+	DebitColumn       string `json:"debit_column"`
+	CreditColumn      string `json:"credit_column"`
+	CurrencyColumn    string `json:"currency_column"`
+	DescriptionColumn string `json:"description_column"`
+	DateFormat        string `json:"date_format"`
+	DecimalSeparator  string `json:"decimal_separator"`
+	SignConvention    string `json:"sign_convention"`
+	Currency          string `json:"currency"`
+}
+
+type StatementImportMappingRequest struct {
+	StatementCategoryMappingRequest
+	Currency         string `json:"currency"`
+	DateOrder        string `json:"date_order"`
+	DecimalSeparator string `json:"decimal_separator"`
+}
+
 type UserLoginRequest struct {
 	UserName string `json:"username"`
 	Password string `json:"password"`
@@ -43,12 +114,22 @@ type ExpenseCategoryRequest struct {
 	MaxAmount float64 `json:"max_amount"`
 	PeriodDay int     `json:"period_day"`
 	Note      string  `json:"note"`
+	ParentId  string  `json:"parent_id"` // optional
 }
 
 type IncomeCategoryRequest struct {
 	Name         string `json:"name"`
 	TargetAmount int    `json:"target_amount"`
 	Note         string `json:"note"`
+	ParentId     string `json:"parent_id"` // optional
+}
+
+type MoveCategoryRequest struct {
+	ParentId string `json:"parent_id"` // empty moves the category to the top level
+}
+
+type ArchiveCategoryRequest struct {
+	Archived bool `json:"archived"`
 }
 
 type UpdateExpenseCategoryRequest struct {
@@ -75,33 +156,51 @@ type OperationResponse struct {
 	Message string `json:"message"`
 	Extra   string `json:"extra"`
 }
+
+type DeleteCategoryResponse struct {
+	Code                 string `json:"code"`
+	Message              string `json:"message"`
+	AffectedTransactions int    `json:"affected_transactions"` // deleted, or moved when moved_to is set
+	MovedTo              string `json:"moved_to,omitempty"`
+}
 type TransactionItem struct {
-	ID           string  `json:"id"`
-	CategoryID   string  `json:"category_id"`
-	CategoryName string  `json:"category_name"`
-	CategoryType string  `json:"category_type"`
-	Amount       float64 `json:"amount"`
-	Currency     string  `json:"currency"`
-	CreatedAt    string  `json:"created_at"`
-	Note         string  `json:"note"`
-	CreatedBy    string  `json:"created_by"`
+	ID           string                 `json:"id"`
+	CategoryID   string                 `json:"category_id"`
+	CategoryName string                 `json:"category_name"`
+	CategoryType string                 `json:"category_type"`
+	Amount       float64                `json:"amount"`
+	Currency     string                 `json:"currency"`
+	OccurredAt   string                 `json:"occurred_at"`
+	CreatedAt    string                 `json:"created_at"` // when the transaction was recorded
+	Note         string                 `json:"note"`
+	CreatedBy    string                 `json:"created_by"`
+	Splits       []TransactionSplitItem `json:"splits,omitempty"`
+	WalletId     string                 `json:"wallet_id,omitempty"`
+	ToWalletId   string                 `json:"to_wallet_id,omitempty"`
+	Cleared      bool                   `json:"cleared"`
+	ReconciledAt string                 `json:"reconciled_at,omitempty"` // reconciled transactions are locked
+	Tags         []string               `json:"tags,omitempty"`
+	PayeeId      string                 `json:"payee_id,omitempty"`
 }
 type ListTransactionResponse struct {
 	Transactions []TransactionItem `json:"transactions"`
 }
 
 type ExpenseCategoryResponseItem struct {
-	ID           string  `json:"id"`
-	Name         string  `json:"name"`
-	Amount       float64 `json:"amount"`
-	MaxAmount    float64 `json:"max_amount"`
-	PeriodDay    int     `json:"period_day"`
-	IsExpired    bool    `json:"is_expired"`
-	UsagePercent int     `json:"usage_percent"`
-	CreatedAt    string  `json:"created_at"`
-	UpdatedAt    string  `json:"updated_at"`
-	Note         string  `json:"note"`
-	CreatedBy    string  `json:"created_by"`
+	ID           string                        `json:"id"`
+	Name         string                        `json:"name"`
+	Amount       float64                       `json:"amount"`     // includes subcategories
+	MaxAmount    float64                       `json:"max_amount"` // includes subcategories
+	PeriodDay    int                           `json:"period_day"`
+	IsExpired    bool                          `json:"is_expired"`
+	UsagePercent int                           `json:"usage_percent"`
+	CreatedAt    string                        `json:"created_at"`
+	UpdatedAt    string                        `json:"updated_at"`
+	Note         string                        `json:"note"`
+	CreatedBy    string                        `json:"created_by"`
+	ParentId     string                        `json:"parent_id,omitempty"`
+	ArchivedAt   string                        `json:"archived_at,omitempty"` // with include_archived=true
+	Children     []ExpenseCategoryResponseItem `json:"children,omitempty"`    // with tree=true
 }
 
 type ExpenseStatsResponse struct {
@@ -117,9 +216,17 @@ type IncomeStatsResponse struct {
 }
 
 type TransactionStatsResponse struct {
+	Expenses float64        `json:"expenses"`
+	Incomes  float64        `json:"incomes"`
+	Total    float64        `json:"total"`
+	Tags     []TagTotalItem `json:"tags"`
+}
+
+type TagTotalItem struct {
+	Name     string  `json:"name"`
 	Expenses float64 `json:"expenses"`
 	Incomes  float64 `json:"incomes"`
-	Total    float64 `json:"total"`
+	Count    int     `json:"count"`
 }
 
 type ListExpenseCategories struct {
@@ -127,15 +234,18 @@ type ListExpenseCategories struct {
 }
 
 type IncomeCategoryResponseItem struct {
-	ID           string  `json:"id"`
-	Name         string  `json:"name"`
-	Amount       float64 `json:"amount"`
-	TargetAmount float64 `json:"target_amount"`
-	UsagePercent int     `json:"usage_percent"`
-	CreatedAt    string  `json:"created_at"`
-	UpdatedAt    string  `json:"updated_at"`
-	Note         string  `json:"note"`
-	CreatedBy    string  `json:"created_by"`
+	ID           string                       `json:"id"`
+	Name         string                       `json:"name"`
+	Amount       float64                      `json:"amount"`        // includes subcategories
+	TargetAmount float64                      `json:"target_amount"` // includes subcategories
+	UsagePercent int                          `json:"usage_percent"`
+	CreatedAt    string                       `json:"created_at"`
+	UpdatedAt    string                       `json:"updated_at"`
+	Note         string                       `json:"note"`
+	CreatedBy    string                       `json:"created_by"`
+	ParentId     string                       `json:"parent_id,omitempty"`
+	ArchivedAt   string                       `json:"archived_at,omitempty"` // with include_archived=true
+	Children     []IncomeCategoryResponseItem `json:"children,omitempty"`    // with tree=true
 }
 
 type ListIncomeCategories struct {
@@ -150,16 +260,76 @@ type ImageToTransactionResponse struct {
 }
 
 type ProcessedImageResponseItem struct {
-	Amounts          []float64 `json:"amounts"`
-	CurrenciesISO    []string  `json:"currencies_iso"`
-	CurrenciesSymbol []string  `json:"currencies_symbol"`
+	Amounts          []float64                `json:"amounts"`
+	CurrenciesISO    []string                 `json:"currencies_iso"`
+	CurrenciesSymbol []string                 `json:"currencies_symbol"`
+	Lines            []TransactionSplitItem   `json:"lines"`
+	Total            float64                  `json:"total"`
+	Payee            string                   `json:"payee"`       // merchant name found on the receipt
+	PayeeId          string                   `json:"payee_id"`    // set when it is one of the user's payees
+	CategoryId       string                   `json:"category_id"` // expense category suggested by the rules or the payee
+	Tags             []string                 `json:"tags"`        // suggested by the rules
+	Suggestions      []CategorySuggestionItem `json:"suggestions"` // learned from history, most likely first
 }
 
 type AccountInfo struct {
-	Username string `json:"username"`
-	Fullname string `json:"fullname"`
-	Email    string `json:"email"`
-	JoinedAt string `json:"joined_at"`
+	Username     string `json:"username"`
+	Fullname     string `json:"fullname"`
+	Email        string `json:"email"`
+	PendingEmail string `json:"pending_email"`
+	JoinedAt     string `json:"joined_at"`
+}
+
+type ImportCategoryCounts struct {
+	Created int `json:"created"`
+	Merged  int `json:"merged"`
+	Renamed int `json:"renamed"`
+	Skipped int `json:"skipped"`
+}
+
+type ImportTransactionCounts struct {
+	Imported   int `json:"imported"`
+	Duplicates int `json:"duplicates"`
+	Skipped    int `json:"skipped"`
+}
+
+type ImportRowError struct {
+	File    string `json:"file"`
+	Index   int    `json:"index"`
+	Message string `json:"message"`
+}
+
+type ImportReportResponse struct {
+	DryRun            bool                    `json:"dry_run"`
+	ExpenseCategories ImportCategoryCounts    `json:"expense_categories"`
+	IncomeCategories  ImportCategoryCounts    `json:"income_categories"`
+	Transactions      ImportTransactionCounts `json:"transactions"`
+	Renamed           map[string]string       `json:"renamed"`
+	Errors            []ImportRowError        `json:"errors"`
+}
+
+type StatementRowItem struct {
+	Row          int      `json:"row"`
+	Date         string   `json:"date"`
+	Amount       float64  `json:"amount"`
+	Currency     string   `json:"currency"`
+	Description  string   `json:"description"`
+	CategoryID   string   `json:"category_id"`
+	CategoryType string   `json:"category_type"`
+	Note         string   `json:"note"`
+	Tags         []string `json:"tags,omitempty"`
+	PayeeId      string   `json:"payee_id,omitempty"`
+	RuleIds      []string `json:"rule_ids,omitempty"` // rules that matched the row
+	Duplicate    bool     `json:"duplicate"`
+}
+
+type StatementImportResponse struct {
+	DryRun     bool               `json:"dry_run"`
+	Imported   int                `json:"imported"`
+	Duplicates int                `json:"duplicates"`
+	Skipped    int                `json:"skipped"`
+	Rows       []StatementRowItem `json:"rows"`
+	Errors     []ImportRowError   `json:"errors"`
 }
 
 func HttpStatusFromErrorCode(errorCode string) int {
@@ -170,7 +340,7 @@ func HttpStatusFromErrorCode(errorCode string) int {
 		return 400 // bad request
 	case appErrors.ErrAuth:
 		return 401 // unauthorized
-	case appErrors.ErrAccessDenied:
+	case appErrors.ErrAccessDenied, appErrors.ErrPendingDeletion:
 		return 403 // access denied
 	case appErrors.ErrConflict:
 		return 409 // conflict
@@ -196,11 +366,16 @@ func IncomeStatsToHttp(stats budget.IncomeStatsResponse) IncomeStatsResponse {
 }
 
 func TransactionStatsToHttp(stats budget.TransactionStatsResponse) TransactionStatsResponse {
-	return TransactionStatsResponse{
+	response := TransactionStatsResponse{
 		Expenses: stats.Expenses,
 		Incomes:  stats.Incomes,
 		Total:    stats.Total,
+		Tags:     make([]TagTotalItem, 0, len(stats.Tags)),
 	}
+	for _, t := range stats.Tags {
+		response.Tags = append(response.Tags, TagTotalItem{Name: t.Name, Expenses: t.Expenses, Incomes: t.Incomes, Count: t.Count})
+	}
+	return response
 }
 
 func TransactionToHttp(transcation budget.Transaction) TransactionItem {
@@ -211,23 +386,130 @@ func TransactionToHttp(transcation budget.Transaction) TransactionItem {
 		CategoryType: transcation.CategoryType,
 		Amount:       transcation.Amount,
 		Currency:     transcation.Currency,
+		OccurredAt:   transcation.OccurredAt.Format(time.RFC3339),
 		CreatedAt:    transcation.CreatedAt.Format(time.RFC3339),
 		Note:         transcation.Note,
 		CreatedBy:    transcation.CreatedBy,
+		Splits:       TransactionSplitsToHttp(transcation.Splits),
+		WalletId:     transcation.WalletId,
+		ToWalletId:   transcation.ToWalletId,
+		Cleared:      transcation.Cleared,
+		ReconciledAt: formatOptionalTime(transcation.ReconciledAt),
+		Tags:         transcation.Tags,
+		PayeeId:      transcation.PayeeId,
+	}
+}
+
+func TransactionSplitsToHttp(splits []budget.TransactionSplit) []TransactionSplitItem {
+	if len(splits) == 0 {
+		return nil
+	}
+	items := make([]TransactionSplitItem, 0, len(splits))
+	for _, split := range splits {
+		items = append(items, TransactionSplitItem{
+			ID:           split.ID,
+			CategoryId:   split.CategoryId,
+			CategoryName: split.CategoryName,
+			Amount:       split.Amount,
+			Note:         split.Note,
+		})
+	}
+	return items
+}
+
+func (req CreateTransactionRequest) SplitsToBudget() []budget.TransactionSplitRequest {
+	if len(req.Splits) == 0 {
+		return nil
+	}
+	splits := make([]budget.TransactionSplitRequest, 0, len(req.Splits))
+	for _, split := range req.Splits {
+		splits = append(splits, budget.TransactionSplitRequest{
+			CategoryId: split.CategoryId,
+			Amount:     split.Amount,
+			Note:       split.Note,
+		})
 	}
+	return splits
 }
 
 func AccountInfoToHttp(accInfo budget.AccountInfo) AccountInfo {
 	return AccountInfo{
-		Username: accInfo.Username,
-		Fullname: accInfo.Fullname,
-		Email:    accInfo.Email,
-		JoinedAt: accInfo.JoinedAt,
+		Username:     accInfo.Username,
+		Fullname:     accInfo.Fullname,
+		Email:        accInfo.Email,
+		PendingEmail: accInfo.PendingEmail,
+		JoinedAt:     accInfo.JoinedAt,
+	}
+}
+
+func ImportReportToHttp(report budget.ImportReport) ImportReportResponse {
+	rowErrors := make([]ImportRowError, 0, len(report.Errors))
+	for _, e := range report.Errors {
+		rowErrors = append(rowErrors, ImportRowError{
+			File:    e.File,
+			Index:   e.Index,
+			Message: e.Message,
+		})
+	}
+
+	return ImportReportResponse{
+		DryRun:            report.DryRun,
+		ExpenseCategories: ImportCategoryCounts(report.ExpenseCategories),
+		IncomeCategories:  ImportCategoryCounts(report.IncomeCategories),
+		Transactions:      ImportTransactionCounts(report.Transactions),
+		Renamed:           report.Renamed,
+		Errors:            rowErrors,
+	}
+}
+
+func (m StatementCategoryMappingRequest) ToBudget() budget.StatementCategoryMapping {
+	return budget.StatementCategoryMapping{
+		ExpenseCategoryId: m.ExpenseCategoryId,
+		IncomeCategoryId:  m.IncomeCategoryId,
+		Rows:              m.RowCategories,
+	}
+}
+
+func StatementImportToHttp(report budget.StatementImportReport) StatementImportResponse {
+	rows := make([]StatementRowItem, 0, len(report.Rows))
+	for _, r := range report.Rows {
+		rows = append(rows, StatementRowItem{
+			Row:          r.Index,
+			Date:         r.Date.Format(time.RFC3339),
+			Amount:       r.Amount,
+			Currency:     r.Currency,
+			Description:  r.Description,
+			CategoryID:   r.CategoryId,
+			CategoryType: r.CategoryType,
+			Note:         r.Note,
+			Tags:         r.Tags,
+			PayeeId:      r.PayeeId,
+			RuleIds:      r.RuleIds,
+			Duplicate:    r.Duplicate,
+		})
+	}
+
+	rowErrors := make([]ImportRowError, 0, len(report.Errors))
+	for _, e := range report.Errors {
+		rowErrors = append(rowErrors, ImportRowError{
+			File:    e.File,
+			Index:   e.Index,
+			Message: e.Message,
+		})
+	}
+
+	return StatementImportResponse{
+		DryRun:     report.DryRun,
+		Imported:   report.Imported,
+		Duplicates: report.Duplicates,
+		Skipped:    report.Skipped,
+		Rows:       rows,
+		Errors:     rowErrors,
 	}
 }
 
 func ExpenseCategoryToHttp(category budget.ExpenseCategoryResponse) ExpenseCategoryResponseItem {
-	return ExpenseCategoryResponseItem{
+	item := ExpenseCategoryResponseItem{
 		ID:           category.ID,
 		Name:         category.Name,
 		Amount:       category.Amount,
@@ -239,11 +521,17 @@ func ExpenseCategoryToHttp(category budget.ExpenseCategoryResponse) ExpenseCateg
 		UpdatedAt:    category.UpdatedAt.Format(time.RFC3339),
 		Note:         category.Note,
 		CreatedBy:    category.CreatedBy,
+		ParentId:     category.ParentId,
+		ArchivedAt:   formatOptionalTime(category.ArchivedAt),
 	}
+	for _, child := range category.Children {
+		item.Children = append(item.Children, ExpenseCategoryToHttp(child))
+	}
+	return item
 }
 
 func IncomeCategoryToHttp(category budget.IncomeCategoryResponse) IncomeCategoryResponseItem {
-	return IncomeCategoryResponseItem{
+	item := IncomeCategoryResponseItem{
 		ID:           category.ID,
 		Name:         category.Name,
 		Amount:       category.Amount,
@@ -253,6 +541,26 @@ func IncomeCategoryToHttp(category budget.IncomeCategoryResponse) IncomeCategory
 		UpdatedAt:    category.UpdatedAt.Format(time.RFC3339),
 		Note:         category.Note,
 		CreatedBy:    category.CreatedBy,
+		ParentId:     category.ParentId,
+		ArchivedAt:   formatOptionalTime(category.ArchivedAt),
+	}
+	for _, child := range category.Children {
+		item.Children = append(item.Children, IncomeCategoryToHttp(child))
+	}
+	return item
+}
+
+// CategoryTypeFromPath maps the {type} of a category path to its sign.
+func CategoryTypeFromPath(pathType string) (string, error) {
+	switch pathType {
+	case "expense":
+		return "-", nil
+	case "income":
+		return "+", nil
+	}
+	return "", appErrors.ErrorResponse{
+		Code:    appErrors.ErrInvalidInput,
+		Message: "Invalid category type, use expense or income.",
 	}
 }
 
@@ -261,7 +569,22 @@ func ProcessedImageToHttp(processedImg budget.ProcessedImageResponse) ProcessedI
 		Amounts:          processedImg.Amounts,
 		CurrenciesISO:    processedImg.CurrenciesISO,
 		CurrenciesSymbol: processedImg.CurrenciesSymbol,
+		Lines:            receiptLinesToHttp(processedImg.Lines),
+		Total:            processedImg.Total,
+		Payee:            processedImg.Payee,
+		PayeeId:          processedImg.PayeeId,
+		CategoryId:       processedImg.CategoryId,
+		Tags:             processedImg.Tags,
+		Suggestions:      CategorySuggestionsToHttp(processedImg.Suggestions),
+	}
+}
+
+func receiptLinesToHttp(lines []budget.TransactionSplitRequest) []TransactionSplitItem {
+	items := make([]TransactionSplitItem, 0, len(lines))
+	for _, line := range lines {
+		items = append(items, TransactionSplitItem{Amount: line.Amount, Note: line.Note})
 	}
+	return items
 }
 
 func IncomeCategoryCheckParams(params url.Values) (*budget.IncomeCategoryList, error) {
@@ -446,6 +769,40 @@ func TransactionCheckParams(params url.Values) (*budget.TransactionList, error)
 
 	hasAnyFilter := false
 
+	// a tags filter makes category names and type optional
+	tags := params.Get("tags")
+	if tags != "" {
+		for _, tag := range strings.Split(tags, ",") {
+			if tag = strings.ToLower(strings.Join(strings.Fields(tag), " ")); tag != "" {
+				filters.Tags = append(filters.Tags, tag)
+			}
+		}
+		hasAnyFilter = len(filters.Tags) > 0
+	}
+
+	// so does a payee filter
+	if payees := params.Get("payee_ids"); payees != "" {
+		for _, id := range strings.Split(payees, ",") {
+			if id = strings.TrimSpace(id); id != "" {
+				filters.PayeeIds = append(filters.PayeeIds, id)
+			}
+		}
+		hasAnyFilter = hasAnyFilter || len(filters.PayeeIds) > 0
+	}
+
+	tagsMode := params.Get("tags_mode")
+	switch tagsMode {
+	case "", budget.TAGS_MODE_ANY:
+		filters.TagsMode = budget.TAGS_MODE_ANY
+	case budget.TAGS_MODE_ALL:
+		filters.TagsMode = budget.TAGS_MODE_ALL
+	default:
+		return nil, appErrors.ErrorResponse{
+			Code:    appErrors.ErrInvalidInput,
+			Message: "Invalid tags mode, use any or all.",
+		}
+	}
+
 	categoryNames := params.Get("category_names")
 
 	if categoryNames != "" {
@@ -460,7 +817,7 @@ func TransactionCheckParams(params url.Values) (*budget.TransactionList, error)
 			filters.CategoryNames = trimmedNames
 			hasAnyFilter = true
 		}
-	} else {
+	} else if len(filters.Tags) == 0 && len(filters.PayeeIds) == 0 {
 		return nil, appErrors.ErrorResponse{
 			Code:    appErrors.ErrInvalidInput,
 			Message: "Category names parameter is required!",
@@ -487,23 +844,27 @@ func TransactionCheckParams(params url.Values) (*budget.TransactionList, error)
 		hasAnyFilter = true
 	}
 
-	createdAtStr := params.Get("created_at")
-	if createdAtStr != "" {
-		createdAt, err := time.Parse("2006-01-02", createdAtStr)
+	// created_at is the old name of the filter, it also means occurred at
+	occurredAtStr := params.Get("occurred_at")
+	if occurredAtStr == "" {
+		occurredAtStr = params.Get("created_at")
+	}
+	if occurredAtStr != "" {
+		occurredAt, err := time.Parse("2006-01-02", occurredAtStr)
 		if err != nil {
 			return nil, appErrors.ErrorResponse{
 				Code:    appErrors.ErrInvalidInput,
-				Message: fmt.Sprintf("Invalid created date: %v", err.Error()),
+				Message: fmt.Sprintf("Invalid occurred date: %v", err.Error()),
 			}
 		}
 
-		filters.CreatedAt = createdAt.UTC()
+		filters.OccurredAt = occurredAt.UTC()
 		hasAnyFilter = true
 	}
 
 	categoryType := params.Get("category_type")
 	if categoryType != "" {
-		if categoryType != "income" && categoryType != "expense" {
+		if categoryType != "income" && categoryType != "expense" && categoryType != "transfer" {
 			return nil, appErrors.ErrorResponse{
 				Code:    appErrors.ErrInvalidInput,
 				Message: "Invalid category type.",
@@ -512,19 +873,1048 @@ func TransactionCheckParams(params url.Values) (*budget.TransactionList, error)
 			if categoryType == "income" {
 				filters.Type = "+"
 				hasAnyFilter = true
+			} else if categoryType == "transfer" {
+				filters.Type = budget.TRANSFER_TYPE
+				hasAnyFilter = true
 			} else {
 				filters.Type = "-"
 				hasAnyFilter = true
 			}
 		}
 
-	} else {
+	} else if len(filters.Tags) == 0 && len(filters.PayeeIds) == 0 {
 		return nil, appErrors.ErrorResponse{
 			Code:    appErrors.ErrInvalidInput,
 			Message: "Category type parameter is required!",
 		}
 	}
 
+	// category names are only unique within a type, transfers have none
+	if len(filters.CategoryNames) > 0 && filters.Type != "+" && filters.Type != "-" {
+		return nil, appErrors.ErrorResponse{
+			Code:    appErrors.ErrInvalidInput,
+			Message: "Category names need category type income or expense.",
+		}
+	}
+
 	filters.IsAllNil = !hasAnyFilter
 	return &filters, nil
 }
+
+// ParseDateTime reads a date field of a request, either a full RFC 3339
+// timestamp or just the day. Empty gives the zero time.
+func ParseDateTime(field string, raw string) (time.Time, error) {
+	if raw == "" {
+		return time.Time{}, nil
+	}
+	if t, err := time.Parse(time.RFC3339, raw); err == nil {
+		return t.UTC(), nil
+	}
+	if t, err := time.Parse("2006-01-02", raw); err == nil {
+		return t, nil
+	}
+	return time.Time{}, appErrors.ErrorResponse{
+		Code:    appErrors.ErrInvalidInput,
+		Message: fmt.Sprintf("Invalid %s, use YYYY-MM-DD or an RFC 3339 date and time", field),
+	}
+}
+
+type RecurringTransactionRequest struct {
+	CategoryId   string  `json:"category_id"`
+	CategoryType string  `json:"category_type"`
+	Amount       float64 `json:"amount"`
+	Currency     string  `json:"currency"`
+	Note         string  `json:"note"`
+	Rule         string  `json:"rule"`     // RRULE, e.g. FREQ=MONTHLY;BYMONTHDAY=1
+	StartAt      string  `json:"start_at"` // optional, today by default
+	EndAt        string  `json:"end_at"`   // optional
+}
+
+func (r RecurringTransactionRequest) ToBudget() (budget.RecurringTransactionRequest, error) {
+	startAt, err := ParseDateTime("start_at", r.StartAt)
+	if err != nil {
+		return budget.RecurringTransactionRequest{}, err
+	}
+	endAt, err := ParseDateTime("end_at", r.EndAt)
+	if err != nil {
+		return budget.RecurringTransactionRequest{}, err
+	}
+	return budget.RecurringTransactionRequest{
+		CategoryId:   r.CategoryId,
+		CategoryType: r.CategoryType,
+		Amount:       r.Amount,
+		Currency:     r.Currency,
+		Note:         r.Note,
+		Rule:         r.Rule,
+		StartAt:      startAt,
+		EndAt:        endAt,
+	}, nil
+}
+
+type RecurringTransactionItem struct {
+	ID           string  `json:"id"`
+	CategoryID   string  `json:"category_id"`
+	CategoryType string  `json:"category_type"`
+	Amount       float64 `json:"amount"`
+	Currency     string  `json:"currency"`
+	Note         string  `json:"note"`
+	Rule         string  `json:"rule"`
+	StartAt      string  `json:"start_at"`
+	EndAt        string  `json:"end_at,omitempty"`
+	NextRunAt    string  `json:"next_run_at,omitempty"` // empty once the schedule has ended
+	Paused       bool    `json:"paused"`
+	PausedReason string  `json:"paused_reason,omitempty"` // set when the scheduler paused it
+	CreatedAt    string  `json:"created_at"`
+}
+
+type ListRecurringTransactionResponse struct {
+	RecurringTransactions []RecurringTransactionItem `json:"recurring_transactions"`
+}
+
+type UpcomingTransactionItem struct {
+	RecurringID  string  `json:"recurring_id"`
+	CategoryID   string  `json:"category_id"`
+	CategoryType string  `json:"category_type"`
+	Amount       float64 `json:"amount"`
+	Currency     string  `json:"currency"`
+	Note         string  `json:"note"`
+	OccursAt     string  `json:"occurs_at"`
+}
+
+type ListUpcomingTransactionResponse struct {
+	Upcoming []UpcomingTransactionItem `json:"upcoming"`
+}
+
+func formatOptionalTime(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	return t.Format(time.RFC3339)
+}
+
+func RecurringTransactionToHttp(r budget.RecurringTransaction) RecurringTransactionItem {
+	return RecurringTransactionItem{
+		ID:           r.ID,
+		CategoryID:   r.CategoryId,
+		CategoryType: r.CategoryType,
+		Amount:       r.Amount,
+		Currency:     r.Currency,
+		Note:         r.Note,
+		Rule:         r.Rule,
+		StartAt:      r.StartAt.Format(time.RFC3339),
+		EndAt:        formatOptionalTime(r.EndAt),
+		NextRunAt:    formatOptionalTime(r.NextRunAt),
+		Paused:       r.Paused,
+		PausedReason: r.PausedReason,
+		CreatedAt:    r.CreatedAt.Format(time.RFC3339),
+	}
+}
+
+func UpcomingTransactionToHttp(u budget.UpcomingTransaction) UpcomingTransactionItem {
+	return UpcomingTransactionItem{
+		RecurringID:  u.RecurringId,
+		CategoryID:   u.CategoryId,
+		CategoryType: u.CategoryType,
+		Amount:       u.Amount,
+		Currency:     u.Currency,
+		Note:         u.Note,
+		OccursAt:     u.OccursAt.Format(time.RFC3339),
+	}
+}
+
+type WalletRequest struct {
+	Name           string  `json:"name"`
+	Type           string  `json:"type"`     // cash, checking, credit_card or savings
+	Currency       string  `json:"currency"` // cannot be changed after creation
+	OpeningBalance float64 `json:"opening_balance"`
+}
+
+func (r WalletRequest) ToBudget() budget.WalletRequest {
+	return budget.WalletRequest{
+		Name:           r.Name,
+		Type:           r.Type,
+		Currency:       r.Currency,
+		OpeningBalance: r.OpeningBalance,
+	}
+}
+
+type WalletItem struct {
+	ID             string  `json:"id"`
+	Name           string  `json:"name"`
+	Type           string  `json:"type"`
+	Currency       string  `json:"currency"`
+	OpeningBalance float64 `json:"opening_balance"`
+	Balance        float64 `json:"balance"`
+	CreatedAt      string  `json:"created_at"`
+	UpdatedAt      string  `json:"updated_at"`
+}
+
+type ListWalletResponse struct {
+	Wallets []WalletItem `json:"wallets"`
+}
+
+type WalletEntryItem struct {
+	Transaction TransactionItem `json:"transaction"`
+	Amount      float64         `json:"amount"`  // signed from the wallet's side
+	Balance     float64         `json:"balance"` // running balance after the transaction
+}
+
+type WalletRegisterResponse struct {
+	Wallet  WalletItem        `json:"wallet"`
+	Entries []WalletEntryItem `json:"entries"`
+}
+
+func WalletToHttp(w budget.Wallet) WalletItem {
+	return WalletItem{
+		ID:             w.ID,
+		Name:           w.Name,
+		Type:           w.Type,
+		Currency:       w.Currency,
+		OpeningBalance: w.OpeningBalance,
+		Balance:        w.Balance,
+		CreatedAt:      w.CreatedAt.Format(time.RFC3339),
+		UpdatedAt:      w.UpdatedAt.Format(time.RFC3339),
+	}
+}
+
+type ReconciliationRequest struct {
+	StatementBalance float64 `json:"statement_balance"`
+	StatementDate    string  `json:"statement_date"` // RFC 3339 or YYYY-MM-DD
+}
+
+func (r ReconciliationRequest) ToBudget() (budget.ReconciliationRequest, error) {
+	statementDate, err := ParseDateTime("statement_date", r.StatementDate)
+	if err != nil {
+		return budget.ReconciliationRequest{}, err
+	}
+	return budget.ReconciliationRequest{
+		StatementBalance: r.StatementBalance,
+		StatementDate:    statementDate,
+	}, nil
+}
+
+type ClearTransactionsRequest struct {
+	TransactionIds []string `json:"transaction_ids"`
+	Cleared        bool     `json:"cleared"`
+}
+
+type ReconciliationItem struct {
+	ID               string  `json:"id"`
+	WalletId         string  `json:"wallet_id"`
+	StatementBalance float64 `json:"statement_balance"`
+	StatementDate    string  `json:"statement_date"`
+	FinishedAt       string  `json:"finished_at,omitempty"`
+	CreatedAt        string  `json:"created_at"`
+}
+
+type ReconciliationStatusResponse struct {
+	Wallet         WalletItem          `json:"wallet"`
+	Reconciliation *ReconciliationItem `json:"reconciliation"` // null when none is open
+	ClearedBalance float64             `json:"cleared_balance"`
+	Difference     float64             `json:"difference"`
+	Unreconciled   []TransactionItem   `json:"unreconciled"`
+}
+
+func ReconciliationToHttp(r budget.Reconciliation) ReconciliationItem {
+	return ReconciliationItem{
+		ID:               r.ID,
+		WalletId:         r.WalletId,
+		StatementBalance: r.StatementBalance,
+		StatementDate:    r.StatementDate.Format(time.RFC3339),
+		FinishedAt:       formatOptionalTime(r.FinishedAt),
+		CreatedAt:        r.CreatedAt.Format(time.RFC3339),
+	}
+}
+
+func ReconciliationStatusToHttp(status budget.ReconciliationStatus) ReconciliationStatusResponse {
+	response := ReconciliationStatusResponse{
+		Wallet:         WalletToHttp(status.Wallet),
+		ClearedBalance: status.ClearedBalance,
+		Difference:     status.Difference,
+		Unreconciled:   make([]TransactionItem, 0, len(status.Unreconciled)),
+	}
+	if status.Reconciliation != nil {
+		r := ReconciliationToHttp(*status.Reconciliation)
+		response.Reconciliation = &r
+	}
+	for _, t := range status.Unreconciled {
+		response.Unreconciled = append(response.Unreconciled, TransactionToHttp(t))
+	}
+	return response
+}
+
+type TagRequest struct {
+	Name string `json:"name"`
+}
+
+type TransactionTagsRequest struct {
+	Tags []string `json:"tags"` // replaces the tags of the transaction
+}
+
+type TagItem struct {
+	ID               string `json:"id"`
+	Name             string `json:"name"`
+	TransactionCount int    `json:"transaction_count"`
+	CreatedAt        string `json:"created_at"`
+	UpdatedAt        string `json:"updated_at"`
+}
+
+type ListTagResponse struct {
+	Tags []TagItem `json:"tags"`
+}
+
+func TagToHttp(t budget.Tag) TagItem {
+	return TagItem{
+		ID:               t.ID,
+		Name:             t.Name,
+		TransactionCount: t.TransactionCount,
+		CreatedAt:        t.CreatedAt.Format(time.RFC3339),
+		UpdatedAt:        t.UpdatedAt.Format(time.RFC3339),
+	}
+}
+
+type CategoryMergeRequest struct {
+	TargetId      string   `json:"target_id"`
+	SourceIds     []string `json:"source_ids"`
+	LimitStrategy string   `json:"limit_strategy"` // target, sum or max
+	NoteStrategy  string   `json:"note_strategy"`  // target or join
+}
+
+type MergedCategoryItem struct {
+	ID    string  `json:"id"`
+	Name  string  `json:"name"`
+	Limit float64 `json:"limit"`
+	Note  string  `json:"note"`
+}
+
+type CategoryMergeItem struct {
+	ID                string               `json:"id"`
+	CategoryType      string               `json:"category_type"`
+	TargetID          string               `json:"target_id"`
+	TargetName        string               `json:"target_name"`
+	Sources           []MergedCategoryItem `json:"sources"`
+	LimitStrategy     string               `json:"limit_strategy"`
+	NoteStrategy      string               `json:"note_strategy"`
+	OldLimit          float64              `json:"old_limit"` // max_amount of expense, target_amount of income categories
+	NewLimit          float64              `json:"new_limit"`
+	OldNote           string               `json:"old_note"`
+	NewNote           string               `json:"new_note"`
+	MovedTransactions int                  `json:"moved_transactions"`
+	CreatedAt         string               `json:"created_at"`
+}
+
+type ListCategoryMergeResponse struct {
+	Merges []CategoryMergeItem `json:"merges"`
+}
+
+func CategoryMergeToHttp(m budget.CategoryMerge) CategoryMergeItem {
+	item := CategoryMergeItem{
+		ID:                m.ID,
+		CategoryType:      m.CategoryType,
+		TargetID:          m.TargetId,
+		TargetName:        m.TargetName,
+		Sources:           make([]MergedCategoryItem, 0, len(m.Sources)),
+		LimitStrategy:     m.LimitStrategy,
+		NoteStrategy:      m.NoteStrategy,
+		OldLimit:          m.OldLimit,
+		NewLimit:          m.NewLimit,
+		OldNote:           m.OldNote,
+		NewNote:           m.NewNote,
+		MovedTransactions: m.MovedTransactions,
+		CreatedAt:         m.CreatedAt.Format(time.RFC3339),
+	}
+	for _, source := range m.Sources {
+		item.Sources = append(item.Sources, MergedCategoryItem{ID: source.ID, Name: source.Name, Limit: source.Limit, Note: source.Note})
+	}
+	return item
+}
+
+type SavingsGoalRequest struct {
+	Name         string  `json:"name"`
+	TargetAmount float64 `json:"target_amount"`
+	Currency     string  `json:"currency"`    // optional when a wallet is linked
+	TargetDate   string  `json:"target_date"` // RFC 3339 or YYYY-MM-DD
+	WalletId     string  `json:"wallet_id"`   // optional
+	Note         string  `json:"note"`
+}
+
+func (r SavingsGoalRequest) ToBudget() (budget.SavingsGoalRequest, error) {
+	targetDate, err := ParseDateTime("target_date", r.TargetDate)
+	if err != nil {
+		return budget.SavingsGoalRequest{}, err
+	}
+	return budget.SavingsGoalRequest{
+		Name:         r.Name,
+		TargetAmount: r.TargetAmount,
+		Currency:     r.Currency,
+		TargetDate:   targetDate,
+		WalletId:     r.WalletId,
+		Note:         r.Note,
+	}, nil
+}
+
+type SavingsContributionRequest struct {
+	Type       string  `json:"type"`   // contribution (default) or withdrawal
+	Amount     float64 `json:"amount"` // positive for both types
+	OccurredAt string  `json:"occurred_at"`
+	Note       string  `json:"note"`
+}
+
+func (r SavingsContributionRequest) ToBudget() (budget.SavingsContributionRequest, error) {
+	occurredAt, err := ParseDateTime("occurred_at", r.OccurredAt)
+	if err != nil {
+		return budget.SavingsContributionRequest{}, err
+	}
+	return budget.SavingsContributionRequest{
+		Type:       r.Type,
+		Amount:     r.Amount,
+		OccurredAt: occurredAt,
+		Note:       r.Note,
+	}, nil
+}
+
+type SavingsGoalItem struct {
+	ID                 string  `json:"id"`
+	Name               string  `json:"name"`
+	TargetAmount       float64 `json:"target_amount"`
+	Currency           string  `json:"currency"`
+	TargetDate         string  `json:"target_date"`
+	WalletId           string  `json:"wallet_id,omitempty"`
+	Note               string  `json:"note"`
+	Saved              float64 `json:"saved"`
+	Remaining          float64 `json:"remaining"`
+	Percent            int     `json:"percent"`
+	MonthsLeft         int     `json:"months_left"`
+	RequiredMonthly    float64 `json:"required_monthly"`
+	AverageMonthly     float64 `json:"average_monthly"`
+	ProjectedDate      string  `json:"projected_date,omitempty"` // at the average pace
+	Status             string  `json:"status"`                   // reached, on_track, behind or overdue
+	WalletBalance      float64 `json:"wallet_balance,omitempty"`
+	WalletBalanceShort bool    `json:"wallet_balance_short,omitempty"` // the wallet holds less than is saved
+	CreatedAt          string  `json:"created_at"`
+	UpdatedAt          string  `json:"updated_at"`
+}
+
+type ListSavingsGoalResponse struct {
+	Goals []SavingsGoalItem `json:"goals"`
+}
+
+type SavingsContributionItem struct {
+	ID         string  `json:"id"`
+	Amount     float64 `json:"amount"` // negative for withdrawals
+	OccurredAt string  `json:"occurred_at"`
+	Note       string  `json:"note"`
+	Saved      float64 `json:"saved"` // after this contribution
+	CreatedAt  string  `json:"created_at"`
+}
+
+type SavingsGoalHistoryResponse struct {
+	Goal    SavingsGoalItem           `json:"goal"`
+	Entries []SavingsContributionItem `json:"entries"`
+}
+
+func SavingsGoalToHttp(p budget.SavingsGoalProgress) SavingsGoalItem {
+	return SavingsGoalItem{
+		ID:                 p.Goal.ID,
+		Name:               p.Goal.Name,
+		TargetAmount:       p.Goal.TargetAmount,
+		Currency:           p.Goal.Currency,
+		TargetDate:         p.Goal.TargetDate.Format(time.RFC3339),
+		WalletId:           p.Goal.WalletId,
+		Note:               p.Goal.Note,
+		Saved:              p.Goal.Saved,
+		Remaining:          p.Remaining,
+		Percent:            p.Percent,
+		MonthsLeft:         p.MonthsLeft,
+		RequiredMonthly:    p.RequiredMonthly,
+		AverageMonthly:     p.AverageMonthly,
+		ProjectedDate:      formatOptionalTime(p.ProjectedDate),
+		Status:             p.Status,
+		WalletBalance:      p.WalletBalance,
+		WalletBalanceShort: p.WalletBalanceShort,
+		CreatedAt:          p.Goal.CreatedAt.Format(time.RFC3339),
+		UpdatedAt:          p.Goal.UpdatedAt.Format(time.RFC3339),
+	}
+}
+
+type DebtRequest struct {
+	Name               string  `json:"name"`
+	Principal          float64 `json:"principal"`
+	InterestRate       float64 `json:"interest_rate"` // yearly, in percent
+	TermMonths         int     `json:"term_months"`
+	FirstPaymentAt     string  `json:"first_payment_at"` // RFC 3339 or YYYY-MM-DD
+	Currency           string  `json:"currency"`         // optional when a wallet is linked
+	WalletId           string  `json:"wallet_id"`        // optional
+	CategoryId         string  `json:"category_id"`
+	InterestCategoryId string  `json:"interest_category_id"` // optional, category_id by default
+	Note               string  `json:"note"`
+}
+
+func (r DebtRequest) ToBudget() (budget.DebtRequest, error) {
+	firstPaymentAt, err := ParseDateTime("first_payment_at", r.FirstPaymentAt)
+	if err != nil {
+		return budget.DebtRequest{}, err
+	}
+	return budget.DebtRequest{
+		Name:               r.Name,
+		Principal:          r.Principal,
+		InterestRate:       r.InterestRate,
+		TermMonths:         r.TermMonths,
+		FirstPaymentAt:     firstPaymentAt,
+		Currency:           r.Currency,
+		WalletId:           r.WalletId,
+		CategoryId:         r.CategoryId,
+		InterestCategoryId: r.InterestCategoryId,
+		Note:               r.Note,
+	}, nil
+}
+
+type DebtPaymentRequest struct {
+	Amount     float64 `json:"amount"` // the scheduled payment when zero
+	OccurredAt string  `json:"occurred_at"`
+	Note       string  `json:"note"`
+}
+
+func (r DebtPaymentRequest) ToBudget() (budget.DebtPaymentRequest, error) {
+	occurredAt, err := ParseDateTime("occurred_at", r.OccurredAt)
+	if err != nil {
+		return budget.DebtPaymentRequest{}, err
+	}
+	return budget.DebtPaymentRequest{
+		Amount:     r.Amount,
+		OccurredAt: occurredAt,
+		Note:       r.Note,
+	}, nil
+}
+
+type DebtPlanRequest struct {
+	Strategy     string   `json:"strategy"` // snowball or avalanche (default)
+	ExtraMonthly float64  `json:"extra_monthly"`
+	DebtIds      []string `json:"debt_ids"` // optional, every debt not paid off by default
+}
+
+type DebtItem struct {
+	ID                 string  `json:"id"`
+	Name               string  `json:"name"`
+	Principal          float64 `json:"principal"`
+	InterestRate       float64 `json:"interest_rate"`
+	TermMonths         int     `json:"term_months"`
+	FirstPaymentAt     string  `json:"first_payment_at"`
+	Currency           string  `json:"currency"`
+	WalletId           string  `json:"wallet_id,omitempty"`
+	CategoryId         string  `json:"category_id"`
+	InterestCategoryId string  `json:"interest_category_id"`
+	Note               string  `json:"note"`
+	PaidPrincipal      float64 `json:"paid_principal"`
+	PaidInterest       float64 `json:"paid_interest"`
+	Balance            float64 `json:"balance"`
+	ScheduledPayment   float64 `json:"scheduled_payment"`
+	NextPaymentAt      string  `json:"next_payment_at,omitempty"` // missing once paid off
+	PaymentsLeft       int     `json:"payments_left"`
+	PayoffDate         string  `json:"payoff_date,omitempty"`
+	RemainingInterest  float64 `json:"remaining_interest"`
+	CreatedAt          string  `json:"created_at"`
+	UpdatedAt          string  `json:"updated_at"`
+}
+
+type ListDebtResponse struct {
+	Debts []DebtItem `json:"debts"`
+}
+
+type DebtPaymentItem struct {
+	ID            string  `json:"id"`
+	DebtId        string  `json:"debt_id"`
+	TransactionId string  `json:"transaction_id,omitempty"`
+	Amount        float64 `json:"amount"`
+	Principal     float64 `json:"principal"`
+	Interest      float64 `json:"interest"`
+	OccurredAt    string  `json:"occurred_at"`
+	CreatedAt     string  `json:"created_at"`
+}
+
+type DebtPaymentResponse struct {
+	Payment DebtPaymentItem `json:"payment"`
+	Debt    DebtItem        `json:"debt"`
+}
+
+type ListDebtPaymentResponse struct {
+	Payments []DebtPaymentItem `json:"payments"`
+}
+
+type AmortizationRowItem struct {
+	Number    int     `json:"number"`
+	DueAt     string  `json:"due_at"`
+	Payment   float64 `json:"payment"`
+	Principal float64 `json:"principal"`
+	Interest  float64 `json:"interest"`
+	Balance   float64 `json:"balance"`
+}
+
+type DebtScheduleResponse struct {
+	Debt          DebtItem              `json:"debt"`
+	ExtraMonthly  float64               `json:"extra_monthly"`
+	PayoffDate    string                `json:"payoff_date,omitempty"`
+	TotalInterest float64               `json:"total_interest"`
+	InterestSaved float64               `json:"interest_saved"`
+	PaymentsSaved int                   `json:"payments_saved"`
+	Rows          []AmortizationRowItem `json:"rows"`
+}
+
+type DebtPlanItem struct {
+	ID            string  `json:"id"`
+	Name          string  `json:"name"`
+	InterestRate  float64 `json:"interest_rate"`
+	Balance       float64 `json:"balance"`
+	Months        int     `json:"months"`
+	PayoffDate    string  `json:"payoff_date"`
+	TotalInterest float64 `json:"total_interest"`
+}
+
+type DebtPlanResponse struct {
+	Strategy      string         `json:"strategy"`
+	Currency      string         `json:"currency"`
+	ExtraMonthly  float64        `json:"extra_monthly"`
+	Months        int            `json:"months"`
+	PayoffDate    string         `json:"payoff_date"`
+	TotalInterest float64        `json:"total_interest"`
+	InterestSaved float64        `json:"interest_saved"`
+	MonthsSaved   int            `json:"months_saved"`
+	Debts         []DebtPlanItem `json:"debts"` // in the order the extra money goes to
+}
+
+func DebtToHttp(s budget.DebtStatus) DebtItem {
+	return DebtItem{
+		ID:                 s.Debt.ID,
+		Name:               s.Debt.Name,
+		Principal:          s.Debt.Principal,
+		InterestRate:       s.Debt.InterestRate,
+		TermMonths:         s.Debt.TermMonths,
+		FirstPaymentAt:     s.Debt.FirstPaymentAt.Format(time.RFC3339),
+		Currency:           s.Debt.Currency,
+		WalletId:           s.Debt.WalletId,
+		CategoryId:         s.Debt.CategoryId,
+		InterestCategoryId: s.Debt.InterestCategoryId,
+		Note:               s.Debt.Note,
+		PaidPrincipal:      s.Debt.PaidPrincipal,
+		PaidInterest:       s.Debt.PaidInterest,
+		Balance:            s.Balance,
+		ScheduledPayment:   s.ScheduledPayment,
+		NextPaymentAt:      formatOptionalTime(s.NextPaymentAt),
+		PaymentsLeft:       s.PaymentsLeft,
+		PayoffDate:         formatOptionalTime(s.PayoffDate),
+		RemainingInterest:  s.RemainingInterest,
+		CreatedAt:          s.Debt.CreatedAt.Format(time.RFC3339),
+		UpdatedAt:          s.Debt.UpdatedAt.Format(time.RFC3339),
+	}
+}
+
+func DebtPaymentToHttp(p budget.DebtPayment) DebtPaymentItem {
+	return DebtPaymentItem{
+		ID:            p.ID,
+		DebtId:        p.DebtId,
+		TransactionId: p.TransactionId,
+		Amount:        p.Amount,
+		Principal:     p.Principal,
+		Interest:      p.Interest,
+		OccurredAt:    p.OccurredAt.Format(time.RFC3339),
+		CreatedAt:     p.CreatedAt.Format(time.RFC3339),
+	}
+}
+
+type PriceChangeItem struct {
+	ChangedAt string  `json:"changed_at"`
+	OldAmount float64 `json:"old_amount"`
+	NewAmount float64 `json:"new_amount"`
+	Percent   float64 `json:"percent"`
+}
+
+type DetectedSubscriptionItem struct {
+	ID             string            `json:"id"`
+	Name           string            `json:"name"`
+	CategoryId     string            `json:"category_id"`
+	Currency       string            `json:"currency"`
+	Period         string            `json:"period"` // weekly, biweekly, monthly, quarterly or yearly
+	Rule           string            `json:"rule"`
+	Amount         float64           `json:"amount"`
+	MonthlyCost    float64           `json:"monthly_cost"`
+	Charges        int               `json:"charges"`
+	FirstChargeAt  string            `json:"first_charge_at"`
+	LastChargeAt   string            `json:"last_charge_at"`
+	NextExpectedAt string            `json:"next_expected_at"`
+	Active         bool              `json:"active"`
+	PriceChanged   bool              `json:"price_changed"` // the latest charge has a new price
+	PriceChanges   []PriceChangeItem `json:"price_changes"`
+	RecurringId    string            `json:"recurring_id,omitempty"` // already tracked by this recurring transaction
+	TransactionIds []string          `json:"transaction_ids"`
+}
+
+type ListDetectedSubscriptionResponse struct {
+	Subscriptions []DetectedSubscriptionItem `json:"subscriptions"`
+	MonthlyTotal  map[string]float64         `json:"monthly_total"` // of the active ones, by currency
+}
+
+func DetectedSubscriptionToHttp(s budget.DetectedSubscription) DetectedSubscriptionItem {
+	item := DetectedSubscriptionItem{
+		ID:             s.ID,
+		Name:           s.Name,
+		CategoryId:     s.CategoryId,
+		Currency:       s.Currency,
+		Period:         s.Period,
+		Rule:           s.Rule,
+		Amount:         s.Amount,
+		MonthlyCost:    s.MonthlyCost,
+		Charges:        s.Charges,
+		FirstChargeAt:  s.FirstChargeAt.Format(time.RFC3339),
+		LastChargeAt:   s.LastChargeAt.Format(time.RFC3339),
+		NextExpectedAt: formatOptionalTime(s.NextExpectedAt),
+		Active:         s.Active,
+		PriceChanged:   s.PriceChanged,
+		PriceChanges:   make([]PriceChangeItem, 0, len(s.PriceChanges)),
+		RecurringId:    s.RecurringId,
+		TransactionIds: s.TransactionIds,
+	}
+	for _, c := range s.PriceChanges {
+		item.PriceChanges = append(item.PriceChanges, PriceChangeItem{
+			ChangedAt: c.ChangedAt.Format(time.RFC3339),
+			OldAmount: c.OldAmount,
+			NewAmount: c.NewAmount,
+			Percent:   c.Percent,
+		})
+	}
+	return item
+}
+
+type PayeeAliasItem struct {
+	Pattern string `json:"pattern"`
+	Match   string `json:"match"` // contains (default), prefix, exact or regex
+}
+
+type PayeeRequest struct {
+	Name                string           `json:"name"`
+	DefaultCategoryId   string           `json:"default_category_id"`   // optional
+	DefaultCategoryType string           `json:"default_category_type"` // expense or income, with default_category_id
+	Aliases             []PayeeAliasItem `json:"aliases"`
+}
+
+func (r PayeeRequest) ToBudget() (budget.PayeeRequest, error) {
+	req := budget.PayeeRequest{
+		Name:              r.Name,
+		DefaultCategoryId: r.DefaultCategoryId,
+		Aliases:           make([]budget.PayeeAlias, 0, len(r.Aliases)),
+	}
+	if r.DefaultCategoryId != "" {
+		categoryType, err := CategoryTypeFromPath(r.DefaultCategoryType)
+		if err != nil {
+			return budget.PayeeRequest{}, err
+		}
+		req.DefaultCategoryType = categoryType
+	}
+	for _, a := range r.Aliases {
+		req.Aliases = append(req.Aliases, budget.PayeeAlias{Pattern: a.Pattern, Match: a.Match})
+	}
+	return req, nil
+}
+
+type TransactionPayeeRequest struct {
+	PayeeId string `json:"payee_id"` // empty unlinks the payee
+}
+
+type PayeeItem struct {
+	ID                  string           `json:"id"`
+	Name                string           `json:"name"`
+	DefaultCategoryId   string           `json:"default_category_id,omitempty"`
+	DefaultCategoryType string           `json:"default_category_type,omitempty"` // expense or income
+	Aliases             []PayeeAliasItem `json:"aliases"`
+	TransactionCount    int              `json:"transaction_count"`
+	CreatedAt           string           `json:"created_at"`
+	UpdatedAt           string           `json:"updated_at"`
+}
+
+type ListPayeeResponse struct {
+	Payees []PayeeItem `json:"payees"`
+}
+
+type LinkPayeeResponse struct {
+	Linked int `json:"linked"` // transactions linked to the payee
+}
+
+func PayeeToHttp(p budget.Payee) PayeeItem {
+	item := PayeeItem{
+		ID:                p.ID,
+		Name:              p.Name,
+		DefaultCategoryId: p.DefaultCategoryId,
+		Aliases:           make([]PayeeAliasItem, 0, len(p.Aliases)),
+		TransactionCount:  p.TransactionCount,
+		CreatedAt:         p.CreatedAt.Format(time.RFC3339),
+		UpdatedAt:         p.UpdatedAt.Format(time.RFC3339),
+	}
+	switch p.DefaultCategoryType {
+	case "-":
+		item.DefaultCategoryType = "expense"
+	case "+":
+		item.DefaultCategoryType = "income"
+	}
+	for _, a := range p.Aliases {
+		item.Aliases = append(item.Aliases, PayeeAliasItem{Pattern: a.Pattern, Match: a.Match})
+	}
+	return item
+}
+
+type PayeeMonthItem struct {
+	Month string  `json:"month"` // YYYY-MM
+	Total float64 `json:"total"`
+	Count int     `json:"count"`
+}
+
+type PayeeStatsItem struct {
+	PayeeId  string           `json:"payee_id"`
+	Name     string           `json:"name"`
+	Currency string           `json:"currency"`
+	Count    int              `json:"count"`
+	Total    float64          `json:"total"`
+	Average  float64          `json:"average"`
+	Largest  float64          `json:"largest"`
+	FirstAt  string           `json:"first_at"`
+	LastAt   string           `json:"last_at"`
+	Months   []PayeeMonthItem `json:"months"`
+}
+
+type PayeeStatsResponse struct {
+	Months int              `json:"months"`
+	Payees []PayeeStatsItem `json:"payees"`
+}
+
+func PayeeStatsToHttp(s budget.PayeeStats) PayeeStatsItem {
+	item := PayeeStatsItem{
+		PayeeId:  s.PayeeId,
+		Name:     s.Name,
+		Currency: s.Currency,
+		Count:    s.Count,
+		Total:    s.Total,
+		Average:  s.Average,
+		Largest:  s.Largest,
+		FirstAt:  s.FirstAt.Format(time.RFC3339),
+		LastAt:   s.LastAt.Format(time.RFC3339),
+		Months:   make([]PayeeMonthItem, 0, len(s.Months)),
+	}
+	for _, m := range s.Months {
+		item.Months = append(item.Months, PayeeMonthItem{Month: m.Month.Format("2006-01"), Total: m.Total, Count: m.Count})
+	}
+	return item
+}
+
+type RuleConditionsItem struct {
+	CategoryType string   `json:"category_type"` // expense or income, empty matches both
+	NotePattern  string   `json:"note_pattern"`  // regular expression, case-insensitive
+	PayeePattern string   `json:"payee_pattern"` // regular expression on the payee name
+	AmountMin    float64  `json:"amount_min"`
+	AmountMax    float64  `json:"amount_max"`
+	Currency     string   `json:"currency"`
+	Weekdays     []string `json:"weekdays"` // monday ... sunday
+}
+
+type RuleActionsItem struct {
+	CategoryId string   `json:"category_id"` // of conditions.category_type
+	Tags       []string `json:"tags"`
+	Note       string   `json:"note"`
+}
+
+type RuleRequest struct {
+	Name       string             `json:"name"`
+	Priority   int                `json:"priority"` // lower runs first
+	Enabled    *bool              `json:"enabled"`  // default true
+	Conditions RuleConditionsItem `json:"conditions"`
+	Actions    RuleActionsItem    `json:"actions"`
+}
+
+func (r RuleRequest) ToBudget() (budget.RuleRequest, error) {
+	req := budget.RuleRequest{
+		Name:     r.Name,
+		Priority: r.Priority,
+		Enabled:  r.Enabled == nil || *r.Enabled,
+		Conditions: budget.RuleConditions{
+			NotePattern:  r.Conditions.NotePattern,
+			PayeePattern: r.Conditions.PayeePattern,
+			AmountMin:    r.Conditions.AmountMin,
+			AmountMax:    r.Conditions.AmountMax,
+			Currency:     r.Conditions.Currency,
+		},
+		Actions: budget.RuleActions{
+			CategoryId: r.Actions.CategoryId,
+			Tags:       r.Actions.Tags,
+			Note:       r.Actions.Note,
+		},
+	}
+	if r.Conditions.CategoryType != "" {
+		categoryType, err := CategoryTypeFromPath(r.Conditions.CategoryType)
+		if err != nil {
+			return budget.RuleRequest{}, err
+		}
+		req.Conditions.CategoryType = categoryType
+	}
+	for _, name := range r.Conditions.Weekdays {
+		day, ok := weekdayNames[strings.ToLower(strings.TrimSpace(name))]
+		if !ok {
+			return budget.RuleRequest{}, appErrors.ErrorResponse{
+				Code:    appErrors.ErrInvalidInput,
+				Message: fmt.Sprintf("Invalid weekday: %s", name),
+			}
+		}
+		req.Conditions.Weekdays = append(req.Conditions.Weekdays, day)
+	}
+	return req, nil
+}
+
+var weekdayNames = map[string]time.Weekday{
+	"sunday":    time.Sunday,
+	"monday":    time.Monday,
+	"tuesday":   time.Tuesday,
+	"wednesday": time.Wednesday,
+	"thursday":  time.Thursday,
+	"friday":    time.Friday,
+	"saturday":  time.Saturday,
+}
+
+type RuleItem struct {
+	ID         string             `json:"id"`
+	Name       string             `json:"name"`
+	Priority   int                `json:"priority"`
+	Enabled    bool               `json:"enabled"`
+	Conditions RuleConditionsItem `json:"conditions"`
+	Actions    RuleActionsItem    `json:"actions"`
+	CreatedAt  string             `json:"created_at"`
+	UpdatedAt  string             `json:"updated_at"`
+}
+
+type ListRuleResponse struct {
+	Rules []RuleItem `json:"rules"`
+}
+
+func categoryTypeToHttp(categoryType string) string {
+	switch categoryType {
+	case "-":
+		return "expense"
+	case "+":
+		return "income"
+	}
+	return ""
+}
+
+func RuleToHttp(r budget.Rule) RuleItem {
+	c, a := r.Conditions, r.Actions
+	item := RuleItem{
+		ID:       r.ID,
+		Name:     r.Name,
+		Priority: r.Priority,
+		Enabled:  r.Enabled,
+		Conditions: RuleConditionsItem{
+			CategoryType: categoryTypeToHttp(c.CategoryType),
+			NotePattern:  c.NotePattern,
+			PayeePattern: c.PayeePattern,
+			AmountMin:    c.AmountMin,
+			AmountMax:    c.AmountMax,
+			Currency:     c.Currency,
+			Weekdays:     make([]string, 0, len(c.Weekdays)),
+		},
+		Actions: RuleActionsItem{
+			CategoryId: a.CategoryId,
+			Tags:       a.Tags,
+			Note:       a.Note,
+		},
+		CreatedAt: r.CreatedAt.Format(time.RFC3339),
+		UpdatedAt: r.UpdatedAt.Format(time.RFC3339),
+	}
+	for _, d := range c.Weekdays {
+		item.Conditions.Weekdays = append(item.Conditions.Weekdays, strings.ToLower(d.String()))
+	}
+	if item.Actions.Tags == nil {
+		item.Actions.Tags = []string{}
+	}
+	return item
+}
+
+type RuleApplyRequest struct {
+	RuleIds []string `json:"rule_ids"` // empty runs every enabled rule
+	DryRun  bool     `json:"dry_run"`
+}
+
+type RuleChangeItem struct {
+	TransactionId string   `json:"transaction_id"`
+	OccurredAt    string   `json:"occurred_at"`
+	Amount        float64  `json:"amount"`
+	Currency      string   `json:"currency"`
+	CategoryType  string   `json:"category_type"` // expense or income
+	OldCategoryId string   `json:"old_category_id"`
+	CategoryId    string   `json:"category_id"`
+	OldNote       string   `json:"old_note"`
+	Note          string   `json:"note"`
+	AddedTags     []string `json:"added_tags"`
+	Tags          []string `json:"tags"`
+	RuleIds       []string `json:"rule_ids"`
+}
+
+type RuleApplyResponse struct {
+	DryRun  bool             `json:"dry_run"`
+	Matched int              `json:"matched"`
+	Changed int              `json:"changed"`
+	Skipped int              `json:"skipped"` // matched, but reconciled or split
+	Changes []RuleChangeItem `json:"changes"`
+}
+
+func RuleApplyReportToHttp(report budget.RuleApplyReport) RuleApplyResponse {
+	resp := RuleApplyResponse{
+		DryRun:  report.DryRun,
+		Matched: report.Matched,
+		Changed: report.Changed,
+		Skipped: report.Skipped,
+		Changes: make([]RuleChangeItem, 0, len(report.Changes)),
+	}
+	for _, c := range report.Changes {
+		item := RuleChangeItem{
+			TransactionId: c.TransactionId,
+			OccurredAt:    c.OccurredAt.Format(time.RFC3339),
+			Amount:        c.Amount,
+			Currency:      c.Currency,
+			CategoryType:  categoryTypeToHttp(c.CategoryType),
+			OldCategoryId: c.OldCategoryId,
+			CategoryId:    c.CategoryId,
+			OldNote:       c.OldNote,
+			Note:          c.Note,
+			AddedTags:     c.AddedTags,
+			Tags:          c.Tags,
+			RuleIds:       c.RuleIds,
+		}
+		if item.AddedTags == nil {
+			item.AddedTags = []string{}
+		}
+		if item.Tags == nil {
+			item.Tags = []string{}
+		}
+		resp.Changes = append(resp.Changes, item)
+	}
+	return resp
+}
+
+type CategorySuggestionItem struct {
+	CategoryId   string  `json:"category_id"`
+	CategoryName string  `json:"category_name"`
+	Confidence   float64 `json:"confidence"` // 0 to 1
+	Examples     int     `json:"examples"`   // transactions of the category learned from
+}
+
+type CategorySuggestionResponse struct {
+	Suggestions []CategorySuggestionItem `json:"suggestions"`
+}
+
+func CategorySuggestionsToHttp(suggestions []budget.CategorySuggestion) []CategorySuggestionItem {
+	items := make([]CategorySuggestionItem, 0, len(suggestions))
+	for _, s := range suggestions {
+		items = append(items, CategorySuggestionItem{
+			CategoryId:   s.CategoryId,
+			CategoryName: s.CategoryName,
+			Confidence:   math.Round(s.Confidence*10000) / 10000,
+			Examples:     s.Examples,
+		})
+	}
+	return items
+}
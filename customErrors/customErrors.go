@@ -11,6 +11,8 @@ const (
 	ErrAccessDenied = "ACCESS DENIED"
 	ErrConflict     = "CONFLICT"
 	ErrInternal     = "INTERNAL"
+
+	ErrPendingDeletion = "PENDING DELETION"
 )
 
 type ErrorResponse struct {
@@ -0,0 +1,14 @@
+CREATE TABLE IF NOT EXISTS `email_verification` (
+    `id` CHAR(36) NOT NULL PRIMARY KEY,
+    `user_id` CHAR(36) NOT NULL,
+    `email` VARCHAR(255) NOT NULL,
+    `token` VARCHAR(255) NOT NULL UNIQUE,
+    `created_at` DATETIME NOT NULL,
+    `expire_at` DATETIME NOT NULL
+);
+
+ALTER TABLE `email_verification`
+ADD CONSTRAINT fk_user_email_verification
+FOREIGN KEY (`user_id`)
+REFERENCES `user` (`id`)
+ON DELETE CASCADE;
@@ -0,0 +1,6 @@
+ALTER TABLE `user`
+ADD COLUMN `deletion_requested_at` DATETIME NULL,
+ADD COLUMN `deletion_scheduled_at` DATETIME NULL,
+ADD COLUMN `deletion_reason` VARCHAR(300) NULL;
+
+CREATE INDEX idx_user_deletion_scheduled_at ON `user`(`deletion_scheduled_at`);
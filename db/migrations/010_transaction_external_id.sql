@@ -0,0 +1,4 @@
+ALTER TABLE `transaction`
+ADD COLUMN `external_id` VARCHAR(255) NULL;
+
+CREATE UNIQUE INDEX idx_transaction_external_id ON `transaction`(`created_by`, `external_id`);
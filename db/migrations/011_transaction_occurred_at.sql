@@ -0,0 +1,9 @@
+ALTER TABLE `transaction`
+ADD COLUMN `occurred_at` DATETIME NULL;
+
+UPDATE `transaction` SET `occurred_at` = `created_at` WHERE `occurred_at` IS NULL;
+
+ALTER TABLE `transaction`
+MODIFY COLUMN `occurred_at` DATETIME NOT NULL;
+
+CREATE INDEX idx_transaction_occurred_at ON `transaction`(`created_by`, `occurred_at`);
@@ -0,0 +1,24 @@
+CREATE TABLE IF NOT EXISTS `recurring_transaction` (
+    `id` CHAR(36) NOT NULL PRIMARY KEY,
+    `category_id` CHAR(36) NOT NULL,
+    `category_type` ENUM('+', '-') NOT NULL,
+    `amount` DECIMAL(20, 2) NOT NULL,
+    `currency` VARCHAR(255) NOT NULL,
+    `note` VARCHAR(1000),
+    `rule` VARCHAR(255) NOT NULL,
+    `start_at` DATETIME NOT NULL,
+    `end_at` DATETIME NULL,
+    `next_run_at` DATETIME NULL,
+    `paused` BOOLEAN NOT NULL DEFAULT FALSE,
+    `created_at` DATETIME NOT NULL,
+    `updated_at` DATETIME NOT NULL,
+    `created_by` CHAR(36) NOT NULL
+);
+
+ALTER TABLE `recurring_transaction`
+ADD CONSTRAINT fk_created_by_recurring_transaction
+FOREIGN KEY (`created_by`)
+REFERENCES `user` (`id`)
+ON DELETE CASCADE;
+
+CREATE INDEX idx_recurring_transaction_next_run ON `recurring_transaction`(`paused`, `next_run_at`);
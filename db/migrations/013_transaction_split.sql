@@ -0,0 +1,23 @@
+CREATE TABLE IF NOT EXISTS `transaction_split` (
+    `id` CHAR(36) NOT NULL PRIMARY KEY,
+    `transaction_id` CHAR(36) NOT NULL,
+    `position` INT NOT NULL,
+    `category_id` CHAR(36) NOT NULL,
+    `amount` DECIMAL(20, 2) NOT NULL,
+    `note` VARCHAR(1000),
+    `created_by` CHAR(36) NOT NULL
+);
+
+ALTER TABLE `transaction_split`
+ADD CONSTRAINT fk_transaction_split_transaction
+FOREIGN KEY (`transaction_id`)
+REFERENCES `transaction` (`id`)
+ON DELETE CASCADE;
+
+ALTER TABLE `transaction_split`
+ADD CONSTRAINT fk_created_by_transaction_split
+FOREIGN KEY (`created_by`)
+REFERENCES `user` (`id`)
+ON DELETE CASCADE;
+
+CREATE INDEX idx_transaction_split_category ON `transaction_split`(`created_by`, `category_id`);
@@ -0,0 +1,35 @@
+CREATE TABLE IF NOT EXISTS `wallet` (
+    `id` CHAR(36) NOT NULL PRIMARY KEY,
+    `name` VARCHAR(255) NOT NULL,
+    `type` ENUM('cash', 'checking', 'credit_card', 'savings') NOT NULL,
+    `currency` VARCHAR(255) NOT NULL,
+    `opening_balance` DECIMAL(20, 2) NOT NULL DEFAULT 0,
+    `created_at` DATETIME NOT NULL,
+    `updated_at` DATETIME NOT NULL,
+    `created_by` CHAR(36) NOT NULL
+);
+
+ALTER TABLE `wallet`
+ADD CONSTRAINT fk_created_by_wallet
+FOREIGN KEY (`created_by`)
+REFERENCES `user` (`id`)
+ON DELETE CASCADE;
+
+CREATE UNIQUE INDEX idx_wallet_name ON `wallet`(`created_by`, `name`);
+
+ALTER TABLE `transaction`
+MODIFY COLUMN `category_type` ENUM('+', '-', '>') NOT NULL;
+
+ALTER TABLE `transaction`
+ADD COLUMN `wallet_id` CHAR(36) NULL,
+ADD COLUMN `to_wallet_id` CHAR(36) NULL;
+
+ALTER TABLE `transaction`
+ADD CONSTRAINT fk_transaction_wallet
+FOREIGN KEY (`wallet_id`)
+REFERENCES `wallet` (`id`);
+
+ALTER TABLE `transaction`
+ADD CONSTRAINT fk_transaction_to_wallet
+FOREIGN KEY (`to_wallet_id`)
+REFERENCES `wallet` (`id`);
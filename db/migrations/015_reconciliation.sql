@@ -0,0 +1,28 @@
+ALTER TABLE `transaction`
+ADD COLUMN `cleared` BOOLEAN NOT NULL DEFAULT FALSE,
+ADD COLUMN `reconciled_at` DATETIME NULL;
+
+CREATE TABLE IF NOT EXISTS `reconciliation` (
+    `id` CHAR(36) NOT NULL PRIMARY KEY,
+    `wallet_id` CHAR(36) NOT NULL,
+    `statement_balance` DECIMAL(20, 2) NOT NULL,
+    `statement_date` DATETIME NOT NULL,
+    `finished_at` DATETIME NULL,
+    `created_at` DATETIME NOT NULL,
+    `updated_at` DATETIME NOT NULL,
+    `created_by` CHAR(36) NOT NULL
+);
+
+ALTER TABLE `reconciliation`
+ADD CONSTRAINT fk_reconciliation_wallet
+FOREIGN KEY (`wallet_id`)
+REFERENCES `wallet` (`id`)
+ON DELETE CASCADE;
+
+ALTER TABLE `reconciliation`
+ADD CONSTRAINT fk_created_by_reconciliation
+FOREIGN KEY (`created_by`)
+REFERENCES `user` (`id`)
+ON DELETE CASCADE;
+
+CREATE INDEX idx_reconciliation_wallet ON `reconciliation`(`created_by`, `wallet_id`, `finished_at`);
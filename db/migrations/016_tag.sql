@@ -0,0 +1,36 @@
+CREATE TABLE IF NOT EXISTS `tag` (
+    `id` CHAR(36) NOT NULL PRIMARY KEY,
+    `name` VARCHAR(50) NOT NULL,
+    `created_at` DATETIME NOT NULL,
+    `updated_at` DATETIME NOT NULL,
+    `created_by` CHAR(36) NOT NULL
+);
+
+ALTER TABLE `tag`
+ADD CONSTRAINT fk_created_by_tag
+FOREIGN KEY (`created_by`)
+REFERENCES `user` (`id`)
+ON DELETE CASCADE;
+
+CREATE UNIQUE INDEX idx_tag_name ON `tag`(`created_by`, `name`);
+
+CREATE TABLE IF NOT EXISTS `transaction_tag` (
+    `transaction_id` CHAR(36) NOT NULL,
+    `tag_id` CHAR(36) NOT NULL,
+    `created_by` CHAR(36) NOT NULL,
+    PRIMARY KEY (`transaction_id`, `tag_id`)
+);
+
+ALTER TABLE `transaction_tag`
+ADD CONSTRAINT fk_transaction_tag_transaction
+FOREIGN KEY (`transaction_id`)
+REFERENCES `transaction` (`id`)
+ON DELETE CASCADE;
+
+ALTER TABLE `transaction_tag`
+ADD CONSTRAINT fk_transaction_tag_tag
+FOREIGN KEY (`tag_id`)
+REFERENCES `tag` (`id`)
+ON DELETE CASCADE;
+
+CREATE INDEX idx_transaction_tag_tag ON `transaction_tag`(`tag_id`);
@@ -0,0 +1,17 @@
+ALTER TABLE `expense_category`
+ADD COLUMN `parent_id` CHAR(36) NULL;
+
+ALTER TABLE `expense_category`
+ADD CONSTRAINT fk_expense_category_parent
+FOREIGN KEY (`parent_id`)
+REFERENCES `expense_category` (`id`)
+ON DELETE SET NULL;
+
+ALTER TABLE `income_category`
+ADD COLUMN `parent_id` CHAR(36) NULL;
+
+ALTER TABLE `income_category`
+ADD CONSTRAINT fk_income_category_parent
+FOREIGN KEY (`parent_id`)
+REFERENCES `income_category` (`id`)
+ON DELETE SET NULL;
@@ -0,0 +1,5 @@
+ALTER TABLE `expense_category`
+ADD COLUMN `archived_at` DATETIME NULL;
+
+ALTER TABLE `income_category`
+ADD COLUMN `archived_at` DATETIME NULL;
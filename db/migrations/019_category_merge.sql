@@ -0,0 +1,39 @@
+CREATE TABLE IF NOT EXISTS `category_merge` (
+    `id` CHAR(36) NOT NULL PRIMARY KEY,
+    `category_type` ENUM('+', '-') NOT NULL,
+    `target_id` CHAR(36) NOT NULL,
+    `target_name` VARCHAR(255) NOT NULL,
+    `limit_strategy` VARCHAR(10) NOT NULL,
+    `note_strategy` VARCHAR(10) NOT NULL,
+    `old_limit` DECIMAL(20, 2) NOT NULL,
+    `new_limit` DECIMAL(20, 2) NOT NULL,
+    `old_note` VARCHAR(1000) NOT NULL DEFAULT "",
+    `new_note` VARCHAR(1000) NOT NULL DEFAULT "",
+    `moved_transactions` INT NOT NULL,
+    `created_at` DATETIME NOT NULL,
+    `created_by` CHAR(36) NOT NULL
+);
+
+ALTER TABLE `category_merge`
+ADD CONSTRAINT fk_created_by_category_merge
+FOREIGN KEY (`created_by`)
+REFERENCES `user` (`id`)
+ON DELETE CASCADE;
+
+CREATE INDEX idx_category_merge_created ON `category_merge`(`created_by`, `category_type`, `created_at`);
+
+CREATE TABLE IF NOT EXISTS `category_merge_source` (
+    `merge_id` CHAR(36) NOT NULL,
+    `category_id` CHAR(36) NOT NULL,
+    `name` VARCHAR(255) NOT NULL,
+    `limit_amount` DECIMAL(20, 2) NOT NULL,
+    `note` VARCHAR(1000) NOT NULL DEFAULT "",
+    `position` INT NOT NULL,
+    PRIMARY KEY (`merge_id`, `category_id`)
+);
+
+ALTER TABLE `category_merge_source`
+ADD CONSTRAINT fk_category_merge_source_merge
+FOREIGN KEY (`merge_id`)
+REFERENCES `category_merge` (`id`)
+ON DELETE CASCADE;
@@ -0,0 +1,44 @@
+CREATE TABLE IF NOT EXISTS `savings_goal` (
+    `id` CHAR(36) NOT NULL PRIMARY KEY,
+    `name` VARCHAR(255) NOT NULL,
+    `target_amount` DECIMAL(20, 2) NOT NULL,
+    `currency` VARCHAR(255) NOT NULL,
+    `target_date` DATETIME NOT NULL,
+    `wallet_id` CHAR(36) NULL,
+    `note` VARCHAR(1000) NOT NULL DEFAULT "",
+    `created_at` DATETIME NOT NULL,
+    `updated_at` DATETIME NOT NULL,
+    `created_by` CHAR(36) NOT NULL
+);
+
+ALTER TABLE `savings_goal`
+ADD CONSTRAINT fk_created_by_savings_goal
+FOREIGN KEY (`created_by`)
+REFERENCES `user` (`id`)
+ON DELETE CASCADE;
+
+ALTER TABLE `savings_goal`
+ADD CONSTRAINT fk_savings_goal_wallet
+FOREIGN KEY (`wallet_id`)
+REFERENCES `wallet` (`id`)
+ON DELETE SET NULL;
+
+CREATE UNIQUE INDEX idx_savings_goal_name ON `savings_goal`(`created_by`, `name`);
+
+CREATE TABLE IF NOT EXISTS `savings_contribution` (
+    `id` CHAR(36) NOT NULL PRIMARY KEY,
+    `goal_id` CHAR(36) NOT NULL,
+    `amount` DECIMAL(20, 2) NOT NULL,
+    `occurred_at` DATETIME NOT NULL,
+    `note` VARCHAR(1000) NOT NULL DEFAULT "",
+    `created_at` DATETIME NOT NULL,
+    `created_by` CHAR(36) NOT NULL
+);
+
+ALTER TABLE `savings_contribution`
+ADD CONSTRAINT fk_savings_contribution_goal
+FOREIGN KEY (`goal_id`)
+REFERENCES `savings_goal` (`id`)
+ON DELETE CASCADE;
+
+CREATE INDEX idx_savings_contribution_goal ON `savings_contribution`(`goal_id`, `occurred_at`);
@@ -0,0 +1,68 @@
+CREATE TABLE IF NOT EXISTS `debt` (
+    `id` CHAR(36) NOT NULL PRIMARY KEY,
+    `name` VARCHAR(255) NOT NULL,
+    `principal` DECIMAL(20, 2) NOT NULL,
+    `interest_rate` DECIMAL(7, 4) NOT NULL,
+    `term_months` INT NOT NULL,
+    `first_payment_at` DATETIME NOT NULL,
+    `currency` VARCHAR(255) NOT NULL,
+    `wallet_id` CHAR(36) NULL,
+    `category_id` CHAR(36) NULL,
+    `interest_category_id` CHAR(36) NULL,
+    `note` VARCHAR(1000) NOT NULL DEFAULT "",
+    `created_at` DATETIME NOT NULL,
+    `updated_at` DATETIME NOT NULL,
+    `created_by` CHAR(36) NOT NULL
+);
+
+ALTER TABLE `debt`
+ADD CONSTRAINT fk_created_by_debt
+FOREIGN KEY (`created_by`)
+REFERENCES `user` (`id`)
+ON DELETE CASCADE;
+
+ALTER TABLE `debt`
+ADD CONSTRAINT fk_debt_wallet
+FOREIGN KEY (`wallet_id`)
+REFERENCES `wallet` (`id`)
+ON DELETE SET NULL;
+
+ALTER TABLE `debt`
+ADD CONSTRAINT fk_debt_category
+FOREIGN KEY (`category_id`)
+REFERENCES `expense_category` (`id`)
+ON DELETE SET NULL;
+
+ALTER TABLE `debt`
+ADD CONSTRAINT fk_debt_interest_category
+FOREIGN KEY (`interest_category_id`)
+REFERENCES `expense_category` (`id`)
+ON DELETE SET NULL;
+
+CREATE UNIQUE INDEX idx_debt_name ON `debt`(`created_by`, `name`);
+
+CREATE TABLE IF NOT EXISTS `debt_payment` (
+    `id` CHAR(36) NOT NULL PRIMARY KEY,
+    `debt_id` CHAR(36) NOT NULL,
+    `transaction_id` CHAR(36) NULL,
+    `amount` DECIMAL(20, 2) NOT NULL,
+    `principal` DECIMAL(20, 2) NOT NULL,
+    `interest` DECIMAL(20, 2) NOT NULL,
+    `occurred_at` DATETIME NOT NULL,
+    `created_at` DATETIME NOT NULL,
+    `created_by` CHAR(36) NOT NULL
+);
+
+ALTER TABLE `debt_payment`
+ADD CONSTRAINT fk_debt_payment_debt
+FOREIGN KEY (`debt_id`)
+REFERENCES `debt` (`id`)
+ON DELETE CASCADE;
+
+ALTER TABLE `debt_payment`
+ADD CONSTRAINT fk_debt_payment_transaction
+FOREIGN KEY (`transaction_id`)
+REFERENCES `transaction` (`id`)
+ON DELETE SET NULL;
+
+CREATE INDEX idx_debt_payment_debt ON `debt_payment`(`debt_id`, `occurred_at`);
@@ -0,0 +1,41 @@
+CREATE TABLE IF NOT EXISTS `payee` (
+    `id` CHAR(36) NOT NULL PRIMARY KEY,
+    `name` VARCHAR(255) NOT NULL,
+    `default_category_id` CHAR(36) NULL,
+    `default_category_type` VARCHAR(1) NULL,
+    `created_at` DATETIME NOT NULL,
+    `updated_at` DATETIME NOT NULL,
+    `created_by` CHAR(36) NOT NULL
+);
+
+ALTER TABLE `payee`
+ADD CONSTRAINT fk_created_by_payee
+FOREIGN KEY (`created_by`)
+REFERENCES `user` (`id`)
+ON DELETE CASCADE;
+
+CREATE UNIQUE INDEX idx_payee_name ON `payee`(`created_by`, `name`);
+
+CREATE TABLE IF NOT EXISTS `payee_alias` (
+    `payee_id` CHAR(36) NOT NULL,
+    `position` INT NOT NULL,
+    `pattern` VARCHAR(255) NOT NULL,
+    `match_type` VARCHAR(20) NOT NULL,
+    `created_by` CHAR(36) NOT NULL,
+    PRIMARY KEY (`payee_id`, `position`)
+);
+
+ALTER TABLE `payee_alias`
+ADD CONSTRAINT fk_payee_alias_payee
+FOREIGN KEY (`payee_id`)
+REFERENCES `payee` (`id`)
+ON DELETE CASCADE;
+
+ALTER TABLE `transaction`
+ADD COLUMN `payee_id` CHAR(36) NULL;
+
+ALTER TABLE `transaction`
+ADD CONSTRAINT fk_transaction_payee
+FOREIGN KEY (`payee_id`)
+REFERENCES `payee` (`id`)
+ON DELETE SET NULL;
@@ -0,0 +1,27 @@
+CREATE TABLE IF NOT EXISTS `transaction_rule` (
+    `id` CHAR(36) NOT NULL PRIMARY KEY,
+    `name` VARCHAR(255) NOT NULL,
+    `priority` INT NOT NULL DEFAULT 0,
+    `enabled` BOOLEAN NOT NULL DEFAULT TRUE,
+    `category_type` VARCHAR(1) NULL,
+    `note_pattern` VARCHAR(255) NOT NULL DEFAULT "",
+    `payee_pattern` VARCHAR(255) NOT NULL DEFAULT "",
+    `amount_min` DECIMAL(20, 2) NULL,
+    `amount_max` DECIMAL(20, 2) NULL,
+    `currency` VARCHAR(255) NOT NULL DEFAULT "",
+    `weekdays` INT NOT NULL DEFAULT 0,
+    `set_category_id` CHAR(36) NULL,
+    `set_note` VARCHAR(1000) NOT NULL DEFAULT "",
+    `set_tags` VARCHAR(1000) NOT NULL DEFAULT "",
+    `created_at` DATETIME NOT NULL,
+    `updated_at` DATETIME NOT NULL,
+    `created_by` CHAR(36) NOT NULL
+);
+
+ALTER TABLE `transaction_rule`
+ADD CONSTRAINT fk_created_by_transaction_rule
+FOREIGN KEY (`created_by`)
+REFERENCES `user` (`id`)
+ON DELETE CASCADE;
+
+CREATE INDEX idx_transaction_rule_priority ON `transaction_rule`(`created_by`, `priority`);
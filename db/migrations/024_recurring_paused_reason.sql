@@ -0,0 +1,2 @@
+ALTER TABLE `recurring_transaction`
+ADD COLUMN `paused_reason` VARCHAR(255) NOT NULL DEFAULT "";
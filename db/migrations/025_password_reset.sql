@@ -0,0 +1,13 @@
+CREATE TABLE IF NOT EXISTS `password_reset` (
+    `id` CHAR(36) NOT NULL PRIMARY KEY,
+    `user_id` CHAR(36) NOT NULL,
+    `token` VARCHAR(255) NOT NULL UNIQUE,
+    `created_at` DATETIME NOT NULL,
+    `expire_at` DATETIME NOT NULL
+);
+
+ALTER TABLE `password_reset`
+ADD CONSTRAINT fk_user_password_reset
+FOREIGN KEY (`user_id`)
+REFERENCES `user` (`id`)
+ON DELETE CASCADE;
@@ -16,12 +16,13 @@ const (
 )
 
 type User struct {
-	ID             string
-	UserName       string
-	FullName       string
-	PasswordHashed string
-	Email          string
-	PendingEmail   string
+	ID                  string
+	UserName            string
+	FullName            string
+	PasswordHashed      string
+	Email               string
+	PendingEmail        string
+	DeletionScheduledAt time.Time // zero unless the account is pending deletion
 }
 
 type NewUser struct {
@@ -31,62 +32,142 @@ type NewUser struct {
 	Email         string
 }
 
+type ChangePassword struct {
+	CurrentPassword string
+	NewPassword     string
+}
+
+type ResetPassword struct {
+	Token       string
+	NewPassword string
+}
+
 type DeleteUser struct {
 	Password string
 	Reason   string
 }
 
+type PendingDeletion struct {
+	UserID      string
+	UserName    string
+	FullName    string
+	Email       string
+	Reason      string
+	ScheduledAt time.Time
+}
+
+type UpdateUser struct {
+	UserName string
+	FullName string
+	Email    string
+}
+
+type EmailVerification struct {
+	ID        string
+	UserID    string
+	Email     string
+	Token     string
+	CreatedAt time.Time
+	ExpireAt  time.Time
+}
+
+type PasswordReset struct {
+	ID        string
+	UserID    string
+	Token     string
+	CreatedAt time.Time
+	ExpireAt  time.Time
+}
+
+var usernameRegex = regexp.MustCompile(`^[a-z0-9_]{1,30}$`)
+var emailRegex = regexp.MustCompile(`^[a-zA-Z0-9](\.?[a-zA-Z0-9_%+-])*@[a-zA-Z0-9-]+(\.[a-zA-Z0-9-]+)*\.[a-zA-Z]{2,}$`)
+
 func (newUser NewUser) ValidateUserFields() error {
-	usernameRegex := regexp.MustCompile(`^[a-z0-9_]{1,30}$`)
-	emailRegex := regexp.MustCompile(`^[a-zA-Z0-9](\.?[a-zA-Z0-9_%+-])*@[a-zA-Z0-9-]+(\.[a-zA-Z0-9-]+)*\.[a-zA-Z]{2,}$`)
-	if newUser.UserName == "" {
+	if err := ValidateUserName(newUser.UserName); err != nil {
+		return err
+	}
+	if err := ValidateFullName(newUser.FullName); err != nil {
+		return err
+	}
+	if err := ValidateEmail(newUser.Email); err != nil {
+		return err
+	}
+	if err := ValidatePassword(newUser.PasswordPlain, newUser.UserName, newUser.Email, newUser.FullName); err != nil {
+		return err
+	}
+	return nil
+}
+
+// ValidateUpdateFields runs the registration rules on every field that is
+// being changed; empty fields are left untouched.
+func (updateUser UpdateUser) ValidateUpdateFields() error {
+	if updateUser.UserName == "" && updateUser.FullName == "" && updateUser.Email == "" {
+		return appErrors.ErrorResponse{
+			Code:    appErrors.ErrInvalidInput,
+			Message: "Nothing to update!",
+		}
+	}
+	if updateUser.UserName != "" {
+		if err := ValidateUserName(updateUser.UserName); err != nil {
+			return err
+		}
+	}
+	if err := ValidateFullName(updateUser.FullName); err != nil {
+		return err
+	}
+	if updateUser.Email != "" {
+		if err := ValidateEmail(updateUser.Email); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func ValidateUserName(userName string) error {
+	if userName == "" {
 		return appErrors.ErrorResponse{
 			Code:    appErrors.ErrInvalidInput,
 			Message: "Username cannot be empty!",
 		}
 	}
-	if !usernameRegex.MatchString(newUser.UserName) {
+	if !usernameRegex.MatchString(userName) {
 		return appErrors.ErrorResponse{
 			Code:    appErrors.ErrInvalidInput,
 			Message: "Username contains wrong characters, example username: john_doe",
 		}
 	}
-	if len(newUser.FullName) > MAX_LENGTH_FULLNAME {
+	return nil
+}
+
+func ValidateFullName(fullName string) error {
+	if len(fullName) > MAX_LENGTH_FULLNAME {
 		return appErrors.ErrorResponse{
 			Code:    appErrors.ErrInvalidInput,
 			Message: fmt.Sprintf("Username so long, maximum length is %d", MAX_LENGTH_USERNAME),
 		}
 	}
-	if newUser.Email == "" {
+	return nil
+}
+
+func ValidateEmail(email string) error {
+	if email == "" {
 		return appErrors.ErrorResponse{
 			Code:    appErrors.ErrInvalidInput,
 			Message: "Email cannot be empty!",
 		}
 	}
-	if !emailRegex.MatchString(newUser.Email) {
+	if !emailRegex.MatchString(email) {
 		return appErrors.ErrorResponse{
 			Code:    appErrors.ErrInternal,
 			Message: "Invalid email format, example valid email: john.doe@gmail.com",
 		}
 	}
-	if len(newUser.Email) > MAX_LENGTH_EMAIL {
+	if len(email) > MAX_LENGTH_EMAIL {
 		return appErrors.ErrorResponse{
 			Code:    appErrors.ErrInvalidInput,
 			Message: fmt.Sprintf("Email so long, maximum length is %d", MAX_LENGTH_EMAIL),
 		}
 	}
-	if newUser.PasswordPlain == "" {
-		return appErrors.ErrorResponse{
-			Code:    appErrors.ErrInvalidInput,
-			Message: "Password cannot be empty!",
-		}
-	}
-	if len(newUser.PasswordPlain) > MAX_PASSWORD_LENGTH {
-		return appErrors.ErrorResponse{
-			Code:    appErrors.ErrInvalidInput,
-			Message: fmt.Sprintf("Password so long, maximum length is %d", MAX_PASSWORD_LENGTH),
-		}
-	}
 	return nil
 }
 
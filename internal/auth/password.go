@@ -0,0 +1,428 @@
+package auth
+
+import (
+	"bufio"
+	"crypto/sha1"
+	_ "embed"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"unicode"
+
+	appErrors "github.com/fatali-fataliyev/budget_tracker/customErrors"
+)
+
+const (
+	DEFAULT_PASSWORD_MIN_LENGTH   = 8
+	DEFAULT_PASSWORD_MIN_STRENGTH = 2
+	MAX_PASSWORD_STRENGTH         = 4
+	BREACHED_HASH_PREFIX_LENGTH   = 5
+)
+
+// breached_passwords.txt uses the "SHA1:COUNT" layout of the public
+// breached-password dumps, so a full downloaded list can be dropped in
+// through BREACHED_PASSWORDS_FILE without conversion.
+//
+//go:embed breached_passwords.txt
+var embeddedBreachedPasswords string
+
+type PasswordPolicy struct {
+	MinLength     int
+	RequireUpper  bool
+	RequireLower  bool
+	RequireDigit  bool
+	RequireSymbol bool
+	MinStrength   int // 0 (too guessable) ... 4 (very unguessable)
+}
+
+// BreachedPasswords is a k-anonymity index: hashes are bucketed by their
+// first 5 hex characters and only the remaining suffix is compared, the
+// same shape as a range query against a remote breach API.
+type BreachedPasswords struct {
+	mu       sync.RWMutex
+	prefixes map[string]map[string]struct{}
+}
+
+var Policy = DefaultPasswordPolicy()
+var Breached = NewBreachedPasswords()
+
+func init() {
+	if _, err := Breached.Load(strings.NewReader(embeddedBreachedPasswords)); err != nil {
+		panic(fmt.Sprintf("failed to load embedded breached password list: %v", err))
+	}
+}
+
+func DefaultPasswordPolicy() PasswordPolicy {
+	return PasswordPolicy{
+		MinLength:    DEFAULT_PASSWORD_MIN_LENGTH,
+		RequireUpper: false,
+		RequireLower: true,
+		RequireDigit: true,
+		MinStrength:  DEFAULT_PASSWORD_MIN_STRENGTH,
+	}
+}
+
+// InitPasswordPolicy reads the PASSWORD_* environment variables over the
+// defaults and loads the optional local breached-password list.
+func InitPasswordPolicy() error {
+	policy := DefaultPasswordPolicy()
+
+	if v := os.Getenv("PASSWORD_MIN_LENGTH"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 || n > MAX_PASSWORD_LENGTH {
+			return fmt.Errorf("invalid PASSWORD_MIN_LENGTH: %q", v)
+		}
+		policy.MinLength = n
+	}
+	if v := os.Getenv("PASSWORD_MIN_STRENGTH"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 || n > MAX_PASSWORD_STRENGTH {
+			return fmt.Errorf("invalid PASSWORD_MIN_STRENGTH: %q", v)
+		}
+		policy.MinStrength = n
+	}
+
+	flags := map[string]*bool{
+		"PASSWORD_REQUIRE_UPPER":  &policy.RequireUpper,
+		"PASSWORD_REQUIRE_LOWER":  &policy.RequireLower,
+		"PASSWORD_REQUIRE_DIGIT":  &policy.RequireDigit,
+		"PASSWORD_REQUIRE_SYMBOL": &policy.RequireSymbol,
+	}
+	for name, field := range flags {
+		v := os.Getenv(name)
+		if v == "" {
+			continue
+		}
+		b, err := strconv.ParseBool(v)
+		if err != nil {
+			return fmt.Errorf("invalid %s: %q", name, v)
+		}
+		*field = b
+	}
+
+	if path := os.Getenv("BREACHED_PASSWORDS_FILE"); path != "" {
+		file, err := os.Open(path)
+		if err != nil {
+			return fmt.Errorf("failed to open breached password list: %w", err)
+		}
+		defer file.Close()
+		if _, err := Breached.Load(file); err != nil {
+			return fmt.Errorf("failed to load breached password list: %w", err)
+		}
+	}
+
+	Policy = policy
+	return nil
+}
+
+func NewBreachedPasswords() *BreachedPasswords {
+	return &BreachedPasswords{prefixes: make(map[string]map[string]struct{})}
+}
+
+// Load adds "SHA1[:COUNT]" lines to the index and returns how many hashes were read.
+func (b *BreachedPasswords) Load(r io.Reader) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	count := 0
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		hash, _, _ := strings.Cut(line, ":")
+		hash = strings.ToUpper(hash)
+		if len(hash) != sha1.Size*2 {
+			return count, fmt.Errorf("invalid SHA-1 hash %q", hash)
+		}
+		prefix, suffix := hash[:BREACHED_HASH_PREFIX_LENGTH], hash[BREACHED_HASH_PREFIX_LENGTH:]
+		bucket, ok := b.prefixes[prefix]
+		if !ok {
+			bucket = make(map[string]struct{})
+			b.prefixes[prefix] = bucket
+		}
+		bucket[suffix] = struct{}{}
+		count++
+	}
+	return count, scanner.Err()
+}
+
+func (b *BreachedPasswords) Contains(password string) bool {
+	sum := sha1.Sum([]byte(password))
+	hash := strings.ToUpper(hex.EncodeToString(sum[:]))
+
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	bucket, ok := b.prefixes[hash[:BREACHED_HASH_PREFIX_LENGTH]]
+	if !ok {
+		return false
+	}
+	_, found := bucket[hash[BREACHED_HASH_PREFIX_LENGTH:]]
+	return found
+}
+
+// ValidatePassword applies the active policy. userInputs (username, email,
+// full name) are treated as dictionary words by the strength estimate.
+func ValidatePassword(password string, userInputs ...string) error {
+	return Policy.Validate(password, userInputs...)
+}
+
+func (p PasswordPolicy) Validate(password string, userInputs ...string) error {
+	if password == "" {
+		return appErrors.ErrorResponse{
+			Code:    appErrors.ErrInvalidInput,
+			Message: "Password cannot be empty!",
+		}
+	}
+	if len(password) > MAX_PASSWORD_LENGTH {
+		return appErrors.ErrorResponse{
+			Code:    appErrors.ErrInvalidInput,
+			Message: fmt.Sprintf("Password so long, maximum length is %d", MAX_PASSWORD_LENGTH),
+		}
+	}
+	if len([]rune(password)) < p.MinLength {
+		return appErrors.ErrorResponse{
+			Code:    appErrors.ErrInvalidInput,
+			Message: fmt.Sprintf("Password so short, minimum length is %d", p.MinLength),
+		}
+	}
+
+	var hasUpper, hasLower, hasDigit, hasSymbol bool
+	for _, r := range password {
+		switch {
+		case unicode.IsUpper(r):
+			hasUpper = true
+		case unicode.IsLower(r):
+			hasLower = true
+		case unicode.IsDigit(r):
+			hasDigit = true
+		default:
+			hasSymbol = true
+		}
+	}
+	if p.RequireUpper && !hasUpper {
+		return appErrors.ErrorResponse{
+			Code:    appErrors.ErrInvalidInput,
+			Message: "Password must contain at least one uppercase letter",
+		}
+	}
+	if p.RequireLower && !hasLower {
+		return appErrors.ErrorResponse{
+			Code:    appErrors.ErrInvalidInput,
+			Message: "Password must contain at least one lowercase letter",
+		}
+	}
+	if p.RequireDigit && !hasDigit {
+		return appErrors.ErrorResponse{
+			Code:    appErrors.ErrInvalidInput,
+			Message: "Password must contain at least one digit",
+		}
+	}
+	if p.RequireSymbol && !hasSymbol {
+		return appErrors.ErrorResponse{
+			Code:    appErrors.ErrInvalidInput,
+			Message: "Password must contain at least one symbol",
+		}
+	}
+
+	if Breached.Contains(password) {
+		return appErrors.ErrorResponse{
+			Code:    appErrors.ErrInvalidInput,
+			Message: "This password has appeared in a data breach, please choose another one",
+		}
+	}
+
+	if score := PasswordStrength(password, userInputs...); score < p.MinStrength {
+		return appErrors.ErrorResponse{
+			Code:    appErrors.ErrInvalidInput,
+			Message: "Password is too easy to guess, try a longer password or add unrelated words",
+		}
+	}
+
+	return nil
+}
+
+var commonPasswordWords = []string{
+	"password", "passwd", "qwerty", "azerty", "letmein", "welcome", "admin", "login",
+	"master", "secret", "dragon", "monkey", "football", "baseball", "soccer", "hockey",
+	"iloveyou", "love", "sunshine", "princess", "shadow", "superman", "batman", "trustno",
+	"money", "budget", "summer", "winter", "spring", "autumn", "hello", "freedom",
+}
+
+var keyboardRows = []string{
+	"`1234567890-=", "qwertyuiop[]\\", "asdfghjkl;'", "zxcvbnm,./",
+	"1qaz2wsx3edc4rfv5tgb6yhn7ujm8ik,9ol.0p;/", "qazwsxedcrfvtgbyhnujmikolp",
+}
+
+// PasswordStrength returns a zxcvbn-style score from 0 to 4. The password is
+// split into the cheapest sequence of guessable patterns (dictionary words,
+// repeats, sequences, keyboard walks, years) and brute-force runs, and the
+// estimated number of guesses is mapped onto the zxcvbn score thresholds.
+func PasswordStrength(password string, userInputs ...string) int {
+	lower := strings.ToLower(password)
+	runes := []rune(lower)
+	n := len(runes)
+	if n == 0 {
+		return 0
+	}
+
+	words := append([]string{}, commonPasswordWords...)
+	for _, input := range userInputs {
+		for _, part := range strings.FieldsFunc(strings.ToLower(input), func(r rune) bool {
+			return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+		}) {
+			if len(part) >= 3 {
+				words = append(words, part)
+			}
+		}
+	}
+
+	cardinality := bruteForceCardinality(password)
+
+	// best[i] is log10 of the guesses needed for the first i runes.
+	best := make([]float64, n+1)
+	for i := 1; i <= n; i++ {
+		best[i] = math.Inf(1)
+	}
+	for end := 1; end <= n; end++ {
+		for start := 0; start < end; start++ {
+			segment := string(runes[start:end])
+			cost := math.Log10(cardinality) * float64(end-start)
+			if c, ok := patternGuesses(segment, words); ok && c < cost {
+				cost = c
+			}
+			// Each extra pattern adds a little to the search space.
+			if start > 0 {
+				cost += math.Log10(2)
+			}
+			if best[start]+cost < best[end] {
+				best[end] = best[start] + cost
+			}
+		}
+	}
+
+	guessesLog := best[n]
+	switch {
+	case guessesLog < 3:
+		return 0
+	case guessesLog < 6:
+		return 1
+	case guessesLog < 8:
+		return 2
+	case guessesLog < 10:
+		return 3
+	default:
+		return 4
+	}
+}
+
+func bruteForceCardinality(password string) float64 {
+	var cardinality float64
+	var hasUpper, hasLower, hasDigit, hasSymbol, hasOther bool
+	for _, r := range password {
+		switch {
+		case r >= 'a' && r <= 'z':
+			hasLower = true
+		case r >= 'A' && r <= 'Z':
+			hasUpper = true
+		case r >= '0' && r <= '9':
+			hasDigit = true
+		case r < 128:
+			hasSymbol = true
+		default:
+			hasOther = true
+		}
+	}
+	if hasLower {
+		cardinality += 26
+	}
+	if hasUpper {
+		cardinality += 26
+	}
+	if hasDigit {
+		cardinality += 10
+	}
+	if hasSymbol {
+		cardinality += 33
+	}
+	if hasOther {
+		cardinality += 100
+	}
+	return cardinality
+}
+
+// patternGuesses returns log10 guesses when segment matches a known pattern.
+func patternGuesses(segment string, words []string) (float64, bool) {
+	runes := []rune(segment)
+	length := float64(len(runes))
+
+	for rank, word := range words {
+		if segment == word || reverseString(segment) == word || unleet(segment) == word {
+			return math.Log10(float64(rank+1) * 2), true
+		}
+	}
+
+	if len(runes) < 3 {
+		return 0, false
+	}
+
+	repeated := true
+	for _, r := range runes[1:] {
+		if r != runes[0] {
+			repeated = false
+			break
+		}
+	}
+	if repeated {
+		return math.Log10(bruteForceCardinality(segment) * length), true
+	}
+
+	if isSequence(runes) {
+		return math.Log10(26 * length), true
+	}
+
+	for _, row := range keyboardRows {
+		if strings.Contains(row, segment) || strings.Contains(row, reverseString(segment)) {
+			return math.Log10(float64(len(row)) * length), true
+		}
+	}
+
+	if len(runes) == 4 {
+		if year, err := strconv.Atoi(segment); err == nil && year >= 1900 && year <= 2099 {
+			return math.Log10(200), true
+		}
+	}
+
+	return 0, false
+}
+
+func isSequence(runes []rune) bool {
+	delta := runes[1] - runes[0]
+	if delta != 1 && delta != -1 {
+		return false
+	}
+	for i := 2; i < len(runes); i++ {
+		if runes[i]-runes[i-1] != delta {
+			return false
+		}
+	}
+	return true
+}
+
+func reverseString(s string) string {
+	runes := []rune(s)
+	for i, j := 0, len(runes)-1; i < j; i, j = i+1, j-1 {
+		runes[i], runes[j] = runes[j], runes[i]
+	}
+	return string(runes)
+}
+
+func unleet(s string) string {
+	return strings.NewReplacer("@", "a", "4", "a", "3", "e", "1", "i", "!", "i", "0", "o", "$", "s", "5", "s", "7", "t").Replace(s)
+}
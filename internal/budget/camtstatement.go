@@ -0,0 +1,245 @@
+package budget
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// camt.053 elements are matched without namespace, so every published
+// version of the message (001.02 to 001.13) is accepted.
+type camtDocument struct {
+	Statements []camtStatement `xml:"BkToCstmrStmt>Stmt"`
+}
+
+type camtStatement struct {
+	Id      string      `xml:"Id"`
+	IBAN    string      `xml:"Acct>Id>IBAN"`
+	OtherId string      `xml:"Acct>Id>Othr>Id"`
+	Ccy     string      `xml:"Acct>Ccy"`
+	Entries []camtEntry `xml:"Ntry"`
+}
+
+type camtAmount struct {
+	Ccy   string `xml:"Ccy,attr"`
+	Value string `xml:",chardata"`
+}
+
+// camtStatus is plain text before version 001.08 and <Cd> afterwards.
+type camtStatus struct {
+	Text string `xml:",chardata"`
+	Cd   string `xml:"Cd"`
+}
+
+type camtEntry struct {
+	NtryRef      string          `xml:"NtryRef"`
+	Amt          camtAmount      `xml:"Amt"`
+	CdtDbtInd    string          `xml:"CdtDbtInd"`
+	RvslInd      bool            `xml:"RvslInd"`
+	Sts          camtStatus      `xml:"Sts"`
+	BookgDt      string          `xml:"BookgDt>Dt"`
+	BookgDtTm    string          `xml:"BookgDt>DtTm"`
+	AcctSvcrRef  string          `xml:"AcctSvcrRef"`
+	AddtlNtryInf string          `xml:"AddtlNtryInf"`
+	TxDtls       []camtTxDetails `xml:"NtryDtls>TxDtls"`
+}
+
+type camtTxDetails struct {
+	AcctSvcrRef string     `xml:"Refs>AcctSvcrRef"`
+	EndToEndId  string     `xml:"Refs>EndToEndId"`
+	Amt         camtAmount `xml:"Amt"`
+	CdtDbtInd   string     `xml:"CdtDbtInd"`
+	DebtorName  string     `xml:"RltdPties>Dbtr>Nm"`
+	DebtorPty   string     `xml:"RltdPties>Dbtr>Pty>Nm"`
+	CreditorNm  string     `xml:"RltdPties>Cdtr>Nm"`
+	CreditorPty string     `xml:"RltdPties>Cdtr>Pty>Nm"`
+	Ustrd       []string   `xml:"RmtInf>Ustrd"`
+	StrdRef     string     `xml:"RmtInf>Strd>CdtrRefInf>Ref"`
+	AddtlTxInf  string     `xml:"AddtlTxInf"`
+}
+
+// ParseCamt053Statement reads an ISO 20022 camt.053 bank to customer
+// statement. A file can hold several statements, e.g. one per account or
+// currency, and batch bookings with several transaction details are split
+// into one row per detail. Only booked entries are returned.
+func ParseCamt053Statement(r io.Reader) ([]StatementRow, []ImportRowError, error) {
+	var doc camtDocument
+	decoder := xml.NewDecoder(io.LimitReader(r, MAX_IMPORT_FILE_SIZE))
+	decoder.CharsetReader = func(charset string, input io.Reader) (io.Reader, error) {
+		// ISO 20022 messages are UTF-8, some banks still label them latin1
+		return input, nil
+	}
+	if err := decoder.Decode(&doc); err != nil {
+		return nil, nil, invalidMapping("The file is not a valid camt.053 XML statement")
+	}
+	if len(doc.Statements) == 0 {
+		return nil, nil, invalidMapping("The file is not a camt.053 statement")
+	}
+
+	var rows []StatementRow
+	var rowErrors []ImportRowError
+	index := 0
+	for _, stmt := range doc.Statements {
+		account := stmt.IBAN
+		if account == "" {
+			account = stmt.OtherId
+		}
+
+		for _, entry := range stmt.Entries {
+			index++
+			status := strings.TrimSpace(entry.Sts.Cd + entry.Sts.Text)
+			if status != "" && status != "BOOK" {
+				continue
+			}
+
+			entryRows, err := camtEntryRows(entry, stmt.Ccy, account)
+			if err != nil {
+				rowErrors = append(rowErrors, ImportRowError{Index: index, Message: err.Error()})
+				continue
+			}
+			for _, row := range entryRows {
+				row.Index = index
+				rows = append(rows, row)
+			}
+			if len(rows)+len(rowErrors) > MAX_STATEMENT_ROWS {
+				return nil, nil, statementTooLarge()
+			}
+		}
+	}
+
+	return rows, rowErrors, nil
+}
+
+func camtEntryRows(entry camtEntry, accountCcy string, account string) ([]StatementRow, error) {
+	date, err := parseCamtDate(entry.BookgDt, entry.BookgDtTm)
+	if err != nil {
+		return nil, err
+	}
+
+	entryRef := entry.AcctSvcrRef
+	if entryRef == "" {
+		entryRef = entry.NtryRef
+	}
+
+	// a batch booking lists each payment with its own amount, otherwise the
+	// entry amount is the one to use.
+	details := entry.TxDtls
+	split := len(details) > 1
+	if split {
+		for _, d := range details {
+			if d.Amt.Value == "" {
+				split = false
+				break
+			}
+		}
+	}
+	if !split {
+		var d camtTxDetails
+		if len(details) > 0 {
+			d = details[0]
+		}
+		d.Amt = entry.Amt
+		d.CdtDbtInd = entry.CdtDbtInd
+		details = []camtTxDetails{d}
+	}
+
+	rows := make([]StatementRow, 0, len(details))
+	for i, d := range details {
+		indicator := d.CdtDbtInd
+		if indicator == "" {
+			indicator = entry.CdtDbtInd
+		}
+		amount, err := camtSignedAmount(d.Amt.Value, indicator)
+		if err != nil {
+			return nil, err
+		}
+		if entry.RvslInd {
+			amount = -amount
+		}
+
+		currency := d.Amt.Ccy
+		if currency == "" {
+			currency = accountCcy
+		}
+		if currency == "" {
+			return nil, fmt.Errorf("Currency is missing")
+		}
+
+		row := StatementRow{
+			Date:        date,
+			Amount:      amount,
+			Currency:    strings.ToUpper(currency),
+			Description: camtDescription(entry, d, amount),
+		}
+
+		ref := d.AcctSvcrRef
+		if ref == "" && entryRef != "" {
+			ref = entryRef
+			if split {
+				ref += ":" + strconv.Itoa(i+1)
+			}
+		}
+		if ref != "" {
+			row.ExternalID = "camt:" + account + ":" + ref
+		}
+		rows = append(rows, row)
+	}
+	return rows, nil
+}
+
+func camtSignedAmount(value string, indicator string) (float64, error) {
+	amount, err := strconv.ParseFloat(strings.TrimSpace(value), 64)
+	if err != nil {
+		return 0, fmt.Errorf("Invalid amount %q", value)
+	}
+	switch indicator {
+	case "CRDT":
+		return amount, nil
+	case "DBIT":
+		return -amount, nil
+	}
+	return 0, fmt.Errorf("Invalid credit/debit indicator %q", indicator)
+}
+
+// camtDescription names the other party (the creditor of a payment, the
+// debtor of an incoming transfer) followed by the remittance information.
+func camtDescription(entry camtEntry, d camtTxDetails, amount float64) string {
+	party := d.CreditorNm + d.CreditorPty
+	if amount > 0 {
+		party = d.DebtorName + d.DebtorPty
+	}
+
+	info := strings.TrimSpace(strings.Join(d.Ustrd, " "))
+	if info == "" {
+		info = d.StrdRef
+	}
+	if info == "" {
+		info = d.AddtlTxInf
+	}
+	if info == "" {
+		info = entry.AddtlNtryInf
+	}
+
+	return strings.TrimSpace(strings.TrimSpace(party) + " " + strings.Join(strings.Fields(info), " "))
+}
+
+func parseCamtDate(date string, dateTime string) (time.Time, error) {
+	if date != "" {
+		if t, err := time.Parse("2006-01-02", strings.TrimSpace(date)); err == nil {
+			return t, nil
+		}
+		return time.Time{}, fmt.Errorf("Invalid booking date %q", date)
+	}
+	if dateTime != "" {
+		for _, layout := range []string{time.RFC3339, "2006-01-02T15:04:05"} {
+			if t, err := time.Parse(layout, strings.TrimSpace(dateTime)); err == nil {
+				return t.UTC(), nil
+			}
+		}
+		return time.Time{}, fmt.Errorf("Invalid booking date %q", dateTime)
+	}
+	return time.Time{}, fmt.Errorf("Booking date is missing")
+}
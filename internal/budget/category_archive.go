@@ -0,0 +1,78 @@
+package budget
+
+import (
+	"context"
+	"time"
+
+	appErrors "github.com/fatali-fataliyev/budget_tracker/customErrors"
+	"github.com/fatali-fataliyev/budget_tracker/internal/contextutil"
+	"github.com/fatali-fataliyev/budget_tracker/logging"
+)
+
+// SetCategoryArchived archives or unarchives a category. An archived category
+// is left out of category lists and takes no new transactions, but its
+// transactions stay and still count in totals, statistics and its parent.
+func (bt *BudgetTracker) SetCategoryArchived(ctx context.Context, userId string, categoryType string, id string, archived bool) error {
+	traceID := contextutil.TraceIDFromContext(ctx)
+
+	if categoryType != "+" && categoryType != "-" {
+		return appErrors.ErrorResponse{
+			Code:    appErrors.ErrInvalidInput,
+			Message: "Invalid category type.",
+		}
+	}
+
+	parents, err := bt.storage.GetCategoryParents(ctx, userId, categoryType)
+	if err != nil {
+		logging.Logger.Errorf("[TraceID=%s] | storage.GetCategoryParents() failed in Service.SetCategoryArchived()", traceID)
+		return err
+	}
+	if _, ok := parents[id]; !ok {
+		return appErrors.ErrorResponse{
+			Code:    appErrors.ErrNotFound,
+			Message: "The category does not exist.",
+		}
+	}
+
+	var archivedAt time.Time
+	if archived {
+		archivedAt = time.Now().UTC()
+	}
+	if err := bt.storage.SetCategoryArchived(ctx, userId, categoryType, id, archivedAt); err != nil {
+		logging.Logger.Errorf("[TraceID=%s] | storage.SetCategoryArchived() failed in Service.SetCategoryArchived()", traceID)
+		return err
+	}
+	return nil
+}
+
+// checkCategoryMoveTarget checks that the transactions of a category that is
+// being deleted can be moved to moveTo, another category of the same type.
+func (bt *BudgetTracker) checkCategoryMoveTarget(ctx context.Context, userId string, categoryType string, id string, moveTo string) error {
+	if moveTo == "" {
+		return nil
+	}
+	if moveTo == id {
+		return appErrors.ErrorResponse{
+			Code:    appErrors.ErrInvalidInput,
+			Message: "Transactions cannot be moved to the category that is deleted.",
+		}
+	}
+
+	parents, err := bt.storage.GetCategoryParents(ctx, userId, categoryType)
+	if err != nil {
+		return err
+	}
+	if _, ok := parents[id]; !ok {
+		return appErrors.ErrorResponse{
+			Code:    appErrors.ErrNotFound,
+			Message: "The category does not exist.",
+		}
+	}
+	if _, ok := parents[moveTo]; !ok {
+		return appErrors.ErrorResponse{
+			Code:    appErrors.ErrNotFound,
+			Message: "The category to move the transactions to does not exist.",
+		}
+	}
+	return nil
+}
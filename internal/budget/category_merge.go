@@ -0,0 +1,338 @@
+package budget
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	appErrors "github.com/fatali-fataliyev/budget_tracker/customErrors"
+	"github.com/fatali-fataliyev/budget_tracker/internal/contextutil"
+	"github.com/fatali-fataliyev/budget_tracker/logging"
+	"github.com/google/uuid"
+)
+
+const (
+	MERGE_KEEP_TARGET = "target" // keep the limit or note of the target
+	MERGE_SUM         = "sum"    // target limit plus the limits of the sources
+	MERGE_MAX         = "max"    // the largest limit of the merged categories
+	MERGE_JOIN        = "join"   // target note followed by the notes of the sources
+
+	MAX_MERGE_SOURCES = 20
+)
+
+type CategoryMergeRequest struct {
+	TargetId      string
+	SourceIds     []string
+	LimitStrategy string // MERGE_KEEP_TARGET, MERGE_SUM or MERGE_MAX, the target's by default
+	NoteStrategy  string // MERGE_KEEP_TARGET or MERGE_JOIN, the target's by default
+}
+
+// MergedCategory is a source category as it was right before the merge.
+type MergedCategory struct {
+	ID    string
+	Name  string
+	Limit float64
+	Note  string
+}
+
+// CategoryMerge is the audit entry of a merge. Limit is the maximum amount of
+// an expense category and the target amount of an income category.
+type CategoryMerge struct {
+	ID                string
+	CategoryType      string
+	TargetId          string
+	TargetName        string
+	Sources           []MergedCategory
+	LimitStrategy     string
+	NoteStrategy      string
+	OldLimit          float64
+	NewLimit          float64
+	OldNote           string
+	NewNote           string
+	MovedTransactions int // filled by storage
+	CreatedAt         time.Time
+	CreatedBy         string
+}
+
+// mergeCandidate is what a merge needs of a category of either type, with
+// its own limit rather than the rolled-up one.
+type mergeCandidate struct {
+	ID       string
+	Name     string
+	Limit    float64
+	Note     string
+	ParentId string
+}
+
+// MergeCategories moves the transactions, splits, recurring transactions and
+// subcategories of the source categories into the target and deletes the
+// sources, all at once. The limit and note of the target are recomputed with
+// the chosen strategies. The returned audit entry is kept.
+func (bt *BudgetTracker) MergeCategories(ctx context.Context, userId string, categoryType string, req CategoryMergeRequest) (CategoryMerge, error) {
+	traceID := contextutil.TraceIDFromContext(ctx)
+
+	sourceIds, err := validateCategoryMerge(categoryType, &req)
+	if err != nil {
+		return CategoryMerge{}, err
+	}
+
+	candidates, err := bt.mergeCandidates(ctx, userId, categoryType)
+	if err != nil {
+		logging.Logger.Errorf("[TraceID=%s] | failed to get categories in Service.MergeCategories()", traceID)
+		return CategoryMerge{}, err
+	}
+	target, ok := candidates[req.TargetId]
+	if !ok {
+		return CategoryMerge{}, appErrors.ErrorResponse{
+			Code:    appErrors.ErrNotFound,
+			Message: "The target category does not exist.",
+		}
+	}
+
+	merge := CategoryMerge{
+		ID:            uuid.New().String(),
+		CategoryType:  categoryType,
+		TargetId:      target.ID,
+		TargetName:    target.Name,
+		LimitStrategy: req.LimitStrategy,
+		NoteStrategy:  req.NoteStrategy,
+		OldLimit:      target.Limit,
+		OldNote:       target.Note,
+		CreatedAt:     time.Now().UTC(),
+		CreatedBy:     userId,
+	}
+	for _, id := range sourceIds {
+		source, ok := candidates[id]
+		if !ok {
+			return CategoryMerge{}, appErrors.ErrorResponse{
+				Code:    appErrors.ErrNotFound,
+				Message: fmt.Sprintf("The source category %s does not exist.", id),
+			}
+		}
+		merge.Sources = append(merge.Sources, MergedCategory{ID: source.ID, Name: source.Name, Limit: source.Limit, Note: source.Note})
+	}
+
+	if err := checkMergedTree(candidates, target.ID, sourceIds); err != nil {
+		return CategoryMerge{}, err
+	}
+	if merge.NewLimit, err = mergedLimit(categoryType, merge); err != nil {
+		return CategoryMerge{}, err
+	}
+	if merge.NewNote, err = mergedNote(merge); err != nil {
+		return CategoryMerge{}, err
+	}
+
+	moved, err := bt.storage.MergeCategories(ctx, merge)
+	if err != nil {
+		logging.Logger.Errorf("[TraceID=%s] | storage.MergeCategories() failed in Service.MergeCategories()", traceID)
+		return CategoryMerge{}, err
+	}
+	merge.MovedTransactions = moved
+	bt.forgetCategories(userId)
+	return merge, nil
+}
+
+// GetCategoryMerges returns the merges of categories of a type, newest first.
+func (bt *BudgetTracker) GetCategoryMerges(ctx context.Context, userId string, categoryType string) ([]CategoryMerge, error) {
+	if categoryType != "+" && categoryType != "-" {
+		return nil, appErrors.ErrorResponse{
+			Code:    appErrors.ErrInvalidInput,
+			Message: "Invalid category type.",
+		}
+	}
+	return bt.storage.GetCategoryMerges(ctx, userId, categoryType)
+}
+
+// validateCategoryMerge fills in the default strategies and returns the
+// source IDs without duplicates.
+func validateCategoryMerge(categoryType string, req *CategoryMergeRequest) ([]string, error) {
+	if categoryType != "+" && categoryType != "-" {
+		return nil, appErrors.ErrorResponse{
+			Code:    appErrors.ErrInvalidInput,
+			Message: "Invalid category type.",
+		}
+	}
+	if req.TargetId == "" {
+		return nil, appErrors.ErrorResponse{
+			Code:    appErrors.ErrInvalidInput,
+			Message: "Target category ID cannot be empty!",
+		}
+	}
+
+	seen := map[string]bool{}
+	var sourceIds []string
+	for _, id := range req.SourceIds {
+		if id == "" || seen[id] {
+			continue
+		}
+		if id == req.TargetId {
+			return nil, appErrors.ErrorResponse{
+				Code:    appErrors.ErrInvalidInput,
+				Message: "The target category cannot be one of the source categories.",
+			}
+		}
+		seen[id] = true
+		sourceIds = append(sourceIds, id)
+	}
+	if len(sourceIds) == 0 {
+		return nil, appErrors.ErrorResponse{
+			Code:    appErrors.ErrInvalidInput,
+			Message: "Source category IDs cannot be empty!",
+		}
+	}
+	if len(sourceIds) > MAX_MERGE_SOURCES {
+		return nil, appErrors.ErrorResponse{
+			Code:    appErrors.ErrInvalidInput,
+			Message: fmt.Sprintf("Maximum %d categories can be merged at once", MAX_MERGE_SOURCES),
+		}
+	}
+
+	if req.LimitStrategy == "" {
+		req.LimitStrategy = MERGE_KEEP_TARGET
+	}
+	if req.LimitStrategy != MERGE_KEEP_TARGET && req.LimitStrategy != MERGE_SUM && req.LimitStrategy != MERGE_MAX {
+		return nil, appErrors.ErrorResponse{
+			Code:    appErrors.ErrInvalidInput,
+			Message: fmt.Sprintf("Invalid limit strategy, use %s, %s or %s.", MERGE_KEEP_TARGET, MERGE_SUM, MERGE_MAX),
+		}
+	}
+	if req.NoteStrategy == "" {
+		req.NoteStrategy = MERGE_KEEP_TARGET
+	}
+	if req.NoteStrategy != MERGE_KEEP_TARGET && req.NoteStrategy != MERGE_JOIN {
+		return nil, appErrors.ErrorResponse{
+			Code:    appErrors.ErrInvalidInput,
+			Message: fmt.Sprintf("Invalid note strategy, use %s or %s.", MERGE_KEEP_TARGET, MERGE_JOIN),
+		}
+	}
+	return sourceIds, nil
+}
+
+func (bt *BudgetTracker) mergeCandidates(ctx context.Context, userId string, categoryType string) (map[string]mergeCandidate, error) {
+	candidates := map[string]mergeCandidate{}
+	if categoryType == "-" {
+		categories, err := bt.storage.GetFilteredExpenseCategories(ctx, userId, &ExpenseCategoryList{IsAllNil: true})
+		if err != nil {
+			return nil, err
+		}
+		for _, c := range categories {
+			candidates[c.ID] = mergeCandidate{ID: c.ID, Name: c.Name, Limit: c.MaxAmount, Note: c.Note, ParentId: c.ParentId}
+		}
+		return candidates, nil
+	}
+
+	categories, err := bt.storage.GetFilteredIncomeCategories(ctx, userId, &IncomeCategoryList{IsAllNil: true})
+	if err != nil {
+		return nil, err
+	}
+	for _, c := range categories {
+		candidates[c.ID] = mergeCandidate{ID: c.ID, Name: c.Name, Limit: c.TargetAmount, Note: c.Note, ParentId: c.ParentId}
+	}
+	return candidates, nil
+}
+
+// checkMergedTree checks the tree after the subcategories of the sources are
+// moved under the target. The target cannot be inside a source, its place in
+// the tree would be gone.
+func checkMergedTree(candidates map[string]mergeCandidate, targetId string, sourceIds []string) error {
+	parents := make(map[string]string, len(candidates))
+	for id, c := range candidates {
+		parents[id] = c.ParentId
+	}
+	isSource := make(map[string]bool, len(sourceIds))
+	for _, id := range sourceIds {
+		isSource[id] = true
+	}
+
+	for current, steps := parents[targetId], 0; current != "" && steps <= MAX_CATEGORY_DEPTH; current, steps = parents[current], steps+1 {
+		if isSource[current] {
+			return appErrors.ErrorResponse{
+				Code:    appErrors.ErrConflict,
+				Message: "The target category cannot be a subcategory of a source category.",
+			}
+		}
+	}
+
+	var children []string
+	for id, parentId := range parents {
+		if isSource[parentId] && !isSource[id] {
+			children = append(children, id)
+		}
+	}
+	for id := range isSource {
+		delete(parents, id)
+	}
+	for _, id := range children {
+		parents[id] = ""
+	}
+	for _, id := range children {
+		if err := checkCategoryParent(parents, id, targetId); err != nil {
+			return err
+		}
+		parents[id] = targetId
+	}
+	return nil
+}
+
+func mergedLimit(categoryType string, merge CategoryMerge) (float64, error) {
+	limit := toCents(merge.OldLimit)
+	for _, source := range merge.Sources {
+		switch merge.LimitStrategy {
+		case MERGE_SUM:
+			limit += toCents(source.Limit)
+		case MERGE_MAX:
+			limit = max(limit, toCents(source.Limit))
+		}
+	}
+
+	newLimit := float64(limit) / 100
+	if categoryType == "-" && newLimit > MAX_CATEGORY_AMOUNT_LIMIT {
+		return 0, appErrors.ErrorResponse{
+			Code:    appErrors.ErrInvalidInput,
+			Message: fmt.Sprintf("Merged maximum amount is too large, allowed maximum amount is %2.f", MAX_CATEGORY_AMOUNT_LIMIT),
+		}
+	}
+	if categoryType == "+" && newLimit > MAX_TARGET_AMOUNT_LIMIT {
+		return 0, appErrors.ErrorResponse{
+			Code:    appErrors.ErrInvalidInput,
+			Message: fmt.Sprintf("Merged target amount is too large, allowed maximum target amount is %d", MAX_TARGET_AMOUNT_LIMIT),
+		}
+	}
+	return newLimit, nil
+}
+
+// mergedNote joins the distinct non-empty notes, the target's first.
+func mergedNote(merge CategoryMerge) (string, error) {
+	if merge.NoteStrategy != MERGE_JOIN {
+		return merge.OldNote, nil
+	}
+
+	var notes []string
+	seen := map[string]bool{}
+	for _, note := range append([]string{merge.OldNote}, sourceNotes(merge.Sources)...) {
+		note = strings.TrimSpace(note)
+		if note == "" || seen[note] {
+			continue
+		}
+		seen[note] = true
+		notes = append(notes, note)
+	}
+
+	joined := strings.Join(notes, "; ")
+	if len(joined) > MAX_TRANSACTION_NOTE_LENGTH {
+		return "", appErrors.ErrorResponse{
+			Code:    appErrors.ErrInvalidInput,
+			Message: fmt.Sprintf("Joined note so long, maximum allowed note length is %d, keep the target note instead", MAX_TRANSACTION_NOTE_LENGTH),
+		}
+	}
+	return joined, nil
+}
+
+func sourceNotes(sources []MergedCategory) []string {
+	notes := make([]string, 0, len(sources))
+	for _, source := range sources {
+		notes = append(notes, source.Note)
+	}
+	return notes
+}
@@ -0,0 +1,283 @@
+package budget
+
+import (
+	"context"
+	"fmt"
+
+	appErrors "github.com/fatali-fataliyev/budget_tracker/customErrors"
+	"github.com/fatali-fataliyev/budget_tracker/internal/contextutil"
+	"github.com/fatali-fataliyev/budget_tracker/logging"
+)
+
+// MAX_CATEGORY_DEPTH is how many levels a category tree can have, a top
+// level category counts as one.
+const MAX_CATEGORY_DEPTH = 5
+
+// categoryTotals is what a category adds to itself and to its ancestors, in
+// cents.
+type categoryTotals struct {
+	Amount int64
+	Limit  int64
+}
+
+// rollUpCategories adds the totals of every category to all its ancestors.
+// parents maps every category ID to its parent ID, empty for top level ones.
+func rollUpCategories(parents map[string]string, own map[string]categoryTotals) map[string]categoryTotals {
+	rolled := make(map[string]categoryTotals, len(own))
+	for id, totals := range own {
+		seen := map[string]bool{}
+		for current := id; current != "" && !seen[current]; current = parents[current] {
+			seen[current] = true
+			r := rolled[current]
+			r.Amount += totals.Amount
+			r.Limit += totals.Limit
+			rolled[current] = r
+		}
+	}
+	return rolled
+}
+
+func totalsUsagePercent(totals categoryTotals) int {
+	if totals.Limit <= 0 {
+		return 0
+	}
+	return int(float64(totals.Amount) / float64(totals.Limit) * 100)
+}
+
+// checkCategoryParent checks that category id can be placed under parentId:
+// the parent exists, is not the category itself or one of its subcategories,
+// and the tree stays within MAX_CATEGORY_DEPTH levels.
+func checkCategoryParent(parents map[string]string, id string, parentId string) error {
+	if parentId == "" {
+		return nil
+	}
+	if _, ok := parents[parentId]; !ok {
+		return appErrors.ErrorResponse{
+			Code:    appErrors.ErrNotFound,
+			Message: "The parent category does not exist.",
+		}
+	}
+	if parentId == id {
+		return appErrors.ErrorResponse{
+			Code:    appErrors.ErrInvalidInput,
+			Message: "A category cannot be its own parent.",
+		}
+	}
+
+	depth := 1
+	for current := parentId; current != ""; current = parents[current] {
+		if current == id {
+			return appErrors.ErrorResponse{
+				Code:    appErrors.ErrConflict,
+				Message: "A category cannot be moved under its own subcategory.",
+			}
+		}
+		depth++
+		if depth > MAX_CATEGORY_DEPTH+1 {
+			// a cycle that is already stored, do not loop forever
+			break
+		}
+	}
+
+	if depth+subtreeHeight(parents, id)-1 > MAX_CATEGORY_DEPTH {
+		return appErrors.ErrorResponse{
+			Code:    appErrors.ErrInvalidInput,
+			Message: fmt.Sprintf("Categories can be nested at most %d levels deep.", MAX_CATEGORY_DEPTH),
+		}
+	}
+	return nil
+}
+
+// subtreeHeight is the number of levels of the tree under and including id.
+func subtreeHeight(parents map[string]string, id string) int {
+	height := 1
+	for node := range parents {
+		levels := 1
+		for current := node; current != "" && levels <= MAX_CATEGORY_DEPTH+1; current = parents[current] {
+			if current == id {
+				height = max(height, levels)
+				break
+			}
+			levels++
+		}
+	}
+	return height
+}
+
+// ExpenseCategoryTree nests categories under their parents. Categories whose
+// parent is not in the list are roots, the order of the list is kept.
+func ExpenseCategoryTree(categories []ExpenseCategoryResponse) []ExpenseCategoryResponse {
+	ids := make([]string, len(categories))
+	parentIds := make([]string, len(categories))
+	for i, c := range categories {
+		ids[i], parentIds[i] = c.ID, c.ParentId
+	}
+	roots, children := categoryTreeIndex(ids, parentIds)
+
+	var build func(i int) ExpenseCategoryResponse
+	build = func(i int) ExpenseCategoryResponse {
+		c := categories[i]
+		for _, child := range children[i] {
+			c.Children = append(c.Children, build(child))
+		}
+		return c
+	}
+	tree := make([]ExpenseCategoryResponse, 0, len(roots))
+	for _, i := range roots {
+		tree = append(tree, build(i))
+	}
+	return tree
+}
+
+// IncomeCategoryTree nests categories under their parents, see
+// ExpenseCategoryTree.
+func IncomeCategoryTree(categories []IncomeCategoryResponse) []IncomeCategoryResponse {
+	ids := make([]string, len(categories))
+	parentIds := make([]string, len(categories))
+	for i, c := range categories {
+		ids[i], parentIds[i] = c.ID, c.ParentId
+	}
+	roots, children := categoryTreeIndex(ids, parentIds)
+
+	var build func(i int) IncomeCategoryResponse
+	build = func(i int) IncomeCategoryResponse {
+		c := categories[i]
+		for _, child := range children[i] {
+			c.Children = append(c.Children, build(child))
+		}
+		return c
+	}
+	tree := make([]IncomeCategoryResponse, 0, len(roots))
+	for _, i := range roots {
+		tree = append(tree, build(i))
+	}
+	return tree
+}
+
+// categoryTreeIndex returns the positions of the roots and, per position, the
+// positions of its children. A category that is part of a cycle is a root so
+// nothing is lost.
+func categoryTreeIndex(ids []string, parentIds []string) ([]int, map[int][]int) {
+	position := make(map[string]int, len(ids))
+	for i, id := range ids {
+		position[id] = i
+	}
+
+	var roots []int
+	children := map[int][]int{}
+	for i, parentId := range parentIds {
+		parent, ok := position[parentId]
+		if !ok || inCategoryCycle(ids, parentIds, position, i) {
+			roots = append(roots, i)
+			continue
+		}
+		children[parent] = append(children[parent], i)
+	}
+	return roots, children
+}
+
+func inCategoryCycle(ids []string, parentIds []string, position map[string]int, i int) bool {
+	current, ok := i, true
+	for steps := 0; ok && steps <= len(ids); steps++ {
+		current, ok = position[parentIds[current]]
+		if ok && current == i {
+			return true
+		}
+	}
+	return false
+}
+
+// MoveCategory places a category under another category of the same type,
+// or at the top level when parentId is empty. The subtree moves with it.
+func (bt *BudgetTracker) MoveCategory(ctx context.Context, userId string, categoryType string, id string, parentId string) error {
+	traceID := contextutil.TraceIDFromContext(ctx)
+
+	if categoryType != "+" && categoryType != "-" {
+		return appErrors.ErrorResponse{
+			Code:    appErrors.ErrInvalidInput,
+			Message: "Invalid category type.",
+		}
+	}
+
+	parents, err := bt.storage.GetCategoryParents(ctx, userId, categoryType)
+	if err != nil {
+		logging.Logger.Errorf("[TraceID=%s] | storage.GetCategoryParents() failed in Service.MoveCategory()", traceID)
+		return err
+	}
+	if _, ok := parents[id]; !ok {
+		return appErrors.ErrorResponse{
+			Code:    appErrors.ErrNotFound,
+			Message: "The category does not exist.",
+		}
+	}
+	if err := checkCategoryParent(parents, id, parentId); err != nil {
+		return err
+	}
+
+	if err := bt.storage.SetCategoryParent(ctx, userId, categoryType, id, parentId); err != nil {
+		logging.Logger.Errorf("[TraceID=%s] | storage.SetCategoryParent() failed in Service.MoveCategory()", traceID)
+		return err
+	}
+	return nil
+}
+
+// checkNewCategoryParent checks the parent of a category that is about to be
+// created.
+func (bt *BudgetTracker) checkNewCategoryParent(ctx context.Context, userId string, categoryType string, id string, parentId string) error {
+	if parentId == "" {
+		return nil
+	}
+	parents, err := bt.storage.GetCategoryParents(ctx, userId, categoryType)
+	if err != nil {
+		return err
+	}
+	return checkCategoryParent(parents, id, parentId)
+}
+
+// rollUpExpenseCategories replaces Amount, MaxAmount and UsagePercent of the
+// given categories with the totals of their subtrees. all is every expense
+// category of the user.
+func rollUpExpenseCategories(all []ExpenseCategoryResponse, categories []ExpenseCategoryResponse) {
+	parents := make(map[string]string, len(all))
+	own := make(map[string]categoryTotals, len(all))
+	for _, c := range all {
+		parents[c.ID] = c.ParentId
+		own[c.ID] = categoryTotals{Amount: toCents(c.Amount), Limit: toCents(c.MaxAmount)}
+	}
+	for _, c := range categories {
+		// the given categories may be fresher, e.g. right after an update
+		parents[c.ID] = c.ParentId
+		own[c.ID] = categoryTotals{Amount: toCents(c.Amount), Limit: toCents(c.MaxAmount)}
+	}
+
+	rolled := rollUpCategories(parents, own)
+	for i := range categories {
+		totals := rolled[categories[i].ID]
+		categories[i].Amount = float64(totals.Amount) / 100
+		categories[i].MaxAmount = float64(totals.Limit) / 100
+		categories[i].UsagePercent = totalsUsagePercent(totals)
+	}
+}
+
+// rollUpIncomeCategories is rollUpExpenseCategories for income categories,
+// the target amount rolls up like a limit.
+func rollUpIncomeCategories(all []IncomeCategoryResponse, categories []IncomeCategoryResponse) {
+	parents := make(map[string]string, len(all))
+	own := make(map[string]categoryTotals, len(all))
+	for _, c := range all {
+		parents[c.ID] = c.ParentId
+		own[c.ID] = categoryTotals{Amount: toCents(c.Amount), Limit: toCents(c.TargetAmount)}
+	}
+	for _, c := range categories {
+		parents[c.ID] = c.ParentId
+		own[c.ID] = categoryTotals{Amount: toCents(c.Amount), Limit: toCents(c.TargetAmount)}
+	}
+
+	rolled := rollUpCategories(parents, own)
+	for i := range categories {
+		totals := rolled[categories[i].ID]
+		categories[i].Amount = float64(totals.Amount) / 100
+		categories[i].TargetAmount = float64(totals.Limit) / 100
+		categories[i].UsagePercent = totalsUsagePercent(totals)
+	}
+}
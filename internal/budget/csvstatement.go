@@ -0,0 +1,349 @@
+package budget
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	appErrors "github.com/fatali-fataliyev/budget_tracker/customErrors"
+)
+
+const (
+	CSV_SIGN_NEGATIVE_EXPENSE = "negative_expense" // -12.50 is money going out
+	CSV_SIGN_POSITIVE_EXPENSE = "positive_expense" // 12.50 is money going out, e.g. credit card statements
+	CSV_SIGN_DEBIT_CREDIT     = "debit_credit"     // separate debit and credit columns
+
+	DEFAULT_CSV_DATE_FORMAT = "YYYY-MM-DD"
+)
+
+// CSVMapping describes how to read a bank's CSV export. Columns are header
+// names when HasHeader is set, otherwise 0-based column numbers.
+type CSVMapping struct {
+	HasHeader         bool
+	SkipRows          int // lines before the header, e.g. an account summary
+	Delimiter         rune
+	DateColumn        string
+	AmountColumn      string
+	DebitColumn       string
+	CreditColumn      string
+	CurrencyColumn    string
+	DescriptionColumn string
+	DateFormat        string // YYYY, MM, DD, HH, mm, ss tokens or a Go layout
+	DecimalSeparator  string // "." or ","
+	SignConvention    string
+	Currency          string // used when there is no currency column
+}
+
+type csvColumns struct {
+	date, amount, debit, credit, currency, description int
+}
+
+func (m *CSVMapping) normalize() error {
+	if m.Delimiter == 0 {
+		m.Delimiter = ','
+	}
+	if m.DateFormat == "" {
+		m.DateFormat = DEFAULT_CSV_DATE_FORMAT
+	}
+	if m.DecimalSeparator == "" {
+		m.DecimalSeparator = "."
+	}
+	if m.SignConvention == "" {
+		m.SignConvention = CSV_SIGN_NEGATIVE_EXPENSE
+	}
+	m.Currency = strings.ToUpper(strings.TrimSpace(m.Currency))
+
+	if m.SkipRows < 0 {
+		return invalidMapping("Skip rows cannot be negative")
+	}
+	if m.DecimalSeparator != "." && m.DecimalSeparator != "," {
+		return invalidMapping("Decimal separator must be '.' or ','")
+	}
+	if m.DateColumn == "" {
+		return invalidMapping("Date column is required")
+	}
+	switch m.SignConvention {
+	case CSV_SIGN_NEGATIVE_EXPENSE, CSV_SIGN_POSITIVE_EXPENSE:
+		if m.AmountColumn == "" {
+			return invalidMapping("Amount column is required")
+		}
+	case CSV_SIGN_DEBIT_CREDIT:
+		if m.DebitColumn == "" || m.CreditColumn == "" {
+			return invalidMapping("Debit and credit columns are required")
+		}
+	default:
+		return invalidMapping("Invalid sign convention, allowed values: negative_expense, positive_expense, debit_credit")
+	}
+	if m.CurrencyColumn == "" && m.Currency == "" {
+		return invalidMapping("Currency column or a default currency is required")
+	}
+	return nil
+}
+
+func invalidMapping(message string) error {
+	return appErrors.ErrorResponse{
+		Code:    appErrors.ErrInvalidInput,
+		Message: message,
+	}
+}
+
+// ParseCSVStatement reads a CSV statement with the given mapping. Rows that
+// cannot be parsed are returned as row errors, an error is returned only
+// when the mapping or the file itself is unusable.
+func ParseCSVStatement(r io.Reader, mapping CSVMapping) ([]StatementRow, []ImportRowError, error) {
+	if err := mapping.normalize(); err != nil {
+		return nil, nil, err
+	}
+
+	reader := csv.NewReader(r)
+	reader.Comma = mapping.Delimiter
+	reader.FieldsPerRecord = -1
+	reader.LazyQuotes = true
+	reader.TrimLeadingSpace = true
+
+	line := 0
+	next := func() ([]string, error) {
+		record, err := reader.Read()
+		if err == nil {
+			line, _ = reader.FieldPos(0)
+		}
+		return record, err
+	}
+
+	for i := 0; i < mapping.SkipRows; i++ {
+		if _, err := next(); err != nil {
+			return nil, nil, invalidMapping("The file has fewer lines than skip rows")
+		}
+	}
+
+	var header []string
+	if mapping.HasHeader {
+		record, err := next()
+		if err != nil {
+			return nil, nil, invalidMapping("The file has no header row")
+		}
+		header = record
+		if len(header) > 0 {
+			header[0] = strings.TrimPrefix(header[0], "\ufeff")
+		}
+	}
+
+	columns, err := resolveCSVColumns(mapping, header)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	layout := csvDateLayout(mapping.DateFormat)
+	var rows []StatementRow
+	var rowErrors []ImportRowError
+	for {
+		record, err := next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			var parseErr *csv.ParseError
+			if errors.As(err, &parseErr) {
+				rowErrors = append(rowErrors, ImportRowError{Index: parseErr.Line, Message: parseErr.Err.Error()})
+				continue
+			}
+			return nil, nil, invalidMapping("Failed to read the CSV file")
+		}
+		if isBlankRecord(record) {
+			continue
+		}
+		if len(rows)+len(rowErrors) >= MAX_STATEMENT_ROWS {
+			return nil, nil, statementTooLarge()
+		}
+
+		row, err := parseCSVRecord(record, columns, mapping, layout)
+		if err != nil {
+			rowErrors = append(rowErrors, ImportRowError{Index: line, Message: err.Error()})
+			continue
+		}
+		row.Index = line
+		rows = append(rows, row)
+	}
+
+	return rows, rowErrors, nil
+}
+
+func resolveCSVColumns(mapping CSVMapping, header []string) (csvColumns, error) {
+	resolve := func(name string) (int, error) {
+		if name == "" {
+			return -1, nil
+		}
+		if header == nil {
+			index, err := strconv.Atoi(name)
+			if err != nil || index < 0 {
+				return 0, invalidMapping(fmt.Sprintf("Column %q must be a column number when the file has no header", name))
+			}
+			return index, nil
+		}
+		for i, h := range header {
+			if strings.EqualFold(strings.TrimSpace(h), strings.TrimSpace(name)) {
+				return i, nil
+			}
+		}
+		return 0, invalidMapping(fmt.Sprintf("Column %q not found in the header", name))
+	}
+
+	var columns csvColumns
+	var err error
+	for _, c := range []struct {
+		name   string
+		target *int
+	}{
+		{mapping.DateColumn, &columns.date},
+		{mapping.AmountColumn, &columns.amount},
+		{mapping.DebitColumn, &columns.debit},
+		{mapping.CreditColumn, &columns.credit},
+		{mapping.CurrencyColumn, &columns.currency},
+		{mapping.DescriptionColumn, &columns.description},
+	} {
+		if *c.target, err = resolve(c.name); err != nil {
+			return csvColumns{}, err
+		}
+	}
+	return columns, nil
+}
+
+func parseCSVRecord(record []string, columns csvColumns, mapping CSVMapping, layout string) (StatementRow, error) {
+	field := func(index int) string {
+		if index < 0 || index >= len(record) {
+			return ""
+		}
+		return strings.TrimSpace(record[index])
+	}
+
+	var row StatementRow
+
+	dateStr := field(columns.date)
+	if dateStr == "" {
+		return row, fmt.Errorf("Date is empty")
+	}
+	date, err := time.Parse(layout, dateStr)
+	if err != nil {
+		return row, fmt.Errorf("Invalid date %q, expected format %s", dateStr, mapping.DateFormat)
+	}
+	row.Date = date
+
+	switch mapping.SignConvention {
+	case CSV_SIGN_DEBIT_CREDIT:
+		debitStr, creditStr := field(columns.debit), field(columns.credit)
+		var debit, credit float64
+		if debitStr != "" {
+			if debit, err = ParseStatementAmount(debitStr, mapping.DecimalSeparator); err != nil {
+				return row, err
+			}
+		}
+		if creditStr != "" {
+			if credit, err = ParseStatementAmount(creditStr, mapping.DecimalSeparator); err != nil {
+				return row, err
+			}
+		}
+		row.Amount = math.Abs(credit) - math.Abs(debit)
+	default:
+		amountStr := field(columns.amount)
+		if amountStr == "" {
+			return row, fmt.Errorf("Amount is empty")
+		}
+		if row.Amount, err = ParseStatementAmount(amountStr, mapping.DecimalSeparator); err != nil {
+			return row, err
+		}
+		if mapping.SignConvention == CSV_SIGN_POSITIVE_EXPENSE {
+			row.Amount = -row.Amount
+		}
+	}
+	if IsFloatZero(math.Abs(row.Amount)) {
+		return row, fmt.Errorf("Amount is zero")
+	}
+
+	row.Currency = mapping.Currency
+	if c := strings.ToUpper(field(columns.currency)); c != "" {
+		row.Currency = c
+	}
+	row.Description = field(columns.description)
+
+	return row, nil
+}
+
+// ParseStatementAmount parses bank formatted amounts such as "1.234,56",
+// "-1,234.56", "(12.00)", "12.00-" or "€ 12,50".
+func ParseStatementAmount(raw string, decimalSeparator string) (float64, error) {
+	s := strings.TrimSpace(raw)
+	negative := false
+	if strings.HasPrefix(s, "(") && strings.HasSuffix(s, ")") {
+		negative = true
+		s = s[1 : len(s)-1]
+	}
+	if strings.HasSuffix(s, "-") {
+		negative = true
+		s = strings.TrimSuffix(s, "-")
+	}
+
+	var b strings.Builder
+	for _, r := range s {
+		switch {
+		case r >= '0' && r <= '9':
+			b.WriteRune(r)
+		case r == '-':
+			negative = !negative
+		case string(r) == decimalSeparator:
+			b.WriteRune('.')
+		}
+	}
+
+	value, err := strconv.ParseFloat(b.String(), 64)
+	if err != nil {
+		return 0, fmt.Errorf("Invalid amount %q", raw)
+	}
+	if negative {
+		value = -value
+	}
+	return value, nil
+}
+
+// csvDateLayout turns YYYY-MM-DD style formats into Go layouts. Formats
+// without these tokens are treated as Go layouts already.
+func csvDateLayout(format string) string {
+	return strings.NewReplacer(
+		"YYYY", "2006",
+		"YY", "06",
+		"MM", "01",
+		"DD", "02",
+		"HH", "15",
+		"mm", "04",
+		"ss", "05",
+	).Replace(format)
+}
+
+func isBlankRecord(record []string) bool {
+	for _, f := range record {
+		if strings.TrimSpace(f) != "" {
+			return false
+		}
+	}
+	return true
+}
+
+// ParseCSVDelimiter accepts a single character or "tab".
+func ParseCSVDelimiter(s string) (rune, error) {
+	if s == "" {
+		return ',', nil
+	}
+	if strings.EqualFold(s, "tab") || s == `\t` {
+		return '\t', nil
+	}
+	if utf8.RuneCountInString(s) != 1 {
+		return 0, invalidMapping("Delimiter must be a single character")
+	}
+	r, _ := utf8.DecodeRuneInString(s)
+	return r, nil
+}
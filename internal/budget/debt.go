@@ -0,0 +1,808 @@
+package budget
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"time"
+
+	appErrors "github.com/fatali-fataliyev/budget_tracker/customErrors"
+	"github.com/fatali-fataliyev/budget_tracker/internal/contextutil"
+	"github.com/fatali-fataliyev/budget_tracker/logging"
+	"github.com/google/uuid"
+)
+
+const (
+	DEBT_SNOWBALL  = "snowball"  // extra money goes to the smallest balance first
+	DEBT_AVALANCHE = "avalanche" // extra money goes to the highest interest rate first
+
+	MAX_DEBT_NAME_LENGTH   = 255
+	MAX_DEBTS_PER_USER     = 100
+	MAX_DEBT_TERM_MONTHS   = 600
+	MAX_DEBT_INTEREST_RATE = 100  // percent a year
+	MAX_DEBT_SCHEDULE_ROWS = 1200 // monthly payments a schedule or plan is worked out for
+)
+
+// debtRecurrence is the payment schedule of every debt, monthly on the day of
+// its first payment.
+var debtRecurrence = Recurrence{Frequency: RECURRENCE_MONTHLY, Interval: 1}
+
+// Debt is a loan or IOU paid off in fixed monthly payments. Interest is
+// charged once per scheduled payment on the balance left.
+type Debt struct {
+	ID                 string
+	Name               string
+	Principal          float64 // amount borrowed
+	InterestRate       float64 // yearly, in percent
+	TermMonths         int
+	FirstPaymentAt     time.Time // payments are due monthly on its day
+	Currency           string
+	WalletId           string // payments are taken from it when set
+	CategoryId         string // expense category of the principal part of payments
+	InterestCategoryId string // expense category of the interest part of payments
+	Note               string
+	PaidPrincipal      float64   // filled by storage when reading
+	PaidInterest       float64   // filled by storage when reading
+	LastPaymentAt      time.Time // filled by storage when reading, zero without payments
+	CreatedAt          time.Time
+	UpdatedAt          time.Time
+	CreatedBy          string
+}
+
+type DebtRequest struct {
+	Name               string
+	Principal          float64
+	InterestRate       float64
+	TermMonths         int
+	FirstPaymentAt     time.Time
+	Currency           string // the wallet's currency when a wallet is linked
+	WalletId           string
+	CategoryId         string
+	InterestCategoryId string // CategoryId when empty
+	Note               string
+}
+
+// DebtPayment is a payment of a debt, recorded as an expense transaction
+// split into its principal and interest parts.
+type DebtPayment struct {
+	ID            string
+	DebtId        string
+	TransactionId string // empty once the transaction is deleted with its category
+	Amount        float64
+	Principal     float64
+	Interest      float64
+	OccurredAt    time.Time
+	CreatedAt     time.Time
+	CreatedBy     string
+}
+
+type DebtPaymentRequest struct {
+	Amount     float64 // the scheduled payment when zero
+	OccurredAt time.Time
+	Note       string
+}
+
+// AmortizationRow is one monthly payment of a schedule.
+type AmortizationRow struct {
+	Number    int
+	DueAt     time.Time
+	Payment   float64
+	Principal float64
+	Interest  float64
+	Balance   float64 // left after the payment
+}
+
+// DebtStatus is a debt with where it stands today.
+type DebtStatus struct {
+	Debt              Debt
+	Balance           float64
+	ScheduledPayment  float64
+	NextPaymentAt     time.Time // zero once paid off
+	PaymentsLeft      int
+	PayoffDate        time.Time // at the scheduled payment, zero once paid off
+	RemainingInterest float64
+}
+
+// DebtSchedule is the amortization table of what is left of a debt, with an
+// extra amount paid every month on top of the scheduled payment.
+type DebtSchedule struct {
+	Status        DebtStatus
+	ExtraMonthly  float64
+	Rows          []AmortizationRow
+	PayoffDate    time.Time
+	TotalInterest float64
+	InterestSaved float64 // compared with the scheduled payment alone
+	PaymentsSaved int
+}
+
+type DebtPlanRequest struct {
+	Strategy     string // DEBT_SNOWBALL or DEBT_AVALANCHE
+	ExtraMonthly float64
+	DebtIds      []string // every debt that is not paid off when empty
+}
+
+type DebtPlanItem struct {
+	Debt          Debt
+	Balance       float64
+	Months        int // until paid off
+	PayoffDate    time.Time
+	TotalInterest float64
+}
+
+// DebtPlan pays the scheduled payment of every debt each month and puts the
+// extra amount, plus the payments of debts already paid off, on one debt at a
+// time in the order of the strategy.
+type DebtPlan struct {
+	Strategy      string
+	Currency      string
+	ExtraMonthly  float64
+	Debts         []DebtPlanItem // in the order the extra money goes to
+	Months        int
+	PayoffDate    time.Time
+	TotalInterest float64
+	InterestSaved float64 // compared with paying only the scheduled payments
+	MonthsSaved   int
+}
+
+// periodInterest is the interest charged on balance for one monthly period,
+// both in cents.
+func periodInterest(balance int64, annualRate float64) int64 {
+	return int64(math.Round(float64(balance) * annualRate / 100 / 12))
+}
+
+// scheduledPayment is the fixed monthly payment that pays off principal in
+// months payments, rounded up to the cent so the last payment is the smallest.
+func scheduledPayment(principal float64, annualRate float64, months int) float64 {
+	cents := float64(toCents(principal))
+	rate := annualRate / 100 / 12
+	if rate == 0 {
+		return math.Ceil(cents/float64(months)) / 100
+	}
+	payment := cents * rate / (1 - math.Pow(1+rate, -float64(months)))
+	return math.Ceil(payment-1e-6) / 100
+}
+
+// amortize lays out the payments that pay off balance, the first one due at
+// the due date number first of d and the others monthly after it. Each
+// payment is payment plus extra, the last one only what is left.
+func amortize(d Debt, balance float64, payment float64, extra float64, first int) ([]AmortizationRow, error) {
+	left := toCents(balance)
+	pay := toCents(payment) + toCents(extra)
+
+	rows := []AmortizationRow{}
+	for k := first; left > 0; k++ {
+		if len(rows) == MAX_DEBT_SCHEDULE_ROWS {
+			return nil, appErrors.ErrorResponse{
+				Code:    appErrors.ErrInvalidInput,
+				Message: fmt.Sprintf("The debt is not paid off within %d payments, pay more each month", MAX_DEBT_SCHEDULE_ROWS),
+			}
+		}
+		interest := periodInterest(left, d.InterestRate)
+		if pay <= interest {
+			return nil, appErrors.ErrorResponse{
+				Code:    appErrors.ErrInvalidInput,
+				Message: fmt.Sprintf("A payment of %.2f does not cover the interest of %.2f, the debt would never be paid off", float64(pay)/100, float64(interest)/100),
+			}
+		}
+		amount := min(pay, left+interest)
+		left -= amount - interest
+		rows = append(rows, AmortizationRow{
+			Number:    k + 1,
+			DueAt:     debtRecurrence.occurrence(d.FirstPaymentAt, k),
+			Payment:   float64(amount) / 100,
+			Principal: float64(amount-interest) / 100,
+			Interest:  float64(interest) / 100,
+			Balance:   float64(left) / 100,
+		})
+	}
+	return rows, nil
+}
+
+// debtDueIndex is the number of the first due date of d on or after t,
+// counted from 0 at the first payment. A payment belongs to that due date.
+func debtDueIndex(d Debt, t time.Time) int {
+	due := debtRecurrence.NextAfter(d.FirstPaymentAt, t.Add(-time.Nanosecond))
+	return (due.Year()-d.FirstPaymentAt.Year())*12 + int(due.Month()) - int(d.FirstPaymentAt.Month())
+}
+
+// nextDebtDueIndex is the number of the first due date no payment covers yet.
+func nextDebtDueIndex(d Debt) int {
+	if d.LastPaymentAt.IsZero() {
+		return 0
+	}
+	return debtDueIndex(d, d.LastPaymentAt) + 1
+}
+
+func debtStatus(d Debt) (DebtStatus, []AmortizationRow, error) {
+	s := DebtStatus{
+		Debt:             d,
+		Balance:          float64(max(toCents(d.Principal)-toCents(d.PaidPrincipal), 0)) / 100,
+		ScheduledPayment: scheduledPayment(d.Principal, d.InterestRate, d.TermMonths),
+	}
+	rows, err := amortize(d, s.Balance, s.ScheduledPayment, 0, nextDebtDueIndex(d))
+	if err != nil {
+		return DebtStatus{}, nil, err
+	}
+	if len(rows) > 0 {
+		s.NextPaymentAt = rows[0].DueAt
+		s.PayoffDate = rows[len(rows)-1].DueAt
+		s.PaymentsLeft = len(rows)
+		s.RemainingInterest = rowsInterest(rows)
+	}
+	return s, rows, nil
+}
+
+func rowsInterest(rows []AmortizationRow) float64 {
+	var total int64
+	for _, row := range rows {
+		total += toCents(row.Interest)
+	}
+	return float64(total) / 100
+}
+
+func (bt *BudgetTracker) validateDebtRequest(ctx context.Context, userId string, req *DebtRequest) error {
+	req.Name = strings.TrimSpace(req.Name)
+	if req.Name == "" {
+		return appErrors.ErrorResponse{
+			Code:    appErrors.ErrInvalidInput,
+			Message: "Debt name cannot be empty!",
+		}
+	}
+	if len(req.Name) > MAX_DEBT_NAME_LENGTH {
+		return appErrors.ErrorResponse{
+			Code:    appErrors.ErrInvalidInput,
+			Message: fmt.Sprintf("Debt name so long, maximum allowed length is %d", MAX_DEBT_NAME_LENGTH),
+		}
+	}
+	if req.Principal <= 0 || IsFloatZero(req.Principal) {
+		return appErrors.ErrorResponse{
+			Code:    appErrors.ErrInvalidInput,
+			Message: "Debt principal must be greater than zero",
+		}
+	}
+	if req.Principal > MAX_TRANSACTION_AMOUNT_LIMIT {
+		return appErrors.ErrorResponse{
+			Code:    appErrors.ErrInvalidInput,
+			Message: fmt.Sprintf("Maximum allowed principal is %d", MAX_TRANSACTION_AMOUNT_LIMIT),
+		}
+	}
+	if req.InterestRate < 0 || req.InterestRate > MAX_DEBT_INTEREST_RATE {
+		return appErrors.ErrorResponse{
+			Code:    appErrors.ErrInvalidInput,
+			Message: fmt.Sprintf("Interest rate must be between 0 and %d percent a year", MAX_DEBT_INTEREST_RATE),
+		}
+	}
+	if req.TermMonths < 1 || req.TermMonths > MAX_DEBT_TERM_MONTHS {
+		return appErrors.ErrorResponse{
+			Code:    appErrors.ErrInvalidInput,
+			Message: fmt.Sprintf("Term must be between 1 and %d months", MAX_DEBT_TERM_MONTHS),
+		}
+	}
+	if req.FirstPaymentAt.IsZero() {
+		return appErrors.ErrorResponse{
+			Code:    appErrors.ErrInvalidInput,
+			Message: "First payment date is required",
+		}
+	}
+	if len(req.Note) > MAX_TRANSACTION_NOTE_LENGTH {
+		return appErrors.ErrorResponse{
+			Code:    appErrors.ErrInvalidInput,
+			Message: fmt.Sprintf("Note so long, maximum allowed note length is %d", MAX_TRANSACTION_NOTE_LENGTH),
+		}
+	}
+
+	if req.CategoryId == "" {
+		return appErrors.ErrorResponse{
+			Code:    appErrors.ErrInvalidInput,
+			Message: "Category ID cannot be empty, payments are recorded as expenses in it!",
+		}
+	}
+	if req.InterestCategoryId == "" {
+		req.InterestCategoryId = req.CategoryId
+	}
+	parents, err := bt.storage.GetCategoryParents(ctx, userId, "-")
+	if err != nil {
+		return err
+	}
+	for _, id := range []string{req.CategoryId, req.InterestCategoryId} {
+		if _, ok := parents[id]; !ok {
+			return appErrors.ErrorResponse{
+				Code:    appErrors.ErrNotFound,
+				Message: fmt.Sprintf("The expense category %s does not exist.", id),
+			}
+		}
+	}
+	return nil
+}
+
+func (bt *BudgetTracker) SaveDebt(ctx context.Context, userId string, req DebtRequest) (DebtStatus, error) {
+	traceID := contextutil.TraceIDFromContext(ctx)
+
+	if err := bt.validateDebtRequest(ctx, userId, &req); err != nil {
+		return DebtStatus{}, err
+	}
+	currency, err := bt.linkedCurrency(ctx, userId, req.WalletId, req.Currency, "debt")
+	if err != nil {
+		return DebtStatus{}, err
+	}
+
+	existing, err := bt.storage.GetDebts(ctx, userId)
+	if err != nil {
+		logging.Logger.Errorf("[TraceID=%s] | storage.GetDebts() failed in Service.SaveDebt()", traceID)
+		return DebtStatus{}, err
+	}
+	if len(existing) >= MAX_DEBTS_PER_USER {
+		return DebtStatus{}, appErrors.ErrorResponse{
+			Code:    appErrors.ErrInvalidInput,
+			Message: fmt.Sprintf("Maximum %d debts are allowed", MAX_DEBTS_PER_USER),
+		}
+	}
+
+	now := time.Now().UTC()
+	d := Debt{
+		ID:                 uuid.New().String(),
+		Name:               req.Name,
+		Principal:          req.Principal,
+		InterestRate:       req.InterestRate,
+		TermMonths:         req.TermMonths,
+		FirstPaymentAt:     req.FirstPaymentAt.UTC(),
+		Currency:           currency,
+		WalletId:           req.WalletId,
+		CategoryId:         req.CategoryId,
+		InterestCategoryId: req.InterestCategoryId,
+		Note:               req.Note,
+		CreatedAt:          now,
+		UpdatedAt:          now,
+		CreatedBy:          userId,
+	}
+	if err := bt.storage.SaveDebt(ctx, d); err != nil {
+		logging.Logger.Errorf("[TraceID=%s] | storage.SaveDebt() failed in Service.SaveDebt()", traceID)
+		return DebtStatus{}, err
+	}
+	s, _, err := debtStatus(d)
+	return s, err
+}
+
+func (bt *BudgetTracker) GetDebts(ctx context.Context, userId string) ([]DebtStatus, error) {
+	debts, err := bt.storage.GetDebts(ctx, userId)
+	if err != nil {
+		return nil, err
+	}
+	statuses := make([]DebtStatus, 0, len(debts))
+	for _, d := range debts {
+		s, _, err := debtStatus(d)
+		if err != nil {
+			return nil, err
+		}
+		statuses = append(statuses, s)
+	}
+	return statuses, nil
+}
+
+func (bt *BudgetTracker) GetDebt(ctx context.Context, userId string, id string) (DebtStatus, error) {
+	d, err := bt.storage.GetDebtById(ctx, userId, id)
+	if err != nil {
+		return DebtStatus{}, err
+	}
+	s, _, err := debtStatus(d)
+	return s, err
+}
+
+// UpdateDebt changes the terms of a debt, its payments stay. The currency is
+// fixed once the debt exists and the principal cannot go below what is
+// already paid of it.
+func (bt *BudgetTracker) UpdateDebt(ctx context.Context, userId string, id string, req DebtRequest) (DebtStatus, error) {
+	d, err := bt.storage.GetDebtById(ctx, userId, id)
+	if err != nil {
+		return DebtStatus{}, err
+	}
+	if err := bt.validateDebtRequest(ctx, userId, &req); err != nil {
+		return DebtStatus{}, err
+	}
+	if req.Currency == "" {
+		req.Currency = d.Currency
+	}
+	currency, err := bt.linkedCurrency(ctx, userId, req.WalletId, req.Currency, "debt")
+	if err != nil {
+		return DebtStatus{}, err
+	}
+	if currency != d.Currency {
+		return DebtStatus{}, appErrors.ErrorResponse{
+			Code:    appErrors.ErrInvalidInput,
+			Message: "The currency of a debt cannot be changed",
+		}
+	}
+	if toCents(req.Principal) < toCents(d.PaidPrincipal) {
+		return DebtStatus{}, appErrors.ErrorResponse{
+			Code:    appErrors.ErrInvalidInput,
+			Message: fmt.Sprintf("The principal cannot be less than the %.2f already paid", d.PaidPrincipal),
+		}
+	}
+
+	d.Name = req.Name
+	d.Principal = req.Principal
+	d.InterestRate = req.InterestRate
+	d.TermMonths = req.TermMonths
+	d.FirstPaymentAt = req.FirstPaymentAt.UTC()
+	d.WalletId = req.WalletId
+	d.CategoryId = req.CategoryId
+	d.InterestCategoryId = req.InterestCategoryId
+	d.Note = req.Note
+	d.UpdatedAt = time.Now().UTC()
+	if err := bt.storage.UpdateDebt(ctx, d); err != nil {
+		return DebtStatus{}, err
+	}
+	s, _, err := debtStatus(d)
+	return s, err
+}
+
+// DeleteDebt deletes a debt with its payment records. The expense
+// transactions of the payments stay, the money was spent.
+func (bt *BudgetTracker) DeleteDebt(ctx context.Context, userId string, id string) error {
+	return bt.storage.DeleteDebt(ctx, userId, id)
+}
+
+// AddDebtPayment records a payment as an expense transaction split into
+// principal and interest. A payment pays the interest of every due date up to
+// the first one on or after it that no earlier payment covers, the rest goes
+// to the principal. A payment can be at most the balance plus that interest.
+func (bt *BudgetTracker) AddDebtPayment(ctx context.Context, userId string, debtId string, req DebtPaymentRequest) (DebtPayment, DebtStatus, error) {
+	traceID := contextutil.TraceIDFromContext(ctx)
+
+	if req.Amount < 0 || req.Amount > MAX_TRANSACTION_AMOUNT_LIMIT {
+		return DebtPayment{}, DebtStatus{}, appErrors.ErrorResponse{
+			Code:    appErrors.ErrInvalidInput,
+			Message: fmt.Sprintf("Payment amount must be between 0 and %d", MAX_TRANSACTION_AMOUNT_LIMIT),
+		}
+	}
+	if req.OccurredAt.After(time.Now().Add(MAX_OCCURRED_AT_AHEAD)) {
+		return DebtPayment{}, DebtStatus{}, appErrors.ErrorResponse{
+			Code:    appErrors.ErrInvalidInput,
+			Message: "Payment date cannot be in the future",
+		}
+	}
+
+	d, err := bt.storage.GetDebtById(ctx, userId, debtId)
+	if err != nil {
+		return DebtPayment{}, DebtStatus{}, err
+	}
+	if d.CategoryId == "" || d.InterestCategoryId == "" {
+		return DebtPayment{}, DebtStatus{}, appErrors.ErrorResponse{
+			Code:    appErrors.ErrInvalidInput,
+			Message: "The category of the debt was deleted, update the debt before adding payments.",
+		}
+	}
+	status, _, err := debtStatus(d)
+	if err != nil {
+		return DebtPayment{}, DebtStatus{}, err
+	}
+	balance := toCents(status.Balance)
+	if balance == 0 {
+		return DebtPayment{}, DebtStatus{}, appErrors.ErrorResponse{
+			Code:    appErrors.ErrConflict,
+			Message: "The debt is already paid off.",
+		}
+	}
+
+	now := time.Now().UTC()
+	occurredAt := now
+	if !req.OccurredAt.IsZero() {
+		occurredAt = req.OccurredAt.UTC()
+	}
+	periods := max(debtDueIndex(d, occurredAt)-nextDebtDueIndex(d)+1, 0)
+	interest := periodInterest(balance, d.InterestRate) * int64(periods)
+
+	amount := toCents(req.Amount)
+	if amount == 0 {
+		amount = min(toCents(status.ScheduledPayment), balance+interest)
+	}
+	if amount > balance+interest {
+		return DebtPayment{}, DebtStatus{}, appErrors.ErrorResponse{
+			Code:    appErrors.ErrInvalidInput,
+			Message: fmt.Sprintf("A payment of %.2f is more than the payoff amount of %.2f", float64(amount)/100, float64(balance+interest)/100),
+		}
+	}
+	interest = min(interest, amount)
+	principal := amount - interest
+
+	note := req.Note
+	if note == "" {
+		note = "Payment of " + d.Name
+	}
+	transaction := TransactionRequest{
+		CategoryId:   d.CategoryId,
+		CategoryType: "-",
+		Amount:       float64(amount) / 100,
+		Currency:     d.Currency,
+		Note:         note,
+		OccurredAt:   occurredAt,
+		WalletId:     d.WalletId,
+	}
+	switch {
+	case principal > 0 && interest > 0:
+		transaction.CategoryId = ""
+		transaction.Splits = []TransactionSplitRequest{
+			{CategoryId: d.CategoryId, Amount: float64(principal) / 100, Note: "Principal"},
+			{CategoryId: d.InterestCategoryId, Amount: float64(interest) / 100, Note: "Interest"},
+		}
+	case interest > 0:
+		transaction.CategoryId = d.InterestCategoryId
+	}
+	if err := validateTransactionRequest(transaction); err != nil {
+		return DebtPayment{}, DebtStatus{}, err
+	}
+	if err := bt.resolveTransactionWallets(ctx, userId, &transaction); err != nil {
+		return DebtPayment{}, DebtStatus{}, err
+	}
+
+	splits := newTransactionSplits(transaction.Splits)
+	categoryId := transaction.CategoryId
+	if len(splits) > 0 {
+		categoryId = splits[0].CategoryId
+	}
+	txn := Transaction{
+		ID:           uuid.New().String(),
+		CategoryId:   categoryId,
+		CategoryType: transaction.CategoryType,
+		Amount:       transaction.Amount,
+		Currency:     transaction.Currency,
+		OccurredAt:   occurredAt,
+		CreatedAt:    now,
+		Note:         transaction.Note,
+		CreatedBy:    userId,
+		Splits:       splits,
+		WalletId:     transaction.WalletId,
+	}
+	payment := DebtPayment{
+		ID:            uuid.New().String(),
+		DebtId:        d.ID,
+		TransactionId: txn.ID,
+		Amount:        float64(amount) / 100,
+		Principal:     float64(principal) / 100,
+		Interest:      float64(interest) / 100,
+		OccurredAt:    occurredAt,
+		CreatedAt:     now,
+		CreatedBy:     userId,
+	}
+	if err := bt.storage.SaveDebtPayment(ctx, payment, txn); err != nil {
+		logging.Logger.Errorf("[TraceID=%s] | storage.SaveDebtPayment() failed in Service.AddDebtPayment()", traceID)
+		return DebtPayment{}, DebtStatus{}, err
+	}
+
+	d.PaidPrincipal = float64(toCents(d.PaidPrincipal)+principal) / 100
+	d.PaidInterest = float64(toCents(d.PaidInterest)+interest) / 100
+	if occurredAt.After(d.LastPaymentAt) {
+		d.LastPaymentAt = occurredAt
+	}
+	status, _, err = debtStatus(d)
+	if err != nil {
+		return DebtPayment{}, DebtStatus{}, err
+	}
+	return payment, status, nil
+}
+
+// GetDebtPayments returns the payments of a debt, oldest first.
+func (bt *BudgetTracker) GetDebtPayments(ctx context.Context, userId string, debtId string) ([]DebtPayment, error) {
+	if _, err := bt.storage.GetDebtById(ctx, userId, debtId); err != nil {
+		return nil, err
+	}
+	return bt.storage.GetDebtPayments(ctx, userId, debtId)
+}
+
+// GetDebtSchedule returns the amortization table of the balance left, from
+// the first due date no payment covers yet, with extraMonthly paid on top of
+// every scheduled payment.
+func (bt *BudgetTracker) GetDebtSchedule(ctx context.Context, userId string, debtId string, extraMonthly float64) (DebtSchedule, error) {
+	if extraMonthly < 0 || extraMonthly > MAX_TRANSACTION_AMOUNT_LIMIT {
+		return DebtSchedule{}, appErrors.ErrorResponse{
+			Code:    appErrors.ErrInvalidInput,
+			Message: fmt.Sprintf("Extra monthly payment must be between 0 and %d", MAX_TRANSACTION_AMOUNT_LIMIT),
+		}
+	}
+
+	d, err := bt.storage.GetDebtById(ctx, userId, debtId)
+	if err != nil {
+		return DebtSchedule{}, err
+	}
+	status, base, err := debtStatus(d)
+	if err != nil {
+		return DebtSchedule{}, err
+	}
+	rows, err := amortize(d, status.Balance, status.ScheduledPayment, extraMonthly, nextDebtDueIndex(d))
+	if err != nil {
+		return DebtSchedule{}, err
+	}
+
+	schedule := DebtSchedule{
+		Status:        status,
+		ExtraMonthly:  extraMonthly,
+		Rows:          rows,
+		TotalInterest: rowsInterest(rows),
+		PaymentsSaved: len(base) - len(rows),
+	}
+	schedule.InterestSaved = float64(toCents(status.RemainingInterest)-toCents(schedule.TotalInterest)) / 100
+	if len(rows) > 0 {
+		schedule.PayoffDate = rows[len(rows)-1].DueAt
+	}
+	return schedule, nil
+}
+
+// PlanDebtPayoff projects paying off several debts of one currency with the
+// snowball or avalanche strategy.
+func (bt *BudgetTracker) PlanDebtPayoff(ctx context.Context, userId string, req DebtPlanRequest) (DebtPlan, error) {
+	traceID := contextutil.TraceIDFromContext(ctx)
+
+	if req.Strategy == "" {
+		req.Strategy = DEBT_AVALANCHE
+	}
+	if req.Strategy != DEBT_SNOWBALL && req.Strategy != DEBT_AVALANCHE {
+		return DebtPlan{}, appErrors.ErrorResponse{
+			Code:    appErrors.ErrInvalidInput,
+			Message: fmt.Sprintf("Strategy must be %s or %s", DEBT_SNOWBALL, DEBT_AVALANCHE),
+		}
+	}
+	if req.ExtraMonthly < 0 || req.ExtraMonthly > MAX_TRANSACTION_AMOUNT_LIMIT {
+		return DebtPlan{}, appErrors.ErrorResponse{
+			Code:    appErrors.ErrInvalidInput,
+			Message: fmt.Sprintf("Extra monthly payment must be between 0 and %d", MAX_TRANSACTION_AMOUNT_LIMIT),
+		}
+	}
+
+	debts, err := bt.storage.GetDebts(ctx, userId)
+	if err != nil {
+		logging.Logger.Errorf("[TraceID=%s] | storage.GetDebts() failed in Service.PlanDebtPayoff()", traceID)
+		return DebtPlan{}, err
+	}
+	wanted := map[string]bool{}
+	for _, id := range req.DebtIds {
+		wanted[id] = true
+	}
+
+	var statuses []DebtStatus
+	for _, d := range debts {
+		if len(wanted) > 0 && !wanted[d.ID] {
+			continue
+		}
+		delete(wanted, d.ID)
+		s, _, err := debtStatus(d)
+		if err != nil {
+			return DebtPlan{}, err
+		}
+		if toCents(s.Balance) > 0 {
+			statuses = append(statuses, s)
+		}
+	}
+	for id := range wanted {
+		return DebtPlan{}, appErrors.ErrorResponse{
+			Code:    appErrors.ErrNotFound,
+			Message: fmt.Sprintf("The debt %s does not exist.", id),
+		}
+	}
+	if len(statuses) == 0 {
+		return DebtPlan{}, appErrors.ErrorResponse{
+			Code:    appErrors.ErrInvalidInput,
+			Message: "There are no debts left to pay off.",
+		}
+	}
+	for _, s := range statuses[1:] {
+		if s.Debt.Currency != statuses[0].Debt.Currency {
+			return DebtPlan{}, appErrors.ErrorResponse{
+				Code:    appErrors.ErrInvalidInput,
+				Message: "The debts are in different currencies, plan the debts of one currency at a time.",
+			}
+		}
+	}
+
+	return planDebtPayoff(statuses, req.Strategy, req.ExtraMonthly, time.Now().UTC())
+}
+
+func planDebtPayoff(statuses []DebtStatus, strategy string, extraMonthly float64, now time.Time) (DebtPlan, error) {
+	sort.SliceStable(statuses, func(i, j int) bool {
+		a, b := statuses[i], statuses[j]
+		balanceA, balanceB := toCents(a.Balance), toCents(b.Balance)
+		if strategy == DEBT_SNOWBALL && balanceA != balanceB {
+			return balanceA < balanceB
+		}
+		if a.Debt.InterestRate != b.Debt.InterestRate {
+			return a.Debt.InterestRate > b.Debt.InterestRate
+		}
+		if balanceA != balanceB {
+			return balanceA < balanceB
+		}
+		return a.Debt.Name < b.Debt.Name
+	})
+
+	months, interest, err := simulateDebtPayoff(statuses, toCents(extraMonthly), true)
+	if err != nil {
+		return DebtPlan{}, err
+	}
+	baseMonths, baseInterest, err := simulateDebtPayoff(statuses, 0, false)
+	if err != nil {
+		return DebtPlan{}, err
+	}
+
+	plan := DebtPlan{
+		Strategy:     strategy,
+		Currency:     statuses[0].Debt.Currency,
+		ExtraMonthly: extraMonthly,
+		Debts:        make([]DebtPlanItem, 0, len(statuses)),
+	}
+	var totalInterest, totalBaseInterest int64
+	baseLongest := 0
+	for i, s := range statuses {
+		plan.Debts = append(plan.Debts, DebtPlanItem{
+			Debt:          s.Debt,
+			Balance:       s.Balance,
+			Months:        months[i],
+			PayoffDate:    now.AddDate(0, months[i], 0),
+			TotalInterest: float64(interest[i]) / 100,
+		})
+		plan.Months = max(plan.Months, months[i])
+		baseLongest = max(baseLongest, baseMonths[i])
+		totalInterest += interest[i]
+		totalBaseInterest += baseInterest[i]
+	}
+	plan.PayoffDate = now.AddDate(0, plan.Months, 0)
+	plan.TotalInterest = float64(totalInterest) / 100
+	plan.InterestSaved = float64(totalBaseInterest-totalInterest) / 100
+	plan.MonthsSaved = baseLongest - plan.Months
+	return plan, nil
+}
+
+// simulateDebtPayoff pays the debts month by month, in the given order, and
+// returns the months each takes and the interest paid on it. Every debt gets
+// its scheduled payment; extra goes to the first debt not paid off yet and,
+// with rollover, so do the scheduled payments of debts already paid off.
+func simulateDebtPayoff(statuses []DebtStatus, extra int64, rollover bool) ([]int, []int64, error) {
+	left := make([]int64, len(statuses))
+	for i, s := range statuses {
+		left[i] = toCents(s.Balance)
+	}
+	months := make([]int, len(statuses))
+	interest := make([]int64, len(statuses))
+
+	open := len(statuses)
+	for month := 1; open > 0; month++ {
+		if month > MAX_DEBT_SCHEDULE_ROWS {
+			return nil, nil, appErrors.ErrorResponse{
+				Code:    appErrors.ErrInvalidInput,
+				Message: fmt.Sprintf("The debts are not paid off within %d months, pay more each month", MAX_DEBT_SCHEDULE_ROWS),
+			}
+		}
+
+		pool := extra
+		for i, s := range statuses {
+			payment := toCents(s.ScheduledPayment)
+			if left[i] == 0 {
+				if rollover {
+					pool += payment
+				}
+				continue
+			}
+			charged := periodInterest(left[i], s.Debt.InterestRate)
+			interest[i] += charged
+			left[i] += charged
+			paid := min(payment, left[i])
+			left[i] -= paid
+			if rollover {
+				pool += payment - paid
+			}
+		}
+		for i := range statuses {
+			if left[i] > 0 && pool > 0 {
+				paid := min(pool, left[i])
+				left[i] -= paid
+				pool -= paid
+			}
+		}
+		for i := range statuses {
+			if left[i] == 0 && months[i] == 0 {
+				months[i] = month
+				open--
+			}
+		}
+	}
+	return months, interest, nil
+}
@@ -0,0 +1,376 @@
+package budget
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+
+	appErrors "github.com/fatali-fataliyev/budget_tracker/customErrors"
+	"github.com/fatali-fataliyev/budget_tracker/internal/contextutil"
+	"github.com/fatali-fataliyev/budget_tracker/logging"
+)
+
+const (
+	JOURNAL_FORMAT_BEANCOUNT = "beancount"
+	JOURNAL_FORMAT_LEDGER    = "ledger" // also read by hledger
+
+	DEFAULT_JOURNAL_ASSETS_ACCOUNT = "Assets:Cash"
+)
+
+var currencySymbols = map[string]string{
+	"$": "USD",
+	"€": "EUR",
+	"£": "GBP",
+	"₼": "AZN",
+	"¥": "JPY",
+	"₺": "TRY",
+	"₽": "RUB",
+	"₹": "INR",
+}
+
+type JournalOptions struct {
+	Format        string
+	AssetsAccount string // the other side of every posting, Assets:Cash when empty
+}
+
+type journalEntry struct {
+	date      time.Time
+	id        string
+	narration string
+	postings  []journalPosting // one per split, balanced by a single posting to balance
+	balance   string           // the wallet's account, or the default assets account
+	currency  string
+}
+
+type journalPosting struct {
+	account string
+	amount  float64 // signed as posted to account
+}
+
+// ExportJournal writes the user's transactions as a plain-text accounting
+// journal. Expense categories become Expenses:<Name> and income categories
+// Income:<Name>, every transaction is balanced against the account of its
+// wallet, or opts.AssetsAccount when it has none.
+func (bt *BudgetTracker) ExportJournal(ctx context.Context, userId string, opts JournalOptions, w io.Writer) error {
+	traceID := contextutil.TraceIDFromContext(ctx)
+
+	if opts.Format != JOURNAL_FORMAT_BEANCOUNT && opts.Format != JOURNAL_FORMAT_LEDGER {
+		return appErrors.ErrorResponse{
+			Code:    appErrors.ErrInvalidInput,
+			Message: "Invalid journal format, allowed values: beancount, ledger",
+		}
+	}
+	if opts.AssetsAccount == "" {
+		opts.AssetsAccount = DEFAULT_JOURNAL_ASSETS_ACCOUNT
+	}
+	assets, ok := journalAccount(opts.AssetsAccount)
+	if !ok {
+		return appErrors.ErrorResponse{
+			Code:    appErrors.ErrInvalidInput,
+			Message: "Invalid assets account, use a name like Assets:Checking",
+		}
+	}
+
+	data, err := bt.storage.GetUserData(ctx, userId)
+	if err != nil {
+		logging.Logger.Errorf("[TraceID=%s] | storage.GetUserData() failed in Service.ExportJournal()", traceID)
+		return err
+	}
+
+	return WriteJournal(w, opts.Format, assets, data)
+}
+
+// WriteJournal renders data in the given format, see ExportJournal.
+func WriteJournal(w io.Writer, format string, assetsAccount string, data UserDataResponse) error {
+	names := newJournalNames()
+	accountsByCategory := make(map[string]string, len(data.ExpenseCategories)+len(data.IncomeCategories))
+	opened := map[string]time.Time{}
+
+	open := func(account string, at time.Time) {
+		if at.IsZero() {
+			return
+		}
+		at = at.UTC()
+		if first, ok := opened[account]; !ok || at.Before(first) {
+			opened[account] = at
+		}
+	}
+
+	for _, c := range data.ExpenseCategories {
+		account := names.unique("Expenses", c.Name)
+		accountsByCategory["-"+c.ID] = account
+		open(account, c.CreatedAt)
+	}
+	for _, c := range data.IncomeCategories {
+		account := names.unique("Income", c.Name)
+		accountsByCategory["+"+c.ID] = account
+		open(account, c.CreatedAt)
+	}
+	accountsByWallet := make(map[string]string, len(data.Wallets))
+	for _, wallet := range data.Wallets {
+		root := "Assets"
+		if wallet.Type == WALLET_CREDIT_CARD {
+			root = "Liabilities"
+		}
+		account := names.unique(root, wallet.Name)
+		accountsByWallet[wallet.ID] = account
+		open(account, wallet.CreatedAt)
+	}
+	walletAccount := func(id string) string {
+		if account, ok := accountsByWallet[id]; ok {
+			return account
+		}
+		return assetsAccount
+	}
+
+	entries := make([]journalEntry, 0, len(data.Transactions))
+	currencyUse := map[string]int{}
+	for _, t := range data.Transactions {
+		currency := journalCurrency(t.Currency)
+		currencyUse[currency]++
+
+		entry := journalEntry{
+			date:      t.OccurredAt.UTC(),
+			id:        t.ID,
+			narration: t.Note,
+			balance:   walletAccount(t.WalletId),
+			currency:  currency,
+		}
+		if t.CategoryType == TRANSFER_TYPE {
+			account := walletAccount(t.ToWalletId)
+			entry.postings = append(entry.postings, journalPosting{account: account, amount: t.Amount})
+			entries = append(entries, entry)
+			open(account, t.OccurredAt.UTC())
+			open(entry.balance, t.OccurredAt.UTC())
+			continue
+		}
+		for _, line := range expandSplits(t) {
+			account, ok := accountsByCategory[line.CategoryType+line.CategoryId]
+			if !ok {
+				// the category was deleted or renamed after the transaction was exported
+				root := "Expenses"
+				if line.CategoryType == "+" {
+					root = "Income"
+				}
+				account = names.unique(root, line.CategoryName)
+				accountsByCategory[line.CategoryType+line.CategoryId] = account
+			}
+
+			amount := line.Amount
+			if line.CategoryType == "+" {
+				amount = -amount
+			}
+			entry.postings = append(entry.postings, journalPosting{account: account, amount: amount})
+			open(account, t.OccurredAt.UTC())
+		}
+		entries = append(entries, entry)
+		open(entry.balance, t.OccurredAt.UTC())
+	}
+	if _, ok := opened[assetsAccount]; !ok {
+		open(assetsAccount, time.Now().UTC())
+	}
+
+	sort.SliceStable(entries, func(i, j int) bool { return entries[i].date.Before(entries[j].date) })
+
+	accounts := make([]string, 0, len(opened))
+	for account := range opened {
+		accounts = append(accounts, account)
+	}
+	sort.Strings(accounts)
+
+	bw := bufio.NewWriter(w)
+	if format == JOURNAL_FORMAT_BEANCOUNT {
+		writeBeancount(bw, accounts, opened, entries, mostUsed(currencyUse))
+	} else {
+		writeLedger(bw, accounts, entries)
+	}
+	return bw.Flush()
+}
+
+func writeBeancount(w *bufio.Writer, accounts []string, opened map[string]time.Time, entries []journalEntry, operatingCurrency string) {
+	fmt.Fprintf(w, "; Budget Tracker export, %s\n\n", time.Now().UTC().Format("2006-01-02"))
+	fmt.Fprintf(w, "option \"title\" \"Budget Tracker\"\n")
+	if operatingCurrency != "" {
+		fmt.Fprintf(w, "option \"operating_currency\" \"%s\"\n", operatingCurrency)
+	}
+	w.WriteString("\n")
+
+	for _, account := range accounts {
+		fmt.Fprintf(w, "%s open %s\n", opened[account].Format("2006-01-02"), account)
+	}
+
+	for _, e := range entries {
+		fmt.Fprintf(w, "\n%s * %s\n", e.date.Format("2006-01-02"), beancountString(e.narration))
+		fmt.Fprintf(w, "  id: %s\n", beancountString(e.id))
+		var total float64
+		for _, p := range e.postings {
+			fmt.Fprintf(w, "  %s  %s %s\n", p.account, journalAmount(p.amount), e.currency)
+			total += p.amount
+		}
+		fmt.Fprintf(w, "  %s  %s %s\n", e.balance, journalAmount(-total), e.currency)
+	}
+}
+
+func writeLedger(w *bufio.Writer, accounts []string, entries []journalEntry) {
+	fmt.Fprintf(w, "; Budget Tracker export, %s\n\n", time.Now().UTC().Format("2006-01-02"))
+
+	for _, account := range accounts {
+		fmt.Fprintf(w, "account %s\n", account)
+	}
+
+	for _, e := range entries {
+		// a leading * or ! would be read as the cleared/pending mark
+		payee := strings.TrimLeft(strings.Join(strings.Fields(e.narration), " "), "*! ")
+		if payee == "" {
+			payee = "Transaction"
+		}
+		currency := ledgerCommodity(e.currency)
+		fmt.Fprintf(w, "\n%s %s\n", e.date.Format("2006/01/02"), payee)
+		fmt.Fprintf(w, "    ; id: %s\n", e.id)
+		var total float64
+		for _, p := range e.postings {
+			fmt.Fprintf(w, "    %s  %s %s\n", p.account, journalAmount(p.amount), currency)
+			total += p.amount
+		}
+		fmt.Fprintf(w, "    %s  %s %s\n", e.balance, journalAmount(-total), currency)
+	}
+}
+
+type journalNames struct {
+	used  map[string]bool
+	byKey map[string]string
+}
+
+func newJournalNames() *journalNames {
+	return &journalNames{used: map[string]bool{}, byKey: map[string]string{}}
+}
+
+// unique maps a category name to an account under root. Names that only
+// differ in punctuation ("home repair", "home-repair") get a numeric suffix.
+func (n *journalNames) unique(root string, name string) string {
+	key := root + "\x00" + name
+	if account, ok := n.byKey[key]; ok {
+		return account
+	}
+
+	base := root + ":" + journalComponent(name)
+	account := base
+	for i := 2; n.used[account]; i++ {
+		account = base + "-" + strconv.Itoa(i)
+	}
+	n.used[account] = true
+	n.byKey[key] = account
+	return account
+}
+
+// journalComponent turns a category name into an account component both
+// beancount and ledger accept: "home repair" becomes "Home-Repair".
+func journalComponent(name string) string {
+	var b strings.Builder
+	upperNext := true
+	for _, r := range strings.TrimSpace(name) {
+		switch {
+		case unicode.IsLetter(r) || unicode.IsDigit(r):
+			if upperNext {
+				r = unicode.ToUpper(r)
+				upperNext = false
+			}
+			b.WriteRune(r)
+		default:
+			if b.Len() > 0 && !strings.HasSuffix(b.String(), "-") {
+				b.WriteRune('-')
+			}
+			upperNext = true
+		}
+	}
+
+	component := strings.TrimSuffix(b.String(), "-")
+	if component == "" {
+		return "Uncategorized"
+	}
+	first := []rune(component)[0]
+	if !unicode.IsUpper(first) && !unicode.IsDigit(first) {
+		// scripts without case, e.g. CJK, still need an uppercase start
+		component = "X-" + component
+	}
+	return component
+}
+
+// journalAccount validates a user supplied account such as Assets:Bank.
+func journalAccount(account string) (string, bool) {
+	parts := strings.Split(account, ":")
+	if len(parts) < 2 {
+		return "", false
+	}
+	switch parts[0] {
+	case "Assets", "Liabilities", "Equity":
+	default:
+		return "", false
+	}
+	for i := 1; i < len(parts); i++ {
+		if parts[i] == "" {
+			return "", false
+		}
+		parts[i] = journalComponent(parts[i])
+	}
+	return strings.Join(parts, ":"), true
+}
+
+// journalCurrency returns a commodity name valid in beancount: 2-24 chars,
+// uppercase letters and digits, starting with a letter.
+func journalCurrency(currency string) string {
+	currency = strings.TrimSpace(currency)
+	if iso, ok := currencySymbols[currency]; ok {
+		return iso
+	}
+
+	var b strings.Builder
+	for _, r := range strings.ToUpper(currency) {
+		if (r >= 'A' && r <= 'Z') || (r >= '0' && r <= '9') {
+			b.WriteRune(r)
+		}
+	}
+	c := b.String()
+	if len(c) > 24 {
+		c = c[:24]
+	}
+	if len(c) < 2 || c[0] < 'A' || c[0] > 'Z' {
+		return "XXX" // ISO 4217 "no currency"
+	}
+	return c
+}
+
+// ledgerCommodity quotes commodities that contain digits, ledger would
+// read them as part of the amount otherwise.
+func ledgerCommodity(currency string) string {
+	if strings.ContainsAny(currency, "0123456789") {
+		return strconv.Quote(currency)
+	}
+	return currency
+}
+
+func journalAmount(amount float64) string {
+	return strconv.FormatFloat(amount, 'f', 2, 64)
+}
+
+func beancountString(s string) string {
+	s = strings.Join(strings.Fields(s), " ")
+	s = strings.ReplaceAll(s, `\`, `\\`)
+	return `"` + strings.ReplaceAll(s, `"`, `\"`) + `"`
+}
+
+func mostUsed(counts map[string]int) string {
+	best, bestCount := "", 0
+	for k, c := range counts {
+		if c > bestCount || (c == bestCount && k < best) {
+			best, bestCount = k, c
+		}
+	}
+	return best
+}
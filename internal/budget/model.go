@@ -11,6 +11,7 @@ type ExpenseCategoryRequest struct {
 	PeriodDay int
 	Note      string
 	Type      string
+	ParentId  string // optional, makes it a subcategory
 }
 
 type IncomeCategoryRequest struct {
@@ -18,6 +19,7 @@ type IncomeCategoryRequest struct {
 	TargetAmount int
 	Note         string
 	Type         string
+	ParentId     string // optional, makes it a subcategory
 }
 
 type TransactionRequest struct {
@@ -26,6 +28,20 @@ type TransactionRequest struct {
 	Amount       float64
 	Currency     string
 	Note         string
+	OccurredAt   time.Time // when the money moved, now when zero
+	Splits       []TransactionSplitRequest
+	WalletId     string   // optional, required on transfers
+	ToWalletId   string   // transfers only
+	Tags         []string // tag names, missing tags are created
+	PayeeId      string   // optional, matched from the note when empty
+}
+
+// TransactionSplitRequest is one line of a split transaction. Lines share the
+// parent's type, currency and date, and their amounts add up to its total.
+type TransactionSplitRequest struct {
+	CategoryId string
+	Amount     float64
+	Note       string
 }
 
 type UpdateExpenseCategoryRequest struct {
@@ -50,15 +66,17 @@ type UpdateIncomeCategoryRequest struct {
 // MODELS:
 
 type ExpenseCategory struct {
-	ID        string
-	Name      string
-	MaxAmount float64
-	PeriodDay int
-	CreatedAt time.Time
-	UpdatedAt time.Time
-	Note      string
-	CreatedBy string
-	Type      string
+	ID         string
+	Name       string
+	MaxAmount  float64
+	PeriodDay  int
+	CreatedAt  time.Time
+	UpdatedAt  time.Time
+	Note       string
+	CreatedBy  string
+	Type       string
+	ParentId   string
+	ArchivedAt time.Time // zero unless archived
 }
 
 type IncomeCategory struct {
@@ -70,6 +88,8 @@ type IncomeCategory struct {
 	Note         string
 	CreatedBy    string
 	Type         string
+	ParentId     string
+	ArchivedAt   time.Time // zero unless archived
 }
 
 type Transaction struct {
@@ -79,12 +99,32 @@ type Transaction struct {
 	CategoryType string
 	Amount       float64
 	Currency     string
-	CreatedAt    time.Time
+	OccurredAt   time.Time // when the money moved, used for filters and statistics
+	CreatedAt    time.Time // when it was recorded, kept for auditing
 	Note         string
 	CreatedBy    string
+	ExternalID   string
+	Splits       []TransactionSplit `json:",omitempty"` // CategoryId is the first line's when split
+	WalletId     string             `json:",omitempty"`
+	ToWalletId   string             `json:",omitempty"` // destination of a transfer
+	Cleared      bool               // seen on a bank statement
+	ReconciledAt time.Time          // set when reconciled, the transaction is locked after that
+	Tags         []string           `json:",omitempty"` // tag names, sorted
+	PayeeId      string             `json:",omitempty"`
+}
+
+type TransactionSplit struct {
+	ID           string
+	CategoryId   string
+	CategoryName string
+	Amount       float64
+	Note         string
 }
 
 // RESPONSES:
+// ExpenseCategoryResponse of a parent category has Amount and MaxAmount
+// rolled up from its subcategories, the parent's own limit covers the
+// transactions posted to it directly.
 type ExpenseCategoryResponse struct {
 	ID           string
 	Name         string
@@ -97,6 +137,9 @@ type ExpenseCategoryResponse struct {
 	UpdatedAt    time.Time
 	Note         string
 	CreatedBy    string
+	ParentId     string                    `json:",omitempty"`
+	ArchivedAt   time.Time                 // archived categories are hidden from lists but still count in totals
+	Children     []ExpenseCategoryResponse `json:"-"` // only filled in a tree
 }
 
 type ExpenseStatsResponse struct {
@@ -115,8 +158,11 @@ type TransactionStatsResponse struct {
 	Expenses float64
 	Incomes  float64
 	Total    float64
+	Tags     []TagTotal
 }
 
+// IncomeCategoryResponse of a parent category has Amount and TargetAmount
+// rolled up from its subcategories.
 type IncomeCategoryResponse struct {
 	ID           string
 	Name         string
@@ -127,31 +173,39 @@ type IncomeCategoryResponse struct {
 	UpdatedAt    time.Time
 	Note         string
 	CreatedBy    string
+	ParentId     string                   `json:",omitempty"`
+	ArchivedAt   time.Time                // archived categories are hidden from lists but still count in totals
+	Children     []IncomeCategoryResponse `json:"-"` // only filled in a tree
 }
 
 type IncomeCategoryList struct {
-	Names        []string
-	TargetAmount float64
-	CreatedAt    time.Time
-	EndDate      time.Time
-	IsAllNil     bool
+	Names           []string
+	TargetAmount    float64
+	CreatedAt       time.Time
+	EndDate         time.Time
+	IncludeArchived bool // the service leaves archived categories out otherwise
+	IsAllNil        bool
 }
 
 type ExpenseCategoryList struct {
-	Names     []string
-	MaxAmount float64
-	PeriodDay int
-	CreatedAt time.Time
-	EndDate   time.Time
-	IsAllNil  bool
+	Names           []string
+	MaxAmount       float64
+	PeriodDay       int
+	CreatedAt       time.Time
+	EndDate         time.Time
+	IncludeArchived bool // the service leaves archived categories out otherwise
+	IsAllNil        bool
 }
 
 type TransactionList struct {
 	CategoryNames []string
 	Amount        float64
 	Currency      string
-	CreatedAt     time.Time
+	OccurredAt    time.Time // from this date on
 	Type          string
+	Tags          []string
+	TagsMode      string // TAGS_MODE_ANY or TAGS_MODE_ALL
+	PayeeIds      []string
 	IsAllNil      bool
 }
 
@@ -159,17 +213,27 @@ type ProcessedImageResponse struct {
 	Amounts       []float64
 	CurrenciesISO []string
 	CurrenciesSymbol []string
+	Lines         []TransactionSplitRequest // receipt items, ready to be categorized and sent as splits
+	Total         float64
+	Payee         string // the merchant printed on the receipt, best effort
+	PayeeId       string // set when Payee matched one of the user's payees
+	CategoryId    string   // expense category suggested by the rules or the payee
+	Tags          []string // suggested by the rules
+	Suggestions   []CategorySuggestion // expense categories learned from history, most likely first
 }
 
 type UserDataResponse struct {
 	Transactions      []Transaction
 	ExpenseCategories []ExpenseCategoryResponse
 	IncomeCategories  []IncomeCategoryResponse
+	Wallets           []Wallet
+	Tags              []Tag
 }
 
 type AccountInfo struct {
-	Username string
-	Fullname string
-	Email    string
-	JoinedAt string
+	Username     string
+	Fullname     string
+	Email        string
+	PendingEmail string
+	JoinedAt     string
 }
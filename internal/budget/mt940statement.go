@@ -0,0 +1,206 @@
+package budget
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// :61: value date, optional entry (booking) date, mark, optional funds code,
+// amount, transaction type and references.
+var mt940LineRegex = regexp.MustCompile(`^(\d{6})(\d{4})?(RC|RD|C|D)([A-Z])?(\d+,\d*)(.*)$`)
+
+var mt940TagRegex = regexp.MustCompile(`^:(\d{2}[A-Z]?):`)
+
+type mt940Field struct {
+	tag   string
+	value string
+	line  int
+}
+
+// ParseMT940Statement reads a SWIFT MT940 customer statement. A file can
+// contain several statements, each one takes its currency from the opening
+// balance (:60F: or :60M:). The booking date is the entry date of :61: when
+// the bank sends it, the value date otherwise.
+func ParseMT940Statement(r io.Reader) ([]StatementRow, []ImportRowError, error) {
+	fields, err := readMT940Fields(r)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var rows []StatementRow
+	var rowErrors []ImportRowError
+	var account, currency string
+	sawStatement := false
+
+	for i := 0; i < len(fields); i++ {
+		f := fields[i]
+		switch f.tag {
+		case "20":
+			sawStatement = true
+			account, currency = "", ""
+		case "25":
+			account = strings.TrimSpace(f.value)
+		case "60F", "60M":
+			if len(f.value) >= 10 {
+				currency = strings.ToUpper(f.value[7:10])
+			}
+		case "61":
+			info := ""
+			if i+1 < len(fields) && fields[i+1].tag == "86" {
+				info = fields[i+1].value
+				i++
+			}
+
+			row, err := mt940Row(f.value, info, currency, account)
+			if err != nil {
+				rowErrors = append(rowErrors, ImportRowError{Index: f.line, Message: err.Error()})
+				continue
+			}
+			row.Index = f.line
+			rows = append(rows, row)
+			if len(rows)+len(rowErrors) > MAX_STATEMENT_ROWS {
+				return nil, nil, statementTooLarge()
+			}
+		}
+	}
+
+	if !sawStatement {
+		return nil, nil, invalidMapping("The file is not an MT940 statement")
+	}
+	return rows, rowErrors, nil
+}
+
+func readMT940Fields(r io.Reader) ([]mt940Field, error) {
+	scanner := bufio.NewScanner(io.LimitReader(r, MAX_IMPORT_FILE_SIZE))
+	var fields []mt940Field
+	line := 0
+
+	for scanner.Scan() {
+		line++
+		text := strings.TrimRight(scanner.Text(), "\r ")
+
+		// SWIFT envelope: {1:...}{2:...}{4: before the text block and -} after it
+		if i := strings.Index(text, "{4:"); i >= 0 {
+			text = text[i+3:]
+		}
+		if strings.HasPrefix(text, "{") || text == "-" || text == "-}" || text == "" {
+			continue
+		}
+
+		if m := mt940TagRegex.FindStringSubmatch(text); m != nil {
+			fields = append(fields, mt940Field{tag: m[1], value: text[len(m[0]):], line: line})
+			continue
+		}
+		if len(fields) > 0 {
+			// continuation of the previous field, :86: lines are wrapped at 65 chars
+			last := &fields[len(fields)-1]
+			if last.tag == "86" {
+				last.value += text
+			} else {
+				last.value += "\n" + text
+			}
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, invalidMapping("Failed to read the MT940 file")
+	}
+	return fields, nil
+}
+
+func mt940Row(statementLine string, info string, currency string, account string) (StatementRow, error) {
+	var row StatementRow
+	first, supplementary, _ := strings.Cut(statementLine, "\n")
+
+	m := mt940LineRegex.FindStringSubmatch(first)
+	if m == nil {
+		return row, fmt.Errorf("Invalid statement line %q", first)
+	}
+
+	valueDate, err := time.Parse("060102", m[1])
+	if err != nil {
+		return row, fmt.Errorf("Invalid value date %q", m[1])
+	}
+	row.Date = valueDate
+	if m[2] != "" {
+		entry, err := time.Parse("0102", m[2])
+		if err != nil {
+			return row, fmt.Errorf("Invalid entry date %q", m[2])
+		}
+		// the entry date has no year: a December booking can have a January
+		// value date and the other way round.
+		year := valueDate.Year()
+		switch {
+		case entry.Month() == time.December && valueDate.Month() == time.January:
+			year--
+		case entry.Month() == time.January && valueDate.Month() == time.December:
+			year++
+		}
+		row.Date = time.Date(year, entry.Month(), entry.Day(), 0, 0, 0, 0, time.UTC)
+	}
+
+	amount, err := strconv.ParseFloat(strings.Replace(m[5], ",", ".", 1), 64)
+	if err != nil {
+		return row, fmt.Errorf("Invalid amount %q", m[5])
+	}
+	// RC and RD are reversals: a reversed credit takes money out
+	switch m[3] {
+	case "D", "RC":
+		amount = -amount
+	}
+	row.Amount = amount
+
+	if currency == "" {
+		return row, fmt.Errorf("Currency is missing, the statement has no opening balance")
+	}
+	row.Currency = currency
+
+	rest := m[6]
+	if _, bankRef, ok := strings.Cut(rest, "//"); ok && strings.TrimSpace(bankRef) != "" {
+		row.ExternalID = "mt940:" + account + ":" + strings.TrimSpace(bankRef)
+	}
+
+	row.Description = mt940Description(info)
+	if row.Description == "" {
+		row.Description = strings.TrimSpace(supplementary)
+	}
+	return row, nil
+}
+
+// mt940Description reads :86: either as free text or in the structured
+// German format "GVC?00text?20remittance...?32name".
+func mt940Description(info string) string {
+	info = strings.TrimSpace(info)
+	if len(info) < 4 || info[3] != '?' {
+		return strings.Join(strings.Fields(info), " ")
+	}
+
+	var name, remittance []string
+	for _, part := range strings.Split(info[4:], "?") {
+		if len(part) < 2 {
+			continue
+		}
+		code, err := strconv.Atoi(part[:2])
+		if err != nil {
+			continue
+		}
+		value := strings.TrimSpace(part[2:])
+		switch {
+		case code >= 20 && code <= 29, code >= 60 && code <= 63:
+			remittance = append(remittance, value)
+		case code == 32 || code == 33:
+			name = append(name, value)
+		}
+	}
+
+	// SEPA payments put the purpose after the SVWZ+ qualifier
+	purpose := strings.Join(remittance, " ")
+	if _, after, ok := strings.Cut(purpose, "SVWZ+"); ok {
+		purpose = after
+	}
+	return strings.TrimSpace(strings.Join(name, " ") + " " + strings.Join(strings.Fields(purpose), " "))
+}
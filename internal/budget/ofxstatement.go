@@ -0,0 +1,195 @@
+package budget
+
+import (
+	"fmt"
+	"html"
+	"io"
+	"strings"
+	"time"
+)
+
+// ParseOFXStatement reads OFX 1.x (SGML, leaf tags are not closed) and OFX
+// 2.x (XML) bank and credit card statements, QFX is the same format. Every
+// transaction gets "ofx:<account>:<FITID>" as external ID, so importing an
+// overlapping statement again does not create duplicates.
+func ParseOFXStatement(r io.Reader) ([]StatementRow, []ImportRowError, error) {
+	data, err := io.ReadAll(io.LimitReader(r, MAX_IMPORT_FILE_SIZE))
+	if err != nil {
+		return nil, nil, invalidMapping("Failed to read the OFX file")
+	}
+
+	content := string(data)
+	start := strings.Index(strings.ToUpper(content), "<OFX>")
+	if start < 0 {
+		return nil, nil, invalidMapping("The file is not an OFX statement")
+	}
+
+	var rows []StatementRow
+	var rowErrors []ImportRowError
+
+	var (
+		currency  string
+		accountId string
+		inTrn     bool
+		trn       map[string]string
+		trnIndex  int
+		lastTag   string
+	)
+
+	for _, tok := range tokenizeOFX(content[start:]) {
+		if tok.text != "" {
+			if inTrn && lastTag != "" {
+				trn[lastTag] = tok.text
+			} else if lastTag == "CURDEF" {
+				currency = strings.ToUpper(tok.text)
+			} else if lastTag == "ACCTID" {
+				accountId = tok.text
+			}
+			lastTag = ""
+			continue
+		}
+
+		if tok.closing {
+			if tok.tag == "STMTTRN" && inTrn {
+				inTrn = false
+				row, err := ofxTransactionRow(trn, currency, accountId)
+				if err != nil {
+					rowErrors = append(rowErrors, ImportRowError{Index: trnIndex, Message: err.Error()})
+					continue
+				}
+				row.Index = trnIndex
+				rows = append(rows, row)
+				if len(rows)+len(rowErrors) > MAX_STATEMENT_ROWS {
+					return nil, nil, statementTooLarge()
+				}
+			}
+			lastTag = ""
+			continue
+		}
+
+		switch tok.tag {
+		case "STMTRS", "CCSTMTRS":
+			// a new statement inside the same file starts with its own
+			// currency and account.
+			currency, accountId = "", ""
+		case "STMTTRN":
+			inTrn = true
+			trnIndex++
+			trn = map[string]string{}
+		}
+		lastTag = tok.tag
+	}
+
+	if len(rows) == 0 && len(rowErrors) == 0 {
+		return nil, nil, invalidMapping("The OFX file has no transactions")
+	}
+	return rows, rowErrors, nil
+}
+
+type ofxToken struct {
+	tag     string
+	closing bool
+	text    string
+}
+
+func tokenizeOFX(content string) []ofxToken {
+	var tokens []ofxToken
+	for len(content) > 0 {
+		lt := strings.IndexByte(content, '<')
+		if lt < 0 {
+			if text := strings.TrimSpace(content); text != "" {
+				tokens = append(tokens, ofxToken{text: html.UnescapeString(text)})
+			}
+			break
+		}
+		if text := strings.TrimSpace(content[:lt]); text != "" {
+			tokens = append(tokens, ofxToken{text: html.UnescapeString(text)})
+		}
+		gt := strings.IndexByte(content[lt:], '>')
+		if gt < 0 {
+			break
+		}
+		tag := strings.TrimSpace(content[lt+1 : lt+gt])
+		content = content[lt+gt+1:]
+
+		if strings.HasPrefix(tag, "?") || strings.HasPrefix(tag, "!") {
+			continue
+		}
+		closing := strings.HasPrefix(tag, "/")
+		tag = strings.TrimPrefix(tag, "/")
+		if i := strings.IndexAny(tag, " \t\r\n"); i >= 0 {
+			tag = tag[:i]
+		}
+		tokens = append(tokens, ofxToken{tag: strings.ToUpper(tag), closing: closing})
+	}
+	return tokens
+}
+
+func ofxTransactionRow(trn map[string]string, currency string, accountId string) (StatementRow, error) {
+	var row StatementRow
+
+	date, err := parseOFXDate(trn["DTPOSTED"])
+	if err != nil {
+		return row, err
+	}
+	row.Date = date
+
+	decimal := "."
+	if strings.Contains(trn["TRNAMT"], ",") && !strings.Contains(trn["TRNAMT"], ".") {
+		decimal = ","
+	}
+	if row.Amount, err = ParseStatementAmount(trn["TRNAMT"], decimal); err != nil {
+		return row, err
+	}
+
+	row.Currency = currency
+	if c := trn["CURSYM"]; c != "" {
+		row.Currency = strings.ToUpper(c)
+	}
+	if row.Currency == "" {
+		return row, fmt.Errorf("Currency is missing")
+	}
+
+	name, memo := trn["NAME"], trn["MEMO"]
+	switch {
+	case name == "":
+		row.Description = memo
+	case memo == "" || memo == name:
+		row.Description = name
+	default:
+		row.Description = name + " " + memo
+	}
+
+	if fitId := trn["FITID"]; fitId != "" {
+		row.ExternalID = "ofx:" + accountId + ":" + fitId
+	}
+	return row, nil
+}
+
+// parseOFXDate accepts YYYYMMDD[HHMMSS[.XXX]][[gmt offset:tz name]].
+func parseOFXDate(raw string) (time.Time, error) {
+	s := raw
+	if i := strings.IndexByte(s, '['); i >= 0 {
+		s = s[:i]
+	}
+	if i := strings.IndexByte(s, '.'); i >= 0 {
+		s = s[:i]
+	}
+	s = strings.TrimSpace(s)
+
+	layout := ""
+	switch len(s) {
+	case 8:
+		layout = "20060102"
+	case 12:
+		layout = "200601021504"
+	case 14:
+		layout = "20060102150405"
+	}
+	if layout != "" {
+		if t, err := time.Parse(layout, s); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("Invalid date %q", raw)
+}
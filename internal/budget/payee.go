@@ -0,0 +1,611 @@
+package budget
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+	"unicode"
+
+	appErrors "github.com/fatali-fataliyev/budget_tracker/customErrors"
+	"github.com/fatali-fataliyev/budget_tracker/internal/contextutil"
+	"github.com/fatali-fataliyev/budget_tracker/logging"
+	"github.com/google/uuid"
+)
+
+const (
+	PAYEE_MATCH_CONTAINS = "contains"
+	PAYEE_MATCH_PREFIX   = "prefix"
+	PAYEE_MATCH_EXACT    = "exact"
+	PAYEE_MATCH_REGEX    = "regex"
+
+	MAX_PAYEE_NAME_LENGTH  = 100
+	MAX_PAYEE_ALIAS_LENGTH = 100
+	MAX_ALIASES_PER_PAYEE  = 50
+	MAX_PAYEES_PER_USER    = 1000
+
+	DEFAULT_PAYEE_STATS_MONTHS = 12
+	MAX_PAYEE_STATS_MONTHS     = 36
+
+	// RECEIPT_HEADER_LINES is how many lines from the top of a receipt are
+	// searched for the merchant name.
+	RECEIPT_HEADER_LINES = 5
+)
+
+// Payee is a merchant or anyone else money goes to or comes from. Aliases
+// turn the raw text of bank statements, like "AMZN Mktp US*2K4", into the
+// payee; the name itself matches too, as whole words.
+type Payee struct {
+	ID                  string
+	Name                string
+	DefaultCategoryId   string // used by new transactions of the payee without a category
+	DefaultCategoryType string // "+" or "-", set with DefaultCategoryId
+	Aliases             []PayeeAlias
+	TransactionCount    int // filled by storage when reading
+	CreatedAt           time.Time
+	UpdatedAt           time.Time
+	CreatedBy           string
+}
+
+// PayeeAlias is a rule matching transaction notes, case-insensitively.
+type PayeeAlias struct {
+	Pattern string
+	Match   string // one of the PAYEE_MATCH constants
+}
+
+type PayeeRequest struct {
+	Name                string
+	DefaultCategoryId   string
+	DefaultCategoryType string
+	Aliases             []PayeeAlias
+}
+
+// PayeeStats is the spending with a payee in one currency.
+type PayeeStats struct {
+	PayeeId  string
+	Name     string
+	Currency string
+	Count    int
+	Total    float64
+	Average  float64
+	Largest  float64
+	FirstAt  time.Time
+	LastAt   time.Time
+	Months   []PayeeMonth // oldest first, months without spending are left out
+}
+
+type PayeeMonth struct {
+	Month time.Time // first day of the month
+	Total float64
+	Count int
+}
+
+// normalizePayeeText lowercases s and collapses its whitespace, aliases and
+// notes are compared in this form.
+func normalizePayeeText(s string) string {
+	return strings.ToLower(strings.Join(strings.Fields(s), " "))
+}
+
+// payeeMatchRank orders the kinds of matches, the more specific one wins
+// when several payees match a note.
+func payeeMatchRank(match string) int {
+	switch match {
+	case PAYEE_MATCH_EXACT:
+		return 4
+	case PAYEE_MATCH_PREFIX:
+		return 3
+	case PAYEE_MATCH_CONTAINS, PAYEE_MATCH_REGEX:
+		return 2
+	}
+	return 0
+}
+
+// payeeScore tells how well a payee matches a note, 0 when it does not. An
+// alias beats the name and a longer pattern beats a shorter one of the same
+// kind. Invalid regular expressions never match.
+func payeeScore(p Payee, note string) int {
+	text := normalizePayeeText(note)
+	if text == "" {
+		return 0
+	}
+
+	best := 0
+	for _, alias := range p.Aliases {
+		pattern := normalizePayeeText(alias.Pattern)
+		matched := false
+		switch alias.Match {
+		case PAYEE_MATCH_EXACT:
+			matched = text == pattern
+		case PAYEE_MATCH_PREFIX:
+			matched = strings.HasPrefix(text, pattern)
+		case PAYEE_MATCH_CONTAINS:
+			matched = strings.Contains(text, pattern)
+		case PAYEE_MATCH_REGEX:
+			re, err := regexp.Compile("(?i)" + alias.Pattern)
+			matched = err == nil && re.MatchString(note)
+		}
+		if matched {
+			best = max(best, payeeMatchRank(alias.Match)*1000+len(pattern))
+		}
+	}
+	if best > 0 {
+		return best
+	}
+
+	name := merchantTokens(p.Name)
+	if len(name) == 0 {
+		return 0
+	}
+	words := map[string]bool{}
+	for _, word := range merchantTokens(note) {
+		words[word] = true
+	}
+	for _, word := range name {
+		if !words[word] {
+			return 0
+		}
+	}
+	return 1000 + len(normalizePayeeText(p.Name))
+}
+
+// matchPayee finds the payee of a note, ties go to the first payee by name.
+func matchPayee(payees []Payee, note string) (Payee, bool) {
+	var found Payee
+	best := 0
+	for _, p := range payees {
+		score := payeeScore(p, note)
+		if score > best || (score == best && score > 0 && p.Name < found.Name) {
+			found, best = p, score
+		}
+	}
+	return found, best > 0
+}
+
+func validatePayeeAliases(aliases []PayeeAlias) ([]PayeeAlias, error) {
+	if len(aliases) > MAX_ALIASES_PER_PAYEE {
+		return nil, appErrors.ErrorResponse{
+			Code:    appErrors.ErrInvalidInput,
+			Message: fmt.Sprintf("Maximum %d aliases are allowed per payee", MAX_ALIASES_PER_PAYEE),
+		}
+	}
+
+	seen := map[string]bool{}
+	result := make([]PayeeAlias, 0, len(aliases))
+	for _, alias := range aliases {
+		alias.Pattern = strings.TrimSpace(alias.Pattern)
+		if alias.Match == "" {
+			alias.Match = PAYEE_MATCH_CONTAINS
+		}
+		if alias.Pattern == "" {
+			return nil, appErrors.ErrorResponse{
+				Code:    appErrors.ErrInvalidInput,
+				Message: "Alias pattern cannot be empty!",
+			}
+		}
+		if len(alias.Pattern) > MAX_PAYEE_ALIAS_LENGTH {
+			return nil, appErrors.ErrorResponse{
+				Code:    appErrors.ErrInvalidInput,
+				Message: fmt.Sprintf("Alias pattern so long, maximum allowed length is %d", MAX_PAYEE_ALIAS_LENGTH),
+			}
+		}
+		switch alias.Match {
+		case PAYEE_MATCH_CONTAINS, PAYEE_MATCH_PREFIX, PAYEE_MATCH_EXACT:
+		case PAYEE_MATCH_REGEX:
+			if _, err := regexp.Compile("(?i)" + alias.Pattern); err != nil {
+				return nil, appErrors.ErrorResponse{
+					Code:    appErrors.ErrInvalidInput,
+					Message: fmt.Sprintf("Invalid alias regular expression: %s", alias.Pattern),
+				}
+			}
+		default:
+			return nil, appErrors.ErrorResponse{
+				Code:    appErrors.ErrInvalidInput,
+				Message: "Invalid alias match, use contains, prefix, exact or regex.",
+			}
+		}
+
+		key := alias.Match + "|" + normalizePayeeText(alias.Pattern)
+		if !seen[key] {
+			seen[key] = true
+			result = append(result, alias)
+		}
+	}
+	return result, nil
+}
+
+// validatePayeeRequest trims the request and checks its default category
+// exists with the given type.
+func (bt *BudgetTracker) validatePayeeRequest(ctx context.Context, userId string, req PayeeRequest) (PayeeRequest, error) {
+	req.Name = strings.Join(strings.Fields(req.Name), " ")
+	if req.Name == "" {
+		return PayeeRequest{}, appErrors.ErrorResponse{
+			Code:    appErrors.ErrInvalidInput,
+			Message: "Payee name cannot be empty!",
+		}
+	}
+	if len(req.Name) > MAX_PAYEE_NAME_LENGTH {
+		return PayeeRequest{}, appErrors.ErrorResponse{
+			Code:    appErrors.ErrInvalidInput,
+			Message: fmt.Sprintf("Payee name so long, maximum allowed length is %d", MAX_PAYEE_NAME_LENGTH),
+		}
+	}
+
+	aliases, err := validatePayeeAliases(req.Aliases)
+	if err != nil {
+		return PayeeRequest{}, err
+	}
+	req.Aliases = aliases
+
+	if req.DefaultCategoryId == "" {
+		req.DefaultCategoryType = ""
+		return req, nil
+	}
+	if req.DefaultCategoryType != "+" && req.DefaultCategoryType != "-" {
+		return PayeeRequest{}, appErrors.ErrorResponse{
+			Code:    appErrors.ErrInvalidInput,
+			Message: "Invalid default category type, use expense or income.",
+		}
+	}
+	categories, err := bt.storage.GetCategoryParents(ctx, userId, req.DefaultCategoryType)
+	if err != nil {
+		return PayeeRequest{}, err
+	}
+	if _, ok := categories[req.DefaultCategoryId]; !ok {
+		return PayeeRequest{}, appErrors.ErrorResponse{
+			Code:    appErrors.ErrNotFound,
+			Message: "Default category not found.",
+		}
+	}
+	return req, nil
+}
+
+func (bt *BudgetTracker) SavePayee(ctx context.Context, userId string, req PayeeRequest) (Payee, error) {
+	traceID := contextutil.TraceIDFromContext(ctx)
+
+	req, err := bt.validatePayeeRequest(ctx, userId, req)
+	if err != nil {
+		return Payee{}, err
+	}
+	existing, err := bt.storage.GetPayees(ctx, userId)
+	if err != nil {
+		logging.Logger.Errorf("[TraceID=%s] | storage.GetPayees() failed in Service.SavePayee()", traceID)
+		return Payee{}, err
+	}
+	if len(existing) >= MAX_PAYEES_PER_USER {
+		return Payee{}, appErrors.ErrorResponse{
+			Code:    appErrors.ErrInvalidInput,
+			Message: fmt.Sprintf("Maximum %d payees are allowed", MAX_PAYEES_PER_USER),
+		}
+	}
+
+	now := time.Now().UTC()
+	p := Payee{
+		ID:                  uuid.New().String(),
+		Name:                req.Name,
+		DefaultCategoryId:   req.DefaultCategoryId,
+		DefaultCategoryType: req.DefaultCategoryType,
+		Aliases:             req.Aliases,
+		CreatedAt:           now,
+		UpdatedAt:           now,
+		CreatedBy:           userId,
+	}
+	if err := bt.storage.SavePayee(ctx, p); err != nil {
+		logging.Logger.Errorf("[TraceID=%s] | storage.SavePayee() failed in Service.SavePayee()", traceID)
+		return Payee{}, err
+	}
+	return p, nil
+}
+
+func (bt *BudgetTracker) GetPayees(ctx context.Context, userId string) ([]Payee, error) {
+	return bt.storage.GetPayees(ctx, userId)
+}
+
+func (bt *BudgetTracker) GetPayee(ctx context.Context, userId string, id string) (Payee, error) {
+	return bt.storage.GetPayeeById(ctx, userId, id)
+}
+
+// UpdatePayee replaces the name, default category and aliases of a payee.
+// Linked transactions stay linked.
+func (bt *BudgetTracker) UpdatePayee(ctx context.Context, userId string, id string, req PayeeRequest) (Payee, error) {
+	p, err := bt.storage.GetPayeeById(ctx, userId, id)
+	if err != nil {
+		return Payee{}, err
+	}
+	req, err = bt.validatePayeeRequest(ctx, userId, req)
+	if err != nil {
+		return Payee{}, err
+	}
+
+	p.Name = req.Name
+	p.DefaultCategoryId = req.DefaultCategoryId
+	p.DefaultCategoryType = req.DefaultCategoryType
+	p.Aliases = req.Aliases
+	p.UpdatedAt = time.Now().UTC()
+	if err := bt.storage.UpdatePayee(ctx, p); err != nil {
+		return Payee{}, err
+	}
+	return p, nil
+}
+
+// DeletePayee deletes a payee, its transactions are kept without one.
+func (bt *BudgetTracker) DeletePayee(ctx context.Context, userId string, id string) error {
+	return bt.storage.DeletePayee(ctx, userId, id)
+}
+
+// resolveTransactionPayee links a new transaction to its payee, the given
+// one or else the one its note matches. Transfers have no payee.
+func (bt *BudgetTracker) resolveTransactionPayee(ctx context.Context, userId string, transaction *TransactionRequest) (Payee, error) {
+	traceID := contextutil.TraceIDFromContext(ctx)
+
+	if transaction.CategoryType == TRANSFER_TYPE {
+		transaction.PayeeId = ""
+		return Payee{}, nil
+	}
+
+	var payee Payee
+	if transaction.PayeeId != "" {
+		p, err := bt.storage.GetPayeeById(ctx, userId, transaction.PayeeId)
+		if err != nil {
+			return Payee{}, err
+		}
+		payee = p
+	} else {
+		if strings.TrimSpace(transaction.Note) == "" {
+			return Payee{}, nil
+		}
+		payees, err := bt.storage.GetPayees(ctx, userId)
+		if err != nil {
+			logging.Logger.Errorf("[TraceID=%s] | storage.GetPayees() failed in Service.resolveTransactionPayee()", traceID)
+			return Payee{}, err
+		}
+		p, ok := matchPayee(payees, transaction.Note)
+		if !ok {
+			return Payee{}, nil
+		}
+		payee = p
+	}
+
+	transaction.PayeeId = payee.ID
+	return payee, nil
+}
+
+// applyPayeeCategory gives a transaction without a category the default
+// category of its payee, when it has the transaction's type.
+func applyPayeeCategory(payee Payee, transaction *TransactionRequest) {
+	if transaction.CategoryId == "" && len(transaction.Splits) == 0 && payee.DefaultCategoryId != "" && payee.DefaultCategoryType == transaction.CategoryType {
+		transaction.CategoryId = payee.DefaultCategoryId
+	}
+}
+
+// SetTransactionPayee links a transaction to a payee, an empty payeeId
+// unlinks it.
+func (bt *BudgetTracker) SetTransactionPayee(ctx context.Context, userId string, transactionId string, payeeId string) (Transaction, error) {
+	traceID := contextutil.TraceIDFromContext(ctx)
+
+	transaction, err := bt.storage.GetTransactionById(ctx, userId, transactionId)
+	if err != nil {
+		return Transaction{}, err
+	}
+	if payeeId != "" {
+		if transaction.CategoryType == TRANSFER_TYPE {
+			return Transaction{}, appErrors.ErrorResponse{
+				Code:    appErrors.ErrInvalidInput,
+				Message: "A transfer cannot have a payee",
+			}
+		}
+		if _, err := bt.storage.GetPayeeById(ctx, userId, payeeId); err != nil {
+			return Transaction{}, err
+		}
+	}
+	if err := bt.storage.SetTransactionPayee(ctx, userId, []string{transactionId}, payeeId); err != nil {
+		logging.Logger.Errorf("[TraceID=%s] | storage.SetTransactionPayee() failed in Service.SetTransactionPayee()", traceID)
+		return Transaction{}, err
+	}
+	transaction.PayeeId = payeeId
+	return transaction, nil
+}
+
+// LinkPayeeTransactions links the transactions without a payee whose notes
+// match this payee better than any other, and returns how many it linked.
+func (bt *BudgetTracker) LinkPayeeTransactions(ctx context.Context, userId string, id string) (int, error) {
+	traceID := contextutil.TraceIDFromContext(ctx)
+
+	payee, err := bt.storage.GetPayeeById(ctx, userId, id)
+	if err != nil {
+		return 0, err
+	}
+	payees, err := bt.storage.GetPayees(ctx, userId)
+	if err != nil {
+		logging.Logger.Errorf("[TraceID=%s] | storage.GetPayees() failed in Service.LinkPayeeTransactions()", traceID)
+		return 0, err
+	}
+	transactions, err := bt.storage.GetFilteredTransactions(ctx, userId, &TransactionList{IsAllNil: true})
+	if err != nil {
+		logging.Logger.Errorf("[TraceID=%s] | storage.GetFilteredTransactions() failed in Service.LinkPayeeTransactions()", traceID)
+		return 0, err
+	}
+
+	var ids []string
+	for _, t := range transactions {
+		if t.PayeeId != "" || t.CategoryType == TRANSFER_TYPE {
+			continue
+		}
+		if p, ok := matchPayee(payees, t.Note); ok && p.ID == payee.ID {
+			ids = append(ids, t.ID)
+		}
+	}
+	if len(ids) == 0 {
+		return 0, nil
+	}
+	if err := bt.storage.SetTransactionPayee(ctx, userId, ids, payee.ID); err != nil {
+		logging.Logger.Errorf("[TraceID=%s] | storage.SetTransactionPayee() failed in Service.LinkPayeeTransactions()", traceID)
+		return 0, err
+	}
+	return len(ids), nil
+}
+
+// GetPayeeStats sums the expenses of each payee over the last months,
+// including the current one, largest total first. An empty payeeId gives
+// every payee with spending.
+func (bt *BudgetTracker) GetPayeeStats(ctx context.Context, userId string, payeeId string, months int) ([]PayeeStats, error) {
+	traceID := contextutil.TraceIDFromContext(ctx)
+
+	if months == 0 {
+		months = DEFAULT_PAYEE_STATS_MONTHS
+	}
+	if months < 1 || months > MAX_PAYEE_STATS_MONTHS {
+		return nil, appErrors.ErrorResponse{
+			Code:    appErrors.ErrInvalidInput,
+			Message: fmt.Sprintf("Months must be between 1 and %d", MAX_PAYEE_STATS_MONTHS),
+		}
+	}
+
+	payees, err := bt.storage.GetPayees(ctx, userId)
+	if err != nil {
+		logging.Logger.Errorf("[TraceID=%s] | storage.GetPayees() failed in Service.GetPayeeStats()", traceID)
+		return nil, err
+	}
+	names := make(map[string]string, len(payees))
+	for _, p := range payees {
+		names[p.ID] = p.Name
+	}
+	if payeeId != "" {
+		if _, ok := names[payeeId]; !ok {
+			return nil, appErrors.ErrorResponse{
+				Code:    appErrors.ErrNotFound,
+				Message: "Payee not found.",
+			}
+		}
+	}
+
+	now := time.Now().UTC()
+	from := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, time.UTC).AddDate(0, 1-months, 0)
+	filters := &TransactionList{Type: "-", OccurredAt: from}
+	if payeeId != "" {
+		filters.PayeeIds = []string{payeeId}
+	}
+	transactions, err := bt.storage.GetFilteredTransactions(ctx, userId, filters)
+	if err != nil {
+		logging.Logger.Errorf("[TraceID=%s] | storage.GetFilteredTransactions() failed in Service.GetPayeeStats()", traceID)
+		return nil, err
+	}
+
+	type key struct{ payeeId, currency string }
+	type totals struct {
+		stats   PayeeStats
+		total   int64
+		largest int64
+		months  map[time.Time]*PayeeMonth
+		cents   map[time.Time]int64
+	}
+	groups := map[key]*totals{}
+	for _, t := range transactions {
+		if t.PayeeId == "" || (payeeId != "" && t.PayeeId != payeeId) || t.OccurredAt.Before(from) {
+			continue
+		}
+		k := key{t.PayeeId, t.Currency}
+		g, ok := groups[k]
+		if !ok {
+			g = &totals{
+				stats:  PayeeStats{PayeeId: t.PayeeId, Name: names[t.PayeeId], Currency: t.Currency},
+				months: map[time.Time]*PayeeMonth{},
+				cents:  map[time.Time]int64{},
+			}
+			groups[k] = g
+		}
+
+		occurredAt := t.OccurredAt.UTC()
+		cents := toCents(t.Amount)
+		g.stats.Count++
+		g.total += cents
+		g.largest = max(g.largest, cents)
+		if g.stats.FirstAt.IsZero() || occurredAt.Before(g.stats.FirstAt) {
+			g.stats.FirstAt = occurredAt
+		}
+		if occurredAt.After(g.stats.LastAt) {
+			g.stats.LastAt = occurredAt
+		}
+
+		month := time.Date(occurredAt.Year(), occurredAt.Month(), 1, 0, 0, 0, 0, time.UTC)
+		if g.months[month] == nil {
+			g.months[month] = &PayeeMonth{Month: month}
+		}
+		g.months[month].Count++
+		g.cents[month] += cents
+	}
+
+	stats := make([]PayeeStats, 0, len(groups))
+	for _, g := range groups {
+		s := g.stats
+		s.Total = float64(g.total) / 100
+		s.Largest = float64(g.largest) / 100
+		s.Average = math.Round(float64(g.total)/float64(s.Count)) / 100
+		for month, m := range g.months {
+			m.Total = float64(g.cents[month]) / 100
+			s.Months = append(s.Months, *m)
+		}
+		sort.Slice(s.Months, func(i, j int) bool { return s.Months[i].Month.Before(s.Months[j].Month) })
+		stats = append(stats, s)
+	}
+	sort.Slice(stats, func(i, j int) bool {
+		if stats[i].Total != stats[j].Total {
+			return stats[i].Total > stats[j].Total
+		}
+		if stats[i].Name != stats[j].Name {
+			return stats[i].Name < stats[j].Name
+		}
+		return stats[i].Currency < stats[j].Currency
+	})
+	return stats, nil
+}
+
+var receiptHeaderSkipWords = []string{"receipt", "invoice", "welcome", "tel", "phone", "date", "time", "cashier", "check", "çek", "qəbz", "qebz"}
+
+// receiptPayee guesses the merchant of a receipt. A payee of the user found
+// on any line wins, otherwise it is the first line near the top that reads
+// like a name rather than an item, a date or a phone number.
+func receiptPayee(payees []Payee, text string) (string, string) {
+	var found Payee
+	best := 0
+	for _, line := range strings.Split(text, "\n") {
+		for _, p := range payees {
+			if score := payeeScore(p, line); score > best {
+				found, best = p, score
+			}
+		}
+	}
+	if best > 0 {
+		return found.Name, found.ID
+	}
+
+	checked := 0
+	for _, raw := range strings.Split(text, "\n") {
+		line := strings.Join(strings.Fields(raw), " ")
+		if line == "" {
+			continue
+		}
+		if checked++; checked > RECEIPT_HEADER_LINES {
+			break
+		}
+		if receiptLineRegex.MatchString(line) {
+			continue
+		}
+		letters, digits := 0, 0
+		for _, r := range line {
+			if unicode.IsLetter(r) {
+				letters++
+			} else if unicode.IsDigit(r) {
+				digits++
+			}
+		}
+		words := receiptWords(line)
+		if letters < 3 || digits > letters || containsAny(words, receiptHeaderSkipWords) || containsAny(words, receiptTotalWords) {
+			continue
+		}
+		return CapitalizeFullName(strings.ToLower(line)), ""
+	}
+	return "", ""
+}
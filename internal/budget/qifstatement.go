@@ -0,0 +1,186 @@
+package budget
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+// QIF_DATE_ORDER_* tell ParseQIFStatement how to read dates such as 03/04/26,
+// QIF itself does not say.
+const (
+	QIF_DATE_ORDER_MDY = "mdy"
+	QIF_DATE_ORDER_DMY = "dmy"
+)
+
+// ParseQIFStatement reads a Quicken Interchange Format file. QIF has no
+// currency and no transaction IDs, so the currency must be given and
+// duplicates are only detected by content.
+func ParseQIFStatement(r io.Reader, currency string, dateOrder string, decimalSeparator string) ([]StatementRow, []ImportRowError, error) {
+	currency = strings.ToUpper(strings.TrimSpace(currency))
+	if currency == "" {
+		return nil, nil, invalidMapping("Currency is required for QIF files")
+	}
+	if dateOrder == "" {
+		dateOrder = QIF_DATE_ORDER_MDY
+	}
+	if dateOrder != QIF_DATE_ORDER_MDY && dateOrder != QIF_DATE_ORDER_DMY {
+		return nil, nil, invalidMapping("Invalid date order, allowed values: mdy, dmy")
+	}
+	if decimalSeparator == "" {
+		decimalSeparator = "."
+	}
+	if decimalSeparator != "." && decimalSeparator != "," {
+		return nil, nil, invalidMapping("Decimal separator must be '.' or ','")
+	}
+
+	scanner := bufio.NewScanner(io.LimitReader(r, MAX_IMPORT_FILE_SIZE))
+	var rows []StatementRow
+	var rowErrors []ImportRowError
+
+	record := map[byte]string{}
+	recordLine := 0
+	line := 0
+	sawType := false
+	skipSection := false
+
+	flush := func() {
+		defer func() { record = map[byte]string{} }()
+		if len(record) == 0 || skipSection {
+			return
+		}
+		row, err := qifRecordRow(record, currency, dateOrder, decimalSeparator)
+		if err != nil {
+			rowErrors = append(rowErrors, ImportRowError{Index: recordLine, Message: err.Error()})
+			return
+		}
+		row.Index = recordLine
+		rows = append(rows, row)
+	}
+
+	for scanner.Scan() {
+		line++
+		text := strings.TrimRight(scanner.Text(), "\r")
+		if line == 1 {
+			text = strings.TrimPrefix(text, "\ufeff")
+		}
+		if strings.TrimSpace(text) == "" {
+			continue
+		}
+
+		if strings.HasPrefix(text, "!") {
+			flush()
+			header := strings.ToLower(strings.TrimSpace(text))
+			if strings.HasPrefix(header, "!type:") {
+				sawType = true
+				kind := strings.TrimPrefix(header, "!type:")
+				// investment and list sections are ignored
+				skipSection = kind != "bank" && kind != "cash" && kind != "ccard" && kind != "oth a" && kind != "oth l"
+			} else {
+				skipSection = true
+			}
+			continue
+		}
+
+		if text[0] == '^' {
+			flush()
+			continue
+		}
+		if len(record) == 0 {
+			recordLine = line
+		}
+		if len(rows)+len(rowErrors) >= MAX_STATEMENT_ROWS {
+			return nil, nil, statementTooLarge()
+		}
+		// split lines (S, E, $) are ignored, T already holds the total
+		code := text[0]
+		if _, exists := record[code]; !exists {
+			record[code] = strings.TrimSpace(text[1:])
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, nil, invalidMapping("Failed to read the QIF file")
+	}
+	flush()
+
+	if !sawType {
+		return nil, nil, invalidMapping("The file is not a QIF file")
+	}
+	return rows, rowErrors, nil
+}
+
+func qifRecordRow(record map[byte]string, currency string, dateOrder string, decimalSeparator string) (StatementRow, error) {
+	var row StatementRow
+
+	date, err := parseQIFDate(record['D'], dateOrder)
+	if err != nil {
+		return row, err
+	}
+	row.Date = date
+
+	amount := record['T']
+	if amount == "" {
+		amount = record['U']
+	}
+	if amount == "" {
+		return row, fmt.Errorf("Amount is empty")
+	}
+	if row.Amount, err = ParseStatementAmount(amount, decimalSeparator); err != nil {
+		return row, err
+	}
+
+	row.Currency = currency
+	payee, memo := record['P'], record['M']
+	switch {
+	case payee == "":
+		row.Description = memo
+	case memo == "" || memo == payee:
+		row.Description = payee
+	default:
+		row.Description = payee + " " + memo
+	}
+	return row, nil
+}
+
+// parseQIFDate handles the many ways Quicken writes dates: 3/4/26, 03/04'26,
+// 03-04-2026, 03.04.2026 and 2026-03-04.
+func parseQIFDate(raw string, dateOrder string) (time.Time, error) {
+	fields := strings.FieldsFunc(strings.TrimSpace(raw), func(r rune) bool {
+		return r == '/' || r == '-' || r == '.' || r == '\'' || r == ' '
+	})
+	if len(fields) != 3 {
+		return time.Time{}, fmt.Errorf("Invalid date %q", raw)
+	}
+
+	nums := make([]int, 3)
+	for i, f := range fields {
+		if _, err := fmt.Sscanf(f, "%d", &nums[i]); err != nil {
+			return time.Time{}, fmt.Errorf("Invalid date %q", raw)
+		}
+	}
+
+	var year, month, day int
+	switch {
+	case len(fields[0]) == 4:
+		year, month, day = nums[0], nums[1], nums[2]
+	case dateOrder == QIF_DATE_ORDER_DMY:
+		day, month, year = nums[0], nums[1], nums[2]
+	default:
+		month, day, year = nums[0], nums[1], nums[2]
+	}
+	if year < 100 {
+		if year < 70 {
+			year += 2000
+		} else {
+			year += 1900
+		}
+	}
+
+	t := time.Date(year, time.Month(month), day, 0, 0, 0, 0, time.UTC)
+	if month < 1 || month > 12 || t.Day() != day {
+		return time.Time{}, fmt.Errorf("Invalid date %q", raw)
+	}
+	return t, nil
+}
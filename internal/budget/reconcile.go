@@ -0,0 +1,212 @@
+package budget
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	appErrors "github.com/fatali-fataliyev/budget_tracker/customErrors"
+	"github.com/fatali-fataliyev/budget_tracker/internal/contextutil"
+	"github.com/fatali-fataliyev/budget_tracker/logging"
+	"github.com/google/uuid"
+)
+
+// Reconciliation compares a wallet with a bank statement. A wallet has at
+// most one open reconciliation; finishing it locks the cleared transactions.
+type Reconciliation struct {
+	ID               string
+	WalletId         string
+	StatementBalance float64
+	StatementDate    time.Time
+	FinishedAt       time.Time // zero while open
+	CreatedAt        time.Time
+	UpdatedAt        time.Time
+	CreatedBy        string
+}
+
+type ReconciliationRequest struct {
+	StatementBalance float64
+	StatementDate    time.Time
+}
+
+// ReconciliationStatus is the state of a wallet against its open statement.
+// Reconciliation is nil when none is open, Difference is then zero.
+type ReconciliationStatus struct {
+	Wallet         Wallet
+	Reconciliation *Reconciliation
+	ClearedBalance float64 // opening balance plus every cleared transaction
+	Difference     float64 // statement balance minus cleared balance
+	Unreconciled   []Transaction
+}
+
+// StartReconciliation opens a reconciliation of a wallet, or changes the
+// statement of the one already open.
+func (bt *BudgetTracker) StartReconciliation(ctx context.Context, userId string, walletId string, req ReconciliationRequest) (ReconciliationStatus, error) {
+	traceID := contextutil.TraceIDFromContext(ctx)
+
+	if req.StatementDate.IsZero() {
+		return ReconciliationStatus{}, appErrors.ErrorResponse{
+			Code:    appErrors.ErrInvalidInput,
+			Message: "Statement date is required",
+		}
+	}
+	if req.StatementDate.After(time.Now().Add(MAX_OCCURRED_AT_AHEAD)) {
+		return ReconciliationStatus{}, appErrors.ErrorResponse{
+			Code:    appErrors.ErrInvalidInput,
+			Message: "Statement date cannot be in the future",
+		}
+	}
+	if req.StatementBalance > MAX_TRANSACTION_AMOUNT_LIMIT || req.StatementBalance < -MAX_TRANSACTION_AMOUNT_LIMIT {
+		return ReconciliationStatus{}, appErrors.ErrorResponse{
+			Code:    appErrors.ErrInvalidInput,
+			Message: fmt.Sprintf("Maximum allowed statement balance is %d", MAX_TRANSACTION_AMOUNT_LIMIT),
+		}
+	}
+
+	if _, err := bt.storage.GetWalletById(ctx, userId, walletId); err != nil {
+		return ReconciliationStatus{}, err
+	}
+
+	now := time.Now().UTC()
+	r, err := bt.openReconciliation(ctx, userId, walletId)
+	if err != nil {
+		return ReconciliationStatus{}, err
+	}
+	if r == nil {
+		r = &Reconciliation{ID: uuid.New().String(), WalletId: walletId, CreatedAt: now, CreatedBy: userId}
+	}
+	r.StatementBalance = req.StatementBalance
+	r.StatementDate = req.StatementDate.UTC()
+	r.UpdatedAt = now
+
+	if err := bt.storage.SaveReconciliation(ctx, *r); err != nil {
+		logging.Logger.Errorf("[TraceID=%s] | storage.SaveReconciliation() failed in Service.StartReconciliation()", traceID)
+		return ReconciliationStatus{}, err
+	}
+	return bt.GetReconciliation(ctx, userId, walletId)
+}
+
+// GetReconciliation returns the cleared balance of a wallet, the difference
+// to the open statement and the transactions not reconciled yet.
+func (bt *BudgetTracker) GetReconciliation(ctx context.Context, userId string, walletId string) (ReconciliationStatus, error) {
+	traceID := contextutil.TraceIDFromContext(ctx)
+
+	w, err := bt.storage.GetWalletById(ctx, userId, walletId)
+	if err != nil {
+		return ReconciliationStatus{}, err
+	}
+	r, err := bt.openReconciliation(ctx, userId, walletId)
+	if err != nil {
+		return ReconciliationStatus{}, err
+	}
+	transactions, err := bt.storage.GetWalletTransactions(ctx, userId, walletId)
+	if err != nil {
+		logging.Logger.Errorf("[TraceID=%s] | storage.GetWalletTransactions() failed in Service.GetReconciliation()", traceID)
+		return ReconciliationStatus{}, err
+	}
+
+	status := ReconciliationStatus{Wallet: w, Reconciliation: r, Unreconciled: []Transaction{}}
+	cleared := toCents(w.OpeningBalance)
+	for _, t := range transactions {
+		if t.Cleared {
+			cleared += toCents(walletAmount(t, walletId))
+		}
+		if t.ReconciledAt.IsZero() {
+			status.Unreconciled = append(status.Unreconciled, t)
+		}
+	}
+	status.ClearedBalance = float64(cleared) / 100
+	if r != nil {
+		status.Difference = float64(toCents(r.StatementBalance)-cleared) / 100
+	}
+	return status, nil
+}
+
+// SetTransactionsCleared marks transactions of a wallet as cleared or not.
+// Reconciled transactions are locked and cannot be changed.
+func (bt *BudgetTracker) SetTransactionsCleared(ctx context.Context, userId string, walletId string, transactionIds []string, cleared bool) (ReconciliationStatus, error) {
+	traceID := contextutil.TraceIDFromContext(ctx)
+
+	if len(transactionIds) == 0 {
+		return ReconciliationStatus{}, appErrors.ErrorResponse{
+			Code:    appErrors.ErrInvalidInput,
+			Message: "Transaction IDs cannot be empty!",
+		}
+	}
+
+	status, err := bt.GetReconciliation(ctx, userId, walletId)
+	if err != nil {
+		return ReconciliationStatus{}, err
+	}
+	unreconciled := make(map[string]bool, len(status.Unreconciled))
+	for _, t := range status.Unreconciled {
+		unreconciled[t.ID] = true
+	}
+	for _, id := range transactionIds {
+		if !unreconciled[id] {
+			return ReconciliationStatus{}, appErrors.ErrorResponse{
+				Code:    appErrors.ErrConflict,
+				Message: fmt.Sprintf("Transaction %s is not in this wallet or is already reconciled", id),
+			}
+		}
+	}
+
+	if err := bt.storage.SetTransactionsCleared(ctx, userId, transactionIds, cleared); err != nil {
+		logging.Logger.Errorf("[TraceID=%s] | storage.SetTransactionsCleared() failed in Service.SetTransactionsCleared()", traceID)
+		return ReconciliationStatus{}, err
+	}
+	return bt.GetReconciliation(ctx, userId, walletId)
+}
+
+// FinishReconciliation closes the open reconciliation of a wallet once the
+// cleared balance matches the statement, and locks the cleared transactions.
+func (bt *BudgetTracker) FinishReconciliation(ctx context.Context, userId string, walletId string) (Reconciliation, error) {
+	traceID := contextutil.TraceIDFromContext(ctx)
+
+	status, err := bt.GetReconciliation(ctx, userId, walletId)
+	if err != nil {
+		return Reconciliation{}, err
+	}
+	if status.Reconciliation == nil {
+		return Reconciliation{}, appErrors.ErrorResponse{
+			Code:    appErrors.ErrNotFound,
+			Message: "The wallet has no open reconciliation.",
+		}
+	}
+	if toCents(status.Difference) != 0 {
+		return Reconciliation{}, appErrors.ErrorResponse{
+			Code:    appErrors.ErrConflict,
+			Message: fmt.Sprintf("Cleared balance is %.2f but the statement balance is %.2f, difference %.2f.", status.ClearedBalance, status.Reconciliation.StatementBalance, status.Difference),
+		}
+	}
+
+	var ids []string
+	for _, t := range status.Unreconciled {
+		if t.Cleared {
+			ids = append(ids, t.ID)
+		}
+	}
+
+	r := *status.Reconciliation
+	r.FinishedAt = time.Now().UTC()
+	r.UpdatedAt = r.FinishedAt
+	if err := bt.storage.FinishReconciliation(ctx, r, ids); err != nil {
+		logging.Logger.Errorf("[TraceID=%s] | storage.FinishReconciliation() failed in Service.FinishReconciliation()", traceID)
+		return Reconciliation{}, err
+	}
+	return r, nil
+}
+
+// openReconciliation returns nil when the wallet has no open reconciliation.
+func (bt *BudgetTracker) openReconciliation(ctx context.Context, userId string, walletId string) (*Reconciliation, error) {
+	r, err := bt.storage.GetOpenReconciliation(ctx, userId, walletId)
+	if err != nil {
+		var appErr appErrors.ErrorResponse
+		if errors.As(err, &appErr) && appErr.Code == appErrors.ErrNotFound {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &r, nil
+}
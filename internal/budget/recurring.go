@@ -0,0 +1,548 @@
+package budget
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	appErrors "github.com/fatali-fataliyev/budget_tracker/customErrors"
+	"github.com/fatali-fataliyev/budget_tracker/internal/contextutil"
+	"github.com/fatali-fataliyev/budget_tracker/logging"
+	"github.com/google/uuid"
+)
+
+const (
+	RECURRENCE_DAILY             = "DAILY"
+	RECURRENCE_WEEKLY            = "WEEKLY"
+	RECURRENCE_MONTHLY           = "MONTHLY"
+	RECURRENCE_LAST_BUSINESS_DAY = "LAST_BUSINESS_DAY" // monthly, BYDAY=MO,TU,WE,TH,FR;BYSETPOS=-1
+
+	MAX_RECURRENCE_INTERVAL  = 366
+	MAX_RECURRING_CATCH_UP   = 400 // instances created for one template in one run
+	DEFAULT_UPCOMING_DAYS    = 30
+	MAX_UPCOMING_DAYS        = 366
+	MAX_RECURRING_PER_USER   = 200
+	RECURRING_SCHEDULER_TICK = 15 * time.Minute
+)
+
+var rruleWeekdays = map[string]time.Weekday{
+	"SU": time.Sunday,
+	"MO": time.Monday,
+	"TU": time.Tuesday,
+	"WE": time.Wednesday,
+	"TH": time.Thursday,
+	"FR": time.Friday,
+	"SA": time.Saturday,
+}
+
+// Recurrence is the subset of an iCalendar RRULE the scheduler understands.
+type Recurrence struct {
+	Frequency  string
+	Interval   int          // every Interval days, weeks or months
+	Weekday    time.Weekday // WEEKLY
+	MonthDay   int          // MONTHLY, -1 is the last day; days past the month end fall on the last day
+	hasWeekday bool
+}
+
+type RecurringTransaction struct {
+	ID           string
+	CategoryId   string
+	CategoryType string
+	Amount       float64
+	Currency     string
+	Note         string
+	Rule         string    // RRULE, e.g. FREQ=MONTHLY;BYMONTHDAY=1
+	StartAt      time.Time // first possible occurrence, its clock time is used for every instance
+	EndAt        time.Time // zero when open ended
+	NextRunAt    time.Time // next instance to create, zero once the schedule has ended
+	Paused       bool
+	PausedReason string // why the scheduler paused it, empty when paused by the user
+	CreatedAt    time.Time
+	UpdatedAt    time.Time
+	CreatedBy    string
+}
+
+type RecurringTransactionRequest struct {
+	CategoryId   string
+	CategoryType string
+	Amount       float64
+	Currency     string
+	Note         string
+	Rule         string
+	StartAt      time.Time // today when zero
+	EndAt        time.Time
+}
+
+type UpcomingTransaction struct {
+	RecurringId  string
+	CategoryId   string
+	CategoryType string
+	Amount       float64
+	Currency     string
+	Note         string
+	OccursAt     time.Time
+}
+
+// ParseRecurrenceRule reads an RRULE such as
+//
+//	FREQ=MONTHLY;BYMONTHDAY=1                      rent on the 1st
+//	FREQ=MONTHLY;BYDAY=MO,TU,WE,TH,FR;BYSETPOS=-1  salary on the last business day
+//	FREQ=WEEKLY;BYDAY=FR                           every Friday
+//	FREQ=DAILY;INTERVAL=14                         every 14 days
+//
+// When BYDAY or BYMONTHDAY is left out, the weekday or day of the start date
+// is used.
+func ParseRecurrenceRule(rule string) (Recurrence, error) {
+	r := Recurrence{Interval: 1}
+	invalid := func(msg string) (Recurrence, error) {
+		return Recurrence{}, appErrors.ErrorResponse{
+			Code:    appErrors.ErrInvalidInput,
+			Message: "Invalid recurrence rule: " + msg,
+		}
+	}
+
+	parts := map[string]string{}
+	for _, part := range strings.Split(strings.TrimPrefix(strings.ToUpper(strings.TrimSpace(rule)), "RRULE:"), ";") {
+		if part == "" {
+			continue
+		}
+		key, value, ok := strings.Cut(part, "=")
+		if !ok || value == "" {
+			return invalid(fmt.Sprintf("%q is not KEY=VALUE", part))
+		}
+		parts[key] = value
+	}
+
+	if v, ok := parts["INTERVAL"]; ok {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 || n > MAX_RECURRENCE_INTERVAL {
+			return invalid(fmt.Sprintf("INTERVAL must be between 1 and %d", MAX_RECURRENCE_INTERVAL))
+		}
+		r.Interval = n
+	}
+
+	byDay, bySetPos, byMonthDay := parts["BYDAY"], parts["BYSETPOS"], parts["BYMONTHDAY"]
+	for key := range parts {
+		switch key {
+		case "FREQ", "INTERVAL", "BYDAY", "BYSETPOS", "BYMONTHDAY":
+		default:
+			return invalid(key + " is not supported")
+		}
+	}
+
+	switch parts["FREQ"] {
+	case RECURRENCE_DAILY:
+		if byDay != "" || bySetPos != "" || byMonthDay != "" {
+			return invalid("DAILY takes only INTERVAL")
+		}
+		r.Frequency = RECURRENCE_DAILY
+	case RECURRENCE_WEEKLY:
+		if bySetPos != "" || byMonthDay != "" {
+			return invalid("WEEKLY takes only INTERVAL and BYDAY")
+		}
+		r.Frequency = RECURRENCE_WEEKLY
+		if byDay != "" {
+			day, ok := rruleWeekdays[byDay]
+			if !ok {
+				return invalid("WEEKLY takes a single BYDAY, e.g. BYDAY=MO")
+			}
+			r.Weekday, r.hasWeekday = day, true
+		}
+	case RECURRENCE_MONTHLY:
+		switch {
+		case byDay != "" || bySetPos != "":
+			if byDay != "MO,TU,WE,TH,FR" || bySetPos != "-1" || byMonthDay != "" {
+				return invalid("monthly BYDAY is only supported as BYDAY=MO,TU,WE,TH,FR;BYSETPOS=-1 (last business day)")
+			}
+			r.Frequency = RECURRENCE_LAST_BUSINESS_DAY
+		case byMonthDay != "":
+			day, err := strconv.Atoi(byMonthDay)
+			if err != nil || day == 0 || day < -1 || day > 31 {
+				return invalid("BYMONTHDAY must be between 1 and 31, or -1 for the last day")
+			}
+			r.Frequency, r.MonthDay = RECURRENCE_MONTHLY, day
+		default:
+			r.Frequency = RECURRENCE_MONTHLY
+		}
+	case "":
+		return invalid("FREQ is required")
+	default:
+		return invalid("FREQ must be DAILY, WEEKLY or MONTHLY")
+	}
+
+	return r, nil
+}
+
+// occurrence returns the k-th date of the schedule counted from start, the
+// first one can be before start and is skipped by the caller.
+func (r Recurrence) occurrence(start time.Time, k int) time.Time {
+	hour, min, sec := start.Clock()
+	switch r.Frequency {
+	case RECURRENCE_DAILY:
+		return start.AddDate(0, 0, k*r.Interval)
+	case RECURRENCE_WEEKLY:
+		weekday := start.Weekday()
+		if r.hasWeekday {
+			weekday = r.Weekday
+		}
+		first := start.AddDate(0, 0, (int(weekday)-int(start.Weekday())+7)%7)
+		return first.AddDate(0, 0, 7*k*r.Interval)
+	}
+
+	// monthly schedules: count months from the start month, then pick the day
+	month := time.Date(start.Year(), start.Month()+time.Month(k*r.Interval), 1, hour, min, sec, 0, time.UTC)
+	last := month.AddDate(0, 1, -1)
+	if r.Frequency == RECURRENCE_LAST_BUSINESS_DAY {
+		for last.Weekday() == time.Saturday || last.Weekday() == time.Sunday {
+			last = last.AddDate(0, 0, -1)
+		}
+		return last
+	}
+	day := r.MonthDay
+	if day == 0 {
+		day = start.Day()
+	}
+	if day == -1 || day > last.Day() {
+		return last
+	}
+	return month.AddDate(0, 0, day-1)
+}
+
+// NextAfter returns the first occurrence of the schedule that is on or after
+// start and strictly after t.
+func (r Recurrence) NextAfter(start time.Time, t time.Time) time.Time {
+	start = start.UTC()
+	k := 0
+	if t.After(start) {
+		// jump close to t instead of walking from start, then step forward
+		switch r.Frequency {
+		case RECURRENCE_DAILY:
+			k = int(t.Sub(start).Hours()/24) / r.Interval
+		case RECURRENCE_WEEKLY:
+			k = int(t.Sub(start).Hours()/24)/(7*r.Interval) - 1
+		default:
+			months := (t.Year()-start.Year())*12 + int(t.Month()) - int(start.Month())
+			k = months/r.Interval - 1
+		}
+		if k < 0 {
+			k = 0
+		}
+	}
+	for {
+		next := r.occurrence(start, k)
+		if !next.Before(start) && next.After(t) {
+			return next
+		}
+		k++
+	}
+}
+
+// First returns the first occurrence on or after start.
+func (r Recurrence) First(start time.Time) time.Time {
+	return r.NextAfter(start, start.Add(-time.Nanosecond))
+}
+
+func (bt *BudgetTracker) SaveRecurringTransaction(ctx context.Context, userId string, req RecurringTransactionRequest) (RecurringTransaction, error) {
+	traceID := contextutil.TraceIDFromContext(ctx)
+
+	if err := validateTransactionRequest(TransactionRequest{
+		CategoryId:   req.CategoryId,
+		CategoryType: req.CategoryType,
+		Amount:       req.Amount,
+		Currency:     req.Currency,
+		Note:         req.Note,
+	}); err != nil {
+		return RecurringTransaction{}, err
+	}
+	if req.CategoryType != "+" && req.CategoryType != "-" {
+		return RecurringTransaction{}, appErrors.ErrorResponse{
+			Code:    appErrors.ErrInvalidInput,
+			Message: "Category type must be + or -",
+		}
+	}
+
+	recurrence, err := ParseRecurrenceRule(req.Rule)
+	if err != nil {
+		return RecurringTransaction{}, err
+	}
+
+	now := time.Now().UTC()
+	start := req.StartAt.UTC()
+	if req.StartAt.IsZero() {
+		start = time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+	}
+	if !req.EndAt.IsZero() && req.EndAt.Before(start) {
+		return RecurringTransaction{}, appErrors.ErrorResponse{
+			Code:    appErrors.ErrInvalidInput,
+			Message: "End date cannot be before the start date",
+		}
+	}
+
+	existing, err := bt.storage.GetRecurringTransactions(ctx, userId)
+	if err != nil {
+		logging.Logger.Errorf("[TraceID=%s] | storage.GetRecurringTransactions() failed in Service.SaveRecurringTransaction()", traceID)
+		return RecurringTransaction{}, err
+	}
+	if len(existing) >= MAX_RECURRING_PER_USER {
+		return RecurringTransaction{}, appErrors.ErrorResponse{
+			Code:    appErrors.ErrInvalidInput,
+			Message: fmt.Sprintf("Maximum %d recurring transactions are allowed", MAX_RECURRING_PER_USER),
+		}
+	}
+
+	r := RecurringTransaction{
+		ID:           uuid.New().String(),
+		CategoryId:   req.CategoryId,
+		CategoryType: req.CategoryType,
+		Amount:       req.Amount,
+		Currency:     req.Currency,
+		Note:         req.Note,
+		Rule:         req.Rule,
+		StartAt:      start,
+		CreatedAt:    now,
+		UpdatedAt:    now,
+		CreatedBy:    userId,
+	}
+	if !req.EndAt.IsZero() {
+		r.EndAt = req.EndAt.UTC()
+	}
+	r.NextRunAt = r.limit(recurrence.First(start))
+
+	if err := bt.storage.SaveRecurringTransaction(ctx, r); err != nil {
+		logging.Logger.Errorf("[TraceID=%s] | storage.SaveRecurringTransaction() failed in Service.SaveRecurringTransaction()", traceID)
+		return RecurringTransaction{}, err
+	}
+	return r, nil
+}
+
+// limit returns zero for an occurrence past the end date, it means the
+// schedule is finished.
+func (r RecurringTransaction) limit(next time.Time) time.Time {
+	if !r.EndAt.IsZero() && next.After(r.EndAt) {
+		return time.Time{}
+	}
+	return next
+}
+
+func (bt *BudgetTracker) GetRecurringTransactions(ctx context.Context, userId string) ([]RecurringTransaction, error) {
+	return bt.storage.GetRecurringTransactions(ctx, userId)
+}
+
+func (bt *BudgetTracker) DeleteRecurringTransaction(ctx context.Context, userId string, id string) error {
+	return bt.storage.DeleteRecurringTransaction(ctx, userId, id)
+}
+
+// PauseRecurringTransaction stops a schedule, occurrences that fall into
+// the pause are not created later.
+func (bt *BudgetTracker) PauseRecurringTransaction(ctx context.Context, userId string, id string) (RecurringTransaction, error) {
+	r, err := bt.storage.GetRecurringTransactionById(ctx, userId, id)
+	if err != nil {
+		return RecurringTransaction{}, err
+	}
+	if r.Paused {
+		return r, nil
+	}
+	r.Paused = true
+	r.PausedReason = ""
+	r.UpdatedAt = time.Now().UTC()
+	if err := bt.storage.UpdateRecurringSchedule(ctx, r); err != nil {
+		return RecurringTransaction{}, err
+	}
+	return r, nil
+}
+
+// ResumeRecurringTransaction restarts a paused schedule from its next
+// occurrence after now.
+func (bt *BudgetTracker) ResumeRecurringTransaction(ctx context.Context, userId string, id string) (RecurringTransaction, error) {
+	r, err := bt.storage.GetRecurringTransactionById(ctx, userId, id)
+	if err != nil {
+		return RecurringTransaction{}, err
+	}
+	if !r.Paused {
+		return r, nil
+	}
+	recurrence, err := ParseRecurrenceRule(r.Rule)
+	if err != nil {
+		return RecurringTransaction{}, err
+	}
+
+	now := time.Now().UTC()
+	r.Paused = false
+	r.PausedReason = ""
+	r.UpdatedAt = now
+	if !r.NextRunAt.IsZero() && r.NextRunAt.Before(now) {
+		r.NextRunAt = r.limit(recurrence.NextAfter(r.StartAt, now))
+	}
+	if err := bt.storage.UpdateRecurringSchedule(ctx, r); err != nil {
+		return RecurringTransaction{}, err
+	}
+	return r, nil
+}
+
+// SkipRecurringTransaction drops the next occurrence, e.g. a month the rent
+// was paid another way.
+func (bt *BudgetTracker) SkipRecurringTransaction(ctx context.Context, userId string, id string) (RecurringTransaction, error) {
+	r, err := bt.storage.GetRecurringTransactionById(ctx, userId, id)
+	if err != nil {
+		return RecurringTransaction{}, err
+	}
+	if r.NextRunAt.IsZero() {
+		return RecurringTransaction{}, appErrors.ErrorResponse{
+			Code:    appErrors.ErrInvalidInput,
+			Message: "The schedule has ended, there is nothing to skip",
+		}
+	}
+	recurrence, err := ParseRecurrenceRule(r.Rule)
+	if err != nil {
+		return RecurringTransaction{}, err
+	}
+
+	r.NextRunAt = r.limit(recurrence.NextAfter(r.StartAt, r.NextRunAt))
+	r.UpdatedAt = time.Now().UTC()
+	if err := bt.storage.UpdateRecurringSchedule(ctx, r); err != nil {
+		return RecurringTransaction{}, err
+	}
+	return r, nil
+}
+
+// GetUpcomingTransactions lists what the active schedules will create in
+// the next days, soonest first.
+func (bt *BudgetTracker) GetUpcomingTransactions(ctx context.Context, userId string, days int) ([]UpcomingTransaction, error) {
+	if days == 0 {
+		days = DEFAULT_UPCOMING_DAYS
+	}
+	if days < 1 || days > MAX_UPCOMING_DAYS {
+		return nil, appErrors.ErrorResponse{
+			Code:    appErrors.ErrInvalidInput,
+			Message: fmt.Sprintf("Days must be between 1 and %d", MAX_UPCOMING_DAYS),
+		}
+	}
+
+	recurring, err := bt.storage.GetRecurringTransactions(ctx, userId)
+	if err != nil {
+		return nil, err
+	}
+
+	until := time.Now().UTC().AddDate(0, 0, days)
+	upcoming := []UpcomingTransaction{}
+	for _, r := range recurring {
+		if r.Paused || r.NextRunAt.IsZero() {
+			continue
+		}
+		recurrence, err := ParseRecurrenceRule(r.Rule)
+		if err != nil {
+			continue
+		}
+		for next := r.NextRunAt; !next.IsZero() && !next.After(until); next = r.limit(recurrence.NextAfter(r.StartAt, next)) {
+			upcoming = append(upcoming, UpcomingTransaction{
+				RecurringId:  r.ID,
+				CategoryId:   r.CategoryId,
+				CategoryType: r.CategoryType,
+				Amount:       r.Amount,
+				Currency:     r.Currency,
+				Note:         r.Note,
+				OccursAt:     next,
+			})
+		}
+	}
+
+	sort.SliceStable(upcoming, func(i, j int) bool { return upcoming[i].OccursAt.Before(upcoming[j].OccursAt) })
+	return upcoming, nil
+}
+
+// MaterializeRecurringTransactions creates every instance that is due,
+// including the ones missed while the server was down, and returns how many
+// were created. Each instance is saved together with the next run of its
+// schedule, so concurrent runs cannot create it twice. A schedule whose
+// category no longer accepts transactions is paused with the reason instead
+// of failing on every run.
+func (bt *BudgetTracker) MaterializeRecurringTransactions(ctx context.Context) (int, error) {
+	traceID := contextutil.TraceIDFromContext(ctx)
+
+	now := time.Now().UTC()
+	due, err := bt.storage.GetDueRecurringTransactions(ctx, now)
+	if err != nil {
+		return 0, err
+	}
+
+	created := 0
+	for _, r := range due {
+		if ctx.Err() != nil {
+			// shutting down, the next run picks up the rest
+			break
+		}
+		recurrence, err := ParseRecurrenceRule(r.Rule)
+		if err != nil {
+			logging.Logger.Errorf("[TraceID=%s] | invalid rule of recurring transaction %s in Service.MaterializeRecurringTransactions() | Error: %v", traceID, r.ID, err)
+			continue
+		}
+
+		for n := 0; !r.NextRunAt.IsZero() && !r.NextRunAt.After(now) && n < MAX_RECURRING_CATCH_UP; n++ {
+			dueAt := r.NextRunAt
+			txn, err := bt.newTransaction(ctx, r.CreatedBy, TransactionRequest{
+				CategoryId:   r.CategoryId,
+				CategoryType: r.CategoryType,
+				Amount:       r.Amount,
+				Currency:     r.Currency,
+				Note:         r.Note,
+				OccurredAt:   dueAt,
+			})
+			if err == nil {
+				r.NextRunAt = r.limit(recurrence.NextAfter(r.StartAt, dueAt))
+				r.UpdatedAt = now
+				err = bt.storage.SaveRecurringInstance(ctx, r, txn)
+			}
+			if err != nil {
+				var appErr appErrors.ErrorResponse
+				if errors.As(err, &appErr) && appErr.Code == appErrors.ErrConflict {
+					// another run got to it first
+					break
+				}
+				logging.Logger.Errorf("[TraceID=%s] | failed to create instance of recurring transaction %s in Service.MaterializeRecurringTransactions() | Error: %v", traceID, r.ID, err)
+				if errors.As(err, &appErr) && appErr.Code == appErrors.ErrInvalidInput {
+					r.NextRunAt = dueAt
+					r.Paused = true
+					r.PausedReason = appErr.Message
+					r.UpdatedAt = now
+					if err := bt.storage.UpdateRecurringSchedule(ctx, r); err != nil {
+						logging.Logger.Errorf("[TraceID=%s] | failed to pause recurring transaction %s | Error: %v", traceID, r.ID, err)
+					}
+				}
+				break
+			}
+			created++
+			bt.learnCategories(r.CreatedBy, txn)
+		}
+	}
+
+	return created, nil
+}
+
+// RunRecurringScheduler calls MaterializeRecurringTransactions every
+// interval until ctx is done. The first run happens right away, which is
+// what catches up after a restart.
+func (bt *BudgetTracker) RunRecurringScheduler(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		traceID := uuid.NewString()
+		runCtx := context.WithValue(ctx, contextutil.TraceIDKey, traceID)
+		created, err := bt.MaterializeRecurringTransactions(runCtx)
+		if err != nil {
+			logging.Logger.Errorf("[TraceID=%s] | recurring transactions run failed | Error: %v", traceID, err)
+		} else if created > 0 {
+			logging.Logger.Infof("[TraceID=%s] | created %d recurring transaction(s)", traceID, created)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,739 @@
+package budget
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	appErrors "github.com/fatali-fataliyev/budget_tracker/customErrors"
+	"github.com/fatali-fataliyev/budget_tracker/internal/contextutil"
+	"github.com/fatali-fataliyev/budget_tracker/logging"
+	"github.com/google/uuid"
+)
+
+const (
+	MAX_RULE_NAME_LENGTH    = 100
+	MAX_RULE_PATTERN_LENGTH = 200
+	MAX_RULES_PER_USER      = 200
+	MAX_RULE_PRIORITY       = 10000
+	// MAX_RULE_TEST_RESULTS caps the changes listed by a rule test, the
+	// counts cover every transaction.
+	MAX_RULE_TEST_RESULTS = 200
+)
+
+// Rule categorizes transactions automatically. A transaction matches when
+// it meets every condition that is set. Rules run by priority, lowest
+// first; the first rule that sets the category or the note decides it and
+// the tags of all matching rules add up.
+type Rule struct {
+	ID         string
+	Name       string
+	Priority   int
+	Enabled    bool
+	Conditions RuleConditions
+	Actions    RuleActions
+	CreatedAt  time.Time
+	UpdatedAt  time.Time
+	CreatedBy  string
+}
+
+type RuleConditions struct {
+	CategoryType string         // "+" or "-", empty matches both
+	NotePattern  string         // regular expression, case-insensitive
+	PayeePattern string         // regular expression on the payee name, case-insensitive
+	AmountMin    float64        // zero means no lower bound
+	AmountMax    float64        // zero means no upper bound
+	Currency     string         // ISO code
+	Weekdays     []time.Weekday // of the day the money moved, empty matches every day
+}
+
+type RuleActions struct {
+	CategoryId string   // of Conditions.CategoryType
+	Tags       []string // added to the transaction, missing tags are created
+	Note       string   // replaces the note
+}
+
+type RuleRequest struct {
+	Name       string
+	Priority   int
+	Enabled    bool
+	Conditions RuleConditions
+	Actions    RuleActions
+}
+
+// RuleChange is what rules do to one transaction.
+type RuleChange struct {
+	TransactionId string
+	OccurredAt    time.Time
+	Amount        float64
+	Currency      string
+	CategoryType  string
+	OldCategoryId string
+	CategoryId    string
+	OldNote       string
+	Note          string
+	AddedTags     []string
+	Tags          []string // all tags after the change
+	RuleIds       []string // the rules that matched, in the order they ran
+}
+
+// Changed tells whether the rules changed anything on the transaction.
+func (c RuleChange) Changed() bool {
+	return c.CategoryId != c.OldCategoryId || c.Note != c.OldNote || len(c.AddedTags) > 0
+}
+
+type RuleApplyReport struct {
+	DryRun  bool
+	Matched int
+	Changed int
+	Skipped int // matched, but reconciled or split
+	Changes []RuleChange
+}
+
+// ruleSubject is what rules look at, a saved transaction, a new one or an
+// imported or scanned one before it becomes a transaction.
+type ruleSubject struct {
+	CategoryType string
+	Note         string
+	Payee        string
+	Amount       float64
+	Currency     string
+	OccurredAt   time.Time
+}
+
+type ruleOutcome struct {
+	CategoryId string
+	Note       string
+	Tags       []string
+	RuleIds    []string
+}
+
+type compiledRule struct {
+	Rule
+	note  *regexp.Regexp
+	payee *regexp.Regexp
+}
+
+// compileRules keeps the enabled rules in the order they run. A rule whose
+// pattern no longer compiles is left out, one whose category is not in
+// categories, the live ones, only keeps its other actions.
+func compileRules(rules []Rule, categories map[string]bool) []compiledRule {
+	compiled := make([]compiledRule, 0, len(rules))
+	for _, r := range rules {
+		if !r.Enabled {
+			continue
+		}
+		if !categories[r.Actions.CategoryId] {
+			r.Actions.CategoryId = ""
+		}
+		c := compiledRule{Rule: r}
+		var err error
+		if r.Conditions.NotePattern != "" {
+			if c.note, err = regexp.Compile("(?i)" + r.Conditions.NotePattern); err != nil {
+				continue
+			}
+		}
+		if r.Conditions.PayeePattern != "" {
+			if c.payee, err = regexp.Compile("(?i)" + r.Conditions.PayeePattern); err != nil {
+				continue
+			}
+		}
+		compiled = append(compiled, c)
+	}
+	sort.SliceStable(compiled, func(i, j int) bool {
+		if compiled[i].Priority != compiled[j].Priority {
+			return compiled[i].Priority < compiled[j].Priority
+		}
+		return compiled[i].CreatedAt.Before(compiled[j].CreatedAt)
+	})
+	return compiled
+}
+
+func (r compiledRule) matches(s ruleSubject) bool {
+	c := r.Conditions
+	if c.CategoryType != "" && c.CategoryType != s.CategoryType {
+		return false
+	}
+	if r.note != nil && !r.note.MatchString(s.Note) {
+		return false
+	}
+	if r.payee != nil && (s.Payee == "" || !r.payee.MatchString(s.Payee)) {
+		return false
+	}
+	if c.AmountMin > 0 && toCents(s.Amount) < toCents(c.AmountMin) {
+		return false
+	}
+	if c.AmountMax > 0 && toCents(s.Amount) > toCents(c.AmountMax) {
+		return false
+	}
+	if c.Currency != "" && !strings.EqualFold(c.Currency, s.Currency) {
+		return false
+	}
+	if len(c.Weekdays) > 0 {
+		weekday := s.OccurredAt.UTC().Weekday()
+		found := false
+		for _, d := range c.Weekdays {
+			found = found || d == weekday
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
+
+// runRules runs the rules on a subject. The category of a rule only applies
+// to subjects of its type, which its conditions make sure of.
+func runRules(rules []compiledRule, s ruleSubject) ruleOutcome {
+	var out ruleOutcome
+	for _, r := range rules {
+		if !r.matches(s) {
+			continue
+		}
+		out.RuleIds = append(out.RuleIds, r.ID)
+		if out.CategoryId == "" {
+			out.CategoryId = r.Actions.CategoryId
+		}
+		if out.Note == "" {
+			out.Note = r.Actions.Note
+		}
+		out.Tags = mergeTags(out.Tags, r.Actions.Tags)
+	}
+	return out
+}
+
+// mergeTags adds the normalized tags in extra that base does not have yet,
+// as long as the transaction limit allows, and sorts the result.
+func mergeTags(base []string, extra []string) []string {
+	has := make(map[string]bool, len(base))
+	merged := append([]string{}, base...)
+	for _, tag := range base {
+		has[tag] = true
+	}
+	for _, tag := range extra {
+		if !has[tag] && len(merged) < MAX_TAGS_PER_TRANSACTION {
+			has[tag] = true
+			merged = append(merged, tag)
+		}
+	}
+	sort.Strings(merged)
+	return merged
+}
+
+func parseRuleWeekdays(days []time.Weekday) ([]time.Weekday, error) {
+	seen := map[time.Weekday]bool{}
+	var result []time.Weekday
+	for _, d := range days {
+		if d < time.Sunday || d > time.Saturday {
+			return nil, appErrors.ErrorResponse{
+				Code:    appErrors.ErrInvalidInput,
+				Message: "Invalid weekday",
+			}
+		}
+		if !seen[d] {
+			seen[d] = true
+			result = append(result, d)
+		}
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i] < result[j] })
+	return result, nil
+}
+
+func validateRulePattern(field string, pattern string) error {
+	if len(pattern) > MAX_RULE_PATTERN_LENGTH {
+		return appErrors.ErrorResponse{
+			Code:    appErrors.ErrInvalidInput,
+			Message: fmt.Sprintf("%s pattern so long, maximum allowed length is %d", field, MAX_RULE_PATTERN_LENGTH),
+		}
+	}
+	if _, err := regexp.Compile("(?i)" + pattern); err != nil {
+		return appErrors.ErrorResponse{
+			Code:    appErrors.ErrInvalidInput,
+			Message: fmt.Sprintf("Invalid %s regular expression: %s", strings.ToLower(field), pattern),
+		}
+	}
+	return nil
+}
+
+// validateRuleRequest normalizes a rule and checks its category exists with
+// the type the rule matches.
+func (bt *BudgetTracker) validateRuleRequest(ctx context.Context, userId string, req RuleRequest) (RuleRequest, error) {
+	req.Name = strings.Join(strings.Fields(req.Name), " ")
+	if req.Name == "" {
+		return RuleRequest{}, appErrors.ErrorResponse{
+			Code:    appErrors.ErrInvalidInput,
+			Message: "Rule name cannot be empty!",
+		}
+	}
+	if len(req.Name) > MAX_RULE_NAME_LENGTH {
+		return RuleRequest{}, appErrors.ErrorResponse{
+			Code:    appErrors.ErrInvalidInput,
+			Message: fmt.Sprintf("Rule name so long, maximum allowed length is %d", MAX_RULE_NAME_LENGTH),
+		}
+	}
+	if req.Priority < 0 || req.Priority > MAX_RULE_PRIORITY {
+		return RuleRequest{}, appErrors.ErrorResponse{
+			Code:    appErrors.ErrInvalidInput,
+			Message: fmt.Sprintf("Priority must be between 0 and %d", MAX_RULE_PRIORITY),
+		}
+	}
+
+	c := &req.Conditions
+	if c.CategoryType != "" && c.CategoryType != "+" && c.CategoryType != "-" {
+		return RuleRequest{}, appErrors.ErrorResponse{
+			Code:    appErrors.ErrInvalidInput,
+			Message: "Invalid category type, use expense or income.",
+		}
+	}
+	if c.NotePattern != "" {
+		if err := validateRulePattern("Note", c.NotePattern); err != nil {
+			return RuleRequest{}, err
+		}
+	}
+	if c.PayeePattern != "" {
+		if err := validateRulePattern("Payee", c.PayeePattern); err != nil {
+			return RuleRequest{}, err
+		}
+	}
+	if c.AmountMin < 0 || c.AmountMax < 0 || (c.AmountMax > 0 && c.AmountMin > c.AmountMax) {
+		return RuleRequest{}, appErrors.ErrorResponse{
+			Code:    appErrors.ErrInvalidInput,
+			Message: "Invalid amount range",
+		}
+	}
+	c.Currency = strings.ToUpper(strings.TrimSpace(c.Currency))
+	weekdays, err := parseRuleWeekdays(c.Weekdays)
+	if err != nil {
+		return RuleRequest{}, err
+	}
+	c.Weekdays = weekdays
+	if c.NotePattern == "" && c.PayeePattern == "" && c.AmountMin == 0 && c.AmountMax == 0 && c.Currency == "" && len(c.Weekdays) == 0 {
+		return RuleRequest{}, appErrors.ErrorResponse{
+			Code:    appErrors.ErrInvalidInput,
+			Message: "A rule needs at least one condition besides the category type",
+		}
+	}
+
+	a := &req.Actions
+	tags, err := normalizeTags(a.Tags)
+	if err != nil {
+		return RuleRequest{}, err
+	}
+	a.Tags = tags
+	a.Note = strings.TrimSpace(a.Note)
+	if len(a.Note) > MAX_TRANSACTION_NOTE_LENGTH {
+		return RuleRequest{}, appErrors.ErrorResponse{
+			Code:    appErrors.ErrInvalidInput,
+			Message: fmt.Sprintf("Note so long, maximum allowed note length is %d", MAX_TRANSACTION_NOTE_LENGTH),
+		}
+	}
+	if a.CategoryId == "" && len(a.Tags) == 0 && a.Note == "" {
+		return RuleRequest{}, appErrors.ErrorResponse{
+			Code:    appErrors.ErrInvalidInput,
+			Message: "A rule needs at least one action",
+		}
+	}
+	if a.CategoryId != "" {
+		if c.CategoryType == "" {
+			return RuleRequest{}, appErrors.ErrorResponse{
+				Code:    appErrors.ErrInvalidInput,
+				Message: "A rule that sets the category must match one category type",
+			}
+		}
+		expenseIds, incomeIds, err := bt.liveCategoryIds(ctx, userId)
+		if err != nil {
+			return RuleRequest{}, err
+		}
+		known := expenseIds
+		if c.CategoryType == "+" {
+			known = incomeIds
+		}
+		if !known[a.CategoryId] {
+			return RuleRequest{}, appErrors.ErrorResponse{
+				Code:    appErrors.ErrNotFound,
+				Message: "Rule category not found.",
+			}
+		}
+	}
+	return req, nil
+}
+
+func (bt *BudgetTracker) SaveRule(ctx context.Context, userId string, req RuleRequest) (Rule, error) {
+	traceID := contextutil.TraceIDFromContext(ctx)
+
+	req, err := bt.validateRuleRequest(ctx, userId, req)
+	if err != nil {
+		return Rule{}, err
+	}
+	existing, err := bt.storage.GetRules(ctx, userId)
+	if err != nil {
+		logging.Logger.Errorf("[TraceID=%s] | storage.GetRules() failed in Service.SaveRule()", traceID)
+		return Rule{}, err
+	}
+	if len(existing) >= MAX_RULES_PER_USER {
+		return Rule{}, appErrors.ErrorResponse{
+			Code:    appErrors.ErrInvalidInput,
+			Message: fmt.Sprintf("Maximum %d rules are allowed", MAX_RULES_PER_USER),
+		}
+	}
+	if err := bt.ensureTags(ctx, userId, req.Actions.Tags); err != nil {
+		return Rule{}, err
+	}
+
+	now := time.Now().UTC()
+	r := Rule{
+		ID:         uuid.New().String(),
+		Name:       req.Name,
+		Priority:   req.Priority,
+		Enabled:    req.Enabled,
+		Conditions: req.Conditions,
+		Actions:    req.Actions,
+		CreatedAt:  now,
+		UpdatedAt:  now,
+		CreatedBy:  userId,
+	}
+	if err := bt.storage.SaveRule(ctx, r); err != nil {
+		logging.Logger.Errorf("[TraceID=%s] | storage.SaveRule() failed in Service.SaveRule()", traceID)
+		return Rule{}, err
+	}
+	return r, nil
+}
+
+// GetRules returns the rules of a user in the order they run.
+func (bt *BudgetTracker) GetRules(ctx context.Context, userId string) ([]Rule, error) {
+	rules, err := bt.storage.GetRules(ctx, userId)
+	if err != nil {
+		return nil, err
+	}
+	sort.SliceStable(rules, func(i, j int) bool {
+		if rules[i].Priority != rules[j].Priority {
+			return rules[i].Priority < rules[j].Priority
+		}
+		return rules[i].CreatedAt.Before(rules[j].CreatedAt)
+	})
+	return rules, nil
+}
+
+func (bt *BudgetTracker) GetRule(ctx context.Context, userId string, id string) (Rule, error) {
+	return bt.storage.GetRuleById(ctx, userId, id)
+}
+
+// UpdateRule replaces a rule. Transactions it already changed stay as they
+// are.
+func (bt *BudgetTracker) UpdateRule(ctx context.Context, userId string, id string, req RuleRequest) (Rule, error) {
+	r, err := bt.storage.GetRuleById(ctx, userId, id)
+	if err != nil {
+		return Rule{}, err
+	}
+	req, err = bt.validateRuleRequest(ctx, userId, req)
+	if err != nil {
+		return Rule{}, err
+	}
+	if err := bt.ensureTags(ctx, userId, req.Actions.Tags); err != nil {
+		return Rule{}, err
+	}
+
+	r.Name = req.Name
+	r.Priority = req.Priority
+	r.Enabled = req.Enabled
+	r.Conditions = req.Conditions
+	r.Actions = req.Actions
+	r.UpdatedAt = time.Now().UTC()
+	if err := bt.storage.UpdateRule(ctx, r); err != nil {
+		return Rule{}, err
+	}
+	return r, nil
+}
+
+func (bt *BudgetTracker) DeleteRule(ctx context.Context, userId string, id string) error {
+	return bt.storage.DeleteRule(ctx, userId, id)
+}
+
+// liveCategoryIds returns the IDs of the expense and income categories of a
+// user that transactions can be added to, archived ones are left out.
+func (bt *BudgetTracker) liveCategoryIds(ctx context.Context, userId string) (map[string]bool, map[string]bool, error) {
+	expenseCategories, err := bt.storage.GetFilteredExpenseCategories(ctx, userId, &ExpenseCategoryList{IsAllNil: true})
+	if err != nil {
+		return nil, nil, err
+	}
+	incomeCategories, err := bt.storage.GetFilteredIncomeCategories(ctx, userId, &IncomeCategoryList{IsAllNil: true})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	expenseIds := make(map[string]bool, len(expenseCategories))
+	for _, c := range expenseCategories {
+		if c.ArchivedAt.IsZero() {
+			expenseIds[c.ID] = true
+		}
+	}
+	incomeIds := make(map[string]bool, len(incomeCategories))
+	for _, c := range incomeCategories {
+		if c.ArchivedAt.IsZero() {
+			incomeIds[c.ID] = true
+		}
+	}
+	return expenseIds, incomeIds, nil
+}
+
+// ruleCategories is the set of live categories a rule can set, of both types.
+func (bt *BudgetTracker) ruleCategories(ctx context.Context, userId string) (map[string]bool, error) {
+	expenseIds, incomeIds, err := bt.liveCategoryIds(ctx, userId)
+	if err != nil {
+		return nil, err
+	}
+	for id := range incomeIds {
+		expenseIds[id] = true
+	}
+	return expenseIds, nil
+}
+
+// userRules loads the enabled rules of a user, ready to run. The category of
+// a rule is dropped once it is deleted or archived.
+func (bt *BudgetTracker) userRules(ctx context.Context, userId string) ([]compiledRule, error) {
+	rules, err := bt.storage.GetRules(ctx, userId)
+	if err != nil {
+		return nil, err
+	}
+	categories, err := bt.ruleCategories(ctx, userId)
+	if err != nil {
+		return nil, err
+	}
+	return compileRules(rules, categories), nil
+}
+
+// applyTransactionRules runs the rules on a new transaction sent without a
+// category. Transactions with a category, splits or transfers are left as
+// they are.
+func (bt *BudgetTracker) applyTransactionRules(ctx context.Context, userId string, transaction *TransactionRequest, payee Payee) error {
+	traceID := contextutil.TraceIDFromContext(ctx)
+
+	if transaction.CategoryId != "" || len(transaction.Splits) > 0 || transaction.CategoryType == TRANSFER_TYPE {
+		return nil
+	}
+	rules, err := bt.userRules(ctx, userId)
+	if err != nil {
+		logging.Logger.Errorf("[TraceID=%s] | storage.GetRules() failed in Service.applyTransactionRules()", traceID)
+		return err
+	}
+	occurredAt := transaction.OccurredAt
+	if occurredAt.IsZero() {
+		occurredAt = time.Now().UTC()
+	}
+	out := runRules(rules, ruleSubject{
+		CategoryType: transaction.CategoryType,
+		Note:         transaction.Note,
+		Payee:        payee.Name,
+		Amount:       transaction.Amount,
+		Currency:     transaction.Currency,
+		OccurredAt:   occurredAt,
+	})
+	transaction.CategoryId = out.CategoryId
+	if out.Note != "" {
+		transaction.Note = out.Note
+	}
+	if len(out.Tags) > 0 {
+		tags, err := normalizeTags(transaction.Tags)
+		if err != nil {
+			return err
+		}
+		transaction.Tags = mergeTags(tags, out.Tags)
+	}
+	return nil
+}
+
+// payeeNames maps payee IDs to names for the payee conditions of rules.
+func (bt *BudgetTracker) payeeNames(ctx context.Context, userId string) (map[string]string, error) {
+	payees, err := bt.storage.GetPayees(ctx, userId)
+	if err != nil {
+		return nil, err
+	}
+	names := make(map[string]string, len(payees))
+	for _, p := range payees {
+		names[p.ID] = p.Name
+	}
+	return names, nil
+}
+
+// ruleChanges runs rules over saved transactions and returns the ones they
+// match. Transfers are never matched, a rule only sets the category of a
+// split transaction through its splits, so those are skipped along with
+// reconciled ones, which are locked.
+func ruleChanges(rules []compiledRule, transactions []Transaction, payees map[string]string) ([]RuleChange, int) {
+	var changes []RuleChange
+	skipped := 0
+	for _, t := range transactions {
+		if t.CategoryType == TRANSFER_TYPE {
+			continue
+		}
+		out := runRules(rules, ruleSubject{
+			CategoryType: t.CategoryType,
+			Note:         t.Note,
+			Payee:        payees[t.PayeeId],
+			Amount:       t.Amount,
+			Currency:     t.Currency,
+			OccurredAt:   t.OccurredAt,
+		})
+		if len(out.RuleIds) == 0 {
+			continue
+		}
+		if len(t.Splits) > 0 || !t.ReconciledAt.IsZero() {
+			skipped++
+			continue
+		}
+
+		change := RuleChange{
+			TransactionId: t.ID,
+			OccurredAt:    t.OccurredAt,
+			Amount:        t.Amount,
+			Currency:      t.Currency,
+			CategoryType:  t.CategoryType,
+			OldCategoryId: t.CategoryId,
+			CategoryId:    t.CategoryId,
+			OldNote:       t.Note,
+			Note:          t.Note,
+			Tags:          t.Tags,
+			RuleIds:       out.RuleIds,
+		}
+		if out.CategoryId != "" {
+			change.CategoryId = out.CategoryId
+		}
+		if out.Note != "" {
+			change.Note = out.Note
+		}
+		if merged := mergeTags(t.Tags, out.Tags); len(merged) > len(t.Tags) {
+			has := make(map[string]bool, len(t.Tags))
+			for _, tag := range t.Tags {
+				has[tag] = true
+			}
+			for _, tag := range merged {
+				if !has[tag] {
+					change.AddedTags = append(change.AddedTags, tag)
+				}
+			}
+			change.Tags = merged
+		}
+		changes = append(changes, change)
+	}
+	return changes, skipped
+}
+
+// TestRule runs a rule that does not have to be saved over the transaction
+// history and reports what it would change. Other rules are not run.
+func (bt *BudgetTracker) TestRule(ctx context.Context, userId string, req RuleRequest) (RuleApplyReport, error) {
+	traceID := contextutil.TraceIDFromContext(ctx)
+
+	req, err := bt.validateRuleRequest(ctx, userId, req)
+	if err != nil {
+		return RuleApplyReport{}, err
+	}
+	transactions, err := bt.storage.GetFilteredTransactions(ctx, userId, &TransactionList{IsAllNil: true})
+	if err != nil {
+		logging.Logger.Errorf("[TraceID=%s] | storage.GetFilteredTransactions() failed in Service.TestRule()", traceID)
+		return RuleApplyReport{}, err
+	}
+	payees, err := bt.payeeNames(ctx, userId)
+	if err != nil {
+		logging.Logger.Errorf("[TraceID=%s] | storage.GetPayees() failed in Service.TestRule()", traceID)
+		return RuleApplyReport{}, err
+	}
+
+	// the category was just checked by validateRuleRequest
+	categories := map[string]bool{req.Actions.CategoryId: true}
+	rules := compileRules([]Rule{{ID: "test", Name: req.Name, Enabled: true, Conditions: req.Conditions, Actions: req.Actions}}, categories)
+	changes, skipped := ruleChanges(rules, transactions, payees)
+	report := RuleApplyReport{DryRun: true, Matched: len(changes) + skipped, Skipped: skipped}
+	for _, c := range changes {
+		if c.Changed() {
+			report.Changed++
+		}
+		if len(report.Changes) < MAX_RULE_TEST_RESULTS {
+			report.Changes = append(report.Changes, c)
+		}
+	}
+	return report, nil
+}
+
+// ApplyRules runs the enabled rules, or only the given ones, over the whole
+// transaction history, in priority order like on new transactions. Unlike
+// on new transactions the category is replaced. With dryRun nothing is
+// saved.
+func (bt *BudgetTracker) ApplyRules(ctx context.Context, userId string, ruleIds []string, dryRun bool) (RuleApplyReport, error) {
+	traceID := contextutil.TraceIDFromContext(ctx)
+
+	all, err := bt.storage.GetRules(ctx, userId)
+	if err != nil {
+		logging.Logger.Errorf("[TraceID=%s] | storage.GetRules() failed in Service.ApplyRules()", traceID)
+		return RuleApplyReport{}, err
+	}
+	selected := all
+	if len(ruleIds) > 0 {
+		byId := make(map[string]Rule, len(all))
+		for _, r := range all {
+			byId[r.ID] = r
+		}
+		selected = nil
+		for _, id := range ruleIds {
+			r, ok := byId[id]
+			if !ok {
+				return RuleApplyReport{}, appErrors.ErrorResponse{
+					Code:    appErrors.ErrNotFound,
+					Message: "Rule not found.",
+				}
+			}
+			selected = append(selected, r)
+		}
+	}
+
+	transactions, err := bt.storage.GetFilteredTransactions(ctx, userId, &TransactionList{IsAllNil: true})
+	if err != nil {
+		logging.Logger.Errorf("[TraceID=%s] | storage.GetFilteredTransactions() failed in Service.ApplyRules()", traceID)
+		return RuleApplyReport{}, err
+	}
+	payees, err := bt.payeeNames(ctx, userId)
+	if err != nil {
+		logging.Logger.Errorf("[TraceID=%s] | storage.GetPayees() failed in Service.ApplyRules()", traceID)
+		return RuleApplyReport{}, err
+	}
+
+	categories, err := bt.ruleCategories(ctx, userId)
+	if err != nil {
+		logging.Logger.Errorf("[TraceID=%s] | storage.GetFilteredExpenseCategories() failed in Service.ApplyRules()", traceID)
+		return RuleApplyReport{}, err
+	}
+
+	matched, skipped := ruleChanges(compileRules(selected, categories), transactions, payees)
+	report := RuleApplyReport{DryRun: dryRun, Matched: len(matched) + skipped, Skipped: skipped}
+	tagSet := map[string]bool{}
+	var tags []string
+	for _, c := range matched {
+		if !c.Changed() {
+			continue
+		}
+		report.Changes = append(report.Changes, c)
+		for _, tag := range c.AddedTags {
+			if !tagSet[tag] {
+				tagSet[tag] = true
+				tags = append(tags, tag)
+			}
+		}
+	}
+	report.Changed = len(report.Changes)
+	if dryRun || report.Changed == 0 {
+		return report, nil
+	}
+
+	newTags, err := bt.newTags(ctx, userId, tags)
+	if err != nil {
+		return RuleApplyReport{}, err
+	}
+	if err := bt.storage.SaveRuleChanges(ctx, userId, newTags, report.Changes); err != nil {
+		logging.Logger.Errorf("[TraceID=%s] | storage.SaveRuleChanges() failed in Service.ApplyRules()", traceID)
+		return RuleApplyReport{}, err
+	}
+	bt.forgetCategories(userId)
+	return report, nil
+}
@@ -0,0 +1,425 @@
+package budget
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"strings"
+	"time"
+
+	appErrors "github.com/fatali-fataliyev/budget_tracker/customErrors"
+	"github.com/fatali-fataliyev/budget_tracker/internal/contextutil"
+	"github.com/fatali-fataliyev/budget_tracker/logging"
+	"github.com/google/uuid"
+)
+
+const (
+	GOAL_REACHED  = "reached"
+	GOAL_ON_TRACK = "on_track"
+	GOAL_BEHIND   = "behind"
+	GOAL_OVERDUE  = "overdue" // the target date passed before the goal was reached
+
+	CONTRIBUTION_DEPOSIT    = "contribution"
+	CONTRIBUTION_WITHDRAWAL = "withdrawal"
+
+	MAX_GOAL_NAME_LENGTH = 255
+	MAX_GOALS_PER_USER   = 100
+
+	// daysPerMonth is the length of an average month, used for projections.
+	daysPerMonth = 365.25 / 12
+)
+
+// SavingsGoal is an amount to save by a date, optionally in a wallet. What is
+// saved is the sum of its contributions minus its withdrawals.
+type SavingsGoal struct {
+	ID           string
+	Name         string
+	TargetAmount float64
+	Currency     string
+	TargetDate   time.Time
+	WalletId     string // empty when not linked to a wallet
+	Note         string
+	Saved        float64 // filled by storage when reading
+	CreatedAt    time.Time
+	UpdatedAt    time.Time
+	CreatedBy    string
+}
+
+type SavingsGoalRequest struct {
+	Name         string
+	TargetAmount float64
+	Currency     string // the wallet's currency when a wallet is linked
+	TargetDate   time.Time
+	WalletId     string
+	Note         string
+}
+
+// SavingsContribution moves money into a goal, or out of it when Amount is
+// negative.
+type SavingsContribution struct {
+	ID         string
+	GoalId     string
+	Amount     float64
+	OccurredAt time.Time
+	Note       string
+	CreatedAt  time.Time
+	CreatedBy  string
+}
+
+type SavingsContributionRequest struct {
+	Type       string  // CONTRIBUTION_DEPOSIT or CONTRIBUTION_WITHDRAWAL
+	Amount     float64 // positive for both types
+	OccurredAt time.Time
+	Note       string
+}
+
+// SavingsGoalProgress is a goal with where it stands today.
+type SavingsGoalProgress struct {
+	Goal               SavingsGoal
+	Remaining          float64
+	Percent            int
+	MonthsLeft         int       // whole months until the target date, 0 once it passed
+	RequiredMonthly    float64   // to contribute every month from now on to reach the target in time
+	AverageMonthly     float64   // net contributions per month since the goal was created
+	ProjectedDate      time.Time // when the goal is reached at the average pace, zero when it is not growing
+	Status             string
+	WalletBalance      float64 // balance of the linked wallet
+	WalletBalanceShort bool    // the linked wallet holds less than what is saved
+}
+
+// SavingsHistoryEntry is a contribution or withdrawal with the saved amount
+// after it.
+type SavingsHistoryEntry struct {
+	Contribution SavingsContribution
+	Saved        float64
+}
+
+// goalProgress works out the projection of a goal at now. A goal is on track
+// while it has at least the share of the target that the elapsed share of its
+// time asks for.
+func goalProgress(g SavingsGoal, now time.Time) SavingsGoalProgress {
+	p := SavingsGoalProgress{Goal: g}
+
+	target, saved := toCents(g.TargetAmount), toCents(g.Saved)
+	remaining := max(target-saved, 0)
+	p.Remaining = float64(remaining) / 100
+	if target > 0 {
+		p.Percent = int(float64(saved) / float64(target) * 100)
+	}
+
+	daysLeft := g.TargetDate.Sub(now).Hours() / 24
+	if daysLeft > 0 {
+		p.MonthsLeft = int(math.Ceil(daysLeft / daysPerMonth))
+	}
+	switch {
+	case remaining == 0:
+		p.RequiredMonthly = 0
+	case p.MonthsLeft == 0:
+		p.RequiredMonthly = p.Remaining
+	default:
+		p.RequiredMonthly = float64((remaining+int64(p.MonthsLeft)-1)/int64(p.MonthsLeft)) / 100
+	}
+
+	monthsElapsed := max(now.Sub(g.CreatedAt).Hours()/24/daysPerMonth, 1)
+	p.AverageMonthly = math.Round(float64(saved)/monthsElapsed) / 100
+	if remaining > 0 && p.AverageMonthly > 0 {
+		months := float64(remaining) / 100 / p.AverageMonthly
+		p.ProjectedDate = now.Add(time.Duration(months * daysPerMonth * 24 * float64(time.Hour))).UTC()
+	}
+
+	total := g.TargetDate.Sub(g.CreatedAt)
+	elapsed := now.Sub(g.CreatedAt)
+	switch {
+	case remaining == 0:
+		p.Status = GOAL_REACHED
+	case daysLeft <= 0:
+		p.Status = GOAL_OVERDUE
+	case total <= 0 || float64(saved) >= float64(target)*min(float64(elapsed)/float64(total), 1):
+		p.Status = GOAL_ON_TRACK
+	default:
+		p.Status = GOAL_BEHIND
+	}
+	return p
+}
+
+func validateSavingsGoalRequest(req SavingsGoalRequest, createdAt time.Time) error {
+	name := strings.TrimSpace(req.Name)
+	if name == "" {
+		return appErrors.ErrorResponse{
+			Code:    appErrors.ErrInvalidInput,
+			Message: "Goal name cannot be empty!",
+		}
+	}
+	if len(name) > MAX_GOAL_NAME_LENGTH {
+		return appErrors.ErrorResponse{
+			Code:    appErrors.ErrInvalidInput,
+			Message: fmt.Sprintf("Goal name so long, maximum allowed length is %d", MAX_GOAL_NAME_LENGTH),
+		}
+	}
+	if req.TargetAmount <= 0 || IsFloatZero(req.TargetAmount) {
+		return appErrors.ErrorResponse{
+			Code:    appErrors.ErrInvalidInput,
+			Message: "Goal target amount must be greater than zero",
+		}
+	}
+	if req.TargetAmount > MAX_TRANSACTION_AMOUNT_LIMIT {
+		return appErrors.ErrorResponse{
+			Code:    appErrors.ErrInvalidInput,
+			Message: fmt.Sprintf("Maximum allowed target amount is %d", MAX_TRANSACTION_AMOUNT_LIMIT),
+		}
+	}
+	if req.TargetDate.IsZero() {
+		return appErrors.ErrorResponse{
+			Code:    appErrors.ErrInvalidInput,
+			Message: "Goal target date is required",
+		}
+	}
+	if !req.TargetDate.After(createdAt) {
+		return appErrors.ErrorResponse{
+			Code:    appErrors.ErrInvalidInput,
+			Message: "Goal target date must be after the goal was created",
+		}
+	}
+	if len(req.Note) > MAX_TRANSACTION_NOTE_LENGTH {
+		return appErrors.ErrorResponse{
+			Code:    appErrors.ErrInvalidInput,
+			Message: fmt.Sprintf("Note so long, maximum allowed note length is %d", MAX_TRANSACTION_NOTE_LENGTH),
+		}
+	}
+	return nil
+}
+
+func (bt *BudgetTracker) SaveSavingsGoal(ctx context.Context, userId string, req SavingsGoalRequest) (SavingsGoalProgress, error) {
+	traceID := contextutil.TraceIDFromContext(ctx)
+
+	now := time.Now().UTC()
+	if err := validateSavingsGoalRequest(req, now); err != nil {
+		return SavingsGoalProgress{}, err
+	}
+	currency, err := bt.linkedCurrency(ctx, userId, req.WalletId, req.Currency, "goal")
+	if err != nil {
+		return SavingsGoalProgress{}, err
+	}
+
+	existing, err := bt.storage.GetSavingsGoals(ctx, userId)
+	if err != nil {
+		logging.Logger.Errorf("[TraceID=%s] | storage.GetSavingsGoals() failed in Service.SaveSavingsGoal()", traceID)
+		return SavingsGoalProgress{}, err
+	}
+	if len(existing) >= MAX_GOALS_PER_USER {
+		return SavingsGoalProgress{}, appErrors.ErrorResponse{
+			Code:    appErrors.ErrInvalidInput,
+			Message: fmt.Sprintf("Maximum %d goals are allowed", MAX_GOALS_PER_USER),
+		}
+	}
+
+	g := SavingsGoal{
+		ID:           uuid.New().String(),
+		Name:         strings.TrimSpace(req.Name),
+		TargetAmount: req.TargetAmount,
+		Currency:     currency,
+		TargetDate:   req.TargetDate.UTC(),
+		WalletId:     req.WalletId,
+		Note:         req.Note,
+		CreatedAt:    now,
+		UpdatedAt:    now,
+		CreatedBy:    userId,
+	}
+	if err := bt.storage.SaveSavingsGoal(ctx, g); err != nil {
+		logging.Logger.Errorf("[TraceID=%s] | storage.SaveSavingsGoal() failed in Service.SaveSavingsGoal()", traceID)
+		return SavingsGoalProgress{}, err
+	}
+	return bt.withWalletBalance(ctx, userId, goalProgress(g, now))
+}
+
+func (bt *BudgetTracker) GetSavingsGoals(ctx context.Context, userId string) ([]SavingsGoalProgress, error) {
+	goals, err := bt.storage.GetSavingsGoals(ctx, userId)
+	if err != nil {
+		return nil, err
+	}
+	wallets, err := bt.storage.GetWallets(ctx, userId)
+	if err != nil {
+		return nil, err
+	}
+	balances := make(map[string]float64, len(wallets))
+	for _, w := range wallets {
+		balances[w.ID] = w.Balance
+	}
+
+	now := time.Now().UTC()
+	progress := make([]SavingsGoalProgress, 0, len(goals))
+	for _, g := range goals {
+		p := goalProgress(g, now)
+		if g.WalletId != "" {
+			p.WalletBalance = balances[g.WalletId]
+			p.WalletBalanceShort = toCents(p.WalletBalance) < toCents(g.Saved)
+		}
+		progress = append(progress, p)
+	}
+	return progress, nil
+}
+
+func (bt *BudgetTracker) GetSavingsGoal(ctx context.Context, userId string, id string) (SavingsGoalProgress, error) {
+	g, err := bt.storage.GetSavingsGoalById(ctx, userId, id)
+	if err != nil {
+		return SavingsGoalProgress{}, err
+	}
+	return bt.withWalletBalance(ctx, userId, goalProgress(g, time.Now().UTC()))
+}
+
+// UpdateSavingsGoal changes a goal, its contributions stay. The currency is
+// fixed once the goal exists; a new wallet must be in the same currency.
+func (bt *BudgetTracker) UpdateSavingsGoal(ctx context.Context, userId string, id string, req SavingsGoalRequest) (SavingsGoalProgress, error) {
+	g, err := bt.storage.GetSavingsGoalById(ctx, userId, id)
+	if err != nil {
+		return SavingsGoalProgress{}, err
+	}
+	if err := validateSavingsGoalRequest(req, g.CreatedAt); err != nil {
+		return SavingsGoalProgress{}, err
+	}
+	if req.Currency == "" {
+		req.Currency = g.Currency
+	}
+	currency, err := bt.linkedCurrency(ctx, userId, req.WalletId, req.Currency, "goal")
+	if err != nil {
+		return SavingsGoalProgress{}, err
+	}
+	if currency != g.Currency {
+		return SavingsGoalProgress{}, appErrors.ErrorResponse{
+			Code:    appErrors.ErrInvalidInput,
+			Message: "The currency of a goal cannot be changed",
+		}
+	}
+
+	g.Name = strings.TrimSpace(req.Name)
+	g.TargetAmount = req.TargetAmount
+	g.TargetDate = req.TargetDate.UTC()
+	g.WalletId = req.WalletId
+	g.Note = req.Note
+	g.UpdatedAt = time.Now().UTC()
+	if err := bt.storage.UpdateSavingsGoal(ctx, g); err != nil {
+		return SavingsGoalProgress{}, err
+	}
+	return bt.withWalletBalance(ctx, userId, goalProgress(g, g.UpdatedAt))
+}
+
+// DeleteSavingsGoal deletes a goal with its contributions. The money itself
+// stays where it is, contributions only earmark it.
+func (bt *BudgetTracker) DeleteSavingsGoal(ctx context.Context, userId string, id string) error {
+	return bt.storage.DeleteSavingsGoal(ctx, userId, id)
+}
+
+// AddSavingsContribution records money put into or taken out of a goal. A
+// withdrawal cannot take out more than is saved.
+func (bt *BudgetTracker) AddSavingsContribution(ctx context.Context, userId string, goalId string, req SavingsContributionRequest) (SavingsGoalProgress, error) {
+	traceID := contextutil.TraceIDFromContext(ctx)
+
+	if req.Type == "" {
+		req.Type = CONTRIBUTION_DEPOSIT
+	}
+	if req.Type != CONTRIBUTION_DEPOSIT && req.Type != CONTRIBUTION_WITHDRAWAL {
+		return SavingsGoalProgress{}, appErrors.ErrorResponse{
+			Code:    appErrors.ErrInvalidInput,
+			Message: fmt.Sprintf("Contribution type must be %s or %s", CONTRIBUTION_DEPOSIT, CONTRIBUTION_WITHDRAWAL),
+		}
+	}
+	if req.Amount <= 0 || IsFloatZero(req.Amount) {
+		return SavingsGoalProgress{}, appErrors.ErrorResponse{
+			Code:    appErrors.ErrInvalidInput,
+			Message: "Contribution amount must be greater than zero",
+		}
+	}
+	if req.Amount > MAX_TRANSACTION_AMOUNT_LIMIT {
+		return SavingsGoalProgress{}, appErrors.ErrorResponse{
+			Code:    appErrors.ErrInvalidInput,
+			Message: fmt.Sprintf("Maximum allowed amount per contribution is %d", MAX_TRANSACTION_AMOUNT_LIMIT),
+		}
+	}
+	if len(req.Note) > MAX_TRANSACTION_NOTE_LENGTH {
+		return SavingsGoalProgress{}, appErrors.ErrorResponse{
+			Code:    appErrors.ErrInvalidInput,
+			Message: fmt.Sprintf("Note so long, maximum allowed note length is %d", MAX_TRANSACTION_NOTE_LENGTH),
+		}
+	}
+	if req.OccurredAt.After(time.Now().Add(MAX_OCCURRED_AT_AHEAD)) {
+		return SavingsGoalProgress{}, appErrors.ErrorResponse{
+			Code:    appErrors.ErrInvalidInput,
+			Message: "Contribution date cannot be in the future",
+		}
+	}
+
+	g, err := bt.storage.GetSavingsGoalById(ctx, userId, goalId)
+	if err != nil {
+		return SavingsGoalProgress{}, err
+	}
+
+	amount := req.Amount
+	if req.Type == CONTRIBUTION_WITHDRAWAL {
+		if toCents(amount) > toCents(g.Saved) {
+			return SavingsGoalProgress{}, appErrors.ErrorResponse{
+				Code:    appErrors.ErrInvalidInput,
+				Message: fmt.Sprintf("Cannot withdraw %.2f, only %.2f is saved", amount, g.Saved),
+			}
+		}
+		amount = -amount
+	}
+
+	now := time.Now().UTC()
+	occurredAt := now
+	if !req.OccurredAt.IsZero() {
+		occurredAt = req.OccurredAt.UTC()
+	}
+	c := SavingsContribution{
+		ID:         uuid.New().String(),
+		GoalId:     g.ID,
+		Amount:     amount,
+		OccurredAt: occurredAt,
+		Note:       req.Note,
+		CreatedAt:  now,
+		CreatedBy:  userId,
+	}
+	if err := bt.storage.SaveSavingsContribution(ctx, c); err != nil {
+		logging.Logger.Errorf("[TraceID=%s] | storage.SaveSavingsContribution() failed in Service.AddSavingsContribution()", traceID)
+		return SavingsGoalProgress{}, err
+	}
+
+	g.Saved = float64(toCents(g.Saved)+toCents(amount)) / 100
+	return bt.withWalletBalance(ctx, userId, goalProgress(g, now))
+}
+
+// GetSavingsGoalHistory lists the contributions and withdrawals of a goal
+// oldest first, each with the saved amount after it.
+func (bt *BudgetTracker) GetSavingsGoalHistory(ctx context.Context, userId string, goalId string) (SavingsGoalProgress, []SavingsHistoryEntry, error) {
+	traceID := contextutil.TraceIDFromContext(ctx)
+
+	progress, err := bt.GetSavingsGoal(ctx, userId, goalId)
+	if err != nil {
+		return SavingsGoalProgress{}, nil, err
+	}
+	contributions, err := bt.storage.GetSavingsContributions(ctx, userId, goalId)
+	if err != nil {
+		logging.Logger.Errorf("[TraceID=%s] | storage.GetSavingsContributions() failed in Service.GetSavingsGoalHistory()", traceID)
+		return SavingsGoalProgress{}, nil, err
+	}
+
+	entries := make([]SavingsHistoryEntry, 0, len(contributions))
+	var saved int64
+	for _, c := range contributions {
+		saved += toCents(c.Amount)
+		entries = append(entries, SavingsHistoryEntry{Contribution: c, Saved: float64(saved) / 100})
+	}
+	return progress, entries, nil
+}
+
+func (bt *BudgetTracker) withWalletBalance(ctx context.Context, userId string, p SavingsGoalProgress) (SavingsGoalProgress, error) {
+	if p.Goal.WalletId == "" {
+		return p, nil
+	}
+	w, err := bt.storage.GetWalletById(ctx, userId, p.Goal.WalletId)
+	if err != nil {
+		return SavingsGoalProgress{}, err
+	}
+	p.WalletBalance = w.Balance
+	p.WalletBalanceShort = toCents(w.Balance) < toCents(p.Goal.Saved)
+	return p, nil
+}
@@ -4,9 +4,12 @@ import (
 	"context"
 	"crypto/rand"
 	"encoding/hex"
+	"errors"
 	"fmt"
 	"io"
+	"os"
 	"regexp"
+	"slices"
 	"strconv"
 	"strings"
 	"time"
@@ -27,6 +30,10 @@ const (
 	MAX_CATEGORY_AMOUNT_LIMIT            = 999999999999999999.99
 	MAX_CATEGORY_NAME_LENGTH             = 255
 	MAX_TARGET_AMOUNT_LIMIT              = 999999999999999999
+	EMAIL_VERIFICATION_TTL               = 24 * time.Hour
+	PASSWORD_RESET_TTL                   = time.Hour
+	MAX_OCCURRED_AT_AHEAD                = 24 * time.Hour // the client may be a day ahead of UTC
+	DEFAULT_DELETION_GRACE_DAYS          = 30
 	Epsilon                              = 1e-9 // For IsFloatZero() func.
 )
 
@@ -35,17 +42,25 @@ func IsFloatZero(f float64) bool {
 }
 
 type BudgetTracker struct {
-	storage     Storage
-	StorageType string
+	storage        Storage
+	mailer         Mailer
+	StorageType    string
+	categoryModels *categoryModels
 }
 
-func NewBudgetTracker(s Storage) BudgetTracker {
+func NewBudgetTracker(s Storage, m Mailer) BudgetTracker {
 	return BudgetTracker{
-		storage:     s,
-		StorageType: s.GetStorageType(),
+		storage:        s,
+		mailer:         m,
+		StorageType:    s.GetStorageType(),
+		categoryModels: newCategoryModels(),
 	}
 }
 
+type Mailer interface {
+	SendMail(ctx context.Context, to string, subject string, body string) error
+}
+
 type Storage interface {
 	SaveUser(ctx context.Context, newUser auth.User) error
 	SaveSession(ctx context.Context, session auth.Session) error
@@ -66,13 +81,113 @@ type Storage interface {
 	IsUserExists(ctx context.Context, username string) (bool, error)
 	IsEmailConfirmed(ctx context.Context, emailAddress string) (bool, error)
 	UpdateExpenseCategory(ctx context.Context, userId string, fields UpdateExpenseCategoryRequest) (*ExpenseCategoryResponse, error)
-	DeleteExpenseCategory(ctx context.Context, userId string, categoryId string) error
-	DeleteIncomeCategory(ctx context.Context, userId string, categoryId string) error
+	// DeleteExpenseCategory deletes a category with its transactions, or moves
+	// them to the category moveTo first when it is not empty. It returns how
+	// many transactions were deleted or moved.
+	DeleteExpenseCategory(ctx context.Context, userId string, categoryId string, moveTo string) (int, error)
+	DeleteIncomeCategory(ctx context.Context, userId string, categoryId string, moveTo string) (int, error)
 	UpdateIncomeCategory(ctx context.Context, userId string, fields UpdateIncomeCategoryRequest) (*IncomeCategoryResponse, error)
+	// GetCategoryParents maps the ID of every category of the type to its
+	// parent ID, empty for top level categories.
+	GetCategoryParents(ctx context.Context, userId string, categoryType string) (map[string]string, error)
+	SetCategoryParent(ctx context.Context, userId string, categoryType string, id string, parentId string) error
+	// SetCategoryArchived archives a category, a zero archivedAt unarchives it.
+	SetCategoryArchived(ctx context.Context, userId string, categoryType string, id string, archivedAt time.Time) error
+	// MergeCategories applies a merge checked by the service in one SQL
+	// transaction and keeps it as an audit entry. It returns how many
+	// transactions were moved to the target.
+	MergeCategories(ctx context.Context, merge CategoryMerge) (int, error)
+	GetCategoryMerges(ctx context.Context, userId string, categoryType string) ([]CategoryMerge, error)
+	SaveSavingsGoal(ctx context.Context, g SavingsGoal) error
+	GetSavingsGoals(ctx context.Context, userId string) ([]SavingsGoal, error)
+	GetSavingsGoalById(ctx context.Context, userId string, id string) (SavingsGoal, error)
+	UpdateSavingsGoal(ctx context.Context, g SavingsGoal) error
+	DeleteSavingsGoal(ctx context.Context, userId string, id string) error
+	SaveSavingsContribution(ctx context.Context, c SavingsContribution) error
+	// GetSavingsContributions returns the contributions of a goal, oldest first.
+	GetSavingsContributions(ctx context.Context, userId string, goalId string) ([]SavingsContribution, error)
+	SaveDebt(ctx context.Context, d Debt) error
+	GetDebts(ctx context.Context, userId string) ([]Debt, error)
+	GetDebtById(ctx context.Context, userId string, id string) (Debt, error)
+	UpdateDebt(ctx context.Context, d Debt) error
+	DeleteDebt(ctx context.Context, userId string, id string) error
+	// SaveDebtPayment saves the payment with its expense transaction in one
+	// SQL transaction.
+	SaveDebtPayment(ctx context.Context, p DebtPayment, t Transaction) error
+	// GetDebtPayments returns the payments of a debt, oldest first.
+	GetDebtPayments(ctx context.Context, userId string, debtId string) ([]DebtPayment, error)
 	LogoutUser(ctx context.Context, userId string, token string) error
-	DeleteUser(ctx context.Context, userId string, deleteReq auth.DeleteUser) error
+	ScheduleUserDeletion(ctx context.Context, userId string, deleteReq auth.DeleteUser, purgeAt time.Time) error
+	RestoreUser(ctx context.Context, userId string, now time.Time) error
+	GetUsersDueForPurge(ctx context.Context, now time.Time) ([]auth.PendingDeletion, error)
+	PurgeUser(ctx context.Context, userId string, anonymizedReason string, now time.Time) error
 	GetUserData(ctx context.Context, userId string) (UserDataResponse, error)
+	// EachTransaction calls fn for every transaction of the user, oldest first,
+	// without loading them all. CategoryName is not filled in. It stops at the
+	// first error fn returns.
+	EachTransaction(ctx context.Context, userId string, fn func(Transaction) error) error
+	// EachFilteredTransaction calls fn for every transaction matching filters,
+	// newest first, with CategoryName filled in, without loading them all.
+	EachFilteredTransaction(ctx context.Context, userId string, filters *TransactionList, fn func(Transaction) error) error
+	SaveImportBatch(ctx context.Context, userId string, batch ImportBatch) error
+	GetExistingExternalIds(ctx context.Context, userId string, externalIds []string) (map[string]bool, error)
+	SaveRecurringTransaction(ctx context.Context, r RecurringTransaction) error
+	GetRecurringTransactions(ctx context.Context, userId string) ([]RecurringTransaction, error)
+	GetRecurringTransactionById(ctx context.Context, userId string, id string) (RecurringTransaction, error)
+	UpdateRecurringSchedule(ctx context.Context, r RecurringTransaction) error
+	// SaveRecurringInstance saves t, the instance due at t.OccurredAt, and
+	// moves the schedule on to r.NextRunAt in one SQL transaction. The
+	// schedule is locked first, ErrConflict means another run already
+	// created the instance or the schedule was paused meanwhile.
+	SaveRecurringInstance(ctx context.Context, r RecurringTransaction, t Transaction) error
+	DeleteRecurringTransaction(ctx context.Context, userId string, id string) error
+	GetDueRecurringTransactions(ctx context.Context, now time.Time) ([]RecurringTransaction, error)
+	SaveWallet(ctx context.Context, w Wallet) error
+	GetWallets(ctx context.Context, userId string) ([]Wallet, error)
+	GetWalletById(ctx context.Context, userId string, id string) (Wallet, error)
+	UpdateWallet(ctx context.Context, w Wallet) error
+	DeleteWallet(ctx context.Context, userId string, id string) error
+	GetWalletTransactions(ctx context.Context, userId string, walletId string) ([]Transaction, error)
+	SaveReconciliation(ctx context.Context, r Reconciliation) error
+	GetOpenReconciliation(ctx context.Context, userId string, walletId string) (Reconciliation, error)
+	SetTransactionsCleared(ctx context.Context, userId string, transactionIds []string, cleared bool) error
+	FinishReconciliation(ctx context.Context, r Reconciliation, transactionIds []string) error
+	SaveTag(ctx context.Context, tag Tag) error
+	GetTags(ctx context.Context, userId string) ([]Tag, error)
+	GetTagById(ctx context.Context, userId string, id string) (Tag, error)
+	UpdateTag(ctx context.Context, tag Tag) error
+	DeleteTag(ctx context.Context, userId string, id string) error
+	// SetTransactionTags replaces the tags of a transaction, the tags must exist.
+	SetTransactionTags(ctx context.Context, userId string, transactionId string, tags []string) error
+	SavePayee(ctx context.Context, p Payee) error
+	GetPayees(ctx context.Context, userId string) ([]Payee, error)
+	GetPayeeById(ctx context.Context, userId string, id string) (Payee, error)
+	// UpdatePayee also replaces the aliases of the payee.
+	UpdatePayee(ctx context.Context, p Payee) error
+	DeletePayee(ctx context.Context, userId string, id string) error
+	// SetTransactionPayee links the transactions to a payee, an empty
+	// payeeId unlinks them.
+	SetTransactionPayee(ctx context.Context, userId string, transactionIds []string, payeeId string) error
+	SaveRule(ctx context.Context, r Rule) error
+	GetRules(ctx context.Context, userId string) ([]Rule, error)
+	GetRuleById(ctx context.Context, userId string, id string) (Rule, error)
+	UpdateRule(ctx context.Context, r Rule) error
+	DeleteRule(ctx context.Context, userId string, id string) error
+	// SaveRuleChanges creates the new tags and sets the category, note and
+	// tags of the changed transactions in one SQL transaction.
+	SaveRuleChanges(ctx context.Context, userId string, tags []Tag, changes []RuleChange) error
 	GetAccountInfo(ctx context.Context, userId string) (AccountInfo, error)
+	UpdatePassword(ctx context.Context, userId string, currentPassword string, newHashedPassword string) error
+	UpdateAccount(ctx context.Context, userId string, userName string, fullName string) error
+	SaveEmailVerification(ctx context.Context, verification auth.EmailVerification) error
+	ConfirmEmailChange(ctx context.Context, userId string, token string) error
+	// SavePasswordReset stores the reset for the account with the given email
+	// and returns ErrNotFound when there is none.
+	SavePasswordReset(ctx context.Context, email string, reset auth.PasswordReset) error
+	GetPasswordResetUser(ctx context.Context, token string, now time.Time) (string, error)
+	// ResetPassword sets the new password, uses up the token and signs the
+	// account out everywhere.
+	ResetPassword(ctx context.Context, token string, newHashedPassword string, now time.Time) error
 	GetStorageType() string
 }
 
@@ -103,12 +218,21 @@ func (bt *BudgetTracker) GenerateSession(ctx context.Context, credentialsPure au
 		return "", err
 	}
 
-	tokenByte := make([]byte, 16)
-	if _, err := io.ReadFull(rand.Reader, tokenByte); err != nil {
-		return "", err
+	if !user.DeletionScheduledAt.IsZero() {
+		return "", appErrors.ErrorResponse{
+			Code:    appErrors.ErrPendingDeletion,
+			Message: fmt.Sprintf("This account will be deleted on %s, restore it to continue.", user.DeletionScheduledAt.Format("2006-01-02")),
+		}
 	}
 
-	token := hex.EncodeToString(tokenByte)
+	return bt.createSession(ctx, user.ID)
+}
+
+func (bt *BudgetTracker) createSession(ctx context.Context, userId string) (string, error) {
+	token, err := generateToken()
+	if err != nil {
+		return "", err
+	}
 
 	now := time.Now().UTC()
 
@@ -117,7 +241,7 @@ func (bt *BudgetTracker) GenerateSession(ctx context.Context, credentialsPure au
 		Token:     token,
 		CreatedAt: now,
 		ExpireAt:  now.AddDate(0, 1, 0),
-		UserID:    user.ID,
+		UserID:    userId,
 	}
 
 	err = bt.storage.SaveSession(ctx, session)
@@ -127,6 +251,14 @@ func (bt *BudgetTracker) GenerateSession(ctx context.Context, credentialsPure au
 	return token, nil
 }
 
+func generateToken() (string, error) {
+	tokenByte := make([]byte, 16)
+	if _, err := io.ReadFull(rand.Reader, tokenByte); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(tokenByte), nil
+}
+
 func (bt *BudgetTracker) CheckSession(ctx context.Context, token string) (string, error) {
 	session, err := bt.storage.GetSessionByToken(ctx, token)
 	if err != nil {
@@ -255,7 +387,86 @@ func CapitalizeFullName(name string) string {
 }
 
 func (bt *BudgetTracker) SaveTransaction(ctx context.Context, userId string, transaction TransactionRequest) error {
-	if transaction.CategoryId == "" {
+	txn, err := bt.newTransaction(ctx, userId, transaction)
+	if err != nil {
+		return err
+	}
+	if err := bt.storage.SaveTransaction(ctx, txn); err != nil {
+		return err
+	}
+	bt.learnCategories(userId, txn)
+	return nil
+}
+
+// newTransaction fills in and validates a transaction request, creating its
+// missing tags, and returns the transaction ready to be saved.
+func (bt *BudgetTracker) newTransaction(ctx context.Context, userId string, transaction TransactionRequest) (Transaction, error) {
+	// an explicit category wins over the rules, the rules over the default
+	// category of the payee and that over a confident suggestion
+	payee, err := bt.resolveTransactionPayee(ctx, userId, &transaction)
+	if err != nil {
+		return Transaction{}, err
+	}
+	if err := bt.applyTransactionRules(ctx, userId, &transaction, payee); err != nil {
+		return Transaction{}, err
+	}
+	applyPayeeCategory(payee, &transaction)
+	if err := bt.applySuggestedCategory(ctx, userId, &transaction); err != nil {
+		return Transaction{}, err
+	}
+	if err := validateTransactionRequest(transaction); err != nil {
+		return Transaction{}, err
+	}
+	if err := bt.resolveTransactionWallets(ctx, userId, &transaction); err != nil {
+		return Transaction{}, err
+	}
+	tags, err := normalizeTags(transaction.Tags)
+	if err != nil {
+		return Transaction{}, err
+	}
+	if err := bt.ensureTags(ctx, userId, tags); err != nil {
+		return Transaction{}, err
+	}
+
+	now := time.Now().UTC()
+	occurredAt := now
+	if !transaction.OccurredAt.IsZero() {
+		occurredAt = transaction.OccurredAt.UTC()
+	}
+	categoryId := transaction.CategoryId
+	splits := newTransactionSplits(transaction.Splits)
+	if len(splits) > 0 {
+		categoryId = splits[0].CategoryId
+	}
+	txn := Transaction{
+		ID:           uuid.New().String(),
+		CategoryId:   categoryId,
+		CategoryType: transaction.CategoryType,
+		Amount:       transaction.Amount,
+		Currency:     transaction.Currency,
+		OccurredAt:   occurredAt,
+		CreatedAt:    now,
+		Note:         transaction.Note,
+		CreatedBy:    userId,
+		Splits:       splits,
+		WalletId:     transaction.WalletId,
+		ToWalletId:   transaction.ToWalletId,
+		Tags:         tags,
+		PayeeId:      transaction.PayeeId,
+	}
+	return txn, nil
+}
+
+func validateTransactionRequest(transaction TransactionRequest) error {
+	if transaction.CategoryType == TRANSFER_TYPE {
+		if err := validateTransfer(transaction); err != nil {
+			return err
+		}
+	} else if len(transaction.Splits) > 0 {
+		if err := validateTransactionSplits(transaction); err != nil {
+			return err
+		}
+	} else if transaction.CategoryId == "" {
 		return appErrors.ErrorResponse{
 			Code:    appErrors.ErrInvalidInput,
 			Message: "Category ID cannot be empty!",
@@ -285,26 +496,16 @@ func (bt *BudgetTracker) SaveTransaction(ctx context.Context, userId string, tra
 			Message: fmt.Sprintf("Note so long, maximum allowed note length is %d", MAX_TRANSACTION_NOTE_LENGTH),
 		}
 	}
-
-	now := time.Now().UTC()
-	txn := Transaction{
-		ID:           uuid.New().String(),
-		CategoryId:   transaction.CategoryId,
-		CategoryType: transaction.CategoryType,
-		Amount:       transaction.Amount,
-		Currency:     transaction.Currency,
-		CreatedAt:    now,
-		Note:         transaction.Note,
-		CreatedBy:    userId,
-	}
-
-	if err := bt.storage.SaveTransaction(ctx, txn); err != nil {
-		return err
+	if transaction.OccurredAt.After(time.Now().Add(MAX_OCCURRED_AT_AHEAD)) {
+		return appErrors.ErrorResponse{
+			Code:    appErrors.ErrInvalidInput,
+			Message: "Transaction date cannot be in the future",
+		}
 	}
 	return nil
 }
 
-func (bt *BudgetTracker) ProcessImage(ctx context.Context, imageRawText string) (ProcessedImageResponse, error) {
+func (bt *BudgetTracker) ProcessImage(ctx context.Context, userId string, imageRawText string) (ProcessedImageResponse, error) {
 	traceID := contextutil.TraceIDFromContext(ctx)
 
 	if imageRawText == "" {
@@ -340,6 +541,53 @@ func (bt *BudgetTracker) ProcessImage(ctx context.Context, imageRawText string)
 	for _, symbol := range symbolMatches {
 		result.CurrenciesSymbol = append(result.CurrenciesSymbol, symbol)
 	}
+	result.Lines, result.Total = parseReceiptLines(imageRawText)
+
+	payees, err := bt.storage.GetPayees(ctx, userId)
+	if err != nil {
+		logging.Logger.Errorf("[TraceID=%s] | storage.GetPayees() failed in Service.ProcessImage()", traceID)
+		return ProcessedImageResponse{}, err
+	}
+	result.Payee, result.PayeeId = receiptPayee(payees, imageRawText)
+
+	rules, err := bt.userRules(ctx, userId)
+	if err != nil {
+		logging.Logger.Errorf("[TraceID=%s] | storage.GetRules() failed in Service.ProcessImage()", traceID)
+		return ProcessedImageResponse{}, err
+	}
+	currency := ""
+	if len(result.CurrenciesISO) > 0 {
+		currency = result.CurrenciesISO[0]
+	}
+	// a receipt is an expense paid today, as far as the rules can tell
+	out := runRules(rules, ruleSubject{
+		CategoryType: "-",
+		Note:         result.Payee,
+		Payee:        result.Payee,
+		Amount:       result.Total,
+		Currency:     currency,
+		OccurredAt:   time.Now().UTC(),
+	})
+	result.CategoryId = out.CategoryId
+	if len(out.Tags) > 0 {
+		result.Tags = out.Tags
+	}
+	if result.CategoryId == "" && result.PayeeId != "" {
+		for _, p := range payees {
+			if p.ID == result.PayeeId && p.DefaultCategoryType == "-" {
+				result.CategoryId = p.DefaultCategoryId
+			}
+		}
+	}
+	result.Suggestions, err = bt.SuggestCategories(ctx, userId, TransactionRequest{
+		CategoryType: "-",
+		Note:         result.Payee,
+		Amount:       result.Total,
+		Currency:     currency,
+	}, DEFAULT_CATEGORY_SUGGESTIONS)
+	if err != nil {
+		return ProcessedImageResponse{}, err
+	}
 
 	return result, nil
 }
@@ -366,9 +614,14 @@ func (bt *BudgetTracker) SaveExpenseCategory(ctx context.Context, userId string,
 		}
 	}
 
+	id := uuid.New().String()
+	if err := bt.checkNewCategoryParent(ctx, userId, "-", id, category.ParentId); err != nil {
+		return err
+	}
+
 	now := time.Now().UTC()
 	categoryItem := ExpenseCategory{
-		ID:        uuid.New().String(),
+		ID:        id,
 		Name:      strings.ToLower(category.Name),
 		MaxAmount: category.MaxAmount,
 		PeriodDay: category.PeriodDay,
@@ -377,6 +630,7 @@ func (bt *BudgetTracker) SaveExpenseCategory(ctx context.Context, userId string,
 		Note:      category.Note,
 		CreatedBy: userId,
 		Type:      category.Type,
+		ParentId:  category.ParentId,
 	}
 
 	if err := bt.storage.SaveExpenseCategory(ctx, categoryItem); err != nil {
@@ -409,9 +663,14 @@ func (bt *BudgetTracker) SaveIncomeCategory(ctx context.Context, userId string,
 		}
 	}
 
+	id := uuid.New().String()
+	if err := bt.checkNewCategoryParent(ctx, userId, "+", id, category.ParentId); err != nil {
+		return err
+	}
+
 	now := time.Now().UTC()
 	categoryItem := IncomeCategory{
-		ID:           uuid.New().String(),
+		ID:           id,
 		Name:         strings.ToLower(category.Name),
 		TargetAmount: category.TargetAmount,
 		CreatedAt:    now,
@@ -419,6 +678,7 @@ func (bt *BudgetTracker) SaveIncomeCategory(ctx context.Context, userId string,
 		Note:         category.Note,
 		CreatedBy:    userId,
 		Type:         category.Type,
+		ParentId:     category.ParentId,
 	}
 
 	if err := bt.storage.SaveIncomeCategory(ctx, categoryItem); err != nil {
@@ -460,28 +720,37 @@ func (bt *BudgetTracker) GetFilteredIncomeCategories(ctx context.Context, userID
 		return nil, err
 	}
 
+	// subcategories that did not match the filters still roll up
+	all := categoriesRaw
+	if !filters.IsAllNil {
+		all, err = bt.storage.GetFilteredIncomeCategories(ctx, userID, &IncomeCategoryList{IsAllNil: true})
+		if err != nil {
+			return nil, err
+		}
+	}
+
 	var categories []IncomeCategoryResponse
 
 	for _, category := range categoriesRaw {
-		var usagePercent int
-		if category.TargetAmount > 0 {
-			usagePercent = int((category.Amount / category.TargetAmount) * 100)
-		}
-
 		category := IncomeCategoryResponse{
 			ID:           category.ID,
 			Name:         category.Name,
 			Amount:       category.Amount,
 			TargetAmount: category.TargetAmount,
-			UsagePercent: usagePercent,
 			CreatedAt:    category.CreatedAt,
 			UpdatedAt:    category.UpdatedAt,
 			Note:         category.Note,
 			CreatedBy:    category.CreatedBy,
+			ParentId:     category.ParentId,
+			ArchivedAt:   category.ArchivedAt,
 		}
 		categories = append(categories, category)
 	}
+	rollUpIncomeCategories(all, categories)
 
+	if !filters.IncludeArchived {
+		categories = slices.DeleteFunc(categories, func(c IncomeCategoryResponse) bool { return !c.ArchivedAt.IsZero() })
+	}
 	return categories, nil
 }
 
@@ -491,33 +760,42 @@ func (bt *BudgetTracker) GetFilteredExpenseCategories(ctx context.Context, userI
 		return nil, err
 	}
 
+	// subcategories that did not match the filters still roll up
+	all := categoriesRaw
+	if !filters.IsAllNil {
+		all, err = bt.storage.GetFilteredExpenseCategories(ctx, userID, &ExpenseCategoryList{IsAllNil: true})
+		if err != nil {
+			return nil, err
+		}
+	}
+
 	var categories []ExpenseCategoryResponse
 
 	for _, category := range categoriesRaw {
-		var usagePercent int
-		if category.MaxAmount > 0 {
-			usagePercent = int((category.Amount / category.MaxAmount) * 100)
-		}
-
 		isExpired := time.Now().UTC().After(category.CreatedAt.AddDate(0, 0, category.PeriodDay))
 
 		category := ExpenseCategoryResponse{
-			ID:           category.ID,
-			Name:         category.Name,
-			Amount:       category.Amount,
-			MaxAmount:    category.MaxAmount,
-			PeriodDay:    category.PeriodDay,
-			UsagePercent: usagePercent,
-			CreatedAt:    category.CreatedAt,
-			UpdatedAt:    category.UpdatedAt,
-			Note:         category.Note,
-			CreatedBy:    category.CreatedBy,
-			IsExpired:    isExpired,
+			ID:         category.ID,
+			Name:       category.Name,
+			Amount:     category.Amount,
+			MaxAmount:  category.MaxAmount,
+			PeriodDay:  category.PeriodDay,
+			CreatedAt:  category.CreatedAt,
+			UpdatedAt:  category.UpdatedAt,
+			Note:       category.Note,
+			CreatedBy:  category.CreatedBy,
+			IsExpired:  isExpired,
+			ParentId:   category.ParentId,
+			ArchivedAt: category.ArchivedAt,
 		}
 
 		categories = append(categories, category)
 	}
+	rollUpExpenseCategories(all, categories)
 
+	if !filters.IncludeArchived {
+		categories = slices.DeleteFunc(categories, func(c ExpenseCategoryResponse) bool { return !c.ArchivedAt.IsZero() })
+	}
 	return categories, nil
 }
 
@@ -546,28 +824,30 @@ func (bt *BudgetTracker) UpdateExpenseCategory(ctx context.Context, userId strin
 	if err != nil {
 		return nil, err
 	}
-	var usagePercent int
-	if categoryRaw.MaxAmount > 0 {
-		usagePercent = int((categoryRaw.Amount / categoryRaw.MaxAmount) * 100)
+	all, err := bt.storage.GetFilteredExpenseCategories(ctx, userId, &ExpenseCategoryList{IsAllNil: true})
+	if err != nil {
+		return nil, err
 	}
 
 	isExpired := time.Now().UTC().After(categoryRaw.CreatedAt.AddDate(0, 0, categoryRaw.PeriodDay))
 
 	category := ExpenseCategoryResponse{
-		ID:           categoryRaw.ID,
-		Name:         categoryRaw.Name,
-		Amount:       categoryRaw.Amount,
-		MaxAmount:    categoryRaw.MaxAmount,
-		PeriodDay:    categoryRaw.PeriodDay,
-		UsagePercent: usagePercent,
-		CreatedAt:    categoryRaw.CreatedAt,
-		UpdatedAt:    categoryRaw.UpdatedAt,
-		Note:         categoryRaw.Note,
-		CreatedBy:    categoryRaw.CreatedBy,
-		IsExpired:    isExpired,
-	}
-
-	return &category, nil
+		ID:        categoryRaw.ID,
+		Name:      categoryRaw.Name,
+		Amount:    categoryRaw.Amount,
+		MaxAmount: categoryRaw.MaxAmount,
+		PeriodDay: categoryRaw.PeriodDay,
+		CreatedAt: categoryRaw.CreatedAt,
+		UpdatedAt: categoryRaw.UpdatedAt,
+		Note:      categoryRaw.Note,
+		CreatedBy: categoryRaw.CreatedBy,
+		IsExpired: isExpired,
+		ParentId:  categoryRaw.ParentId,
+	}
+	categories := []ExpenseCategoryResponse{category}
+	rollUpExpenseCategories(all, categories)
+
+	return &categories[0], nil
 }
 
 func (bt *BudgetTracker) UpdateIncomeCategory(ctx context.Context, userId string, fields UpdateIncomeCategoryRequest) (*IncomeCategoryResponse, error) {
@@ -595,29 +875,42 @@ func (bt *BudgetTracker) UpdateIncomeCategory(ctx context.Context, userId string
 	if err != nil {
 		return nil, err
 	}
-
-	var usagePercent int
-	if category.TargetAmount > 0 {
-		usagePercent = int((category.Amount / category.TargetAmount) * 100)
+	all, err := bt.storage.GetFilteredIncomeCategories(ctx, userId, &IncomeCategoryList{IsAllNil: true})
+	if err != nil {
+		return nil, err
 	}
-	category.UsagePercent = usagePercent
-	return category, nil
+
+	categories := []IncomeCategoryResponse{*category}
+	rollUpIncomeCategories(all, categories)
+	return &categories[0], nil
 }
 
-func (bt *BudgetTracker) DeleteIncomeCategory(ctx context.Context, userId string, categoryId string) error {
-	err := bt.storage.DeleteIncomeCategory(ctx, userId, categoryId)
+// DeleteIncomeCategory deletes a category together with its transactions,
+// unless moveTo names another income category to move them to. It returns how
+// many transactions were deleted or moved.
+func (bt *BudgetTracker) DeleteIncomeCategory(ctx context.Context, userId string, categoryId string, moveTo string) (int, error) {
+	if err := bt.checkCategoryMoveTarget(ctx, userId, "+", categoryId, moveTo); err != nil {
+		return 0, err
+	}
+	affected, err := bt.storage.DeleteIncomeCategory(ctx, userId, categoryId, moveTo)
 	if err != nil {
-		return err
+		return 0, err
 	}
-	return nil
+	bt.forgetCategories(userId)
+	return affected, nil
 }
 
-func (bt *BudgetTracker) DeleteExpenseCategory(ctx context.Context, userId string, categoryId string) error {
-	err := bt.storage.DeleteExpenseCategory(ctx, userId, categoryId)
+// DeleteExpenseCategory is DeleteIncomeCategory for expense categories.
+func (bt *BudgetTracker) DeleteExpenseCategory(ctx context.Context, userId string, categoryId string, moveTo string) (int, error) {
+	if err := bt.checkCategoryMoveTarget(ctx, userId, "-", categoryId, moveTo); err != nil {
+		return 0, err
+	}
+	affected, err := bt.storage.DeleteExpenseCategory(ctx, userId, categoryId, moveTo)
 	if err != nil {
-		return err
+		return 0, err
 	}
-	return nil
+	bt.forgetCategories(userId)
+	return affected, nil
 }
 
 func (bt *BudgetTracker) GetFilteredTransactions(ctx context.Context, userID string, filters *TransactionList) ([]Transaction, error) {
@@ -634,9 +927,17 @@ func (bt *BudgetTracker) GetFilteredTransactions(ctx context.Context, userID str
 			CategoryType: transaction.CategoryType,
 			Amount:       transaction.Amount,
 			Currency:     transaction.Currency,
+			OccurredAt:   transaction.OccurredAt,
 			CreatedAt:    transaction.CreatedAt,
 			Note:         transaction.Note,
 			CreatedBy:    transaction.CreatedBy,
+			Splits:       transaction.Splits,
+			WalletId:     transaction.WalletId,
+			ToWalletId:   transaction.ToWalletId,
+			Cleared:      transaction.Cleared,
+			ReconciledAt: transaction.ReconciledAt,
+			Tags:         transaction.Tags,
+			PayeeId:      transaction.PayeeId,
 		}
 		transactions = append(transactions, t)
 	}
@@ -660,34 +961,371 @@ func (bt *BudgetTracker) LogoutUser(ctx context.Context, userId string, token st
 	return nil
 }
 
-func (bt *BudgetTracker) DownloadUserData(ctx context.Context, userId string) (UserDataResponse, error) {
-	data, err := bt.storage.GetUserData(ctx, userId)
+// DeleteUser only schedules the deletion: the account is signed out
+// everywhere and kept restorable until the grace period ends, after which
+// the purge job removes it for good.
+func (bt *BudgetTracker) DeleteUser(ctx context.Context, userId string, deleteReq auth.DeleteUser) (time.Time, error) {
+	if deleteReq.Password == "" {
+		return time.Time{}, appErrors.ErrorResponse{
+			Code:    appErrors.ErrInvalidInput,
+			Message: "Password cannot be empty!",
+		}
+	}
+
+	purgeAt := time.Now().UTC().Add(DeletionGracePeriod)
+	err := bt.storage.ScheduleUserDeletion(ctx, userId, deleteReq, purgeAt)
 	if err != nil {
-		return UserDataResponse{}, err
+		return time.Time{}, err
 	}
-	return data, nil
+
+	return purgeAt, nil
 }
 
-func (bt *BudgetTracker) DeleteUser(ctx context.Context, userId string, deleteReq auth.DeleteUser) error {
-	if deleteReq.Password == "" {
+var DeletionGracePeriod = DEFAULT_DELETION_GRACE_DAYS * 24 * time.Hour
+
+// InitDeletionGracePeriod reads ACCOUNT_DELETION_GRACE_DAYS over the default.
+func InitDeletionGracePeriod() error {
+	v := os.Getenv("ACCOUNT_DELETION_GRACE_DAYS")
+	if v == "" {
+		return nil
+	}
+	days, err := strconv.Atoi(v)
+	if err != nil || days < 1 {
+		return fmt.Errorf("invalid ACCOUNT_DELETION_GRACE_DAYS: %q", v)
+	}
+	DeletionGracePeriod = time.Duration(days) * 24 * time.Hour
+	return nil
+}
+
+func (bt *BudgetTracker) RestoreUser(ctx context.Context, credentials auth.UserCredentialsPure) (string, error) {
+	user, err := bt.ValidateUser(ctx, credentials)
+	if err != nil {
+		return "", err
+	}
+
+	if user.DeletionScheduledAt.IsZero() {
+		return "", appErrors.ErrorResponse{
+			Code:    appErrors.ErrInvalidInput,
+			Message: "Account is not scheduled for deletion.",
+		}
+	}
+	now := time.Now().UTC()
+	if !user.DeletionScheduledAt.After(now) {
+		return "", appErrors.ErrorResponse{
+			Code:    appErrors.ErrInvalidInput,
+			Message: "The grace period has ended, the account can no longer be restored.",
+		}
+	}
+
+	if err := bt.storage.RestoreUser(ctx, user.ID, now); err != nil {
+		return "", err
+	}
+
+	return bt.createSession(ctx, user.ID)
+}
+
+// PurgeDeletedUsers removes every account whose grace period has ended and
+// returns how many were purged.
+func (bt *BudgetTracker) PurgeDeletedUsers(ctx context.Context) (int, error) {
+	traceID := contextutil.TraceIDFromContext(ctx)
+
+	now := time.Now().UTC()
+	pending, err := bt.storage.GetUsersDueForPurge(ctx, now)
+	if err != nil {
+		return 0, err
+	}
+
+	purged := 0
+	for _, p := range pending {
+		if ctx.Err() != nil {
+			// shutting down, the next run picks up the rest
+			break
+		}
+		reason := AnonymizeReason(p.Reason, p.UserName, p.FullName, p.Email)
+		if err := bt.storage.PurgeUser(ctx, p.UserID, reason, now); err != nil {
+			var appErr appErrors.ErrorResponse
+			if errors.As(err, &appErr) && appErr.Code == appErrors.ErrConflict {
+				// restored since the list was read
+				continue
+			}
+			logging.Logger.Errorf("[TraceID=%s] | failed to purge user %s in Service.PurgeDeletedUsers() function | Error: %v", traceID, p.UserID, err)
+			continue
+		}
+		bt.forgetCategories(p.UserID)
+		purged++
+	}
+
+	return purged, nil
+}
+
+// RunDeletionPurger calls PurgeDeletedUsers every interval until ctx is done.
+func (bt *BudgetTracker) RunDeletionPurger(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		traceID := uuid.NewString()
+		runCtx := context.WithValue(ctx, contextutil.TraceIDKey, traceID)
+		purged, err := bt.PurgeDeletedUsers(runCtx)
+		if err != nil {
+			logging.Logger.Errorf("[TraceID=%s] | account purge failed | Error: %v", traceID, err)
+		} else if purged > 0 {
+			logging.Logger.Infof("[TraceID=%s] | purged %d deleted account(s)", traceID, purged)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+var emailLikeRegex = regexp.MustCompile(`[^\s@]+@[^\s@]+\.[^\s@]+`)
+
+// AnonymizeReason strips email addresses and the user's own identifiers from
+// a free-text deletion reason before it is kept.
+func AnonymizeReason(reason string, identifiers ...string) string {
+	reason = emailLikeRegex.ReplaceAllString(reason, "[redacted]")
+
+	var parts []string
+	for _, identifier := range identifiers {
+		for _, part := range strings.Fields(identifier) {
+			if len(part) >= 3 {
+				parts = append(parts, regexp.QuoteMeta(part))
+			}
+		}
+	}
+	if len(parts) > 0 {
+		identifierRegex := regexp.MustCompile(`(?i)\b(` + strings.Join(parts, "|") + `)\b`)
+		reason = identifierRegex.ReplaceAllString(reason, "[redacted]")
+	}
+
+	reason = strings.TrimSpace(reason)
+	if reason == "" {
+		return "-"
+	}
+	return reason
+}
+
+func (bt *BudgetTracker) GetAccountInfo(ctx context.Context, userId string) (AccountInfo, error) {
+	accInfo, err := bt.storage.GetAccountInfo(ctx, userId)
+	if err != nil {
+		return AccountInfo{}, err
+	}
+	return accInfo, nil
+}
+
+func (bt *BudgetTracker) ChangePassword(ctx context.Context, userId string, change auth.ChangePassword) error {
+	if change.CurrentPassword == "" {
 		return appErrors.ErrorResponse{
 			Code:    appErrors.ErrInvalidInput,
-			Message: "Password cannot be empty!",
+			Message: "Current password cannot be empty!",
 		}
 	}
+	if change.CurrentPassword == change.NewPassword {
+		return appErrors.ErrorResponse{
+			Code:    appErrors.ErrInvalidInput,
+			Message: "New password must be different from the current password",
+		}
+	}
+
+	accInfo, err := bt.storage.GetAccountInfo(ctx, userId)
+	if err != nil {
+		return err
+	}
+	if err := auth.ValidatePassword(change.NewPassword, accInfo.Username, accInfo.Email, accInfo.Fullname); err != nil {
+		return err
+	}
 
-	err := bt.storage.DeleteUser(ctx, userId, deleteReq)
+	hashedPassword, err := auth.HashPassword(ctx, change.NewPassword)
 	if err != nil {
 		return err
 	}
 
+	if err := bt.storage.UpdatePassword(ctx, userId, change.CurrentPassword, hashedPassword); err != nil {
+		return err
+	}
 	return nil
 }
 
-func (bt *BudgetTracker) GetAccountInfo(ctx context.Context, userId string) (AccountInfo, error) {
+// RequestPasswordReset mails a reset code to the account with the given email.
+// An unknown email is not reported, so the endpoint cannot be used to find
+// out who has an account.
+func (bt *BudgetTracker) RequestPasswordReset(ctx context.Context, email string) error {
+	traceID := contextutil.TraceIDFromContext(ctx)
+
+	email = strings.ToLower(strings.TrimSpace(email))
+	if email == "" {
+		return appErrors.ErrorResponse{
+			Code:    appErrors.ErrInvalidInput,
+			Message: "Email cannot be empty!",
+		}
+	}
+
+	token, err := generateToken()
+	if err != nil {
+		return err
+	}
+
+	now := time.Now().UTC()
+	reset := auth.PasswordReset{
+		ID:        uuid.New().String(),
+		Token:     token,
+		CreatedAt: now,
+		ExpireAt:  now.Add(PASSWORD_RESET_TTL),
+	}
+
+	if err := bt.storage.SavePasswordReset(ctx, email, reset); err != nil {
+		var appErr appErrors.ErrorResponse
+		if errors.As(err, &appErr) && appErr.Code == appErrors.ErrNotFound {
+			return nil
+		}
+		return err
+	}
+
+	body := fmt.Sprintf("Use this code to reset your Budget Tracker password: %s\n\nThe code expires in %d minutes. If you did not ask for a reset, you can ignore this message.", token, int(PASSWORD_RESET_TTL.Minutes()))
+	if err := bt.mailer.SendMail(ctx, email, "Reset your password", body); err != nil {
+		logging.Logger.Errorf("[TraceID=%s] | failed to send password reset in Service.RequestPasswordReset() function | Error: %v", traceID, err)
+		return appErrors.ErrorResponse{
+			Code:    appErrors.ErrInternal,
+			Message: "Failed to send password reset email, try again later.",
+		}
+	}
+	return nil
+}
+
+// ResetPassword sets a new password with a code from RequestPasswordReset,
+// the password policy applies as on registration and password change.
+func (bt *BudgetTracker) ResetPassword(ctx context.Context, reset auth.ResetPassword) error {
+	if reset.Token == "" {
+		return appErrors.ErrorResponse{
+			Code:    appErrors.ErrInvalidInput,
+			Message: "Reset code cannot be empty!",
+		}
+	}
+
+	now := time.Now().UTC()
+	userId, err := bt.storage.GetPasswordResetUser(ctx, reset.Token, now)
+	if err != nil {
+		return err
+	}
+
+	accInfo, err := bt.storage.GetAccountInfo(ctx, userId)
+	if err != nil {
+		return err
+	}
+	if err := auth.ValidatePassword(reset.NewPassword, accInfo.Username, accInfo.Email, accInfo.Fullname); err != nil {
+		return err
+	}
+
+	hashedPassword, err := auth.HashPassword(ctx, reset.NewPassword)
+	if err != nil {
+		return err
+	}
+
+	if err := bt.storage.ResetPassword(ctx, reset.Token, hashedPassword, now); err != nil {
+		return err
+	}
+	return nil
+}
+
+// UpdateAccount applies profile changes. Username and full name are saved
+// immediately; a new email is only stored as pending until the owner confirms
+// it with the token sent to that address.
+func (bt *BudgetTracker) UpdateAccount(ctx context.Context, userId string, update auth.UpdateUser) (AccountInfo, error) {
+	if err := update.ValidateUpdateFields(); err != nil {
+		return AccountInfo{}, err
+	}
+
+	current, err := bt.storage.GetAccountInfo(ctx, userId)
+	if err != nil {
+		return AccountInfo{}, err
+	}
+
+	userName := current.Username
+	if update.UserName != "" && strings.ToLower(update.UserName) != current.Username {
+		isUserExists, err := bt.IsUserExists(ctx, strings.ToLower(update.UserName))
+		if err != nil {
+			return AccountInfo{}, err
+		}
+		if isUserExists {
+			return AccountInfo{}, appErrors.ErrorResponse{
+				Code:    appErrors.ErrConflict,
+				Message: "Username already taken!",
+			}
+		}
+		userName = strings.ToLower(update.UserName)
+	}
+
+	fullName := current.Fullname
+	if update.FullName != "" {
+		fullName = CapitalizeFullName(update.FullName)
+	}
+
+	if userName != current.Username || fullName != current.Fullname {
+		if err := bt.storage.UpdateAccount(ctx, userId, userName, fullName); err != nil {
+			return AccountInfo{}, err
+		}
+	}
+
+	email := strings.ToLower(update.Email)
+	if email != "" && email != current.Email {
+		if err := bt.requestEmailChange(ctx, userId, email); err != nil {
+			return AccountInfo{}, err
+		}
+	}
+
 	accInfo, err := bt.storage.GetAccountInfo(ctx, userId)
 	if err != nil {
 		return AccountInfo{}, err
 	}
 	return accInfo, nil
 }
+
+func (bt *BudgetTracker) requestEmailChange(ctx context.Context, userId string, email string) error {
+	traceID := contextutil.TraceIDFromContext(ctx)
+
+	token, err := generateToken()
+	if err != nil {
+		return err
+	}
+
+	now := time.Now().UTC()
+	verification := auth.EmailVerification{
+		ID:        uuid.New().String(),
+		UserID:    userId,
+		Email:     email,
+		Token:     token,
+		CreatedAt: now,
+		ExpireAt:  now.Add(EMAIL_VERIFICATION_TTL),
+	}
+
+	if err := bt.storage.SaveEmailVerification(ctx, verification); err != nil {
+		return err
+	}
+
+	body := fmt.Sprintf("Use this code to confirm your new Budget Tracker email address: %s\n\nThe code expires in %d hours. If you did not request this change, you can ignore this message.", token, int(EMAIL_VERIFICATION_TTL.Hours()))
+	if err := bt.mailer.SendMail(ctx, email, "Confirm your new email address", body); err != nil {
+		logging.Logger.Errorf("[TraceID=%s] | failed to send email verification in Service.requestEmailChange() function | Error: %v", traceID, err)
+		return appErrors.ErrorResponse{
+			Code:    appErrors.ErrInternal,
+			Message: "Failed to send verification email, try again later.",
+		}
+	}
+	return nil
+}
+
+func (bt *BudgetTracker) ConfirmEmailChange(ctx context.Context, userId string, token string) error {
+	if token == "" {
+		return appErrors.ErrorResponse{
+			Code:    appErrors.ErrInvalidInput,
+			Message: "Verification code cannot be empty!",
+		}
+	}
+
+	if err := bt.storage.ConfirmEmailChange(ctx, userId, token); err != nil {
+		return err
+	}
+	return nil
+}
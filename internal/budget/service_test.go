@@ -1,20 +1,52 @@
 package budget
 
 import (
+	"archive/zip"
+	"bytes"
 	"context"
+	"encoding/json"
+	"encoding/xml"
 	"errors"
 	"fmt"
+	"io"
 	"math"
+	"os"
+	"regexp"
+	"runtime"
+	"slices"
+	"sort"
+	"strconv"
 	"strings"
 	"testing"
 	"time"
 
 	appErrors "github.com/fatali-fataliyev/budget_tracker/customErrors"
 	"github.com/fatali-fataliyev/budget_tracker/internal/auth"
+	"github.com/fatali-fataliyev/budget_tracker/logging"
+	"github.com/sirupsen/logrus"
 )
 
 // Mocks
 type MockStorage struct {
+	ImportedBatch     *ImportBatch
+	SavedTransactions []Transaction
+	Recurring         map[string]RecurringTransaction
+	Wallets           map[string]Wallet
+	Reconciliations   map[string]Reconciliation
+	Tags              map[string]Tag
+	ExpenseCategories []ExpenseCategoryResponse // replaces the default category when set
+	MovedCategories   map[string]string
+	DeletedCategories map[string]string // category ID -> the category its transactions moved to
+	Merges            []CategoryMerge
+	Goals             map[string]SavingsGoal
+	Contributions     []SavingsContribution
+	Debts             map[string]Debt
+	DebtPayments      []DebtPayment
+	History           []Transaction // returned by GetFilteredTransactions when set
+	Payees            map[string]Payee
+	PayeeLinks        map[string]string // transaction ID -> payee ID, set by SetTransactionPayee
+	Rules             map[string]Rule
+	RuleChanges       []RuleChange
 }
 
 func (m *MockStorage) SaveUser(ctx context.Context, newUser auth.User) error {
@@ -25,6 +57,12 @@ func (m *MockStorage) ValidateUser(ctx context.Context, creds auth.UserCredentia
 	if creds.UserName == "john" {
 		return auth.User{ID: "1234", UserName: "valid_user"}, nil
 	}
+	if creds.UserName == "pending_john" {
+		return auth.User{ID: "5678", UserName: "pending_john", DeletionScheduledAt: time.Now().Add(24 * time.Hour)}, nil
+	}
+	if creds.UserName == "expired_john" {
+		return auth.User{ID: "9012", UserName: "expired_john", DeletionScheduledAt: time.Now().Add(-time.Hour)}, nil
+	}
 	return auth.User{}, errors.New("storage error")
 }
 
@@ -73,16 +111,27 @@ func (m *MockStorage) GetSessionByToken(ctx context.Context, token string) (auth
 }
 
 func (m *MockStorage) SaveTransaction(ctx context.Context, t Transaction) error {
+	if t.CategoryId == "deleted" {
+		return appErrors.ErrorResponse{
+			Code:    appErrors.ErrInvalidInput,
+			Message: "The category does not exist, please create the category",
+		}
+	}
+	m.SavedTransactions = append(m.SavedTransactions, t)
 	return nil
 }
 
 func (m *MockStorage) GetFilteredTransactions(ctx context.Context, userID string, filters *TransactionList) ([]Transaction, error) {
+	if m.History != nil {
+		return m.History, nil
+	}
 	transactions := []Transaction{
 		{
 			ID:           "ts-1",
 			CategoryType: "+",
 			Amount:       30.45,
 			Currency:     "USD",
+			OccurredAt:   time.Now(),
 			CreatedAt:    time.Now(),
 			Note:         "Freelance",
 			CreatedBy:    "john-1234",
@@ -92,7 +141,330 @@ func (m *MockStorage) GetFilteredTransactions(ctx context.Context, userID string
 	return transactions, nil
 }
 
+func (m *MockStorage) EachTransaction(ctx context.Context, userId string, fn func(Transaction) error) error {
+	transactions, _ := m.GetFilteredTransactions(ctx, userId, &TransactionList{IsAllNil: true})
+	for _, t := range transactions {
+		if err := fn(t); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (m *MockStorage) EachFilteredTransaction(ctx context.Context, userId string, filters *TransactionList, fn func(Transaction) error) error {
+	transactions, err := m.GetFilteredTransactions(ctx, userId, filters)
+	if err != nil {
+		return err
+	}
+	for _, t := range transactions {
+		if err := fn(t); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (m *MockStorage) SaveRecurringTransaction(ctx context.Context, r RecurringTransaction) error {
+	if m.Recurring == nil {
+		m.Recurring = map[string]RecurringTransaction{}
+	}
+	m.Recurring[r.ID] = r
+	return nil
+}
+
+func (m *MockStorage) GetRecurringTransactions(ctx context.Context, userId string) ([]RecurringTransaction, error) {
+	recurring := []RecurringTransaction{}
+	for _, r := range m.Recurring {
+		if r.CreatedBy == userId {
+			recurring = append(recurring, r)
+		}
+	}
+	return recurring, nil
+}
+
+func (m *MockStorage) GetRecurringTransactionById(ctx context.Context, userId string, id string) (RecurringTransaction, error) {
+	r, ok := m.Recurring[id]
+	if !ok || r.CreatedBy != userId {
+		return RecurringTransaction{}, appErrors.ErrorResponse{Code: appErrors.ErrNotFound, Message: "Recurring transaction not found."}
+	}
+	return r, nil
+}
+
+func (m *MockStorage) UpdateRecurringSchedule(ctx context.Context, r RecurringTransaction) error {
+	stored := m.Recurring[r.ID]
+	stored.NextRunAt, stored.Paused, stored.PausedReason, stored.UpdatedAt = r.NextRunAt, r.Paused, r.PausedReason, r.UpdatedAt
+	m.Recurring[r.ID] = stored
+	return nil
+}
+
+func (m *MockStorage) SaveRecurringInstance(ctx context.Context, r RecurringTransaction, t Transaction) error {
+	stored, ok := m.Recurring[r.ID]
+	if !ok || stored.Paused || !stored.NextRunAt.Equal(t.OccurredAt) {
+		return appErrors.ErrorResponse{Code: appErrors.ErrConflict, Message: "The instance was already created."}
+	}
+	if err := m.SaveTransaction(ctx, t); err != nil {
+		return err
+	}
+	stored.NextRunAt, stored.UpdatedAt = r.NextRunAt, r.UpdatedAt
+	m.Recurring[r.ID] = stored
+	return nil
+}
+
+func (m *MockStorage) DeleteRecurringTransaction(ctx context.Context, userId string, id string) error {
+	delete(m.Recurring, id)
+	return nil
+}
+
+func (m *MockStorage) GetDueRecurringTransactions(ctx context.Context, now time.Time) ([]RecurringTransaction, error) {
+	due := []RecurringTransaction{}
+	for _, r := range m.Recurring {
+		if !r.Paused && !r.NextRunAt.IsZero() && !r.NextRunAt.After(now) {
+			due = append(due, r)
+		}
+	}
+	return due, nil
+}
+
+func (m *MockStorage) SaveWallet(ctx context.Context, w Wallet) error {
+	if m.Wallets == nil {
+		m.Wallets = map[string]Wallet{}
+	}
+	m.Wallets[w.ID] = w
+	return nil
+}
+
+func (m *MockStorage) GetWallets(ctx context.Context, userId string) ([]Wallet, error) {
+	wallets := []Wallet{}
+	for _, w := range m.Wallets {
+		if w.CreatedBy == userId {
+			wallets = append(wallets, w)
+		}
+	}
+	return wallets, nil
+}
+
+func (m *MockStorage) GetWalletById(ctx context.Context, userId string, id string) (Wallet, error) {
+	w, ok := m.Wallets[id]
+	if !ok || w.CreatedBy != userId {
+		return Wallet{}, appErrors.ErrorResponse{Code: appErrors.ErrNotFound, Message: "Wallet not found."}
+	}
+	return w, nil
+}
+
+func (m *MockStorage) UpdateWallet(ctx context.Context, w Wallet) error {
+	m.Wallets[w.ID] = w
+	return nil
+}
+
+func (m *MockStorage) DeleteWallet(ctx context.Context, userId string, id string) error {
+	delete(m.Wallets, id)
+	return nil
+}
+
+func (m *MockStorage) GetWalletTransactions(ctx context.Context, userId string, walletId string) ([]Transaction, error) {
+	transactions := []Transaction{}
+	for _, t := range m.SavedTransactions {
+		if t.WalletId == walletId || t.ToWalletId == walletId {
+			transactions = append(transactions, t)
+		}
+	}
+	return transactions, nil
+}
+
+func (m *MockStorage) SaveReconciliation(ctx context.Context, r Reconciliation) error {
+	if m.Reconciliations == nil {
+		m.Reconciliations = map[string]Reconciliation{}
+	}
+	m.Reconciliations[r.ID] = r
+	return nil
+}
+
+func (m *MockStorage) GetOpenReconciliation(ctx context.Context, userId string, walletId string) (Reconciliation, error) {
+	for _, r := range m.Reconciliations {
+		if r.CreatedBy == userId && r.WalletId == walletId && r.FinishedAt.IsZero() {
+			return r, nil
+		}
+	}
+	return Reconciliation{}, appErrors.ErrorResponse{Code: appErrors.ErrNotFound, Message: "The wallet has no open reconciliation."}
+}
+
+func (m *MockStorage) SetTransactionsCleared(ctx context.Context, userId string, transactionIds []string, cleared bool) error {
+	for i, t := range m.SavedTransactions {
+		if slices.Contains(transactionIds, t.ID) && t.ReconciledAt.IsZero() {
+			m.SavedTransactions[i].Cleared = cleared
+		}
+	}
+	return nil
+}
+
+func (m *MockStorage) FinishReconciliation(ctx context.Context, r Reconciliation, transactionIds []string) error {
+	for i, t := range m.SavedTransactions {
+		if slices.Contains(transactionIds, t.ID) {
+			m.SavedTransactions[i].ReconciledAt = r.FinishedAt
+		}
+	}
+	m.Reconciliations[r.ID] = r
+	return nil
+}
+
+func (m *MockStorage) SaveTag(ctx context.Context, tag Tag) error {
+	if m.Tags == nil {
+		m.Tags = map[string]Tag{}
+	}
+	for _, existing := range m.Tags {
+		if existing.CreatedBy == tag.CreatedBy && existing.Name == tag.Name {
+			return appErrors.ErrorResponse{Code: appErrors.ErrConflict, Message: "A tag with this name already exists."}
+		}
+	}
+	m.Tags[tag.ID] = tag
+	return nil
+}
+
+func (m *MockStorage) GetTags(ctx context.Context, userId string) ([]Tag, error) {
+	tags := []Tag{}
+	for _, tag := range m.Tags {
+		if tag.CreatedBy == userId {
+			tags = append(tags, tag)
+		}
+	}
+	return tags, nil
+}
+
+func (m *MockStorage) GetTagById(ctx context.Context, userId string, id string) (Tag, error) {
+	tag, ok := m.Tags[id]
+	if !ok || tag.CreatedBy != userId {
+		return Tag{}, appErrors.ErrorResponse{Code: appErrors.ErrNotFound, Message: "Tag not found."}
+	}
+	return tag, nil
+}
+
+func (m *MockStorage) UpdateTag(ctx context.Context, tag Tag) error {
+	m.Tags[tag.ID] = tag
+	return nil
+}
+
+func (m *MockStorage) DeleteTag(ctx context.Context, userId string, id string) error {
+	delete(m.Tags, id)
+	return nil
+}
+
+func (m *MockStorage) SetTransactionTags(ctx context.Context, userId string, transactionId string, tags []string) error {
+	for i, t := range m.SavedTransactions {
+		if t.ID == transactionId {
+			m.SavedTransactions[i].Tags = tags
+		}
+	}
+	return nil
+}
+
+func (m *MockStorage) SavePayee(ctx context.Context, p Payee) error {
+	if m.Payees == nil {
+		m.Payees = map[string]Payee{}
+	}
+	m.Payees[p.ID] = p
+	return nil
+}
+
+func (m *MockStorage) GetPayees(ctx context.Context, userId string) ([]Payee, error) {
+	payees := []Payee{}
+	for _, p := range m.Payees {
+		if p.CreatedBy == userId {
+			payees = append(payees, p)
+		}
+	}
+	sort.Slice(payees, func(i, j int) bool { return payees[i].Name < payees[j].Name })
+	return payees, nil
+}
+
+func (m *MockStorage) GetPayeeById(ctx context.Context, userId string, id string) (Payee, error) {
+	p, ok := m.Payees[id]
+	if !ok || p.CreatedBy != userId {
+		return Payee{}, appErrors.ErrorResponse{Code: appErrors.ErrNotFound, Message: "Payee not found."}
+	}
+	return p, nil
+}
+
+func (m *MockStorage) UpdatePayee(ctx context.Context, p Payee) error {
+	m.Payees[p.ID] = p
+	return nil
+}
+
+func (m *MockStorage) DeletePayee(ctx context.Context, userId string, id string) error {
+	delete(m.Payees, id)
+	return nil
+}
+
+func (m *MockStorage) SetTransactionPayee(ctx context.Context, userId string, transactionIds []string, payeeId string) error {
+	if m.PayeeLinks == nil {
+		m.PayeeLinks = map[string]string{}
+	}
+	for _, id := range transactionIds {
+		m.PayeeLinks[id] = payeeId
+	}
+	return nil
+}
+
+func (m *MockStorage) SaveRule(ctx context.Context, r Rule) error {
+	if m.Rules == nil {
+		m.Rules = map[string]Rule{}
+	}
+	m.Rules[r.ID] = r
+	return nil
+}
+
+func (m *MockStorage) GetRules(ctx context.Context, userId string) ([]Rule, error) {
+	rules := []Rule{}
+	for _, r := range m.Rules {
+		if r.CreatedBy == userId {
+			rules = append(rules, r)
+		}
+	}
+	sort.Slice(rules, func(i, j int) bool { return rules[i].ID < rules[j].ID })
+	return rules, nil
+}
+
+func (m *MockStorage) GetRuleById(ctx context.Context, userId string, id string) (Rule, error) {
+	r, ok := m.Rules[id]
+	if !ok || r.CreatedBy != userId {
+		return Rule{}, appErrors.ErrorResponse{Code: appErrors.ErrNotFound, Message: "Rule not found."}
+	}
+	return r, nil
+}
+
+func (m *MockStorage) UpdateRule(ctx context.Context, r Rule) error {
+	m.Rules[r.ID] = r
+	return nil
+}
+
+func (m *MockStorage) DeleteRule(ctx context.Context, userId string, id string) error {
+	delete(m.Rules, id)
+	return nil
+}
+
+func (m *MockStorage) SaveRuleChanges(ctx context.Context, userId string, tags []Tag, changes []RuleChange) error {
+	for _, tag := range tags {
+		if err := m.SaveTag(ctx, tag); err != nil {
+			return err
+		}
+	}
+	m.RuleChanges = append(m.RuleChanges, changes...)
+	return nil
+}
+
 func (m *MockStorage) GetFilteredExpenseCategories(ctx context.Context, userID string, filters *ExpenseCategoryList) ([]ExpenseCategoryResponse, error) {
+	if m.ExpenseCategories != nil {
+		if filters.IsAllNil {
+			return m.ExpenseCategories, nil
+		}
+		var categories []ExpenseCategoryResponse
+		for _, c := range m.ExpenseCategories {
+			if slices.Contains(filters.Names, c.Name) {
+				categories = append(categories, c)
+			}
+		}
+		return categories, nil
+	}
+
 	categories := []ExpenseCategoryResponse{
 		{
 			ID:           "ts-1",
@@ -128,6 +500,170 @@ func (m *MockStorage) GetFilteredIncomeCategories(ctx context.Context, userID st
 	return categories, nil
 }
 
+func (m *MockStorage) GetCategoryParents(ctx context.Context, userId string, categoryType string) (map[string]string, error) {
+	parents := map[string]string{}
+	for _, c := range m.ExpenseCategories {
+		parents[c.ID] = c.ParentId
+	}
+	return parents, nil
+}
+
+func (m *MockStorage) SetCategoryParent(ctx context.Context, userId string, categoryType string, id string, parentId string) error {
+	if m.MovedCategories == nil {
+		m.MovedCategories = map[string]string{}
+	}
+	m.MovedCategories[id] = parentId
+	return nil
+}
+
+func (m *MockStorage) MergeCategories(ctx context.Context, merge CategoryMerge) (int, error) {
+	moved := 0
+	for _, t := range m.SavedTransactions {
+		for _, source := range merge.Sources {
+			if t.CategoryId == source.ID {
+				moved++
+			}
+		}
+	}
+	merge.MovedTransactions = moved
+	m.Merges = append(m.Merges, merge)
+	return moved, nil
+}
+
+func (m *MockStorage) GetCategoryMerges(ctx context.Context, userId string, categoryType string) ([]CategoryMerge, error) {
+	return m.Merges, nil
+}
+
+func (m *MockStorage) SaveSavingsGoal(ctx context.Context, g SavingsGoal) error {
+	if m.Goals == nil {
+		m.Goals = map[string]SavingsGoal{}
+	}
+	m.Goals[g.ID] = g
+	return nil
+}
+
+func (m *MockStorage) GetSavingsGoals(ctx context.Context, userId string) ([]SavingsGoal, error) {
+	goals := []SavingsGoal{}
+	for id := range m.Goals {
+		if g, err := m.GetSavingsGoalById(ctx, userId, id); err == nil {
+			goals = append(goals, g)
+		}
+	}
+	return goals, nil
+}
+
+func (m *MockStorage) GetSavingsGoalById(ctx context.Context, userId string, id string) (SavingsGoal, error) {
+	g, ok := m.Goals[id]
+	if !ok || g.CreatedBy != userId {
+		return SavingsGoal{}, appErrors.ErrorResponse{Code: appErrors.ErrNotFound, Message: "Goal not found."}
+	}
+	var saved int64
+	for _, c := range m.Contributions {
+		if c.GoalId == id {
+			saved += toCents(c.Amount)
+		}
+	}
+	g.Saved = float64(saved) / 100
+	return g, nil
+}
+
+func (m *MockStorage) UpdateSavingsGoal(ctx context.Context, g SavingsGoal) error {
+	m.Goals[g.ID] = g
+	return nil
+}
+
+func (m *MockStorage) DeleteSavingsGoal(ctx context.Context, userId string, id string) error {
+	delete(m.Goals, id)
+	return nil
+}
+
+func (m *MockStorage) SaveSavingsContribution(ctx context.Context, c SavingsContribution) error {
+	m.Contributions = append(m.Contributions, c)
+	return nil
+}
+
+func (m *MockStorage) GetSavingsContributions(ctx context.Context, userId string, goalId string) ([]SavingsContribution, error) {
+	contributions := []SavingsContribution{}
+	for _, c := range m.Contributions {
+		if c.GoalId == goalId {
+			contributions = append(contributions, c)
+		}
+	}
+	return contributions, nil
+}
+
+func (m *MockStorage) SaveDebt(ctx context.Context, d Debt) error {
+	if m.Debts == nil {
+		m.Debts = map[string]Debt{}
+	}
+	m.Debts[d.ID] = d
+	return nil
+}
+
+func (m *MockStorage) GetDebts(ctx context.Context, userId string) ([]Debt, error) {
+	debts := []Debt{}
+	for id := range m.Debts {
+		if d, err := m.GetDebtById(ctx, userId, id); err == nil {
+			debts = append(debts, d)
+		}
+	}
+	return debts, nil
+}
+
+func (m *MockStorage) GetDebtById(ctx context.Context, userId string, id string) (Debt, error) {
+	d, ok := m.Debts[id]
+	if !ok || d.CreatedBy != userId {
+		return Debt{}, appErrors.ErrorResponse{Code: appErrors.ErrNotFound, Message: "Debt not found."}
+	}
+	var principal, interest int64
+	for _, p := range m.DebtPayments {
+		if p.DebtId == id {
+			principal += toCents(p.Principal)
+			interest += toCents(p.Interest)
+			if p.OccurredAt.After(d.LastPaymentAt) {
+				d.LastPaymentAt = p.OccurredAt
+			}
+		}
+	}
+	d.PaidPrincipal, d.PaidInterest = float64(principal)/100, float64(interest)/100
+	return d, nil
+}
+
+func (m *MockStorage) UpdateDebt(ctx context.Context, d Debt) error {
+	m.Debts[d.ID] = d
+	return nil
+}
+
+func (m *MockStorage) DeleteDebt(ctx context.Context, userId string, id string) error {
+	delete(m.Debts, id)
+	return nil
+}
+
+func (m *MockStorage) SaveDebtPayment(ctx context.Context, p DebtPayment, t Transaction) error {
+	m.DebtPayments = append(m.DebtPayments, p)
+	m.SavedTransactions = append(m.SavedTransactions, t)
+	return nil
+}
+
+func (m *MockStorage) GetDebtPayments(ctx context.Context, userId string, debtId string) ([]DebtPayment, error) {
+	payments := []DebtPayment{}
+	for _, p := range m.DebtPayments {
+		if p.DebtId == debtId {
+			payments = append(payments, p)
+		}
+	}
+	return payments, nil
+}
+
+func (m *MockStorage) SetCategoryArchived(ctx context.Context, userId string, categoryType string, id string, archivedAt time.Time) error {
+	for i := range m.ExpenseCategories {
+		if m.ExpenseCategories[i].ID == id {
+			m.ExpenseCategories[i].ArchivedAt = archivedAt
+		}
+	}
+	return nil
+}
+
 func (m *MockStorage) GetTransactionById(ctx context.Context, userID string, transacationID string) (Transaction, error) {
 	transaction := Transaction{
 		ID:           "ts-1",
@@ -173,6 +709,9 @@ func (m *MockStorage) GetTransactionStats(ctx context.Context, userId string) (T
 }
 
 func (m *MockStorage) IsUserExists(ctx context.Context, username string) (bool, error) {
+	if username == "taken_name" {
+		return true, nil
+	}
 	return false, nil
 }
 
@@ -198,12 +737,22 @@ func (m *MockStorage) UpdateExpenseCategory(ctx context.Context, userId string,
 	return &updatedExpenseCategory, nil
 }
 
-func (m *MockStorage) DeleteExpenseCategory(ctx context.Context, userId string, categoryId string) error {
-	return nil
+func (m *MockStorage) DeleteExpenseCategory(ctx context.Context, userId string, categoryId string, moveTo string) (int, error) {
+	if m.DeletedCategories == nil {
+		m.DeletedCategories = map[string]string{}
+	}
+	m.DeletedCategories[categoryId] = moveTo
+	affected := 0
+	for _, t := range m.SavedTransactions {
+		if t.CategoryId == categoryId {
+			affected++
+		}
+	}
+	return affected, nil
 }
 
-func (m *MockStorage) DeleteIncomeCategory(ctx context.Context, userId string, categoryId string) error {
-	return nil
+func (m *MockStorage) DeleteIncomeCategory(ctx context.Context, userId string, categoryId string, moveTo string) (int, error) {
+	return 0, nil
 }
 
 func (m *MockStorage) UpdateIncomeCategory(ctx context.Context, userId string, fields UpdateIncomeCategoryRequest) (*IncomeCategoryResponse, error) {
@@ -225,7 +774,26 @@ func (m *MockStorage) LogoutUser(ctx context.Context, userId string, token strin
 	return nil
 }
 
-func (m *MockStorage) DeleteUser(ctx context.Context, userId string, deleteReq auth.DeleteUser) error {
+func (m *MockStorage) ScheduleUserDeletion(ctx context.Context, userId string, deleteReq auth.DeleteUser, purgeAt time.Time) error {
+	return nil
+}
+
+func (m *MockStorage) RestoreUser(ctx context.Context, userId string, now time.Time) error {
+	return nil
+}
+
+func (m *MockStorage) GetUsersDueForPurge(ctx context.Context, now time.Time) ([]auth.PendingDeletion, error) {
+	return []auth.PendingDeletion{
+		{UserID: "old-1", UserName: "john", Email: "john@gmail.com", Reason: "john here, mail me at john@gmail.com"},
+		{UserID: "old-2", UserName: "jane", Reason: ""},
+		{UserID: "restored-3", UserName: "jim", Reason: "changed my mind"},
+	}, nil
+}
+
+func (m *MockStorage) PurgeUser(ctx context.Context, userId string, anonymizedReason string, now time.Time) error {
+	if userId == "restored-3" {
+		return appErrors.ErrorResponse{Code: appErrors.ErrConflict, Message: "Account is not due for deletion."}
+	}
 	return nil
 }
 
@@ -239,6 +807,20 @@ func (m *MockStorage) GetUserData(ctx context.Context, userId string) (UserDataR
 	return userData, nil
 }
 
+func (m *MockStorage) SaveImportBatch(ctx context.Context, userId string, batch ImportBatch) error {
+	for _, tag := range batch.Tags {
+		if err := m.SaveTag(ctx, tag); err != nil {
+			return err
+		}
+	}
+	m.ImportedBatch = &batch
+	return nil
+}
+
+func (m *MockStorage) GetExistingExternalIds(ctx context.Context, userId string, externalIds []string) (map[string]bool, error) {
+	return map[string]bool{"ofx:12345:already-imported": true}, nil
+}
+
 func (m *MockStorage) GetAccountInfo(ctx context.Context, userId string) (AccountInfo, error) {
 	accountInfo := AccountInfo{
 		Username: "john",
@@ -250,10 +832,71 @@ func (m *MockStorage) GetAccountInfo(ctx context.Context, userId string) (Accoun
 	return accountInfo, nil
 }
 
+func (m *MockStorage) UpdatePassword(ctx context.Context, userId string, currentPassword string, newHashedPassword string) error {
+	if currentPassword != "1234" {
+		return appErrors.ErrorResponse{
+			Code:    appErrors.ErrInvalidInput,
+			Message: "Current password is incorrect",
+		}
+	}
+	return nil
+}
+
+func (m *MockStorage) UpdateAccount(ctx context.Context, userId string, userName string, fullName string) error {
+	return nil
+}
+
+func (m *MockStorage) SaveEmailVerification(ctx context.Context, verification auth.EmailVerification) error {
+	if verification.Email == "taken@gmail.com" {
+		return appErrors.ErrorResponse{
+			Code:    appErrors.ErrConflict,
+			Message: "Email already taken!",
+		}
+	}
+	return nil
+}
+
+func (m *MockStorage) ConfirmEmailChange(ctx context.Context, userId string, token string) error {
+	return nil
+}
+
+func (m *MockStorage) SavePasswordReset(ctx context.Context, email string, reset auth.PasswordReset) error {
+	if email != "john@gmail.com" {
+		return appErrors.ErrorResponse{
+			Code:    appErrors.ErrNotFound,
+			Message: "User does not exist.",
+		}
+	}
+	return nil
+}
+
+func (m *MockStorage) GetPasswordResetUser(ctx context.Context, token string, now time.Time) (string, error) {
+	if token != "reset-1234" {
+		return "", appErrors.ErrorResponse{
+			Code:    appErrors.ErrInvalidInput,
+			Message: "Reset code is invalid or expired, please request a new one.",
+		}
+	}
+	return "john123", nil
+}
+
+func (m *MockStorage) ResetPassword(ctx context.Context, token string, newHashedPassword string, now time.Time) error {
+	return nil
+}
+
 func (m *MockStorage) GetStorageType() string {
 	return "MySQL"
 }
 
+type MockMailer struct {
+	Sent []string
+}
+
+func (m *MockMailer) SendMail(ctx context.Context, to string, subject string, body string) error {
+	m.Sent = append(m.Sent, to)
+	return nil
+}
+
 // Tests
 
 func TestSaveUser(t *testing.T) {
@@ -589,18 +1232,19 @@ func TestSaveTransaction(t *testing.T) {
 		expectedMsg string
 	}{
 		{
-			name: "Fail - Empty name",
+			name: "Fail - Empty category ID",
 			input: TransactionRequest{
 				CategoryType: "-",
 				Amount:       30.33,
 				Currency:     "USD",
 				Note:         "tires replaced",
 			},
-			expectedMsg: "Category name cannot be empty!",
+			expectedMsg: "Category ID cannot be empty!",
 		},
 		{
 			name: "Fail - Zero Amount with decimal",
 			input: TransactionRequest{
+				CategoryId:   "ts-1",
 				CategoryType: "-",
 				Amount:       0.0,
 				Currency:     "USD",
@@ -611,6 +1255,7 @@ func TestSaveTransaction(t *testing.T) {
 		{
 			name: "Fail - Zero Amount",
 			input: TransactionRequest{
+				CategoryId:   "ts-1",
 				CategoryType: "-",
 				Amount:       0,
 				Currency:     "USD",
@@ -621,6 +1266,7 @@ func TestSaveTransaction(t *testing.T) {
 		{
 			name: "Fail - Maximum Amount",
 			input: TransactionRequest{
+				CategoryId:   "ts-1",
 				CategoryType: "-",
 				Amount:       math.MaxUint64,
 				Currency:     "USD",
@@ -629,18 +1275,21 @@ func TestSaveTransaction(t *testing.T) {
 			expectedMsg: "allowed amount per transaction",
 		},
 		{
-			name: "Fail - Long category name",
+			name: "Fail - Date far in the future",
 			input: TransactionRequest{
+				CategoryId:   "ts-1",
 				CategoryType: "-",
 				Amount:       3000,
 				Currency:     "USD",
 				Note:         "eCommerce",
+				OccurredAt:   time.Now().AddDate(0, 1, 0),
 			},
-			expectedMsg: "Category name so long",
+			expectedMsg: "cannot be in the future",
 		},
 		{
 			name: "Fail - Long Currency name",
 			input: TransactionRequest{
+				CategoryId:   "ts-1",
 				CategoryType: "-",
 				Amount:       3000,
 				Currency:     strings.Repeat("A", 256),
@@ -651,6 +1300,7 @@ func TestSaveTransaction(t *testing.T) {
 		{
 			name: "Fail - Long Note",
 			input: TransactionRequest{
+				CategoryId:   "ts-1",
 				CategoryType: "-",
 				Amount:       3000,
 				Currency:     "USD",
@@ -659,15 +1309,90 @@ func TestSaveTransaction(t *testing.T) {
 			expectedMsg: "Note so long",
 		},
 		{
-			name: "Success - Valid transaction",
+			name: "Fail - Splits do not add up",
 			input: TransactionRequest{
-				CategoryType: "+",
-				Amount:       3000,
+				CategoryType: "-",
+				Amount:       50,
+				Currency:     "USD",
+				Splits:       []TransactionSplitRequest{{CategoryId: "ts-1", Amount: 30}, {CategoryId: "ts-2", Amount: 19.99}},
+			},
+			expectedMsg: "Splits add up to 49.99",
+		},
+		{
+			name: "Fail - Split with category ID",
+			input: TransactionRequest{
+				CategoryId:   "ts-1",
+				CategoryType: "-",
+				Amount:       50,
+				Currency:     "USD",
+				Splits:       []TransactionSplitRequest{{CategoryId: "ts-1", Amount: 30}, {CategoryId: "ts-2", Amount: 20}},
+			},
+			expectedMsg: "Category ID must be empty",
+		},
+		{
+			name: "Fail - Single split",
+			input: TransactionRequest{
+				CategoryType: "-",
+				Amount:       50,
+				Currency:     "USD",
+				Splits:       []TransactionSplitRequest{{CategoryId: "ts-1", Amount: 50}},
+			},
+			expectedMsg: "between 2 and 50 splits",
+		},
+		{
+			name: "Fail - Split without category",
+			input: TransactionRequest{
+				CategoryType: "-",
+				Amount:       50,
+				Currency:     "USD",
+				Splits:       []TransactionSplitRequest{{CategoryId: "ts-1", Amount: 30}, {Amount: 20}},
+			},
+			expectedMsg: "Category ID of split 2",
+		},
+		{
+			name: "Fail - Negative split",
+			input: TransactionRequest{
+				CategoryType: "-",
+				Amount:       50,
+				Currency:     "USD",
+				Splits:       []TransactionSplitRequest{{CategoryId: "ts-1", Amount: 70}, {CategoryId: "ts-2", Amount: -20}},
+			},
+			expectedMsg: "Amount of split 2",
+		},
+		{
+			name: "Success - Supermarket receipt split",
+			input: TransactionRequest{
+				CategoryType: "-",
+				Amount:       0.3,
+				Currency:     "USD",
+				Note:         "supermarket",
+				Splits:       []TransactionSplitRequest{{CategoryId: "ts-2", Amount: 0.1, Note: "bread"}, {CategoryId: "ts-1", Amount: 0.2, Note: "light bulbs"}},
+			},
+			expectedMsg: "",
+		},
+		{
+			name: "Success - Valid transaction",
+			input: TransactionRequest{
+				CategoryId:   "ts-1",
+				CategoryType: "+",
+				Amount:       3000,
 				Currency:     "USD",
 				Note:         "work work work",
 			},
 			expectedMsg: "",
 		},
+		{
+			name: "Success - Dinner yesterday",
+			input: TransactionRequest{
+				CategoryId:   "ts-1",
+				CategoryType: "-",
+				Amount:       42.5,
+				Currency:     "USD",
+				Note:         "dinner",
+				OccurredAt:   time.Date(2026, 3, 14, 20, 30, 0, 0, time.FixedZone("UTC+4", 4*3600)),
+			},
+			expectedMsg: "",
+		},
 	}
 
 	for _, tt := range tests {
@@ -692,7 +1417,29 @@ func TestSaveTransaction(t *testing.T) {
 
 			} else {
 				if err != nil {
-					t.Errorf("Expected success, but got error: %v", err)
+					t.Fatalf("Expected success, but got error: %v", err)
+				}
+
+				saved := mockStore.SavedTransactions[len(mockStore.SavedTransactions)-1]
+				if time.Since(saved.CreatedAt) > time.Minute {
+					t.Errorf("Expected recorded time to be now, got %v", saved.CreatedAt)
+				}
+				if tt.input.OccurredAt.IsZero() {
+					if !saved.OccurredAt.Equal(saved.CreatedAt) {
+						t.Errorf("Expected occurred time to default to now, got %v", saved.OccurredAt)
+					}
+				} else if !saved.OccurredAt.Equal(tt.input.OccurredAt) || saved.OccurredAt.Location() != time.UTC {
+					t.Errorf("Expected occurred time %v in UTC, got %v", tt.input.OccurredAt, saved.OccurredAt)
+				}
+				if len(tt.input.Splits) > 0 {
+					if len(saved.Splits) != len(tt.input.Splits) || saved.CategoryId != tt.input.Splits[0].CategoryId {
+						t.Errorf("Expected %d splits under the first split's category, got %+v", len(tt.input.Splits), saved)
+					}
+					for i, split := range saved.Splits {
+						if split.ID == "" || split.CategoryId != tt.input.Splits[i].CategoryId || split.Amount != tt.input.Splits[i].Amount {
+							t.Errorf("Split %d mismatch: %+v", i, split)
+						}
+					}
 				}
 			}
 		})
@@ -912,7 +1659,89 @@ func TestDeleteUser(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			err := bt.DeleteUser(ctx, userId, tt.input)
+			_, err := bt.DeleteUser(ctx, userId, tt.input)
+
+			if tt.expectedMsg != "" {
+				if err == nil {
+					t.Fatalf("Expected error containing %q, but got nil", tt.expectedMsg)
+				}
+
+				var msg string
+				if appErr, ok := err.(appErrors.ErrorResponse); ok {
+					msg = appErr.Message
+				} else {
+					msg = err.Error()
+				}
+
+				if !strings.Contains(msg, tt.expectedMsg) {
+					t.Errorf("Error message mismatch:\n Got:  %q\n Want: %q", msg, tt.expectedMsg)
+				}
+
+			} else {
+				if err != nil {
+					t.Errorf("Expected success, but got error: %v", err)
+				}
+			}
+		})
+	}
+}
+
+func TestChangePassword(t *testing.T) {
+	mockStore := &MockStorage{}
+	bt := &BudgetTracker{storage: mockStore}
+	ctx := context.Background()
+	userId := "john123"
+
+	tests := []struct {
+		name        string
+		input       auth.ChangePassword
+		expectedMsg string
+	}{
+		{
+			name:        "Fail - Empty current password",
+			input:       auth.ChangePassword{NewPassword: "violet-harbor-92"},
+			expectedMsg: "Current password cannot be empty!",
+		},
+		{
+			name:        "Fail - Same password",
+			input:       auth.ChangePassword{CurrentPassword: "1234", NewPassword: "1234"},
+			expectedMsg: "must be different",
+		},
+		{
+			name:        "Fail - Too short",
+			input:       auth.ChangePassword{CurrentPassword: "1234", NewPassword: "a1"},
+			expectedMsg: "Password so short",
+		},
+		{
+			name:        "Fail - No digit",
+			input:       auth.ChangePassword{CurrentPassword: "1234", NewPassword: "violetharbor"},
+			expectedMsg: "at least one digit",
+		},
+		{
+			name:        "Fail - Breached password",
+			input:       auth.ChangePassword{CurrentPassword: "1234", NewPassword: "password123"},
+			expectedMsg: "data breach",
+		},
+		{
+			name:        "Fail - Guessable password",
+			input:       auth.ChangePassword{CurrentPassword: "1234", NewPassword: "john2024"},
+			expectedMsg: "too easy to guess",
+		},
+		{
+			name:        "Fail - Wrong current password",
+			input:       auth.ChangePassword{CurrentPassword: "4321", NewPassword: "violet-harbor-92"},
+			expectedMsg: "Current password is incorrect",
+		},
+		{
+			name:        "Success - Strong password",
+			input:       auth.ChangePassword{CurrentPassword: "1234", NewPassword: "violet-harbor-92"},
+			expectedMsg: "",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := bt.ChangePassword(ctx, userId, tt.input)
 
 			if tt.expectedMsg != "" {
 				if err == nil {
@@ -938,3 +1767,3098 @@ func TestDeleteUser(t *testing.T) {
 		})
 	}
 }
+
+func TestRequestPasswordReset(t *testing.T) {
+	tests := []struct {
+		name        string
+		email       string
+		expectedMsg string
+		wantMail    bool
+	}{
+		{name: "Fail - Empty email", email: " ", expectedMsg: "Email cannot be empty!"},
+		{name: "Success - Unknown email sends nothing", email: "nobody@gmail.com"},
+		{name: "Success - Known email", email: "John@gmail.com", wantMail: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mailer := &MockMailer{}
+			bt := &BudgetTracker{storage: &MockStorage{}, mailer: mailer}
+
+			err := bt.RequestPasswordReset(context.Background(), tt.email)
+			if tt.expectedMsg != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.expectedMsg) {
+					t.Fatalf("Expected error containing %q, got: %v", tt.expectedMsg, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Expected success, but got error: %v", err)
+			}
+			if tt.wantMail && (len(mailer.Sent) != 1 || mailer.Sent[0] != "john@gmail.com") {
+				t.Errorf("Expected reset mail to john@gmail.com, got %v", mailer.Sent)
+			}
+			if !tt.wantMail && len(mailer.Sent) != 0 {
+				t.Errorf("Expected no reset mail, got %v", mailer.Sent)
+			}
+		})
+	}
+}
+
+func TestResetPassword(t *testing.T) {
+	mockStore := &MockStorage{}
+	bt := &BudgetTracker{storage: mockStore}
+	ctx := context.Background()
+
+	tests := []struct {
+		name        string
+		input       auth.ResetPassword
+		expectedMsg string
+	}{
+		{
+			name:        "Fail - Empty code",
+			input:       auth.ResetPassword{NewPassword: "violet-harbor-92"},
+			expectedMsg: "Reset code cannot be empty!",
+		},
+		{
+			name:        "Fail - Invalid code",
+			input:       auth.ResetPassword{Token: "guess", NewPassword: "violet-harbor-92"},
+			expectedMsg: "invalid or expired",
+		},
+		{
+			name:        "Fail - Too short",
+			input:       auth.ResetPassword{Token: "reset-1234", NewPassword: "a1"},
+			expectedMsg: "Password so short",
+		},
+		{
+			name:        "Fail - Breached password",
+			input:       auth.ResetPassword{Token: "reset-1234", NewPassword: "password123"},
+			expectedMsg: "data breach",
+		},
+		{
+			name:        "Fail - Guessable password",
+			input:       auth.ResetPassword{Token: "reset-1234", NewPassword: "john2024"},
+			expectedMsg: "too easy to guess",
+		},
+		{
+			name:        "Success - Strong password",
+			input:       auth.ResetPassword{Token: "reset-1234", NewPassword: "violet-harbor-92"},
+			expectedMsg: "",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := bt.ResetPassword(ctx, tt.input)
+
+			if tt.expectedMsg != "" {
+				if err == nil {
+					t.Fatalf("Expected error containing %q, but got nil", tt.expectedMsg)
+				}
+				if !strings.Contains(err.Error(), tt.expectedMsg) {
+					t.Errorf("Error message mismatch:\n Got:  %q\n Want: %q", err.Error(), tt.expectedMsg)
+				}
+			} else if err != nil {
+				t.Errorf("Expected success, but got error: %v", err)
+			}
+		})
+	}
+}
+
+func TestUpdateAccount(t *testing.T) {
+	mockStore := &MockStorage{}
+	ctx := context.Background()
+	userId := "john123"
+
+	tests := []struct {
+		name        string
+		input       auth.UpdateUser
+		wantMail    bool
+		expectedMsg string
+	}{
+		{
+			name:        "Fail - Nothing to update",
+			input:       auth.UpdateUser{},
+			expectedMsg: "Nothing to update!",
+		},
+		{
+			name:        "Fail - Invalid username",
+			input:       auth.UpdateUser{UserName: "John Doe"},
+			expectedMsg: "Username contains wrong characters",
+		},
+		{
+			name:        "Fail - Invalid email",
+			input:       auth.UpdateUser{Email: "john@"},
+			expectedMsg: "Invalid email format",
+		},
+		{
+			name:        "Fail - Username taken",
+			input:       auth.UpdateUser{UserName: "taken_name"},
+			expectedMsg: "Username already taken!",
+		},
+		{
+			name:        "Fail - Email taken",
+			input:       auth.UpdateUser{Email: "taken@gmail.com"},
+			expectedMsg: "Email already taken!",
+		},
+		{
+			name:        "Success - Same email does not send verification",
+			input:       auth.UpdateUser{FullName: "john doe", Email: "john@gmail.com"},
+			expectedMsg: "",
+		},
+		{
+			name:        "Success - New email sends verification",
+			input:       auth.UpdateUser{UserName: "john_doe", Email: "John.Doe@gmail.com"},
+			wantMail:    true,
+			expectedMsg: "",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mailer := &MockMailer{}
+			bt := &BudgetTracker{storage: mockStore, mailer: mailer}
+
+			_, err := bt.UpdateAccount(ctx, userId, tt.input)
+
+			if tt.expectedMsg != "" {
+				if err == nil {
+					t.Fatalf("Expected error containing %q, but got nil", tt.expectedMsg)
+				}
+
+				var msg string
+				if appErr, ok := err.(appErrors.ErrorResponse); ok {
+					msg = appErr.Message
+				} else {
+					msg = err.Error()
+				}
+
+				if !strings.Contains(msg, tt.expectedMsg) {
+					t.Errorf("Error message mismatch:\n Got:  %q\n Want: %q", msg, tt.expectedMsg)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Errorf("Expected success, but got error: %v", err)
+			}
+			if tt.wantMail && (len(mailer.Sent) != 1 || mailer.Sent[0] != "john.doe@gmail.com") {
+				t.Errorf("Expected verification mail to john.doe@gmail.com, got %v", mailer.Sent)
+			}
+			if !tt.wantMail && len(mailer.Sent) != 0 {
+				t.Errorf("Expected no verification mail, got %v", mailer.Sent)
+			}
+		})
+	}
+}
+
+func TestRestoreUser(t *testing.T) {
+	mockStore := &MockStorage{}
+	bt := &BudgetTracker{storage: mockStore}
+	ctx := context.Background()
+
+	_, err := bt.GenerateSession(ctx, auth.UserCredentialsPure{UserName: "pending_john", PasswordPlain: "1234"})
+	if appErr, ok := err.(appErrors.ErrorResponse); !ok || appErr.Code != appErrors.ErrPendingDeletion {
+		t.Fatalf("Expected %q error on login, got: %v", appErrors.ErrPendingDeletion, err)
+	}
+
+	_, err = bt.RestoreUser(ctx, auth.UserCredentialsPure{UserName: "john", PasswordPlain: "1234"})
+	if err == nil || !strings.Contains(err.Error(), "not scheduled for deletion") {
+		t.Errorf("Expected not scheduled error, got: %v", err)
+	}
+
+	_, err = bt.RestoreUser(ctx, auth.UserCredentialsPure{UserName: "expired_john", PasswordPlain: "1234"})
+	if err == nil || !strings.Contains(err.Error(), "grace period has ended") {
+		t.Errorf("Expected grace period ended error, got: %v", err)
+	}
+
+	token, err := bt.RestoreUser(ctx, auth.UserCredentialsPure{UserName: "pending_john", PasswordPlain: "1234"})
+	if err != nil {
+		t.Fatalf("Expected success, but got error: %v", err)
+	}
+	if token == "" {
+		t.Errorf("Expected session token after restore")
+	}
+}
+
+func TestAnonymizeReason(t *testing.T) {
+	tests := []struct {
+		name     string
+		reason   string
+		ids      []string
+		expected string
+	}{
+		{
+			name:     "Empty reason",
+			reason:   "   ",
+			expected: "-",
+		},
+		{
+			name:     "Email address",
+			reason:   "contact me at jane.doe@gmail.com",
+			expected: "contact me at [redacted]",
+		},
+		{
+			name:     "Own identifiers",
+			reason:   "John Doe here, john_doe does not need it",
+			ids:      []string{"john_doe", "John Doe"},
+			expected: "[redacted] [redacted] here, [redacted] does not need it",
+		},
+		{
+			name:     "Nothing to redact",
+			reason:   "The app is too complex!",
+			ids:      []string{"john_doe"},
+			expected: "The app is too complex!",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := AnonymizeReason(tt.reason, tt.ids...)
+			if got != tt.expected {
+				t.Errorf("Got %q, want %q", got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestPurgeDeletedUsers(t *testing.T) {
+	mockStore := &MockStorage{}
+	bt := &BudgetTracker{storage: mockStore}
+
+	purged, err := bt.PurgeDeletedUsers(context.Background())
+	if err != nil {
+		t.Fatalf("Expected success, but got error: %v", err)
+	}
+	if purged != 2 {
+		t.Errorf("Expected 2 purged accounts, got %d", purged)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	purged, err = bt.PurgeDeletedUsers(ctx)
+	if err != nil {
+		t.Fatalf("Expected success, but got error: %v", err)
+	}
+	if purged != 0 {
+		t.Errorf("Expected no purge after shutdown, got %d", purged)
+	}
+}
+
+func buildExportArchive(t *testing.T, files map[string]interface{}) *bytes.Reader {
+	t.Helper()
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	for name, payload := range files {
+		w, err := zw.Create(name)
+		if err != nil {
+			t.Fatalf("failed to create %s: %v", name, err)
+		}
+		if err := json.NewEncoder(w).Encode(payload); err != nil {
+			t.Fatalf("failed to write %s: %v", name, err)
+		}
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatalf("failed to close archive: %v", err)
+	}
+	return bytes.NewReader(buf.Bytes())
+}
+
+func TestReadUserDataArchive(t *testing.T) {
+	categories := []ExpenseCategoryResponse{{ID: "c-1", Name: "food"}}
+
+	tests := []struct {
+		name        string
+		files       map[string]interface{}
+		expectedErr error
+	}{
+		{
+			name: "current version",
+			files: map[string]interface{}{
+				EXPORT_MANIFEST_FILE:           ExportManifest{Format: EXPORT_FORMAT_NAME, Version: EXPORT_FORMAT_VERSION},
+				EXPORT_EXPENSE_CATEGORIES_FILE: categories[0], // a single NDJSON line
+			},
+			expectedErr: nil,
+		},
+		{
+			name: "version 1 with JSON arrays",
+			files: map[string]interface{}{
+				EXPORT_MANIFEST_FILE:      ExportManifest{Format: EXPORT_FORMAT_NAME, Version: 1},
+				"expense_categories.json": categories,
+			},
+			expectedErr: nil,
+		},
+		{
+			name: "export without manifest",
+			files: map[string]interface{}{
+				"expense_categories.json": categories,
+			},
+			expectedErr: nil,
+		},
+		{
+			name: "newer version",
+			files: map[string]interface{}{
+				EXPORT_MANIFEST_FILE:           ExportManifest{Format: EXPORT_FORMAT_NAME, Version: EXPORT_FORMAT_VERSION + 1},
+				EXPORT_EXPENSE_CATEGORIES_FILE: categories,
+			},
+			expectedErr: appErrors.ErrorResponse{
+				Code:    appErrors.ErrInvalidInput,
+				Message: "Unsupported export version",
+			},
+		},
+		{
+			name: "foreign archive",
+			files: map[string]interface{}{
+				EXPORT_MANIFEST_FILE: ExportManifest{Format: "something_else", Version: 1},
+			},
+			expectedErr: appErrors.ErrorResponse{
+				Code:    appErrors.ErrInvalidInput,
+				Message: "not a Budget Tracker export",
+			},
+		},
+		{
+			name:  "empty archive",
+			files: map[string]interface{}{},
+			expectedErr: appErrors.ErrorResponse{
+				Code:    appErrors.ErrInvalidInput,
+				Message: "does not contain any exported data",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			archive := buildExportArchive(t, tt.files)
+			data, err := ReadUserDataArchive(archive, archive.Size())
+
+			if tt.expectedErr != nil {
+				if err == nil {
+					t.Fatalf("Expected error, but got nil")
+				}
+				var appErr appErrors.ErrorResponse
+				if errors.As(err, &appErr) {
+					expectedAppErr := tt.expectedErr.(appErrors.ErrorResponse)
+					if appErr.Code != expectedAppErr.Code || !strings.Contains(appErr.Message, expectedAppErr.Message) {
+						t.Errorf("Expected error %v, got %v", expectedAppErr, appErr)
+					}
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("Expected success, but got error: %v", err)
+			}
+			if len(data.ExpenseCategories) != 1 {
+				t.Errorf("Expected 1 expense category, got %d", len(data.ExpenseCategories))
+			}
+		})
+	}
+}
+
+func TestExportUserData(t *testing.T) {
+	bt := &BudgetTracker{storage: &MockStorage{}}
+
+	var buf bytes.Buffer
+	if err := bt.ExportUserData(context.Background(), "john-1234", func() io.Writer { return &buf }); err != nil {
+		t.Fatalf("Expected success, but got error: %v", err)
+	}
+
+	archive := bytes.NewReader(buf.Bytes())
+	data, err := ReadUserDataArchive(archive, archive.Size())
+	if err != nil {
+		t.Fatalf("Expected the export to read back, got error: %v", err)
+	}
+	if len(data.Transactions) != 1 || len(data.ExpenseCategories) != 1 || len(data.IncomeCategories) != 1 {
+		t.Errorf("Expected 1 transaction and 1 category of each type, got %d, %d, %d",
+			len(data.Transactions), len(data.ExpenseCategories), len(data.IncomeCategories))
+	}
+
+	zr, _ := zip.NewReader(archive, archive.Size())
+	for _, f := range zr.File {
+		if f.Name != EXPORT_MANIFEST_FILE {
+			continue
+		}
+		rc, _ := f.Open()
+		var manifest ExportManifest
+		if err := json.NewDecoder(rc).Decode(&manifest); err != nil {
+			t.Fatalf("Failed to decode manifest: %v", err)
+		}
+		rc.Close()
+		if manifest.Version != EXPORT_FORMAT_VERSION || manifest.Files[EXPORT_TRANSACTIONS_FILE] != 1 || !slices.Contains(manifest.Excluded, "rules") {
+			t.Errorf("Unexpected manifest: %+v", manifest)
+		}
+	}
+}
+
+// streamingStorage yields n generated transactions without holding them.
+type streamingStorage struct {
+	MockStorage
+	n int
+}
+
+func (s *streamingStorage) EachTransaction(ctx context.Context, userId string, fn func(Transaction) error) error {
+	createdAt := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	for i := 0; i < s.n; i++ {
+		t := Transaction{
+			ID:           "tx-" + strconv.Itoa(i),
+			CategoryId:   "ts-1",
+			CategoryType: "-",
+			Amount:       float64(i%10000) / 100,
+			Currency:     "USD",
+			CreatedAt:    createdAt.Add(time.Duration(i) * time.Minute),
+			Note:         "lunch with the team",
+			CreatedBy:    userId,
+		}
+		if err := fn(t); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// BenchmarkExportUserData reports the peak heap while exporting, which stays
+// the same from 10k to 1M transactions:
+//
+//	go test ./internal/budget -run '^$' -bench ExportUserData -benchmem
+func BenchmarkExportUserData(b *testing.B) {
+	for _, n := range []int{10_000, 100_000, 1_000_000} {
+		b.Run(strconv.Itoa(n), func(b *testing.B) {
+			store := &streamingStorage{n: n}
+			bt := &BudgetTracker{storage: store}
+
+			for i := 0; i < b.N; i++ {
+				runtime.GC()
+				var stats runtime.MemStats
+				runtime.ReadMemStats(&stats)
+				base, peak := stats.HeapInuse, stats.HeapInuse
+
+				sample := &countingWriter{w: io.Discard, every: 1 << 20, sample: func() {
+					runtime.ReadMemStats(&stats)
+					if stats.HeapInuse > peak {
+						peak = stats.HeapInuse
+					}
+				}}
+				if err := bt.ExportUserData(context.Background(), "john-1234", func() io.Writer { return sample }); err != nil {
+					b.Fatal(err)
+				}
+
+				b.ReportMetric(float64(peak-base)/(1<<20), "peak-heap-MiB")
+				b.ReportMetric(float64(sample.n)/(1<<20), "zip-MiB")
+			}
+		})
+	}
+}
+
+// countingWriter calls sample every time another `every` bytes went through.
+type countingWriter struct {
+	w      io.Writer
+	n      int64
+	every  int64
+	next   int64
+	sample func()
+}
+
+func (c *countingWriter) Write(p []byte) (int, error) {
+	c.n += int64(len(p))
+	if c.n >= c.next {
+		c.sample()
+		c.next = c.n + c.every
+	}
+	return c.w.Write(p)
+}
+
+func TestImportUserData(t *testing.T) {
+	createdAt := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	data := UserDataResponse{
+		ExpenseCategories: []ExpenseCategoryResponse{
+			{ID: "old-e1", Name: "Home Repair", MaxAmount: 500, PeriodDay: 30},
+			{ID: "old-e2", Name: "food", MaxAmount: 300, PeriodDay: 7},
+		},
+		IncomeCategories: []IncomeCategoryResponse{
+			{ID: "old-i1", Name: "salary", TargetAmount: 2000},
+		},
+		Transactions: []Transaction{
+			{ID: "t1", CategoryId: "old-e1", CategoryName: "home repair", CategoryType: "-", Amount: 40, Currency: "USD", CreatedAt: createdAt},
+			{ID: "t2", CategoryId: "old-e2", CategoryName: "food", CategoryType: "-", Amount: 12.5, Currency: "USD", CreatedAt: createdAt},
+			{ID: "t3", CategoryId: "old-i1", CategoryName: "salary", CategoryType: "+", Amount: 1500, Currency: "USD", CreatedAt: createdAt},
+			{ID: "t4", CategoryId: "old-e2", CategoryName: "food", CategoryType: "-", Amount: 0, Currency: "USD", CreatedAt: createdAt},
+			{ID: "t5", CategoryId: "missing", CategoryName: "missing", CategoryType: "-", Amount: 5, Currency: "USD", CreatedAt: createdAt},
+			{ID: "t6", CategoryId: "old-e2", CategoryName: "food", CategoryType: "-", Amount: 30, Currency: "USD", CreatedAt: createdAt, Splits: []TransactionSplit{
+				{ID: "s1", CategoryId: "old-e2", CategoryName: "food", Amount: 20},
+				{ID: "s2", CategoryId: "old-e1", CategoryName: "home repair", Amount: 10, Note: "light bulbs"},
+			}},
+		},
+	}
+
+	tests := []struct {
+		name             string
+		opts             ImportOptions
+		expectedExpense  ImportCategoryCounts
+		expectedImported int
+		expectedSkipped  int
+		expectedErr      error
+	}{
+		{
+			name:             "merge into existing category",
+			opts:             ImportOptions{OnConflict: IMPORT_CONFLICT_MERGE},
+			expectedExpense:  ImportCategoryCounts{Created: 1, Merged: 1},
+			expectedImported: 4,
+			expectedSkipped:  2,
+		},
+		{
+			name:             "rename conflicting category",
+			opts:             ImportOptions{OnConflict: IMPORT_CONFLICT_RENAME},
+			expectedExpense:  ImportCategoryCounts{Created: 1, Renamed: 1},
+			expectedImported: 4,
+			expectedSkipped:  2,
+		},
+		{
+			name:             "skip conflicting category",
+			opts:             ImportOptions{OnConflict: IMPORT_CONFLICT_SKIP},
+			expectedExpense:  ImportCategoryCounts{Created: 1, Skipped: 1},
+			expectedImported: 2,
+			expectedSkipped:  4,
+		},
+		{
+			name:             "dry run",
+			opts:             ImportOptions{DryRun: true},
+			expectedExpense:  ImportCategoryCounts{Created: 1, Merged: 1},
+			expectedImported: 4,
+			expectedSkipped:  2,
+		},
+		{
+			name: "invalid conflict strategy",
+			opts: ImportOptions{OnConflict: "overwrite"},
+			expectedErr: appErrors.ErrorResponse{
+				Code:    appErrors.ErrInvalidInput,
+				Message: "Invalid conflict strategy",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockStore := &MockStorage{}
+			bt := &BudgetTracker{storage: mockStore}
+
+			report, err := bt.ImportUserData(context.Background(), "john-1234", data, tt.opts)
+
+			if tt.expectedErr != nil {
+				if err == nil {
+					t.Fatalf("Expected error, but got nil")
+				}
+				var appErr appErrors.ErrorResponse
+				if errors.As(err, &appErr) {
+					expectedAppErr := tt.expectedErr.(appErrors.ErrorResponse)
+					if appErr.Code != expectedAppErr.Code || !strings.Contains(appErr.Message, expectedAppErr.Message) {
+						t.Errorf("Expected error %v, got %v", expectedAppErr, appErr)
+					}
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("Expected success, but got error: %v", err)
+			}
+			if report.ExpenseCategories != tt.expectedExpense {
+				t.Errorf("Expected expense counts %+v, got %+v", tt.expectedExpense, report.ExpenseCategories)
+			}
+			if report.Transactions.Imported != tt.expectedImported {
+				t.Errorf("Expected %d imported transactions, got %d", tt.expectedImported, report.Transactions.Imported)
+			}
+			if report.Transactions.Skipped != tt.expectedSkipped {
+				t.Errorf("Expected %d skipped transactions, got %d", tt.expectedSkipped, report.Transactions.Skipped)
+			}
+			if tt.opts.DryRun && mockStore.ImportedBatch != nil {
+				t.Errorf("Dry run must not write to storage")
+			}
+			if !tt.opts.DryRun && len(mockStore.ImportedBatch.Transactions) != tt.expectedImported {
+				t.Errorf("Expected %d stored transactions, got %d", tt.expectedImported, len(mockStore.ImportedBatch.Transactions))
+			}
+			if tt.opts.OnConflict == IMPORT_CONFLICT_RENAME && report.Renamed["home repair"] != "home repair (imported)" {
+				t.Errorf("Expected rename to 'home repair (imported)', got %q", report.Renamed["home repair"])
+			}
+			if mockStore.ImportedBatch != nil {
+				for _, imported := range mockStore.ImportedBatch.Transactions {
+					// the archive predates occurred-at, the recorded time stands in for it
+					if !imported.OccurredAt.Equal(createdAt) {
+						t.Errorf("Expected occurred time %v, got %v", createdAt, imported.OccurredAt)
+					}
+					if len(imported.Splits) > 0 {
+						if len(imported.Splits) != 2 || imported.Splits[0].ID == "s1" || imported.Splits[1].CategoryId == "old-e1" || imported.CategoryId != imported.Splits[0].CategoryId {
+							t.Errorf("Expected splits with fresh IDs and remapped categories, got %+v", imported)
+						}
+					}
+				}
+			}
+		})
+	}
+}
+
+func TestImportUserDataRenamesLongNames(t *testing.T) {
+	long := strings.Repeat("a", 250)
+	existing := map[string]string{long: "e-1", strings.Repeat("a", 244) + " (imported)": "e-2"}
+
+	_, name, outcome, err := resolveImportedCategory(long, existing, IMPORT_CONFLICT_RENAME, MAX_CATEGORY_NAME_LENGTH)
+	if err != nil || outcome != IMPORT_CONFLICT_RENAME {
+		t.Fatalf("Expected a rename, got %q, %v", outcome, err)
+	}
+	if len(name) > MAX_CATEGORY_NAME_LENGTH || !strings.HasSuffix(name, " (imported 2)") {
+		t.Errorf("Expected a shortened name with the suffix, got %q (%d bytes)", name, len(name))
+	}
+
+	taken := map[string]string{long: "e-1"}
+	for n := 1; n <= MAX_IMPORT_RENAME_ATTEMPTS; n++ {
+		suffix := " (imported)"
+		if n > 1 {
+			suffix = fmt.Sprintf(" (imported %d)", n)
+		}
+		taken[truncateName(long, MAX_CATEGORY_NAME_LENGTH-len(suffix))+suffix] = "x"
+	}
+	if _, _, _, err := resolveImportedCategory(long, taken, IMPORT_CONFLICT_RENAME, MAX_CATEGORY_NAME_LENGTH); err == nil {
+		t.Errorf("Expected error when no free name is left")
+	}
+
+	mockStore := &MockStorage{
+		ExpenseCategories: []ExpenseCategoryResponse{{ID: "e-1", Name: long}},
+		Wallets:           map[string]Wallet{"w-1": {ID: "w-1", Name: long, Type: "cash", Currency: "EUR", CreatedBy: "john-1234"}},
+	}
+	bt := &BudgetTracker{storage: mockStore}
+	data := UserDataResponse{
+		ExpenseCategories: []ExpenseCategoryResponse{{ID: "old-e1", Name: long}},
+		Wallets:           []Wallet{{ID: "old-w1", Name: long, Type: "cash", Currency: "USD"}},
+	}
+	// a merge into a wallet of another currency falls back to a rename
+	report, err := bt.ImportUserData(context.Background(), "john-1234", data, ImportOptions{OnConflict: IMPORT_CONFLICT_MERGE})
+	if err != nil {
+		t.Fatalf("Expected success, but got error: %v", err)
+	}
+	if report.Wallets.Renamed != 1 || len(mockStore.ImportedBatch.Wallets[0].Name) > MAX_WALLET_NAME_LENGTH {
+		t.Errorf("Expected the wallet to be renamed within the limit, got %+v", report.Wallets)
+	}
+
+	report, err = bt.ImportUserData(context.Background(), "john-1234", data, ImportOptions{OnConflict: IMPORT_CONFLICT_RENAME})
+	if err != nil {
+		t.Fatalf("Expected success, but got error: %v", err)
+	}
+	if report.ExpenseCategories.Renamed != 1 || len(mockStore.ImportedBatch.ExpenseCategories[0].Name) > MAX_CATEGORY_NAME_LENGTH {
+		t.Errorf("Expected the category to be renamed within the limit, got %+v", report.ExpenseCategories)
+	}
+}
+
+func TestParseCSVStatement(t *testing.T) {
+	tests := []struct {
+		name            string
+		input           string
+		mapping         CSVMapping
+		expectedAmounts []float64
+		expectedErrors  int
+		expectedErr     error
+	}{
+		{
+			name:  "header with signed amounts",
+			input: "Date,Amount,Currency,Description\n2026-03-01,-12.50,usd,Coffee\n2026-03-02,1500.00,USD,Salary\n",
+			mapping: CSVMapping{
+				HasHeader: true, DateColumn: "date", AmountColumn: "Amount", CurrencyColumn: "Currency", DescriptionColumn: "Description",
+			},
+			expectedAmounts: []float64{-12.5, 1500},
+		},
+		{
+			name:  "european format with preamble",
+			input: "Account;DE123\n\nBuchungstag;Betrag;Verwendungszweck\n01.03.2026;-1.234,56;Miete\n02.03.2026;\"2.000,00\";Gehalt\n",
+			mapping: CSVMapping{
+				HasHeader: true, SkipRows: 1, Delimiter: ';', DateColumn: "Buchungstag", AmountColumn: "Betrag", DescriptionColumn: "Verwendungszweck",
+				DateFormat: "DD.MM.YYYY", DecimalSeparator: ",", Currency: "eur",
+			},
+			expectedAmounts: []float64{-1234.56, 2000},
+		},
+		{
+			name:  "credit card positive expenses without header",
+			input: "03/01/2026,45.00,Groceries\n03/05/2026,(20.00),Refund\n",
+			mapping: CSVMapping{
+				DateColumn: "0", AmountColumn: "1", DescriptionColumn: "2", DateFormat: "MM/DD/YYYY",
+				SignConvention: CSV_SIGN_POSITIVE_EXPENSE, Currency: "USD",
+			},
+			expectedAmounts: []float64{-45, 20},
+		},
+		{
+			name:  "debit and credit columns with bad rows",
+			input: "date,debit,credit,note\n2026-03-01,10.00,,Lunch\n2026-03-02,,250.00,Refund\nyesterday,5.00,,Bad date\n2026-03-04,,,Empty\n",
+			mapping: CSVMapping{
+				HasHeader: true, DateColumn: "date", DebitColumn: "debit", CreditColumn: "credit", DescriptionColumn: "note",
+				SignConvention: CSV_SIGN_DEBIT_CREDIT, Currency: "USD",
+			},
+			expectedAmounts: []float64{-10, 250},
+			expectedErrors:  2,
+		},
+		{
+			name:    "missing column",
+			input:   "Date,Amount\n2026-03-01,1\n",
+			mapping: CSVMapping{HasHeader: true, DateColumn: "Date", AmountColumn: "Value", Currency: "USD"},
+			expectedErr: appErrors.ErrorResponse{
+				Code:    appErrors.ErrInvalidInput,
+				Message: "not found in the header",
+			},
+		},
+		{
+			name:    "no currency",
+			input:   "2026-03-01,1\n",
+			mapping: CSVMapping{DateColumn: "0", AmountColumn: "1"},
+			expectedErr: appErrors.ErrorResponse{
+				Code:    appErrors.ErrInvalidInput,
+				Message: "default currency is required",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rows, rowErrors, err := ParseCSVStatement(strings.NewReader(tt.input), tt.mapping)
+
+			if tt.expectedErr != nil {
+				if err == nil {
+					t.Fatalf("Expected error, but got nil")
+				}
+				var appErr appErrors.ErrorResponse
+				if errors.As(err, &appErr) {
+					expectedAppErr := tt.expectedErr.(appErrors.ErrorResponse)
+					if appErr.Code != expectedAppErr.Code || !strings.Contains(appErr.Message, expectedAppErr.Message) {
+						t.Errorf("Expected error %v, got %v", expectedAppErr, appErr)
+					}
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("Expected success, but got error: %v", err)
+			}
+			if len(rowErrors) != tt.expectedErrors {
+				t.Errorf("Expected %d row errors, got %d: %v", tt.expectedErrors, len(rowErrors), rowErrors)
+			}
+			if len(rows) != len(tt.expectedAmounts) {
+				t.Fatalf("Expected %d rows, got %d", len(tt.expectedAmounts), len(rows))
+			}
+			for i, row := range rows {
+				if math.Abs(row.Amount-tt.expectedAmounts[i]) > 1e-9 {
+					t.Errorf("Row %d: expected amount %v, got %v", i, tt.expectedAmounts[i], row.Amount)
+				}
+				if row.Currency == "" || row.Date.IsZero() {
+					t.Errorf("Row %d: currency and date must be set, got %+v", i, row)
+				}
+			}
+		})
+	}
+}
+
+func TestImportStatement(t *testing.T) {
+	date := time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC)
+	rows := []StatementRow{
+		{Index: 2, Date: date, Amount: -12.5, Currency: "usd", Description: "Coffee"},
+		{Index: 3, Date: date, Amount: 1500, Currency: "USD", Description: "Salary"},
+		{Index: 4, Date: date, Amount: -80, Currency: "USD", Description: "Plumber"},
+	}
+
+	tests := []struct {
+		name             string
+		mapping          StatementCategoryMapping
+		dryRun           bool
+		expectedImported int
+		expectedSkipped  int
+		expectedErr      error
+	}{
+		{
+			name:             "all rows mapped",
+			mapping:          StatementCategoryMapping{ExpenseCategoryId: "ts-1", IncomeCategoryId: "ts-1"},
+			expectedImported: 3,
+		},
+		{
+			name:             "no income category",
+			mapping:          StatementCategoryMapping{ExpenseCategoryId: "ts-1"},
+			expectedImported: 2,
+			expectedSkipped:  1,
+		},
+		{
+			name:             "unknown row override",
+			mapping:          StatementCategoryMapping{ExpenseCategoryId: "ts-1", IncomeCategoryId: "ts-1", Rows: map[int]string{4: "nope"}},
+			dryRun:           true,
+			expectedImported: 2,
+			expectedSkipped:  1,
+		},
+		{
+			name:    "unknown default category",
+			mapping: StatementCategoryMapping{ExpenseCategoryId: "nope"},
+			expectedErr: appErrors.ErrorResponse{
+				Code:    appErrors.ErrInvalidInput,
+				Message: "Expense category does not exist",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockStore := &MockStorage{}
+			bt := &BudgetTracker{storage: mockStore}
+
+			report, err := bt.ImportStatement(context.Background(), "john-1234", "bank.csv", rows, nil, tt.mapping, tt.dryRun)
+
+			if tt.expectedErr != nil {
+				if err == nil {
+					t.Fatalf("Expected error, but got nil")
+				}
+				var appErr appErrors.ErrorResponse
+				if errors.As(err, &appErr) {
+					expectedAppErr := tt.expectedErr.(appErrors.ErrorResponse)
+					if appErr.Code != expectedAppErr.Code || !strings.Contains(appErr.Message, expectedAppErr.Message) {
+						t.Errorf("Expected error %v, got %v", expectedAppErr, appErr)
+					}
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("Expected success, but got error: %v", err)
+			}
+			if report.Imported != tt.expectedImported || report.Skipped != tt.expectedSkipped {
+				t.Errorf("Expected %d imported and %d skipped, got %d and %d", tt.expectedImported, tt.expectedSkipped, report.Imported, report.Skipped)
+			}
+			if tt.dryRun != (mockStore.ImportedBatch == nil) {
+				t.Errorf("Expected storage write only when not a dry run")
+			}
+			for _, row := range report.Rows {
+				if row.Amount < 0 && row.CategoryType != "-" {
+					t.Errorf("Outgoing row %d must be an expense, got %q", row.Index, row.CategoryType)
+				}
+			}
+		})
+	}
+}
+
+const ofxSGMLStatement = `OFXHEADER:100
+DATA:OFXSGML
+VERSION:102
+SECURITY:NONE
+ENCODING:USASCII
+
+<OFX>
+<SIGNONMSGSRSV1><SONRS><STATUS><CODE>0<SEVERITY>INFO</STATUS><DTSERVER>20260305120000</SONRS></SIGNONMSGSRSV1>
+<BANKMSGSRSV1><STMTTRNRS><TRNUID>1<STMTRS>
+<CURDEF>USD
+<BANKACCTFROM><BANKID>0001<ACCTID>12345<ACCTTYPE>CHECKING</BANKACCTFROM>
+<BANKTRANLIST><DTSTART>20260301<DTEND>20260305
+<STMTTRN><TRNTYPE>DEBIT<DTPOSTED>20260301120000.000[-5:EST]<TRNAMT>-42.10<FITID>2026030101<NAME>AMZN Mktp US*2K4<MEMO>Books</STMTTRN>
+<STMTTRN><TRNTYPE>CREDIT<DTPOSTED>20260302<TRNAMT>1500.00<FITID>2026030201<NAME>ACME PAYROLL</STMTTRN>
+<STMTTRN><TRNTYPE>DEBIT<DTPOSTED>20260303<TRNAMT>-9.99<FITID>already-imported<NAME>Streaming &amp; Co</STMTTRN>
+<STMTTRN><TRNTYPE>DEBIT<DTPOSTED>yesterday<TRNAMT>-1.00<FITID>bad</STMTTRN>
+</BANKTRANLIST></STMTRS></STMTTRNRS></BANKMSGSRSV1>
+</OFX>
+`
+
+const ofxXMLStatement = `<?xml version="1.0" encoding="UTF-8"?>
+<?OFX OFXHEADER="200" VERSION="220" SECURITY="NONE"?>
+<OFX>
+  <CREDITCARDMSGSRSV1>
+    <CCSTMTTRNRS>
+      <CCSTMTRS>
+        <CURDEF>EUR</CURDEF>
+        <CCACCTFROM><ACCTID>9999</ACCTID></CCACCTFROM>
+        <BANKTRANLIST>
+          <STMTTRN>
+            <TRNTYPE>DEBIT</TRNTYPE>
+            <DTPOSTED>20260310</DTPOSTED>
+            <TRNAMT>-15.00</TRNAMT>
+            <FITID>A1</FITID>
+            <NAME>Cafe</NAME>
+            <CURRENCY><CURRATE>1.1</CURRATE><CURSYM>USD</CURSYM></CURRENCY>
+          </STMTTRN>
+        </BANKTRANLIST>
+      </CCSTMTRS>
+    </CCSTMTTRNRS>
+  </CREDITCARDMSGSRSV1>
+</OFX>
+`
+
+func TestParseOFXStatement(t *testing.T) {
+	rows, rowErrors, err := ParseOFXStatement(strings.NewReader(ofxSGMLStatement))
+	if err != nil {
+		t.Fatalf("Expected success, but got error: %v", err)
+	}
+	if len(rows) != 3 || len(rowErrors) != 1 {
+		t.Fatalf("Expected 3 rows and 1 error, got %d and %d", len(rows), len(rowErrors))
+	}
+	if rows[0].Amount != -42.10 || rows[0].Currency != "USD" || rows[0].Description != "AMZN Mktp US*2K4 Books" {
+		t.Errorf("Unexpected first row: %+v", rows[0])
+	}
+	if rows[0].ExternalID != "ofx:12345:2026030101" {
+		t.Errorf("Expected external ID from account and FITID, got %q", rows[0].ExternalID)
+	}
+	if rows[2].Description != "Streaming & Co" {
+		t.Errorf("Expected entities to be decoded, got %q", rows[2].Description)
+	}
+
+	rows, _, err = ParseOFXStatement(strings.NewReader(ofxXMLStatement))
+	if err != nil {
+		t.Fatalf("Expected success, but got error: %v", err)
+	}
+	if len(rows) != 1 || rows[0].Currency != "USD" || rows[0].ExternalID != "ofx:9999:A1" {
+		t.Errorf("Unexpected XML rows: %+v", rows)
+	}
+
+	if _, _, err := ParseOFXStatement(strings.NewReader("Date,Amount\n")); err == nil {
+		t.Errorf("Expected error for a non OFX file")
+	}
+}
+
+func TestParseQIFStatement(t *testing.T) {
+	input := "!Type:Bank\nD03/04'26\nT-1,234.56\nPLandlord\nMMarch rent\n^\nD3/5/2026\nT2000.00\nPEmployer\n^\nD13/45/2026\nT1.00\n^\n!Type:Memorized\nKC\nT5.00\n^\n"
+
+	rows, rowErrors, err := ParseQIFStatement(strings.NewReader(input), "usd", "", "")
+	if err != nil {
+		t.Fatalf("Expected success, but got error: %v", err)
+	}
+	if len(rows) != 2 || len(rowErrors) != 1 {
+		t.Fatalf("Expected 2 rows and 1 error, got %d and %d", len(rows), len(rowErrors))
+	}
+	if !rows[0].Date.Equal(time.Date(2026, 3, 4, 0, 0, 0, 0, time.UTC)) || rows[0].Amount != -1234.56 {
+		t.Errorf("Unexpected first row: %+v", rows[0])
+	}
+	if rows[0].Description != "Landlord March rent" || rows[0].Currency != "USD" {
+		t.Errorf("Unexpected first row: %+v", rows[0])
+	}
+
+	rows, _, err = ParseQIFStatement(strings.NewReader("!Type:CCard\nD04.03.2026\nT-10,00\n^\n"), "EUR", QIF_DATE_ORDER_DMY, ",")
+	if err != nil || len(rows) != 1 || rows[0].Date.Month() != time.March || rows[0].Amount != -10 {
+		t.Errorf("Unexpected day-first rows: %+v, err: %v", rows, err)
+	}
+
+	if _, _, err := ParseQIFStatement(strings.NewReader(input), "", "", ""); err == nil {
+		t.Errorf("Expected error without currency")
+	}
+}
+
+func TestImportStatementDeduplicatesByExternalID(t *testing.T) {
+	rows, rowErrors, err := ParseOFXStatement(strings.NewReader(ofxSGMLStatement))
+	if err != nil {
+		t.Fatalf("Expected success, but got error: %v", err)
+	}
+	// the same statement twice in one upload must not double count either
+	rows = append(rows, rows...)
+
+	mockStore := &MockStorage{}
+	bt := &BudgetTracker{storage: mockStore}
+	mapping := StatementCategoryMapping{ExpenseCategoryId: "ts-1", IncomeCategoryId: "ts-1"}
+
+	report, err := bt.ImportStatement(context.Background(), "john-1234", "bank.ofx", rows, rowErrors, mapping, false)
+	if err != nil {
+		t.Fatalf("Expected success, but got error: %v", err)
+	}
+	if report.Imported != 2 || report.Duplicates != 4 || report.Skipped != 1 {
+		t.Errorf("Expected 2 imported, 4 duplicates and 1 skipped, got %d, %d and %d", report.Imported, report.Duplicates, report.Skipped)
+	}
+	for _, tr := range mockStore.ImportedBatch.Transactions {
+		if tr.ExternalID == "" {
+			t.Errorf("Expected external ID to be stored, got %+v", tr)
+		}
+	}
+}
+
+func TestParseCamt053Statement(t *testing.T) {
+	file, err := os.Open("testdata/camt053_multi_statement.xml")
+	if err != nil {
+		t.Fatalf("failed to open fixture: %v", err)
+	}
+	defer file.Close()
+
+	rows, rowErrors, err := ParseCamt053Statement(file)
+	if err != nil {
+		t.Fatalf("Expected success, but got error: %v", err)
+	}
+
+	expected := []StatementRow{
+		{Date: time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC), Amount: -1250, Currency: "EUR", Description: "Hausverwaltung GmbH Miete Maerz Whg 4", ExternalID: "camt:DE89370400440532013000:EUR-0001"},
+		{Date: time.Date(2026, 3, 27, 0, 0, 0, 0, time.UTC), Amount: 3200, Currency: "EUR", Description: "ACME AG Gehalt 03/2026", ExternalID: "camt:DE89370400440532013000:EUR-0002"},
+		{Date: time.Date(2026, 3, 28, 0, 0, 0, 0, time.UTC), Amount: -50, Currency: "EUR", Description: "Stadtwerke Strom", ExternalID: "camt:DE89370400440532013000:EUR-0003:1"},
+		{Date: time.Date(2026, 3, 28, 0, 0, 0, 0, time.UTC), Amount: -25.5, Currency: "EUR", Description: "Telekom Internet", ExternalID: "camt:DE89370400440532013000:EUR-0003:2"},
+		{Date: time.Date(2026, 3, 15, 15, 30, 0, 0, time.UTC), Amount: -40, Currency: "USD", Description: "REVERSAL OF REFUND", ExternalID: "camt:US-4711:USD-0001"},
+		{Date: time.Date(2026, 3, 16, 0, 0, 0, 0, time.UTC), Amount: -12, Currency: "GBP", Description: "London Cafe", ExternalID: "camt:US-4711:USD-0002"},
+	}
+
+	if len(rowErrors) != 1 {
+		t.Errorf("Expected 1 row error for the invalid amount, got %v", rowErrors)
+	}
+	if len(rows) != len(expected) {
+		t.Fatalf("Expected %d rows, got %d: %+v", len(expected), len(rows), rows)
+	}
+	for i, want := range expected {
+		got := rows[i]
+		got.Index = 0
+		if !got.Date.Equal(want.Date) || got.Amount != want.Amount || got.Currency != want.Currency ||
+			got.Description != want.Description || got.ExternalID != want.ExternalID {
+			t.Errorf("Row %d: expected %+v, got %+v", i, want, got)
+		}
+	}
+
+	if _, _, err := ParseCamt053Statement(strings.NewReader(ofxXMLStatement)); err == nil {
+		t.Errorf("Expected error for a non camt.053 file")
+	}
+}
+
+func TestParseMT940Statement(t *testing.T) {
+	file, err := os.Open("testdata/mt940_multi_statement.sta")
+	if err != nil {
+		t.Fatalf("failed to open fixture: %v", err)
+	}
+	defer file.Close()
+
+	rows, rowErrors, err := ParseMT940Statement(file)
+	if err != nil {
+		t.Fatalf("Expected success, but got error: %v", err)
+	}
+
+	expected := []StatementRow{
+		{Date: time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC), Amount: -1250, Currency: "EUR", Description: "Hausverwaltung GmbH Miete Maerz Whg 4", ExternalID: "mt940:37040044/0532013000:B6C01"},
+		{Date: time.Date(2026, 3, 27, 0, 0, 0, 0, time.UTC), Amount: 3200, Currency: "EUR", Description: "ACME AG Gehalt 03/2026", ExternalID: "mt940:37040044/0532013000:B6C02"},
+		{Date: time.Date(2026, 3, 28, 0, 0, 0, 0, time.UTC), Amount: -40, Currency: "EUR", Description: "REVERSAL OF REFUND", ExternalID: "mt940:37040044/0532013000:B6C03"},
+		{Date: time.Date(2025, 12, 31, 0, 0, 0, 0, time.UTC), Amount: -12.5, Currency: "USD", Description: "CARD FEE", ExternalID: "mt940:US-4711:U0001"},
+		{Date: time.Date(2026, 1, 3, 0, 0, 0, 0, time.UTC), Amount: 7, Currency: "USD", Description: "Refund from shop, order 42 paid with card"},
+	}
+
+	if len(rowErrors) != 1 {
+		t.Errorf("Expected 1 row error for the invalid line, got %v", rowErrors)
+	}
+	if len(rows) != len(expected) {
+		t.Fatalf("Expected %d rows, got %d: %+v", len(expected), len(rows), rows)
+	}
+	for i, want := range expected {
+		got := rows[i]
+		got.Index = 0
+		if !got.Date.Equal(want.Date) || got.Amount != want.Amount || got.Currency != want.Currency ||
+			got.Description != want.Description || got.ExternalID != want.ExternalID {
+			t.Errorf("Row %d: expected %+v, got %+v", i, want, got)
+		}
+	}
+
+	if _, _, err := ParseMT940Statement(strings.NewReader("Date,Amount\n")); err == nil {
+		t.Errorf("Expected error for a non MT940 file")
+	}
+}
+
+var (
+	beancountAccount  = `(?:Assets|Liabilities|Equity|Income|Expenses)(?::[\p{Lu}\p{Nd}][\p{L}\p{Nd}-]*)+`
+	beancountCurrency = `[A-Z][A-Z0-9'._-]{0,22}[A-Z0-9]`
+	beancountQuoted   = `"(?:[^"\\]|\\.)*"`
+
+	beancountOptionLine  = regexp.MustCompile(`^option ` + beancountQuoted + ` ` + beancountQuoted + `$`)
+	beancountOpenLine    = regexp.MustCompile(`^(\d{4}-\d{2}-\d{2}) open (` + beancountAccount + `)$`)
+	beancountTxnLine     = regexp.MustCompile(`^(\d{4}-\d{2}-\d{2}) [*!] ` + beancountQuoted + `$`)
+	beancountMetaLine    = regexp.MustCompile(`^  [a-z][a-zA-Z0-9_-]+: ` + beancountQuoted + `$`)
+	beancountPostingLine = regexp.MustCompile(`^  (` + beancountAccount + `)  (-?\d+\.\d+) (` + beancountCurrency + `)$`)
+
+	ledgerAccountLine = regexp.MustCompile(`^account (` + beancountAccount + `)$`)
+	ledgerTxnLine     = regexp.MustCompile(`^(\d{4}/\d{2}/\d{2}) [^*!\s].*$`)
+	ledgerNoteLine    = regexp.MustCompile(`^    ; .*$`)
+	ledgerPostingLine = regexp.MustCompile(`^    (` + beancountAccount + `)  (-?\d+\.\d+) ([A-Z]+|"[^"]+")$`)
+)
+
+// checkJournal is a small bean-check: every line must be valid syntax,
+// accounts must be opened before they are used and every transaction must
+// balance per currency.
+func checkJournal(t *testing.T, journal string, format string) int {
+	t.Helper()
+
+	opened := map[string]string{}
+	transactions := 0
+	var txnDate string
+	sums := map[string]float64{}
+
+	closeTxn := func(line int) {
+		for currency, sum := range sums {
+			if math.Abs(sum) > 1e-9 {
+				t.Errorf("line %d: transaction does not balance in %s by %v", line, currency, sum)
+			}
+		}
+		sums = map[string]float64{}
+	}
+
+	for i, line := range strings.Split(journal, "\n") {
+		n := i + 1
+		switch {
+		case line == "":
+			closeTxn(n)
+		case strings.HasPrefix(line, ";"):
+		case format == JOURNAL_FORMAT_BEANCOUNT && beancountOptionLine.MatchString(line):
+		case format == JOURNAL_FORMAT_BEANCOUNT && beancountOpenLine.MatchString(line):
+			m := beancountOpenLine.FindStringSubmatch(line)
+			if _, dup := opened[m[2]]; dup {
+				t.Errorf("line %d: account %s opened twice", n, m[2])
+			}
+			opened[m[2]] = m[1]
+		case format == JOURNAL_FORMAT_LEDGER && ledgerAccountLine.MatchString(line):
+			opened[ledgerAccountLine.FindStringSubmatch(line)[1]] = "0000-00-00"
+		case format == JOURNAL_FORMAT_BEANCOUNT && beancountTxnLine.MatchString(line):
+			txnDate = beancountTxnLine.FindStringSubmatch(line)[1]
+			transactions++
+		case format == JOURNAL_FORMAT_LEDGER && ledgerTxnLine.MatchString(line):
+			txnDate = strings.ReplaceAll(ledgerTxnLine.FindStringSubmatch(line)[1], "/", "-")
+			transactions++
+		case format == JOURNAL_FORMAT_BEANCOUNT && beancountMetaLine.MatchString(line):
+		case format == JOURNAL_FORMAT_LEDGER && ledgerNoteLine.MatchString(line):
+		case format == JOURNAL_FORMAT_BEANCOUNT && beancountPostingLine.MatchString(line),
+			format == JOURNAL_FORMAT_LEDGER && ledgerPostingLine.MatchString(line):
+			m := beancountPostingLine.FindStringSubmatch(line)
+			if format == JOURNAL_FORMAT_LEDGER {
+				m = ledgerPostingLine.FindStringSubmatch(line)
+			}
+			openedAt, ok := opened[m[1]]
+			if !ok || openedAt > txnDate {
+				t.Errorf("line %d: account %s used before it is opened", n, m[1])
+			}
+			amount, _ := strconv.ParseFloat(m[2], 64)
+			sums[m[3]] += amount
+		default:
+			t.Errorf("line %d: invalid syntax %q", n, line)
+		}
+	}
+	closeTxn(0)
+	return transactions
+}
+
+func TestWriteJournal(t *testing.T) {
+	day := func(d int) time.Time { return time.Date(2026, 3, d, 18, 30, 0, 0, time.UTC) }
+	data := UserDataResponse{
+		ExpenseCategories: []ExpenseCategoryResponse{
+			{ID: "e1", Name: "food", CreatedAt: day(1)},
+			{ID: "e2", Name: "home repair", CreatedAt: day(2)},
+			{ID: "e3", Name: "home-repair", CreatedAt: day(2)},
+			{ID: "e4", Name: "çay evi", CreatedAt: day(2)},
+		},
+		IncomeCategories: []IncomeCategoryResponse{
+			{ID: "i1", Name: "salary", CreatedAt: day(5)},
+		},
+		Transactions: []Transaction{
+			{ID: "t1", CategoryId: "e1", CategoryType: "-", Amount: 12.5, Currency: "usd", OccurredAt: day(3), Note: `Lunch with "Bob" \ team`},
+			{ID: "t2", CategoryId: "i1", CategoryType: "+", Amount: 1500, Currency: "USD", OccurredAt: day(4), Note: "March salary"},
+			{ID: "t3", CategoryId: "e3", CategoryType: "-", Amount: 30, Currency: "€", OccurredAt: day(6), Note: "* new tap"},
+			{ID: "t4", CategoryId: "gone", CategoryName: "old stuff", CategoryType: "-", Amount: 5, Currency: "$", OccurredAt: day(7)},
+			{ID: "t5", CategoryId: "e4", CategoryType: "-", Amount: 3, Currency: "X1", OccurredAt: day(7)},
+			{ID: "t6", CategoryId: "e1", CategoryType: "-", Amount: 40.1, Currency: "USD", OccurredAt: day(8), Note: "supermarket", Splits: []TransactionSplit{
+				{CategoryId: "e1", Amount: 25.05}, {CategoryId: "e2", Amount: 15.05},
+			}},
+			{ID: "t7", CategoryType: TRANSFER_TYPE, Amount: 200, Currency: "USD", OccurredAt: day(9), Note: "card payment", WalletId: "w2", ToWalletId: "w1"},
+			{ID: "t8", CategoryId: "e1", CategoryType: "-", Amount: 7.25, Currency: "USD", OccurredAt: day(9), WalletId: "w1"},
+		},
+		Wallets: []Wallet{
+			{ID: "w1", Name: "Visa", Type: WALLET_CREDIT_CARD, CreatedAt: day(1)},
+			{ID: "w2", Name: "Savings", Type: WALLET_SAVINGS, CreatedAt: day(1)},
+		},
+	}
+
+	for _, format := range []string{JOURNAL_FORMAT_BEANCOUNT, JOURNAL_FORMAT_LEDGER} {
+		t.Run(format, func(t *testing.T) {
+			var buf bytes.Buffer
+			if err := WriteJournal(&buf, format, "Assets:Checking", data); err != nil {
+				t.Fatalf("Expected success, but got error: %v", err)
+			}
+			journal := buf.String()
+
+			if got := checkJournal(t, journal, format); got != len(data.Transactions) {
+				t.Errorf("Expected %d transactions, got %d", len(data.Transactions), got)
+			}
+			for _, want := range []string{"Expenses:Food", "Expenses:Home-Repair", "Expenses:Home-Repair-2", "Expenses:Çay-Evi", "Expenses:Old-Stuff", "Income:Salary", "-1500.00 USD", "30.00 EUR", "Expenses:Home-Repair  15.05 USD", "-40.10 USD", "Liabilities:Visa  200.00 USD", "Assets:Savings  -200.00 USD", "Liabilities:Visa  -7.25 USD"} {
+				if !strings.Contains(journal, want) {
+					t.Errorf("Expected journal to contain %q:\n%s", want, journal)
+				}
+			}
+		})
+	}
+
+	var buf bytes.Buffer
+	if err := WriteJournal(&buf, JOURNAL_FORMAT_BEANCOUNT, "Assets:Checking", data); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(buf.String(), `"Lunch with \"Bob\" \\ team"`) {
+		t.Errorf("Expected narration to be escaped:\n%s", buf.String())
+	}
+	if !strings.Contains(buf.String(), `option "operating_currency" "USD"`) {
+		t.Errorf("Expected USD as operating currency:\n%s", buf.String())
+	}
+}
+
+func TestExportJournal(t *testing.T) {
+	bt := &BudgetTracker{storage: &MockStorage{}}
+
+	var buf bytes.Buffer
+	if err := bt.ExportJournal(context.Background(), "john-1234", JournalOptions{Format: "qif"}, &buf); err == nil {
+		t.Errorf("Expected error for unsupported format")
+	}
+	if err := bt.ExportJournal(context.Background(), "john-1234", JournalOptions{Format: JOURNAL_FORMAT_LEDGER, AssetsAccount: "Expenses:Cash"}, &buf); err == nil {
+		t.Errorf("Expected error for an assets account outside Assets, Liabilities or Equity")
+	}
+	if err := bt.ExportJournal(context.Background(), "john-1234", JournalOptions{Format: JOURNAL_FORMAT_BEANCOUNT}, &buf); err != nil {
+		t.Errorf("Expected success, but got error: %v", err)
+	}
+	checkJournal(t, buf.String(), JOURNAL_FORMAT_BEANCOUNT)
+}
+
+func TestFormatLocaleAmount(t *testing.T) {
+	tests := []struct {
+		amount   float64
+		locale   string
+		expected string
+	}{
+		{1234567.891, "iso", "1234567.89"},
+		{1234567.891, "en-US", "1,234,567.89"},
+		{-1234.5, "de-DE", "-1.234,50"},
+		{-999.99, "de-DE", "-999,99"},
+		{1000, "fr-FR", "1 000,00"},
+		{-0.001, "iso", "0.00"},
+	}
+
+	for _, tt := range tests {
+		got := formatLocaleAmount(tt.amount, exportLocales[tt.locale])
+		if got != tt.expected {
+			t.Errorf("formatLocaleAmount(%v, %s) = %q, expected %q", tt.amount, tt.locale, got, tt.expected)
+		}
+	}
+}
+
+func TestExportTransactions(t *testing.T) {
+	bt := &BudgetTracker{storage: &MockStorage{}}
+	all := &TransactionList{IsAllNil: true}
+
+	tests := []struct {
+		name        string
+		opts        TransactionExportOptions
+		expectedErr string
+	}{
+		{name: "Unsupported format", opts: TransactionExportOptions{Format: "pdf"}, expectedErr: "Invalid export format"},
+		{name: "Unsupported locale", opts: TransactionExportOptions{Format: TRANSACTION_EXPORT_CSV, Locale: "xx-XX"}, expectedErr: "Unsupported locale"},
+		{name: "Quote as delimiter", opts: TransactionExportOptions{Format: TRANSACTION_EXPORT_CSV, Delimiter: '"'}, expectedErr: "Invalid delimiter"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			opened := false
+			err := bt.ExportTransactions(context.Background(), "john-1234", all, tt.opts, func() io.Writer {
+				opened = true
+				return io.Discard
+			})
+			var appErr appErrors.ErrorResponse
+			if !errors.As(err, &appErr) || !strings.Contains(appErr.Message, tt.expectedErr) {
+				t.Errorf("Expected error containing %q, got: %v", tt.expectedErr, err)
+			}
+			if opened {
+				t.Errorf("Output must not be opened when the options are invalid")
+			}
+		})
+	}
+
+	t.Run("CSV with locale", func(t *testing.T) {
+		var buf bytes.Buffer
+		opts := TransactionExportOptions{Format: TRANSACTION_EXPORT_CSV, Locale: "de-DE"}
+		if err := bt.ExportTransactions(context.Background(), "john-1234", all, opts, func() io.Writer { return &buf }); err != nil {
+			t.Fatalf("Expected success, but got error: %v", err)
+		}
+
+		out := buf.String()
+		if !strings.HasPrefix(out, "\ufeffDate;Category;Type;Amount;Currency;Note\n") {
+			t.Errorf("Expected BOM and ; separated header, got: %q", out)
+		}
+		for _, want := range []string{";income;30,45;USD;Freelance\n", "Total income;;;30,45;USD;\n", "Total expense;;;0,00;USD;\n", "Net;;;30,45;USD;\n"} {
+			if !strings.Contains(out, want) {
+				t.Errorf("Expected CSV to contain %q, got:\n%s", want, out)
+			}
+		}
+	})
+
+	t.Run("XLSX", func(t *testing.T) {
+		var buf bytes.Buffer
+		opts := TransactionExportOptions{Format: TRANSACTION_EXPORT_XLSX}
+		if err := bt.ExportTransactions(context.Background(), "john-1234", all, opts, func() io.Writer { return &buf }); err != nil {
+			t.Fatalf("Expected success, but got error: %v", err)
+		}
+
+		zr, err := zip.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+		if err != nil {
+			t.Fatalf("Expected a valid zip, got: %v", err)
+		}
+		parts := map[string]string{}
+		for _, f := range zr.File {
+			rc, err := f.Open()
+			if err != nil {
+				t.Fatalf("Failed to open %s: %v", f.Name, err)
+			}
+			data, _ := io.ReadAll(rc)
+			rc.Close()
+			parts[f.Name] = string(data)
+
+			if strings.HasSuffix(f.Name, ".xml") || strings.HasSuffix(f.Name, ".rels") {
+				decoder := xml.NewDecoder(bytes.NewReader(data))
+				for {
+					if _, err := decoder.Token(); err == io.EOF {
+						break
+					} else if err != nil {
+						t.Fatalf("%s is not well-formed XML: %v", f.Name, err)
+					}
+				}
+			}
+		}
+
+		for _, name := range []string{"[Content_Types].xml", "_rels/.rels", "xl/workbook.xml", "xl/_rels/workbook.xml.rels", "xl/styles.xml", "xl/worksheets/sheet1.xml"} {
+			if _, ok := parts[name]; !ok {
+				t.Errorf("Expected workbook part %s", name)
+			}
+		}
+		sheet := parts["xl/worksheets/sheet1.xml"]
+		for _, want := range []string{`<c r="D2" s="2"><v>30.45</v></c>`, "<t xml:space=\"preserve\">Freelance</t>", "<t xml:space=\"preserve\">Net</t>"} {
+			if !strings.Contains(sheet, want) {
+				t.Errorf("Expected sheet to contain %q", want)
+			}
+		}
+	})
+}
+
+func TestExcelSerialDate(t *testing.T) {
+	got := excelSerialDate(time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC))
+	if got != 45352.5 {
+		t.Errorf("Expected 45352.5, got %v", got)
+	}
+}
+
+func TestParseRecurrenceRule(t *testing.T) {
+	tests := []struct {
+		rule        string
+		expected    Recurrence
+		expectedErr string
+	}{
+		{rule: "FREQ=MONTHLY;BYMONTHDAY=1", expected: Recurrence{Frequency: RECURRENCE_MONTHLY, Interval: 1, MonthDay: 1}},
+		{rule: "RRULE:freq=monthly;bymonthday=-1", expected: Recurrence{Frequency: RECURRENCE_MONTHLY, Interval: 1, MonthDay: -1}},
+		{rule: "FREQ=MONTHLY;BYDAY=MO,TU,WE,TH,FR;BYSETPOS=-1", expected: Recurrence{Frequency: RECURRENCE_LAST_BUSINESS_DAY, Interval: 1}},
+		{rule: "FREQ=WEEKLY;INTERVAL=2;BYDAY=FR", expected: Recurrence{Frequency: RECURRENCE_WEEKLY, Interval: 2, Weekday: time.Friday, hasWeekday: true}},
+		{rule: "FREQ=DAILY;INTERVAL=14", expected: Recurrence{Frequency: RECURRENCE_DAILY, Interval: 14}},
+		{rule: "INTERVAL=2", expectedErr: "FREQ is required"},
+		{rule: "FREQ=YEARLY", expectedErr: "FREQ must be"},
+		{rule: "FREQ=DAILY;INTERVAL=0", expectedErr: "INTERVAL must be"},
+		{rule: "FREQ=MONTHLY;BYMONTHDAY=32", expectedErr: "BYMONTHDAY must be"},
+		{rule: "FREQ=WEEKLY;BYDAY=MO,FR", expectedErr: "single BYDAY"},
+		{rule: "FREQ=MONTHLY;BYDAY=MO;BYSETPOS=1", expectedErr: "last business day"},
+		{rule: "FREQ=DAILY;COUNT=3", expectedErr: "COUNT is not supported"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.rule, func(t *testing.T) {
+			got, err := ParseRecurrenceRule(tt.rule)
+			if tt.expectedErr != "" {
+				var appErr appErrors.ErrorResponse
+				if !errors.As(err, &appErr) || !strings.Contains(appErr.Message, tt.expectedErr) {
+					t.Errorf("Expected error containing %q, got: %v", tt.expectedErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Expected success, but got error: %v", err)
+			}
+			if got != tt.expected {
+				t.Errorf("Expected %+v, got %+v", tt.expected, got)
+			}
+		})
+	}
+}
+
+func TestRecurrenceNextAfter(t *testing.T) {
+	date := func(y int, m time.Month, d int) time.Time { return time.Date(y, m, d, 9, 0, 0, 0, time.UTC) }
+
+	tests := []struct {
+		name     string
+		rule     string
+		start    time.Time
+		after    time.Time
+		expected []time.Time
+	}{
+		{
+			name:     "day 31 falls on the month end",
+			rule:     "FREQ=MONTHLY;BYMONTHDAY=31",
+			start:    date(2026, 1, 1),
+			after:    date(2025, 12, 1),
+			expected: []time.Time{date(2026, 1, 31), date(2026, 2, 28), date(2026, 3, 31), date(2026, 4, 30)},
+		},
+		{
+			name:     "last business day skips weekends",
+			rule:     "FREQ=MONTHLY;BYDAY=MO,TU,WE,TH,FR;BYSETPOS=-1",
+			start:    date(2026, 1, 1),
+			after:    date(2026, 1, 1),
+			expected: []time.Time{date(2026, 1, 30), date(2026, 2, 27), date(2026, 3, 31), date(2026, 4, 30), date(2026, 5, 29)},
+		},
+		{
+			name:     "every other Friday",
+			rule:     "FREQ=WEEKLY;INTERVAL=2;BYDAY=FR",
+			start:    date(2026, 3, 2),
+			after:    date(2026, 3, 1),
+			expected: []time.Time{date(2026, 3, 6), date(2026, 3, 20), date(2026, 4, 3)},
+		},
+		{
+			name:     "every 14 days far from the start",
+			rule:     "FREQ=DAILY;INTERVAL=14",
+			start:    date(2020, 1, 1),
+			after:    date(2026, 3, 1),
+			expected: []time.Time{date(2026, 3, 4), date(2026, 3, 18)},
+		},
+		{
+			name:     "quarterly with the start day",
+			rule:     "FREQ=MONTHLY;INTERVAL=3",
+			start:    date(2025, 11, 15),
+			after:    date(2026, 3, 1),
+			expected: []time.Time{date(2026, 5, 15), date(2026, 8, 15)},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r, err := ParseRecurrenceRule(tt.rule)
+			if err != nil {
+				t.Fatalf("Failed to parse rule: %v", err)
+			}
+			after := tt.after
+			for _, want := range tt.expected {
+				got := r.NextAfter(tt.start, after)
+				if !got.Equal(want) {
+					t.Fatalf("NextAfter(%v) = %v, expected %v", after, got, want)
+				}
+				after = got
+			}
+		})
+	}
+}
+
+func TestMaterializeRecurringTransactions(t *testing.T) {
+	now := time.Now().UTC()
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+
+	mockStore := &MockStorage{Recurring: map[string]RecurringTransaction{
+		// the server was down for five days
+		"daily": {ID: "daily", CategoryId: "ts-1", CategoryType: "-", Amount: 3.5, Currency: "USD", Rule: "FREQ=DAILY",
+			StartAt: today.AddDate(0, 0, -5), NextRunAt: today.AddDate(0, 0, -5), CreatedBy: "john-1234"},
+		"ending": {ID: "ending", CategoryId: "ts-1", CategoryType: "-", Amount: 10, Currency: "USD", Rule: "FREQ=DAILY",
+			StartAt: today.AddDate(0, 0, -3), EndAt: today.AddDate(0, 0, -2), NextRunAt: today.AddDate(0, 0, -3), CreatedBy: "john-1234"},
+		"paused": {ID: "paused", CategoryId: "ts-1", CategoryType: "-", Amount: 1, Currency: "USD", Rule: "FREQ=DAILY",
+			StartAt: today.AddDate(0, 0, -5), NextRunAt: today.AddDate(0, 0, -5), Paused: true, CreatedBy: "john-1234"},
+		"deleted": {ID: "deleted", CategoryId: "deleted", CategoryType: "-", Amount: 1, Currency: "USD", Rule: "FREQ=DAILY",
+			StartAt: today, NextRunAt: today, CreatedBy: "john-1234"},
+	}}
+	bt := &BudgetTracker{storage: mockStore}
+	logging.Logger = logrus.New()
+	logging.Logger.SetOutput(io.Discard)
+	stale, _ := mockStore.GetDueRecurringTransactions(context.Background(), now)
+
+	created, err := bt.MaterializeRecurringTransactions(context.Background())
+	if err != nil {
+		t.Fatalf("Expected success, but got error: %v", err)
+	}
+	if created != 8 {
+		t.Errorf("Expected 6 daily and 2 ending instances, got %d", created)
+	}
+
+	daily := 0
+	for _, saved := range mockStore.SavedTransactions {
+		if saved.Amount == 3.5 {
+			if want := today.AddDate(0, 0, daily-5); !saved.OccurredAt.Equal(want) {
+				t.Errorf("Expected instance %d on %v, got %v", daily, want, saved.OccurredAt)
+			}
+			daily++
+		}
+	}
+
+	if next := mockStore.Recurring["daily"].NextRunAt; !next.Equal(today.AddDate(0, 0, 1)) {
+		t.Errorf("Expected next run tomorrow, got %v", next)
+	}
+	if next := mockStore.Recurring["ending"].NextRunAt; !next.IsZero() {
+		t.Errorf("Expected the ended schedule to have no next run, got %v", next)
+	}
+	if deleted := mockStore.Recurring["deleted"]; !deleted.Paused || deleted.PausedReason != "The category does not exist, please create the category" {
+		t.Errorf("Expected a schedule with a deleted category to be paused with the reason, got %+v", deleted)
+	}
+
+	// a second run right after has nothing left to do
+	if created, _ := bt.MaterializeRecurringTransactions(context.Background()); created != 0 {
+		t.Errorf("Expected no new instances on the second run, got %d", created)
+	}
+
+	// neither has a concurrent run that read the due schedules before
+	bt.storage = staleDueStorage{MockStorage: mockStore, due: stale}
+	if created, _ := bt.MaterializeRecurringTransactions(context.Background()); created != 0 {
+		t.Errorf("Expected no duplicate instances from a concurrent run, got %d", created)
+	}
+}
+
+// staleDueStorage returns the due schedules as another run read them.
+type staleDueStorage struct {
+	*MockStorage
+	due []RecurringTransaction
+}
+
+func (s staleDueStorage) GetDueRecurringTransactions(ctx context.Context, now time.Time) ([]RecurringTransaction, error) {
+	return s.due, nil
+}
+
+func TestRecurringTransactionSkipPauseResume(t *testing.T) {
+	mockStore := &MockStorage{}
+	bt := &BudgetTracker{storage: mockStore}
+	ctx := context.Background()
+
+	now := time.Now().UTC()
+	start := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC).AddDate(0, 0, 1)
+
+	if _, err := bt.SaveRecurringTransaction(ctx, "john-1234", RecurringTransactionRequest{
+		CategoryId: "ts-1", CategoryType: "-", Amount: 5, Currency: "USD", Rule: "FREQ=MONTHLY;BYMONTHDAY=32",
+	}); err == nil {
+		t.Errorf("Expected error for an invalid rule")
+	}
+
+	r, err := bt.SaveRecurringTransaction(ctx, "john-1234", RecurringTransactionRequest{
+		CategoryId: "ts-1", CategoryType: "-", Amount: 5, Currency: "USD", Note: "gym", Rule: "FREQ=WEEKLY", StartAt: start,
+	})
+	if err != nil {
+		t.Fatalf("Expected success, but got error: %v", err)
+	}
+	if !r.NextRunAt.Equal(start) {
+		t.Errorf("Expected the first run on the start date %v, got %v", start, r.NextRunAt)
+	}
+
+	upcoming, err := bt.GetUpcomingTransactions(ctx, "john-1234", 30)
+	if err != nil {
+		t.Fatalf("Expected success, but got error: %v", err)
+	}
+	if len(upcoming) < 4 || len(upcoming) > 5 || !upcoming[0].OccursAt.Equal(start) {
+		t.Errorf("Expected 4 or 5 weekly instances starting %v, got %+v", start, upcoming)
+	}
+
+	r, err = bt.SkipRecurringTransaction(ctx, "john-1234", r.ID)
+	if err != nil {
+		t.Fatalf("Expected success, but got error: %v", err)
+	}
+	if !r.NextRunAt.Equal(start.AddDate(0, 0, 7)) {
+		t.Errorf("Expected skip to move to %v, got %v", start.AddDate(0, 0, 7), r.NextRunAt)
+	}
+
+	if _, err := bt.PauseRecurringTransaction(ctx, "john-1234", r.ID); err != nil {
+		t.Fatalf("Expected success, but got error: %v", err)
+	}
+	if upcoming, _ := bt.GetUpcomingTransactions(ctx, "john-1234", 30); len(upcoming) != 0 {
+		t.Errorf("Expected no upcoming instances while paused, got %d", len(upcoming))
+	}
+
+	// pretend the pause lasted long enough for the next run to pass
+	stored := mockStore.Recurring[r.ID]
+	stored.NextRunAt = start.AddDate(0, 0, -21)
+	mockStore.Recurring[r.ID] = stored
+
+	r, err = bt.ResumeRecurringTransaction(ctx, "john-1234", r.ID)
+	if err != nil {
+		t.Fatalf("Expected success, but got error: %v", err)
+	}
+	if r.Paused || !r.NextRunAt.After(now) {
+		t.Errorf("Expected resume to continue after now without catching up, got %+v", r)
+	}
+
+	if _, err := bt.GetUpcomingTransactions(ctx, "john-1234", 400); err == nil {
+		t.Errorf("Expected error for more than %d days", MAX_UPCOMING_DAYS)
+	}
+}
+
+func TestParseReceiptLines(t *testing.T) {
+	receipt := `BRAVO SUPERMARKET
+Tel: 012 555 44 33
+14.03.2026 18:42
+Bread 0.80
+Milk 3.2% 1L ...... 2.45
+2 x 1.50 Eggs  3.00 AZN
+Light bulbs        ₼12.30
+Dish soap: 4,15
+SUBTOTAL 22.70
+CƏMİ 22.70
+NAĞD 30.00
+QALIQ 7.30`
+
+	lines, total := parseReceiptLines(receipt)
+	expected := []TransactionSplitRequest{
+		{Note: "Bread", Amount: 0.8},
+		{Note: "Milk 3.2% 1L", Amount: 2.45},
+		{Note: "2 x 1.50 Eggs", Amount: 3},
+		{Note: "Light bulbs", Amount: 12.3},
+		{Note: "Dish soap", Amount: 4.15},
+	}
+	if len(lines) != len(expected) {
+		t.Fatalf("Expected %d lines, got %+v", len(expected), lines)
+	}
+	for i := range expected {
+		if lines[i] != expected[i] {
+			t.Errorf("Line %d: expected %+v, got %+v", i, expected[i], lines[i])
+		}
+	}
+	if total != 22.7 {
+		t.Errorf("Expected total 22.70, got %v", total)
+	}
+
+	// without a total line the items are the total
+	if _, total := parseReceiptLines("Coffee 2.50\nCake 3.75"); total != 6.25 {
+		t.Errorf("Expected total 6.25, got %v", total)
+	}
+}
+
+func TestExportSplitTransaction(t *testing.T) {
+	mockStore := &splitStorage{}
+	bt := &BudgetTracker{storage: mockStore}
+
+	var buf bytes.Buffer
+	err := bt.ExportTransactions(context.Background(), "john-1234", &TransactionList{IsAllNil: true}, TransactionExportOptions{Format: TRANSACTION_EXPORT_CSV}, func() io.Writer { return &buf })
+	if err != nil {
+		t.Fatalf("Expected success, but got error: %v", err)
+	}
+	csv := buf.String()
+	for _, want := range []string{"food,expense,-25.05,USD,bread", "home repair,expense,-15.05,USD,supermarket", "Total expense,,,-40.10,USD"} {
+		if !strings.Contains(csv, want) {
+			t.Errorf("Expected export to contain %q:\n%s", want, csv)
+		}
+	}
+
+	// filtering by a category only counts its splits
+	buf.Reset()
+	err = bt.ExportTransactions(context.Background(), "john-1234", &TransactionList{CategoryNames: []string{"Food"}, Type: "-"}, TransactionExportOptions{Format: TRANSACTION_EXPORT_CSV}, func() io.Writer { return &buf })
+	if err != nil {
+		t.Fatalf("Expected success, but got error: %v", err)
+	}
+	if strings.Contains(buf.String(), "home repair") || !strings.Contains(buf.String(), "-25.05") {
+		t.Errorf("Expected only the food split:\n%s", buf.String())
+	}
+}
+
+type splitStorage struct {
+	MockStorage
+}
+
+func (s *splitStorage) GetFilteredTransactions(ctx context.Context, userID string, filters *TransactionList) ([]Transaction, error) {
+	return []Transaction{{
+		ID: "t1", CategoryId: "e1", CategoryName: "food", CategoryType: "-", Amount: 40.1, Currency: "USD", Note: "supermarket",
+		OccurredAt: time.Date(2026, 3, 14, 18, 0, 0, 0, time.UTC),
+		Splits: []TransactionSplit{
+			{ID: "s1", CategoryId: "e1", CategoryName: "food", Amount: 25.05, Note: "bread"},
+			{ID: "s2", CategoryId: "e2", CategoryName: "home repair", Amount: 15.05},
+		},
+	}}, nil
+}
+
+func (s *splitStorage) EachFilteredTransaction(ctx context.Context, userID string, filters *TransactionList, fn func(Transaction) error) error {
+	transactions, _ := s.GetFilteredTransactions(ctx, userID, filters)
+	for _, t := range transactions {
+		if err := fn(t); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func TestSaveWallet(t *testing.T) {
+	mockStore := &MockStorage{}
+	bt := &BudgetTracker{storage: mockStore}
+	ctx := context.Background()
+
+	tests := []struct {
+		name        string
+		input       WalletRequest
+		expectedMsg string
+	}{
+		{
+			name:        "Fail - Empty Name",
+			input:       WalletRequest{Name: "  ", Type: WALLET_CASH, Currency: "USD"},
+			expectedMsg: "Wallet name cannot be empty!",
+		},
+		{
+			name:        "Fail - Unknown Type",
+			input:       WalletRequest{Name: "Wallet", Type: "crypto", Currency: "USD"},
+			expectedMsg: "Wallet type must be one of",
+		},
+		{
+			name:        "Fail - Missing Currency",
+			input:       WalletRequest{Name: "Wallet", Type: WALLET_CASH},
+			expectedMsg: "Wallet currency is required",
+		},
+		{
+			name:  "Success - Credit Card With Negative Balance",
+			input: WalletRequest{Name: "Visa", Type: WALLET_CREDIT_CARD, Currency: "usd", OpeningBalance: -120.5},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w, err := bt.SaveWallet(ctx, "john-1234", tt.input)
+			if tt.expectedMsg != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.expectedMsg) {
+					t.Fatalf("Expected error containing %q, got %v", tt.expectedMsg, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Expected success, but got error: %v", err)
+			}
+			if w.Currency != "USD" || w.Balance != -120.5 {
+				t.Errorf("Expected USD wallet with balance -120.50, got %s %.2f", w.Currency, w.Balance)
+			}
+		})
+	}
+
+	wallets, _ := mockStore.GetWallets(ctx, "john-1234")
+	w := wallets[0]
+	if _, err := bt.UpdateWallet(ctx, "john-1234", w.ID, WalletRequest{Name: "Visa", Type: WALLET_CREDIT_CARD, Currency: "EUR"}); err == nil {
+		t.Errorf("Expected error when changing the wallet currency")
+	}
+}
+
+func TestSaveTransfer(t *testing.T) {
+	mockStore := &MockStorage{Wallets: map[string]Wallet{
+		"cash": {ID: "cash", Name: "Cash", Currency: "USD", CreatedBy: "john-1234"},
+		"bank": {ID: "bank", Name: "Bank", Currency: "USD", CreatedBy: "john-1234"},
+		"euro": {ID: "euro", Name: "Euro", Currency: "EUR", CreatedBy: "john-1234"},
+		"mine": {ID: "mine", Name: "Other", Currency: "USD", CreatedBy: "someone-else"},
+	}}
+	bt := &BudgetTracker{storage: mockStore}
+	ctx := context.Background()
+
+	tests := []struct {
+		name        string
+		input       TransactionRequest
+		expectedMsg string
+	}{
+		{
+			name:        "Fail - Transfer With Category",
+			input:       TransactionRequest{CategoryId: "ts-1", CategoryType: TRANSFER_TYPE, Amount: 10, WalletId: "cash", ToWalletId: "bank"},
+			expectedMsg: "A transfer has no category.",
+		},
+		{
+			name:        "Fail - Missing Destination",
+			input:       TransactionRequest{CategoryType: TRANSFER_TYPE, Amount: 10, WalletId: "cash"},
+			expectedMsg: "needs both wallet ID and destination wallet ID",
+		},
+		{
+			name:        "Fail - Same Wallet",
+			input:       TransactionRequest{CategoryType: TRANSFER_TYPE, Amount: 10, WalletId: "cash", ToWalletId: "cash"},
+			expectedMsg: "Cannot transfer to the same wallet.",
+		},
+		{
+			name:        "Fail - Currency Mismatch",
+			input:       TransactionRequest{CategoryType: TRANSFER_TYPE, Amount: 10, WalletId: "cash", ToWalletId: "euro"},
+			expectedMsg: "must match the currency of wallet \"Euro\"",
+		},
+		{
+			name:        "Fail - Wallet Of Another User",
+			input:       TransactionRequest{CategoryType: TRANSFER_TYPE, Amount: 10, WalletId: "cash", ToWalletId: "mine"},
+			expectedMsg: "Wallet not found.",
+		},
+		{
+			name:        "Fail - Destination Without Transfer",
+			input:       TransactionRequest{CategoryId: "ts-1", CategoryType: "-", Amount: 10, ToWalletId: "bank"},
+			expectedMsg: "only allowed on transfers",
+		},
+		{
+			name:  "Success - Transfer Takes Wallet Currency",
+			input: TransactionRequest{CategoryType: TRANSFER_TYPE, Amount: 10, WalletId: "cash", ToWalletId: "bank"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := bt.SaveTransaction(ctx, "john-1234", tt.input)
+			if tt.expectedMsg != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.expectedMsg) {
+					t.Fatalf("Expected error containing %q, got %v", tt.expectedMsg, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Expected success, but got error: %v", err)
+			}
+		})
+	}
+
+	if len(mockStore.SavedTransactions) != 1 || mockStore.SavedTransactions[0].Currency != "USD" {
+		t.Fatalf("Expected one USD transfer to be saved, got %+v", mockStore.SavedTransactions)
+	}
+}
+
+func TestGetWalletRegister(t *testing.T) {
+	day := func(d int) time.Time { return time.Date(2026, 3, d, 12, 0, 0, 0, time.UTC) }
+	mockStore := &MockStorage{
+		Wallets: map[string]Wallet{
+			"bank": {ID: "bank", Name: "Bank", Currency: "USD", OpeningBalance: 100, CreatedBy: "john-1234"},
+		},
+		SavedTransactions: []Transaction{
+			{ID: "t1", CategoryType: "+", Amount: 1000.1, WalletId: "bank", OccurredAt: day(1)},
+			{ID: "t2", CategoryType: "-", Amount: 250.05, WalletId: "bank", OccurredAt: day(2)},
+			{ID: "t3", CategoryType: TRANSFER_TYPE, Amount: 300, WalletId: "bank", ToWalletId: "cash", OccurredAt: day(3)},
+			{ID: "t4", CategoryType: TRANSFER_TYPE, Amount: 49.95, WalletId: "card", ToWalletId: "bank", OccurredAt: day(4)},
+		},
+	}
+	bt := &BudgetTracker{storage: mockStore}
+
+	_, entries, err := bt.GetWalletRegister(context.Background(), "john-1234", "bank")
+	if err != nil {
+		t.Fatalf("Expected success, but got error: %v", err)
+	}
+
+	want := []struct{ amount, balance float64 }{{1000.1, 1100.1}, {-250.05, 850.05}, {-300, 550.05}, {49.95, 600}}
+	if len(entries) != len(want) {
+		t.Fatalf("Expected %d entries, got %d", len(want), len(entries))
+	}
+	for i, w := range want {
+		if entries[i].Amount != w.amount || entries[i].Balance != w.balance {
+			t.Errorf("Entry %d: got amount %.2f balance %.2f, want %.2f and %.2f", i, entries[i].Amount, entries[i].Balance, w.amount, w.balance)
+		}
+	}
+
+	if _, _, err := bt.GetWalletRegister(context.Background(), "someone-else", "bank"); err == nil {
+		t.Errorf("Expected error for a wallet of another user")
+	}
+}
+
+func TestReconciliation(t *testing.T) {
+	day := func(d int) time.Time { return time.Date(2026, 3, d, 12, 0, 0, 0, time.UTC) }
+	mockStore := &MockStorage{
+		Wallets: map[string]Wallet{
+			"bank": {ID: "bank", Name: "Bank", Currency: "USD", OpeningBalance: 100, CreatedBy: "john-1234"},
+		},
+		SavedTransactions: []Transaction{
+			{ID: "t1", CategoryType: "+", Amount: 1000, WalletId: "bank", OccurredAt: day(1)},
+			{ID: "t2", CategoryType: "-", Amount: 250.05, WalletId: "bank", OccurredAt: day(2)},
+			{ID: "t3", CategoryType: "-", Amount: 40, WalletId: "bank", OccurredAt: day(3)},
+		},
+	}
+	bt := &BudgetTracker{storage: mockStore}
+	ctx := context.Background()
+
+	if _, err := bt.FinishReconciliation(ctx, "john-1234", "bank"); err == nil {
+		t.Fatalf("Expected error when no reconciliation is open")
+	}
+
+	status, err := bt.StartReconciliation(ctx, "john-1234", "bank", ReconciliationRequest{StatementBalance: 849.95, StatementDate: day(2)})
+	if err != nil {
+		t.Fatalf("Expected success, but got error: %v", err)
+	}
+	if status.ClearedBalance != 100 || status.Difference != 749.95 || len(status.Unreconciled) != 3 {
+		t.Fatalf("Unexpected status before clearing: %+v", status)
+	}
+
+	status, err = bt.SetTransactionsCleared(ctx, "john-1234", "bank", []string{"t1", "t2"}, true)
+	if err != nil {
+		t.Fatalf("Expected success, but got error: %v", err)
+	}
+	if status.ClearedBalance != 849.95 || status.Difference != 0 {
+		t.Fatalf("Expected cleared balance 849.95 and no difference, got %.2f and %.2f", status.ClearedBalance, status.Difference)
+	}
+
+	// a wrong statement balance blocks finishing
+	if _, err := bt.StartReconciliation(ctx, "john-1234", "bank", ReconciliationRequest{StatementBalance: 850, StatementDate: day(2)}); err != nil {
+		t.Fatalf("Expected success, but got error: %v", err)
+	}
+	if _, err := bt.FinishReconciliation(ctx, "john-1234", "bank"); err == nil || !strings.Contains(err.Error(), "difference 0.05") {
+		t.Fatalf("Expected difference error, got %v", err)
+	}
+	if len(mockStore.Reconciliations) != 1 {
+		t.Fatalf("Expected the open reconciliation to be updated, got %d", len(mockStore.Reconciliations))
+	}
+
+	if _, err := bt.StartReconciliation(ctx, "john-1234", "bank", ReconciliationRequest{StatementBalance: 849.95, StatementDate: day(2)}); err != nil {
+		t.Fatalf("Expected success, but got error: %v", err)
+	}
+	if _, err := bt.FinishReconciliation(ctx, "john-1234", "bank"); err != nil {
+		t.Fatalf("Expected success, but got error: %v", err)
+	}
+
+	status, err = bt.GetReconciliation(ctx, "john-1234", "bank")
+	if err != nil {
+		t.Fatalf("Expected success, but got error: %v", err)
+	}
+	if status.Reconciliation != nil || len(status.Unreconciled) != 1 || status.Unreconciled[0].ID != "t3" {
+		t.Fatalf("Expected only t3 to be left unreconciled, got %+v", status)
+	}
+
+	// reconciled transactions are locked
+	if _, err := bt.SetTransactionsCleared(ctx, "john-1234", "bank", []string{"t1"}, false); err == nil {
+		t.Errorf("Expected error when changing a reconciled transaction")
+	}
+}
+
+func TestSaveTransactionTags(t *testing.T) {
+	mockStore := &MockStorage{Tags: map[string]Tag{
+		"g1": {ID: "g1", Name: "vacation 2026", CreatedBy: "john-1234"},
+	}}
+	bt := &BudgetTracker{storage: mockStore}
+	ctx := context.Background()
+
+	tests := []struct {
+		name         string
+		tags         []string
+		expectedMsg  string
+		expectedTags []string
+	}{
+		{
+			name:        "Fail - Empty Tag",
+			tags:        []string{"food", "  "},
+			expectedMsg: "Tag name cannot be empty!",
+		},
+		{
+			name:        "Fail - Comma In Tag",
+			tags:        []string{"a,b"},
+			expectedMsg: "cannot contain a comma",
+		},
+		{
+			name:        "Fail - Tag Too Long",
+			tags:        []string{strings.Repeat("x", MAX_TAG_NAME_LENGTH+1)},
+			expectedMsg: "Tag name so long",
+		},
+		{
+			name:         "Success - Normalized, Deduplicated And Sorted",
+			tags:         []string{"  Vacation   2026 ", "Reimbursable", "vacation 2026"},
+			expectedTags: []string{"reimbursable", "vacation 2026"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockStore.SavedTransactions = nil
+			err := bt.SaveTransaction(ctx, "john-1234", TransactionRequest{CategoryId: "ts-1", CategoryType: "-", Amount: 10, Currency: "USD", Tags: tt.tags})
+			if tt.expectedMsg != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.expectedMsg) {
+					t.Fatalf("Expected error containing %q, got %v", tt.expectedMsg, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Expected success, but got error: %v", err)
+			}
+			if got := mockStore.SavedTransactions[0].Tags; !slices.Equal(got, tt.expectedTags) {
+				t.Errorf("Expected tags %v, got %v", tt.expectedTags, got)
+			}
+		})
+	}
+
+	// the missing tag was created, the existing one reused
+	if len(mockStore.Tags) != 2 {
+		t.Fatalf("Expected 2 tags, got %+v", mockStore.Tags)
+	}
+}
+
+func TestSetTransactionTags(t *testing.T) {
+	mockStore := &MockStorage{SavedTransactions: []Transaction{{ID: "ts-1", CategoryType: "+", Amount: 1500, Tags: []string{"old"}}}}
+	bt := &BudgetTracker{storage: mockStore}
+	ctx := context.Background()
+
+	transaction, err := bt.SetTransactionTags(ctx, "john-1234", "ts-1", []string{"Bonus", "work"})
+	if err != nil {
+		t.Fatalf("Expected success, but got error: %v", err)
+	}
+	if !slices.Equal(transaction.Tags, []string{"bonus", "work"}) || !slices.Equal(mockStore.SavedTransactions[0].Tags, transaction.Tags) {
+		t.Fatalf("Expected tags to be replaced, got %v", mockStore.SavedTransactions[0].Tags)
+	}
+	if len(mockStore.Tags) != 2 {
+		t.Fatalf("Expected 2 tags to be created, got %d", len(mockStore.Tags))
+	}
+
+	tags, _ := mockStore.GetTags(ctx, "john-1234")
+	if _, err := bt.UpdateTag(ctx, "john-1234", tags[0].ID, "work"); err != nil {
+		t.Fatalf("Expected rename to pass to storage, got %v", err)
+	}
+	if _, err := bt.UpdateTag(ctx, "someone-else", tags[0].ID, "other"); err == nil {
+		t.Errorf("Expected error when renaming the tag of another user")
+	}
+}
+
+func TestCategoryRollUp(t *testing.T) {
+	mockStore := &MockStorage{ExpenseCategories: []ExpenseCategoryResponse{
+		{ID: "food", Name: "food", Amount: 10, MaxAmount: 50},
+		{ID: "groceries", Name: "groceries", Amount: 120.5, MaxAmount: 200, ParentId: "food"},
+		{ID: "restaurants", Name: "restaurants", Amount: 69.5, MaxAmount: 150, ParentId: "food"},
+		{ID: "coffee", Name: "coffee", Amount: 20, MaxAmount: 0, ParentId: "restaurants"},
+		{ID: "rent", Name: "rent", Amount: 900, MaxAmount: 1000},
+	}}
+	bt := &BudgetTracker{storage: mockStore}
+	ctx := context.Background()
+
+	categories, err := bt.GetFilteredExpenseCategories(ctx, "john-1234", &ExpenseCategoryList{IsAllNil: true})
+	if err != nil {
+		t.Fatalf("Expected success, but got error: %v", err)
+	}
+	byId := map[string]ExpenseCategoryResponse{}
+	for _, c := range categories {
+		byId[c.ID] = c
+	}
+	if food := byId["food"]; food.Amount != 220 || food.MaxAmount != 400 || food.UsagePercent != 55 {
+		t.Errorf("Expected food to roll up to 220 of 400, got %+v", food)
+	}
+	if restaurants := byId["restaurants"]; restaurants.Amount != 89.5 || restaurants.MaxAmount != 150 {
+		t.Errorf("Expected restaurants to include coffee, got %+v", restaurants)
+	}
+
+	// subcategories roll up even when they are filtered out
+	filtered, err := bt.GetFilteredExpenseCategories(ctx, "john-1234", &ExpenseCategoryList{Names: []string{"food"}})
+	if err != nil {
+		t.Fatalf("Expected success, but got error: %v", err)
+	}
+	if len(filtered) != 1 || filtered[0].Amount != 220 {
+		t.Errorf("Expected filtered food to roll up to 220, got %+v", filtered)
+	}
+
+	tree := ExpenseCategoryTree(categories)
+	if len(tree) != 2 || tree[0].ID != "food" || len(tree[0].Children) != 2 || len(tree[0].Children[1].Children) != 1 {
+		t.Errorf("Unexpected tree: %+v", tree)
+	}
+}
+
+func TestMoveCategory(t *testing.T) {
+	var chain []ExpenseCategoryResponse
+	parent := ""
+	for _, id := range []string{"l1", "l2", "l3", "l4", "l5"} {
+		chain = append(chain, ExpenseCategoryResponse{ID: id, Name: id, ParentId: parent})
+		parent = id
+	}
+	mockStore := &MockStorage{ExpenseCategories: append(chain, ExpenseCategoryResponse{ID: "other", Name: "other"})}
+	bt := &BudgetTracker{storage: mockStore}
+	ctx := context.Background()
+
+	tests := []struct {
+		name        string
+		id          string
+		parentId    string
+		expectedMsg string
+	}{
+		{name: "Fail - Unknown Category", id: "missing", parentId: "l1", expectedMsg: "The category does not exist."},
+		{name: "Fail - Unknown Parent", id: "other", parentId: "missing", expectedMsg: "The parent category does not exist."},
+		{name: "Fail - Own Parent", id: "l2", parentId: "l2", expectedMsg: "cannot be its own parent"},
+		{name: "Fail - Under Own Subcategory", id: "l2", parentId: "l4", expectedMsg: "under its own subcategory"},
+		{name: "Fail - Too Deep", id: "other", parentId: "l5", expectedMsg: "at most 5 levels"},
+		{name: "Fail - Subtree Too Deep", id: "l1", parentId: "other", expectedMsg: "at most 5 levels"},
+		{name: "Success - Under Sibling Subtree", id: "other", parentId: "l4"},
+		{name: "Success - To Top Level", id: "l3", parentId: ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := bt.MoveCategory(ctx, "john-1234", "-", tt.id, tt.parentId)
+			if tt.expectedMsg != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.expectedMsg) {
+					t.Fatalf("Expected error containing %q, got %v", tt.expectedMsg, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Expected success, but got error: %v", err)
+			}
+			if got, ok := mockStore.MovedCategories[tt.id]; !ok || got != tt.parentId {
+				t.Errorf("Expected %s to be moved under %q, got %q", tt.id, tt.parentId, got)
+			}
+		})
+	}
+}
+
+func TestArchiveCategory(t *testing.T) {
+	mockStore := &MockStorage{ExpenseCategories: []ExpenseCategoryResponse{
+		{ID: "food", Name: "food", Amount: 100, MaxAmount: 500},
+		{ID: "snacks", Name: "snacks", Amount: 20, MaxAmount: 50, ParentId: "food"},
+	}}
+	bt := &BudgetTracker{storage: mockStore}
+	ctx := context.Background()
+
+	err := bt.SetCategoryArchived(ctx, "john-1234", "-", "missing", true)
+	if err == nil || !strings.Contains(err.Error(), "does not exist") {
+		t.Fatalf("Expected not found error, got %v", err)
+	}
+
+	if err := bt.SetCategoryArchived(ctx, "john-1234", "-", "snacks", true); err != nil {
+		t.Fatalf("Expected success, but got error: %v", err)
+	}
+
+	categories, err := bt.GetFilteredExpenseCategories(ctx, "john-1234", &ExpenseCategoryList{IsAllNil: true})
+	if err != nil {
+		t.Fatalf("Expected success, but got error: %v", err)
+	}
+	if len(categories) != 1 || categories[0].ID != "food" {
+		t.Fatalf("Expected only the food category, got %+v", categories)
+	}
+	if categories[0].Amount != 120 {
+		t.Errorf("Expected the archived subcategory to still count, got amount %v", categories[0].Amount)
+	}
+
+	categories, err = bt.GetFilteredExpenseCategories(ctx, "john-1234", &ExpenseCategoryList{IsAllNil: true, IncludeArchived: true})
+	if err != nil {
+		t.Fatalf("Expected success, but got error: %v", err)
+	}
+	if len(categories) != 2 || categories[1].ArchivedAt.IsZero() {
+		t.Fatalf("Expected the archived category with include archived, got %+v", categories)
+	}
+
+	if err := bt.SetCategoryArchived(ctx, "john-1234", "-", "snacks", false); err != nil {
+		t.Fatalf("Expected success, but got error: %v", err)
+	}
+	if !mockStore.ExpenseCategories[1].ArchivedAt.IsZero() {
+		t.Errorf("Expected the category to be unarchived")
+	}
+}
+
+func TestDeleteCategoryMoveTo(t *testing.T) {
+	mockStore := &MockStorage{
+		ExpenseCategories: []ExpenseCategoryResponse{{ID: "old", Name: "old"}, {ID: "new", Name: "new"}},
+		SavedTransactions: []Transaction{{ID: "t-1", CategoryId: "old"}, {ID: "t-2", CategoryId: "old"}, {ID: "t-3", CategoryId: "new"}},
+	}
+	bt := &BudgetTracker{storage: mockStore}
+	ctx := context.Background()
+
+	tests := []struct {
+		name        string
+		id          string
+		moveTo      string
+		expectedMsg string
+	}{
+		{name: "Fail - Move To Itself", id: "old", moveTo: "old", expectedMsg: "category that is deleted"},
+		{name: "Fail - Unknown Category", id: "missing", moveTo: "new", expectedMsg: "The category does not exist."},
+		{name: "Fail - Unknown Target", id: "old", moveTo: "missing", expectedMsg: "move the transactions to does not exist"},
+		{name: "Success - Move", id: "old", moveTo: "new"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			affected, err := bt.DeleteExpenseCategory(ctx, "john-1234", tt.id, tt.moveTo)
+			if tt.expectedMsg != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.expectedMsg) {
+					t.Fatalf("Expected error containing %q, got %v", tt.expectedMsg, err)
+				}
+				if _, ok := mockStore.DeletedCategories[tt.id]; ok {
+					t.Errorf("Expected %s not to be deleted", tt.id)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Expected success, but got error: %v", err)
+			}
+			if affected != 2 {
+				t.Errorf("Expected 2 affected transactions, got %d", affected)
+			}
+			if got := mockStore.DeletedCategories[tt.id]; got != tt.moveTo {
+				t.Errorf("Expected transactions to move to %q, got %q", tt.moveTo, got)
+			}
+		})
+	}
+}
+
+func TestMergeCategories(t *testing.T) {
+	mockStore := &MockStorage{
+		ExpenseCategories: []ExpenseCategoryResponse{
+			{ID: "food", Name: "food", MaxAmount: 100, Note: "daily"},
+			{ID: "groceries", Name: "groceries", MaxAmount: 250.5, Note: "weekly"},
+			{ID: "snacks", Name: "snacks", MaxAmount: 20, ParentId: "groceries"},
+			{ID: "market", Name: "market", MaxAmount: 300, Note: "daily"},
+		},
+		SavedTransactions: []Transaction{{ID: "t-1", CategoryId: "groceries"}, {ID: "t-2", CategoryId: "market"}, {ID: "t-3", CategoryId: "food"}},
+	}
+	bt := &BudgetTracker{storage: mockStore}
+	ctx := context.Background()
+
+	tests := []struct {
+		name          string
+		req           CategoryMergeRequest
+		expectedMsg   string
+		expectedLimit float64
+		expectedNote  string
+	}{
+		{name: "Fail - No Sources", req: CategoryMergeRequest{TargetId: "food"}, expectedMsg: "Source category IDs cannot be empty"},
+		{name: "Fail - Target Is Source", req: CategoryMergeRequest{TargetId: "food", SourceIds: []string{"food"}}, expectedMsg: "cannot be one of the source"},
+		{name: "Fail - Unknown Strategy", req: CategoryMergeRequest{TargetId: "food", SourceIds: []string{"market"}, LimitStrategy: "avg"}, expectedMsg: "Invalid limit strategy"},
+		{name: "Fail - Unknown Target", req: CategoryMergeRequest{TargetId: "missing", SourceIds: []string{"market"}}, expectedMsg: "target category does not exist"},
+		{name: "Fail - Unknown Source", req: CategoryMergeRequest{TargetId: "food", SourceIds: []string{"missing"}}, expectedMsg: "source category missing does not exist"},
+		{name: "Fail - Target Inside Source", req: CategoryMergeRequest{TargetId: "snacks", SourceIds: []string{"groceries"}}, expectedMsg: "subcategory of a source"},
+		{name: "Success - Keep Target", req: CategoryMergeRequest{TargetId: "food", SourceIds: []string{"market"}}, expectedLimit: 100, expectedNote: "daily"},
+		{
+			name:          "Success - Sum And Join",
+			req:           CategoryMergeRequest{TargetId: "food", SourceIds: []string{"groceries", "market", "groceries"}, LimitStrategy: MERGE_SUM, NoteStrategy: MERGE_JOIN},
+			expectedLimit: 650.5,
+			expectedNote:  "daily; weekly",
+		},
+		{name: "Success - Max", req: CategoryMergeRequest{TargetId: "food", SourceIds: []string{"groceries", "market"}, LimitStrategy: MERGE_MAX}, expectedLimit: 300, expectedNote: "daily"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			merge, err := bt.MergeCategories(ctx, "john-1234", "-", tt.req)
+			if tt.expectedMsg != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.expectedMsg) {
+					t.Fatalf("Expected error containing %q, got %v", tt.expectedMsg, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Expected success, but got error: %v", err)
+			}
+			if merge.NewLimit != tt.expectedLimit || merge.NewNote != tt.expectedNote {
+				t.Errorf("Expected limit %v and note %q, got %v and %q", tt.expectedLimit, tt.expectedNote, merge.NewLimit, merge.NewNote)
+			}
+			if merge.OldLimit != 100 || merge.TargetName != "food" {
+				t.Errorf("Expected the audit entry to keep the old target, got %+v", merge)
+			}
+		})
+	}
+
+	last := mockStore.Merges[len(mockStore.Merges)-1]
+	if len(last.Sources) != 2 || last.Sources[0].ID != "groceries" || last.MovedTransactions != 2 {
+		t.Errorf("Expected 2 deduplicated sources and 2 moved transactions, got %+v", last)
+	}
+}
+
+func TestSavingsGoalProgress(t *testing.T) {
+	created := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	target := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	halfway := time.Date(2025, 7, 2, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name             string
+		saved            float64
+		now              time.Time
+		expectedStatus   string
+		expectedMonths   int
+		expectedRequired float64
+		expectedPercent  int
+	}{
+		{name: "Reached", saved: 1200, now: halfway, expectedStatus: GOAL_REACHED, expectedMonths: 6, expectedRequired: 0, expectedPercent: 100},
+		{name: "On Track", saved: 600, now: halfway, expectedStatus: GOAL_ON_TRACK, expectedMonths: 6, expectedRequired: 100, expectedPercent: 50},
+		{name: "Behind", saved: 300, now: halfway, expectedStatus: GOAL_BEHIND, expectedMonths: 6, expectedRequired: 150, expectedPercent: 25},
+		{name: "Overdue", saved: 300, now: target.AddDate(0, 0, 1), expectedStatus: GOAL_OVERDUE, expectedMonths: 0, expectedRequired: 900, expectedPercent: 25},
+		{name: "Nothing Saved At Start", saved: 0, now: created, expectedStatus: GOAL_ON_TRACK, expectedMonths: 12, expectedRequired: 100, expectedPercent: 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := SavingsGoal{TargetAmount: 1200, Saved: tt.saved, TargetDate: target, CreatedAt: created}
+			p := goalProgress(g, tt.now)
+			if p.Status != tt.expectedStatus || p.MonthsLeft != tt.expectedMonths || p.RequiredMonthly != tt.expectedRequired || p.Percent != tt.expectedPercent {
+				t.Errorf("Expected %s, %d months, %v monthly and %d%%, got %s, %d months, %v monthly and %d%%",
+					tt.expectedStatus, tt.expectedMonths, tt.expectedRequired, tt.expectedPercent, p.Status, p.MonthsLeft, p.RequiredMonthly, p.Percent)
+			}
+		})
+	}
+
+	// Three average months in, 200 saved is 66.67 a month, too slow for the target date.
+	p := goalProgress(SavingsGoal{TargetAmount: 1200, Saved: 200, TargetDate: target, CreatedAt: created}, created.Add(time.Duration(3*daysPerMonth*24*float64(time.Hour))))
+	if p.AverageMonthly != 66.67 || !p.ProjectedDate.After(target) {
+		t.Errorf("Expected 66.67 a month and a projection after the target date, got %v and %v", p.AverageMonthly, p.ProjectedDate)
+	}
+}
+
+func TestSavingsContributions(t *testing.T) {
+	mockStore := &MockStorage{
+		Wallets: map[string]Wallet{"w-1": {ID: "w-1", Currency: "EUR", Balance: 50, CreatedBy: "john-1234"}},
+	}
+	bt := &BudgetTracker{storage: mockStore}
+	ctx := context.Background()
+	targetDate := time.Now().UTC().AddDate(1, 0, 0)
+
+	if _, err := bt.SaveSavingsGoal(ctx, "john-1234", SavingsGoalRequest{Name: "car", TargetAmount: 1000, Currency: "USD", TargetDate: targetDate, WalletId: "w-1"}); err == nil || !strings.Contains(err.Error(), "must be EUR") {
+		t.Fatalf("Expected a wallet currency error, got %v", err)
+	}
+	if _, err := bt.SaveSavingsGoal(ctx, "john-1234", SavingsGoalRequest{Name: "car", TargetAmount: 1000, Currency: "USD", TargetDate: time.Now().AddDate(0, 0, -1)}); err == nil || !strings.Contains(err.Error(), "target date must be after") {
+		t.Fatalf("Expected a target date error, got %v", err)
+	}
+
+	goal, err := bt.SaveSavingsGoal(ctx, "john-1234", SavingsGoalRequest{Name: " car ", TargetAmount: 1000, TargetDate: targetDate, WalletId: "w-1"})
+	if err != nil {
+		t.Fatalf("Expected success, but got error: %v", err)
+	}
+	if goal.Goal.Currency != "EUR" || goal.Goal.Name != "car" {
+		t.Fatalf("Expected the wallet currency and a trimmed name, got %+v", goal.Goal)
+	}
+
+	tests := []struct {
+		name          string
+		req           SavingsContributionRequest
+		expectedMsg   string
+		expectedSaved float64
+	}{
+		{name: "Fail - Zero Amount", req: SavingsContributionRequest{Amount: 0}, expectedMsg: "greater than zero"},
+		{name: "Fail - Unknown Type", req: SavingsContributionRequest{Type: "gift", Amount: 10}, expectedMsg: "Contribution type must be"},
+		{name: "Fail - Withdraw More Than Saved", req: SavingsContributionRequest{Type: CONTRIBUTION_WITHDRAWAL, Amount: 10}, expectedMsg: "only 0.00 is saved"},
+		{name: "Success - Contribution", req: SavingsContributionRequest{Amount: 100.1}, expectedSaved: 100.1},
+		{name: "Success - Withdrawal", req: SavingsContributionRequest{Type: CONTRIBUTION_WITHDRAWAL, Amount: 30.05}, expectedSaved: 70.05},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p, err := bt.AddSavingsContribution(ctx, "john-1234", goal.Goal.ID, tt.req)
+			if tt.expectedMsg != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.expectedMsg) {
+					t.Fatalf("Expected error containing %q, got %v", tt.expectedMsg, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Expected success, but got error: %v", err)
+			}
+			if p.Goal.Saved != tt.expectedSaved {
+				t.Errorf("Expected %v saved, got %v", tt.expectedSaved, p.Goal.Saved)
+			}
+			if !p.WalletBalanceShort {
+				t.Errorf("Expected the wallet balance of 50 to be short of %v", p.Goal.Saved)
+			}
+		})
+	}
+
+	_, history, err := bt.GetSavingsGoalHistory(ctx, "john-1234", goal.Goal.ID)
+	if err != nil {
+		t.Fatalf("Expected success, but got error: %v", err)
+	}
+	if len(history) != 2 || history[0].Saved != 100.1 || history[1].Contribution.Amount != -30.05 || history[1].Saved != 70.05 {
+		t.Errorf("Expected a running saved amount over 2 entries, got %+v", history)
+	}
+}
+
+func TestDebtAmortization(t *testing.T) {
+	first := time.Date(2025, 1, 31, 0, 0, 0, 0, time.UTC)
+	loan := Debt{Principal: 10000, InterestRate: 12, TermMonths: 12, FirstPaymentAt: first}
+
+	payment := scheduledPayment(loan.Principal, loan.InterestRate, loan.TermMonths)
+	if payment != 888.49 {
+		t.Fatalf("Expected a scheduled payment of 888.49, got %v", payment)
+	}
+	rows, err := amortize(loan, loan.Principal, payment, 0, 0)
+	if err != nil {
+		t.Fatalf("Expected success, but got error: %v", err)
+	}
+	if len(rows) != 12 || rows[0].Interest != 100 || rows[0].Principal != 788.49 || rows[11].Balance != 0 {
+		t.Errorf("Expected 12 payments starting with 100 interest and ending at zero, got %d rows, first %+v, last %+v", len(rows), rows[0], rows[len(rows)-1])
+	}
+	if !rows[1].DueAt.Equal(time.Date(2025, 2, 28, 0, 0, 0, 0, time.UTC)) || !rows[2].DueAt.Equal(time.Date(2025, 3, 31, 0, 0, 0, 0, time.UTC)) {
+		t.Errorf("Expected payments on the last day of short months, got %v and %v", rows[1].DueAt, rows[2].DueAt)
+	}
+	var principal int64
+	for _, row := range rows {
+		principal += toCents(row.Principal)
+	}
+	if principal != 1000000 || rows[11].Payment > payment {
+		t.Errorf("Expected the principal to add up to 10000 and a smaller last payment, got %v and %v", float64(principal)/100, rows[11].Payment)
+	}
+
+	extraRows, err := amortize(loan, loan.Principal, payment, 500, 0)
+	if err != nil {
+		t.Fatalf("Expected success, but got error: %v", err)
+	}
+	if len(extraRows) != 8 || rowsInterest(extraRows) >= rowsInterest(rows) {
+		t.Errorf("Expected 8 payments and less interest with 500 extra, got %d and %v", len(extraRows), rowsInterest(extraRows))
+	}
+
+	iou := Debt{Principal: 1000, TermMonths: 3, FirstPaymentAt: first}
+	rows, err = amortize(iou, iou.Principal, scheduledPayment(iou.Principal, 0, 3), 0, 0)
+	if err != nil || len(rows) != 3 || rows[0].Payment != 333.34 || rows[2].Payment != 333.32 || rows[2].Interest != 0 {
+		t.Errorf("Expected 333.34, 333.34 and 333.32 without interest, got %+v, %v", rows, err)
+	}
+
+	if _, err := amortize(loan, loan.Principal, 50, 0, 0); err == nil || !strings.Contains(err.Error(), "does not cover the interest") {
+		t.Errorf("Expected an error for a payment below the interest, got %v", err)
+	}
+}
+
+func TestDebtPayments(t *testing.T) {
+	mockStore := &MockStorage{
+		ExpenseCategories: []ExpenseCategoryResponse{{ID: "loan", Name: "loan"}, {ID: "interest", Name: "interest"}},
+	}
+	bt := &BudgetTracker{storage: mockStore}
+	ctx := context.Background()
+	first := time.Date(2025, 1, 15, 0, 0, 0, 0, time.UTC)
+
+	if _, err := bt.SaveDebt(ctx, "john-1234", DebtRequest{Name: "car", Principal: 1200, InterestRate: 12, TermMonths: 12, FirstPaymentAt: first, Currency: "usd", CategoryId: "missing"}); err == nil || !strings.Contains(err.Error(), "does not exist") {
+		t.Fatalf("Expected a missing category error, got %v", err)
+	}
+	debt, err := bt.SaveDebt(ctx, "john-1234", DebtRequest{Name: "car", Principal: 1200, InterestRate: 12, TermMonths: 12, FirstPaymentAt: first, Currency: "usd", CategoryId: "loan", InterestCategoryId: "interest"})
+	if err != nil {
+		t.Fatalf("Expected success, but got error: %v", err)
+	}
+	if debt.ScheduledPayment != 106.62 || debt.PaymentsLeft != 12 || debt.Debt.Currency != "USD" {
+		t.Fatalf("Expected 12 payments of 106.62 in USD, got %+v", debt)
+	}
+
+	tests := []struct {
+		name              string
+		req               DebtPaymentRequest
+		expectedMsg       string
+		expectedPrincipal float64
+		expectedInterest  float64
+		expectedSplits    int
+	}{
+		{name: "Fail - More Than Payoff", req: DebtPaymentRequest{Amount: 5000, OccurredAt: first}, expectedMsg: "more than the payoff amount of 1212.00"},
+		{name: "Success - Scheduled Payment On Time", req: DebtPaymentRequest{OccurredAt: first}, expectedPrincipal: 94.62, expectedInterest: 12, expectedSplits: 2},
+		{name: "Success - Early Payment Of Next Due Date", req: DebtPaymentRequest{Amount: 100, OccurredAt: first.AddDate(0, 0, 5)}, expectedPrincipal: 88.95, expectedInterest: 11.05, expectedSplits: 2},
+		{name: "Success - Extra Payment Without Interest", req: DebtPaymentRequest{Amount: 50, OccurredAt: first.AddDate(0, 1, 0)}, expectedPrincipal: 50, expectedInterest: 0, expectedSplits: 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			payment, _, err := bt.AddDebtPayment(ctx, "john-1234", debt.Debt.ID, tt.req)
+			if tt.expectedMsg != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.expectedMsg) {
+					t.Fatalf("Expected error containing %q, got %v", tt.expectedMsg, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Expected success, but got error: %v", err)
+			}
+			if payment.Principal != tt.expectedPrincipal || payment.Interest != tt.expectedInterest {
+				t.Errorf("Expected %v principal and %v interest, got %v and %v", tt.expectedPrincipal, tt.expectedInterest, payment.Principal, payment.Interest)
+			}
+			saved := mockStore.SavedTransactions[len(mockStore.SavedTransactions)-1]
+			if saved.ID != payment.TransactionId || len(saved.Splits) != tt.expectedSplits || saved.CategoryType != "-" || saved.CategoryId != "loan" {
+				t.Errorf("Expected an expense in loan with %d splits, got %+v", tt.expectedSplits, saved)
+			}
+		})
+	}
+
+	status, err := bt.GetDebt(ctx, "john-1234", debt.Debt.ID)
+	if err != nil {
+		t.Fatalf("Expected success, but got error: %v", err)
+	}
+	if status.Balance != 966.43 || !status.NextPaymentAt.Equal(first.AddDate(0, 2, 0)) {
+		t.Errorf("Expected 966.43 left with the next payment in March, got %v on %v", status.Balance, status.NextPaymentAt)
+	}
+
+	schedule, err := bt.GetDebtSchedule(ctx, "john-1234", debt.Debt.ID, 100)
+	if err != nil {
+		t.Fatalf("Expected success, but got error: %v", err)
+	}
+	if schedule.Rows[0].Number != 3 || schedule.PaymentsSaved <= 0 || schedule.InterestSaved <= 0 {
+		t.Errorf("Expected the schedule to start at payment 3 and to save with extra payments, got %+v", schedule)
+	}
+}
+
+func TestDebtPayoffPlan(t *testing.T) {
+	now := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	statuses := func() []DebtStatus {
+		return []DebtStatus{
+			{Debt: Debt{Name: "card", InterestRate: 20}, Balance: 3000, ScheduledPayment: 150},
+			{Debt: Debt{Name: "friend", InterestRate: 0}, Balance: 1000, ScheduledPayment: 100},
+		}
+	}
+
+	snowball, err := planDebtPayoff(statuses(), DEBT_SNOWBALL, 200, now)
+	if err != nil {
+		t.Fatalf("Expected success, but got error: %v", err)
+	}
+	avalanche, err := planDebtPayoff(statuses(), DEBT_AVALANCHE, 200, now)
+	if err != nil {
+		t.Fatalf("Expected success, but got error: %v", err)
+	}
+
+	if snowball.Debts[0].Debt.Name != "friend" || avalanche.Debts[0].Debt.Name != "card" {
+		t.Errorf("Expected snowball to start with friend and avalanche with card, got %s and %s", snowball.Debts[0].Debt.Name, avalanche.Debts[0].Debt.Name)
+	}
+	if avalanche.TotalInterest >= snowball.TotalInterest {
+		t.Errorf("Expected avalanche to pay less interest, got %v and snowball %v", avalanche.TotalInterest, snowball.TotalInterest)
+	}
+	if snowball.Debts[0].Months != 4 || snowball.InterestSaved <= 0 || snowball.MonthsSaved <= 0 {
+		t.Errorf("Expected friend paid off in 4 months and savings from the extra money, got %+v", snowball)
+	}
+	if !snowball.PayoffDate.Equal(now.AddDate(0, snowball.Months, 0)) {
+		t.Errorf("Expected the payoff date %d months from now, got %v", snowball.Months, snowball.PayoffDate)
+	}
+
+	if _, err := planDebtPayoff([]DebtStatus{{Debt: Debt{InterestRate: 20}, Balance: 3000, ScheduledPayment: 10}}, DEBT_AVALANCHE, 0, now); err == nil || !strings.Contains(err.Error(), "not paid off within") {
+		t.Errorf("Expected an error for a debt that is never paid off, got %v", err)
+	}
+}
+
+func TestDetectSubscriptions(t *testing.T) {
+	now := time.Date(2025, 7, 10, 0, 0, 0, 0, time.UTC)
+	var history []Transaction
+	charge := func(note string, categoryId string, amount float64, at time.Time) {
+		history = append(history, Transaction{ID: fmt.Sprintf("t-%d", len(history)), CategoryId: categoryId, CategoryType: "-", Amount: amount, Currency: "USD", OccurredAt: at, Note: note})
+	}
+	for m := 0; m < 7; m++ {
+		amount := 10.99
+		if m == 6 {
+			amount = 12.99
+		}
+		charge(fmt.Sprintf("NETFLIX.COM %d", 8800+m), "fun", amount, time.Date(2025, time.Month(1+m), 5, 9, 0, 0, 0, time.UTC))
+		charge("Apple Services", "fun", 0.99, time.Date(2025, time.Month(1+m), 2, 0, 0, 0, 0, time.UTC))
+		charge("APPLE.COM/BILL", "fun", 9.99, time.Date(2025, time.Month(1+m), 3, 0, 0, 0, 0, time.UTC))
+	}
+	charge("Domain renewal example", "web", 15, time.Date(2024, 7, 1, 0, 0, 0, 0, time.UTC))
+	charge("Domain renewal example", "web", 15, time.Date(2025, 7, 1, 0, 0, 0, 0, time.UTC))
+	for _, d := range []int{1, 3, 10, 11, 25} {
+		charge("Market", "food", float64(40+d), time.Date(2025, 6, d, 0, 0, 0, 0, time.UTC))
+	}
+	for m := 9; m <= 12; m++ {
+		charge("Gym club", "gym", 30, time.Date(2024, time.Month(m), 1, 0, 0, 0, 0, time.UTC))
+	}
+	charge("Salary", "job", 1000, time.Date(2025, 6, 1, 0, 0, 0, 0, time.UTC))
+	history[len(history)-1].CategoryType = "+"
+
+	recurring := []RecurringTransaction{{ID: "r-1", CategoryId: "gym", CategoryType: "-", Amount: 30, Currency: "USD"}}
+	detected := detectSubscriptions(history, recurring, now)
+
+	var names []string
+	for _, s := range detected {
+		names = append(names, fmt.Sprintf("%s/%s/%v", s.Name, s.Period, s.Amount))
+	}
+	expected := []string{"NETFLIX.COM 8806/monthly/12.99", "APPLE.COM/BILL/monthly/9.99", "Domain renewal example/yearly/15", "Apple Services/monthly/0.99", "Gym club/monthly/30"}
+	if !slices.Equal(names, expected) {
+		t.Fatalf("Expected %v, got %v", expected, names)
+	}
+
+	netflix := detected[0]
+	if netflix.Charges != 7 || !netflix.PriceChanged || len(netflix.PriceChanges) != 1 || netflix.PriceChanges[0].Percent != 18.2 {
+		t.Errorf("Expected 7 charges with an 18.2%% price rise on the latest one, got %+v", netflix)
+	}
+	if !netflix.NextExpectedAt.Equal(time.Date(2025, 8, 5, 9, 0, 0, 0, time.UTC)) || netflix.MonthlyCost != 12.99 || !netflix.Active {
+		t.Errorf("Expected an active subscription next charged on Aug 5, got %v", netflix.NextExpectedAt)
+	}
+	if detected[1].ID == detected[3].ID {
+		t.Errorf("Expected the two Apple subscriptions to have different IDs, got %s", detected[1].ID)
+	}
+	if detected[2].MonthlyCost != 1.25 {
+		t.Errorf("Expected a yearly charge of 15 to cost 1.25 a month, got %v", detected[2].MonthlyCost)
+	}
+	if gym := detected[4]; gym.Active || gym.RecurringId != "r-1" {
+		t.Errorf("Expected the gym to be inactive and tracked by r-1, got %+v", gym)
+	}
+	if again := detectSubscriptions(history, recurring, now); again[0].ID != netflix.ID {
+		t.Errorf("Expected stable IDs, got %s and %s", netflix.ID, again[0].ID)
+	}
+}
+
+func TestConvertSubscription(t *testing.T) {
+	today := time.Now().UTC()
+	now := time.Date(today.Year(), today.Month(), 1, 0, 0, 0, 0, time.UTC) // month ends would shift AddDate
+	mockStore := &MockStorage{}
+	for m := 4; m >= 0; m-- {
+		mockStore.History = append(mockStore.History, Transaction{ID: fmt.Sprintf("t-%d", m), CategoryId: "fun", CategoryType: "-", Amount: 7.5, Currency: "EUR", OccurredAt: now.AddDate(0, -m, 0), Note: "Spotify AB"})
+	}
+	bt := &BudgetTracker{storage: mockStore}
+	ctx := context.Background()
+
+	detected, err := bt.DetectSubscriptions(ctx, "john-1234", 0)
+	if err != nil || len(detected) != 1 {
+		t.Fatalf("Expected one subscription, got %v, %v", detected, err)
+	}
+
+	if _, err := bt.ConvertSubscription(ctx, "john-1234", "missing", 0); err == nil || !strings.Contains(err.Error(), "Subscription not found") {
+		t.Errorf("Expected a not found error, got %v", err)
+	}
+	r, err := bt.ConvertSubscription(ctx, "john-1234", detected[0].ID, 0)
+	if err != nil {
+		t.Fatalf("Expected success, but got error: %v", err)
+	}
+	if r.Rule != "FREQ=MONTHLY" || r.Amount != 7.5 || r.CategoryId != "fun" || !r.StartAt.Equal(now.AddDate(0, 1, 0)) {
+		t.Errorf("Expected a monthly 7.5 template starting next month, got %+v", r)
+	}
+	if _, err := bt.ConvertSubscription(ctx, "john-1234", detected[0].ID, 0); err == nil || !strings.Contains(err.Error(), "already tracked") {
+		t.Errorf("Expected the second conversion to conflict, got %v", err)
+	}
+}
+
+func TestMatchPayee(t *testing.T) {
+	payees := []Payee{
+		{ID: "amazon", Name: "Amazon", Aliases: []PayeeAlias{{Pattern: "AMZN Mktp", Match: PAYEE_MATCH_PREFIX}, {Pattern: "amazon.com", Match: PAYEE_MATCH_CONTAINS}}},
+		{ID: "prime", Name: "Amazon Prime", Aliases: []PayeeAlias{{Pattern: "prime video", Match: PAYEE_MATCH_CONTAINS}}},
+		{ID: "starbucks", Name: "Starbucks"},
+		{ID: "uber", Name: "Uber", Aliases: []PayeeAlias{{Pattern: `^uber\s*\*\s*(trip|eats)`, Match: PAYEE_MATCH_REGEX}}},
+		{ID: "shell", Name: "Shell", Aliases: []PayeeAlias{{Pattern: "SHELL 0042", Match: PAYEE_MATCH_EXACT}}},
+	}
+
+	tests := []struct {
+		note     string
+		expected string
+	}{
+		{"AMZN Mktp US*2K4", "amazon"},
+		{"amzn  mktp de", "amazon"},
+		{"Order at AMAZON.COM", "amazon"},
+		{"Prime Video amazon.com", "prime"}, // the longer contains pattern wins
+		{"STARBUCKS STORE 0412", "starbucks"},
+		{"UBER *TRIP HELP.UBER.COM", "uber"},
+		{"shell 0042", "shell"},
+		{"SHELL 0042 TOP UP", "shell"}, // not exact, the name still matches
+		{"Shellfish bar", ""},
+		{"Coffee", ""},
+		{"", ""},
+	}
+
+	for _, tt := range tests {
+		p, ok := matchPayee(payees, tt.note)
+		if ok != (tt.expected != "") || p.ID != tt.expected {
+			t.Errorf("Note %q: expected payee %q, got %q", tt.note, tt.expected, p.ID)
+		}
+	}
+}
+
+func TestSaveTransactionPayee(t *testing.T) {
+	mockStore := &MockStorage{ExpenseCategories: []ExpenseCategoryResponse{{ID: "food", Name: "Food"}}}
+	bt := &BudgetTracker{storage: mockStore}
+	ctx := context.Background()
+
+	if _, err := bt.SavePayee(ctx, "john-1234", PayeeRequest{Name: "Uber", Aliases: []PayeeAlias{{Pattern: "uber*(", Match: PAYEE_MATCH_REGEX}}}); err == nil {
+		t.Errorf("Expected error for an invalid regular expression")
+	}
+	if _, err := bt.SavePayee(ctx, "john-1234", PayeeRequest{Name: "Bravo", DefaultCategoryId: "fun", DefaultCategoryType: "-"}); err == nil {
+		t.Errorf("Expected error for a missing default category")
+	}
+	payee, err := bt.SavePayee(ctx, "john-1234", PayeeRequest{
+		Name:                "  Bravo   Market ",
+		DefaultCategoryId:   "food",
+		DefaultCategoryType: "-",
+		Aliases:             []PayeeAlias{{Pattern: " BRAVO SM "}, {Pattern: "bravo sm", Match: PAYEE_MATCH_CONTAINS}},
+	})
+	if err != nil {
+		t.Fatalf("Expected success, but got error: %v", err)
+	}
+	if payee.Name != "Bravo Market" || len(payee.Aliases) != 1 || payee.Aliases[0].Match != PAYEE_MATCH_CONTAINS {
+		t.Fatalf("Expected a trimmed payee with one contains alias, got %+v", payee)
+	}
+
+	// matched from the note, the default category fills the missing one
+	if err := bt.SaveTransaction(ctx, "john-1234", TransactionRequest{CategoryType: "-", Amount: 12.5, Currency: "USD", Note: "POS BRAVO SM 0117 BAKU"}); err != nil {
+		t.Fatalf("Expected success, but got error: %v", err)
+	}
+	saved := mockStore.SavedTransactions[0]
+	if saved.PayeeId != payee.ID || saved.CategoryId != "food" {
+		t.Errorf("Expected the payee and its default category, got %+v", saved)
+	}
+
+	// the given category wins, the default only applies to its type
+	if err := bt.SaveTransaction(ctx, "john-1234", TransactionRequest{CategoryId: "ts-1", CategoryType: "-", Amount: 3, Currency: "USD", Note: "Bravo market"}); err != nil {
+		t.Fatalf("Expected success, but got error: %v", err)
+	}
+	if saved := mockStore.SavedTransactions[1]; saved.PayeeId != payee.ID || saved.CategoryId != "ts-1" {
+		t.Errorf("Expected the given category to be kept, got %+v", saved)
+	}
+	err = bt.SaveTransaction(ctx, "john-1234", TransactionRequest{CategoryType: "+", Amount: 3, Currency: "USD", Note: "Bravo SM refund"})
+	if err == nil || !strings.Contains(err.Error(), "Category ID cannot be empty!") {
+		t.Errorf("Expected the expense default category not to be used for an income, got %v", err)
+	}
+
+	if err := bt.SaveTransaction(ctx, "john-1234", TransactionRequest{CategoryId: "ts-1", CategoryType: "-", Amount: 3, Currency: "USD", PayeeId: "missing"}); err == nil {
+		t.Errorf("Expected error for a missing payee")
+	}
+	if err := bt.SaveTransaction(ctx, "john-1234", TransactionRequest{CategoryId: "ts-1", CategoryType: "-", Amount: 3, Currency: "USD", Note: "Corner shop"}); err != nil {
+		t.Fatalf("Expected success, but got error: %v", err)
+	}
+	if saved := mockStore.SavedTransactions[2]; saved.PayeeId != "" {
+		t.Errorf("Expected no payee for an unknown merchant, got %q", saved.PayeeId)
+	}
+}
+
+func TestPayeeStatsAndLinking(t *testing.T) {
+	now := time.Now().UTC()
+	month := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, time.UTC)
+	mockStore := &MockStorage{
+		Payees: map[string]Payee{
+			"p-1": {ID: "p-1", Name: "Netflix", CreatedBy: "john-1234"},
+			"p-2": {ID: "p-2", Name: "Bolt", Aliases: []PayeeAlias{{Pattern: "bolt.eu", Match: PAYEE_MATCH_CONTAINS}}, CreatedBy: "john-1234"},
+		},
+		History: []Transaction{
+			{ID: "t-1", PayeeId: "p-1", CategoryType: "-", Amount: 10.99, Currency: "USD", OccurredAt: month},
+			{ID: "t-2", PayeeId: "p-1", CategoryType: "-", Amount: 12.99, Currency: "USD", OccurredAt: month.AddDate(0, -1, 3)},
+			{ID: "t-3", PayeeId: "p-1", CategoryType: "-", Amount: 9.99, Currency: "USD", OccurredAt: month.AddDate(0, -2, 0)},
+			{ID: "t-4", PayeeId: "p-2", CategoryType: "-", Amount: 4, Currency: "EUR", OccurredAt: month},
+			{ID: "t-5", CategoryType: "-", Amount: 6, Currency: "EUR", OccurredAt: month, Note: "BOLT.EU/O/2501"},
+			{ID: "t-6", PayeeId: "p-1", CategoryType: "-", Amount: 5, Currency: "EUR", OccurredAt: month, Note: "bolt.eu"},
+		},
+	}
+	bt := &BudgetTracker{storage: mockStore}
+	ctx := context.Background()
+
+	stats, err := bt.GetPayeeStats(ctx, "john-1234", "", 2)
+	if err != nil {
+		t.Fatalf("Expected success, but got error: %v", err)
+	}
+	var got []string
+	for _, s := range stats {
+		got = append(got, fmt.Sprintf("%s/%s/%d/%v/%v/%d", s.Name, s.Currency, s.Count, s.Total, s.Average, len(s.Months)))
+	}
+	expected := []string{"Netflix/USD/2/23.98/11.99/2", "Netflix/EUR/1/5/5/1", "Bolt/EUR/1/4/4/1"}
+	if !slices.Equal(got, expected) {
+		t.Fatalf("Expected %v, got %v", expected, got)
+	}
+	if netflix := stats[0]; netflix.Largest != 12.99 || !netflix.LastAt.Equal(month) || netflix.Months[0].Total != 12.99 {
+		t.Errorf("Expected the latest charge and the monthly totals oldest first, got %+v", netflix)
+	}
+
+	if _, err := bt.GetPayeeStats(ctx, "john-1234", "missing", 0); err == nil {
+		t.Errorf("Expected error for a missing payee")
+	}
+	if _, err := bt.GetPayeeStats(ctx, "john-1234", "", MAX_PAYEE_STATS_MONTHS+1); err == nil {
+		t.Errorf("Expected error for too many months")
+	}
+
+	// only the transaction without a payee is linked
+	linked, err := bt.LinkPayeeTransactions(ctx, "john-1234", "p-2")
+	if err != nil {
+		t.Fatalf("Expected success, but got error: %v", err)
+	}
+	if linked != 1 || mockStore.PayeeLinks["t-5"] != "p-2" {
+		t.Errorf("Expected t-5 to be linked, got %d %v", linked, mockStore.PayeeLinks)
+	}
+}
+
+func TestReceiptPayee(t *testing.T) {
+	receipt := "\n  ** WELCOME **\n12/05/2026 14:32\nBRAVO   SUPERMARKET\nBread 1.20\nTOTAL 1.20\n"
+
+	name, id := receiptPayee(nil, receipt)
+	if name != "Bravo Supermarket" || id != "" {
+		t.Errorf("Expected the header line as the payee, got %q %q", name, id)
+	}
+
+	payees := []Payee{{ID: "p-1", Name: "Bravo", Aliases: []PayeeAlias{{Pattern: "bravo supermarket", Match: PAYEE_MATCH_EXACT}}}}
+	name, id = receiptPayee(payees, receipt)
+	if name != "Bravo" || id != "p-1" {
+		t.Errorf("Expected the matching payee, got %q %q", name, id)
+	}
+
+	if name, _ := receiptPayee(nil, "12/05/2026\nBread 1.20\n"); name != "" {
+		t.Errorf("Expected no payee, got %q", name)
+	}
+}
+
+func TestRunRules(t *testing.T) {
+	created := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	rules := compileRules([]Rule{
+		{ID: "late", Priority: 20, Enabled: true, CreatedAt: created, Conditions: RuleConditions{NotePattern: "coffee"}, Actions: RuleActions{CategoryId: "drinks", Tags: []string{"coffee"}}},
+		{ID: "early", Priority: 10, Enabled: true, CreatedAt: created, Conditions: RuleConditions{CategoryType: "-", PayeePattern: "^starbucks$", AmountMax: 10}, Actions: RuleActions{CategoryId: "food", Tags: []string{"cafe"}}},
+		{ID: "off", Priority: 0, Enabled: false, CreatedAt: created, Conditions: RuleConditions{NotePattern: "."}, Actions: RuleActions{Note: "never"}},
+		{ID: "weekend", Priority: 30, Enabled: true, CreatedAt: created, Conditions: RuleConditions{Currency: "USD", Weekdays: []time.Weekday{time.Saturday, time.Sunday}}, Actions: RuleActions{Note: "Weekend"}},
+		{ID: "broken", Priority: 5, Enabled: true, CreatedAt: created, Conditions: RuleConditions{NotePattern: "(("}, Actions: RuleActions{Note: "broken"}},
+	}, map[string]bool{"drinks": true, "food": true})
+	if len(rules) != 3 || rules[0].ID != "early" {
+		t.Fatalf("Expected the enabled valid rules by priority, got %d", len(rules))
+	}
+
+	saturday := time.Date(2026, 3, 7, 9, 0, 0, 0, time.UTC)
+	out := runRules(rules, ruleSubject{CategoryType: "-", Note: "Coffee to go", Payee: "Starbucks", Amount: 4.5, Currency: "USD", OccurredAt: saturday})
+	if out.CategoryId != "food" || out.Note != "Weekend" || !slices.Equal(out.Tags, []string{"cafe", "coffee"}) || !slices.Equal(out.RuleIds, []string{"early", "late", "weekend"}) {
+		t.Errorf("Expected the first category and all tags, got %+v", out)
+	}
+
+	// over the amount range and on a weekday only the note rule matches
+	out = runRules(rules, ruleSubject{CategoryType: "-", Note: "COFFEE beans", Payee: "Starbucks", Amount: 10.01, Currency: "usd", OccurredAt: saturday.AddDate(0, 0, 2)})
+	if out.CategoryId != "drinks" || out.Note != "" || !slices.Equal(out.RuleIds, []string{"late"}) {
+		t.Errorf("Expected only the note rule, got %+v", out)
+	}
+}
+
+func TestSaveTransactionRules(t *testing.T) {
+	mockStore := &MockStorage{
+		ExpenseCategories: []ExpenseCategoryResponse{{ID: "food", Name: "Food"}, {ID: "groceries", Name: "Groceries"}},
+		Payees: map[string]Payee{
+			"p-1": {ID: "p-1", Name: "Bravo", DefaultCategoryId: "food", DefaultCategoryType: "-", CreatedBy: "john-1234"},
+		},
+	}
+	bt := &BudgetTracker{storage: mockStore}
+	ctx := context.Background()
+
+	if _, err := bt.SaveRule(ctx, "john-1234", RuleRequest{Name: "Empty", Actions: RuleActions{Note: "x"}}); err == nil {
+		t.Errorf("Expected error for a rule without conditions")
+	}
+	if _, err := bt.SaveRule(ctx, "john-1234", RuleRequest{Name: "Untyped", Conditions: RuleConditions{NotePattern: "x"}, Actions: RuleActions{CategoryId: "food"}}); err == nil {
+		t.Errorf("Expected error for a category without a category type")
+	}
+	if _, err := bt.SaveRule(ctx, "john-1234", RuleRequest{Name: "Bad", Conditions: RuleConditions{NotePattern: "(("}, Actions: RuleActions{Note: "x"}}); err == nil {
+		t.Errorf("Expected error for an invalid pattern")
+	}
+	rule, err := bt.SaveRule(ctx, "john-1234", RuleRequest{
+		Name:       "Bravo weekly shop",
+		Enabled:    true,
+		Conditions: RuleConditions{CategoryType: "-", PayeePattern: "bravo", AmountMin: 50},
+		Actions:    RuleActions{CategoryId: "groceries", Tags: []string{"Weekly"}},
+	})
+	if err != nil {
+		t.Fatalf("Expected success, but got error: %v", err)
+	}
+	if len(mockStore.Tags) != 1 || !slices.Equal(rule.Actions.Tags, []string{"weekly"}) {
+		t.Errorf("Expected the rule tag to be normalized and created, got %v", rule.Actions.Tags)
+	}
+
+	// the rule wins over the payee default
+	if err := bt.SaveTransaction(ctx, "john-1234", TransactionRequest{CategoryType: "-", Amount: 72, Currency: "USD", Note: "BRAVO 0117", Tags: []string{"family"}}); err != nil {
+		t.Fatalf("Expected success, but got error: %v", err)
+	}
+	saved := mockStore.SavedTransactions[0]
+	if saved.CategoryId != "groceries" || saved.PayeeId != "p-1" || !slices.Equal(saved.Tags, []string{"family", "weekly"}) {
+		t.Errorf("Expected the rule category and tags, got %+v", saved)
+	}
+
+	// below the amount the payee default applies
+	if err := bt.SaveTransaction(ctx, "john-1234", TransactionRequest{CategoryType: "-", Amount: 3, Currency: "USD", Note: "Bravo"}); err != nil {
+		t.Fatalf("Expected success, but got error: %v", err)
+	}
+	if saved := mockStore.SavedTransactions[1]; saved.CategoryId != "food" || len(saved.Tags) != 0 {
+		t.Errorf("Expected the payee default category, got %+v", saved)
+	}
+
+	// an explicit category is left alone
+	if err := bt.SaveTransaction(ctx, "john-1234", TransactionRequest{CategoryId: "food", CategoryType: "-", Amount: 90, Currency: "USD", Note: "Bravo"}); err != nil {
+		t.Fatalf("Expected success, but got error: %v", err)
+	}
+	if saved := mockStore.SavedTransactions[2]; saved.CategoryId != "food" || len(saved.Tags) != 0 {
+		t.Errorf("Expected the given category without rule tags, got %+v", saved)
+	}
+
+	// once its category is archived the rule only adds its tags
+	mockStore.ExpenseCategories[1].ArchivedAt = time.Now()
+	if err := bt.SaveTransaction(ctx, "john-1234", TransactionRequest{CategoryType: "-", Amount: 72, Currency: "USD", Note: "BRAVO 0118"}); err != nil {
+		t.Fatalf("Expected success, but got error: %v", err)
+	}
+	if saved := mockStore.SavedTransactions[3]; saved.CategoryId != "food" || !slices.Equal(saved.Tags, []string{"weekly"}) {
+		t.Errorf("Expected the payee default category with the rule tags, got %+v", saved)
+	}
+	_, err = bt.SaveRule(ctx, "john-1234", RuleRequest{Name: "Archived", Conditions: RuleConditions{CategoryType: "-", NotePattern: "x"}, Actions: RuleActions{CategoryId: "groceries"}})
+	if err == nil {
+		t.Errorf("Expected error for an archived rule category")
+	}
+}
+
+func TestApplyRules(t *testing.T) {
+	day := time.Date(2026, 3, 2, 10, 0, 0, 0, time.UTC)
+	mockStore := &MockStorage{
+		ExpenseCategories: []ExpenseCategoryResponse{{ID: "food", Name: "Food"}, {ID: "taxi", Name: "Taxi"}},
+		Tags:              map[string]Tag{"g-1": {ID: "g-1", Name: "work", CreatedBy: "john-1234"}},
+		Rules: map[string]Rule{
+			"r-1": {ID: "r-1", Name: "Uber", Priority: 1, Enabled: true, CreatedBy: "john-1234", Conditions: RuleConditions{CategoryType: "-", NotePattern: "uber"}, Actions: RuleActions{CategoryId: "taxi", Tags: []string{"travel"}}},
+			"r-2": {ID: "r-2", Name: "Work", Priority: 2, Enabled: true, CreatedBy: "john-1234", Conditions: RuleConditions{Weekdays: []time.Weekday{time.Monday}}, Actions: RuleActions{Tags: []string{"work"}}},
+		},
+		History: []Transaction{
+			{ID: "t-1", CategoryId: "food", CategoryType: "-", Amount: 12, Currency: "USD", Note: "UBER trip", OccurredAt: day},
+			{ID: "t-2", CategoryId: "taxi", CategoryType: "-", Amount: 8, Currency: "USD", Note: "Uber", OccurredAt: day, Tags: []string{"travel", "work"}},
+			{ID: "t-3", CategoryId: "food", CategoryType: "-", Amount: 9, Currency: "USD", Note: "uber eats", OccurredAt: day, ReconciledAt: day},
+			{ID: "t-4", CategoryId: "food", CategoryType: "-", Amount: 20, Currency: "USD", Note: "Uber and lunch", OccurredAt: day, Splits: []TransactionSplit{{CategoryId: "food", Amount: 20}}},
+			{ID: "t-5", CategoryType: TRANSFER_TYPE, Amount: 50, Currency: "USD", Note: "uber card top up", OccurredAt: day},
+			{ID: "t-6", CategoryId: "food", CategoryType: "-", Amount: 5, Currency: "USD", Note: "Bakery", OccurredAt: day.AddDate(0, 0, 1)},
+		},
+	}
+	bt := &BudgetTracker{storage: mockStore}
+	ctx := context.Background()
+
+	report, err := bt.TestRule(ctx, "john-1234", RuleRequest{Name: "Uber", Conditions: RuleConditions{CategoryType: "-", NotePattern: "uber"}, Actions: RuleActions{CategoryId: "taxi"}})
+	if err != nil {
+		t.Fatalf("Expected success, but got error: %v", err)
+	}
+	if report.Matched != 4 || report.Skipped != 2 || report.Changed != 1 || len(report.Changes) != 2 {
+		t.Errorf("Expected 4 matched, 2 skipped and 1 changed, got %+v", report)
+	}
+
+	if _, err := bt.ApplyRules(ctx, "john-1234", []string{"missing"}, false); err == nil {
+		t.Errorf("Expected error for an unknown rule")
+	}
+	report, err = bt.ApplyRules(ctx, "john-1234", nil, true)
+	if err != nil {
+		t.Fatalf("Expected success, but got error: %v", err)
+	}
+	if report.Changed != 1 || len(mockStore.RuleChanges) != 0 {
+		t.Fatalf("Expected one change and nothing saved on a dry run, got %+v", report)
+	}
+
+	report, err = bt.ApplyRules(ctx, "john-1234", nil, false)
+	if err != nil {
+		t.Fatalf("Expected success, but got error: %v", err)
+	}
+	if len(mockStore.RuleChanges) != 1 {
+		t.Fatalf("Expected one saved change, got %+v", mockStore.RuleChanges)
+	}
+	change := mockStore.RuleChanges[0]
+	if change.TransactionId != "t-1" || change.CategoryId != "taxi" || !slices.Equal(change.AddedTags, []string{"travel", "work"}) || !slices.Equal(change.RuleIds, []string{"r-1", "r-2"}) {
+		t.Errorf("Expected t-1 to move to taxi with both tags, got %+v", change)
+	}
+	if len(mockStore.Tags) != 2 {
+		t.Errorf("Expected the missing tag to be created, got %v", mockStore.Tags)
+	}
+
+	// a rule whose category was deleted no longer moves transactions
+	mockStore.ExpenseCategories = mockStore.ExpenseCategories[:1]
+	mockStore.RuleChanges = nil
+	report, err = bt.ApplyRules(ctx, "john-1234", []string{"r-1"}, false)
+	if err != nil {
+		t.Fatalf("Expected success, but got error: %v", err)
+	}
+	for _, c := range report.Changes {
+		if c.CategoryId != c.OldCategoryId {
+			t.Errorf("Expected no category change, got %+v", c)
+		}
+	}
+}
+
+func TestImportStatementRules(t *testing.T) {
+	date := time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC)
+	mockStore := &MockStorage{
+		ExpenseCategories: []ExpenseCategoryResponse{{ID: "ts-1", Name: "Other"}, {ID: "food", Name: "Food"}},
+		Payees: map[string]Payee{
+			"p-1": {ID: "p-1", Name: "Bravo", DefaultCategoryId: "food", DefaultCategoryType: "-", CreatedBy: "john-1234"},
+		},
+		Rules: map[string]Rule{
+			"r-1": {ID: "r-1", Name: "Coffee", Enabled: true, CreatedBy: "john-1234", Conditions: RuleConditions{CategoryType: "-", NotePattern: "coffee"}, Actions: RuleActions{CategoryId: "food", Note: "Coffee", Tags: []string{"cafe"}}},
+			"r-2": {ID: "r-2", Name: "Gone", Priority: 1, Enabled: true, CreatedBy: "john-1234", Conditions: RuleConditions{CategoryType: "-", NotePattern: "plumber"}, Actions: RuleActions{CategoryId: "deleted"}},
+		},
+	}
+	bt := &BudgetTracker{storage: mockStore}
+	rows := []StatementRow{
+		{Index: 2, Date: date, Amount: -3.5, Currency: "USD", Description: "POS COFFEE HOUSE"},
+		{Index: 3, Date: date, Amount: -40, Currency: "USD", Description: "BRAVO 0117"},
+		{Index: 4, Date: date, Amount: -80, Currency: "USD", Description: "Plumber"},
+		{Index: 5, Date: date, Amount: -6, Currency: "USD", Description: "Coffee beans"},
+	}
+	mapping := StatementCategoryMapping{ExpenseCategoryId: "ts-1", Rows: map[int]string{5: "ts-1"}}
+
+	report, err := bt.ImportStatement(context.Background(), "john-1234", "bank.csv", rows, nil, mapping, false)
+	if err != nil {
+		t.Fatalf("Expected success, but got error: %v", err)
+	}
+	if report.Imported != 4 {
+		t.Fatalf("Expected 4 imported, got %+v", report)
+	}
+	saved := mockStore.ImportedBatch.Transactions
+	if tr := saved[0]; tr.CategoryId != "food" || tr.Note != "Coffee" || !slices.Equal(tr.Tags, []string{"cafe"}) {
+		t.Errorf("Expected the rule category, note and tags, got %+v", tr)
+	}
+	if tr := saved[1]; tr.CategoryId != "food" || tr.PayeeId != "p-1" {
+		t.Errorf("Expected the payee and its default category, got %+v", tr)
+	}
+	if tr := saved[2]; tr.CategoryId != "ts-1" || !slices.Equal(report.Rows[2].RuleIds, []string{"r-2"}) {
+		t.Errorf("Expected the mapping category when the rule category is gone, got %+v", tr)
+	}
+	// a listed row keeps its category, the rest of the rule still applies
+	if tr := saved[3]; tr.CategoryId != "ts-1" || tr.Note != "Coffee" {
+		t.Errorf("Expected the listed category to win, got %+v", tr)
+	}
+	if len(mockStore.Tags) != 1 {
+		t.Errorf("Expected the rule tag to be created, got %v", mockStore.Tags)
+	}
+}
+
+func suggestionHistory() []Transaction {
+	monday := time.Date(2026, 3, 2, 9, 0, 0, 0, time.UTC)
+	var history []Transaction
+	for i := 0; i < 6; i++ {
+		day := monday.AddDate(0, 0, 7*i)
+		history = append(history,
+			Transaction{ID: fmt.Sprintf("c-%d", i), CategoryId: "food", CategoryType: "-", Amount: 4.5, Currency: "USD", Note: "Starbucks coffee", OccurredAt: day},
+			Transaction{ID: fmt.Sprintf("u-%d", i), CategoryId: "taxi", CategoryType: "-", Amount: 14, Currency: "USD", Note: "Uber trip", OccurredAt: day.AddDate(0, 0, 5)},
+		)
+	}
+	return append(history,
+		Transaction{ID: "s-1", CategoryId: "salary", CategoryType: "+", Amount: 3000, Currency: "USD", Note: "ACME payroll", OccurredAt: monday},
+		Transaction{ID: "r-1", CategoryId: "food", CategoryType: "-", Amount: 30, Currency: "USD", Note: "Dinner and taxi", OccurredAt: monday, Splits: []TransactionSplit{
+			{CategoryId: "food", Amount: 18, Note: "Dinner"},
+			{CategoryId: "gone", Amount: 12, Note: "Taxi home"},
+		}},
+	)
+}
+
+func TestCategoryModel(t *testing.T) {
+	model := &userCategoryModel{types: map[string]*categoryModel{}}
+	for _, tr := range suggestionHistory() {
+		model.learnTransaction(tr)
+	}
+	if model.types["-"].examples["gone"] != 1 || model.types["+"].size != 1 {
+		t.Fatalf("Expected split lines to be learned on their own, got %+v", model.types["-"].examples)
+	}
+
+	saturday := time.Date(2026, 4, 4, 20, 0, 0, 0, time.UTC)
+	ranked := model.predict("-", categoryFeatures("UBER *TRIP 8812", 13, "USD", saturday))
+	if len(ranked) != 3 || ranked[0].CategoryId != "taxi" || ranked[0].Confidence < 0.9 {
+		t.Fatalf("Expected taxi first with high confidence, got %+v", ranked)
+	}
+	sum := 0.0
+	for _, s := range ranked {
+		sum += s.Confidence
+	}
+	if math.Abs(sum-1) > 1e-9 {
+		t.Errorf("Expected confidences to add up to 1, got %f", sum)
+	}
+
+	// without words the amount and weekday still point somewhere
+	ranked = model.predict("-", categoryFeatures("", 5, "USD", time.Date(2026, 4, 6, 8, 0, 0, 0, time.UTC)))
+	if ranked[0].CategoryId != "food" {
+		t.Errorf("Expected food for a small Monday payment, got %+v", ranked)
+	}
+	if ranked := model.predict(TRANSFER_TYPE, nil); ranked != nil {
+		t.Errorf("Expected no suggestions for transfers, got %+v", ranked)
+	}
+}
+
+func TestSuggestCategories(t *testing.T) {
+	mockStore := &MockStorage{
+		ExpenseCategories: []ExpenseCategoryResponse{{ID: "food", Name: "Food"}, {ID: "taxi", Name: "Taxi"}},
+		History:           suggestionHistory(),
+	}
+	bt := &BudgetTracker{storage: mockStore, categoryModels: newCategoryModels()}
+	ctx := context.Background()
+
+	if _, err := bt.SuggestCategories(ctx, "john-1234", TransactionRequest{CategoryType: TRANSFER_TYPE}, 3); err == nil {
+		t.Errorf("Expected error for a transfer")
+	}
+	if _, err := bt.SuggestCategories(ctx, "john-1234", TransactionRequest{CategoryType: "-"}, MAX_CATEGORY_SUGGESTIONS+1); err == nil {
+		t.Errorf("Expected error for a limit over the maximum")
+	}
+
+	suggestions, err := bt.SuggestCategories(ctx, "john-1234", TransactionRequest{CategoryType: "-", Note: "Coffee", Amount: 4, Currency: "USD"}, 0)
+	if err != nil {
+		t.Fatalf("Expected success, but got error: %v", err)
+	}
+	// the deleted category is never suggested
+	if len(suggestions) != 2 || suggestions[0].CategoryId != "food" || suggestions[0].CategoryName != "Food" || suggestions[0].Examples != 7 {
+		t.Fatalf("Expected food then taxi, got %+v", suggestions)
+	}
+
+	// a new merchant is learned incrementally, without training again
+	mockStore.History = nil
+	for i := 0; i < AUTO_CATEGORY_MIN_EXAMPLES; i++ {
+		if err := bt.SaveTransaction(ctx, "john-1234", TransactionRequest{CategoryId: "taxi", CategoryType: "-", Amount: 9, Currency: "USD", Note: "Bolt ride"}); err != nil {
+			t.Fatalf("Expected success, but got error: %v", err)
+		}
+	}
+	suggestions, err = bt.SuggestCategories(ctx, "john-1234", TransactionRequest{CategoryType: "-", Note: "BOLT.EU ride", Amount: 11, Currency: "USD"}, 1)
+	if err != nil {
+		t.Fatalf("Expected success, but got error: %v", err)
+	}
+	if len(suggestions) != 1 || suggestions[0].CategoryId != "taxi" || suggestions[0].Examples != 6+AUTO_CATEGORY_MIN_EXAMPLES {
+		t.Errorf("Expected the cached model to learn the new transactions, got %+v", suggestions)
+	}
+
+	// a confident suggestion categorizes a transaction sent without one
+	if err := bt.SaveTransaction(ctx, "john-1234", TransactionRequest{CategoryType: "-", Amount: 12, Currency: "USD", Note: "Uber trip home"}); err != nil {
+		t.Fatalf("Expected success, but got error: %v", err)
+	}
+	if saved := mockStore.SavedTransactions[len(mockStore.SavedTransactions)-1]; saved.CategoryId != "taxi" {
+		t.Errorf("Expected the suggested category, got %+v", saved)
+	}
+	err = bt.SaveTransaction(ctx, "john-1234", TransactionRequest{CategoryType: "-", Amount: 12, Currency: "USD"})
+	if err == nil || !strings.Contains(err.Error(), "Category ID cannot be empty!") {
+		t.Errorf("Expected no suggestion to be used without a note, got %v", err)
+	}
+
+	bt.forgetCategories("john-1234")
+	if _, ok := bt.categoryModels.users["john-1234"]; ok {
+		t.Errorf("Expected the model to be dropped")
+	}
+
+	// a model trained while the history changed is used once but not cached
+	mockStore.History = suggestionHistory()
+	bt.storage = changingHistoryStorage{MockStorage: mockStore, change: func() { bt.forgetCategories("john-1234") }}
+	if _, err := bt.SuggestCategories(ctx, "john-1234", TransactionRequest{CategoryType: "-", Note: "Coffee", Amount: 4, Currency: "USD"}, 0); err != nil {
+		t.Fatalf("Expected success, but got error: %v", err)
+	}
+	if _, ok := bt.categoryModels.users["john-1234"]; ok {
+		t.Errorf("Expected the stale model not to be cached")
+	}
+	if len(bt.categoryModels.trainings) != 0 {
+		t.Errorf("Expected no trainings to be tracked once they ended, got %d", len(bt.categoryModels.trainings))
+	}
+}
+
+// changingHistoryStorage changes the history while a model is trained.
+type changingHistoryStorage struct {
+	*MockStorage
+	change func()
+}
+
+func (s changingHistoryStorage) EachTransaction(ctx context.Context, userId string, fn func(Transaction) error) error {
+	s.change()
+	return s.MockStorage.EachTransaction(ctx, userId, fn)
+}
@@ -0,0 +1,170 @@
+package budget
+
+import (
+	"fmt"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode"
+
+	appErrors "github.com/fatali-fataliyev/budget_tracker/customErrors"
+	"github.com/google/uuid"
+)
+
+const (
+	MIN_TRANSACTION_SPLITS = 2
+	MAX_TRANSACTION_SPLITS = 50
+)
+
+// validateTransactionSplits checks the lines of a split transaction. The
+// parent has no category of its own, each line carries one, and the lines
+// must add up to the parent amount to the cent.
+func validateTransactionSplits(transaction TransactionRequest) error {
+	if transaction.CategoryId != "" {
+		return appErrors.ErrorResponse{
+			Code:    appErrors.ErrInvalidInput,
+			Message: "Category ID must be empty for a split transaction, every split has its own category.",
+		}
+	}
+	if len(transaction.Splits) < MIN_TRANSACTION_SPLITS || len(transaction.Splits) > MAX_TRANSACTION_SPLITS {
+		return appErrors.ErrorResponse{
+			Code:    appErrors.ErrInvalidInput,
+			Message: fmt.Sprintf("A split transaction must have between %d and %d splits.", MIN_TRANSACTION_SPLITS, MAX_TRANSACTION_SPLITS),
+		}
+	}
+
+	var total int64
+	for i, split := range transaction.Splits {
+		if split.CategoryId == "" {
+			return appErrors.ErrorResponse{
+				Code:    appErrors.ErrInvalidInput,
+				Message: fmt.Sprintf("Category ID of split %d cannot be empty!", i+1),
+			}
+		}
+		if split.Amount <= 0 || IsFloatZero(split.Amount) {
+			return appErrors.ErrorResponse{
+				Code:    appErrors.ErrInvalidInput,
+				Message: fmt.Sprintf("Amount of split %d must be greater than zero.", i+1),
+			}
+		}
+		if len(split.Note) > MAX_TRANSACTION_NOTE_LENGTH {
+			return appErrors.ErrorResponse{
+				Code:    appErrors.ErrInvalidInput,
+				Message: fmt.Sprintf("Note of split %d so long, maximum allowed note length is %d", i+1, MAX_TRANSACTION_NOTE_LENGTH),
+			}
+		}
+		total += toCents(split.Amount)
+	}
+
+	if total != toCents(transaction.Amount) {
+		return appErrors.ErrorResponse{
+			Code:    appErrors.ErrInvalidInput,
+			Message: fmt.Sprintf("Splits add up to %.2f but the transaction amount is %.2f.", float64(total)/100, transaction.Amount),
+		}
+	}
+	return nil
+}
+
+func newTransactionSplits(requests []TransactionSplitRequest) []TransactionSplit {
+	if len(requests) == 0 {
+		return nil
+	}
+	splits := make([]TransactionSplit, 0, len(requests))
+	for _, r := range requests {
+		splits = append(splits, TransactionSplit{
+			ID:         uuid.New().String(),
+			CategoryId: r.CategoryId,
+			Amount:     r.Amount,
+			Note:       r.Note,
+		})
+	}
+	return splits
+}
+
+func toCents(amount float64) int64 {
+	return int64(math.Round(amount * 100))
+}
+
+// expandSplits returns one transaction per split line with the line's
+// category and amount, or the transaction itself when it is not split. Lines
+// without a note of their own keep the parent's.
+func expandSplits(t Transaction) []Transaction {
+	if len(t.Splits) == 0 {
+		return []Transaction{t}
+	}
+	lines := make([]Transaction, 0, len(t.Splits))
+	for _, split := range t.Splits {
+		line := t
+		line.CategoryId = split.CategoryId
+		line.CategoryName = split.CategoryName
+		line.Amount = split.Amount
+		if split.Note != "" {
+			line.Note = split.Note
+		}
+		line.Splits = nil
+		lines = append(lines, line)
+	}
+	return lines
+}
+
+var (
+	receiptLineRegex  = regexp.MustCompile(`^(.*?\p{L}.*?)[\s.:*=]+(?:[^\s\d\p{L}]\s?)?(\d{1,3}(?:[ ,.]\d{3})*|\d+)[.,](\d{2})\s*(?:[A-Z]{3}|[^\s\d\p{L}]{1,2})?\s*$`)
+	receiptSkipWords  = []string{"subtotal", "sub total", "cash", "card", "change", "visa", "mastercard", "paid", "nağd", "nagd", "qalıq", "qaliq", "kart"}
+	receiptTotalWords = []string{"total", "sum", "cəmi", "cemi", "итого", "toplam", "gesamt", "summe", "amount due"}
+)
+
+// parseReceiptLines picks item lines and the total out of OCR'd receipt text.
+// A line counts as an item when it has a description followed by an amount
+// with two decimals, which filters out dates, quantities and phone numbers.
+// The total falls back to the sum of the items when the receipt has none.
+func parseReceiptLines(text string) ([]TransactionSplitRequest, float64) {
+	var lines []TransactionSplitRequest
+	var total, sum int64
+
+	for _, raw := range strings.Split(text, "\n") {
+		m := receiptLineRegex.FindStringSubmatch(strings.TrimSpace(raw))
+		if m == nil {
+			continue
+		}
+		whole := strings.NewReplacer(" ", "", ",", "", ".", "").Replace(m[2])
+		cents, err := strconv.ParseInt(whole+m[3], 10, 64)
+		if err != nil || cents == 0 {
+			continue
+		}
+
+		description := strings.TrimSpace(m[1])
+		words := receiptWords(description)
+		if containsAny(words, receiptSkipWords) {
+			continue
+		}
+		if containsAny(words, receiptTotalWords) {
+			total = cents
+			continue
+		}
+
+		lines = append(lines, TransactionSplitRequest{Amount: float64(cents) / 100, Note: description})
+		sum += cents
+	}
+
+	if total == 0 {
+		total = sum
+	}
+	return lines, float64(total) / 100
+}
+
+// receiptWords lowercases s and keeps only its words, space padded, so
+// keywords can be matched as whole words in any script.
+func receiptWords(s string) string {
+	words := strings.FieldsFunc(strings.ToLower(s), func(r rune) bool { return !unicode.IsLetter(r) })
+	return " " + strings.Join(words, " ") + " "
+}
+
+func containsAny(words string, keywords []string) bool {
+	for _, k := range keywords {
+		if strings.Contains(words, " "+k+" ") {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,284 @@
+package budget
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"strings"
+	"time"
+
+	appErrors "github.com/fatali-fataliyev/budget_tracker/customErrors"
+	"github.com/fatali-fataliyev/budget_tracker/internal/contextutil"
+	"github.com/fatali-fataliyev/budget_tracker/logging"
+	"github.com/google/uuid"
+)
+
+const MAX_STATEMENT_ROWS = 10000
+
+// statementTooLarge is returned by the parsers and ImportStatement when a
+// statement has more than MAX_STATEMENT_ROWS rows.
+func statementTooLarge() error {
+	return invalidMapping(fmt.Sprintf("Statement is too large, maximum allowed rows is %d", MAX_STATEMENT_ROWS))
+}
+
+// StatementRow is one parsed line of a bank statement, before it is turned
+// into a transaction.
+type StatementRow struct {
+	Index       int // 1-based row number in the source file
+	Date        time.Time
+	Amount      float64 // signed, negative means money going out
+	Currency    string
+	Description string
+	ExternalID  string // bank transaction ID such as OFX FITID, empty when the format has none
+}
+
+// StatementCategoryMapping decides the category of each row: money going out
+// goes to ExpenseCategoryId, money coming in to IncomeCategoryId, unless the
+// row index is listed in Rows. Without a listed category the user's rules and
+// then the default category of the row's payee come before these two.
+type StatementCategoryMapping struct {
+	ExpenseCategoryId string
+	IncomeCategoryId  string
+	Rows              map[int]string
+}
+
+type StatementPreviewRow struct {
+	StatementRow
+	CategoryId   string
+	CategoryType string
+	Note         string   // the description, unless a rule replaced it
+	Tags         []string // added by rules
+	PayeeId      string   // the payee the description matches
+	RuleIds      []string // the rules that matched the row
+	Duplicate    bool
+}
+
+type StatementImportReport struct {
+	DryRun     bool
+	Rows       []StatementPreviewRow
+	Imported   int
+	Duplicates int
+	Skipped    int
+	Errors     []ImportRowError
+}
+
+// ImportStatement maps parsed statement rows to categories and saves them in
+// one storage transaction. Rows that already exist in the account are
+// reported as duplicates and not saved again. With dryRun only the preview is
+// returned.
+func (bt *BudgetTracker) ImportStatement(ctx context.Context, userId string, source string, rows []StatementRow, parseErrors []ImportRowError, mapping StatementCategoryMapping, dryRun bool) (StatementImportReport, error) {
+	traceID := contextutil.TraceIDFromContext(ctx)
+
+	if len(rows) > MAX_STATEMENT_ROWS {
+		return StatementImportReport{}, statementTooLarge()
+	}
+
+	expenseIds, incomeIds, err := bt.liveCategoryIds(ctx, userId)
+	if err != nil {
+		return StatementImportReport{}, err
+	}
+
+	if mapping.ExpenseCategoryId != "" && !expenseIds[mapping.ExpenseCategoryId] {
+		return StatementImportReport{}, appErrors.ErrorResponse{
+			Code:    appErrors.ErrInvalidInput,
+			Message: "Expense category does not exist",
+		}
+	}
+	if mapping.IncomeCategoryId != "" && !incomeIds[mapping.IncomeCategoryId] {
+		return StatementImportReport{}, appErrors.ErrorResponse{
+			Code:    appErrors.ErrInvalidInput,
+			Message: "Income category does not exist",
+		}
+	}
+
+	existingTransactions, err := bt.storage.GetFilteredTransactions(ctx, userId, &TransactionList{IsAllNil: true})
+	if err != nil {
+		return StatementImportReport{}, err
+	}
+	rules, err := bt.userRules(ctx, userId)
+	if err != nil {
+		return StatementImportReport{}, err
+	}
+	payees, err := bt.storage.GetPayees(ctx, userId)
+	if err != nil {
+		return StatementImportReport{}, err
+	}
+
+	existingKeys := make(map[string]bool, len(existingTransactions))
+	for _, t := range existingTransactions {
+		existingKeys[transactionImportKey(t.CategoryId, t)] = true
+	}
+
+	externalIds := make([]string, 0, len(rows))
+	for _, row := range rows {
+		if row.ExternalID != "" {
+			externalIds = append(externalIds, row.ExternalID)
+		}
+	}
+	existingExternalIds := map[string]bool{}
+	if len(externalIds) > 0 {
+		existingExternalIds, err = bt.storage.GetExistingExternalIds(ctx, userId, externalIds)
+		if err != nil {
+			return StatementImportReport{}, err
+		}
+	}
+
+	report := StatementImportReport{DryRun: dryRun}
+	for _, e := range parseErrors {
+		if e.File == "" {
+			e.File = source
+		}
+		report.Errors = append(report.Errors, e)
+		report.Skipped++
+	}
+
+	var batch ImportBatch
+	now := time.Now().UTC()
+	for _, row := range rows {
+		rowError := func(message string) {
+			report.Errors = append(report.Errors, ImportRowError{File: source, Index: row.Index, Message: message})
+			report.Skipped++
+		}
+
+		categoryId, categoryType := "", ""
+		if override, ok := mapping.Rows[row.Index]; ok {
+			// IDs are only unique per category table, the row's direction
+			// decides when an ID is in both.
+			switch {
+			case expenseIds[override] && (row.Amount < 0 || !incomeIds[override]):
+				categoryType = "-"
+			case incomeIds[override]:
+				categoryType = "+"
+			default:
+				rowError("Category does not exist")
+				continue
+			}
+			categoryId = override
+		} else if row.Amount < 0 {
+			categoryId, categoryType = mapping.ExpenseCategoryId, "-"
+		} else {
+			categoryId, categoryType = mapping.IncomeCategoryId, "+"
+		}
+
+		occurredAt := importTime(row.Date, now)
+		payee, _ := matchPayee(payees, row.Description)
+		out := runRules(rules, ruleSubject{
+			CategoryType: categoryType,
+			Note:         row.Description,
+			Payee:        payee.Name,
+			Amount:       math.Abs(row.Amount),
+			Currency:     row.Currency,
+			OccurredAt:   occurredAt,
+		})
+		if _, listed := mapping.Rows[row.Index]; !listed {
+			known := expenseIds
+			if categoryType == "+" {
+				known = incomeIds
+			}
+			// the category of a rule or payee may have been deleted since
+			if known[out.CategoryId] {
+				categoryId = out.CategoryId
+			} else if payee.DefaultCategoryType == categoryType && known[payee.DefaultCategoryId] {
+				categoryId = payee.DefaultCategoryId
+			}
+		}
+		note := row.Description
+		if out.Note != "" {
+			note = out.Note
+		}
+
+		if categoryId == "" {
+			if categoryType == "-" {
+				rowError("No expense category selected for outgoing payment")
+			} else {
+				rowError("No income category selected for incoming payment")
+			}
+			continue
+		}
+
+		t := Transaction{
+			ID:           uuid.New().String(),
+			CategoryId:   categoryId,
+			CategoryType: categoryType,
+			Amount:       math.Abs(row.Amount),
+			Currency:     strings.ToUpper(row.Currency),
+			OccurredAt:   occurredAt,
+			CreatedAt:    now,
+			Note:         note,
+			CreatedBy:    userId,
+			ExternalID:   row.ExternalID,
+			Tags:         out.Tags,
+			PayeeId:      payee.ID,
+		}
+		if len(t.Tags) == 0 {
+			t.Tags = nil
+		}
+
+		if err := validateTransactionRequest(TransactionRequest{
+			CategoryId:   t.CategoryId,
+			CategoryType: t.CategoryType,
+			Amount:       t.Amount,
+			Currency:     t.Currency,
+			Note:         t.Note,
+			OccurredAt:   t.OccurredAt,
+		}); err != nil {
+			rowError(importErrorMessage(err))
+			continue
+		}
+
+		preview := StatementPreviewRow{
+			StatementRow: row,
+			CategoryId:   categoryId,
+			CategoryType: categoryType,
+			Note:         note,
+			Tags:         t.Tags,
+			PayeeId:      payee.ID,
+			RuleIds:      out.RuleIds,
+		}
+		// a bank ID is authoritative, two real payments can look identical.
+		// without one, fall back to comparing the content.
+		key := transactionImportKey(categoryId, t)
+		duplicate := existingKeys[key]
+		if t.ExternalID != "" {
+			key = "external:" + t.ExternalID
+			duplicate = existingExternalIds[t.ExternalID] || existingKeys[key]
+		}
+		if duplicate {
+			preview.Duplicate = true
+			report.Duplicates++
+		} else {
+			existingKeys[key] = true
+			batch.Transactions = append(batch.Transactions, t)
+			report.Imported++
+		}
+		report.Rows = append(report.Rows, preview)
+	}
+
+	if dryRun || len(batch.Transactions) == 0 {
+		return report, nil
+	}
+
+	var tags []string
+	seenTags := make(map[string]bool)
+	for _, t := range batch.Transactions {
+		for _, name := range t.Tags {
+			if !seenTags[name] {
+				seenTags[name] = true
+				tags = append(tags, name)
+			}
+		}
+	}
+	// created with the transactions, so a failed import leaves no tags
+	batch.Tags, err = bt.newTags(ctx, userId, tags)
+	if err != nil {
+		return StatementImportReport{}, err
+	}
+
+	if err := bt.storage.SaveImportBatch(ctx, userId, batch); err != nil {
+		logging.Logger.Errorf("[TraceID=%s] | storage.SaveImportBatch() failed in Service.ImportStatement()", traceID)
+		return StatementImportReport{}, err
+	}
+	bt.learnCategories(userId, batch.Transactions...)
+
+	return report, nil
+}
@@ -0,0 +1,384 @@
+package budget
+
+import (
+	"context"
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	"math"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	appErrors "github.com/fatali-fataliyev/budget_tracker/customErrors"
+	"github.com/fatali-fataliyev/budget_tracker/internal/contextutil"
+	"github.com/fatali-fataliyev/budget_tracker/logging"
+)
+
+const (
+	DEFAULT_SUBSCRIPTION_MONTHS = 13
+	MAX_SUBSCRIPTION_MONTHS     = 36
+
+	// SUBSCRIPTION_AMOUNT_TOLERANCE is how far a charge may be from the one
+	// before it, as a share of it, to belong to the same subscription.
+	SUBSCRIPTION_AMOUNT_TOLERANCE = 0.25
+	// SUBSCRIPTION_NOTE_SIMILARITY is the share of words two notes must have
+	// in common to be charges of the same merchant.
+	SUBSCRIPTION_NOTE_SIMILARITY = 0.6
+	// SUBSCRIPTION_REGULARITY is the share of gaps between charges that must
+	// fit the period.
+	SUBSCRIPTION_REGULARITY = 0.75
+)
+
+// subscriptionPeriod is a billing period the analyzer looks for. A gap
+// between two charges fits it when it is between MinDays and MaxDays.
+type subscriptionPeriod struct {
+	Name       string
+	MinDays    float64
+	MaxDays    float64
+	Rule       string  // RRULE of a recurring transaction with this period
+	PerMonth   float64 // charges per month
+	MinCharges int
+}
+
+var subscriptionPeriods = []subscriptionPeriod{
+	{Name: "weekly", MinDays: 6, MaxDays: 8, Rule: "FREQ=WEEKLY", PerMonth: 52.0 / 12, MinCharges: 4},
+	{Name: "biweekly", MinDays: 12, MaxDays: 16, Rule: "FREQ=WEEKLY;INTERVAL=2", PerMonth: 26.0 / 12, MinCharges: 3},
+	{Name: "monthly", MinDays: 26, MaxDays: 35, Rule: "FREQ=MONTHLY", PerMonth: 1, MinCharges: 3},
+	{Name: "quarterly", MinDays: 82, MaxDays: 100, Rule: "FREQ=MONTHLY;INTERVAL=3", PerMonth: 1.0 / 3, MinCharges: 3},
+	{Name: "yearly", MinDays: 350, MaxDays: 380, Rule: "FREQ=MONTHLY;INTERVAL=12", PerMonth: 1.0 / 12, MinCharges: 2},
+}
+
+var (
+	merchantNonLetterRegex = regexp.MustCompile(`[^\p{L}]+`)
+	merchantNoiseWords     = map[string]bool{
+		"payment": true, "purchase": true, "pos": true, "card": true, "debit": true, "credit": true,
+		"ref": true, "www": true, "com": true, "net": true, "inc": true, "ltd": true, "llc": true,
+		"subscription": true, "monthly": true, "yearly": true, "annual": true, "renewal": true,
+	}
+)
+
+// DetectedSubscription is a series of charges from one merchant, of about
+// the same amount, at a regular period.
+type DetectedSubscription struct {
+	ID             string // stays the same while the merchant and period do
+	Name           string // note of the latest charge
+	CategoryId     string // of the latest charge
+	Currency       string
+	Period         string
+	Rule           string  // RRULE the subscription is converted with
+	Amount         float64 // of the latest charge
+	MonthlyCost    float64
+	Charges        int
+	FirstChargeAt  time.Time
+	LastChargeAt   time.Time
+	NextExpectedAt time.Time
+	Active         bool // charged within the last period and a half
+	PriceChanges   []PriceChange
+	PriceChanged   bool   // the latest charge has a new price
+	RecurringId    string // the recurring transaction that already tracks it
+	TransactionIds []string
+}
+
+type PriceChange struct {
+	ChangedAt time.Time
+	OldAmount float64
+	NewAmount float64
+	Percent   float64 // of the old amount, negative when the price went down
+}
+
+// merchantTokens are the words of a note that name a merchant, without
+// digits, punctuation and words every bank puts on a charge.
+func merchantTokens(note string) []string {
+	var tokens []string
+	seen := map[string]bool{}
+	for _, word := range strings.Fields(merchantNonLetterRegex.ReplaceAllString(strings.ToLower(note), " ")) {
+		if len([]rune(word)) < 2 || merchantNoiseWords[word] || seen[word] {
+			continue
+		}
+		seen[word] = true
+		tokens = append(tokens, word)
+	}
+	sort.Strings(tokens)
+	return tokens
+}
+
+// tokenSimilarity is the Jaccard index of two sorted word lists. Two empty
+// lists are the same merchant.
+func tokenSimilarity(a []string, b []string) float64 {
+	if len(a) == 0 && len(b) == 0 {
+		return 1
+	}
+	common := 0
+	for i, j := 0, 0; i < len(a) && j < len(b); {
+		switch {
+		case a[i] == b[j]:
+			common++
+			i++
+			j++
+		case a[i] < b[j]:
+			i++
+		default:
+			j++
+		}
+	}
+	return float64(common) / float64(len(a)+len(b)-common)
+}
+
+type merchantGroup struct {
+	currency   string
+	categoryId string // only set for charges without a note
+	tokens     []string
+	series     [][]Transaction
+}
+
+// detectSubscriptions finds subscriptions in the expenses of transactions.
+// Charges are grouped by merchant, a group is split into series of similar
+// amounts and a series is kept when the gaps between its charges fit a
+// billing period.
+func detectSubscriptions(transactions []Transaction, recurring []RecurringTransaction, now time.Time) []DetectedSubscription {
+	expenses := make([]Transaction, 0, len(transactions))
+	for _, t := range transactions {
+		if t.CategoryType == "-" && t.Amount > 0 {
+			expenses = append(expenses, t)
+		}
+	}
+	sort.SliceStable(expenses, func(i, j int) bool { return expenses[i].OccurredAt.Before(expenses[j].OccurredAt) })
+
+	var groups []*merchantGroup
+	for _, t := range expenses {
+		tokens := merchantTokens(t.Note)
+		categoryId := ""
+		if len(tokens) == 0 {
+			categoryId = t.CategoryId // without a note, only the category tells charges apart
+		}
+		var group *merchantGroup
+		for _, g := range groups {
+			if g.currency == t.Currency && g.categoryId == categoryId && tokenSimilarity(g.tokens, tokens) >= SUBSCRIPTION_NOTE_SIMILARITY {
+				group = g
+				break
+			}
+		}
+		if group == nil {
+			group = &merchantGroup{currency: t.Currency, categoryId: categoryId, tokens: tokens}
+			groups = append(groups, group)
+		}
+		addToSeries(group, t)
+	}
+
+	var detected []DetectedSubscription
+	ids := map[string]int{}
+	for _, g := range groups {
+		for _, series := range g.series {
+			s, ok := subscriptionOf(series, now)
+			if !ok {
+				continue
+			}
+			sum := sha1.Sum([]byte(g.currency + "|" + g.categoryId + "|" + strings.Join(g.tokens, " ") + "|" + s.Period))
+			s.ID = hex.EncodeToString(sum[:8])
+			if ids[s.ID]++; ids[s.ID] > 1 {
+				s.ID = fmt.Sprintf("%s-%d", s.ID, ids[s.ID])
+			}
+			s.RecurringId = trackingRecurring(s, recurring)
+			detected = append(detected, s)
+		}
+	}
+
+	sort.SliceStable(detected, func(i, j int) bool {
+		if detected[i].Active != detected[j].Active {
+			return detected[i].Active
+		}
+		return detected[i].MonthlyCost > detected[j].MonthlyCost
+	})
+	return detected
+}
+
+// addToSeries puts t into the series whose latest charge is the closest in
+// amount within SUBSCRIPTION_AMOUNT_TOLERANCE, or starts a new one.
+func addToSeries(g *merchantGroup, t Transaction) {
+	best, bestDiff := -1, math.MaxFloat64
+	for i, series := range g.series {
+		last := series[len(series)-1].Amount
+		diff := math.Abs(t.Amount - last)
+		if diff <= last*SUBSCRIPTION_AMOUNT_TOLERANCE && diff < bestDiff {
+			best, bestDiff = i, diff
+		}
+	}
+	if best == -1 {
+		g.series = append(g.series, []Transaction{t})
+		return
+	}
+	g.series[best] = append(g.series[best], t)
+}
+
+// subscriptionOf checks a series of charges, oldest first, against the
+// billing periods.
+func subscriptionOf(series []Transaction, now time.Time) (DetectedSubscription, bool) {
+	if len(series) < 2 {
+		return DetectedSubscription{}, false
+	}
+	gaps := make([]float64, 0, len(series)-1)
+	for i := 1; i < len(series); i++ {
+		gaps = append(gaps, series[i].OccurredAt.Sub(series[i-1].OccurredAt).Hours()/24)
+	}
+	sorted := append([]float64(nil), gaps...)
+	sort.Float64s(sorted)
+	median := sorted[len(sorted)/2]
+
+	var period subscriptionPeriod
+	found := false
+	for _, p := range subscriptionPeriods {
+		if median >= p.MinDays && median <= p.MaxDays {
+			period, found = p, true
+			break
+		}
+	}
+	if !found || len(series) < period.MinCharges {
+		return DetectedSubscription{}, false
+	}
+	regular := 0
+	for _, gap := range gaps {
+		if gap >= period.MinDays && gap <= period.MaxDays {
+			regular++
+		}
+	}
+	if float64(regular) < float64(len(gaps))*SUBSCRIPTION_REGULARITY {
+		return DetectedSubscription{}, false
+	}
+
+	first, last := series[0], series[len(series)-1]
+	s := DetectedSubscription{
+		Name:          last.Note,
+		CategoryId:    last.CategoryId,
+		Currency:      last.Currency,
+		Period:        period.Name,
+		Rule:          period.Rule,
+		Amount:        last.Amount,
+		MonthlyCost:   math.Round(last.Amount*period.PerMonth*100) / 100,
+		Charges:       len(series),
+		FirstChargeAt: first.OccurredAt,
+		LastChargeAt:  last.OccurredAt,
+		Active:        now.Sub(last.OccurredAt).Hours()/24 <= period.MaxDays*1.5,
+	}
+	if recurrence, err := ParseRecurrenceRule(period.Rule); err == nil {
+		s.NextExpectedAt = recurrence.NextAfter(last.OccurredAt, last.OccurredAt)
+	}
+	for i, t := range series {
+		s.TransactionIds = append(s.TransactionIds, t.ID)
+		if i == 0 {
+			continue
+		}
+		old := toCents(series[i-1].Amount)
+		if toCents(t.Amount) != old {
+			s.PriceChanges = append(s.PriceChanges, PriceChange{
+				ChangedAt: t.OccurredAt,
+				OldAmount: series[i-1].Amount,
+				NewAmount: t.Amount,
+				Percent:   math.Round(float64(toCents(t.Amount)-old)/float64(old)*10000) / 100,
+			})
+		}
+	}
+	s.PriceChanged = len(s.PriceChanges) > 0 && s.PriceChanges[len(s.PriceChanges)-1].ChangedAt.Equal(last.OccurredAt)
+	return s, true
+}
+
+// trackingRecurring returns the ID of the recurring expense that already
+// covers s: same currency, about the same amount, and the same category or
+// merchant.
+func trackingRecurring(s DetectedSubscription, recurring []RecurringTransaction) string {
+	tokens := merchantTokens(s.Name)
+	for _, r := range recurring {
+		if r.CategoryType != "-" || !strings.EqualFold(r.Currency, s.Currency) {
+			continue
+		}
+		if math.Abs(r.Amount-s.Amount) > s.Amount*SUBSCRIPTION_AMOUNT_TOLERANCE {
+			continue
+		}
+		if r.CategoryId == s.CategoryId || (len(tokens) > 0 && tokenSimilarity(merchantTokens(r.Note), tokens) >= SUBSCRIPTION_NOTE_SIMILARITY) {
+			return r.ID
+		}
+	}
+	return ""
+}
+
+// DetectSubscriptions analyzes the expenses of the last months months.
+func (bt *BudgetTracker) DetectSubscriptions(ctx context.Context, userId string, months int) ([]DetectedSubscription, error) {
+	traceID := contextutil.TraceIDFromContext(ctx)
+
+	if months == 0 {
+		months = DEFAULT_SUBSCRIPTION_MONTHS
+	}
+	if months < 1 || months > MAX_SUBSCRIPTION_MONTHS {
+		return nil, appErrors.ErrorResponse{
+			Code:    appErrors.ErrInvalidInput,
+			Message: fmt.Sprintf("Months must be between 1 and %d", MAX_SUBSCRIPTION_MONTHS),
+		}
+	}
+
+	now := time.Now().UTC()
+	transactions, err := bt.storage.GetFilteredTransactions(ctx, userId, &TransactionList{Type: "-", OccurredAt: now.AddDate(0, -months, 0)})
+	if err != nil {
+		logging.Logger.Errorf("[TraceID=%s] | storage.GetFilteredTransactions() failed in Service.DetectSubscriptions()", traceID)
+		return nil, err
+	}
+	recurring, err := bt.storage.GetRecurringTransactions(ctx, userId)
+	if err != nil {
+		logging.Logger.Errorf("[TraceID=%s] | storage.GetRecurringTransactions() failed in Service.DetectSubscriptions()", traceID)
+		return nil, err
+	}
+	return detectSubscriptions(transactions, recurring, now), nil
+}
+
+// ConvertSubscription creates a recurring expense from a detected
+// subscription, starting at its next expected charge that is still ahead.
+func (bt *BudgetTracker) ConvertSubscription(ctx context.Context, userId string, id string, months int) (RecurringTransaction, error) {
+	detected, err := bt.DetectSubscriptions(ctx, userId, months)
+	if err != nil {
+		return RecurringTransaction{}, err
+	}
+	idx := -1
+	for i, s := range detected {
+		if s.ID == id {
+			idx = i
+			break
+		}
+	}
+	if idx == -1 {
+		return RecurringTransaction{}, appErrors.ErrorResponse{
+			Code:    appErrors.ErrNotFound,
+			Message: "Subscription not found, it may no longer be detected.",
+		}
+	}
+	s := detected[idx]
+	if s.RecurringId != "" {
+		return RecurringTransaction{}, appErrors.ErrorResponse{
+			Code:    appErrors.ErrConflict,
+			Message: fmt.Sprintf("The subscription is already tracked by recurring transaction %s.", s.RecurringId),
+		}
+	}
+	if !s.Active {
+		return RecurringTransaction{}, appErrors.ErrorResponse{
+			Code:    appErrors.ErrInvalidInput,
+			Message: "The subscription is no longer charged.",
+		}
+	}
+
+	start := s.NextExpectedAt
+	if now := time.Now().UTC(); start.Before(now) {
+		recurrence, err := ParseRecurrenceRule(s.Rule)
+		if err != nil {
+			return RecurringTransaction{}, err
+		}
+		start = recurrence.NextAfter(s.LastChargeAt, now)
+	}
+	return bt.SaveRecurringTransaction(ctx, userId, RecurringTransactionRequest{
+		CategoryId:   s.CategoryId,
+		CategoryType: "-",
+		Amount:       s.Amount,
+		Currency:     s.Currency,
+		Note:         s.Name,
+		Rule:         s.Rule,
+		StartAt:      start,
+	})
+}
@@ -0,0 +1,394 @@
+package budget
+
+import (
+	"context"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	appErrors "github.com/fatali-fataliyev/budget_tracker/customErrors"
+	"github.com/fatali-fataliyev/budget_tracker/internal/contextutil"
+	"github.com/fatali-fataliyev/budget_tracker/logging"
+)
+
+const (
+	DEFAULT_CATEGORY_SUGGESTIONS = 3
+	MAX_CATEGORY_SUGGESTIONS     = 10
+	// CATEGORY_MODEL_MAX_AGE is how long a model is kept before it is trained
+	// again from the whole history. Until then new transactions are added to
+	// it as they are saved.
+	CATEGORY_MODEL_MAX_AGE = 6 * time.Hour
+	// MAX_CACHED_CATEGORY_MODELS bounds the users whose models are kept in
+	// memory, the least recently trained one makes room.
+	MAX_CACHED_CATEGORY_MODELS = 1000
+	// a transaction sent without a category only gets the top suggestion
+	// when the model is this sure and has seen the category often enough.
+	AUTO_CATEGORY_MIN_CONFIDENCE = 0.9
+	AUTO_CATEGORY_MIN_EXAMPLES   = 5
+)
+
+type CategorySuggestion struct {
+	CategoryId   string
+	CategoryName string
+	Confidence   float64 // between 0 and 1, the suggestions for one transaction add up to at most 1
+	Examples     int     // transactions of the category the model learned from
+}
+
+// categoryModel is a naive Bayes classifier over the words of the note, the
+// amount bucket and the weekday of transactions of one category type.
+type categoryModel struct {
+	examples map[string]int            // category ID -> transactions learned
+	counts   map[string]map[string]int // category ID -> feature -> occurrences
+	totals   map[string]int            // category ID -> features learned
+	features map[string]bool
+	size     int
+}
+
+func newCategoryModel() *categoryModel {
+	return &categoryModel{
+		examples: map[string]int{},
+		counts:   map[string]map[string]int{},
+		totals:   map[string]int{},
+		features: map[string]bool{},
+	}
+}
+
+// amountBucket groups amounts by powers of two, so 12.5 and 15 fall
+// together and 12.5 and 120 do not. Amounts below 1 are bucket 0.
+func amountBucket(amount float64) int {
+	if amount < 1 {
+		return 0
+	}
+	return int(math.Floor(math.Log2(amount))) + 1
+}
+
+// categoryFeatures describes a transaction to the model. Amounts are only
+// comparable in one currency, so the bucket carries it.
+func categoryFeatures(note string, amount float64, currency string, occurredAt time.Time) []string {
+	features := []string{
+		"amount:" + strings.ToUpper(currency) + ":" + strconv.Itoa(amountBucket(amount)),
+		"weekday:" + strconv.Itoa(int(occurredAt.UTC().Weekday())),
+	}
+	for _, word := range merchantTokens(note) {
+		features = append(features, "word:"+word)
+	}
+	return features
+}
+
+func (m *categoryModel) learn(categoryId string, features []string) {
+	if categoryId == "" {
+		return
+	}
+	counts, ok := m.counts[categoryId]
+	if !ok {
+		counts = map[string]int{}
+		m.counts[categoryId] = counts
+	}
+	for _, f := range features {
+		counts[f]++
+		m.features[f] = true
+	}
+	m.totals[categoryId] += len(features)
+	m.examples[categoryId]++
+	m.size++
+}
+
+// predict ranks every category the model knows, most likely first. Features
+// the model never saw say nothing about any category and are left out, the
+// rest are smoothed so one unseen pairing does not rule a category out.
+func (m *categoryModel) predict(features []string) []CategorySuggestion {
+	if m.size == 0 {
+		return nil
+	}
+	vocabulary := float64(len(m.features))
+	scores := make(map[string]float64, len(m.examples))
+	best := math.Inf(-1)
+	for categoryId, examples := range m.examples {
+		score := math.Log(float64(examples) / float64(m.size))
+		for _, f := range features {
+			if !m.features[f] {
+				continue
+			}
+			score += math.Log((float64(m.counts[categoryId][f]) + 1) / (float64(m.totals[categoryId]) + vocabulary))
+		}
+		scores[categoryId] = score
+		best = math.Max(best, score)
+	}
+
+	// softmax, shifted by the best score to stay within float range
+	sum := 0.0
+	for _, score := range scores {
+		sum += math.Exp(score - best)
+	}
+	suggestions := make([]CategorySuggestion, 0, len(scores))
+	for categoryId, score := range scores {
+		suggestions = append(suggestions, CategorySuggestion{
+			CategoryId: categoryId,
+			Confidence: math.Exp(score-best) / sum,
+			Examples:   m.examples[categoryId],
+		})
+	}
+	sort.Slice(suggestions, func(i, j int) bool {
+		if suggestions[i].Confidence != suggestions[j].Confidence {
+			return suggestions[i].Confidence > suggestions[j].Confidence
+		}
+		return suggestions[i].CategoryId < suggestions[j].CategoryId
+	})
+	return suggestions
+}
+
+// userCategoryModel holds a model per category type.
+type userCategoryModel struct {
+	types     map[string]*categoryModel
+	trainedAt time.Time
+}
+
+// learnTransaction adds a transaction to the model, a split transaction
+// line by line. Transfers have no category to learn.
+func (u *userCategoryModel) learnTransaction(t Transaction) {
+	if t.CategoryType != "+" && t.CategoryType != "-" {
+		return
+	}
+	model, ok := u.types[t.CategoryType]
+	if !ok {
+		model = newCategoryModel()
+		u.types[t.CategoryType] = model
+	}
+	if len(t.Splits) == 0 {
+		model.learn(t.CategoryId, categoryFeatures(t.Note, t.Amount, t.Currency, t.OccurredAt))
+		return
+	}
+	for _, s := range t.Splits {
+		note := s.Note
+		if note == "" {
+			note = t.Note
+		}
+		model.learn(s.CategoryId, categoryFeatures(note, s.Amount, t.Currency, t.OccurredAt))
+	}
+}
+
+// categoryModels keeps the trained models of recently active users between
+// requests. While a model is trained, the history changes of its user are
+// counted in trainings, a model trained across such a change is not cached
+// since it may miss it. The entry is removed when the last training of the
+// user ends.
+type categoryModels struct {
+	mu        sync.Mutex
+	users     map[string]*userCategoryModel
+	trainings map[string]*categoryTraining
+}
+
+type categoryTraining struct {
+	running    int
+	generation uint64
+}
+
+func newCategoryModels() *categoryModels {
+	return &categoryModels{users: map[string]*userCategoryModel{}, trainings: map[string]*categoryTraining{}}
+}
+
+// historyChanged marks the running trainings of the user as outdated, the
+// caller holds mu.
+func (c *categoryModels) historyChanged(userId string) {
+	if training, ok := c.trainings[userId]; ok {
+		training.generation++
+	}
+}
+
+// trainCategoryModel trains a model from the whole transaction history.
+func (bt *BudgetTracker) trainCategoryModel(ctx context.Context, userId string) (*userCategoryModel, error) {
+	model := &userCategoryModel{types: map[string]*categoryModel{}, trainedAt: time.Now().UTC()}
+	err := bt.storage.EachTransaction(ctx, userId, func(t Transaction) error {
+		model.learnTransaction(t)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return model, nil
+}
+
+// suggestCategories ranks the categories of a transaction with the cached
+// model of the user, training it first when there is none or it is too old.
+// Without a cache the model is trained on every call.
+func (bt *BudgetTracker) suggestCategories(ctx context.Context, userId string, categoryType string, features []string) ([]CategorySuggestion, error) {
+	cache := bt.categoryModels
+	if cache == nil {
+		model, err := bt.trainCategoryModel(ctx, userId)
+		if err != nil {
+			return nil, err
+		}
+		return model.predict(categoryType, features), nil
+	}
+
+	cache.mu.Lock()
+	if model, ok := cache.users[userId]; ok && time.Since(model.trainedAt) < CATEGORY_MODEL_MAX_AGE {
+		defer cache.mu.Unlock()
+		return model.predict(categoryType, features), nil
+	}
+	training, ok := cache.trainings[userId]
+	if !ok {
+		training = &categoryTraining{}
+		cache.trainings[userId] = training
+	}
+	training.running++
+	generation := training.generation
+	cache.mu.Unlock()
+
+	model, err := bt.trainCategoryModel(ctx, userId)
+
+	cache.mu.Lock()
+	defer cache.mu.Unlock()
+	training.running--
+	if training.running == 0 {
+		delete(cache.trainings, userId)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if training.generation != generation {
+		return model.predict(categoryType, features), nil
+	}
+	if _, ok := cache.users[userId]; !ok && len(cache.users) >= MAX_CACHED_CATEGORY_MODELS {
+		oldest := ""
+		for id, m := range cache.users {
+			if oldest == "" || m.trainedAt.Before(cache.users[oldest].trainedAt) {
+				oldest = id
+			}
+		}
+		delete(cache.users, oldest)
+	}
+	cache.users[userId] = model
+	return model.predict(categoryType, features), nil
+}
+
+func (u *userCategoryModel) predict(categoryType string, features []string) []CategorySuggestion {
+	model, ok := u.types[categoryType]
+	if !ok {
+		return nil
+	}
+	return model.predict(features)
+}
+
+// learnCategories retrains the cached model of a user incrementally with
+// newly saved transactions. Without a cached model there is nothing to
+// update, the next suggestion trains one from the history.
+func (bt *BudgetTracker) learnCategories(userId string, transactions ...Transaction) {
+	if bt.categoryModels == nil {
+		return
+	}
+	bt.categoryModels.mu.Lock()
+	defer bt.categoryModels.mu.Unlock()
+	bt.categoryModels.historyChanged(userId)
+	model, ok := bt.categoryModels.users[userId]
+	if !ok {
+		return
+	}
+	for _, t := range transactions {
+		model.learnTransaction(t)
+	}
+}
+
+// forgetCategories drops the cached model of a user after the categories of
+// saved transactions changed or the account was purged, it is trained again
+// on the next suggestion.
+func (bt *BudgetTracker) forgetCategories(userId string) {
+	if bt.categoryModels == nil {
+		return
+	}
+	bt.categoryModels.mu.Lock()
+	defer bt.categoryModels.mu.Unlock()
+	bt.categoryModels.historyChanged(userId)
+	delete(bt.categoryModels.users, userId)
+}
+
+// SuggestCategories ranks the categories of the transaction's type by how
+// likely they are for it, learned from the user's history. Categories that
+// no longer exist are left out, limit caps the suggestions.
+func (bt *BudgetTracker) SuggestCategories(ctx context.Context, userId string, transaction TransactionRequest, limit int) ([]CategorySuggestion, error) {
+	traceID := contextutil.TraceIDFromContext(ctx)
+
+	if transaction.CategoryType != "+" && transaction.CategoryType != "-" {
+		return nil, appErrors.ErrorResponse{
+			Code:    appErrors.ErrInvalidInput,
+			Message: "Invalid category type, use expense or income.",
+		}
+	}
+	if limit <= 0 {
+		limit = DEFAULT_CATEGORY_SUGGESTIONS
+	}
+	if limit > MAX_CATEGORY_SUGGESTIONS {
+		return nil, appErrors.ErrorResponse{
+			Code:    appErrors.ErrInvalidInput,
+			Message: "Limit must be between 1 and 10",
+		}
+	}
+	occurredAt := transaction.OccurredAt
+	if occurredAt.IsZero() {
+		occurredAt = time.Now().UTC()
+	}
+
+	names := map[string]string{}
+	if transaction.CategoryType == "-" {
+		categories, err := bt.storage.GetFilteredExpenseCategories(ctx, userId, &ExpenseCategoryList{IsAllNil: true})
+		if err != nil {
+			logging.Logger.Errorf("[TraceID=%s] | storage.GetFilteredExpenseCategories() failed in Service.SuggestCategories()", traceID)
+			return nil, err
+		}
+		for _, c := range categories {
+			names[c.ID] = c.Name
+		}
+	} else {
+		categories, err := bt.storage.GetFilteredIncomeCategories(ctx, userId, &IncomeCategoryList{IsAllNil: true})
+		if err != nil {
+			logging.Logger.Errorf("[TraceID=%s] | storage.GetFilteredIncomeCategories() failed in Service.SuggestCategories()", traceID)
+			return nil, err
+		}
+		for _, c := range categories {
+			names[c.ID] = c.Name
+		}
+	}
+
+	ranked, err := bt.suggestCategories(ctx, userId, transaction.CategoryType, categoryFeatures(transaction.Note, transaction.Amount, transaction.Currency, occurredAt))
+	if err != nil {
+		logging.Logger.Errorf("[TraceID=%s] | storage.EachTransaction() failed in Service.SuggestCategories()", traceID)
+		return nil, err
+	}
+	suggestions := []CategorySuggestion{}
+	for _, s := range ranked {
+		name, ok := names[s.CategoryId]
+		if !ok {
+			continue
+		}
+		s.CategoryName = name
+		suggestions = append(suggestions, s)
+		if len(suggestions) == limit {
+			break
+		}
+	}
+	return suggestions, nil
+}
+
+// applySuggestedCategory gives a transaction that is still without a
+// category the top suggestion, when the model is sure enough of it. The
+// amount and weekday alone are too weak a hint, the note has to name
+// something.
+func (bt *BudgetTracker) applySuggestedCategory(ctx context.Context, userId string, transaction *TransactionRequest) error {
+	if transaction.CategoryId != "" || len(transaction.Splits) > 0 || (transaction.CategoryType != "+" && transaction.CategoryType != "-") {
+		return nil
+	}
+	if len(merchantTokens(transaction.Note)) == 0 {
+		return nil
+	}
+	suggestions, err := bt.SuggestCategories(ctx, userId, *transaction, 1)
+	if err != nil {
+		return err
+	}
+	if len(suggestions) > 0 && suggestions[0].Confidence >= AUTO_CATEGORY_MIN_CONFIDENCE && suggestions[0].Examples >= AUTO_CATEGORY_MIN_EXAMPLES {
+		transaction.CategoryId = suggestions[0].CategoryId
+	}
+	return nil
+}
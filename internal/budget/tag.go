@@ -0,0 +1,218 @@
+package budget
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	appErrors "github.com/fatali-fataliyev/budget_tracker/customErrors"
+	"github.com/fatali-fataliyev/budget_tracker/internal/contextutil"
+	"github.com/fatali-fataliyev/budget_tracker/logging"
+	"github.com/google/uuid"
+)
+
+const (
+	TAGS_MODE_ANY = "any"
+	TAGS_MODE_ALL = "all"
+
+	MAX_TAG_NAME_LENGTH      = 50
+	MAX_TAGS_PER_TRANSACTION = 20
+	MAX_TAGS_PER_USER        = 500
+)
+
+// Tag groups transactions across categories of both types, like
+// "vacation-2026". Names are lowercase and unique per user.
+type Tag struct {
+	ID               string
+	Name             string
+	TransactionCount int // filled by storage when reading
+	CreatedAt        time.Time
+	UpdatedAt        time.Time
+	CreatedBy        string
+}
+
+type TagTotal struct {
+	Name     string
+	Expenses float64
+	Incomes  float64
+	Count    int
+}
+
+// normalizeTagName lowercases a tag name and collapses its whitespace.
+func normalizeTagName(name string) (string, error) {
+	name = strings.ToLower(strings.Join(strings.Fields(name), " "))
+	if name == "" {
+		return "", appErrors.ErrorResponse{
+			Code:    appErrors.ErrInvalidInput,
+			Message: "Tag name cannot be empty!",
+		}
+	}
+	if len(name) > MAX_TAG_NAME_LENGTH {
+		return "", appErrors.ErrorResponse{
+			Code:    appErrors.ErrInvalidInput,
+			Message: fmt.Sprintf("Tag name so long, maximum allowed length is %d", MAX_TAG_NAME_LENGTH),
+		}
+	}
+	if strings.Contains(name, ",") {
+		// the tags filter is a comma separated list
+		return "", appErrors.ErrorResponse{
+			Code:    appErrors.ErrInvalidInput,
+			Message: "Tag name cannot contain a comma",
+		}
+	}
+	return name, nil
+}
+
+// normalizeTags normalizes, deduplicates and sorts the tags of a transaction.
+func normalizeTags(names []string) ([]string, error) {
+	if len(names) == 0 {
+		return nil, nil
+	}
+	seen := make(map[string]bool, len(names))
+	tags := make([]string, 0, len(names))
+	for _, raw := range names {
+		name, err := normalizeTagName(raw)
+		if err != nil {
+			return nil, err
+		}
+		if !seen[name] {
+			seen[name] = true
+			tags = append(tags, name)
+		}
+	}
+	if len(tags) > MAX_TAGS_PER_TRANSACTION {
+		return nil, appErrors.ErrorResponse{
+			Code:    appErrors.ErrInvalidInput,
+			Message: fmt.Sprintf("Maximum %d tags are allowed per transaction", MAX_TAGS_PER_TRANSACTION),
+		}
+	}
+	sort.Strings(tags)
+	return tags, nil
+}
+
+func (bt *BudgetTracker) SaveTag(ctx context.Context, userId string, name string) (Tag, error) {
+	traceID := contextutil.TraceIDFromContext(ctx)
+
+	name, err := normalizeTagName(name)
+	if err != nil {
+		return Tag{}, err
+	}
+	existing, err := bt.storage.GetTags(ctx, userId)
+	if err != nil {
+		logging.Logger.Errorf("[TraceID=%s] | storage.GetTags() failed in Service.SaveTag()", traceID)
+		return Tag{}, err
+	}
+	if len(existing) >= MAX_TAGS_PER_USER {
+		return Tag{}, appErrors.ErrorResponse{
+			Code:    appErrors.ErrInvalidInput,
+			Message: fmt.Sprintf("Maximum %d tags are allowed", MAX_TAGS_PER_USER),
+		}
+	}
+
+	now := time.Now().UTC()
+	tag := Tag{ID: uuid.New().String(), Name: name, CreatedAt: now, UpdatedAt: now, CreatedBy: userId}
+	if err := bt.storage.SaveTag(ctx, tag); err != nil {
+		logging.Logger.Errorf("[TraceID=%s] | storage.SaveTag() failed in Service.SaveTag()", traceID)
+		return Tag{}, err
+	}
+	return tag, nil
+}
+
+func (bt *BudgetTracker) GetTags(ctx context.Context, userId string) ([]Tag, error) {
+	return bt.storage.GetTags(ctx, userId)
+}
+
+// UpdateTag renames a tag, its transactions keep it.
+func (bt *BudgetTracker) UpdateTag(ctx context.Context, userId string, id string, name string) (Tag, error) {
+	name, err := normalizeTagName(name)
+	if err != nil {
+		return Tag{}, err
+	}
+	tag, err := bt.storage.GetTagById(ctx, userId, id)
+	if err != nil {
+		return Tag{}, err
+	}
+	tag.Name = name
+	tag.UpdatedAt = time.Now().UTC()
+	if err := bt.storage.UpdateTag(ctx, tag); err != nil {
+		return Tag{}, err
+	}
+	return tag, nil
+}
+
+// DeleteTag removes a tag from all its transactions and deletes it.
+func (bt *BudgetTracker) DeleteTag(ctx context.Context, userId string, id string) error {
+	return bt.storage.DeleteTag(ctx, userId, id)
+}
+
+// SetTransactionTags replaces the tags of a transaction.
+func (bt *BudgetTracker) SetTransactionTags(ctx context.Context, userId string, transactionId string, names []string) (Transaction, error) {
+	traceID := contextutil.TraceIDFromContext(ctx)
+
+	tags, err := normalizeTags(names)
+	if err != nil {
+		return Transaction{}, err
+	}
+	transaction, err := bt.storage.GetTransactionById(ctx, userId, transactionId)
+	if err != nil {
+		return Transaction{}, err
+	}
+	if err := bt.ensureTags(ctx, userId, tags); err != nil {
+		return Transaction{}, err
+	}
+	if err := bt.storage.SetTransactionTags(ctx, userId, transactionId, tags); err != nil {
+		logging.Logger.Errorf("[TraceID=%s] | storage.SetTransactionTags() failed in Service.SetTransactionTags()", traceID)
+		return Transaction{}, err
+	}
+	transaction.Tags = tags
+	return transaction, nil
+}
+
+// ensureTags creates the tags in names the user does not have yet.
+func (bt *BudgetTracker) ensureTags(ctx context.Context, userId string, names []string) error {
+	tags, err := bt.newTags(ctx, userId, names)
+	if err != nil {
+		return err
+	}
+	for _, tag := range tags {
+		if err := bt.storage.SaveTag(ctx, tag); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// newTags returns the tags in names the user does not have yet, for callers
+// that save them in the same storage transaction as their transactions.
+func (bt *BudgetTracker) newTags(ctx context.Context, userId string, names []string) ([]Tag, error) {
+	if len(names) == 0 {
+		return nil, nil
+	}
+	existing, err := bt.storage.GetTags(ctx, userId)
+	if err != nil {
+		return nil, err
+	}
+	known := make(map[string]bool, len(existing))
+	for _, t := range existing {
+		known[t.Name] = true
+	}
+
+	var tags []Tag
+	now := time.Now().UTC()
+	for _, name := range names {
+		if known[name] {
+			continue
+		}
+		if len(existing)+len(tags) >= MAX_TAGS_PER_USER {
+			return nil, appErrors.ErrorResponse{
+				Code:    appErrors.ErrInvalidInput,
+				Message: fmt.Sprintf("Maximum %d tags are allowed", MAX_TAGS_PER_USER),
+			}
+		}
+		known[name] = true
+		tags = append(tags, Tag{ID: uuid.New().String(), Name: name, CreatedAt: now, UpdatedAt: now, CreatedBy: userId})
+	}
+	return tags, nil
+}
@@ -0,0 +1,438 @@
+package budget
+
+import (
+	"archive/zip"
+	"bufio"
+	"context"
+	"encoding/csv"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	appErrors "github.com/fatali-fataliyev/budget_tracker/customErrors"
+	"github.com/fatali-fataliyev/budget_tracker/internal/contextutil"
+	"github.com/fatali-fataliyev/budget_tracker/logging"
+)
+
+const (
+	TRANSACTION_EXPORT_CSV  = "csv"
+	TRANSACTION_EXPORT_XLSX = "xlsx"
+
+	DEFAULT_EXPORT_LOCALE = "iso"
+)
+
+// ExportLocale controls how numbers and dates are written to CSV. XLSX
+// stores real numbers and dates, the spreadsheet formats them itself.
+type ExportLocale struct {
+	DecimalSeparator string
+	GroupSeparator   string
+	DateLayout       string
+	Delimiter        rune // default when the user does not choose one
+}
+
+var exportLocales = map[string]ExportLocale{
+	"iso":   {DecimalSeparator: ".", GroupSeparator: "", DateLayout: "2006-01-02 15:04", Delimiter: ','},
+	"en-US": {DecimalSeparator: ".", GroupSeparator: ",", DateLayout: "01/02/2006 15:04", Delimiter: ','},
+	"en-GB": {DecimalSeparator: ".", GroupSeparator: ",", DateLayout: "02/01/2006 15:04", Delimiter: ','},
+	"de-DE": {DecimalSeparator: ",", GroupSeparator: ".", DateLayout: "02.01.2006 15:04", Delimiter: ';'},
+	"fr-FR": {DecimalSeparator: ",", GroupSeparator: " ", DateLayout: "02/01/2006 15:04", Delimiter: ';'},
+	"az-AZ": {DecimalSeparator: ",", GroupSeparator: " ", DateLayout: "02.01.2006 15:04", Delimiter: ';'},
+	"tr-TR": {DecimalSeparator: ",", GroupSeparator: ".", DateLayout: "02.01.2006 15:04", Delimiter: ';'},
+	"ru-RU": {DecimalSeparator: ",", GroupSeparator: " ", DateLayout: "02.01.2006 15:04", Delimiter: ';'},
+}
+
+type TransactionExportOptions struct {
+	Format    string
+	Delimiter rune // CSV only, the locale's default when zero
+	Locale    string
+}
+
+var transactionExportHeader = []string{"Date", "Category", "Type", "Amount", "Currency", "Note"}
+
+// transactionExportWriter receives rows one at a time, so the output never
+// has to be held in memory.
+type transactionExportWriter interface {
+	Row(t Transaction) error
+	Totals(totals []currencyTotal) error
+	Close() error
+}
+
+type currencyTotal struct {
+	Currency string
+	Income   float64
+	Expense  float64
+}
+
+// ExportTransactions writes the transactions matching filters as CSV or
+// XLSX while they are read from storage. open is called once the first
+// transaction is read, so the caller can still answer with an error when the
+// options are invalid or the query fails.
+func (bt *BudgetTracker) ExportTransactions(ctx context.Context, userId string, filters *TransactionList, opts TransactionExportOptions, open func() io.Writer) error {
+	traceID := contextutil.TraceIDFromContext(ctx)
+
+	if opts.Format != TRANSACTION_EXPORT_CSV && opts.Format != TRANSACTION_EXPORT_XLSX {
+		return appErrors.ErrorResponse{
+			Code:    appErrors.ErrInvalidInput,
+			Message: "Invalid export format, allowed values: csv, xlsx",
+		}
+	}
+	if opts.Locale == "" {
+		opts.Locale = DEFAULT_EXPORT_LOCALE
+	}
+	locale, ok := exportLocales[opts.Locale]
+	if !ok {
+		return appErrors.ErrorResponse{
+			Code:    appErrors.ErrInvalidInput,
+			Message: "Unsupported locale, allowed values: " + strings.Join(ExportLocales(), ", "),
+		}
+	}
+	if opts.Delimiter == 0 {
+		opts.Delimiter = locale.Delimiter
+	}
+	if opts.Delimiter == '"' || opts.Delimiter == '\r' || opts.Delimiter == '\n' {
+		return appErrors.ErrorResponse{
+			Code:    appErrors.ErrInvalidInput,
+			Message: "Invalid delimiter",
+		}
+	}
+
+	var out transactionExportWriter
+	start := func() error {
+		var err error
+		w := open()
+		if opts.Format == TRANSACTION_EXPORT_CSV {
+			out, err = newCSVTransactionWriter(w, opts.Delimiter, locale)
+		} else {
+			out, err = newXLSXTransactionWriter(w)
+		}
+		return err
+	}
+
+	totals := map[string]*currencyTotal{}
+	err := bt.storage.EachFilteredTransaction(ctx, userId, filters, func(transaction Transaction) error {
+		if out == nil {
+			if err := start(); err != nil {
+				return err
+			}
+		}
+		// a split transaction is written as one row per split, and only the
+		// splits in the filtered categories are counted
+		for _, t := range expandSplits(transaction) {
+			if len(transaction.Splits) > 0 && !isInCategories(t.CategoryName, filters.CategoryNames) {
+				continue
+			}
+			if err := out.Row(t); err != nil {
+				logging.Logger.Errorf("[TraceID=%s] | failed to write export row in Service.ExportTransactions() | Error: %v", traceID, err)
+				return err
+			}
+
+			if t.CategoryType == TRANSFER_TYPE {
+				// moving money between wallets is neither income nor expense
+				continue
+			}
+			total, ok := totals[t.Currency]
+			if !ok {
+				total = &currencyTotal{Currency: t.Currency}
+				totals[t.Currency] = total
+			}
+			if t.CategoryType == "+" {
+				total.Income += t.Amount
+			} else {
+				total.Expense += t.Amount
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		logging.Logger.Errorf("[TraceID=%s] | storage.EachFilteredTransaction() failed in Service.ExportTransactions()", traceID)
+		return err
+	}
+	if out == nil {
+		// nothing matched, the export has only its header and no totals
+		if err := start(); err != nil {
+			return err
+		}
+	}
+
+	sorted := make([]currencyTotal, 0, len(totals))
+	for _, total := range totals {
+		sorted = append(sorted, *total)
+	}
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].Currency < sorted[j].Currency })
+
+	if err := out.Totals(sorted); err != nil {
+		return err
+	}
+	return out.Close()
+}
+
+func ExportLocales() []string {
+	locales := make([]string, 0, len(exportLocales))
+	for l := range exportLocales {
+		locales = append(locales, l)
+	}
+	sort.Strings(locales)
+	return locales
+}
+
+func isInCategories(name string, names []string) bool {
+	if len(names) == 0 {
+		return true
+	}
+	for _, n := range names {
+		if strings.EqualFold(strings.TrimSpace(n), name) {
+			return true
+		}
+	}
+	return false
+}
+
+func signedAmount(t Transaction) float64 {
+	if t.CategoryType == "+" || t.CategoryType == TRANSFER_TYPE {
+		return t.Amount
+	}
+	return -t.Amount
+}
+
+func categoryTypeName(categoryType string) string {
+	switch categoryType {
+	case "+":
+		return "income"
+	case TRANSFER_TYPE:
+		return "transfer"
+	}
+	return "expense"
+}
+
+// formatLocaleAmount writes 1234567.891 as "1.234.567,89" for de-DE.
+func formatLocaleAmount(amount float64, locale ExportLocale) string {
+	s := strconv.FormatFloat(math.Abs(amount), 'f', 2, 64)
+	intPart, frac, _ := strings.Cut(s, ".")
+
+	if locale.GroupSeparator != "" && len(intPart) > 3 {
+		var b strings.Builder
+		lead := len(intPart) % 3
+		if lead > 0 {
+			b.WriteString(intPart[:lead])
+		}
+		for i := lead; i < len(intPart); i += 3 {
+			if b.Len() > 0 {
+				b.WriteString(locale.GroupSeparator)
+			}
+			b.WriteString(intPart[i : i+3])
+		}
+		intPart = b.String()
+	}
+
+	sign := ""
+	if amount < 0 && s != "0.00" {
+		sign = "-"
+	}
+	return sign + intPart + locale.DecimalSeparator + frac
+}
+
+type csvTransactionWriter struct {
+	w      *csv.Writer
+	locale ExportLocale
+}
+
+func newCSVTransactionWriter(w io.Writer, delimiter rune, locale ExportLocale) (*csvTransactionWriter, error) {
+	// the BOM makes Excel read the file as UTF-8
+	if _, err := io.WriteString(w, "\ufeff"); err != nil {
+		return nil, err
+	}
+	cw := csv.NewWriter(w)
+	cw.Comma = delimiter
+	if err := cw.Write(transactionExportHeader); err != nil {
+		return nil, err
+	}
+	return &csvTransactionWriter{w: cw, locale: locale}, nil
+}
+
+func (c *csvTransactionWriter) Row(t Transaction) error {
+	return c.w.Write([]string{
+		t.OccurredAt.UTC().Format(c.locale.DateLayout),
+		t.CategoryName,
+		categoryTypeName(t.CategoryType),
+		formatLocaleAmount(signedAmount(t), c.locale),
+		t.Currency,
+		t.Note,
+	})
+}
+
+func (c *csvTransactionWriter) Totals(totals []currencyTotal) error {
+	if err := c.w.Write([]string{""}); err != nil {
+		return err
+	}
+	for _, total := range totals {
+		for _, line := range []struct {
+			label  string
+			amount float64
+		}{
+			{"Total income", total.Income},
+			{"Total expense", -total.Expense},
+			{"Net", total.Income - total.Expense},
+		} {
+			if err := c.w.Write([]string{line.label, "", "", formatLocaleAmount(line.amount, c.locale), total.Currency, ""}); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (c *csvTransactionWriter) Close() error {
+	c.w.Flush()
+	return c.w.Error()
+}
+
+// xlsxTransactionWriter streams a single sheet workbook. The zip entries
+// are written in order, the sheet last, so rows go straight to the client.
+type xlsxTransactionWriter struct {
+	zw    *zip.Writer
+	sheet *bufio.Writer
+	row   int
+}
+
+const xlsxContentTypes = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<Types xmlns="http://schemas.openxmlformats.org/package/2006/content-types"><Default Extension="rels" ContentType="application/vnd.openxmlformats-package.relationships+xml"/><Default Extension="xml" ContentType="application/xml"/><Override PartName="/xl/workbook.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.sheet.main+xml"/><Override PartName="/xl/worksheets/sheet1.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.worksheet+xml"/><Override PartName="/xl/styles.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.styles+xml"/></Types>`
+
+const xlsxRootRels = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships"><Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/officeDocument" Target="xl/workbook.xml"/></Relationships>`
+
+const xlsxWorkbook = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<workbook xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main" xmlns:r="http://schemas.openxmlformats.org/officeDocument/2006/relationships"><sheets><sheet name="Transactions" sheetId="1" r:id="rId1"/></sheets></workbook>`
+
+const xlsxWorkbookRels = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships"><Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/worksheet" Target="worksheets/sheet1.xml"/><Relationship Id="rId2" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/styles" Target="styles.xml"/></Relationships>`
+
+// cell styles: 0 default, 1 date and time, 2 amount, 3 bold header, 4 bold amount
+const xlsxStyles = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<styleSheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main"><numFmts count="1"><numFmt numFmtId="164" formatCode="yyyy-mm-dd hh:mm"/></numFmts><fonts count="2"><font><sz val="11"/><name val="Calibri"/></font><font><b/><sz val="11"/><name val="Calibri"/></font></fonts><fills count="2"><fill><patternFill patternType="none"/></fill><fill><patternFill patternType="gray125"/></fill></fills><borders count="1"><border><left/><right/><top/><bottom/><diagonal/></border></borders><cellStyleXfs count="1"><xf numFmtId="0" fontId="0" fillId="0" borderId="0"/></cellStyleXfs><cellXfs count="5"><xf numFmtId="0" fontId="0" fillId="0" borderId="0" xfId="0"/><xf numFmtId="164" fontId="0" fillId="0" borderId="0" xfId="0" applyNumberFormat="1"/><xf numFmtId="4" fontId="0" fillId="0" borderId="0" xfId="0" applyNumberFormat="1"/><xf numFmtId="0" fontId="1" fillId="0" borderId="0" xfId="0" applyFont="1"/><xf numFmtId="4" fontId="1" fillId="0" borderId="0" xfId="0" applyNumberFormat="1" applyFont="1"/></cellXfs></styleSheet>`
+
+func newXLSXTransactionWriter(w io.Writer) (*xlsxTransactionWriter, error) {
+	zw := zip.NewWriter(w)
+	for _, part := range []struct{ name, content string }{
+		{"[Content_Types].xml", xlsxContentTypes},
+		{"_rels/.rels", xlsxRootRels},
+		{"xl/workbook.xml", xlsxWorkbook},
+		{"xl/_rels/workbook.xml.rels", xlsxWorkbookRels},
+		{"xl/styles.xml", xlsxStyles},
+	} {
+		f, err := zw.Create(part.name)
+		if err != nil {
+			return nil, err
+		}
+		if _, err := io.WriteString(f, part.content); err != nil {
+			return nil, err
+		}
+	}
+
+	f, err := zw.Create("xl/worksheets/sheet1.xml")
+	if err != nil {
+		return nil, err
+	}
+	x := &xlsxTransactionWriter{zw: zw, sheet: bufio.NewWriter(f)}
+	x.sheet.WriteString(`<?xml version="1.0" encoding="UTF-8" standalone="yes"?>` + "\n")
+	x.sheet.WriteString(`<worksheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main"><cols><col min="1" max="1" width="18" customWidth="1"/><col min="2" max="2" width="24" customWidth="1"/><col min="6" max="6" width="40" customWidth="1"/></cols><sheetData>`)
+
+	cells := make([]xlsxCell, len(transactionExportHeader))
+	for i, h := range transactionExportHeader {
+		cells[i] = xlsxCell{text: h, style: 3}
+	}
+	if err := x.writeRow(cells); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+type xlsxCell struct {
+	text    string
+	number  float64
+	numeric bool
+	style   int
+}
+
+func (x *xlsxTransactionWriter) writeRow(cells []xlsxCell) error {
+	x.row++
+	fmt.Fprintf(x.sheet, `<row r="%d">`, x.row)
+	for i, c := range cells {
+		ref := fmt.Sprintf("%c%d", 'A'+i, x.row)
+		style := ""
+		if c.style != 0 {
+			style = fmt.Sprintf(` s="%d"`, c.style)
+		}
+		if c.numeric {
+			fmt.Fprintf(x.sheet, `<c r="%s"%s><v>%s</v></c>`, ref, style, strconv.FormatFloat(c.number, 'f', -1, 64))
+			continue
+		}
+		if c.text == "" {
+			continue
+		}
+		fmt.Fprintf(x.sheet, `<c r="%s"%s t="inlineStr"><is><t xml:space="preserve">`, ref, style)
+		if err := xml.EscapeText(x.sheet, []byte(c.text)); err != nil {
+			return err
+		}
+		x.sheet.WriteString(`</t></is></c>`)
+	}
+	_, err := x.sheet.WriteString("</row>")
+	return err
+}
+
+// excelSerialDate is the number of days since 1899-12-30, the epoch
+// spreadsheets use for dates.
+func excelSerialDate(t time.Time) float64 {
+	epoch := time.Date(1899, 12, 30, 0, 0, 0, 0, time.UTC)
+	days := t.UTC().Sub(epoch).Hours() / 24
+	return math.Round(days*86400) / 86400
+}
+
+func (x *xlsxTransactionWriter) Row(t Transaction) error {
+	return x.writeRow([]xlsxCell{
+		{number: excelSerialDate(t.OccurredAt), numeric: true, style: 1},
+		{text: t.CategoryName},
+		{text: categoryTypeName(t.CategoryType)},
+		{number: signedAmount(t), numeric: true, style: 2},
+		{text: t.Currency},
+		{text: t.Note},
+	})
+}
+
+func (x *xlsxTransactionWriter) Totals(totals []currencyTotal) error {
+	x.row++ // blank line before the footer
+	for _, total := range totals {
+		for _, line := range []struct {
+			label  string
+			amount float64
+		}{
+			{"Total income", total.Income},
+			{"Total expense", -total.Expense},
+			{"Net", total.Income - total.Expense},
+		} {
+			if err := x.writeRow([]xlsxCell{
+				{text: line.label, style: 3},
+				{},
+				{},
+				{number: line.amount, numeric: true, style: 4},
+				{text: total.Currency},
+				{},
+			}); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (x *xlsxTransactionWriter) Close() error {
+	x.sheet.WriteString(`</sheetData></worksheet>`)
+	if err := x.sheet.Flush(); err != nil {
+		return err
+	}
+	return x.zw.Close()
+}
@@ -0,0 +1,907 @@
+package budget
+
+import (
+	"archive/zip"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"reflect"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	appErrors "github.com/fatali-fataliyev/budget_tracker/customErrors"
+	"github.com/fatali-fataliyev/budget_tracker/internal/contextutil"
+	"github.com/fatali-fataliyev/budget_tracker/logging"
+	"github.com/google/uuid"
+)
+
+const (
+	EXPORT_FORMAT_NAME    = "budget_tracker_export"
+	EXPORT_FORMAT_VERSION = 2 // 2: one JSON record per line (NDJSON) instead of a JSON array
+
+	EXPORT_MANIFEST_FILE           = "manifest.json"
+	EXPORT_TRANSACTIONS_FILE       = "transactions.ndjson"
+	EXPORT_EXPENSE_CATEGORIES_FILE = "expense_categories.ndjson"
+	EXPORT_INCOME_CATEGORIES_FILE  = "income_categories.ndjson"
+	EXPORT_WALLETS_FILE            = "wallets.ndjson"
+	EXPORT_TAGS_FILE               = "tags.ndjson"
+
+	MAX_IMPORT_FILE_SIZE = 64 << 20 // 64mib uncompressed per file
+	// MAX_IMPORT_RENAME_ATTEMPTS bounds the "(imported N)" names tried for
+	// one conflicting name.
+	MAX_IMPORT_RENAME_ATTEMPTS = 100
+
+	IMPORT_CONFLICT_MERGE  = "merge"
+	IMPORT_CONFLICT_RENAME = "rename"
+	IMPORT_CONFLICT_SKIP   = "skip"
+)
+
+type ExportManifest struct {
+	Format     string         `json:"format"`
+	Version    int            `json:"version"`
+	ExportedAt time.Time      `json:"exported_at"`
+	Files      map[string]int `json:"files"`              // file name -> number of records
+	Excluded   []string       `json:"excluded,omitempty"` // data of the account the archive leaves out
+}
+
+// exportExcluded is the account data not in the archive. It points at
+// categories, wallets and payees by ID, which the import does not keep, so
+// it has to be set up again after importing. Transactions lose their payee.
+var exportExcluded = []string{
+	"recurring_transactions",
+	"savings_goals",
+	"savings_contributions",
+	"debts",
+	"debt_payments",
+	"payees",
+	"rules",
+	"category_merges",
+}
+
+// ImportOptions of a user data import. Transactions the account already has
+// are always reported as duplicates and not saved again.
+type ImportOptions struct {
+	DryRun     bool
+	OnConflict string // IMPORT_CONFLICT_MERGE, IMPORT_CONFLICT_RENAME or IMPORT_CONFLICT_SKIP
+}
+
+// ImportBatch is everything an import writes; storage saves it atomically.
+type ImportBatch struct {
+	ExpenseCategories []ExpenseCategory
+	IncomeCategories  []IncomeCategory
+	Wallets           []Wallet
+	Tags              []Tag
+	Transactions      []Transaction
+}
+
+type ImportCategoryCounts struct {
+	Created int
+	Merged  int
+	Renamed int
+	Skipped int
+}
+
+type ImportTransactionCounts struct {
+	Imported   int
+	Duplicates int
+	Skipped    int
+}
+
+type ImportRowError struct {
+	File    string
+	Index   int
+	Message string
+}
+
+type ImportReport struct {
+	DryRun            bool
+	ExpenseCategories ImportCategoryCounts
+	IncomeCategories  ImportCategoryCounts
+	Wallets           ImportCategoryCounts
+	Tags              ImportCategoryCounts
+	Transactions      ImportTransactionCounts
+	Renamed           map[string]string // old category or wallet name -> name it was imported as
+	Errors            []ImportRowError
+}
+
+// version 1 archives held every file as a single JSON array
+var exportV1Files = map[string]string{
+	EXPORT_TRANSACTIONS_FILE:       "transactions.json",
+	EXPORT_EXPENSE_CATEGORIES_FILE: "expense_categories.json",
+	EXPORT_INCOME_CATEGORIES_FILE:  "income_categories.json",
+}
+
+func NewExportManifest(files map[string]int) ExportManifest {
+	return ExportManifest{
+		Format:     EXPORT_FORMAT_NAME,
+		Version:    EXPORT_FORMAT_VERSION,
+		ExportedAt: time.Now().UTC(),
+		Files:      files,
+		Excluded:   exportExcluded,
+	}
+}
+
+// ExportUserData streams the account as a ZIP of NDJSON files. Transactions
+// come from storage one row at a time and go straight into the archive, so
+// memory use does not depend on how long the history is. The manifest is
+// written last, once the record counts are known. open is called after the
+// categories are loaded, errors before that can still be sent as JSON.
+func (bt *BudgetTracker) ExportUserData(ctx context.Context, userId string, open func() io.Writer) error {
+	traceID := contextutil.TraceIDFromContext(ctx)
+
+	expenseCategories, err := bt.storage.GetFilteredExpenseCategories(ctx, userId, &ExpenseCategoryList{IsAllNil: true})
+	if err != nil {
+		logging.Logger.Errorf("[TraceID=%s] | storage.GetFilteredExpenseCategories() failed in Service.ExportUserData()", traceID)
+		return err
+	}
+	incomeCategories, err := bt.storage.GetFilteredIncomeCategories(ctx, userId, &IncomeCategoryList{IsAllNil: true})
+	if err != nil {
+		logging.Logger.Errorf("[TraceID=%s] | storage.GetFilteredIncomeCategories() failed in Service.ExportUserData()", traceID)
+		return err
+	}
+	wallets, err := bt.storage.GetWallets(ctx, userId)
+	if err != nil {
+		logging.Logger.Errorf("[TraceID=%s] | storage.GetWallets() failed in Service.ExportUserData()", traceID)
+		return err
+	}
+	tags, err := bt.storage.GetTags(ctx, userId)
+	if err != nil {
+		logging.Logger.Errorf("[TraceID=%s] | storage.GetTags() failed in Service.ExportUserData()", traceID)
+		return err
+	}
+
+	// category names are resolved here instead of once per row in storage
+	categoryNames := make(map[string]string, len(expenseCategories)+len(incomeCategories))
+	for _, c := range expenseCategories {
+		categoryNames["-"+c.ID] = c.Name
+	}
+	for _, c := range incomeCategories {
+		categoryNames["+"+c.ID] = c.Name
+	}
+
+	zw := zip.NewWriter(open())
+	counts := make(map[string]int, 4)
+
+	writeFile := func(name string, write func(enc *json.Encoder) (int, error)) error {
+		f, err := zw.Create(name)
+		if err != nil {
+			return err
+		}
+		n, err := write(json.NewEncoder(f))
+		if err != nil {
+			return err
+		}
+		counts[name] = n
+		return nil
+	}
+
+	err = writeFile(EXPORT_EXPENSE_CATEGORIES_FILE, func(enc *json.Encoder) (int, error) {
+		for _, c := range expenseCategories {
+			if err := enc.Encode(c); err != nil {
+				return 0, err
+			}
+		}
+		return len(expenseCategories), nil
+	})
+	if err != nil {
+		logging.Logger.Errorf("[TraceID=%s] | failed to write %s in Service.ExportUserData() | Error: %v", traceID, EXPORT_EXPENSE_CATEGORIES_FILE, err)
+		return err
+	}
+
+	err = writeFile(EXPORT_INCOME_CATEGORIES_FILE, func(enc *json.Encoder) (int, error) {
+		for _, c := range incomeCategories {
+			if err := enc.Encode(c); err != nil {
+				return 0, err
+			}
+		}
+		return len(incomeCategories), nil
+	})
+	if err != nil {
+		logging.Logger.Errorf("[TraceID=%s] | failed to write %s in Service.ExportUserData() | Error: %v", traceID, EXPORT_INCOME_CATEGORIES_FILE, err)
+		return err
+	}
+
+	err = writeFile(EXPORT_WALLETS_FILE, func(enc *json.Encoder) (int, error) {
+		for _, w := range wallets {
+			if err := enc.Encode(w); err != nil {
+				return 0, err
+			}
+		}
+		return len(wallets), nil
+	})
+	if err != nil {
+		logging.Logger.Errorf("[TraceID=%s] | failed to write %s in Service.ExportUserData() | Error: %v", traceID, EXPORT_WALLETS_FILE, err)
+		return err
+	}
+
+	err = writeFile(EXPORT_TAGS_FILE, func(enc *json.Encoder) (int, error) {
+		for _, tag := range tags {
+			if err := enc.Encode(tag); err != nil {
+				return 0, err
+			}
+		}
+		return len(tags), nil
+	})
+	if err != nil {
+		logging.Logger.Errorf("[TraceID=%s] | failed to write %s in Service.ExportUserData() | Error: %v", traceID, EXPORT_TAGS_FILE, err)
+		return err
+	}
+
+	err = writeFile(EXPORT_TRANSACTIONS_FILE, func(enc *json.Encoder) (int, error) {
+		n := 0
+		err := bt.storage.EachTransaction(ctx, userId, func(t Transaction) error {
+			t.CategoryName = categoryNames[t.CategoryType+t.CategoryId]
+			for i := range t.Splits {
+				t.Splits[i].CategoryName = categoryNames[t.CategoryType+t.Splits[i].CategoryId]
+			}
+			n++
+			return enc.Encode(t)
+		})
+		return n, err
+	})
+	if err != nil {
+		logging.Logger.Errorf("[TraceID=%s] | failed to write %s in Service.ExportUserData() | Error: %v", traceID, EXPORT_TRANSACTIONS_FILE, err)
+		return err
+	}
+
+	f, err := zw.Create(EXPORT_MANIFEST_FILE)
+	if err != nil {
+		return err
+	}
+	if err := json.NewEncoder(f).Encode(NewExportManifest(counts)); err != nil {
+		return err
+	}
+	return zw.Close()
+}
+
+// ReadUserDataArchive parses a ZIP produced by the data export. Archives
+// from before the manifest existed are accepted as version 1.
+func ReadUserDataArchive(r io.ReaderAt, size int64) (UserDataResponse, error) {
+	archive, err := zip.NewReader(r, size)
+	if err != nil {
+		return UserDataResponse{}, appErrors.ErrorResponse{
+			Code:    appErrors.ErrInvalidInput,
+			Message: "The file is not a valid ZIP archive.",
+		}
+	}
+
+	files := make(map[string]*zip.File, len(archive.File))
+	for _, f := range archive.File {
+		files[f.Name] = f
+	}
+
+	manifest := ExportManifest{Format: EXPORT_FORMAT_NAME, Version: 1}
+	if f, ok := files[EXPORT_MANIFEST_FILE]; ok {
+		if err := readArchiveJSON(f, &manifest); err != nil {
+			return UserDataResponse{}, err
+		}
+	}
+	if manifest.Format != EXPORT_FORMAT_NAME {
+		return UserDataResponse{}, appErrors.ErrorResponse{
+			Code:    appErrors.ErrInvalidInput,
+			Message: "The archive is not a Budget Tracker export.",
+		}
+	}
+	if manifest.Version < 1 || manifest.Version > EXPORT_FORMAT_VERSION {
+		return UserDataResponse{}, appErrors.ErrorResponse{
+			Code:    appErrors.ErrInvalidInput,
+			Message: fmt.Sprintf("Unsupported export version %d, supported up to %d.", manifest.Version, EXPORT_FORMAT_VERSION),
+		}
+	}
+
+	var data UserDataResponse
+	targets := map[string]interface{}{
+		EXPORT_TRANSACTIONS_FILE:       &data.Transactions,
+		EXPORT_EXPENSE_CATEGORIES_FILE: &data.ExpenseCategories,
+		EXPORT_INCOME_CATEGORIES_FILE:  &data.IncomeCategories,
+		EXPORT_WALLETS_FILE:            &data.Wallets,
+		EXPORT_TAGS_FILE:               &data.Tags,
+	}
+	found := false
+	for name, target := range targets {
+		read := readArchiveNDJSON
+		if manifest.Version == 1 {
+			name = exportV1Files[name]
+			read = readArchiveJSON
+		}
+		f, ok := files[name]
+		if !ok {
+			// wallets.ndjson is missing from archives made before wallets
+			continue
+		}
+		found = true
+		if err := read(f, target); err != nil {
+			return UserDataResponse{}, err
+		}
+	}
+	if !found {
+		return UserDataResponse{}, appErrors.ErrorResponse{
+			Code:    appErrors.ErrInvalidInput,
+			Message: "The archive does not contain any exported data.",
+		}
+	}
+
+	return data, nil
+}
+
+func readArchiveJSON(f *zip.File, target interface{}) error {
+	if f.UncompressedSize64 > MAX_IMPORT_FILE_SIZE {
+		return appErrors.ErrorResponse{
+			Code:    appErrors.ErrInvalidInput,
+			Message: fmt.Sprintf("%s is too large to import.", f.Name),
+		}
+	}
+
+	rc, err := f.Open()
+	if err != nil {
+		return appErrors.ErrorResponse{
+			Code:    appErrors.ErrInvalidInput,
+			Message: fmt.Sprintf("Failed to open %s in the archive.", f.Name),
+		}
+	}
+	defer rc.Close()
+
+	if err := json.NewDecoder(io.LimitReader(rc, MAX_IMPORT_FILE_SIZE)).Decode(target); err != nil {
+		return appErrors.ErrorResponse{
+			Code:    appErrors.ErrInvalidInput,
+			Message: fmt.Sprintf("%s is not valid JSON: %v", f.Name, err),
+		}
+	}
+	return nil
+}
+
+// readArchiveNDJSON appends every line of f to target, a pointer to a slice.
+func readArchiveNDJSON(f *zip.File, target interface{}) error {
+	if f.UncompressedSize64 > MAX_IMPORT_FILE_SIZE {
+		return appErrors.ErrorResponse{
+			Code:    appErrors.ErrInvalidInput,
+			Message: fmt.Sprintf("%s is too large to import.", f.Name),
+		}
+	}
+
+	rc, err := f.Open()
+	if err != nil {
+		return appErrors.ErrorResponse{
+			Code:    appErrors.ErrInvalidInput,
+			Message: fmt.Sprintf("Failed to open %s in the archive.", f.Name),
+		}
+	}
+	defer rc.Close()
+
+	slice := reflect.ValueOf(target).Elem()
+	decoder := json.NewDecoder(io.LimitReader(rc, MAX_IMPORT_FILE_SIZE))
+	for line := 1; ; line++ {
+		item := reflect.New(slice.Type().Elem())
+		if err := decoder.Decode(item.Interface()); err == io.EOF {
+			return nil
+		} else if err != nil {
+			return appErrors.ErrorResponse{
+				Code:    appErrors.ErrInvalidInput,
+				Message: fmt.Sprintf("%s record %d is not valid JSON: %v", f.Name, line, err),
+			}
+		}
+		slice.Set(reflect.Append(slice, item.Elem()))
+	}
+}
+
+// ImportUserData restores an export into the account. Category IDs from the
+// archive are replaced with fresh ones, categories whose name already exists
+// are merged, renamed or skipped per opts.OnConflict, and transactions that
+// are already in the account are not duplicated. With opts.DryRun only the
+// report is produced.
+func (bt *BudgetTracker) ImportUserData(ctx context.Context, userId string, data UserDataResponse, opts ImportOptions) (ImportReport, error) {
+	traceID := contextutil.TraceIDFromContext(ctx)
+
+	switch opts.OnConflict {
+	case "":
+		opts.OnConflict = IMPORT_CONFLICT_MERGE
+	case IMPORT_CONFLICT_MERGE, IMPORT_CONFLICT_RENAME, IMPORT_CONFLICT_SKIP:
+	default:
+		return ImportReport{}, appErrors.ErrorResponse{
+			Code:    appErrors.ErrInvalidInput,
+			Message: "Invalid conflict strategy, allowed values: merge, rename, skip",
+		}
+	}
+
+	existingExpense, err := bt.storage.GetFilteredExpenseCategories(ctx, userId, &ExpenseCategoryList{IsAllNil: true})
+	if err != nil {
+		return ImportReport{}, err
+	}
+	existingIncome, err := bt.storage.GetFilteredIncomeCategories(ctx, userId, &IncomeCategoryList{IsAllNil: true})
+	if err != nil {
+		return ImportReport{}, err
+	}
+	existingTransactions, err := bt.storage.GetFilteredTransactions(ctx, userId, &TransactionList{IsAllNil: true})
+	if err != nil {
+		return ImportReport{}, err
+	}
+
+	report := ImportReport{DryRun: opts.DryRun, Renamed: map[string]string{}}
+	var batch ImportBatch
+	now := time.Now().UTC()
+
+	// category key is type + old ID and type + name, so transactions can be
+	// matched even when only the category name survived in the file.
+	categoryMap := map[string]string{}
+	skippedCategories := map[string]bool{}
+	archivedParents := map[string]string{} // new category ID -> parent ID in the archive
+
+	expenseNames := map[string]string{}
+	for _, c := range existingExpense {
+		expenseNames[c.Name] = c.ID
+	}
+	for i, c := range data.ExpenseCategories {
+		name := strings.ToLower(strings.TrimSpace(c.Name))
+		if name == "" || len(name) > MAX_CATEGORY_NAME_LENGTH {
+			report.Errors = append(report.Errors, ImportRowError{File: EXPORT_EXPENSE_CATEGORIES_FILE, Index: i, Message: "Invalid category name"})
+			report.ExpenseCategories.Skipped++
+			continue
+		}
+
+		newId, finalName, outcome, err := resolveImportedCategory(name, expenseNames, opts.OnConflict, MAX_CATEGORY_NAME_LENGTH)
+		if err != nil {
+			report.Errors = append(report.Errors, ImportRowError{File: EXPORT_EXPENSE_CATEGORIES_FILE, Index: i, Message: importErrorMessage(err)})
+			report.ExpenseCategories.Skipped++
+			skippedCategories["-"+c.ID] = true
+			skippedCategories["-name:"+name] = true
+			continue
+		}
+		switch outcome {
+		case IMPORT_CONFLICT_SKIP:
+			report.ExpenseCategories.Skipped++
+			skippedCategories["-"+c.ID] = true
+			skippedCategories["-name:"+name] = true
+			continue
+		case IMPORT_CONFLICT_MERGE:
+			report.ExpenseCategories.Merged++
+		case IMPORT_CONFLICT_RENAME:
+			report.ExpenseCategories.Renamed++
+			report.Renamed[name] = finalName
+		default:
+			report.ExpenseCategories.Created++
+		}
+
+		if newId == "" {
+			newId = uuid.New().String()
+			expenseNames[finalName] = newId
+			batch.ExpenseCategories = append(batch.ExpenseCategories, ExpenseCategory{
+				ID:         newId,
+				Name:       finalName,
+				MaxAmount:  c.MaxAmount,
+				PeriodDay:  c.PeriodDay,
+				CreatedAt:  importTime(c.CreatedAt, now),
+				UpdatedAt:  now,
+				Note:       c.Note,
+				CreatedBy:  userId,
+				Type:       "-",
+				ArchivedAt: c.ArchivedAt,
+			})
+			if c.ParentId != "" {
+				archivedParents[newId] = "-" + c.ParentId
+			}
+		}
+		categoryMap["-"+c.ID] = newId
+		categoryMap["-name:"+name] = newId
+	}
+
+	incomeNames := map[string]string{}
+	for _, c := range existingIncome {
+		incomeNames[c.Name] = c.ID
+	}
+	for i, c := range data.IncomeCategories {
+		name := strings.ToLower(strings.TrimSpace(c.Name))
+		if name == "" || len(name) > MAX_CATEGORY_NAME_LENGTH {
+			report.Errors = append(report.Errors, ImportRowError{File: EXPORT_INCOME_CATEGORIES_FILE, Index: i, Message: "Invalid category name"})
+			report.IncomeCategories.Skipped++
+			continue
+		}
+
+		newId, finalName, outcome, err := resolveImportedCategory(name, incomeNames, opts.OnConflict, MAX_CATEGORY_NAME_LENGTH)
+		if err != nil {
+			report.Errors = append(report.Errors, ImportRowError{File: EXPORT_INCOME_CATEGORIES_FILE, Index: i, Message: importErrorMessage(err)})
+			report.IncomeCategories.Skipped++
+			skippedCategories["+"+c.ID] = true
+			skippedCategories["+name:"+name] = true
+			continue
+		}
+		switch outcome {
+		case IMPORT_CONFLICT_SKIP:
+			report.IncomeCategories.Skipped++
+			skippedCategories["+"+c.ID] = true
+			skippedCategories["+name:"+name] = true
+			continue
+		case IMPORT_CONFLICT_MERGE:
+			report.IncomeCategories.Merged++
+		case IMPORT_CONFLICT_RENAME:
+			report.IncomeCategories.Renamed++
+			report.Renamed[name] = finalName
+		default:
+			report.IncomeCategories.Created++
+		}
+
+		if newId == "" {
+			newId = uuid.New().String()
+			incomeNames[finalName] = newId
+			batch.IncomeCategories = append(batch.IncomeCategories, IncomeCategory{
+				ID:           newId,
+				Name:         finalName,
+				TargetAmount: int(c.TargetAmount),
+				CreatedAt:    importTime(c.CreatedAt, now),
+				UpdatedAt:    now,
+				Note:         c.Note,
+				CreatedBy:    userId,
+				Type:         "+",
+				ArchivedAt:   c.ArchivedAt,
+			})
+			if c.ParentId != "" {
+				archivedParents[newId] = "+" + c.ParentId
+			}
+		}
+		categoryMap["+"+c.ID] = newId
+		categoryMap["+name:"+name] = newId
+	}
+
+	// new subcategories keep their parent when it was imported or merged,
+	// categories that already exist are not moved
+	expenseParents := make(map[string]string, len(existingExpense)+len(batch.ExpenseCategories))
+	for _, c := range existingExpense {
+		expenseParents[c.ID] = c.ParentId
+	}
+	for _, c := range batch.ExpenseCategories {
+		expenseParents[c.ID] = ""
+	}
+	for i := range batch.ExpenseCategories {
+		c := &batch.ExpenseCategories[i]
+		parentId, ok := categoryMap[archivedParents[c.ID]]
+		if ok && checkCategoryParent(expenseParents, c.ID, parentId) == nil {
+			c.ParentId = parentId
+			expenseParents[c.ID] = parentId
+		}
+	}
+	incomeParents := make(map[string]string, len(existingIncome)+len(batch.IncomeCategories))
+	for _, c := range existingIncome {
+		incomeParents[c.ID] = c.ParentId
+	}
+	for _, c := range batch.IncomeCategories {
+		incomeParents[c.ID] = ""
+	}
+	for i := range batch.IncomeCategories {
+		c := &batch.IncomeCategories[i]
+		parentId, ok := categoryMap[archivedParents[c.ID]]
+		if ok && checkCategoryParent(incomeParents, c.ID, parentId) == nil {
+			c.ParentId = parentId
+			incomeParents[c.ID] = parentId
+		}
+	}
+
+	existingWallets, err := bt.storage.GetWallets(ctx, userId)
+	if err != nil {
+		return ImportReport{}, err
+	}
+	walletMap := map[string]string{}
+	skippedWallets := map[string]bool{}
+	walletNames := map[string]string{}
+	walletCurrencies := map[string]string{}
+	for _, w := range existingWallets {
+		walletNames[w.Name] = w.ID
+		walletCurrencies[w.ID] = w.Currency
+	}
+	for i, w := range data.Wallets {
+		w.Name = strings.TrimSpace(w.Name)
+		w.Currency = strings.ToUpper(strings.TrimSpace(w.Currency))
+		if err := validateWalletRequest(WalletRequest{Name: w.Name, Type: w.Type, Currency: w.Currency, OpeningBalance: w.OpeningBalance}); err != nil {
+			report.Errors = append(report.Errors, ImportRowError{File: EXPORT_WALLETS_FILE, Index: i, Message: importErrorMessage(err)})
+			report.Wallets.Skipped++
+			skippedWallets[w.ID] = true
+			continue
+		}
+
+		newId, finalName, outcome, err := resolveImportedCategory(w.Name, walletNames, opts.OnConflict, MAX_WALLET_NAME_LENGTH)
+		if err == nil && outcome == IMPORT_CONFLICT_MERGE && walletCurrencies[newId] != w.Currency {
+			// the transactions of the archived wallet are in another currency
+			newId, finalName, outcome, err = resolveImportedCategory(w.Name, walletNames, IMPORT_CONFLICT_RENAME, MAX_WALLET_NAME_LENGTH)
+		}
+		if err != nil {
+			report.Errors = append(report.Errors, ImportRowError{File: EXPORT_WALLETS_FILE, Index: i, Message: importErrorMessage(err)})
+			report.Wallets.Skipped++
+			skippedWallets[w.ID] = true
+			continue
+		}
+		switch outcome {
+		case IMPORT_CONFLICT_SKIP:
+			report.Wallets.Skipped++
+			skippedWallets[w.ID] = true
+			continue
+		case IMPORT_CONFLICT_MERGE:
+			report.Wallets.Merged++
+		case IMPORT_CONFLICT_RENAME:
+			report.Wallets.Renamed++
+			report.Renamed[w.Name] = finalName
+		default:
+			report.Wallets.Created++
+		}
+
+		if newId == "" {
+			newId = uuid.New().String()
+			walletNames[finalName] = newId
+			walletCurrencies[newId] = w.Currency
+			batch.Wallets = append(batch.Wallets, Wallet{
+				ID:             newId,
+				Name:           finalName,
+				Type:           w.Type,
+				Currency:       w.Currency,
+				OpeningBalance: w.OpeningBalance,
+				CreatedAt:      importTime(w.CreatedAt, now),
+				UpdatedAt:      now,
+				CreatedBy:      userId,
+			})
+		}
+		walletMap[w.ID] = newId
+	}
+
+	// tags are matched by name, a tag the account already has is merged
+	existingTags, err := bt.storage.GetTags(ctx, userId)
+	if err != nil {
+		return ImportReport{}, err
+	}
+	tagNames := make(map[string]bool, len(existingTags))
+	for _, tag := range existingTags {
+		tagNames[tag.Name] = true
+	}
+	addTag := func(name string, createdAt time.Time) {
+		tagNames[name] = true
+		batch.Tags = append(batch.Tags, Tag{
+			ID:        uuid.New().String(),
+			Name:      name,
+			CreatedAt: importTime(createdAt, now),
+			UpdatedAt: now,
+			CreatedBy: userId,
+		})
+	}
+	for i, tag := range data.Tags {
+		name, err := normalizeTagName(tag.Name)
+		if err != nil {
+			report.Errors = append(report.Errors, ImportRowError{File: EXPORT_TAGS_FILE, Index: i, Message: importErrorMessage(err)})
+			report.Tags.Skipped++
+			continue
+		}
+		if tagNames[name] {
+			report.Tags.Merged++
+			continue
+		}
+		if len(existingTags)+len(batch.Tags) >= MAX_TAGS_PER_USER {
+			report.Errors = append(report.Errors, ImportRowError{File: EXPORT_TAGS_FILE, Index: i, Message: fmt.Sprintf("Maximum %d tags are allowed", MAX_TAGS_PER_USER)})
+			report.Tags.Skipped++
+			continue
+		}
+		addTag(name, tag.CreatedAt)
+		report.Tags.Created++
+	}
+
+	existingKeys := make(map[string]bool, len(existingTransactions))
+	for _, t := range existingTransactions {
+		existingKeys[transactionImportKey(t.CategoryId, t)] = true
+	}
+
+	for i, t := range data.Transactions {
+		if t.CategoryType != "+" && t.CategoryType != "-" && t.CategoryType != TRANSFER_TYPE {
+			report.Errors = append(report.Errors, ImportRowError{File: EXPORT_TRANSACTIONS_FILE, Index: i, Message: "Invalid category type"})
+			report.Transactions.Skipped++
+			continue
+		}
+
+		if (t.WalletId != "" && skippedWallets[t.WalletId]) || (t.ToWalletId != "" && skippedWallets[t.ToWalletId]) {
+			report.Transactions.Skipped++
+			continue
+		}
+		walletId, ok := walletMap[t.WalletId]
+		toWalletId, toOk := walletMap[t.ToWalletId]
+		if (t.WalletId != "" && !ok) || (t.ToWalletId != "" && !toOk) {
+			report.Errors = append(report.Errors, ImportRowError{File: EXPORT_TRANSACTIONS_FILE, Index: i, Message: "Transaction wallet is not in the archive"})
+			report.Transactions.Skipped++
+			continue
+		}
+
+		categoryId := ""
+		var splits []TransactionSplitRequest
+		if t.CategoryType != TRANSFER_TYPE {
+			nameKey := t.CategoryType + "name:" + strings.ToLower(t.CategoryName)
+			if skippedCategories[t.CategoryType+t.CategoryId] || skippedCategories[nameKey] {
+				report.Transactions.Skipped++
+				continue
+			}
+
+			categoryId, ok = categoryMap[t.CategoryType+t.CategoryId]
+			if !ok {
+				categoryId, ok = categoryMap[nameKey]
+			}
+			if !ok {
+				report.Errors = append(report.Errors, ImportRowError{File: EXPORT_TRANSACTIONS_FILE, Index: i, Message: "Transaction category is not in the archive"})
+				report.Transactions.Skipped++
+				continue
+			}
+
+			skipped := false
+			for _, split := range t.Splits {
+				splitNameKey := t.CategoryType + "name:" + strings.ToLower(split.CategoryName)
+				if skippedCategories[t.CategoryType+split.CategoryId] || skippedCategories[splitNameKey] {
+					skipped = true
+					break
+				}
+				splitCategoryId, found := categoryMap[t.CategoryType+split.CategoryId]
+				if !found {
+					splitCategoryId, found = categoryMap[splitNameKey]
+				}
+				if !found {
+					ok = false
+					break
+				}
+				splits = append(splits, TransactionSplitRequest{CategoryId: splitCategoryId, Amount: split.Amount, Note: split.Note})
+			}
+			if skipped {
+				report.Transactions.Skipped++
+				continue
+			}
+			if !ok {
+				report.Errors = append(report.Errors, ImportRowError{File: EXPORT_TRANSACTIONS_FILE, Index: i, Message: "Split category is not in the archive"})
+				report.Transactions.Skipped++
+				continue
+			}
+		}
+
+		if t.OccurredAt.IsZero() {
+			// exports made before transactions had their own date
+			t.OccurredAt = t.CreatedAt
+		}
+		t.OccurredAt = importTime(t.OccurredAt, now)
+
+		request := TransactionRequest{
+			CategoryId:   categoryId,
+			CategoryType: t.CategoryType,
+			Amount:       t.Amount,
+			Currency:     t.Currency,
+			Note:         t.Note,
+			OccurredAt:   t.OccurredAt,
+			Splits:       splits,
+			WalletId:     walletId,
+			ToWalletId:   toWalletId,
+		}
+		if len(splits) > 0 {
+			request.CategoryId = ""
+			categoryId = splits[0].CategoryId
+		}
+		if err := validateTransactionRequest(request); err != nil {
+			report.Errors = append(report.Errors, ImportRowError{File: EXPORT_TRANSACTIONS_FILE, Index: i, Message: importErrorMessage(err)})
+			report.Transactions.Skipped++
+			continue
+		}
+		tags, err := normalizeTags(t.Tags)
+		if err == nil {
+			added := 0
+			for _, name := range tags {
+				if !tagNames[name] {
+					added++
+				}
+			}
+			if len(existingTags)+len(batch.Tags)+added > MAX_TAGS_PER_USER {
+				err = appErrors.ErrorResponse{
+					Code:    appErrors.ErrInvalidInput,
+					Message: fmt.Sprintf("Maximum %d tags are allowed", MAX_TAGS_PER_USER),
+				}
+			}
+		}
+		if err != nil {
+			report.Errors = append(report.Errors, ImportRowError{File: EXPORT_TRANSACTIONS_FILE, Index: i, Message: importErrorMessage(err)})
+			report.Transactions.Skipped++
+			continue
+		}
+
+		key := transactionImportKey(categoryId, t)
+		if existingKeys[key] {
+			report.Transactions.Duplicates++
+			continue
+		}
+		existingKeys[key] = true
+
+		batch.Transactions = append(batch.Transactions, Transaction{
+			ID:           uuid.New().String(),
+			CategoryId:   categoryId,
+			CategoryType: t.CategoryType,
+			Amount:       t.Amount,
+			Currency:     t.Currency,
+			OccurredAt:   t.OccurredAt,
+			CreatedAt:    importTime(t.CreatedAt, now),
+			Note:         t.Note,
+			CreatedBy:    userId,
+			Splits:       newTransactionSplits(splits),
+			WalletId:     walletId,
+			ToWalletId:   toWalletId,
+			Tags:         tags,
+		})
+		for _, name := range tags {
+			if !tagNames[name] {
+				// archives from other tools may tag transactions without a tags file
+				addTag(name, now)
+				report.Tags.Created++
+			}
+		}
+		report.Transactions.Imported++
+	}
+
+	if opts.DryRun {
+		return report, nil
+	}
+
+	if err := bt.storage.SaveImportBatch(ctx, userId, batch); err != nil {
+		logging.Logger.Errorf("[TraceID=%s] | storage.SaveImportBatch() failed in Service.ImportUserData()", traceID)
+		return ImportReport{}, err
+	}
+	bt.forgetCategories(userId)
+
+	return report, nil
+}
+
+// resolveImportedCategory returns the ID to reuse (empty when a new category
+// is needed), the final name and which conflict rule was applied, or "" when
+// there was no conflict. A renamed name is cut short so the suffix fits in
+// maxLength, it fails when no free name is found.
+func resolveImportedCategory(name string, existing map[string]string, onConflict string, maxLength int) (string, string, string, error) {
+	id, taken := existing[name]
+	if !taken {
+		return "", name, "", nil
+	}
+
+	switch onConflict {
+	case IMPORT_CONFLICT_SKIP:
+		return "", name, IMPORT_CONFLICT_SKIP, nil
+	case IMPORT_CONFLICT_RENAME:
+		for n := 1; n <= MAX_IMPORT_RENAME_ATTEMPTS; n++ {
+			suffix := " (imported)"
+			if n > 1 {
+				suffix = fmt.Sprintf(" (imported %d)", n)
+			}
+			candidate := truncateName(name, maxLength-len(suffix)) + suffix
+			if _, taken := existing[candidate]; !taken {
+				return "", candidate, IMPORT_CONFLICT_RENAME, nil
+			}
+		}
+		return "", name, IMPORT_CONFLICT_RENAME, appErrors.ErrorResponse{
+			Code:    appErrors.ErrInvalidInput,
+			Message: "No free name left to rename the imported item to",
+		}
+	default:
+		return id, name, IMPORT_CONFLICT_MERGE, nil
+	}
+}
+
+// truncateName cuts a name to at most max bytes without splitting a
+// character.
+func truncateName(name string, max int) string {
+	if len(name) <= max {
+		return name
+	}
+	for max > 0 && !utf8.RuneStart(name[max]) {
+		max--
+	}
+	return strings.TrimRight(name[:max], " ")
+}
+
+func transactionImportKey(categoryId string, t Transaction) string {
+	return fmt.Sprintf("%s|%s|%.2f|%s|%s|%s", categoryId, t.CategoryType, t.Amount, t.Currency, t.OccurredAt.UTC().Format(time.RFC3339), t.Note)
+}
+
+func importTime(t time.Time, fallback time.Time) time.Time {
+	if t.IsZero() {
+		return fallback
+	}
+	return t.UTC()
+}
+
+func importErrorMessage(err error) string {
+	if appErr, ok := err.(appErrors.ErrorResponse); ok {
+		return appErr.Message
+	}
+	return err.Error()
+}
@@ -0,0 +1,308 @@
+package budget
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	appErrors "github.com/fatali-fataliyev/budget_tracker/customErrors"
+	"github.com/fatali-fataliyev/budget_tracker/internal/contextutil"
+	"github.com/fatali-fataliyev/budget_tracker/logging"
+	"github.com/google/uuid"
+)
+
+const (
+	WALLET_CASH        = "cash"
+	WALLET_CHECKING    = "checking"
+	WALLET_CREDIT_CARD = "credit_card"
+	WALLET_SAVINGS     = "savings"
+
+	// TRANSFER_TYPE is the category type of a transfer between two wallets.
+	// Transfers have no category and are neither income nor expense.
+	TRANSFER_TYPE = ">"
+
+	MAX_WALLET_NAME_LENGTH = 255
+	MAX_WALLETS_PER_USER   = 100
+)
+
+var walletTypes = map[string]bool{
+	WALLET_CASH:        true,
+	WALLET_CHECKING:    true,
+	WALLET_CREDIT_CARD: true,
+	WALLET_SAVINGS:     true,
+}
+
+// Wallet is an account where money lives. Its balance is the opening balance
+// plus income, minus expenses, plus or minus transfers; a credit card usually
+// has a negative balance.
+type Wallet struct {
+	ID             string
+	Name           string
+	Type           string
+	Currency       string
+	OpeningBalance float64
+	Balance        float64 // filled by storage when reading
+	CreatedAt      time.Time
+	UpdatedAt      time.Time
+	CreatedBy      string
+}
+
+type WalletRequest struct {
+	Name           string
+	Type           string
+	Currency       string
+	OpeningBalance float64
+}
+
+// WalletEntry is a transaction as seen from one wallet: Amount is signed from
+// the wallet's side and Balance is the running balance after it.
+type WalletEntry struct {
+	Transaction Transaction
+	Amount      float64
+	Balance     float64
+}
+
+func validateWalletRequest(req WalletRequest) error {
+	name := strings.TrimSpace(req.Name)
+	if name == "" {
+		return appErrors.ErrorResponse{
+			Code:    appErrors.ErrInvalidInput,
+			Message: "Wallet name cannot be empty!",
+		}
+	}
+	if len(name) > MAX_WALLET_NAME_LENGTH {
+		return appErrors.ErrorResponse{
+			Code:    appErrors.ErrInvalidInput,
+			Message: fmt.Sprintf("Wallet name so long, maximum allowed length is %d", MAX_WALLET_NAME_LENGTH),
+		}
+	}
+	if !walletTypes[req.Type] {
+		return appErrors.ErrorResponse{
+			Code:    appErrors.ErrInvalidInput,
+			Message: "Wallet type must be one of: cash, checking, credit_card, savings",
+		}
+	}
+	if req.Currency == "" || len(req.Currency) > MAX_TRANSACTION_CURRENCY_LENGTH {
+		return appErrors.ErrorResponse{
+			Code:    appErrors.ErrInvalidInput,
+			Message: "Wallet currency is required",
+		}
+	}
+	if req.OpeningBalance > MAX_TRANSACTION_AMOUNT_LIMIT || req.OpeningBalance < -MAX_TRANSACTION_AMOUNT_LIMIT {
+		return appErrors.ErrorResponse{
+			Code:    appErrors.ErrInvalidInput,
+			Message: fmt.Sprintf("Maximum allowed opening balance is %d", MAX_TRANSACTION_AMOUNT_LIMIT),
+		}
+	}
+	return nil
+}
+
+func (bt *BudgetTracker) SaveWallet(ctx context.Context, userId string, req WalletRequest) (Wallet, error) {
+	traceID := contextutil.TraceIDFromContext(ctx)
+
+	req.Currency = strings.ToUpper(strings.TrimSpace(req.Currency))
+	if err := validateWalletRequest(req); err != nil {
+		return Wallet{}, err
+	}
+
+	existing, err := bt.storage.GetWallets(ctx, userId)
+	if err != nil {
+		logging.Logger.Errorf("[TraceID=%s] | storage.GetWallets() failed in Service.SaveWallet()", traceID)
+		return Wallet{}, err
+	}
+	if len(existing) >= MAX_WALLETS_PER_USER {
+		return Wallet{}, appErrors.ErrorResponse{
+			Code:    appErrors.ErrInvalidInput,
+			Message: fmt.Sprintf("Maximum %d wallets are allowed", MAX_WALLETS_PER_USER),
+		}
+	}
+
+	now := time.Now().UTC()
+	w := Wallet{
+		ID:             uuid.New().String(),
+		Name:           strings.TrimSpace(req.Name),
+		Type:           req.Type,
+		Currency:       req.Currency,
+		OpeningBalance: req.OpeningBalance,
+		Balance:        req.OpeningBalance,
+		CreatedAt:      now,
+		UpdatedAt:      now,
+		CreatedBy:      userId,
+	}
+	if err := bt.storage.SaveWallet(ctx, w); err != nil {
+		logging.Logger.Errorf("[TraceID=%s] | storage.SaveWallet() failed in Service.SaveWallet()", traceID)
+		return Wallet{}, err
+	}
+	return w, nil
+}
+
+func (bt *BudgetTracker) GetWallets(ctx context.Context, userId string) ([]Wallet, error) {
+	return bt.storage.GetWallets(ctx, userId)
+}
+
+// UpdateWallet changes the name, type and opening balance of a wallet. The
+// currency is fixed once the wallet exists, its transactions are in it.
+func (bt *BudgetTracker) UpdateWallet(ctx context.Context, userId string, id string, req WalletRequest) (Wallet, error) {
+	w, err := bt.storage.GetWalletById(ctx, userId, id)
+	if err != nil {
+		return Wallet{}, err
+	}
+
+	req.Currency = strings.ToUpper(strings.TrimSpace(req.Currency))
+	if req.Currency == "" {
+		req.Currency = w.Currency
+	}
+	if req.Currency != w.Currency {
+		return Wallet{}, appErrors.ErrorResponse{
+			Code:    appErrors.ErrInvalidInput,
+			Message: "The currency of a wallet cannot be changed",
+		}
+	}
+	if err := validateWalletRequest(req); err != nil {
+		return Wallet{}, err
+	}
+
+	w.Balance += req.OpeningBalance - w.OpeningBalance
+	w.Name = strings.TrimSpace(req.Name)
+	w.Type = req.Type
+	w.OpeningBalance = req.OpeningBalance
+	w.UpdatedAt = time.Now().UTC()
+	if err := bt.storage.UpdateWallet(ctx, w); err != nil {
+		return Wallet{}, err
+	}
+	return w, nil
+}
+
+// DeleteWallet removes a wallet that no transaction refers to.
+func (bt *BudgetTracker) DeleteWallet(ctx context.Context, userId string, id string) error {
+	return bt.storage.DeleteWallet(ctx, userId, id)
+}
+
+// GetWalletRegister lists the transactions of a wallet oldest first, each with
+// the wallet's balance after it.
+func (bt *BudgetTracker) GetWalletRegister(ctx context.Context, userId string, id string) (Wallet, []WalletEntry, error) {
+	traceID := contextutil.TraceIDFromContext(ctx)
+
+	w, err := bt.storage.GetWalletById(ctx, userId, id)
+	if err != nil {
+		return Wallet{}, nil, err
+	}
+	transactions, err := bt.storage.GetWalletTransactions(ctx, userId, id)
+	if err != nil {
+		logging.Logger.Errorf("[TraceID=%s] | storage.GetWalletTransactions() failed in Service.GetWalletRegister()", traceID)
+		return Wallet{}, nil, err
+	}
+
+	entries := make([]WalletEntry, 0, len(transactions))
+	balance := toCents(w.OpeningBalance)
+	for _, t := range transactions {
+		amount := walletAmount(t, id)
+		balance += toCents(amount)
+		entries = append(entries, WalletEntry{Transaction: t, Amount: amount, Balance: float64(balance) / 100})
+	}
+	return w, entries, nil
+}
+
+// walletAmount is what a transaction adds to the given wallet.
+func walletAmount(t Transaction, walletId string) float64 {
+	switch {
+	case t.CategoryType == TRANSFER_TYPE && t.ToWalletId == walletId:
+		return t.Amount
+	case t.CategoryType == "+" && t.WalletId == walletId:
+		return t.Amount
+	case t.WalletId == walletId:
+		return -t.Amount
+	}
+	return 0
+}
+
+func validateTransfer(transaction TransactionRequest) error {
+	if transaction.CategoryId != "" || len(transaction.Splits) > 0 {
+		return appErrors.ErrorResponse{
+			Code:    appErrors.ErrInvalidInput,
+			Message: "A transfer has no category.",
+		}
+	}
+	if transaction.WalletId == "" || transaction.ToWalletId == "" {
+		return appErrors.ErrorResponse{
+			Code:    appErrors.ErrInvalidInput,
+			Message: "A transfer needs both wallet ID and destination wallet ID.",
+		}
+	}
+	if transaction.WalletId == transaction.ToWalletId {
+		return appErrors.ErrorResponse{
+			Code:    appErrors.ErrInvalidInput,
+			Message: "Cannot transfer to the same wallet.",
+		}
+	}
+	if transaction.Amount < 0 {
+		return appErrors.ErrorResponse{
+			Code:    appErrors.ErrInvalidInput,
+			Message: "Transfer amount must be greater than zero.",
+		}
+	}
+	return nil
+}
+
+// resolveTransactionWallets checks that the wallets of a transaction belong to
+// the user and share its currency. An empty currency takes the wallet's.
+func (bt *BudgetTracker) resolveTransactionWallets(ctx context.Context, userId string, transaction *TransactionRequest) error {
+	if transaction.WalletId == "" {
+		if transaction.ToWalletId != "" {
+			return appErrors.ErrorResponse{
+				Code:    appErrors.ErrInvalidInput,
+				Message: "Destination wallet ID is only allowed on transfers.",
+			}
+		}
+		return nil
+	}
+
+	ids := []string{transaction.WalletId}
+	if transaction.ToWalletId != "" {
+		ids = append(ids, transaction.ToWalletId)
+	}
+	for _, id := range ids {
+		w, err := bt.storage.GetWalletById(ctx, userId, id)
+		if err != nil {
+			return err
+		}
+		if transaction.Currency == "" {
+			transaction.Currency = w.Currency
+		}
+		if !strings.EqualFold(transaction.Currency, w.Currency) {
+			return appErrors.ErrorResponse{
+				Code:    appErrors.ErrInvalidInput,
+				Message: fmt.Sprintf("Transaction currency must match the currency of wallet %q (%s)", w.Name, w.Currency),
+			}
+		}
+		transaction.Currency = w.Currency
+	}
+	return nil
+}
+
+// linkedCurrency is the currency of a goal or debt: its wallet's when it is
+// linked to one, the requested one otherwise. subject names it in errors.
+func (bt *BudgetTracker) linkedCurrency(ctx context.Context, userId string, walletId string, currency string, subject string) (string, error) {
+	currency = strings.ToUpper(strings.TrimSpace(currency))
+	if walletId != "" {
+		w, err := bt.storage.GetWalletById(ctx, userId, walletId)
+		if err != nil {
+			return "", err
+		}
+		if currency != "" && currency != w.Currency {
+			return "", appErrors.ErrorResponse{
+				Code:    appErrors.ErrInvalidInput,
+				Message: fmt.Sprintf("The %s currency must be %s, the currency of its wallet", subject, w.Currency),
+			}
+		}
+		return w.Currency, nil
+	}
+	if currency == "" || len(currency) > MAX_TRANSACTION_CURRENCY_LENGTH {
+		return "", appErrors.ErrorResponse{
+			Code:    appErrors.ErrInvalidInput,
+			Message: fmt.Sprintf("The %s currency is required when no wallet is linked", subject),
+		}
+	}
+	return currency, nil
+}
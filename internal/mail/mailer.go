@@ -0,0 +1,85 @@
+package mail
+
+import (
+	"context"
+	"fmt"
+	"net/smtp"
+	"os"
+	"strings"
+
+	"github.com/fatali-fataliyev/budget_tracker/internal/contextutil"
+	"github.com/fatali-fataliyev/budget_tracker/logging"
+)
+
+type Mailer interface {
+	SendMail(ctx context.Context, to string, subject string, body string) error
+}
+
+type SMTPMailer struct {
+	host     string
+	port     string
+	username string
+	password string
+	from     string
+}
+
+// LogMailer writes outgoing mail to the application log, used when SMTP is
+// not configured (local development, tests).
+type LogMailer struct{}
+
+// Init returns an SMTP mailer when SMTP_HOST is set and a LogMailer otherwise.
+func Init() (Mailer, error) {
+	host := os.Getenv("SMTP_HOST")
+	if host == "" {
+		logging.Logger.Warn("SMTP_HOST environment variable not set, outgoing mail will only be logged")
+		return &LogMailer{}, nil
+	}
+
+	port := os.Getenv("SMTP_PORT")
+	if port == "" {
+		port = "587"
+	}
+	from := os.Getenv("SMTP_FROM")
+	if from == "" {
+		return nil, fmt.Errorf("SMTP_FROM is required when SMTP_HOST is set")
+	}
+
+	return &SMTPMailer{
+		host:     host,
+		port:     port,
+		username: os.Getenv("SMTP_USERNAME"),
+		password: os.Getenv("SMTP_PASSWORD"),
+		from:     from,
+	}, nil
+}
+
+func (m *SMTPMailer) SendMail(ctx context.Context, to string, subject string, body string) error {
+	traceID := contextutil.TraceIDFromContext(ctx)
+
+	var smtpAuth smtp.Auth
+	if m.username != "" {
+		smtpAuth = smtp.PlainAuth("", m.username, m.password, m.host)
+	}
+
+	msg := strings.Join([]string{
+		"From: " + m.from,
+		"To: " + to,
+		"Subject: " + subject,
+		"MIME-Version: 1.0",
+		"Content-Type: text/plain; charset=UTF-8",
+		"",
+		body,
+	}, "\r\n")
+
+	if err := smtp.SendMail(m.host+":"+m.port, smtpAuth, m.from, []string{to}, []byte(msg)); err != nil {
+		logging.Logger.Errorf("[TraceID=%s] | failed to send mail in Mailer.SendMail() function | Error: %v", traceID, err)
+		return err
+	}
+	return nil
+}
+
+func (m *LogMailer) SendMail(ctx context.Context, to string, subject string, body string) error {
+	traceID := contextutil.TraceIDFromContext(ctx)
+	logging.Logger.Infof("[TraceID=%s] | mail to %s | Subject: %s | Body: %s", traceID, to, subject, body)
+	return nil
+}
@@ -267,8 +267,8 @@ func (mySql *MySQLStorage) SaveSession(ctx context.Context, session auth.Session
 func (mySql *MySQLStorage) SaveExpenseCategory(ctx context.Context, category budget.ExpenseCategory) error {
 	traceID := contextutil.TraceIDFromContext(ctx)
 
-	query := "INSERT INTO expense_category (id, name, max_amount, period_day, created_at, updated_at, note, created_by) VALUES (?, ?, ?, ?, ?, ?, ?, ?);"
-	_, err := mySql.db.Exec(query, category.ID, category.Name, category.MaxAmount, category.PeriodDay, category.CreatedAt, category.UpdatedAt, category.Note, category.CreatedBy)
+	query := "INSERT INTO expense_category (id, name, max_amount, period_day, created_at, updated_at, note, created_by, parent_id) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?);"
+	_, err := mySql.db.Exec(query, category.ID, category.Name, category.MaxAmount, category.PeriodDay, category.CreatedAt, category.UpdatedAt, category.Note, category.CreatedBy, emptyToNull(category.ParentId))
 	if err != nil {
 		if mysqlErr, ok := err.(*mysql.MySQLError); ok {
 			if mysqlErr.Number == 1062 {
@@ -291,8 +291,8 @@ func (mySql *MySQLStorage) SaveExpenseCategory(ctx context.Context, category bud
 func (mySql *MySQLStorage) SaveIncomeCategory(ctx context.Context, category budget.IncomeCategory) error {
 	traceID := contextutil.TraceIDFromContext(ctx)
 
-	query := "INSERT INTO income_category (id, name, target_amount, created_at, updated_at, note, created_by) VALUES (?, ?, ?, ?, ?, ?, ?);"
-	_, err := mySql.db.Exec(query, category.ID, category.Name, category.TargetAmount, category.CreatedAt, category.UpdatedAt, category.Note, category.CreatedBy)
+	query := "INSERT INTO income_category (id, name, target_amount, created_at, updated_at, note, created_by, parent_id) VALUES (?, ?, ?, ?, ?, ?, ?, ?);"
+	_, err := mySql.db.Exec(query, category.ID, category.Name, category.TargetAmount, category.CreatedAt, category.UpdatedAt, category.Note, category.CreatedBy, emptyToNull(category.ParentId))
 	if err != nil {
 		if mysqlErr, ok := err.(*mysql.MySQLError); ok {
 			if mysqlErr.Number == 1062 {
@@ -408,14 +408,24 @@ func (mySql *MySQLStorage) CheckSession(ctx context.Context, token string) (stri
 	return userID, nil
 }
 
-func (mySql *MySQLStorage) isCategoryExists(traceID string, categoryId string, categoryType string) (bool, string, error) {
+// errCategoryArchived is returned when a transaction is added to an archived
+// category, the recurring scheduler pauses its schedule on it.
+var errCategoryArchived = appErrors.ErrorResponse{
+	Code:    appErrors.ErrInvalidInput,
+	Message: "The category is archived, unarchive it to add transactions.",
+}
+
+// isCategoryExists reports whether the user owns a live category with the
+// given id and type.
+func (mySql *MySQLStorage) isCategoryExists(traceID string, userId string, categoryId string, categoryType string) (bool, string, error) {
 	switch categoryType {
 	case "+":
-		incomeQuery := "SELECT id FROM income_category WHERE id = ?;"
+		incomeQuery := "SELECT id, archived_at IS NOT NULL FROM income_category WHERE id = ? AND created_by = ?;"
 
 		var incomeCategoryId string
-		row := mySql.db.QueryRow(incomeQuery, categoryId)
-		err := row.Scan(&incomeCategoryId)
+		var archived bool
+		row := mySql.db.QueryRow(incomeQuery, categoryId, userId)
+		err := row.Scan(&incomeCategoryId, &archived)
 		if err != nil {
 			if errors.Is(err, sql.ErrNoRows) {
 				return false, "+", nil
@@ -428,15 +438,19 @@ func (mySql *MySQLStorage) isCategoryExists(traceID string, categoryId string, c
 			}
 		}
 
+		if archived {
+			return false, "", errCategoryArchived
+		}
 		if incomeCategoryId != "" && incomeCategoryId == categoryId {
 			return true, "+", nil
 		}
 	case "-":
-		expenseQuery := "SELECT id FROM expense_category WHERE id = ?;"
+		expenseQuery := "SELECT id, archived_at IS NOT NULL FROM expense_category WHERE id = ? AND created_by = ?;"
 
 		var expenseCategoryId string
-		row := mySql.db.QueryRow(expenseQuery, categoryId)
-		err := row.Scan(&expenseCategoryId)
+		var archived bool
+		row := mySql.db.QueryRow(expenseQuery, categoryId, userId)
+		err := row.Scan(&expenseCategoryId, &archived)
 		if err != nil {
 			if errors.Is(err, sql.ErrNoRows) {
 				return false, "-", nil
@@ -449,6 +463,9 @@ func (mySql *MySQLStorage) isCategoryExists(traceID string, categoryId string, c
 			}
 		}
 
+		if archived {
+			return false, "", errCategoryArchived
+		}
 		if expenseCategoryId != "" && expenseCategoryId == categoryId {
 			return true, "-", nil
 		}
@@ -461,24 +478,24 @@ func (mySql *MySQLStorage) isCategoryExists(traceID string, categoryId string, c
 
 func (mySql *MySQLStorage) SaveTransaction(ctx context.Context, t budget.Transaction) error {
 	traceID := contextutil.TraceIDFromContext(ctx)
-	isExist, cType, err := mySql.isCategoryExists(traceID, t.CategoryId, t.CategoryType)
+	if len(t.Splits) > 0 {
+		return mySql.saveSplitTransaction(ctx, t)
+	}
+	if t.CategoryType == budget.TRANSFER_TYPE {
+		// wallets were checked by the service, a transfer has no category
+		query := "INSERT INTO transaction (id, category_id, amount, currency, occurred_at, created_at, note, created_by, category_type, wallet_id, to_wallet_id) VALUES (?, NULL, ?, ?, ?, ?, ?, ?, ?, ?, ?);"
+		return mySql.saveTransactionRow(ctx, t, query, t.ID, t.Amount, t.Currency, t.OccurredAt, t.CreatedAt, t.Note, t.CreatedBy, t.CategoryType, t.WalletId, t.ToWalletId)
+	}
+
+	isExist, cType, err := mySql.isCategoryExists(traceID, t.CreatedBy, t.CategoryId, t.CategoryType)
 	if err != nil {
 		return err
 	}
 
 	if isExist {
 		if cType != "" {
-			query := "INSERT INTO transaction (id, category_id, amount, currency, created_at, note, created_by, category_type) VALUES (?, ?, ?, ?, ?, ?, ?, ?);"
-			_, err := mySql.db.Exec(query, t.ID, t.CategoryId, t.Amount, t.Currency, t.CreatedAt, t.Note, t.CreatedBy, cType)
-			if err != nil {
-				logging.Logger.Errorf("[TraceID=%s] | failed to save transaction in Storage.SaveTransaction() function, | Error: %v", traceID, err)
-				return appErrors.ErrorResponse{
-					Code:    appErrors.ErrInternal,
-					Message: "Failed to save transaction, try again later.",
-				}
-			}
-
-			return nil
+			query := "INSERT INTO transaction (id, category_id, amount, currency, occurred_at, created_at, note, created_by, category_type, wallet_id, payee_id) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?);"
+			return mySql.saveTransactionRow(ctx, t, query, t.ID, t.CategoryId, t.Amount, t.Currency, t.OccurredAt, t.CreatedAt, t.Note, t.CreatedBy, cType, emptyToNull(t.WalletId), emptyToNull(t.PayeeId))
 		} else {
 			return appErrors.ErrorResponse{
 				Code:    appErrors.ErrInvalidInput,
@@ -492,6 +509,32 @@ func (mySql *MySQLStorage) SaveTransaction(ctx context.Context, t budget.Transac
 	}
 }
 
+func emptyToNull(s string) sql.NullString {
+	return sql.NullString{String: s, Valid: s != ""}
+}
+
+// transactionColumns are read by scanTransaction, in this order.
+const transactionColumns = "id, category_id, category_type, amount, currency, occurred_at, created_at, note, created_by, wallet_id, to_wallet_id, cleared, reconciled_at, payee_id"
+
+// scanTransaction reads transactionColumns followed by any extra columns.
+// Transfers have no category, and transactions made before wallets existed
+// have none either.
+func scanTransaction(scan func(dest ...interface{}) error, extra ...interface{}) (budget.Transaction, error) {
+	var t budget.Transaction
+	var categoryId, walletId, toWalletId, payeeId sql.NullString
+	var reconciledAt sql.NullTime
+	dest := []interface{}{&t.ID, &categoryId, &t.CategoryType, &t.Amount, &t.Currency, &t.OccurredAt, &t.CreatedAt, &t.Note, &t.CreatedBy, &walletId, &toWalletId, &t.Cleared, &reconciledAt, &payeeId}
+	if err := scan(append(dest, extra...)...); err != nil {
+		return budget.Transaction{}, err
+	}
+	t.CategoryId = categoryId.String
+	t.WalletId = walletId.String
+	t.ToWalletId = toWalletId.String
+	t.ReconciledAt = reconciledAt.Time
+	t.PayeeId = payeeId.String
+	return t, nil
+}
+
 func NilToNullFloat64(v *float64) sql.NullFloat64 {
 	if v == nil {
 		return sql.NullFloat64{Valid: false}
@@ -515,11 +558,26 @@ func (mySql *MySQLStorage) GetTotalAmountOfTransactions(ctx context.Context, use
 	`
 	args := []interface{}{userID}
 	if categoryId != "" {
-		query += " AND category_id = ?"
-		args = append(args, categoryId)
-	}
-
-	if categoryType != "" {
+		// a split transaction counts towards the categories of its splits,
+		// not the category stored on the transaction itself
+		typeFilter := ""
+		lineArgs := []interface{}{categoryId}
+		if categoryType != "" {
+			typeFilter = " AND t.category_type = ?"
+			lineArgs = append(lineArgs, categoryType)
+		}
+		query = `
+		SELECT IFNULL(SUM(amount), 0) FROM (
+			SELECT t.amount FROM transaction t
+			WHERE t.created_by = ? AND t.category_id = ?` + typeFilter + `
+			AND NOT EXISTS (SELECT 1 FROM transaction_split s WHERE s.transaction_id = t.id)
+			UNION ALL
+			SELECT s.amount FROM transaction_split s JOIN transaction t ON t.id = s.transaction_id
+			WHERE s.created_by = ? AND s.category_id = ?` + typeFilter + `
+		) AS category_amount
+		`
+		args = append(append(append(args, lineArgs...), userID), lineArgs...)
+	} else if categoryType != "" {
 		query += " AND category_type = ?"
 		args = append(args, categoryType)
 	}
@@ -545,8 +603,9 @@ func (mySql *MySQLStorage) processIncomeRows(ctx context.Context, rows *sql.Rows
 
 	for rows.Next() {
 		var category budget.IncomeCategoryResponse
+		var archivedAt sql.NullTime
 
-		err := rows.Scan(&category.ID, &category.Name, &category.TargetAmount, &category.CreatedAt, &category.UpdatedAt, &category.Note, &category.CreatedBy)
+		err := rows.Scan(&category.ID, &category.Name, &category.TargetAmount, &category.CreatedAt, &category.UpdatedAt, &category.Note, &category.CreatedBy, &category.ParentId, &archivedAt)
 		if err != nil {
 			logging.Logger.Errorf("[TraceID=%s] | failed to scan row in Storage.processIncomeRows() function | Error : %v", traceID, err)
 			return nil, appErrors.ErrorResponse{
@@ -560,6 +619,7 @@ func (mySql *MySQLStorage) processIncomeRows(ctx context.Context, rows *sql.Rows
 			return nil, err
 		}
 		category.Amount = categoryAmount
+		category.ArchivedAt = archivedAt.Time
 
 		categories = append(categories, category)
 	}
@@ -576,7 +636,7 @@ func (mySql *MySQLStorage) processIncomeRows(ctx context.Context, rows *sql.Rows
 }
 
 func (mySql *MySQLStorage) GetFilteredIncomeCategories(ctx context.Context, userID string, filters *budget.IncomeCategoryList) ([]budget.IncomeCategoryResponse, error) {
-	query := "SELECT id, name, target_amount, created_at, updated_at, note, created_by FROM income_category WHERE created_by = ?"
+	query := "SELECT id, name, target_amount, created_at, updated_at, note, created_by, IFNULL(parent_id, ''), archived_at FROM income_category WHERE created_by = ?"
 	args := []interface{}{userID}
 	traceID := contextutil.TraceIDFromContext(ctx)
 
@@ -651,8 +711,9 @@ func (mySql *MySQLStorage) processExpenseRows(ctx context.Context, rows *sql.Row
 
 	for rows.Next() {
 		var category budget.ExpenseCategoryResponse
+		var archivedAt sql.NullTime
 
-		err := rows.Scan(&category.ID, &category.Name, &category.MaxAmount, &category.PeriodDay, &category.CreatedAt, &category.UpdatedAt, &category.Note, &category.CreatedBy)
+		err := rows.Scan(&category.ID, &category.Name, &category.MaxAmount, &category.PeriodDay, &category.CreatedAt, &category.UpdatedAt, &category.Note, &category.CreatedBy, &category.ParentId, &archivedAt)
 		if err != nil {
 			logging.Logger.Errorf("[TraceID=%s] | failed to scan row in Storage.processExpenseRows() function | Error : %v", traceID, err)
 			return nil, appErrors.ErrorResponse{
@@ -666,6 +727,7 @@ func (mySql *MySQLStorage) processExpenseRows(ctx context.Context, rows *sql.Row
 			return nil, err
 		}
 		category.Amount = categoryAmount
+		category.ArchivedAt = archivedAt.Time
 
 		categories = append(categories, category)
 	}
@@ -823,7 +885,7 @@ func (mySql *MySQLStorage) GetTransactionStats(ctx context.Context, userId strin
 		IFNULL(SUM(CASE WHEN category_type = '+' THEN amount ELSE 0 END), 0) AS incomes,
 		IFNULL(SUM(amount), 0) AS total
 	FROM transaction
-	WHERE created_by = ?;
+	WHERE created_by = ? AND category_type IN ('+', '-');
 	`
 
 	var stat dbTransactionStats
@@ -836,16 +898,61 @@ func (mySql *MySQLStorage) GetTransactionStats(ctx context.Context, userId strin
 		}
 	}
 
+	// transfers are neither income nor expense, tags without transactions
+	// are listed with zero totals
+	tagQuery := `
+	SELECT g.name,
+		IFNULL(SUM(CASE WHEN t.category_type = '-' THEN t.amount ELSE 0 END), 0) AS expenses,
+		IFNULL(SUM(CASE WHEN t.category_type = '+' THEN t.amount ELSE 0 END), 0) AS incomes,
+		COUNT(t.id)
+	FROM tag g
+	LEFT JOIN transaction_tag tt ON tt.tag_id = g.id
+	LEFT JOIN transaction t ON t.id = tt.transaction_id AND t.category_type IN ('+', '-')
+	WHERE g.created_by = ?
+	GROUP BY g.id, g.name
+	ORDER BY g.name;
+	`
+	rows, err := mySql.db.QueryContext(ctx, tagQuery, userId)
+	if err != nil {
+		logging.Logger.Errorf("[TraceID=%s] | failed to get tag totals in Storage.GetTransactionStats() function | Error: %v", traceID, err)
+		return budget.TransactionStatsResponse{}, appErrors.ErrorResponse{
+			Code:    appErrors.ErrInternal,
+			Message: "Failed to get transaction statistics, try again later",
+		}
+	}
+	defer rows.Close()
+
+	tags := []budget.TagTotal{}
+	for rows.Next() {
+		var total budget.TagTotal
+		if err := rows.Scan(&total.Name, &total.Expenses, &total.Incomes, &total.Count); err != nil {
+			logging.Logger.Errorf("[TraceID=%s] | failed to scan tag totals in Storage.GetTransactionStats() function | Error: %v", traceID, err)
+			return budget.TransactionStatsResponse{}, appErrors.ErrorResponse{
+				Code:    appErrors.ErrInternal,
+				Message: "Failed to get transaction statistics, try again later",
+			}
+		}
+		tags = append(tags, total)
+	}
+	if err := rows.Err(); err != nil {
+		logging.Logger.Errorf("[TraceID=%s] | failed to iterate tag totals in Storage.GetTransactionStats() function | Error: %v", traceID, err)
+		return budget.TransactionStatsResponse{}, appErrors.ErrorResponse{
+			Code:    appErrors.ErrInternal,
+			Message: "Failed to get transaction statistics, try again later",
+		}
+	}
+
 	return budget.TransactionStatsResponse{
 		Expenses: stat.Expenses,
 		Incomes:  stat.Incomes,
 		Total:    stat.Total,
+		Tags:     tags,
 	}, nil
 }
 
 func (mySql *MySQLStorage) GetFilteredExpenseCategories(ctx context.Context, userID string, filters *budget.ExpenseCategoryList) ([]budget.ExpenseCategoryResponse, error) {
 	traceID := contextutil.TraceIDFromContext(ctx)
-	query := "SELECT id, name, max_amount, period_day, created_at, updated_at, note, created_by FROM expense_category WHERE created_by = ?"
+	query := "SELECT id, name, max_amount, period_day, created_at, updated_at, note, created_by, IFNULL(parent_id, ''), archived_at FROM expense_category WHERE created_by = ?"
 	args := []interface{}{userID}
 
 	if filters.IsAllNil {
@@ -925,12 +1032,13 @@ func (mySql *MySQLStorage) UpdateExpenseCategory(ctx context.Context, userID str
 		}
 	}
 
-	query = "SELECT id, name, max_amount, period_day, created_at, updated_at, note, created_by FROM expense_category WHERE created_by = ? AND id = ?;"
+	query = "SELECT id, name, max_amount, period_day, created_at, updated_at, note, created_by, IFNULL(parent_id, ''), archived_at FROM expense_category WHERE created_by = ? AND id = ?;"
 	row := mySql.db.QueryRow(query, userID, filters.ID)
 
 	var category budget.ExpenseCategoryResponse
+	var archivedAt sql.NullTime
 
-	err = row.Scan(&category.ID, &category.Name, &category.MaxAmount, &category.PeriodDay, &category.CreatedAt, &category.UpdatedAt, &category.Note, &category.CreatedBy)
+	err = row.Scan(&category.ID, &category.Name, &category.MaxAmount, &category.PeriodDay, &category.CreatedAt, &category.UpdatedAt, &category.Note, &category.CreatedBy, &category.ParentId, &archivedAt)
 	if err != nil {
 		logging.Logger.Errorf("[TraceID=%s] | failed to scan row in Storage.UpdateExpenseCategory() function | Error : %v", traceID, err)
 		return nil, appErrors.ErrorResponse{
@@ -945,6 +1053,7 @@ func (mySql *MySQLStorage) UpdateExpenseCategory(ctx context.Context, userID str
 	}
 
 	category.Amount = categoryAmount
+	category.ArchivedAt = archivedAt.Time
 	return &category, nil
 }
 
@@ -961,12 +1070,13 @@ func (mySql *MySQLStorage) UpdateIncomeCategory(ctx context.Context, userID stri
 		}
 	}
 
-	query = "SELECT id, name, target_amount, created_at, updated_at, note, created_by FROM income_category WHERE created_by = ? AND id = ?;"
+	query = "SELECT id, name, target_amount, created_at, updated_at, note, created_by, IFNULL(parent_id, ''), archived_at FROM income_category WHERE created_by = ? AND id = ?;"
 	row := mySql.db.QueryRow(query, userID, filters.ID)
 
 	var category budget.IncomeCategoryResponse
+	var archivedAt sql.NullTime
 
-	err = row.Scan(&category.ID, &category.Name, &category.TargetAmount, &category.CreatedAt, &category.UpdatedAt, &category.Note, &category.CreatedBy)
+	err = row.Scan(&category.ID, &category.Name, &category.TargetAmount, &category.CreatedAt, &category.UpdatedAt, &category.Note, &category.CreatedBy, &category.ParentId, &archivedAt)
 	if err != nil {
 		logging.Logger.Errorf("[TraceID=%s] | failed to scan row in Storage.UpdateIncomeCategory() function | Error : %v", traceID, err)
 		return nil, appErrors.ErrorResponse{
@@ -981,16 +1091,53 @@ func (mySql *MySQLStorage) UpdateIncomeCategory(ctx context.Context, userID stri
 	}
 
 	category.Amount = categoryAmount
+	category.ArchivedAt = archivedAt.Time
 	return &category, nil
 }
 
-func (mySql *MySQLStorage) DeleteExpenseCategory(ctx context.Context, userId string, categoryId string) error {
+func (mySql *MySQLStorage) DeleteExpenseCategory(ctx context.Context, userId string, categoryId string, moveTo string) (int, error) {
 	tx, err := mySql.db.Begin()
 	traceID := contextutil.TraceIDFromContext(ctx)
 
 	if err != nil {
 		logging.Logger.Errorf("[TraceID=%s] |  failed to start SQL transaction in Storage.DeleteExpenseCategory() function | Error : %v", traceID, err)
-		return appErrors.ErrorResponse{
+		return 0, appErrors.ErrorResponse{
+			Code:    appErrors.ErrInternal,
+			Message: "Failed to delete the category.",
+		}
+	}
+
+	affected, err := countCategoryTransactions(ctx, tx, userId, categoryId, "-")
+	if err != nil {
+		tx.Rollback()
+		logging.Logger.Errorf("[TraceID=%s] |  failed to count related transactions in Storage.DeleteExpenseCategory() function | Error : %v", traceID, err)
+		return 0, appErrors.ErrorResponse{
+			Code:    appErrors.ErrInternal,
+			Message: "Failed to delete the category.",
+		}
+	}
+
+	// once moved nothing refers to the category, the deletes below are no-ops
+	if moveTo != "" {
+		if err := moveCategoryTransactions(ctx, tx, userId, categoryId, moveTo, "-"); err != nil {
+			tx.Rollback()
+			logging.Logger.Errorf("[TraceID=%s] |  failed to move related transactions in Storage.DeleteExpenseCategory() function | Error : %v", traceID, err)
+			return 0, appErrors.ErrorResponse{
+				Code:    appErrors.ErrInternal,
+				Message: "Failed to delete the category.",
+			}
+		}
+	}
+
+	if err := checkCategoryNotReconciled(ctx, tx, userId, categoryId, "-"); err != nil {
+		tx.Rollback()
+		return 0, err
+	}
+
+	if err := deleteCategorySplits(ctx, tx, userId, categoryId, "-"); err != nil {
+		tx.Rollback()
+		logging.Logger.Errorf("[TraceID=%s] |  failed to delete related splits in Storage.DeleteExpenseCategory() function | Error : %v", traceID, err)
+		return 0, appErrors.ErrorResponse{
 			Code:    appErrors.ErrInternal,
 			Message: "Failed to delete the category.",
 		}
@@ -1001,7 +1148,41 @@ func (mySql *MySQLStorage) DeleteExpenseCategory(ctx context.Context, userId str
 	if err != nil {
 		tx.Rollback()
 		logging.Logger.Errorf("[TraceID=%s] |  failed to delete all related transactions in Storage.DeleteExpenseCategory() function | Error : %v", traceID, err)
-		return appErrors.ErrorResponse{
+		return 0, appErrors.ErrorResponse{
+			Code:    appErrors.ErrInternal,
+			Message: "Failed to delete the category.",
+		}
+	}
+
+	deleteRecurringQuery := "DELETE FROM recurring_transaction WHERE created_by = ? AND category_id = ? AND category_type = '-';"
+	_, err = tx.Exec(deleteRecurringQuery, userId, categoryId)
+	if err != nil {
+		tx.Rollback()
+		logging.Logger.Errorf("[TraceID=%s] |  failed to delete related recurring transactions in Storage.DeleteExpenseCategory() function | Error : %v", traceID, err)
+		return 0, appErrors.ErrorResponse{
+			Code:    appErrors.ErrInternal,
+			Message: "Failed to delete the category.",
+		}
+	}
+
+	// the rules keep their other actions
+	clearRuleQuery := "UPDATE transaction_rule SET set_category_id = NULL WHERE created_by = ? AND set_category_id = ?;"
+	_, err = tx.Exec(clearRuleQuery, userId, categoryId)
+	if err != nil {
+		tx.Rollback()
+		logging.Logger.Errorf("[TraceID=%s] |  failed to clear the category of related rules in Storage.DeleteExpenseCategory() function | Error : %v", traceID, err)
+		return 0, appErrors.ErrorResponse{
+			Code:    appErrors.ErrInternal,
+			Message: "Failed to delete the category.",
+		}
+	}
+
+	clearPayeeQuery := "UPDATE payee SET default_category_id = NULL, default_category_type = NULL WHERE created_by = ? AND default_category_id = ?;"
+	_, err = tx.Exec(clearPayeeQuery, userId, categoryId)
+	if err != nil {
+		tx.Rollback()
+		logging.Logger.Errorf("[TraceID=%s] |  failed to clear the default category of related payees in Storage.DeleteExpenseCategory() function | Error : %v", traceID, err)
+		return 0, appErrors.ErrorResponse{
 			Code:    appErrors.ErrInternal,
 			Message: "Failed to delete the category.",
 		}
@@ -1012,7 +1193,7 @@ func (mySql *MySQLStorage) DeleteExpenseCategory(ctx context.Context, userId str
 	if err != nil {
 		tx.Rollback()
 		logging.Logger.Errorf("[TraceID=%s] |  failed to delete expense category in Storage.DeleteExpenseCategory() function | Error : %v", traceID, err)
-		return appErrors.ErrorResponse{
+		return 0, appErrors.ErrorResponse{
 			Code:    appErrors.ErrInternal,
 			Message: "Failed to delete the category.",
 		}
@@ -1022,7 +1203,7 @@ func (mySql *MySQLStorage) DeleteExpenseCategory(ctx context.Context, userId str
 	if err != nil {
 		tx.Rollback()
 		logging.Logger.Errorf("[TraceID=%s] | failed to check expense category delete status in Storage.DeleteExpenseCategory() function | Error : %v", traceID, err)
-		return appErrors.ErrorResponse{
+		return 0, appErrors.ErrorResponse{
 			Code:    appErrors.ErrInternal,
 			Message: "Failed to delete the category.",
 		}
@@ -1030,7 +1211,7 @@ func (mySql *MySQLStorage) DeleteExpenseCategory(ctx context.Context, userId str
 	}
 	if rowsAffected == 0 {
 		tx.Rollback()
-		return appErrors.ErrorResponse{
+		return 0, appErrors.ErrorResponse{
 			Code:    appErrors.ErrInternal,
 			Message: "The category does not exist.",
 		}
@@ -1038,13 +1219,13 @@ func (mySql *MySQLStorage) DeleteExpenseCategory(ctx context.Context, userId str
 
 	if err := tx.Commit(); err != nil {
 		logging.Logger.Errorf("[TraceID=%s] | failed to commit  SQL transaction in Storage.DeleteExpenseCategory() function | Error : %v", traceID, err)
-		return appErrors.ErrorResponse{
+		return 0, appErrors.ErrorResponse{
 			Code:    appErrors.ErrInternal,
 			Message: "Failed to delete the category.",
 		}
 	}
 
-	return nil
+	return affected, nil
 }
 
 func (mySql *MySQLStorage) getCategoryNameById(traceID string, userID string, categoryId string, categoryType string) (*string, error) {
@@ -1116,13 +1297,49 @@ func (mySql *MySQLStorage) getCategoryIdByName(traceID string, userID string, ca
 	return &id, nil
 }
 
-func (mySql *MySQLStorage) DeleteIncomeCategory(ctx context.Context, userId string, categoryId string) error {
+func (mySql *MySQLStorage) DeleteIncomeCategory(ctx context.Context, userId string, categoryId string, moveTo string) (int, error) {
 	tx, err := mySql.db.Begin()
 	traceID := contextutil.TraceIDFromContext(ctx)
 
 	if err != nil {
 		logging.Logger.Errorf("[TraceID=%s] |  failed to start SQL transaction in Storage.DeleteIncomeCategory() function | Error : %v", traceID, err)
-		return appErrors.ErrorResponse{
+		return 0, appErrors.ErrorResponse{
+			Code:    appErrors.ErrInternal,
+			Message: "Failed to delete the category.",
+		}
+	}
+
+	affected, err := countCategoryTransactions(ctx, tx, userId, categoryId, "+")
+	if err != nil {
+		tx.Rollback()
+		logging.Logger.Errorf("[TraceID=%s] |  failed to count related transactions in Storage.DeleteIncomeCategory() function | Error : %v", traceID, err)
+		return 0, appErrors.ErrorResponse{
+			Code:    appErrors.ErrInternal,
+			Message: "Failed to delete the category.",
+		}
+	}
+
+	// once moved nothing refers to the category, the deletes below are no-ops
+	if moveTo != "" {
+		if err := moveCategoryTransactions(ctx, tx, userId, categoryId, moveTo, "+"); err != nil {
+			tx.Rollback()
+			logging.Logger.Errorf("[TraceID=%s] |  failed to move related transactions in Storage.DeleteIncomeCategory() function | Error : %v", traceID, err)
+			return 0, appErrors.ErrorResponse{
+				Code:    appErrors.ErrInternal,
+				Message: "Failed to delete the category.",
+			}
+		}
+	}
+
+	if err := checkCategoryNotReconciled(ctx, tx, userId, categoryId, "+"); err != nil {
+		tx.Rollback()
+		return 0, err
+	}
+
+	if err := deleteCategorySplits(ctx, tx, userId, categoryId, "+"); err != nil {
+		tx.Rollback()
+		logging.Logger.Errorf("[TraceID=%s] |  failed to delete related splits in Storage.DeleteIncomeCategory() function | Error : %v", traceID, err)
+		return 0, appErrors.ErrorResponse{
 			Code:    appErrors.ErrInternal,
 			Message: "Failed to delete the category.",
 		}
@@ -1133,7 +1350,41 @@ func (mySql *MySQLStorage) DeleteIncomeCategory(ctx context.Context, userId stri
 	if err != nil {
 		tx.Rollback()
 		logging.Logger.Errorf("[TraceID=%s] |  failed to delete all related transactions in Storage.DeleteIncomeCategory() function | Error : %v", traceID, err)
-		return appErrors.ErrorResponse{
+		return 0, appErrors.ErrorResponse{
+			Code:    appErrors.ErrInternal,
+			Message: "Failed to delete the category.",
+		}
+	}
+
+	deleteRecurringQuery := "DELETE FROM recurring_transaction WHERE created_by = ? AND category_id = ? AND category_type = '+';"
+	_, err = tx.Exec(deleteRecurringQuery, userId, categoryId)
+	if err != nil {
+		tx.Rollback()
+		logging.Logger.Errorf("[TraceID=%s] |  failed to delete related recurring transactions in Storage.DeleteIncomeCategory() function | Error : %v", traceID, err)
+		return 0, appErrors.ErrorResponse{
+			Code:    appErrors.ErrInternal,
+			Message: "Failed to delete the category.",
+		}
+	}
+
+	// the rules keep their other actions
+	clearRuleQuery := "UPDATE transaction_rule SET set_category_id = NULL WHERE created_by = ? AND set_category_id = ?;"
+	_, err = tx.Exec(clearRuleQuery, userId, categoryId)
+	if err != nil {
+		tx.Rollback()
+		logging.Logger.Errorf("[TraceID=%s] |  failed to clear the category of related rules in Storage.DeleteIncomeCategory() function | Error : %v", traceID, err)
+		return 0, appErrors.ErrorResponse{
+			Code:    appErrors.ErrInternal,
+			Message: "Failed to delete the category.",
+		}
+	}
+
+	clearPayeeQuery := "UPDATE payee SET default_category_id = NULL, default_category_type = NULL WHERE created_by = ? AND default_category_id = ?;"
+	_, err = tx.Exec(clearPayeeQuery, userId, categoryId)
+	if err != nil {
+		tx.Rollback()
+		logging.Logger.Errorf("[TraceID=%s] |  failed to clear the default category of related payees in Storage.DeleteIncomeCategory() function | Error : %v", traceID, err)
+		return 0, appErrors.ErrorResponse{
 			Code:    appErrors.ErrInternal,
 			Message: "Failed to delete the category.",
 		}
@@ -1144,7 +1395,7 @@ func (mySql *MySQLStorage) DeleteIncomeCategory(ctx context.Context, userId stri
 	if err != nil {
 		tx.Rollback()
 		logging.Logger.Errorf("[TraceID=%s] | failed to delete income category in Storage.DeleteIncomeCategory() function | Error : %v", traceID, err)
-		return appErrors.ErrorResponse{
+		return 0, appErrors.ErrorResponse{
 			Code:    appErrors.ErrInternal,
 			Message: "Failed to delete the category.",
 		}
@@ -1154,7 +1405,7 @@ func (mySql *MySQLStorage) DeleteIncomeCategory(ctx context.Context, userId stri
 	if err != nil {
 		tx.Rollback()
 		logging.Logger.Errorf("[TraceID=%s] | failed to check income category delete status in Storage.DeleteIncomeCategory() function | Error : %v", traceID, err)
-		return appErrors.ErrorResponse{
+		return 0, appErrors.ErrorResponse{
 			Code:    appErrors.ErrInternal,
 			Message: "Failed to delete the category.",
 		}
@@ -1162,7 +1413,7 @@ func (mySql *MySQLStorage) DeleteIncomeCategory(ctx context.Context, userId stri
 
 	if rowsAffected == 0 {
 		tx.Rollback()
-		return appErrors.ErrorResponse{
+		return 0, appErrors.ErrorResponse{
 			Code:    appErrors.ErrInvalidInput,
 			Message: "The category does not exist.",
 		}
@@ -1170,13 +1421,13 @@ func (mySql *MySQLStorage) DeleteIncomeCategory(ctx context.Context, userId stri
 
 	if err := tx.Commit(); err != nil {
 		logging.Logger.Errorf("[TraceID=%s] |  failed to commit SQL transaction  in Storage.DeleteIncomeCategory() function | Error : %v", traceID, err)
-		return appErrors.ErrorResponse{
+		return 0, appErrors.ErrorResponse{
 			Code:    appErrors.ErrInternal,
 			Message: "Failed to delete the category.",
 		}
 	}
 
-	return nil
+	return affected, nil
 }
 
 func (mySql *MySQLStorage) processTransactionRows(ctx context.Context, rows *sql.Rows, userId string) ([]budget.Transaction, error) {
@@ -1186,9 +1437,7 @@ func (mySql *MySQLStorage) processTransactionRows(ctx context.Context, rows *sql
 
 	defer rows.Close()
 	for rows.Next() {
-		var transaction budget.Transaction
-
-		err := rows.Scan(&transaction.ID, &transaction.CategoryId, &transaction.CategoryType, &transaction.Amount, &transaction.Currency, &transaction.CreatedAt, &transaction.Note, &transaction.CreatedBy)
+		transaction, err := scanTransaction(rows.Scan)
 		if err != nil {
 			logging.Logger.Errorf("[TraceID=%s] | failed to scan row in Storage.processTransactionRows() | Error : %v", traceID, err)
 			return nil, appErrors.ErrorResponse{
@@ -1197,11 +1446,13 @@ func (mySql *MySQLStorage) processTransactionRows(ctx context.Context, rows *sql
 			}
 		}
 
-		categoryName, err := mySql.getCategoryNameById(traceID, userId, transaction.CategoryId, transaction.CategoryType)
-		if err != nil {
-			logging.Logger.Errorf("[TraceID=%s] | failed to get Category name by Category ID Storage.processTransactionRows() | Error : %v", traceID, err)
+		if transaction.CategoryType != budget.TRANSFER_TYPE {
+			categoryName, err := mySql.getCategoryNameById(traceID, userId, transaction.CategoryId, transaction.CategoryType)
+			if err != nil {
+				logging.Logger.Errorf("[TraceID=%s] | failed to get Category name by Category ID Storage.processTransactionRows() | Error : %v", traceID, err)
+			}
+			transaction.CategoryName = *categoryName
 		}
-		transaction.CategoryName = *categoryName
 		transactions = append(transactions, transaction)
 	}
 
@@ -1213,84 +1464,465 @@ func (mySql *MySQLStorage) processTransactionRows(ctx context.Context, rows *sql
 		}
 	}
 
+	if err := mySql.attachTransactionSplits(ctx, userId, transactions); err != nil {
+		return nil, err
+	}
+	if err := mySql.attachTransactionTags(ctx, userId, transactions); err != nil {
+		return nil, err
+	}
+
 	return transactions, nil
 }
 
-func (mySql *MySQLStorage) GetFilteredTransactions(ctx context.Context, userID string, filters *budget.TransactionList) ([]budget.Transaction, error) {
+// saveTransactionRow runs the insert of a transaction and adds its tags in
+// one SQL transaction.
+func (mySql *MySQLStorage) saveTransactionRow(ctx context.Context, t budget.Transaction, query string, args ...interface{}) error {
 	traceID := contextutil.TraceIDFromContext(ctx)
-	query := "SELECT id, category_id, category_type, amount, currency, created_at, note, created_by FROM transaction WHERE created_by = ?"
-	args := []interface{}{userID}
 
-	if filters.IsAllNil {
-		query += " ORDER BY created_at DESC;"
-		rows, err := mySql.db.Query(query, args...)
-		if err != nil {
-			logging.Logger.Errorf("[TraceID=%s] | failed to get all transactions from Storage.GetFilteredTransactions() function | Error : %v", traceID, err)
-			return nil, appErrors.ErrorResponse{
-				Code:    appErrors.ErrInternal,
-				Message: "Failed to get transactions, try again later.",
-			}
+	tx, err := mySql.db.BeginTx(ctx, nil)
+	if err != nil {
+		logging.Logger.Errorf("[TraceID=%s] | failed to start SQL transaction in Storage.SaveTransaction() function | Error: %v", traceID, err)
+		return appErrors.ErrorResponse{
+			Code:    appErrors.ErrInternal,
+			Message: "Failed to save transaction, try again later.",
 		}
+	}
+	defer tx.Rollback()
 
-		transactions, err := mySql.processTransactionRows(ctx, rows, userID)
-		if err != nil {
-			return nil, err
+	if _, err := tx.ExecContext(ctx, query, args...); err != nil {
+		logging.Logger.Errorf("[TraceID=%s] | failed to save transaction in Storage.SaveTransaction() function, | Error: %v", traceID, err)
+		return appErrors.ErrorResponse{
+			Code:    appErrors.ErrInternal,
+			Message: "Failed to save transaction, try again later.",
 		}
-
-		return transactions, nil
 	}
-
-	if len(filters.CategoryNames) > 0 {
-		categoryIds := make([]string, 0, len(filters.CategoryNames))
-
-		for _, name := range filters.CategoryNames {
-			id, err := mySql.getCategoryIdByName(traceID, userID, name, filters.Type)
-			if err != nil {
-				logging.Logger.Errorf("[TraceID=%s] | failed to get category ID by Name  Storage.GetFilteredTransactions() function | Error : %v", traceID, err)
-				continue
-			}
-			categoryIds = append(categoryIds, *id)
+	if err := insertTransactionTags(ctx, tx, t.CreatedBy, t); err != nil {
+		logging.Logger.Errorf("[TraceID=%s] | failed to save tags in Storage.SaveTransaction() function | Error: %v", traceID, err)
+		return appErrors.ErrorResponse{
+			Code:    appErrors.ErrInternal,
+			Message: "Failed to save transaction, try again later.",
 		}
+	}
 
-		query += " AND category_id IN (?" + strings.Repeat(",?", len(categoryIds)-1) + ")"
-		for _, id := range categoryIds {
-			args = append(args, id)
+	if err := tx.Commit(); err != nil {
+		logging.Logger.Errorf("[TraceID=%s] | failed to commit SQL transaction in Storage.SaveTransaction() function | Error: %v", traceID, err)
+		return appErrors.ErrorResponse{
+			Code:    appErrors.ErrInternal,
+			Message: "Failed to save transaction, try again later.",
 		}
 	}
+	return nil
+}
 
-	if filters.Amount > 0 {
-		query += " AND amount >= ?"
-		args = append(args, filters.Amount)
-	}
+// saveSplitTransaction stores a transaction together with its splits. Every
+// split's category must exist with the type of the transaction.
+func (mySql *MySQLStorage) saveSplitTransaction(ctx context.Context, t budget.Transaction) error {
+	traceID := contextutil.TraceIDFromContext(ctx)
 
-	if !filters.CreatedAt.IsZero() {
-		query += " AND created_at >= ?"
-		args = append(args, filters.CreatedAt)
+	for _, split := range t.Splits {
+		isExist, _, err := mySql.isCategoryExists(traceID, t.CreatedBy, split.CategoryId, t.CategoryType)
+		if err != nil {
+			return err
+		}
+		if !isExist {
+			return appErrors.ErrorResponse{
+				Code:    appErrors.ErrInvalidInput,
+				Message: "The category of a split does not exist, please create the category",
+			}
+		}
 	}
 
-	if filters.Currency != "" {
-		query += " AND currency = ?"
-		args = append(args, filters.Currency)
+	tx, err := mySql.db.BeginTx(ctx, nil)
+	if err != nil {
+		logging.Logger.Errorf("[TraceID=%s] | failed to start SQL transaction in Storage.saveSplitTransaction() function | Error: %v", traceID, err)
+		return appErrors.ErrorResponse{
+			Code:    appErrors.ErrInternal,
+			Message: "Failed to save transaction, try again later.",
+		}
 	}
+	defer tx.Rollback()
 
-	if filters.Type != "" {
-		query += " AND category_type = ?"
-		args = append(args, filters.Type)
+	query := "INSERT INTO transaction (id, category_id, amount, currency, occurred_at, created_at, note, created_by, category_type, wallet_id, payee_id) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?);"
+	if _, err := tx.ExecContext(ctx, query, t.ID, t.CategoryId, t.Amount, t.Currency, t.OccurredAt, t.CreatedAt, t.Note, t.CreatedBy, t.CategoryType, emptyToNull(t.WalletId), emptyToNull(t.PayeeId)); err != nil {
+		logging.Logger.Errorf("[TraceID=%s] | failed to save transaction in Storage.saveSplitTransaction() function | Error: %v", traceID, err)
+		return appErrors.ErrorResponse{
+			Code:    appErrors.ErrInternal,
+			Message: "Failed to save transaction, try again later.",
+		}
 	}
-
-	query += " ORDER BY created_at DESC;"
-	rows, err := mySql.db.Query(query, args...)
-	if err != nil {
-		logging.Logger.Errorf("[TraceID=%s] | failed to get filtered transactions from Storage.GetFilteredTransactions() function | Error : %v", traceID, err)
-		return nil, appErrors.ErrorResponse{
+	if err := insertTransactionSplits(ctx, tx, t.CreatedBy, t); err != nil {
+		logging.Logger.Errorf("[TraceID=%s] | failed to save splits in Storage.saveSplitTransaction() function | Error: %v", traceID, err)
+		return appErrors.ErrorResponse{
 			Code:    appErrors.ErrInternal,
-			Message: "Failed to get transactions, try again later.",
+			Message: "Failed to save transaction, try again later.",
 		}
 	}
-
-	transactions, err := mySql.processTransactionRows(ctx, rows, userID)
-	if err != nil {
-		return nil, err
+	if err := insertTransactionTags(ctx, tx, t.CreatedBy, t); err != nil {
+		logging.Logger.Errorf("[TraceID=%s] | failed to save tags in Storage.saveSplitTransaction() function | Error: %v", traceID, err)
+		return appErrors.ErrorResponse{
+			Code:    appErrors.ErrInternal,
+			Message: "Failed to save transaction, try again later.",
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		logging.Logger.Errorf("[TraceID=%s] | failed to commit SQL transaction in Storage.saveSplitTransaction() function | Error: %v", traceID, err)
+		return appErrors.ErrorResponse{
+			Code:    appErrors.ErrInternal,
+			Message: "Failed to save transaction, try again later.",
+		}
+	}
+	return nil
+}
+
+func insertTransactionSplits(ctx context.Context, tx *sql.Tx, userId string, t budget.Transaction) error {
+	query := "INSERT INTO transaction_split (id, transaction_id, position, category_id, amount, note, created_by) VALUES (?, ?, ?, ?, ?, ?, ?);"
+	for i, split := range t.Splits {
+		if _, err := tx.ExecContext(ctx, query, split.ID, t.ID, i, split.CategoryId, split.Amount, split.Note, userId); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// insertTransactionTags links a transaction to its tags by name.
+func insertTransactionTags(ctx context.Context, tx *sql.Tx, userId string, t budget.Transaction) error {
+	if len(t.Tags) == 0 {
+		return nil
+	}
+	query := "INSERT INTO transaction_tag (transaction_id, tag_id, created_by) SELECT ?, id, created_by FROM tag WHERE created_by = ? AND name IN (?" + strings.Repeat(", ?", len(t.Tags)-1) + ");"
+	args := []interface{}{t.ID, userId}
+	for _, tag := range t.Tags {
+		args = append(args, tag)
+	}
+	_, err := tx.ExecContext(ctx, query, args...)
+	return err
+}
+
+// attachTransactionTags loads the tag names of the given transactions in place.
+func (mySql *MySQLStorage) attachTransactionTags(ctx context.Context, userId string, transactions []budget.Transaction) error {
+	traceID := contextutil.TraceIDFromContext(ctx)
+
+	byId := make(map[string]int, len(transactions))
+	for i, t := range transactions {
+		byId[t.ID] = i
+	}
+
+	const chunkSize = 1000
+	for start := 0; start < len(transactions); start += chunkSize {
+		end := min(start+chunkSize, len(transactions))
+		chunk := transactions[start:end]
+
+		query := "SELECT tt.transaction_id, g.name FROM transaction_tag tt JOIN tag g ON g.id = tt.tag_id WHERE tt.created_by = ? AND tt.transaction_id IN (?" + strings.Repeat(", ?", len(chunk)-1) + ") ORDER BY g.name;"
+		args := make([]interface{}, 0, len(chunk)+1)
+		args = append(args, userId)
+		for _, t := range chunk {
+			args = append(args, t.ID)
+		}
+
+		rows, err := mySql.db.QueryContext(ctx, query, args...)
+		if err != nil {
+			logging.Logger.Errorf("[TraceID=%s] | failed to get tags in Storage.attachTransactionTags() function | Error: %v", traceID, err)
+			return appErrors.ErrorResponse{
+				Code:    appErrors.ErrInternal,
+				Message: "Failed to process transactions, try again later.",
+			}
+		}
+		for rows.Next() {
+			var transactionId, name string
+			if err := rows.Scan(&transactionId, &name); err != nil {
+				rows.Close()
+				logging.Logger.Errorf("[TraceID=%s] | failed to scan tag in Storage.attachTransactionTags() function | Error: %v", traceID, err)
+				return appErrors.ErrorResponse{
+					Code:    appErrors.ErrInternal,
+					Message: "Failed to process transactions, try again later.",
+				}
+			}
+			t := &transactions[byId[transactionId]]
+			t.Tags = append(t.Tags, name)
+		}
+		rows.Close()
+	}
+	return nil
+}
+
+// attachTransactionSplits loads the splits of the given transactions in place.
+func (mySql *MySQLStorage) attachTransactionSplits(ctx context.Context, userId string, transactions []budget.Transaction) error {
+	traceID := contextutil.TraceIDFromContext(ctx)
+
+	byId := make(map[string]int, len(transactions))
+	for i, t := range transactions {
+		byId[t.ID] = i
+	}
+
+	// keep the IN list well below the placeholder limit
+	const chunkSize = 1000
+	for start := 0; start < len(transactions); start += chunkSize {
+		end := min(start+chunkSize, len(transactions))
+		chunk := transactions[start:end]
+
+		query := "SELECT transaction_id, id, category_id, amount, note FROM transaction_split WHERE created_by = ? AND transaction_id IN (?" + strings.Repeat(", ?", len(chunk)-1) + ") ORDER BY transaction_id, position;"
+		args := make([]interface{}, 0, len(chunk)+1)
+		args = append(args, userId)
+		for _, t := range chunk {
+			args = append(args, t.ID)
+		}
+
+		rows, err := mySql.db.QueryContext(ctx, query, args...)
+		if err != nil {
+			logging.Logger.Errorf("[TraceID=%s] | failed to get splits in Storage.attachTransactionSplits() function | Error: %v", traceID, err)
+			return appErrors.ErrorResponse{
+				Code:    appErrors.ErrInternal,
+				Message: "Failed to process transactions, try again later.",
+			}
+		}
+		for rows.Next() {
+			var transactionId string
+			var split budget.TransactionSplit
+			if err := rows.Scan(&transactionId, &split.ID, &split.CategoryId, &split.Amount, &split.Note); err != nil {
+				rows.Close()
+				logging.Logger.Errorf("[TraceID=%s] | failed to scan split in Storage.attachTransactionSplits() function | Error: %v", traceID, err)
+				return appErrors.ErrorResponse{
+					Code:    appErrors.ErrInternal,
+					Message: "Failed to process transactions, try again later.",
+				}
+			}
+
+			t := &transactions[byId[transactionId]]
+			categoryName, err := mySql.getCategoryNameById(traceID, userId, split.CategoryId, t.CategoryType)
+			if err != nil {
+				logging.Logger.Errorf("[TraceID=%s] | failed to get Category name by Category ID Storage.attachTransactionSplits() | Error : %v", traceID, err)
+			} else {
+				split.CategoryName = *categoryName
+			}
+			t.Splits = append(t.Splits, split)
+		}
+		rows.Close()
+	}
+	return nil
+}
+
+// checkCategoryNotReconciled fails with ErrConflict when deleting the
+// category would delete or change a reconciled transaction.
+func checkCategoryNotReconciled(ctx context.Context, tx *sql.Tx, userId string, categoryId string, categoryType string) error {
+	traceID := contextutil.TraceIDFromContext(ctx)
+
+	query := `SELECT COUNT(*) FROM transaction t WHERE t.created_by = ? AND t.category_type = ? AND t.reconciled_at IS NOT NULL
+		AND (t.category_id = ? OR EXISTS (SELECT 1 FROM transaction_split s WHERE s.transaction_id = t.id AND s.category_id = ?));`
+	var count int
+	if err := tx.QueryRowContext(ctx, query, userId, categoryType, categoryId, categoryId).Scan(&count); err != nil {
+		logging.Logger.Errorf("[TraceID=%s] | failed to count reconciled transactions in checkCategoryNotReconciled() function | Error: %v", traceID, err)
+		return appErrors.ErrorResponse{
+			Code:    appErrors.ErrInternal,
+			Message: "Failed to delete the category.",
+		}
+	}
+	if count > 0 {
+		return appErrors.ErrorResponse{
+			Code:    appErrors.ErrConflict,
+			Message: fmt.Sprintf("The category has %d reconciled transaction(s), reconciled transactions cannot be deleted.", count),
+		}
+	}
+	return nil
+}
+
+// countCategoryTransactions counts the transactions in a category, including
+// the ones with only a split in it.
+func countCategoryTransactions(ctx context.Context, tx *sql.Tx, userId string, categoryId string, categoryType string) (int, error) {
+	query := `SELECT COUNT(*) FROM transaction t WHERE t.created_by = ? AND t.category_type = ?
+		AND (t.category_id = ? OR EXISTS (SELECT 1 FROM transaction_split s WHERE s.transaction_id = t.id AND s.category_id = ?));`
+	var count int
+	err := tx.QueryRowContext(ctx, query, userId, categoryType, categoryId, categoryId).Scan(&count)
+	return count, err
+}
+
+// moveCategoryTransactions points the transactions, splits, recurring
+// transactions, rules, payee defaults and debts of a category at another
+// category of the same type.
+// Amounts and wallets do not change, so reconciled transactions can be moved
+// too.
+func moveCategoryTransactions(ctx context.Context, tx *sql.Tx, userId string, categoryId string, moveTo string, categoryType string) error {
+	queries := []string{
+		"UPDATE transaction SET category_id = ? WHERE created_by = ? AND category_id = ? AND category_type = ?;",
+		`UPDATE transaction_split s JOIN transaction t ON t.id = s.transaction_id SET s.category_id = ?
+			WHERE s.created_by = ? AND s.category_id = ? AND t.category_type = ?;`,
+		"UPDATE recurring_transaction SET category_id = ? WHERE created_by = ? AND category_id = ? AND category_type = ?;",
+		"UPDATE transaction_rule SET set_category_id = ? WHERE created_by = ? AND set_category_id = ? AND category_type = ?;",
+		"UPDATE payee SET default_category_id = ? WHERE created_by = ? AND default_category_id = ? AND default_category_type = ?;",
+	}
+	for _, query := range queries {
+		if _, err := tx.ExecContext(ctx, query, moveTo, userId, categoryId, categoryType); err != nil {
+			return err
+		}
+	}
+	if categoryType != "-" {
+		return nil
+	}
+	// debts only have expense categories
+	debtQueries := []string{
+		"UPDATE debt SET category_id = ? WHERE created_by = ? AND category_id = ?;",
+		"UPDATE debt SET interest_category_id = ? WHERE created_by = ? AND interest_category_id = ?;",
+	}
+	for _, query := range debtQueries {
+		if _, err := tx.ExecContext(ctx, query, moveTo, userId, categoryId); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// deleteCategorySplits removes the splits in a category that is being
+// deleted. What is left of each split transaction keeps its remaining splits,
+// with the amount and category recalculated from them; transactions left
+// without any splits still point at the category and go with it.
+func deleteCategorySplits(ctx context.Context, tx *sql.Tx, userId string, categoryId string, categoryType string) error {
+	deleteQuery := `DELETE s FROM transaction_split s JOIN transaction t ON t.id = s.transaction_id
+		WHERE s.created_by = ? AND s.category_id = ? AND t.category_type = ?;`
+	result, err := tx.ExecContext(ctx, deleteQuery, userId, categoryId, categoryType)
+	if err != nil {
+		return err
+	}
+	if n, err := result.RowsAffected(); err == nil && n == 0 {
+		return nil
+	}
+
+	updateQuery := `UPDATE transaction t SET
+		t.amount = (SELECT SUM(s.amount) FROM transaction_split s WHERE s.transaction_id = t.id),
+		t.category_id = (SELECT s.category_id FROM transaction_split s WHERE s.transaction_id = t.id ORDER BY s.position LIMIT 1)
+		WHERE t.created_by = ? AND t.category_type = ?
+		AND EXISTS (SELECT 1 FROM transaction_split s WHERE s.transaction_id = t.id);`
+	_, err = tx.ExecContext(ctx, updateQuery, userId, categoryType)
+	return err
+}
+
+// transactionFilters builds the conditions of filters on the transaction
+// table, to add after "WHERE created_by = ?". none is set when the filters
+// cannot match anything.
+func (mySql *MySQLStorage) transactionFilters(traceID string, userID string, filters *budget.TransactionList) (query string, args []interface{}, none bool, err error) {
+	if len(filters.CategoryNames) > 0 {
+		if filters.Type != "+" && filters.Type != "-" {
+			return "", nil, false, appErrors.ErrorResponse{
+				Code:    appErrors.ErrInvalidInput,
+				Message: "Category names need category type income or expense.",
+			}
+		}
+		categoryIds := make([]string, 0, len(filters.CategoryNames))
+
+		for _, name := range filters.CategoryNames {
+			id, err := mySql.getCategoryIdByName(traceID, userID, name, filters.Type)
+			if err != nil {
+				logging.Logger.Errorf("[TraceID=%s] | failed to get category ID by Name  Storage.transactionFilters() function | Error : %v", traceID, err)
+				continue
+			}
+			categoryIds = append(categoryIds, *id)
+		}
+		if len(categoryIds) == 0 {
+			// none of the names is a category of the user
+			return "", nil, true, nil
+		}
+
+		placeholders := "(?" + strings.Repeat(",?", len(categoryIds)-1) + ")"
+		query += " AND (category_id IN " + placeholders + " OR id IN (SELECT transaction_id FROM transaction_split WHERE created_by = ? AND category_id IN " + placeholders + "))"
+		for _, id := range categoryIds {
+			args = append(args, id)
+		}
+		args = append(args, userID)
+		for _, id := range categoryIds {
+			args = append(args, id)
+		}
+	}
+
+	if filters.Amount > 0 {
+		query += " AND amount >= ?"
+		args = append(args, filters.Amount)
+	}
+
+	if !filters.OccurredAt.IsZero() {
+		query += " AND occurred_at >= ?"
+		args = append(args, filters.OccurredAt)
+	}
+
+	if filters.Currency != "" {
+		query += " AND currency = ?"
+		args = append(args, filters.Currency)
+	}
+
+	if filters.Type != "" {
+		query += " AND category_type = ?"
+		args = append(args, filters.Type)
+	}
+
+	if len(filters.Tags) > 0 {
+		placeholders := "(?" + strings.Repeat(",?", len(filters.Tags)-1) + ")"
+		tagQuery := "SELECT tt.transaction_id FROM transaction_tag tt JOIN tag g ON g.id = tt.tag_id WHERE g.created_by = ? AND g.name IN " + placeholders
+		args = append(args, userID)
+		for _, tag := range filters.Tags {
+			args = append(args, tag)
+		}
+		if filters.TagsMode == budget.TAGS_MODE_ALL {
+			tagQuery += " GROUP BY tt.transaction_id HAVING COUNT(DISTINCT g.id) = ?"
+			args = append(args, len(filters.Tags))
+		}
+		query += " AND id IN (" + tagQuery + ")"
+	}
+
+	if len(filters.PayeeIds) > 0 {
+		query += " AND payee_id IN (?" + strings.Repeat(",?", len(filters.PayeeIds)-1) + ")"
+		for _, id := range filters.PayeeIds {
+			args = append(args, id)
+		}
+	}
+
+	return query, args, false, nil
+}
+
+func (mySql *MySQLStorage) GetFilteredTransactions(ctx context.Context, userID string, filters *budget.TransactionList) ([]budget.Transaction, error) {
+	traceID := contextutil.TraceIDFromContext(ctx)
+	query := "SELECT " + transactionColumns + " FROM transaction WHERE created_by = ?"
+	args := []interface{}{userID}
+
+	if filters.IsAllNil {
+		query += " ORDER BY occurred_at DESC;"
+		rows, err := mySql.db.Query(query, args...)
+		if err != nil {
+			logging.Logger.Errorf("[TraceID=%s] | failed to get all transactions from Storage.GetFilteredTransactions() function | Error : %v", traceID, err)
+			return nil, appErrors.ErrorResponse{
+				Code:    appErrors.ErrInternal,
+				Message: "Failed to get transactions, try again later.",
+			}
+		}
+
+		transactions, err := mySql.processTransactionRows(ctx, rows, userID)
+		if err != nil {
+			return nil, err
+		}
+
+		return transactions, nil
+	}
+
+	filterQuery, filterArgs, none, err := mySql.transactionFilters(traceID, userID, filters)
+	if err != nil {
+		return nil, err
+	}
+	if none {
+		return []budget.Transaction{}, nil
+	}
+	query += filterQuery
+	args = append(args, filterArgs...)
+
+	query += " ORDER BY occurred_at DESC;"
+	rows, err := mySql.db.Query(query, args...)
+	if err != nil {
+		logging.Logger.Errorf("[TraceID=%s] | failed to get filtered transactions from Storage.GetFilteredTransactions() function | Error : %v", traceID, err)
+		return nil, appErrors.ErrorResponse{
+			Code:    appErrors.ErrInternal,
+			Message: "Failed to get transactions, try again later.",
+		}
+	}
+
+	transactions, err := mySql.processTransactionRows(ctx, rows, userID)
+	if err != nil {
+		return nil, err
 	}
 
 	return transactions, nil
@@ -1299,10 +1931,9 @@ func (mySql *MySQLStorage) GetFilteredTransactions(ctx context.Context, userID s
 func (mySql *MySQLStorage) GetTransactionById(ctx context.Context, userID string, transactionId string) (budget.Transaction, error) {
 	traceID := contextutil.TraceIDFromContext(ctx)
 
-	query := "SELECT id, category_name, amount, currency, created_at, note, created_by, category_type FROM transaction WHERE created_by = ? AND id = ?;"
+	query := "SELECT " + transactionColumns + " FROM transaction WHERE created_by = ? AND id = ?;"
 	row := mySql.db.QueryRow(query, userID, transactionId)
-	var transaction budget.Transaction
-	err := row.Scan(&transaction.ID, &transaction.ID, &transaction.Amount, &transaction.Currency, &transaction.CreatedAt, &transaction.Note, &transaction.CreatedBy, &transaction.CategoryType)
+	transaction, err := scanTransaction(row.Scan)
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
 			return budget.Transaction{}, appErrors.ErrorResponse{
@@ -1326,16 +1957,25 @@ func (mySql *MySQLStorage) GetTransactionById(ctx context.Context, userID string
 		}
 	}
 
-	return transaction, nil
+	transactions := []budget.Transaction{transaction}
+	if err := mySql.attachTransactionSplits(ctx, userID, transactions); err != nil {
+		return budget.Transaction{}, err
+	}
+	if err := mySql.attachTransactionTags(ctx, userID, transactions); err != nil {
+		return budget.Transaction{}, err
+	}
+
+	return transactions[0], nil
 }
 
 func (mySql *MySQLStorage) ValidateUser(ctx context.Context, credentials auth.UserCredentialsPure) (auth.User, error) {
 	traceID := contextutil.TraceIDFromContext(ctx)
 
-	query := "SELECT id, username, fullname, hashed_password, email FROM user WHERE username = ?;"
+	query := "SELECT id, username, fullname, hashed_password, email, deletion_scheduled_at FROM user WHERE username = ?;"
 	row := mySql.db.QueryRow(query, credentials.UserName)
 	var user auth.User
-	err := row.Scan(&user.ID, &user.UserName, &user.FullName, &user.PasswordHashed, &user.Email)
+	var deletionScheduledAt sql.NullTime
+	err := row.Scan(&user.ID, &user.UserName, &user.FullName, &user.PasswordHashed, &user.Email, &deletionScheduledAt)
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
 			return auth.User{}, appErrors.ErrorResponse{
@@ -1357,6 +1997,8 @@ func (mySql *MySQLStorage) ValidateUser(ctx context.Context, credentials auth.Us
 		}
 	}
 
+	user.DeletionScheduledAt = deletionScheduledAt.Time
+
 	return user, nil
 }
 
@@ -1445,119 +2087,390 @@ func (mySql *MySQLStorage) GetUserData(ctx context.Context, userId string) (budg
 			Message: "Failed to get account info, try later.",
 		}
 	}
+	wallets, err := mySql.GetWallets(ctx, userId)
+	if err != nil {
+		logging.Logger.Errorf("[TraceID=%s] | failed to get wallets in Storage.GetUserData() function | Error: %v", traceID, err)
+		return budget.UserDataResponse{}, appErrors.ErrorResponse{
+			Code:    appErrors.ErrInternal,
+			Message: "Failed to get account info, try later.",
+		}
+	}
+
+	tags, err := mySql.GetTags(ctx, userId)
+	if err != nil {
+		logging.Logger.Errorf("[TraceID=%s] | failed to get tags in Storage.GetUserData() function | Error: %v", traceID, err)
+		return budget.UserDataResponse{}, appErrors.ErrorResponse{
+			Code:    appErrors.ErrInternal,
+			Message: "Failed to get account info, try later.",
+		}
+	}
 
 	userData := budget.UserDataResponse{
 		ExpenseCategories: expenseCategories,
 		IncomeCategories:  incomeCategories,
 		Transactions:      transactions,
+		Wallets:           wallets,
+		Tags:              tags,
 	}
 
 	return userData, nil
 }
 
-func (mySql *MySQLStorage) DeleteUser(ctx context.Context, userId string, deleteReq auth.DeleteUser) error {
+func (mySql *MySQLStorage) EachTransaction(ctx context.Context, userId string, fn func(budget.Transaction) error) error {
+	return mySql.eachTransaction(ctx, "EachTransaction", "", []interface{}{userId}, "t.occurred_at, t.id", fn)
+}
+
+// EachFilteredTransaction streams the transactions matching filters, newest
+// first like GetFilteredTransactions, with the names of their categories.
+func (mySql *MySQLStorage) EachFilteredTransaction(ctx context.Context, userId string, filters *budget.TransactionList, fn func(budget.Transaction) error) error {
 	traceID := contextutil.TraceIDFromContext(ctx)
 
-	txn, err := mySql.db.Begin()
+	args := []interface{}{userId}
+	filterQuery := ""
+	if !filters.IsAllNil {
+		var filterArgs []interface{}
+		var none bool
+		var err error
+		filterQuery, filterArgs, none, err = mySql.transactionFilters(traceID, userId, filters)
+		if err != nil {
+			return err
+		}
+		if none {
+			return nil
+		}
+		args = append(args, filterArgs...)
+	}
+
+	// the category names of a user are few next to their transactions
+	names := map[string]string{}
+	query := "SELECT id, name FROM expense_category WHERE created_by = ? UNION ALL SELECT id, name FROM income_category WHERE created_by = ?;"
+	rows, err := mySql.db.QueryContext(ctx, query, userId, userId)
 	if err != nil {
-		logging.Logger.Errorf("[TraceID=%s] | failed to start SQL transaction in Storage.DeleteUser() function | Error: %v", traceID, err)
+		logging.Logger.Errorf("[TraceID=%s] | failed to get category names in Storage.EachFilteredTransaction() function | Error: %v", traceID, err)
 		return appErrors.ErrorResponse{
 			Code:    appErrors.ErrInternal,
-			Message: "Failed to delete account, try later.",
+			Message: "Failed to get transactions, try again later.",
 		}
 	}
-
-	var hashedPassword string
-	passwordQuery := "SELECT hashed_password FROM user WHERE id = ?;"
-	row := mySql.db.QueryRow(passwordQuery, userId)
-
-	if err := row.Scan(&hashedPassword); err != nil {
-		if errors.Is(err, sql.ErrNoRows) {
+	defer rows.Close()
+	for rows.Next() {
+		var id, name string
+		if err := rows.Scan(&id, &name); err != nil {
+			logging.Logger.Errorf("[TraceID=%s] | failed to scan category name in Storage.EachFilteredTransaction() function | Error: %v", traceID, err)
 			return appErrors.ErrorResponse{
 				Code:    appErrors.ErrInternal,
-				Message: "User does not exist.",
+				Message: "Failed to get transactions, try again later.",
 			}
 		}
-
-		txn.Rollback()
-		logging.Logger.Errorf("[TraceID=%s] | failed to scan user row in Storage.DeleteUser() function | Error : %v", traceID, err)
-		return appErrors.ErrorResponse{
-			Code:    appErrors.ErrInternal,
-			Message: "Failed to delete account, try later.",
-		}
+		names[id] = name
 	}
+	rows.Close()
 
-	if auth.ComparePasswords(hashedPassword, deleteReq.Password) != true {
-		txn.Rollback()
-		return appErrors.ErrorResponse{
-			Code:    appErrors.ErrInvalidInput,
-			Message: "Username or Password is incorrect",
+	return mySql.eachTransaction(ctx, "EachFilteredTransaction", filterQuery, args, "t.occurred_at DESC, t.id", func(t budget.Transaction) error {
+		t.CategoryName = names[t.CategoryId]
+		for i := range t.Splits {
+			t.Splits[i].CategoryName = names[t.Splits[i].CategoryId]
 		}
-	}
+		return fn(t)
+	})
+}
 
-	sessionDelQuery := "DELETE FROM session WHERE user_id = ?;"
-	_, err = mySql.db.Exec(sessionDelQuery, userId)
-	if err != nil {
-		txn.Rollback()
-		logging.Logger.Errorf("[TraceID=%s]| failed to delete all user sessions in Storage.DeleteUser() function | Error : %v", traceID, err)
-		return appErrors.ErrorResponse{
-			Code:    appErrors.ErrInternal,
-			Message: "Failed to delete account, try later.",
-		}
-	}
+// eachTransaction runs the query of the transactions of args[0] matching
+// filterQuery and calls fn for each of them in order.
+func (mySql *MySQLStorage) eachTransaction(ctx context.Context, caller string, filterQuery string, args []interface{}, order string, fn func(budget.Transaction) error) error {
+	traceID := contextutil.TraceIDFromContext(ctx)
 
-	txnDelQuery := "DELETE FROM transaction WHERE created_by = ?;"
-	_, err = mySql.db.Exec(txnDelQuery, userId)
+	// the driver reads the result set from the connection as rows.Next()
+	// advances, nothing is buffered beyond the current row. Splits come as
+	// extra rows right after their transaction and are gathered before fn.
+	// Tag names cannot contain a comma, so they are read as one list. The
+	// filters name the columns of the transaction table without an alias,
+	// so they are applied in a derived table.
+	query := "SELECT " + prefixColumns("t", transactionColumns) + `, t.external_id,
+		(SELECT GROUP_CONCAT(g.name ORDER BY g.name SEPARATOR ',') FROM transaction_tag tt JOIN tag g ON g.id = tt.tag_id WHERE tt.transaction_id = t.id),
+		s.id, s.category_id, s.amount, s.note
+		FROM (SELECT * FROM transaction WHERE created_by = ?` + filterQuery + `) t LEFT JOIN transaction_split s ON s.transaction_id = t.id
+		ORDER BY ` + order + ", s.position;"
+	rows, err := mySql.db.QueryContext(ctx, query, args...)
 	if err != nil {
-		txn.Rollback()
-		logging.Logger.Errorf("[TraceID=%s] | failed to delete all user transactions in Storage.DeleteUser() function | Error : %v", traceID, err)
+		logging.Logger.Errorf("[TraceID=%s] | failed to query transactions in Storage.%s() function | Error: %v", traceID, caller, err)
 		return appErrors.ErrorResponse{
 			Code:    appErrors.ErrInternal,
-			Message: "Failed to delete account, try later.",
+			Message: "Failed to get transactions, try again later.",
 		}
 	}
+	defer rows.Close()
 
-	incomeDelQuery := "DELETE FROM income_category WHERE created_by = ?;"
-	_, err = mySql.db.Exec(incomeDelQuery, userId)
+	var pending *budget.Transaction
+	for rows.Next() {
+		var externalId, tags, splitId, splitCategoryId, splitNote sql.NullString
+		var splitAmount sql.NullFloat64
+		transaction, err := scanTransaction(rows.Scan, &externalId, &tags, &splitId, &splitCategoryId, &splitAmount, &splitNote)
+		if err != nil {
+			logging.Logger.Errorf("[TraceID=%s] | failed to scan row in Storage.%s() function | Error: %v", traceID, caller, err)
+			return appErrors.ErrorResponse{
+				Code:    appErrors.ErrInternal,
+				Message: "Failed to process transactions, try again later.",
+			}
+		}
+		transaction.ExternalID = externalId.String
+		if tags.Valid {
+			transaction.Tags = strings.Split(tags.String, ",")
+		}
+
+		if pending == nil || pending.ID != transaction.ID {
+			if pending != nil {
+				if err := fn(*pending); err != nil {
+					return err
+				}
+			}
+			pending = &transaction
+		}
+		if splitId.Valid {
+			pending.Splits = append(pending.Splits, budget.TransactionSplit{
+				ID:         splitId.String,
+				CategoryId: splitCategoryId.String,
+				Amount:     splitAmount.Float64,
+				Note:       splitNote.String,
+			})
+		}
+	}
+
+	if err := rows.Err(); err != nil {
+		logging.Logger.Errorf("[TraceID=%s] | failed to iterate rows in Storage.%s() function | Error: %v", traceID, caller, err)
+		return appErrors.ErrorResponse{
+			Code:    appErrors.ErrInternal,
+			Message: "Failed to process transactions, try again later.",
+		}
+	}
+	if pending != nil {
+		return fn(*pending)
+	}
+	return nil
+}
+
+func (mySql *MySQLStorage) ScheduleUserDeletion(ctx context.Context, userId string, deleteReq auth.DeleteUser, purgeAt time.Time) error {
+	traceID := contextutil.TraceIDFromContext(ctx)
+
+	var hashedPassword string
+	passwordQuery := "SELECT hashed_password FROM user WHERE id = ?;"
+	row := mySql.db.QueryRow(passwordQuery, userId)
+
+	if err := row.Scan(&hashedPassword); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return appErrors.ErrorResponse{
+				Code:    appErrors.ErrInternal,
+				Message: "User does not exist.",
+			}
+		}
+
+		logging.Logger.Errorf("[TraceID=%s] | failed to scan user row in Storage.ScheduleUserDeletion() function | Error : %v", traceID, err)
+		return appErrors.ErrorResponse{
+			Code:    appErrors.ErrInternal,
+			Message: "Failed to delete account, try later.",
+		}
+	}
+
+	if auth.ComparePasswords(hashedPassword, deleteReq.Password) != true {
+		return appErrors.ErrorResponse{
+			Code:    appErrors.ErrInvalidInput,
+			Message: "Username or Password is incorrect",
+		}
+	}
+
+	txn, err := mySql.db.Begin()
 	if err != nil {
+		logging.Logger.Errorf("[TraceID=%s] | failed to start SQL transaction in Storage.ScheduleUserDeletion() function | Error: %v", traceID, err)
+		return appErrors.ErrorResponse{
+			Code:    appErrors.ErrInternal,
+			Message: "Failed to delete account, try later.",
+		}
+	}
+
+	scheduleQuery := "UPDATE user SET deletion_requested_at = UTC_TIMESTAMP(), deletion_scheduled_at = ?, deletion_reason = ? WHERE id = ?;"
+	if _, err := txn.Exec(scheduleQuery, purgeAt, deleteReq.Reason, userId); err != nil {
 		txn.Rollback()
-		logging.Logger.Errorf("[TraceID=%s] | failed to delete all user income categories in Storage.DeleteUser() function | Error : %v", traceID, err)
+		logging.Logger.Errorf("[TraceID=%s] | failed to schedule user deletion in Storage.ScheduleUserDeletion() function | Error : %v", traceID, err)
 		return appErrors.ErrorResponse{
 			Code:    appErrors.ErrInternal,
 			Message: "Failed to delete account, try later.",
 		}
 	}
 
-	expenseDelQuery := "DELETE FROM expense_category WHERE created_by = ?;"
-	_, err = mySql.db.Exec(expenseDelQuery, userId)
-	if err != nil {
+	sessionDelQuery := "DELETE FROM session WHERE user_id = ?;"
+	if _, err := txn.Exec(sessionDelQuery, userId); err != nil {
 		txn.Rollback()
-		logging.Logger.Errorf("[TraceID=%s]| failed to delete all user expense categories in Storage.DeleteUser() function | Error : %v", traceID, err)
+		logging.Logger.Errorf("[TraceID=%s]| failed to delete all user sessions in Storage.ScheduleUserDeletion() function | Error : %v", traceID, err)
+		return appErrors.ErrorResponse{
+			Code:    appErrors.ErrInternal,
+			Message: "Failed to delete account, try later.",
+		}
+	}
+
+	if err := txn.Commit(); err != nil {
+		logging.Logger.Errorf("[TraceID=%s] | failed to commit SQL transaction in Storage.ScheduleUserDeletion() function | Error : %v", traceID, err)
 		return appErrors.ErrorResponse{
 			Code:    appErrors.ErrInternal,
 			Message: "Failed to delete account, try later.",
 		}
 	}
 
-	userDelQuery := "DELETE FROM user where id = ?;"
-	_, err = mySql.db.Exec(userDelQuery, userId)
+	return nil
+}
+
+// RestoreUser cancels a scheduled deletion while its grace period is still
+// running, once it has passed the account belongs to the purge job.
+func (mySql *MySQLStorage) RestoreUser(ctx context.Context, userId string, now time.Time) error {
+	traceID := contextutil.TraceIDFromContext(ctx)
+
+	query := "UPDATE user SET deletion_requested_at = NULL, deletion_scheduled_at = NULL, deletion_reason = NULL WHERE id = ? AND deletion_scheduled_at IS NOT NULL AND deletion_scheduled_at > ?;"
+	result, err := mySql.db.Exec(query, userId, now)
+	if err != nil {
+		logging.Logger.Errorf("[TraceID=%s] | failed to restore user in Storage.RestoreUser() function | Error : %v", traceID, err)
+		return appErrors.ErrorResponse{
+			Code:    appErrors.ErrInternal,
+			Message: "Failed to restore account, try later.",
+		}
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		logging.Logger.Errorf("[TraceID=%s] | failed to check restore status in Storage.RestoreUser() function | Error : %v", traceID, err)
+		return appErrors.ErrorResponse{
+			Code:    appErrors.ErrInternal,
+			Message: "Failed to restore account, try later.",
+		}
+	}
+	if rowsAffected == 0 {
+		return appErrors.ErrorResponse{
+			Code:    appErrors.ErrInvalidInput,
+			Message: "Account is not scheduled for deletion or can no longer be restored.",
+		}
+	}
+
+	return nil
+}
+
+func (mySql *MySQLStorage) GetUsersDueForPurge(ctx context.Context, now time.Time) ([]auth.PendingDeletion, error) {
+	traceID := contextutil.TraceIDFromContext(ctx)
+
+	query := "SELECT id, username, fullname, email, deletion_reason, deletion_scheduled_at FROM user WHERE deletion_scheduled_at IS NOT NULL AND deletion_scheduled_at <= ?;"
+	rows, err := mySql.db.Query(query, now)
+	if err != nil {
+		logging.Logger.Errorf("[TraceID=%s] | failed to get users due for purge in Storage.GetUsersDueForPurge() function | Error : %v", traceID, err)
+		return nil, appErrors.ErrorResponse{
+			Code:    appErrors.ErrInternal,
+			Message: "Failed to get accounts pending deletion.",
+		}
+	}
+	defer rows.Close()
+
+	var pending []auth.PendingDeletion
+	for rows.Next() {
+		var p auth.PendingDeletion
+		var fullName, email, reason sql.NullString
+		if err := rows.Scan(&p.UserID, &p.UserName, &fullName, &email, &reason, &p.ScheduledAt); err != nil {
+			logging.Logger.Errorf("[TraceID=%s] | failed to scan row in Storage.GetUsersDueForPurge() function | Error : %v", traceID, err)
+			return nil, appErrors.ErrorResponse{
+				Code:    appErrors.ErrInternal,
+				Message: "Failed to get accounts pending deletion.",
+			}
+		}
+		p.FullName = fullName.String
+		p.Email = email.String
+		p.Reason = reason.String
+		pending = append(pending, p)
+	}
+
+	if err := rows.Err(); err != nil {
+		logging.Logger.Errorf("[TraceID=%s] | failed to iterate rows in Storage.GetUsersDueForPurge() function | Error : %v", traceID, err)
+		return nil, appErrors.ErrorResponse{
+			Code:    appErrors.ErrInternal,
+			Message: "Failed to get accounts pending deletion.",
+		}
+	}
+
+	return pending, nil
+}
+
+// PurgeUser permanently removes the account and everything it owns, then
+// records the already anonymized deletion reason. The user row is locked
+// first and an account that was restored or is not due yet at now is left
+// alone with an ErrConflict.
+func (mySql *MySQLStorage) PurgeUser(ctx context.Context, userId string, anonymizedReason string, now time.Time) error {
+	traceID := contextutil.TraceIDFromContext(ctx)
+
+	txn, err := mySql.db.Begin()
 	if err != nil {
+		logging.Logger.Errorf("[TraceID=%s] | failed to start SQL transaction in Storage.PurgeUser() function | Error: %v", traceID, err)
+		return appErrors.ErrorResponse{
+			Code:    appErrors.ErrInternal,
+			Message: "Failed to delete account.",
+		}
+	}
+
+	var lockedId string
+	dueQuery := "SELECT id FROM user WHERE id = ? AND deletion_scheduled_at IS NOT NULL AND deletion_scheduled_at <= ? FOR UPDATE;"
+	if err := txn.QueryRow(dueQuery, userId, now).Scan(&lockedId); err != nil {
 		txn.Rollback()
-		logging.Logger.Errorf("[TraceID=%s]| failed to delete user in Storage.DeleteUser() function | Error : %v", traceID, err)
+		if errors.Is(err, sql.ErrNoRows) {
+			return appErrors.ErrorResponse{
+				Code:    appErrors.ErrConflict,
+				Message: "Account is not due for deletion.",
+			}
+		}
+		logging.Logger.Errorf("[TraceID=%s] | failed to lock user in Storage.PurgeUser() function | Error : %v", traceID, err)
 		return appErrors.ErrorResponse{
 			Code:    appErrors.ErrInternal,
-			Message: "Failed to delete account, try later.",
+			Message: "Failed to delete account.",
+		}
+	}
+
+	deleteQueries := []struct {
+		query string
+		what  string
+	}{
+		{"DELETE FROM session WHERE user_id = ?;", "sessions"},
+		{"DELETE FROM transaction WHERE created_by = ?;", "transactions"},
+		{"DELETE FROM tag WHERE created_by = ?;", "tags"},
+		{"DELETE FROM payee WHERE created_by = ?;", "payees"},
+		{"DELETE FROM transaction_rule WHERE created_by = ?;", "rules"},
+		{"DELETE FROM category_merge WHERE created_by = ?;", "category merges"},
+		{"DELETE FROM reconciliation WHERE created_by = ?;", "reconciliations"},
+		{"DELETE FROM debt WHERE created_by = ?;", "debts"},
+		{"DELETE FROM savings_goal WHERE created_by = ?;", "savings goals"},
+		{"DELETE FROM wallet WHERE created_by = ?;", "wallets"},
+		{"DELETE FROM income_category WHERE created_by = ?;", "income categories"},
+		{"DELETE FROM expense_category WHERE created_by = ?;", "expense categories"},
+		{"DELETE FROM user WHERE id = ?;", "user"},
+	}
+
+	for _, d := range deleteQueries {
+		if _, err := txn.Exec(d.query, userId); err != nil {
+			txn.Rollback()
+			logging.Logger.Errorf("[TraceID=%s] | failed to delete %s in Storage.PurgeUser() function | Error : %v", traceID, d.what, err)
+			return appErrors.ErrorResponse{
+				Code:    appErrors.ErrInternal,
+				Message: "Failed to delete account.",
+			}
 		}
 	}
 
 	deleteInfoQuery := "INSERT INTO deleted_account (reason) VALUES (?);"
-	_, err = mySql.db.Exec(deleteInfoQuery, deleteReq.Reason)
-	if err != nil {
+	if _, err := txn.Exec(deleteInfoQuery, anonymizedReason); err != nil {
 		txn.Rollback()
-		logging.Logger.Errorf("[TraceID=%s] | failed to insert delete info in Storage.DeleteUser() function | Error : %v", traceID, err)
+		logging.Logger.Errorf("[TraceID=%s] | failed to insert delete info in Storage.PurgeUser() function | Error : %v", traceID, err)
 		return appErrors.ErrorResponse{
 			Code:    appErrors.ErrInternal,
-			Message: "Failed to delete account, try later.",
+			Message: "Failed to delete account.",
+		}
+	}
+
+	if err := txn.Commit(); err != nil {
+		logging.Logger.Errorf("[TraceID=%s] | failed to commit SQL transaction in Storage.PurgeUser() function | Error : %v", traceID, err)
+		return appErrors.ErrorResponse{
+			Code:    appErrors.ErrInternal,
+			Message: "Failed to delete account.",
 		}
 	}
 
@@ -1567,21 +2480,2215 @@ func (mySql *MySQLStorage) DeleteUser(ctx context.Context, userId string, delete
 func (mySql *MySQLStorage) GetAccountInfo(ctx context.Context, userId string) (budget.AccountInfo, error) {
 	var info budget.AccountInfo
 
+	// user.pending_email marks an unconfirmed registration, the address of
+	// an email change waits in email_verification until it is confirmed
 	query := `
-		SELECT username, fullname, email, joined_at
-		FROM user
-		WHERE id = ?
+		SELECT u.username, u.fullname, u.email, v.email, u.joined_at
+		FROM user u
+		LEFT JOIN email_verification v ON v.user_id = u.id AND v.expire_at > ?
+		WHERE u.id = ?
 	`
 
-	row := mySql.db.QueryRowContext(ctx, query, userId)
-	err := row.Scan(&info.Username, &info.Fullname, &info.Email, &info.JoinedAt)
+	var pendingEmail sql.NullString
+	row := mySql.db.QueryRowContext(ctx, query, time.Now().UTC(), userId)
+	err := row.Scan(&info.Username, &info.Fullname, &info.Email, &pendingEmail, &info.JoinedAt)
 	if err != nil {
 		return budget.AccountInfo{}, fmt.Errorf("GetAccountInfo: %w", err)
 	}
+	info.PendingEmail = pendingEmail.String
 
 	return info, nil
 }
 
-func (mySql *MySQLStorage) GetStorageType() string {
-	return "MySQL"
+func (mySql *MySQLStorage) UpdateAccount(ctx context.Context, userId string, userName string, fullName string) error {
+	traceID := contextutil.TraceIDFromContext(ctx)
+
+	query := "UPDATE user SET username = ?, fullname = ? WHERE id = ?;"
+	_, err := mySql.db.Exec(query, userName, fullName, userId)
+	if err != nil {
+		if mysqlErr, ok := err.(*mysql.MySQLError); ok {
+			if mysqlErr.Number == 1062 {
+				return appErrors.ErrorResponse{
+					Code:    appErrors.ErrConflict,
+					Message: "Username already taken!",
+				}
+			}
+		}
+		logging.Logger.Errorf("[TraceID=%s] | failed to update account in Storage.UpdateAccount() function | Error: %v", traceID, err)
+		return appErrors.ErrorResponse{
+			Code:    appErrors.ErrInternal,
+			Message: "Failed to update account, try later.",
+		}
+	}
+
+	return nil
+}
+
+func (mySql *MySQLStorage) SaveEmailVerification(ctx context.Context, verification auth.EmailVerification) error {
+	traceID := contextutil.TraceIDFromContext(ctx)
+
+	var dummy int
+	takenQuery := "SELECT 1 FROM user WHERE email = ? AND id <> ?;"
+	err := mySql.db.QueryRow(takenQuery, verification.Email, verification.UserID).Scan(&dummy)
+	if err == nil {
+		return appErrors.ErrorResponse{
+			Code:    appErrors.ErrConflict,
+			Message: "Email already taken!",
+		}
+	}
+	if !errors.Is(err, sql.ErrNoRows) {
+		logging.Logger.Errorf("[TraceID=%s] | failed to check email existence in Storage.SaveEmailVerification() function | Error: %v", traceID, err)
+		return appErrors.ErrorResponse{
+			Code:    appErrors.ErrInternal,
+			Message: "Failed to change email, try later.",
+		}
+	}
+
+	txn, err := mySql.db.Begin()
+	if err != nil {
+		logging.Logger.Errorf("[TraceID=%s] | failed to start SQL transaction in Storage.SaveEmailVerification() function | Error: %v", traceID, err)
+		return appErrors.ErrorResponse{
+			Code:    appErrors.ErrInternal,
+			Message: "Failed to change email, try later.",
+		}
+	}
+
+	// Only the latest requested address can be confirmed.
+	if _, err := txn.Exec("DELETE FROM email_verification WHERE user_id = ?;", verification.UserID); err != nil {
+		txn.Rollback()
+		logging.Logger.Errorf("[TraceID=%s] | failed to delete previous verifications in Storage.SaveEmailVerification() function | Error: %v", traceID, err)
+		return appErrors.ErrorResponse{
+			Code:    appErrors.ErrInternal,
+			Message: "Failed to change email, try later.",
+		}
+	}
+
+	insertQuery := "INSERT INTO email_verification (id, user_id, email, token, created_at, expire_at) VALUES (?, ?, ?, ?, ?, ?);"
+	if _, err := txn.Exec(insertQuery, verification.ID, verification.UserID, verification.Email, verification.Token, verification.CreatedAt, verification.ExpireAt); err != nil {
+		txn.Rollback()
+		logging.Logger.Errorf("[TraceID=%s] | failed to save email verification in Storage.SaveEmailVerification() function | Error: %v", traceID, err)
+		return appErrors.ErrorResponse{
+			Code:    appErrors.ErrInternal,
+			Message: "Failed to change email, try later.",
+		}
+	}
+
+	if err := txn.Commit(); err != nil {
+		logging.Logger.Errorf("[TraceID=%s] | failed to commit SQL transaction in Storage.SaveEmailVerification() function | Error: %v", traceID, err)
+		return appErrors.ErrorResponse{
+			Code:    appErrors.ErrInternal,
+			Message: "Failed to change email, try later.",
+		}
+	}
+
+	return nil
+}
+
+func (mySql *MySQLStorage) ConfirmEmailChange(ctx context.Context, userId string, token string) error {
+	traceID := contextutil.TraceIDFromContext(ctx)
+
+	var email string
+	var expireAt time.Time
+	query := "SELECT email, expire_at FROM email_verification WHERE user_id = ? AND token = ?;"
+	err := mySql.db.QueryRow(query, userId, token).Scan(&email, &expireAt)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return appErrors.ErrorResponse{
+				Code:    appErrors.ErrInvalidInput,
+				Message: "Verification code is invalid.",
+			}
+		}
+		logging.Logger.Errorf("[TraceID=%s] | failed to get email verification in Storage.ConfirmEmailChange() function | Error: %v", traceID, err)
+		return appErrors.ErrorResponse{
+			Code:    appErrors.ErrInternal,
+			Message: "Failed to confirm email, try later.",
+		}
+	}
+
+	if expireAt.Before(time.Now().UTC()) {
+		return appErrors.ErrorResponse{
+			Code:    appErrors.ErrInvalidInput,
+			Message: "Verification code expired, please request the change again.",
+		}
+	}
+
+	txn, err := mySql.db.Begin()
+	if err != nil {
+		logging.Logger.Errorf("[TraceID=%s] | failed to start SQL transaction in Storage.ConfirmEmailChange() function | Error: %v", traceID, err)
+		return appErrors.ErrorResponse{
+			Code:    appErrors.ErrInternal,
+			Message: "Failed to confirm email, try later.",
+		}
+	}
+
+	if _, err := txn.Exec("UPDATE user SET email = ? WHERE id = ?;", email, userId); err != nil {
+		txn.Rollback()
+		if mysqlErr, ok := err.(*mysql.MySQLError); ok {
+			if mysqlErr.Number == 1062 {
+				return appErrors.ErrorResponse{
+					Code:    appErrors.ErrConflict,
+					Message: "Email already taken!",
+				}
+			}
+		}
+		logging.Logger.Errorf("[TraceID=%s] | failed to update email in Storage.ConfirmEmailChange() function | Error: %v", traceID, err)
+		return appErrors.ErrorResponse{
+			Code:    appErrors.ErrInternal,
+			Message: "Failed to confirm email, try later.",
+		}
+	}
+
+	if _, err := txn.Exec("DELETE FROM email_verification WHERE user_id = ?;", userId); err != nil {
+		txn.Rollback()
+		logging.Logger.Errorf("[TraceID=%s] | failed to delete email verifications in Storage.ConfirmEmailChange() function | Error: %v", traceID, err)
+		return appErrors.ErrorResponse{
+			Code:    appErrors.ErrInternal,
+			Message: "Failed to confirm email, try later.",
+		}
+	}
+
+	if err := txn.Commit(); err != nil {
+		logging.Logger.Errorf("[TraceID=%s] | failed to commit SQL transaction in Storage.ConfirmEmailChange() function | Error: %v", traceID, err)
+		return appErrors.ErrorResponse{
+			Code:    appErrors.ErrInternal,
+			Message: "Failed to confirm email, try later.",
+		}
+	}
+
+	return nil
+}
+
+func (mySql *MySQLStorage) UpdatePassword(ctx context.Context, userId string, currentPassword string, newHashedPassword string) error {
+	traceID := contextutil.TraceIDFromContext(ctx)
+
+	var hashedPassword string
+	passwordQuery := "SELECT hashed_password FROM user WHERE id = ?;"
+	row := mySql.db.QueryRow(passwordQuery, userId)
+	if err := row.Scan(&hashedPassword); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return appErrors.ErrorResponse{
+				Code:    appErrors.ErrNotFound,
+				Message: "User does not exist.",
+			}
+		}
+		logging.Logger.Errorf("[TraceID=%s] | failed to scan user row in Storage.UpdatePassword() function | Error : %v", traceID, err)
+		return appErrors.ErrorResponse{
+			Code:    appErrors.ErrInternal,
+			Message: "Failed to change password, try later.",
+		}
+	}
+
+	if auth.ComparePasswords(hashedPassword, currentPassword) != true {
+		return appErrors.ErrorResponse{
+			Code:    appErrors.ErrInvalidInput,
+			Message: "Current password is incorrect",
+		}
+	}
+
+	updateQuery := "UPDATE user SET hashed_password = ? WHERE id = ?;"
+	_, err := mySql.db.Exec(updateQuery, newHashedPassword, userId)
+	if err != nil {
+		logging.Logger.Errorf("[TraceID=%s] | failed to update password in Storage.UpdatePassword() function | Error : %v", traceID, err)
+		return appErrors.ErrorResponse{
+			Code:    appErrors.ErrInternal,
+			Message: "Failed to change password, try later.",
+		}
+	}
+
+	return nil
+}
+
+func (mySql *MySQLStorage) SavePasswordReset(ctx context.Context, email string, reset auth.PasswordReset) error {
+	traceID := contextutil.TraceIDFromContext(ctx)
+
+	var userId string
+	err := mySql.db.QueryRow("SELECT id FROM user WHERE email = ?;", email).Scan(&userId)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return appErrors.ErrorResponse{
+				Code:    appErrors.ErrNotFound,
+				Message: "User does not exist.",
+			}
+		}
+		logging.Logger.Errorf("[TraceID=%s] | failed to get user by email in Storage.SavePasswordReset() function | Error: %v", traceID, err)
+		return appErrors.ErrorResponse{
+			Code:    appErrors.ErrInternal,
+			Message: "Failed to reset password, try later.",
+		}
+	}
+
+	txn, err := mySql.db.Begin()
+	if err != nil {
+		logging.Logger.Errorf("[TraceID=%s] | failed to start SQL transaction in Storage.SavePasswordReset() function | Error: %v", traceID, err)
+		return appErrors.ErrorResponse{
+			Code:    appErrors.ErrInternal,
+			Message: "Failed to reset password, try later.",
+		}
+	}
+
+	// Only the latest code can be used.
+	if _, err := txn.Exec("DELETE FROM password_reset WHERE user_id = ?;", userId); err != nil {
+		txn.Rollback()
+		logging.Logger.Errorf("[TraceID=%s] | failed to delete previous password resets in Storage.SavePasswordReset() function | Error: %v", traceID, err)
+		return appErrors.ErrorResponse{
+			Code:    appErrors.ErrInternal,
+			Message: "Failed to reset password, try later.",
+		}
+	}
+
+	insertQuery := "INSERT INTO password_reset (id, user_id, token, created_at, expire_at) VALUES (?, ?, ?, ?, ?);"
+	if _, err := txn.Exec(insertQuery, reset.ID, userId, reset.Token, reset.CreatedAt, reset.ExpireAt); err != nil {
+		txn.Rollback()
+		logging.Logger.Errorf("[TraceID=%s] | failed to save password reset in Storage.SavePasswordReset() function | Error: %v", traceID, err)
+		return appErrors.ErrorResponse{
+			Code:    appErrors.ErrInternal,
+			Message: "Failed to reset password, try later.",
+		}
+	}
+
+	if err := txn.Commit(); err != nil {
+		logging.Logger.Errorf("[TraceID=%s] | failed to commit SQL transaction in Storage.SavePasswordReset() function | Error: %v", traceID, err)
+		return appErrors.ErrorResponse{
+			Code:    appErrors.ErrInternal,
+			Message: "Failed to reset password, try later.",
+		}
+	}
+
+	return nil
+}
+
+func (mySql *MySQLStorage) GetPasswordResetUser(ctx context.Context, token string, now time.Time) (string, error) {
+	traceID := contextutil.TraceIDFromContext(ctx)
+
+	var userId string
+	query := "SELECT user_id FROM password_reset WHERE token = ? AND expire_at > ?;"
+	if err := mySql.db.QueryRow(query, token, now).Scan(&userId); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return "", appErrors.ErrorResponse{
+				Code:    appErrors.ErrInvalidInput,
+				Message: "Reset code is invalid or expired, please request a new one.",
+			}
+		}
+		logging.Logger.Errorf("[TraceID=%s] | failed to get password reset in Storage.GetPasswordResetUser() function | Error: %v", traceID, err)
+		return "", appErrors.ErrorResponse{
+			Code:    appErrors.ErrInternal,
+			Message: "Failed to reset password, try later.",
+		}
+	}
+
+	return userId, nil
+}
+
+func (mySql *MySQLStorage) ResetPassword(ctx context.Context, token string, newHashedPassword string, now time.Time) error {
+	traceID := contextutil.TraceIDFromContext(ctx)
+
+	fail := func(what string, err error) error {
+		logging.Logger.Errorf("[TraceID=%s] | failed to %s in Storage.ResetPassword() function | Error: %v", traceID, what, err)
+		return appErrors.ErrorResponse{
+			Code:    appErrors.ErrInternal,
+			Message: "Failed to reset password, try later.",
+		}
+	}
+
+	txn, err := mySql.db.Begin()
+	if err != nil {
+		return fail("start SQL transaction", err)
+	}
+
+	var userId string
+	lockQuery := "SELECT user_id FROM password_reset WHERE token = ? AND expire_at > ? FOR UPDATE;"
+	if err := txn.QueryRow(lockQuery, token, now).Scan(&userId); err != nil {
+		txn.Rollback()
+		if errors.Is(err, sql.ErrNoRows) {
+			return appErrors.ErrorResponse{
+				Code:    appErrors.ErrInvalidInput,
+				Message: "Reset code is invalid or expired, please request a new one.",
+			}
+		}
+		return fail("lock password reset", err)
+	}
+
+	if _, err := txn.Exec("UPDATE user SET hashed_password = ? WHERE id = ?;", newHashedPassword, userId); err != nil {
+		txn.Rollback()
+		return fail("update password", err)
+	}
+	if _, err := txn.Exec("DELETE FROM password_reset WHERE user_id = ?;", userId); err != nil {
+		txn.Rollback()
+		return fail("delete password resets", err)
+	}
+	if _, err := txn.Exec("DELETE FROM session WHERE user_id = ?;", userId); err != nil {
+		txn.Rollback()
+		return fail("delete user sessions", err)
+	}
+
+	if err := txn.Commit(); err != nil {
+		return fail("commit SQL transaction", err)
+	}
+
+	return nil
+}
+
+func (mySql *MySQLStorage) GetStorageType() string {
+	return "MySQL"
+}
+
+func (mySql *MySQLStorage) SaveImportBatch(ctx context.Context, userId string, batch budget.ImportBatch) error {
+	traceID := contextutil.TraceIDFromContext(ctx)
+
+	tx, err := mySql.db.BeginTx(ctx, nil)
+	if err != nil {
+		logging.Logger.Errorf("[TraceID=%s] | failed to start SQL transaction in Storage.SaveImportBatch() function | Error: %v", traceID, err)
+		return appErrors.ErrorResponse{
+			Code:    appErrors.ErrInternal,
+			Message: "Failed to import data, try again later.",
+		}
+	}
+	defer tx.Rollback()
+
+	conflictOrInternal := func(err error, what string) error {
+		if mysqlErr, ok := err.(*mysql.MySQLError); ok && mysqlErr.Number == 1062 {
+			return appErrors.ErrorResponse{
+				Code:    appErrors.ErrConflict,
+				Message: "The imported data conflicts with existing " + what + ", try again.",
+			}
+		}
+		logging.Logger.Errorf("[TraceID=%s] | failed to insert %s in Storage.SaveImportBatch() function | Error: %v", traceID, what, err)
+		return appErrors.ErrorResponse{
+			Code:    appErrors.ErrInternal,
+			Message: "Failed to import data, try again later.",
+		}
+	}
+
+	expenseQuery := "INSERT INTO expense_category (id, name, max_amount, period_day, created_at, updated_at, note, created_by, archived_at) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?);"
+	for _, c := range batch.ExpenseCategories {
+		if _, err := tx.ExecContext(ctx, expenseQuery, c.ID, c.Name, c.MaxAmount, c.PeriodDay, c.CreatedAt, c.UpdatedAt, c.Note, userId, nullTime(c.ArchivedAt)); err != nil {
+			return conflictOrInternal(err, "expense categories")
+		}
+	}
+
+	incomeQuery := "INSERT INTO income_category (id, name, target_amount, created_at, updated_at, note, created_by, archived_at) VALUES (?, ?, ?, ?, ?, ?, ?, ?);"
+	for _, c := range batch.IncomeCategories {
+		if _, err := tx.ExecContext(ctx, incomeQuery, c.ID, c.Name, c.TargetAmount, c.CreatedAt, c.UpdatedAt, c.Note, userId, nullTime(c.ArchivedAt)); err != nil {
+			return conflictOrInternal(err, "income categories")
+		}
+	}
+
+	// parents are set once every category exists, the archive is not ordered
+	for _, c := range batch.ExpenseCategories {
+		if c.ParentId == "" {
+			continue
+		}
+		if _, err := tx.ExecContext(ctx, "UPDATE expense_category SET parent_id = ? WHERE created_by = ? AND id = ?;", c.ParentId, userId, c.ID); err != nil {
+			return conflictOrInternal(err, "expense categories")
+		}
+	}
+	for _, c := range batch.IncomeCategories {
+		if c.ParentId == "" {
+			continue
+		}
+		if _, err := tx.ExecContext(ctx, "UPDATE income_category SET parent_id = ? WHERE created_by = ? AND id = ?;", c.ParentId, userId, c.ID); err != nil {
+			return conflictOrInternal(err, "income categories")
+		}
+	}
+
+	walletQuery := "INSERT INTO wallet (id, name, type, currency, opening_balance, created_at, updated_at, created_by) VALUES (?, ?, ?, ?, ?, ?, ?, ?);"
+	for _, w := range batch.Wallets {
+		if _, err := tx.ExecContext(ctx, walletQuery, w.ID, w.Name, w.Type, w.Currency, w.OpeningBalance, w.CreatedAt, w.UpdatedAt, userId); err != nil {
+			return conflictOrInternal(err, "wallets")
+		}
+	}
+
+	tagQuery := "INSERT INTO tag (id, name, created_at, updated_at, created_by) VALUES (?, ?, ?, ?, ?);"
+	for _, tag := range batch.Tags {
+		if _, err := tx.ExecContext(ctx, tagQuery, tag.ID, tag.Name, tag.CreatedAt, tag.UpdatedAt, userId); err != nil {
+			return conflictOrInternal(err, "tags")
+		}
+	}
+
+	transactionQuery := "INSERT INTO transaction (id, category_id, amount, currency, occurred_at, created_at, note, created_by, category_type, external_id, wallet_id, to_wallet_id, payee_id) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?);"
+	for _, t := range batch.Transactions {
+		if _, err := tx.ExecContext(ctx, transactionQuery, t.ID, emptyToNull(t.CategoryId), t.Amount, t.Currency, t.OccurredAt, t.CreatedAt, t.Note, userId, t.CategoryType, emptyToNull(t.ExternalID), emptyToNull(t.WalletId), emptyToNull(t.ToWalletId), emptyToNull(t.PayeeId)); err != nil {
+			return conflictOrInternal(err, "transactions")
+		}
+		if err := insertTransactionSplits(ctx, tx, userId, t); err != nil {
+			return conflictOrInternal(err, "transactions")
+		}
+		if err := insertTransactionTags(ctx, tx, userId, t); err != nil {
+			return conflictOrInternal(err, "transactions")
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		logging.Logger.Errorf("[TraceID=%s] | failed to commit SQL transaction in Storage.SaveImportBatch() function | Error: %v", traceID, err)
+		return appErrors.ErrorResponse{
+			Code:    appErrors.ErrInternal,
+			Message: "Failed to import data, try again later.",
+		}
+	}
+	return nil
+}
+
+func (mySql *MySQLStorage) GetExistingExternalIds(ctx context.Context, userId string, externalIds []string) (map[string]bool, error) {
+	traceID := contextutil.TraceIDFromContext(ctx)
+	existing := make(map[string]bool)
+
+	// keep the IN list well below the placeholder limit
+	const chunkSize = 1000
+	for start := 0; start < len(externalIds); start += chunkSize {
+		end := min(start+chunkSize, len(externalIds))
+		chunk := externalIds[start:end]
+
+		query := "SELECT external_id FROM transaction WHERE created_by = ? AND external_id IN (?" + strings.Repeat(", ?", len(chunk)-1) + ");"
+		args := make([]interface{}, 0, len(chunk)+1)
+		args = append(args, userId)
+		for _, id := range chunk {
+			args = append(args, id)
+		}
+
+		rows, err := mySql.db.QueryContext(ctx, query, args...)
+		if err != nil {
+			logging.Logger.Errorf("[TraceID=%s] | failed to get external ids in Storage.GetExistingExternalIds() function | Error: %v", traceID, err)
+			return nil, appErrors.ErrorResponse{
+				Code:    appErrors.ErrInternal,
+				Message: "Failed to check for duplicate transactions, try again later.",
+			}
+		}
+		for rows.Next() {
+			var id string
+			if err := rows.Scan(&id); err != nil {
+				rows.Close()
+				logging.Logger.Errorf("[TraceID=%s] | failed to scan external id in Storage.GetExistingExternalIds() function | Error: %v", traceID, err)
+				return nil, appErrors.ErrorResponse{
+					Code:    appErrors.ErrInternal,
+					Message: "Failed to check for duplicate transactions, try again later.",
+				}
+			}
+			existing[id] = true
+		}
+		rows.Close()
+	}
+
+	return existing, nil
+}
+
+func (mySql *MySQLStorage) SaveRecurringTransaction(ctx context.Context, r budget.RecurringTransaction) error {
+	traceID := contextutil.TraceIDFromContext(ctx)
+
+	categoryQuery := "SELECT COUNT(*) FROM expense_category WHERE id = ? AND created_by = ?;"
+	if r.CategoryType == "+" {
+		categoryQuery = "SELECT COUNT(*) FROM income_category WHERE id = ? AND created_by = ?;"
+	}
+	var count int
+	if err := mySql.db.QueryRowContext(ctx, categoryQuery, r.CategoryId, r.CreatedBy).Scan(&count); err != nil {
+		logging.Logger.Errorf("[TraceID=%s] | failed to check category in Storage.SaveRecurringTransaction() function | Error: %v", traceID, err)
+		return appErrors.ErrorResponse{
+			Code:    appErrors.ErrInternal,
+			Message: "Failed to save recurring transaction, try again later.",
+		}
+	}
+	if count == 0 {
+		return appErrors.ErrorResponse{
+			Code:    appErrors.ErrInvalidInput,
+			Message: "The category does not exist, please create the category",
+		}
+	}
+
+	query := "INSERT INTO recurring_transaction (id, category_id, category_type, amount, currency, note, rule, start_at, end_at, next_run_at, paused, paused_reason, created_at, updated_at, created_by) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?);"
+	_, err := mySql.db.ExecContext(ctx, query, r.ID, r.CategoryId, r.CategoryType, r.Amount, r.Currency, r.Note, r.Rule, r.StartAt,
+		nullTime(r.EndAt), nullTime(r.NextRunAt), r.Paused, r.PausedReason, r.CreatedAt, r.UpdatedAt, r.CreatedBy)
+	if err != nil {
+		logging.Logger.Errorf("[TraceID=%s] | failed to save recurring transaction in Storage.SaveRecurringTransaction() function | Error: %v", traceID, err)
+		return appErrors.ErrorResponse{
+			Code:    appErrors.ErrInternal,
+			Message: "Failed to save recurring transaction, try again later.",
+		}
+	}
+	return nil
+}
+
+const recurringTransactionColumns = "id, category_id, category_type, amount, currency, note, rule, start_at, end_at, next_run_at, paused, paused_reason, created_at, updated_at, created_by"
+
+func scanRecurringTransaction(scan func(dest ...interface{}) error) (budget.RecurringTransaction, error) {
+	var r budget.RecurringTransaction
+	var note sql.NullString
+	var endAt, nextRunAt sql.NullTime
+	err := scan(&r.ID, &r.CategoryId, &r.CategoryType, &r.Amount, &r.Currency, &note, &r.Rule, &r.StartAt, &endAt, &nextRunAt, &r.Paused, &r.PausedReason, &r.CreatedAt, &r.UpdatedAt, &r.CreatedBy)
+	r.Note = note.String
+	r.EndAt = endAt.Time
+	r.NextRunAt = nextRunAt.Time
+	return r, err
+}
+
+func (mySql *MySQLStorage) queryRecurringTransactions(ctx context.Context, caller string, query string, args ...interface{}) ([]budget.RecurringTransaction, error) {
+	traceID := contextutil.TraceIDFromContext(ctx)
+
+	rows, err := mySql.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		logging.Logger.Errorf("[TraceID=%s] | failed to get recurring transactions in Storage.%s() function | Error: %v", traceID, caller, err)
+		return nil, appErrors.ErrorResponse{
+			Code:    appErrors.ErrInternal,
+			Message: "Failed to get recurring transactions, try again later.",
+		}
+	}
+	defer rows.Close()
+
+	recurring := []budget.RecurringTransaction{}
+	for rows.Next() {
+		r, err := scanRecurringTransaction(rows.Scan)
+		if err != nil {
+			logging.Logger.Errorf("[TraceID=%s] | failed to scan row in Storage.%s() function | Error: %v", traceID, caller, err)
+			return nil, appErrors.ErrorResponse{
+				Code:    appErrors.ErrInternal,
+				Message: "Failed to get recurring transactions, try again later.",
+			}
+		}
+		recurring = append(recurring, r)
+	}
+	if err := rows.Err(); err != nil {
+		logging.Logger.Errorf("[TraceID=%s] | failed to iterate rows in Storage.%s() function | Error: %v", traceID, caller, err)
+		return nil, appErrors.ErrorResponse{
+			Code:    appErrors.ErrInternal,
+			Message: "Failed to get recurring transactions, try again later.",
+		}
+	}
+	return recurring, nil
+}
+
+func (mySql *MySQLStorage) GetRecurringTransactions(ctx context.Context, userId string) ([]budget.RecurringTransaction, error) {
+	query := "SELECT " + recurringTransactionColumns + " FROM recurring_transaction WHERE created_by = ? ORDER BY created_at;"
+	return mySql.queryRecurringTransactions(ctx, "GetRecurringTransactions", query, userId)
+}
+
+func (mySql *MySQLStorage) GetDueRecurringTransactions(ctx context.Context, now time.Time) ([]budget.RecurringTransaction, error) {
+	// accounts waiting for deletion are left alone
+	query := "SELECT " + prefixColumns("r", recurringTransactionColumns) + ` FROM recurring_transaction r
+		JOIN user u ON u.id = r.created_by
+		WHERE r.paused = FALSE AND r.next_run_at IS NOT NULL AND r.next_run_at <= ? AND u.deletion_scheduled_at IS NULL
+		ORDER BY r.next_run_at;`
+	return mySql.queryRecurringTransactions(ctx, "GetDueRecurringTransactions", query, now)
+}
+
+func (mySql *MySQLStorage) GetRecurringTransactionById(ctx context.Context, userId string, id string) (budget.RecurringTransaction, error) {
+	traceID := contextutil.TraceIDFromContext(ctx)
+
+	query := "SELECT " + recurringTransactionColumns + " FROM recurring_transaction WHERE created_by = ? AND id = ?;"
+	r, err := scanRecurringTransaction(mySql.db.QueryRowContext(ctx, query, userId, id).Scan)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return budget.RecurringTransaction{}, appErrors.ErrorResponse{
+				Code:    appErrors.ErrNotFound,
+				Message: "Recurring transaction not found.",
+			}
+		}
+		logging.Logger.Errorf("[TraceID=%s] | failed to get recurring transaction in Storage.GetRecurringTransactionById() function | Error: %v", traceID, err)
+		return budget.RecurringTransaction{}, appErrors.ErrorResponse{
+			Code:    appErrors.ErrInternal,
+			Message: "Failed to get recurring transaction, try again later.",
+		}
+	}
+	return r, nil
+}
+
+func (mySql *MySQLStorage) UpdateRecurringSchedule(ctx context.Context, r budget.RecurringTransaction) error {
+	traceID := contextutil.TraceIDFromContext(ctx)
+
+	query := "UPDATE recurring_transaction SET next_run_at = ?, paused = ?, paused_reason = ?, updated_at = ? WHERE created_by = ? AND id = ?;"
+	_, err := mySql.db.ExecContext(ctx, query, nullTime(r.NextRunAt), r.Paused, r.PausedReason, r.UpdatedAt, r.CreatedBy, r.ID)
+	if err != nil {
+		logging.Logger.Errorf("[TraceID=%s] | failed to update recurring transaction in Storage.UpdateRecurringSchedule() function | Error: %v", traceID, err)
+		return appErrors.ErrorResponse{
+			Code:    appErrors.ErrInternal,
+			Message: "Failed to update recurring transaction, try again later.",
+		}
+	}
+	return nil
+}
+
+func (mySql *MySQLStorage) SaveRecurringInstance(ctx context.Context, r budget.RecurringTransaction, t budget.Transaction) error {
+	traceID := contextutil.TraceIDFromContext(ctx)
+
+	fail := func(what string, err error) error {
+		logging.Logger.Errorf("[TraceID=%s] | failed to %s in Storage.SaveRecurringInstance() function | Error: %v", traceID, what, err)
+		return appErrors.ErrorResponse{
+			Code:    appErrors.ErrInternal,
+			Message: "Failed to save recurring transaction, try again later.",
+		}
+	}
+
+	isExist, _, err := mySql.isCategoryExists(traceID, t.CreatedBy, t.CategoryId, t.CategoryType)
+	if err != nil {
+		return err
+	}
+	if !isExist {
+		return appErrors.ErrorResponse{
+			Code:    appErrors.ErrInvalidInput,
+			Message: "The category does not exist, please create the category",
+		}
+	}
+
+	tx, err := mySql.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fail("start SQL transaction", err)
+	}
+	defer tx.Rollback()
+
+	// the lock makes a concurrent run wait here and then see the new next run
+	var nextRunAt sql.NullTime
+	var paused bool
+	lockQuery := "SELECT next_run_at, paused FROM recurring_transaction WHERE created_by = ? AND id = ? FOR UPDATE;"
+	err = tx.QueryRowContext(ctx, lockQuery, r.CreatedBy, r.ID).Scan(&nextRunAt, &paused)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return fail("lock recurring transaction", err)
+	}
+	if err != nil || paused || !nextRunAt.Valid || !nextRunAt.Time.Equal(t.OccurredAt) {
+		return appErrors.ErrorResponse{
+			Code:    appErrors.ErrConflict,
+			Message: "The instance was already created.",
+		}
+	}
+
+	insertQuery := "INSERT INTO transaction (id, category_id, amount, currency, occurred_at, created_at, note, created_by, category_type, wallet_id, payee_id) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?);"
+	if _, err := tx.ExecContext(ctx, insertQuery, t.ID, t.CategoryId, t.Amount, t.Currency, t.OccurredAt, t.CreatedAt, t.Note, t.CreatedBy, t.CategoryType, emptyToNull(t.WalletId), emptyToNull(t.PayeeId)); err != nil {
+		return fail("save transaction", err)
+	}
+	if err := insertTransactionTags(ctx, tx, t.CreatedBy, t); err != nil {
+		return fail("save tags", err)
+	}
+
+	updateQuery := "UPDATE recurring_transaction SET next_run_at = ?, updated_at = ? WHERE created_by = ? AND id = ?;"
+	if _, err := tx.ExecContext(ctx, updateQuery, nullTime(r.NextRunAt), r.UpdatedAt, r.CreatedBy, r.ID); err != nil {
+		return fail("advance recurring transaction", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fail("commit SQL transaction", err)
+	}
+	return nil
+}
+
+func (mySql *MySQLStorage) DeleteRecurringTransaction(ctx context.Context, userId string, id string) error {
+	traceID := contextutil.TraceIDFromContext(ctx)
+
+	result, err := mySql.db.ExecContext(ctx, "DELETE FROM recurring_transaction WHERE created_by = ? AND id = ?;", userId, id)
+	if err != nil {
+		logging.Logger.Errorf("[TraceID=%s] | failed to delete recurring transaction in Storage.DeleteRecurringTransaction() function | Error: %v", traceID, err)
+		return appErrors.ErrorResponse{
+			Code:    appErrors.ErrInternal,
+			Message: "Failed to delete recurring transaction, try again later.",
+		}
+	}
+	if n, err := result.RowsAffected(); err == nil && n == 0 {
+		return appErrors.ErrorResponse{
+			Code:    appErrors.ErrNotFound,
+			Message: "Recurring transaction not found.",
+		}
+	}
+	return nil
+}
+
+func nullTime(t time.Time) sql.NullTime {
+	return sql.NullTime{Time: t, Valid: !t.IsZero()}
+}
+
+// prefixColumns turns "id, name" into "r.id, r.name".
+func prefixColumns(alias string, columns string) string {
+	parts := strings.Split(columns, ", ")
+	for i, p := range parts {
+		parts[i] = alias + "." + p
+	}
+	return strings.Join(parts, ", ")
+}
+
+func (mySql *MySQLStorage) SaveWallet(ctx context.Context, w budget.Wallet) error {
+	traceID := contextutil.TraceIDFromContext(ctx)
+
+	query := "INSERT INTO wallet (id, name, type, currency, opening_balance, created_at, updated_at, created_by) VALUES (?, ?, ?, ?, ?, ?, ?, ?);"
+	_, err := mySql.db.ExecContext(ctx, query, w.ID, w.Name, w.Type, w.Currency, w.OpeningBalance, w.CreatedAt, w.UpdatedAt, w.CreatedBy)
+	if err != nil {
+		if mysqlErr, ok := err.(*mysql.MySQLError); ok && mysqlErr.Number == 1062 {
+			return appErrors.ErrorResponse{
+				Code:    appErrors.ErrConflict,
+				Message: "A wallet with this name already exists.",
+			}
+		}
+		logging.Logger.Errorf("[TraceID=%s] | failed to save wallet in Storage.SaveWallet() function | Error: %v", traceID, err)
+		return appErrors.ErrorResponse{
+			Code:    appErrors.ErrInternal,
+			Message: "Failed to save wallet, try again later.",
+		}
+	}
+	return nil
+}
+
+// walletQuery selects wallets with their balance: the opening balance, plus
+// income, minus expenses and outgoing transfers, plus incoming transfers.
+const walletQuery = `SELECT w.id, w.name, w.type, w.currency, w.opening_balance, w.created_at, w.updated_at, w.created_by,
+	w.opening_balance + IFNULL((
+		SELECT SUM(CASE
+			WHEN t.category_type = '>' AND t.to_wallet_id = w.id THEN t.amount
+			WHEN t.category_type = '+' THEN t.amount
+			ELSE -t.amount
+		END)
+		FROM transaction t
+		WHERE t.created_by = w.created_by AND (t.wallet_id = w.id OR t.to_wallet_id = w.id)
+	), 0) AS balance
+	FROM wallet w`
+
+func (mySql *MySQLStorage) GetWallets(ctx context.Context, userId string) ([]budget.Wallet, error) {
+	traceID := contextutil.TraceIDFromContext(ctx)
+
+	rows, err := mySql.db.QueryContext(ctx, walletQuery+" WHERE w.created_by = ? ORDER BY w.created_at;", userId)
+	if err != nil {
+		logging.Logger.Errorf("[TraceID=%s] | failed to get wallets in Storage.GetWallets() function | Error: %v", traceID, err)
+		return nil, appErrors.ErrorResponse{
+			Code:    appErrors.ErrInternal,
+			Message: "Failed to get wallets, try again later.",
+		}
+	}
+	defer rows.Close()
+
+	wallets := []budget.Wallet{}
+	for rows.Next() {
+		var w budget.Wallet
+		if err := rows.Scan(&w.ID, &w.Name, &w.Type, &w.Currency, &w.OpeningBalance, &w.CreatedAt, &w.UpdatedAt, &w.CreatedBy, &w.Balance); err != nil {
+			logging.Logger.Errorf("[TraceID=%s] | failed to scan wallet in Storage.GetWallets() function | Error: %v", traceID, err)
+			return nil, appErrors.ErrorResponse{
+				Code:    appErrors.ErrInternal,
+				Message: "Failed to get wallets, try again later.",
+			}
+		}
+		wallets = append(wallets, w)
+	}
+	if err := rows.Err(); err != nil {
+		logging.Logger.Errorf("[TraceID=%s] | failed to iterate wallets in Storage.GetWallets() function | Error: %v", traceID, err)
+		return nil, appErrors.ErrorResponse{
+			Code:    appErrors.ErrInternal,
+			Message: "Failed to get wallets, try again later.",
+		}
+	}
+	return wallets, nil
+}
+
+func (mySql *MySQLStorage) GetWalletById(ctx context.Context, userId string, id string) (budget.Wallet, error) {
+	traceID := contextutil.TraceIDFromContext(ctx)
+
+	var w budget.Wallet
+	row := mySql.db.QueryRowContext(ctx, walletQuery+" WHERE w.created_by = ? AND w.id = ?;", userId, id)
+	if err := row.Scan(&w.ID, &w.Name, &w.Type, &w.Currency, &w.OpeningBalance, &w.CreatedAt, &w.UpdatedAt, &w.CreatedBy, &w.Balance); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return budget.Wallet{}, appErrors.ErrorResponse{
+				Code:    appErrors.ErrNotFound,
+				Message: "Wallet not found.",
+			}
+		}
+		logging.Logger.Errorf("[TraceID=%s] | failed to get wallet in Storage.GetWalletById() function | Error: %v", traceID, err)
+		return budget.Wallet{}, appErrors.ErrorResponse{
+			Code:    appErrors.ErrInternal,
+			Message: "Failed to get wallet, try again later.",
+		}
+	}
+	return w, nil
+}
+
+func (mySql *MySQLStorage) UpdateWallet(ctx context.Context, w budget.Wallet) error {
+	traceID := contextutil.TraceIDFromContext(ctx)
+
+	query := "UPDATE wallet SET name = ?, type = ?, opening_balance = ?, updated_at = ? WHERE created_by = ? AND id = ?;"
+	if _, err := mySql.db.ExecContext(ctx, query, w.Name, w.Type, w.OpeningBalance, w.UpdatedAt, w.CreatedBy, w.ID); err != nil {
+		if mysqlErr, ok := err.(*mysql.MySQLError); ok && mysqlErr.Number == 1062 {
+			return appErrors.ErrorResponse{
+				Code:    appErrors.ErrConflict,
+				Message: "A wallet with this name already exists.",
+			}
+		}
+		logging.Logger.Errorf("[TraceID=%s] | failed to update wallet in Storage.UpdateWallet() function | Error: %v", traceID, err)
+		return appErrors.ErrorResponse{
+			Code:    appErrors.ErrInternal,
+			Message: "Failed to update wallet, try again later.",
+		}
+	}
+	return nil
+}
+
+func (mySql *MySQLStorage) DeleteWallet(ctx context.Context, userId string, id string) error {
+	traceID := contextutil.TraceIDFromContext(ctx)
+
+	var count int
+	countQuery := "SELECT COUNT(*) FROM transaction WHERE created_by = ? AND (wallet_id = ? OR to_wallet_id = ?);"
+	if err := mySql.db.QueryRowContext(ctx, countQuery, userId, id, id).Scan(&count); err != nil {
+		logging.Logger.Errorf("[TraceID=%s] | failed to count wallet transactions in Storage.DeleteWallet() function | Error: %v", traceID, err)
+		return appErrors.ErrorResponse{
+			Code:    appErrors.ErrInternal,
+			Message: "Failed to delete wallet, try again later.",
+		}
+	}
+	if count > 0 {
+		return appErrors.ErrorResponse{
+			Code:    appErrors.ErrConflict,
+			Message: fmt.Sprintf("The wallet has %d transaction(s), move or delete them first.", count),
+		}
+	}
+
+	result, err := mySql.db.ExecContext(ctx, "DELETE FROM wallet WHERE created_by = ? AND id = ?;", userId, id)
+	if err != nil {
+		logging.Logger.Errorf("[TraceID=%s] | failed to delete wallet in Storage.DeleteWallet() function | Error: %v", traceID, err)
+		return appErrors.ErrorResponse{
+			Code:    appErrors.ErrInternal,
+			Message: "Failed to delete wallet, try again later.",
+		}
+	}
+	if n, err := result.RowsAffected(); err == nil && n == 0 {
+		return appErrors.ErrorResponse{
+			Code:    appErrors.ErrNotFound,
+			Message: "Wallet not found.",
+		}
+	}
+	return nil
+}
+
+func (mySql *MySQLStorage) SaveSavingsGoal(ctx context.Context, g budget.SavingsGoal) error {
+	traceID := contextutil.TraceIDFromContext(ctx)
+
+	query := "INSERT INTO savings_goal (id, name, target_amount, currency, target_date, wallet_id, note, created_at, updated_at, created_by) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?);"
+	_, err := mySql.db.ExecContext(ctx, query, g.ID, g.Name, g.TargetAmount, g.Currency, g.TargetDate, emptyToNull(g.WalletId), g.Note, g.CreatedAt, g.UpdatedAt, g.CreatedBy)
+	if err != nil {
+		if mysqlErr, ok := err.(*mysql.MySQLError); ok && mysqlErr.Number == 1062 {
+			return appErrors.ErrorResponse{
+				Code:    appErrors.ErrConflict,
+				Message: "A goal with this name already exists.",
+			}
+		}
+		logging.Logger.Errorf("[TraceID=%s] | failed to save goal in Storage.SaveSavingsGoal() function | Error: %v", traceID, err)
+		return appErrors.ErrorResponse{
+			Code:    appErrors.ErrInternal,
+			Message: "Failed to save goal, try again later.",
+		}
+	}
+	return nil
+}
+
+// savingsGoalQuery selects goals with what is saved in them.
+const savingsGoalQuery = `SELECT g.id, g.name, g.target_amount, g.currency, g.target_date, g.wallet_id, g.note, g.created_at, g.updated_at, g.created_by,
+	IFNULL((SELECT SUM(c.amount) FROM savings_contribution c WHERE c.goal_id = g.id), 0) AS saved
+	FROM savings_goal g`
+
+func scanSavingsGoal(scan func(dest ...interface{}) error) (budget.SavingsGoal, error) {
+	var g budget.SavingsGoal
+	var walletId sql.NullString
+	if err := scan(&g.ID, &g.Name, &g.TargetAmount, &g.Currency, &g.TargetDate, &walletId, &g.Note, &g.CreatedAt, &g.UpdatedAt, &g.CreatedBy, &g.Saved); err != nil {
+		return budget.SavingsGoal{}, err
+	}
+	g.WalletId = walletId.String
+	return g, nil
+}
+
+func (mySql *MySQLStorage) GetSavingsGoals(ctx context.Context, userId string) ([]budget.SavingsGoal, error) {
+	traceID := contextutil.TraceIDFromContext(ctx)
+
+	rows, err := mySql.db.QueryContext(ctx, savingsGoalQuery+" WHERE g.created_by = ? ORDER BY g.target_date;", userId)
+	if err != nil {
+		logging.Logger.Errorf("[TraceID=%s] | failed to get goals in Storage.GetSavingsGoals() function | Error: %v", traceID, err)
+		return nil, appErrors.ErrorResponse{
+			Code:    appErrors.ErrInternal,
+			Message: "Failed to get goals, try again later.",
+		}
+	}
+	defer rows.Close()
+
+	goals := []budget.SavingsGoal{}
+	for rows.Next() {
+		g, err := scanSavingsGoal(rows.Scan)
+		if err != nil {
+			logging.Logger.Errorf("[TraceID=%s] | failed to scan goal in Storage.GetSavingsGoals() function | Error: %v", traceID, err)
+			return nil, appErrors.ErrorResponse{
+				Code:    appErrors.ErrInternal,
+				Message: "Failed to get goals, try again later.",
+			}
+		}
+		goals = append(goals, g)
+	}
+	if err := rows.Err(); err != nil {
+		logging.Logger.Errorf("[TraceID=%s] | failed to iterate goals in Storage.GetSavingsGoals() function | Error: %v", traceID, err)
+		return nil, appErrors.ErrorResponse{
+			Code:    appErrors.ErrInternal,
+			Message: "Failed to get goals, try again later.",
+		}
+	}
+	return goals, nil
+}
+
+func (mySql *MySQLStorage) GetSavingsGoalById(ctx context.Context, userId string, id string) (budget.SavingsGoal, error) {
+	traceID := contextutil.TraceIDFromContext(ctx)
+
+	row := mySql.db.QueryRowContext(ctx, savingsGoalQuery+" WHERE g.created_by = ? AND g.id = ?;", userId, id)
+	g, err := scanSavingsGoal(row.Scan)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return budget.SavingsGoal{}, appErrors.ErrorResponse{
+				Code:    appErrors.ErrNotFound,
+				Message: "Goal not found.",
+			}
+		}
+		logging.Logger.Errorf("[TraceID=%s] | failed to get goal in Storage.GetSavingsGoalById() function | Error: %v", traceID, err)
+		return budget.SavingsGoal{}, appErrors.ErrorResponse{
+			Code:    appErrors.ErrInternal,
+			Message: "Failed to get goal, try again later.",
+		}
+	}
+	return g, nil
+}
+
+func (mySql *MySQLStorage) UpdateSavingsGoal(ctx context.Context, g budget.SavingsGoal) error {
+	traceID := contextutil.TraceIDFromContext(ctx)
+
+	query := "UPDATE savings_goal SET name = ?, target_amount = ?, target_date = ?, wallet_id = ?, note = ?, updated_at = ? WHERE created_by = ? AND id = ?;"
+	if _, err := mySql.db.ExecContext(ctx, query, g.Name, g.TargetAmount, g.TargetDate, emptyToNull(g.WalletId), g.Note, g.UpdatedAt, g.CreatedBy, g.ID); err != nil {
+		if mysqlErr, ok := err.(*mysql.MySQLError); ok && mysqlErr.Number == 1062 {
+			return appErrors.ErrorResponse{
+				Code:    appErrors.ErrConflict,
+				Message: "A goal with this name already exists.",
+			}
+		}
+		logging.Logger.Errorf("[TraceID=%s] | failed to update goal in Storage.UpdateSavingsGoal() function | Error: %v", traceID, err)
+		return appErrors.ErrorResponse{
+			Code:    appErrors.ErrInternal,
+			Message: "Failed to update goal, try again later.",
+		}
+	}
+	return nil
+}
+
+func (mySql *MySQLStorage) DeleteSavingsGoal(ctx context.Context, userId string, id string) error {
+	traceID := contextutil.TraceIDFromContext(ctx)
+
+	result, err := mySql.db.ExecContext(ctx, "DELETE FROM savings_goal WHERE created_by = ? AND id = ?;", userId, id)
+	if err != nil {
+		logging.Logger.Errorf("[TraceID=%s] | failed to delete goal in Storage.DeleteSavingsGoal() function | Error: %v", traceID, err)
+		return appErrors.ErrorResponse{
+			Code:    appErrors.ErrInternal,
+			Message: "Failed to delete goal, try again later.",
+		}
+	}
+	if n, err := result.RowsAffected(); err == nil && n == 0 {
+		return appErrors.ErrorResponse{
+			Code:    appErrors.ErrNotFound,
+			Message: "Goal not found.",
+		}
+	}
+	return nil
+}
+
+func (mySql *MySQLStorage) SaveSavingsContribution(ctx context.Context, c budget.SavingsContribution) error {
+	traceID := contextutil.TraceIDFromContext(ctx)
+
+	query := "INSERT INTO savings_contribution (id, goal_id, amount, occurred_at, note, created_at, created_by) VALUES (?, ?, ?, ?, ?, ?, ?);"
+	if _, err := mySql.db.ExecContext(ctx, query, c.ID, c.GoalId, c.Amount, c.OccurredAt, c.Note, c.CreatedAt, c.CreatedBy); err != nil {
+		logging.Logger.Errorf("[TraceID=%s] | failed to save contribution in Storage.SaveSavingsContribution() function | Error: %v", traceID, err)
+		return appErrors.ErrorResponse{
+			Code:    appErrors.ErrInternal,
+			Message: "Failed to save contribution, try again later.",
+		}
+	}
+	return nil
+}
+
+func (mySql *MySQLStorage) GetSavingsContributions(ctx context.Context, userId string, goalId string) ([]budget.SavingsContribution, error) {
+	traceID := contextutil.TraceIDFromContext(ctx)
+
+	query := `SELECT id, goal_id, amount, occurred_at, note, created_at, created_by FROM savings_contribution
+		WHERE created_by = ? AND goal_id = ? ORDER BY occurred_at, created_at;`
+	rows, err := mySql.db.QueryContext(ctx, query, userId, goalId)
+	if err != nil {
+		logging.Logger.Errorf("[TraceID=%s] | failed to get contributions in Storage.GetSavingsContributions() function | Error: %v", traceID, err)
+		return nil, appErrors.ErrorResponse{
+			Code:    appErrors.ErrInternal,
+			Message: "Failed to get contributions, try again later.",
+		}
+	}
+	defer rows.Close()
+
+	contributions := []budget.SavingsContribution{}
+	for rows.Next() {
+		var c budget.SavingsContribution
+		if err := rows.Scan(&c.ID, &c.GoalId, &c.Amount, &c.OccurredAt, &c.Note, &c.CreatedAt, &c.CreatedBy); err != nil {
+			logging.Logger.Errorf("[TraceID=%s] | failed to scan contribution in Storage.GetSavingsContributions() function | Error: %v", traceID, err)
+			return nil, appErrors.ErrorResponse{
+				Code:    appErrors.ErrInternal,
+				Message: "Failed to get contributions, try again later.",
+			}
+		}
+		contributions = append(contributions, c)
+	}
+	if err := rows.Err(); err != nil {
+		logging.Logger.Errorf("[TraceID=%s] | failed to iterate contributions in Storage.GetSavingsContributions() function | Error: %v", traceID, err)
+		return nil, appErrors.ErrorResponse{
+			Code:    appErrors.ErrInternal,
+			Message: "Failed to get contributions, try again later.",
+		}
+	}
+	return contributions, nil
+}
+
+func (mySql *MySQLStorage) SaveDebt(ctx context.Context, d budget.Debt) error {
+	traceID := contextutil.TraceIDFromContext(ctx)
+
+	query := `INSERT INTO debt (id, name, principal, interest_rate, term_months, first_payment_at, currency, wallet_id, category_id, interest_category_id, note, created_at, updated_at, created_by)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?);`
+	_, err := mySql.db.ExecContext(ctx, query, d.ID, d.Name, d.Principal, d.InterestRate, d.TermMonths, d.FirstPaymentAt, d.Currency,
+		emptyToNull(d.WalletId), emptyToNull(d.CategoryId), emptyToNull(d.InterestCategoryId), d.Note, d.CreatedAt, d.UpdatedAt, d.CreatedBy)
+	if err != nil {
+		if mysqlErr, ok := err.(*mysql.MySQLError); ok && mysqlErr.Number == 1062 {
+			return appErrors.ErrorResponse{
+				Code:    appErrors.ErrConflict,
+				Message: "A debt with this name already exists.",
+			}
+		}
+		logging.Logger.Errorf("[TraceID=%s] | failed to save debt in Storage.SaveDebt() function | Error: %v", traceID, err)
+		return appErrors.ErrorResponse{
+			Code:    appErrors.ErrInternal,
+			Message: "Failed to save debt, try again later.",
+		}
+	}
+	return nil
+}
+
+// debtQuery selects debts with what is paid of them.
+const debtQuery = `SELECT d.id, d.name, d.principal, d.interest_rate, d.term_months, d.first_payment_at, d.currency,
+	IFNULL(d.wallet_id, ''), IFNULL(d.category_id, ''), IFNULL(d.interest_category_id, ''), d.note, d.created_at, d.updated_at, d.created_by,
+	IFNULL(p.paid_principal, 0), IFNULL(p.paid_interest, 0), p.last_payment_at
+	FROM debt d
+	LEFT JOIN (
+		SELECT debt_id, SUM(principal) AS paid_principal, SUM(interest) AS paid_interest, MAX(occurred_at) AS last_payment_at
+		FROM debt_payment GROUP BY debt_id
+	) p ON p.debt_id = d.id`
+
+func scanDebt(scan func(dest ...interface{}) error) (budget.Debt, error) {
+	var d budget.Debt
+	var lastPaymentAt sql.NullTime
+	err := scan(&d.ID, &d.Name, &d.Principal, &d.InterestRate, &d.TermMonths, &d.FirstPaymentAt, &d.Currency,
+		&d.WalletId, &d.CategoryId, &d.InterestCategoryId, &d.Note, &d.CreatedAt, &d.UpdatedAt, &d.CreatedBy,
+		&d.PaidPrincipal, &d.PaidInterest, &lastPaymentAt)
+	if err != nil {
+		return budget.Debt{}, err
+	}
+	d.LastPaymentAt = lastPaymentAt.Time
+	return d, nil
+}
+
+func (mySql *MySQLStorage) GetDebts(ctx context.Context, userId string) ([]budget.Debt, error) {
+	traceID := contextutil.TraceIDFromContext(ctx)
+
+	rows, err := mySql.db.QueryContext(ctx, debtQuery+" WHERE d.created_by = ? ORDER BY d.name;", userId)
+	if err != nil {
+		logging.Logger.Errorf("[TraceID=%s] | failed to get debts in Storage.GetDebts() function | Error: %v", traceID, err)
+		return nil, appErrors.ErrorResponse{
+			Code:    appErrors.ErrInternal,
+			Message: "Failed to get debts, try again later.",
+		}
+	}
+	defer rows.Close()
+
+	debts := []budget.Debt{}
+	for rows.Next() {
+		d, err := scanDebt(rows.Scan)
+		if err != nil {
+			logging.Logger.Errorf("[TraceID=%s] | failed to scan debt in Storage.GetDebts() function | Error: %v", traceID, err)
+			return nil, appErrors.ErrorResponse{
+				Code:    appErrors.ErrInternal,
+				Message: "Failed to get debts, try again later.",
+			}
+		}
+		debts = append(debts, d)
+	}
+	if err := rows.Err(); err != nil {
+		logging.Logger.Errorf("[TraceID=%s] | failed to iterate debts in Storage.GetDebts() function | Error: %v", traceID, err)
+		return nil, appErrors.ErrorResponse{
+			Code:    appErrors.ErrInternal,
+			Message: "Failed to get debts, try again later.",
+		}
+	}
+	return debts, nil
+}
+
+func (mySql *MySQLStorage) GetDebtById(ctx context.Context, userId string, id string) (budget.Debt, error) {
+	traceID := contextutil.TraceIDFromContext(ctx)
+
+	row := mySql.db.QueryRowContext(ctx, debtQuery+" WHERE d.created_by = ? AND d.id = ?;", userId, id)
+	d, err := scanDebt(row.Scan)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return budget.Debt{}, appErrors.ErrorResponse{
+				Code:    appErrors.ErrNotFound,
+				Message: "Debt not found.",
+			}
+		}
+		logging.Logger.Errorf("[TraceID=%s] | failed to get debt in Storage.GetDebtById() function | Error: %v", traceID, err)
+		return budget.Debt{}, appErrors.ErrorResponse{
+			Code:    appErrors.ErrInternal,
+			Message: "Failed to get debt, try again later.",
+		}
+	}
+	return d, nil
+}
+
+func (mySql *MySQLStorage) UpdateDebt(ctx context.Context, d budget.Debt) error {
+	traceID := contextutil.TraceIDFromContext(ctx)
+
+	query := `UPDATE debt SET name = ?, principal = ?, interest_rate = ?, term_months = ?, first_payment_at = ?, wallet_id = ?,
+		category_id = ?, interest_category_id = ?, note = ?, updated_at = ? WHERE created_by = ? AND id = ?;`
+	_, err := mySql.db.ExecContext(ctx, query, d.Name, d.Principal, d.InterestRate, d.TermMonths, d.FirstPaymentAt, emptyToNull(d.WalletId),
+		emptyToNull(d.CategoryId), emptyToNull(d.InterestCategoryId), d.Note, d.UpdatedAt, d.CreatedBy, d.ID)
+	if err != nil {
+		if mysqlErr, ok := err.(*mysql.MySQLError); ok && mysqlErr.Number == 1062 {
+			return appErrors.ErrorResponse{
+				Code:    appErrors.ErrConflict,
+				Message: "A debt with this name already exists.",
+			}
+		}
+		logging.Logger.Errorf("[TraceID=%s] | failed to update debt in Storage.UpdateDebt() function | Error: %v", traceID, err)
+		return appErrors.ErrorResponse{
+			Code:    appErrors.ErrInternal,
+			Message: "Failed to update debt, try again later.",
+		}
+	}
+	return nil
+}
+
+func (mySql *MySQLStorage) DeleteDebt(ctx context.Context, userId string, id string) error {
+	traceID := contextutil.TraceIDFromContext(ctx)
+
+	result, err := mySql.db.ExecContext(ctx, "DELETE FROM debt WHERE created_by = ? AND id = ?;", userId, id)
+	if err != nil {
+		logging.Logger.Errorf("[TraceID=%s] | failed to delete debt in Storage.DeleteDebt() function | Error: %v", traceID, err)
+		return appErrors.ErrorResponse{
+			Code:    appErrors.ErrInternal,
+			Message: "Failed to delete debt, try again later.",
+		}
+	}
+	if n, err := result.RowsAffected(); err == nil && n == 0 {
+		return appErrors.ErrorResponse{
+			Code:    appErrors.ErrNotFound,
+			Message: "Debt not found.",
+		}
+	}
+	return nil
+}
+
+// SaveDebtPayment inserts the expense transaction of a payment, its splits
+// and the payment itself in one SQL transaction.
+func (mySql *MySQLStorage) SaveDebtPayment(ctx context.Context, p budget.DebtPayment, t budget.Transaction) error {
+	traceID := contextutil.TraceIDFromContext(ctx)
+
+	categoryIds := []string{t.CategoryId}
+	for _, split := range t.Splits {
+		categoryIds = append(categoryIds, split.CategoryId)
+	}
+	for _, id := range categoryIds {
+		isExist, _, err := mySql.isCategoryExists(traceID, t.CreatedBy, id, t.CategoryType)
+		if err != nil {
+			return err
+		}
+		if !isExist {
+			return appErrors.ErrorResponse{
+				Code:    appErrors.ErrInvalidInput,
+				Message: "The category of the debt does not exist, update the debt before adding payments.",
+			}
+		}
+	}
+
+	fail := func(what string, err error) error {
+		logging.Logger.Errorf("[TraceID=%s] | failed to %s in Storage.SaveDebtPayment() function | Error: %v", traceID, what, err)
+		return appErrors.ErrorResponse{
+			Code:    appErrors.ErrInternal,
+			Message: "Failed to save payment, try again later.",
+		}
+	}
+
+	tx, err := mySql.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fail("start SQL transaction", err)
+	}
+	defer tx.Rollback()
+
+	query := "INSERT INTO transaction (id, category_id, amount, currency, occurred_at, created_at, note, created_by, category_type, wallet_id) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?);"
+	if _, err := tx.ExecContext(ctx, query, t.ID, t.CategoryId, t.Amount, t.Currency, t.OccurredAt, t.CreatedAt, t.Note, t.CreatedBy, t.CategoryType, emptyToNull(t.WalletId)); err != nil {
+		return fail("save transaction", err)
+	}
+	if err := insertTransactionSplits(ctx, tx, t.CreatedBy, t); err != nil {
+		return fail("save splits", err)
+	}
+
+	query = "INSERT INTO debt_payment (id, debt_id, transaction_id, amount, principal, interest, occurred_at, created_at, created_by) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?);"
+	if _, err := tx.ExecContext(ctx, query, p.ID, p.DebtId, p.TransactionId, p.Amount, p.Principal, p.Interest, p.OccurredAt, p.CreatedAt, p.CreatedBy); err != nil {
+		return fail("save payment", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fail("commit SQL transaction", err)
+	}
+	return nil
+}
+
+func (mySql *MySQLStorage) GetDebtPayments(ctx context.Context, userId string, debtId string) ([]budget.DebtPayment, error) {
+	traceID := contextutil.TraceIDFromContext(ctx)
+
+	query := `SELECT id, debt_id, IFNULL(transaction_id, ''), amount, principal, interest, occurred_at, created_at, created_by FROM debt_payment
+		WHERE created_by = ? AND debt_id = ? ORDER BY occurred_at, created_at;`
+	rows, err := mySql.db.QueryContext(ctx, query, userId, debtId)
+	if err != nil {
+		logging.Logger.Errorf("[TraceID=%s] | failed to get payments in Storage.GetDebtPayments() function | Error: %v", traceID, err)
+		return nil, appErrors.ErrorResponse{
+			Code:    appErrors.ErrInternal,
+			Message: "Failed to get payments, try again later.",
+		}
+	}
+	defer rows.Close()
+
+	payments := []budget.DebtPayment{}
+	for rows.Next() {
+		var p budget.DebtPayment
+		if err := rows.Scan(&p.ID, &p.DebtId, &p.TransactionId, &p.Amount, &p.Principal, &p.Interest, &p.OccurredAt, &p.CreatedAt, &p.CreatedBy); err != nil {
+			logging.Logger.Errorf("[TraceID=%s] | failed to scan payment in Storage.GetDebtPayments() function | Error: %v", traceID, err)
+			return nil, appErrors.ErrorResponse{
+				Code:    appErrors.ErrInternal,
+				Message: "Failed to get payments, try again later.",
+			}
+		}
+		payments = append(payments, p)
+	}
+	if err := rows.Err(); err != nil {
+		logging.Logger.Errorf("[TraceID=%s] | failed to iterate payments in Storage.GetDebtPayments() function | Error: %v", traceID, err)
+		return nil, appErrors.ErrorResponse{
+			Code:    appErrors.ErrInternal,
+			Message: "Failed to get payments, try again later.",
+		}
+	}
+	return payments, nil
+}
+
+func (mySql *MySQLStorage) GetWalletTransactions(ctx context.Context, userId string, walletId string) ([]budget.Transaction, error) {
+	traceID := contextutil.TraceIDFromContext(ctx)
+
+	query := "SELECT " + transactionColumns + " FROM transaction WHERE created_by = ? AND (wallet_id = ? OR to_wallet_id = ?) ORDER BY occurred_at, created_at, id;"
+	rows, err := mySql.db.QueryContext(ctx, query, userId, walletId, walletId)
+	if err != nil {
+		logging.Logger.Errorf("[TraceID=%s] | failed to get wallet transactions in Storage.GetWalletTransactions() function | Error: %v", traceID, err)
+		return nil, appErrors.ErrorResponse{
+			Code:    appErrors.ErrInternal,
+			Message: "Failed to get transactions, try again later.",
+		}
+	}
+	return mySql.processTransactionRows(ctx, rows, userId)
+}
+
+const reconciliationColumns = "id, wallet_id, statement_balance, statement_date, finished_at, created_at, updated_at, created_by"
+
+// SaveReconciliation inserts a reconciliation or updates the statement of an
+// open one.
+func (mySql *MySQLStorage) SaveReconciliation(ctx context.Context, r budget.Reconciliation) error {
+	traceID := contextutil.TraceIDFromContext(ctx)
+
+	query := "INSERT INTO reconciliation (" + reconciliationColumns + `) VALUES (?, ?, ?, ?, ?, ?, ?, ?)
+		ON DUPLICATE KEY UPDATE statement_balance = VALUES(statement_balance), statement_date = VALUES(statement_date), updated_at = VALUES(updated_at);`
+	_, err := mySql.db.ExecContext(ctx, query, r.ID, r.WalletId, r.StatementBalance, r.StatementDate, nullTime(r.FinishedAt), r.CreatedAt, r.UpdatedAt, r.CreatedBy)
+	if err != nil {
+		logging.Logger.Errorf("[TraceID=%s] | failed to save reconciliation in Storage.SaveReconciliation() function | Error: %v", traceID, err)
+		return appErrors.ErrorResponse{
+			Code:    appErrors.ErrInternal,
+			Message: "Failed to save reconciliation, try again later.",
+		}
+	}
+	return nil
+}
+
+func (mySql *MySQLStorage) GetOpenReconciliation(ctx context.Context, userId string, walletId string) (budget.Reconciliation, error) {
+	traceID := contextutil.TraceIDFromContext(ctx)
+
+	var r budget.Reconciliation
+	var finishedAt sql.NullTime
+	query := "SELECT " + reconciliationColumns + " FROM reconciliation WHERE created_by = ? AND wallet_id = ? AND finished_at IS NULL ORDER BY created_at DESC LIMIT 1;"
+	err := mySql.db.QueryRowContext(ctx, query, userId, walletId).Scan(&r.ID, &r.WalletId, &r.StatementBalance, &r.StatementDate, &finishedAt, &r.CreatedAt, &r.UpdatedAt, &r.CreatedBy)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return budget.Reconciliation{}, appErrors.ErrorResponse{
+				Code:    appErrors.ErrNotFound,
+				Message: "The wallet has no open reconciliation.",
+			}
+		}
+		logging.Logger.Errorf("[TraceID=%s] | failed to get reconciliation in Storage.GetOpenReconciliation() function | Error: %v", traceID, err)
+		return budget.Reconciliation{}, appErrors.ErrorResponse{
+			Code:    appErrors.ErrInternal,
+			Message: "Failed to get reconciliation, try again later.",
+		}
+	}
+	r.FinishedAt = finishedAt.Time
+	return r, nil
+}
+
+// SetTransactionsCleared never touches reconciled transactions.
+func (mySql *MySQLStorage) SetTransactionsCleared(ctx context.Context, userId string, transactionIds []string, cleared bool) error {
+	traceID := contextutil.TraceIDFromContext(ctx)
+
+	placeholders := strings.TrimSuffix(strings.Repeat("?, ", len(transactionIds)), ", ")
+	query := "UPDATE transaction SET cleared = ? WHERE created_by = ? AND reconciled_at IS NULL AND id IN (" + placeholders + ");"
+	args := []interface{}{cleared, userId}
+	for _, id := range transactionIds {
+		args = append(args, id)
+	}
+	if _, err := mySql.db.ExecContext(ctx, query, args...); err != nil {
+		logging.Logger.Errorf("[TraceID=%s] | failed to update cleared flag in Storage.SetTransactionsCleared() function | Error: %v", traceID, err)
+		return appErrors.ErrorResponse{
+			Code:    appErrors.ErrInternal,
+			Message: "Failed to update transactions, try again later.",
+		}
+	}
+	return nil
+}
+
+// FinishReconciliation locks the given transactions and closes r in one SQL
+// transaction.
+func (mySql *MySQLStorage) FinishReconciliation(ctx context.Context, r budget.Reconciliation, transactionIds []string) error {
+	traceID := contextutil.TraceIDFromContext(ctx)
+
+	fail := func(what string, err error) error {
+		logging.Logger.Errorf("[TraceID=%s] | failed to %s in Storage.FinishReconciliation() function | Error: %v", traceID, what, err)
+		return appErrors.ErrorResponse{
+			Code:    appErrors.ErrInternal,
+			Message: "Failed to finish reconciliation, try again later.",
+		}
+	}
+
+	tx, err := mySql.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fail("start SQL transaction", err)
+	}
+	defer tx.Rollback()
+
+	if len(transactionIds) > 0 {
+		placeholders := strings.TrimSuffix(strings.Repeat("?, ", len(transactionIds)), ", ")
+		query := "UPDATE transaction SET reconciled_at = ? WHERE created_by = ? AND cleared = TRUE AND reconciled_at IS NULL AND id IN (" + placeholders + ");"
+		args := []interface{}{r.FinishedAt, r.CreatedBy}
+		for _, id := range transactionIds {
+			args = append(args, id)
+		}
+		if _, err := tx.ExecContext(ctx, query, args...); err != nil {
+			return fail("lock reconciled transactions", err)
+		}
+	}
+
+	query := "UPDATE reconciliation SET finished_at = ?, updated_at = ? WHERE created_by = ? AND id = ? AND finished_at IS NULL;"
+	if _, err := tx.ExecContext(ctx, query, r.FinishedAt, r.UpdatedAt, r.CreatedBy, r.ID); err != nil {
+		return fail("close reconciliation", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fail("commit SQL transaction", err)
+	}
+	return nil
+}
+
+func (mySql *MySQLStorage) SaveTag(ctx context.Context, tag budget.Tag) error {
+	traceID := contextutil.TraceIDFromContext(ctx)
+
+	query := "INSERT INTO tag (id, name, created_at, updated_at, created_by) VALUES (?, ?, ?, ?, ?);"
+	if _, err := mySql.db.ExecContext(ctx, query, tag.ID, tag.Name, tag.CreatedAt, tag.UpdatedAt, tag.CreatedBy); err != nil {
+		if mysqlErr, ok := err.(*mysql.MySQLError); ok && mysqlErr.Number == 1062 {
+			return appErrors.ErrorResponse{
+				Code:    appErrors.ErrConflict,
+				Message: "A tag with this name already exists.",
+			}
+		}
+		logging.Logger.Errorf("[TraceID=%s] | failed to save tag in Storage.SaveTag() function | Error: %v", traceID, err)
+		return appErrors.ErrorResponse{
+			Code:    appErrors.ErrInternal,
+			Message: "Failed to save tag, try again later.",
+		}
+	}
+	return nil
+}
+
+const tagQuery = `SELECT g.id, g.name, g.created_at, g.updated_at, g.created_by,
+	(SELECT COUNT(*) FROM transaction_tag tt WHERE tt.tag_id = g.id) AS transaction_count
+	FROM tag g`
+
+func (mySql *MySQLStorage) GetTags(ctx context.Context, userId string) ([]budget.Tag, error) {
+	traceID := contextutil.TraceIDFromContext(ctx)
+
+	rows, err := mySql.db.QueryContext(ctx, tagQuery+" WHERE g.created_by = ? ORDER BY g.name;", userId)
+	if err != nil {
+		logging.Logger.Errorf("[TraceID=%s] | failed to get tags in Storage.GetTags() function | Error: %v", traceID, err)
+		return nil, appErrors.ErrorResponse{
+			Code:    appErrors.ErrInternal,
+			Message: "Failed to get tags, try again later.",
+		}
+	}
+	defer rows.Close()
+
+	tags := []budget.Tag{}
+	for rows.Next() {
+		var tag budget.Tag
+		if err := rows.Scan(&tag.ID, &tag.Name, &tag.CreatedAt, &tag.UpdatedAt, &tag.CreatedBy, &tag.TransactionCount); err != nil {
+			logging.Logger.Errorf("[TraceID=%s] | failed to scan tag in Storage.GetTags() function | Error: %v", traceID, err)
+			return nil, appErrors.ErrorResponse{
+				Code:    appErrors.ErrInternal,
+				Message: "Failed to get tags, try again later.",
+			}
+		}
+		tags = append(tags, tag)
+	}
+	if err := rows.Err(); err != nil {
+		logging.Logger.Errorf("[TraceID=%s] | failed to iterate tags in Storage.GetTags() function | Error: %v", traceID, err)
+		return nil, appErrors.ErrorResponse{
+			Code:    appErrors.ErrInternal,
+			Message: "Failed to get tags, try again later.",
+		}
+	}
+	return tags, nil
+}
+
+func (mySql *MySQLStorage) GetTagById(ctx context.Context, userId string, id string) (budget.Tag, error) {
+	traceID := contextutil.TraceIDFromContext(ctx)
+
+	var tag budget.Tag
+	row := mySql.db.QueryRowContext(ctx, tagQuery+" WHERE g.created_by = ? AND g.id = ?;", userId, id)
+	if err := row.Scan(&tag.ID, &tag.Name, &tag.CreatedAt, &tag.UpdatedAt, &tag.CreatedBy, &tag.TransactionCount); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return budget.Tag{}, appErrors.ErrorResponse{
+				Code:    appErrors.ErrNotFound,
+				Message: "Tag not found.",
+			}
+		}
+		logging.Logger.Errorf("[TraceID=%s] | failed to get tag in Storage.GetTagById() function | Error: %v", traceID, err)
+		return budget.Tag{}, appErrors.ErrorResponse{
+			Code:    appErrors.ErrInternal,
+			Message: "Failed to get tag, try again later.",
+		}
+	}
+	return tag, nil
+}
+
+func (mySql *MySQLStorage) UpdateTag(ctx context.Context, tag budget.Tag) error {
+	traceID := contextutil.TraceIDFromContext(ctx)
+
+	query := "UPDATE tag SET name = ?, updated_at = ? WHERE created_by = ? AND id = ?;"
+	if _, err := mySql.db.ExecContext(ctx, query, tag.Name, tag.UpdatedAt, tag.CreatedBy, tag.ID); err != nil {
+		if mysqlErr, ok := err.(*mysql.MySQLError); ok && mysqlErr.Number == 1062 {
+			return appErrors.ErrorResponse{
+				Code:    appErrors.ErrConflict,
+				Message: "A tag with this name already exists.",
+			}
+		}
+		logging.Logger.Errorf("[TraceID=%s] | failed to update tag in Storage.UpdateTag() function | Error: %v", traceID, err)
+		return appErrors.ErrorResponse{
+			Code:    appErrors.ErrInternal,
+			Message: "Failed to update tag, try again later.",
+		}
+	}
+	return nil
+}
+
+// DeleteTag also unlinks the tag from its transactions, through the foreign
+// key.
+func (mySql *MySQLStorage) DeleteTag(ctx context.Context, userId string, id string) error {
+	traceID := contextutil.TraceIDFromContext(ctx)
+
+	result, err := mySql.db.ExecContext(ctx, "DELETE FROM tag WHERE created_by = ? AND id = ?;", userId, id)
+	if err != nil {
+		logging.Logger.Errorf("[TraceID=%s] | failed to delete tag in Storage.DeleteTag() function | Error: %v", traceID, err)
+		return appErrors.ErrorResponse{
+			Code:    appErrors.ErrInternal,
+			Message: "Failed to delete tag, try again later.",
+		}
+	}
+	if n, err := result.RowsAffected(); err == nil && n == 0 {
+		return appErrors.ErrorResponse{
+			Code:    appErrors.ErrNotFound,
+			Message: "Tag not found.",
+		}
+	}
+	return nil
+}
+
+func (mySql *MySQLStorage) SetTransactionTags(ctx context.Context, userId string, transactionId string, tags []string) error {
+	traceID := contextutil.TraceIDFromContext(ctx)
+
+	fail := func(what string, err error) error {
+		logging.Logger.Errorf("[TraceID=%s] | failed to %s in Storage.SetTransactionTags() function | Error: %v", traceID, what, err)
+		return appErrors.ErrorResponse{
+			Code:    appErrors.ErrInternal,
+			Message: "Failed to update tags, try again later.",
+		}
+	}
+
+	tx, err := mySql.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fail("start SQL transaction", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, "DELETE FROM transaction_tag WHERE created_by = ? AND transaction_id = ?;", userId, transactionId); err != nil {
+		return fail("remove tags", err)
+	}
+	if err := insertTransactionTags(ctx, tx, userId, budget.Transaction{ID: transactionId, Tags: tags}); err != nil {
+		return fail("add tags", err)
+	}
+	if err := tx.Commit(); err != nil {
+		return fail("commit SQL transaction", err)
+	}
+	return nil
+}
+
+func (mySql *MySQLStorage) SavePayee(ctx context.Context, p budget.Payee) error {
+	traceID := contextutil.TraceIDFromContext(ctx)
+
+	fail := func(what string, err error) error {
+		logging.Logger.Errorf("[TraceID=%s] | failed to %s in Storage.SavePayee() function | Error: %v", traceID, what, err)
+		return appErrors.ErrorResponse{
+			Code:    appErrors.ErrInternal,
+			Message: "Failed to save payee, try again later.",
+		}
+	}
+
+	tx, err := mySql.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fail("start SQL transaction", err)
+	}
+	defer tx.Rollback()
+
+	query := "INSERT INTO payee (id, name, default_category_id, default_category_type, created_at, updated_at, created_by) VALUES (?, ?, ?, ?, ?, ?, ?);"
+	if _, err := tx.ExecContext(ctx, query, p.ID, p.Name, emptyToNull(p.DefaultCategoryId), emptyToNull(p.DefaultCategoryType), p.CreatedAt, p.UpdatedAt, p.CreatedBy); err != nil {
+		if mysqlErr, ok := err.(*mysql.MySQLError); ok && mysqlErr.Number == 1062 {
+			return appErrors.ErrorResponse{
+				Code:    appErrors.ErrConflict,
+				Message: "A payee with this name already exists.",
+			}
+		}
+		return fail("save payee", err)
+	}
+	if err := insertPayeeAliases(ctx, tx, p); err != nil {
+		return fail("save aliases", err)
+	}
+	if err := tx.Commit(); err != nil {
+		return fail("commit SQL transaction", err)
+	}
+	return nil
+}
+
+func insertPayeeAliases(ctx context.Context, tx *sql.Tx, p budget.Payee) error {
+	query := "INSERT INTO payee_alias (payee_id, position, pattern, match_type, created_by) VALUES (?, ?, ?, ?, ?);"
+	for i, alias := range p.Aliases {
+		if _, err := tx.ExecContext(ctx, query, p.ID, i, alias.Pattern, alias.Match, p.CreatedBy); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+const payeeQuery = `SELECT p.id, p.name, IFNULL(p.default_category_id, ''), IFNULL(p.default_category_type, ''), p.created_at, p.updated_at, p.created_by,
+	(SELECT COUNT(*) FROM transaction t WHERE t.payee_id = p.id) AS transaction_count
+	FROM payee p`
+
+func (mySql *MySQLStorage) GetPayees(ctx context.Context, userId string) ([]budget.Payee, error) {
+	return mySql.getPayees(ctx, "GetPayees", payeeQuery+" WHERE p.created_by = ? ORDER BY p.name;", userId)
+}
+
+func (mySql *MySQLStorage) GetPayeeById(ctx context.Context, userId string, id string) (budget.Payee, error) {
+	payees, err := mySql.getPayees(ctx, "GetPayeeById", payeeQuery+" WHERE p.created_by = ? AND p.id = ?;", userId, id)
+	if err != nil {
+		return budget.Payee{}, err
+	}
+	if len(payees) == 0 {
+		return budget.Payee{}, appErrors.ErrorResponse{
+			Code:    appErrors.ErrNotFound,
+			Message: "Payee not found.",
+		}
+	}
+	return payees[0], nil
+}
+
+// getPayees reads the payees of a query along with their aliases. args
+// starts with the user id.
+func (mySql *MySQLStorage) getPayees(ctx context.Context, caller string, query string, args ...interface{}) ([]budget.Payee, error) {
+	traceID := contextutil.TraceIDFromContext(ctx)
+
+	fail := func(what string, err error) error {
+		logging.Logger.Errorf("[TraceID=%s] | failed to %s in Storage.%s() function | Error: %v", traceID, what, caller, err)
+		return appErrors.ErrorResponse{
+			Code:    appErrors.ErrInternal,
+			Message: "Failed to get payees, try again later.",
+		}
+	}
+
+	rows, err := mySql.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fail("get payees", err)
+	}
+	defer rows.Close()
+
+	payees := []budget.Payee{}
+	index := map[string]int{}
+	for rows.Next() {
+		var p budget.Payee
+		if err := rows.Scan(&p.ID, &p.Name, &p.DefaultCategoryId, &p.DefaultCategoryType, &p.CreatedAt, &p.UpdatedAt, &p.CreatedBy, &p.TransactionCount); err != nil {
+			return nil, fail("scan payee", err)
+		}
+		index[p.ID] = len(payees)
+		payees = append(payees, p)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fail("iterate payees", err)
+	}
+	if len(payees) == 0 {
+		return payees, nil
+	}
+
+	aliasRows, err := mySql.db.QueryContext(ctx, "SELECT payee_id, pattern, match_type FROM payee_alias WHERE created_by = ? ORDER BY payee_id, position;", args[0])
+	if err != nil {
+		return nil, fail("get aliases", err)
+	}
+	defer aliasRows.Close()
+
+	for aliasRows.Next() {
+		var payeeId string
+		var alias budget.PayeeAlias
+		if err := aliasRows.Scan(&payeeId, &alias.Pattern, &alias.Match); err != nil {
+			return nil, fail("scan alias", err)
+		}
+		if i, ok := index[payeeId]; ok {
+			payees[i].Aliases = append(payees[i].Aliases, alias)
+		}
+	}
+	if err := aliasRows.Err(); err != nil {
+		return nil, fail("iterate aliases", err)
+	}
+	return payees, nil
+}
+
+func (mySql *MySQLStorage) UpdatePayee(ctx context.Context, p budget.Payee) error {
+	traceID := contextutil.TraceIDFromContext(ctx)
+
+	fail := func(what string, err error) error {
+		logging.Logger.Errorf("[TraceID=%s] | failed to %s in Storage.UpdatePayee() function | Error: %v", traceID, what, err)
+		return appErrors.ErrorResponse{
+			Code:    appErrors.ErrInternal,
+			Message: "Failed to update payee, try again later.",
+		}
+	}
+
+	tx, err := mySql.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fail("start SQL transaction", err)
+	}
+	defer tx.Rollback()
+
+	query := "UPDATE payee SET name = ?, default_category_id = ?, default_category_type = ?, updated_at = ? WHERE created_by = ? AND id = ?;"
+	if _, err := tx.ExecContext(ctx, query, p.Name, emptyToNull(p.DefaultCategoryId), emptyToNull(p.DefaultCategoryType), p.UpdatedAt, p.CreatedBy, p.ID); err != nil {
+		if mysqlErr, ok := err.(*mysql.MySQLError); ok && mysqlErr.Number == 1062 {
+			return appErrors.ErrorResponse{
+				Code:    appErrors.ErrConflict,
+				Message: "A payee with this name already exists.",
+			}
+		}
+		return fail("update payee", err)
+	}
+	if _, err := tx.ExecContext(ctx, "DELETE FROM payee_alias WHERE created_by = ? AND payee_id = ?;", p.CreatedBy, p.ID); err != nil {
+		return fail("remove aliases", err)
+	}
+	if err := insertPayeeAliases(ctx, tx, p); err != nil {
+		return fail("add aliases", err)
+	}
+	if err := tx.Commit(); err != nil {
+		return fail("commit SQL transaction", err)
+	}
+	return nil
+}
+
+// DeletePayee also unlinks the payee from its transactions and deletes its
+// aliases, through the foreign keys.
+func (mySql *MySQLStorage) DeletePayee(ctx context.Context, userId string, id string) error {
+	traceID := contextutil.TraceIDFromContext(ctx)
+
+	result, err := mySql.db.ExecContext(ctx, "DELETE FROM payee WHERE created_by = ? AND id = ?;", userId, id)
+	if err != nil {
+		logging.Logger.Errorf("[TraceID=%s] | failed to delete payee in Storage.DeletePayee() function | Error: %v", traceID, err)
+		return appErrors.ErrorResponse{
+			Code:    appErrors.ErrInternal,
+			Message: "Failed to delete payee, try again later.",
+		}
+	}
+	if n, err := result.RowsAffected(); err == nil && n == 0 {
+		return appErrors.ErrorResponse{
+			Code:    appErrors.ErrNotFound,
+			Message: "Payee not found.",
+		}
+	}
+	return nil
+}
+
+func (mySql *MySQLStorage) SetTransactionPayee(ctx context.Context, userId string, transactionIds []string, payeeId string) error {
+	traceID := contextutil.TraceIDFromContext(ctx)
+
+	placeholders := "?" + strings.Repeat(",?", len(transactionIds)-1)
+	query := "UPDATE transaction SET payee_id = ? WHERE created_by = ? AND id IN (" + placeholders + ");"
+	args := []interface{}{emptyToNull(payeeId), userId}
+	for _, id := range transactionIds {
+		args = append(args, id)
+	}
+	if _, err := mySql.db.ExecContext(ctx, query, args...); err != nil {
+		logging.Logger.Errorf("[TraceID=%s] | failed to set payee in Storage.SetTransactionPayee() function | Error: %v", traceID, err)
+		return appErrors.ErrorResponse{
+			Code:    appErrors.ErrInternal,
+			Message: "Failed to update payee of transactions, try again later.",
+		}
+	}
+	return nil
+}
+
+// ruleWeekdays packs weekdays into a bitmask, bit 0 is Sunday.
+func ruleWeekdays(days []time.Weekday) int {
+	mask := 0
+	for _, d := range days {
+		mask |= 1 << uint(d)
+	}
+	return mask
+}
+
+func ruleWeekdaysFromMask(mask int) []time.Weekday {
+	var days []time.Weekday
+	for d := time.Sunday; d <= time.Saturday; d++ {
+		if mask&(1<<uint(d)) != 0 {
+			days = append(days, d)
+		}
+	}
+	return days
+}
+
+func zeroToNull(v float64) sql.NullFloat64 {
+	return sql.NullFloat64{Float64: v, Valid: v != 0}
+}
+
+// ruleArgs are the columns of a rule after its id, in the order of the
+// INSERT and UPDATE queries.
+func ruleArgs(r budget.Rule) []interface{} {
+	c, a := r.Conditions, r.Actions
+	return []interface{}{
+		r.Name, r.Priority, r.Enabled, emptyToNull(c.CategoryType), c.NotePattern, c.PayeePattern,
+		zeroToNull(c.AmountMin), zeroToNull(c.AmountMax), c.Currency, ruleWeekdays(c.Weekdays),
+		emptyToNull(a.CategoryId), a.Note, strings.Join(a.Tags, ","), r.UpdatedAt,
+	}
+}
+
+func (mySql *MySQLStorage) SaveRule(ctx context.Context, r budget.Rule) error {
+	traceID := contextutil.TraceIDFromContext(ctx)
+
+	query := `INSERT INTO transaction_rule (name, priority, enabled, category_type, note_pattern, payee_pattern, amount_min, amount_max, currency, weekdays,
+		set_category_id, set_note, set_tags, updated_at, id, created_at, created_by) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?);`
+	args := append(ruleArgs(r), r.ID, r.CreatedAt, r.CreatedBy)
+	if _, err := mySql.db.ExecContext(ctx, query, args...); err != nil {
+		logging.Logger.Errorf("[TraceID=%s] | failed to save rule in Storage.SaveRule() function | Error: %v", traceID, err)
+		return appErrors.ErrorResponse{
+			Code:    appErrors.ErrInternal,
+			Message: "Failed to save rule, try again later.",
+		}
+	}
+	return nil
+}
+
+const ruleQuery = `SELECT id, name, priority, enabled, IFNULL(category_type, ''), note_pattern, payee_pattern, IFNULL(amount_min, 0), IFNULL(amount_max, 0),
+	currency, weekdays, IFNULL(set_category_id, ''), set_note, set_tags, created_at, updated_at, created_by
+	FROM transaction_rule`
+
+func (mySql *MySQLStorage) GetRules(ctx context.Context, userId string) ([]budget.Rule, error) {
+	return mySql.getRules(ctx, "GetRules", ruleQuery+" WHERE created_by = ? ORDER BY priority, created_at;", userId)
+}
+
+func (mySql *MySQLStorage) GetRuleById(ctx context.Context, userId string, id string) (budget.Rule, error) {
+	rules, err := mySql.getRules(ctx, "GetRuleById", ruleQuery+" WHERE created_by = ? AND id = ?;", userId, id)
+	if err != nil {
+		return budget.Rule{}, err
+	}
+	if len(rules) == 0 {
+		return budget.Rule{}, appErrors.ErrorResponse{
+			Code:    appErrors.ErrNotFound,
+			Message: "Rule not found.",
+		}
+	}
+	return rules[0], nil
+}
+
+func (mySql *MySQLStorage) getRules(ctx context.Context, caller string, query string, args ...interface{}) ([]budget.Rule, error) {
+	traceID := contextutil.TraceIDFromContext(ctx)
+
+	fail := func(what string, err error) error {
+		logging.Logger.Errorf("[TraceID=%s] | failed to %s in Storage.%s() function | Error: %v", traceID, what, caller, err)
+		return appErrors.ErrorResponse{
+			Code:    appErrors.ErrInternal,
+			Message: "Failed to get rules, try again later.",
+		}
+	}
+
+	rows, err := mySql.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fail("get rules", err)
+	}
+	defer rows.Close()
+
+	rules := []budget.Rule{}
+	for rows.Next() {
+		var r budget.Rule
+		var weekdays int
+		var tags string
+		c, a := &r.Conditions, &r.Actions
+		if err := rows.Scan(&r.ID, &r.Name, &r.Priority, &r.Enabled, &c.CategoryType, &c.NotePattern, &c.PayeePattern, &c.AmountMin, &c.AmountMax,
+			&c.Currency, &weekdays, &a.CategoryId, &a.Note, &tags, &r.CreatedAt, &r.UpdatedAt, &r.CreatedBy); err != nil {
+			return nil, fail("scan rule", err)
+		}
+		c.Weekdays = ruleWeekdaysFromMask(weekdays)
+		if tags != "" {
+			a.Tags = strings.Split(tags, ",")
+		}
+		rules = append(rules, r)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fail("iterate rules", err)
+	}
+	return rules, nil
+}
+
+func (mySql *MySQLStorage) UpdateRule(ctx context.Context, r budget.Rule) error {
+	traceID := contextutil.TraceIDFromContext(ctx)
+
+	query := `UPDATE transaction_rule SET name = ?, priority = ?, enabled = ?, category_type = ?, note_pattern = ?, payee_pattern = ?, amount_min = ?, amount_max = ?,
+		currency = ?, weekdays = ?, set_category_id = ?, set_note = ?, set_tags = ?, updated_at = ? WHERE id = ? AND created_by = ?;`
+	args := append(ruleArgs(r), r.ID, r.CreatedBy)
+	if _, err := mySql.db.ExecContext(ctx, query, args...); err != nil {
+		logging.Logger.Errorf("[TraceID=%s] | failed to update rule in Storage.UpdateRule() function | Error: %v", traceID, err)
+		return appErrors.ErrorResponse{
+			Code:    appErrors.ErrInternal,
+			Message: "Failed to update rule, try again later.",
+		}
+	}
+	return nil
+}
+
+func (mySql *MySQLStorage) DeleteRule(ctx context.Context, userId string, id string) error {
+	traceID := contextutil.TraceIDFromContext(ctx)
+
+	result, err := mySql.db.ExecContext(ctx, "DELETE FROM transaction_rule WHERE created_by = ? AND id = ?;", userId, id)
+	if err != nil {
+		logging.Logger.Errorf("[TraceID=%s] | failed to delete rule in Storage.DeleteRule() function | Error: %v", traceID, err)
+		return appErrors.ErrorResponse{
+			Code:    appErrors.ErrInternal,
+			Message: "Failed to delete rule, try again later.",
+		}
+	}
+	if n, err := result.RowsAffected(); err == nil && n == 0 {
+		return appErrors.ErrorResponse{
+			Code:    appErrors.ErrNotFound,
+			Message: "Rule not found.",
+		}
+	}
+	return nil
+}
+
+// SaveRuleChanges leaves transactions reconciled in the meantime as they are.
+func (mySql *MySQLStorage) SaveRuleChanges(ctx context.Context, userId string, tags []budget.Tag, changes []budget.RuleChange) error {
+	traceID := contextutil.TraceIDFromContext(ctx)
+
+	fail := func(what string, err error) error {
+		logging.Logger.Errorf("[TraceID=%s] | failed to %s in Storage.SaveRuleChanges() function | Error: %v", traceID, what, err)
+		return appErrors.ErrorResponse{
+			Code:    appErrors.ErrInternal,
+			Message: "Failed to apply rules, try again later.",
+		}
+	}
+
+	tx, err := mySql.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fail("start SQL transaction", err)
+	}
+	defer tx.Rollback()
+
+	tagQuery := "INSERT INTO tag (id, name, created_at, updated_at, created_by) VALUES (?, ?, ?, ?, ?);"
+	for _, tag := range tags {
+		if _, err := tx.ExecContext(ctx, tagQuery, tag.ID, tag.Name, tag.CreatedAt, tag.UpdatedAt, userId); err != nil {
+			return fail("save tag", err)
+		}
+	}
+
+	query := "UPDATE transaction SET category_id = ?, note = ? WHERE created_by = ? AND id = ? AND reconciled_at IS NULL;"
+	for _, c := range changes {
+		result, err := tx.ExecContext(ctx, query, emptyToNull(c.CategoryId), c.Note, userId, c.TransactionId)
+		if err != nil {
+			return fail("update transaction", err)
+		}
+		// the row is not affected when nothing but the tags changed, so only
+		// skip the tags of transactions that are gone or locked
+		if n, err := result.RowsAffected(); err == nil && n == 0 && (c.CategoryId != c.OldCategoryId || c.Note != c.OldNote) {
+			continue
+		}
+		if err := insertTransactionTags(ctx, tx, userId, budget.Transaction{ID: c.TransactionId, Tags: c.AddedTags}); err != nil {
+			return fail("add tags", err)
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return fail("commit SQL transaction", err)
+	}
+	return nil
+}
+
+func (mySql *MySQLStorage) GetCategoryParents(ctx context.Context, userId string, categoryType string) (map[string]string, error) {
+	traceID := contextutil.TraceIDFromContext(ctx)
+
+	var query string
+	switch categoryType {
+	case "-":
+		query = "SELECT id, IFNULL(parent_id, '') FROM expense_category WHERE created_by = ?;"
+	case "+":
+		query = "SELECT id, IFNULL(parent_id, '') FROM income_category WHERE created_by = ?;"
+	default:
+		return nil, appErrors.ErrorResponse{
+			Code:    appErrors.ErrInvalidInput,
+			Message: "Invalid category type.",
+		}
+	}
+
+	rows, err := mySql.db.QueryContext(ctx, query, userId)
+	if err != nil {
+		logging.Logger.Errorf("[TraceID=%s] | failed to get category parents in Storage.GetCategoryParents() function | Error: %v", traceID, err)
+		return nil, appErrors.ErrorResponse{
+			Code:    appErrors.ErrInternal,
+			Message: "Failed to get categories, try again later.",
+		}
+	}
+	defer rows.Close()
+
+	parents := map[string]string{}
+	for rows.Next() {
+		var id, parentId string
+		if err := rows.Scan(&id, &parentId); err != nil {
+			logging.Logger.Errorf("[TraceID=%s] | failed to scan category parent in Storage.GetCategoryParents() function | Error: %v", traceID, err)
+			return nil, appErrors.ErrorResponse{
+				Code:    appErrors.ErrInternal,
+				Message: "Failed to get categories, try again later.",
+			}
+		}
+		parents[id] = parentId
+	}
+	if err := rows.Err(); err != nil {
+		logging.Logger.Errorf("[TraceID=%s] | failed to iterate category parents in Storage.GetCategoryParents() function | Error: %v", traceID, err)
+		return nil, appErrors.ErrorResponse{
+			Code:    appErrors.ErrInternal,
+			Message: "Failed to get categories, try again later.",
+		}
+	}
+	return parents, nil
+}
+
+func (mySql *MySQLStorage) SetCategoryParent(ctx context.Context, userId string, categoryType string, id string, parentId string) error {
+	traceID := contextutil.TraceIDFromContext(ctx)
+
+	var query string
+	switch categoryType {
+	case "-":
+		query = "UPDATE expense_category SET parent_id = ?, updated_at = ? WHERE created_by = ? AND id = ?;"
+	case "+":
+		query = "UPDATE income_category SET parent_id = ?, updated_at = ? WHERE created_by = ? AND id = ?;"
+	default:
+		return appErrors.ErrorResponse{
+			Code:    appErrors.ErrInvalidInput,
+			Message: "Invalid category type.",
+		}
+	}
+
+	if _, err := mySql.db.ExecContext(ctx, query, emptyToNull(parentId), time.Now().UTC(), userId, id); err != nil {
+		logging.Logger.Errorf("[TraceID=%s] | failed to move category in Storage.SetCategoryParent() function | Error: %v", traceID, err)
+		return appErrors.ErrorResponse{
+			Code:    appErrors.ErrInternal,
+			Message: "Failed to move the category, try again later.",
+		}
+	}
+	return nil
+}
+
+func (mySql *MySQLStorage) SetCategoryArchived(ctx context.Context, userId string, categoryType string, id string, archivedAt time.Time) error {
+	traceID := contextutil.TraceIDFromContext(ctx)
+
+	var query string
+	switch categoryType {
+	case "-":
+		query = "UPDATE expense_category SET archived_at = ?, updated_at = ? WHERE created_by = ? AND id = ?;"
+	case "+":
+		query = "UPDATE income_category SET archived_at = ?, updated_at = ? WHERE created_by = ? AND id = ?;"
+	default:
+		return appErrors.ErrorResponse{
+			Code:    appErrors.ErrInvalidInput,
+			Message: "Invalid category type.",
+		}
+	}
+
+	if _, err := mySql.db.ExecContext(ctx, query, nullTime(archivedAt), time.Now().UTC(), userId, id); err != nil {
+		logging.Logger.Errorf("[TraceID=%s] | failed to archive category in Storage.SetCategoryArchived() function | Error: %v", traceID, err)
+		return appErrors.ErrorResponse{
+			Code:    appErrors.ErrInternal,
+			Message: "Failed to archive the category, try again later.",
+		}
+	}
+	return nil
+}
+
+func (mySql *MySQLStorage) MergeCategories(ctx context.Context, merge budget.CategoryMerge) (int, error) {
+	traceID := contextutil.TraceIDFromContext(ctx)
+
+	fail := func(what string, err error) (int, error) {
+		logging.Logger.Errorf("[TraceID=%s] | failed to %s in Storage.MergeCategories() function | Error: %v", traceID, what, err)
+		return 0, appErrors.ErrorResponse{
+			Code:    appErrors.ErrInternal,
+			Message: "Failed to merge the categories, try again later.",
+		}
+	}
+
+	var table, limitColumn string
+	switch merge.CategoryType {
+	case "-":
+		table, limitColumn = "expense_category", "max_amount"
+	case "+":
+		table, limitColumn = "income_category", "target_amount"
+	default:
+		return 0, appErrors.ErrorResponse{
+			Code:    appErrors.ErrInvalidInput,
+			Message: "Invalid category type.",
+		}
+	}
+
+	sourceIds := make([]interface{}, 0, len(merge.Sources))
+	for _, source := range merge.Sources {
+		sourceIds = append(sourceIds, source.ID)
+	}
+	placeholders := "?" + strings.Repeat(",?", len(sourceIds)-1)
+
+	tx, err := mySql.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fail("start SQL transaction", err)
+	}
+	defer tx.Rollback()
+
+	var moved int
+	countQuery := `SELECT COUNT(*) FROM transaction t WHERE t.created_by = ? AND t.category_type = ?
+		AND (t.category_id IN (` + placeholders + `) OR EXISTS (SELECT 1 FROM transaction_split s WHERE s.transaction_id = t.id AND s.category_id IN (` + placeholders + `)));`
+	countArgs := append([]interface{}{merge.CreatedBy, merge.CategoryType}, sourceIds...)
+	if err := tx.QueryRowContext(ctx, countQuery, append(countArgs, sourceIds...)...).Scan(&moved); err != nil {
+		return fail("count transactions", err)
+	}
+
+	for _, source := range merge.Sources {
+		if err := moveCategoryTransactions(ctx, tx, merge.CreatedBy, source.ID, merge.TargetId, merge.CategoryType); err != nil {
+			return fail("move transactions", err)
+		}
+	}
+
+	childrenQuery := "UPDATE " + table + " SET parent_id = ? WHERE created_by = ? AND parent_id IN (" + placeholders + ");"
+	if _, err := tx.ExecContext(ctx, childrenQuery, append([]interface{}{merge.TargetId, merge.CreatedBy}, sourceIds...)...); err != nil {
+		return fail("move subcategories", err)
+	}
+
+	targetQuery := "UPDATE " + table + " SET " + limitColumn + " = ?, note = ?, updated_at = ? WHERE created_by = ? AND id = ?;"
+	result, err := tx.ExecContext(ctx, targetQuery, merge.NewLimit, merge.NewNote, merge.CreatedAt, merge.CreatedBy, merge.TargetId)
+	if err != nil {
+		return fail("update target category", err)
+	}
+	if n, err := result.RowsAffected(); err == nil && n == 0 {
+		return 0, appErrors.ErrorResponse{
+			Code:    appErrors.ErrNotFound,
+			Message: "The target category does not exist.",
+		}
+	}
+
+	deleteQuery := "DELETE FROM " + table + " WHERE created_by = ? AND id IN (" + placeholders + ");"
+	result, err = tx.ExecContext(ctx, deleteQuery, append([]interface{}{merge.CreatedBy}, sourceIds...)...)
+	if err != nil {
+		return fail("delete source categories", err)
+	}
+	if n, err := result.RowsAffected(); err == nil && int(n) != len(sourceIds) {
+		return 0, appErrors.ErrorResponse{
+			Code:    appErrors.ErrConflict,
+			Message: "A source category was changed while merging, try again.",
+		}
+	}
+
+	mergeQuery := `INSERT INTO category_merge (id, category_type, target_id, target_name, limit_strategy, note_strategy, old_limit, new_limit, old_note, new_note, moved_transactions, created_at, created_by)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?);`
+	if _, err := tx.ExecContext(ctx, mergeQuery, merge.ID, merge.CategoryType, merge.TargetId, merge.TargetName, merge.LimitStrategy, merge.NoteStrategy,
+		merge.OldLimit, merge.NewLimit, merge.OldNote, merge.NewNote, moved, merge.CreatedAt, merge.CreatedBy); err != nil {
+		return fail("save merge", err)
+	}
+	sourceQuery := "INSERT INTO category_merge_source (merge_id, category_id, name, limit_amount, note, position) VALUES (?, ?, ?, ?, ?, ?);"
+	for i, source := range merge.Sources {
+		if _, err := tx.ExecContext(ctx, sourceQuery, merge.ID, source.ID, source.Name, source.Limit, source.Note, i); err != nil {
+			return fail("save merged category", err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fail("commit SQL transaction", err)
+	}
+	return moved, nil
+}
+
+func (mySql *MySQLStorage) GetCategoryMerges(ctx context.Context, userId string, categoryType string) ([]budget.CategoryMerge, error) {
+	traceID := contextutil.TraceIDFromContext(ctx)
+
+	fail := func(what string, err error) ([]budget.CategoryMerge, error) {
+		logging.Logger.Errorf("[TraceID=%s] | failed to %s in Storage.GetCategoryMerges() function | Error: %v", traceID, what, err)
+		return nil, appErrors.ErrorResponse{
+			Code:    appErrors.ErrInternal,
+			Message: "Failed to get category merges, try again later.",
+		}
+	}
+
+	query := `SELECT id, category_type, target_id, target_name, limit_strategy, note_strategy, old_limit, new_limit, old_note, new_note, moved_transactions, created_at, created_by
+		FROM category_merge WHERE created_by = ? AND category_type = ? ORDER BY created_at DESC;`
+	rows, err := mySql.db.QueryContext(ctx, query, userId, categoryType)
+	if err != nil {
+		return fail("get merges", err)
+	}
+	defer rows.Close()
+
+	merges := []budget.CategoryMerge{}
+	position := map[string]int{}
+	for rows.Next() {
+		var m budget.CategoryMerge
+		if err := rows.Scan(&m.ID, &m.CategoryType, &m.TargetId, &m.TargetName, &m.LimitStrategy, &m.NoteStrategy, &m.OldLimit, &m.NewLimit,
+			&m.OldNote, &m.NewNote, &m.MovedTransactions, &m.CreatedAt, &m.CreatedBy); err != nil {
+			return fail("scan merge", err)
+		}
+		position[m.ID] = len(merges)
+		merges = append(merges, m)
+	}
+	if err := rows.Err(); err != nil {
+		return fail("iterate merges", err)
+	}
+
+	sourceQuery := `SELECT s.merge_id, s.category_id, s.name, s.limit_amount, s.note FROM category_merge_source s
+		JOIN category_merge m ON m.id = s.merge_id WHERE m.created_by = ? AND m.category_type = ? ORDER BY s.position;`
+	sourceRows, err := mySql.db.QueryContext(ctx, sourceQuery, userId, categoryType)
+	if err != nil {
+		return fail("get merged categories", err)
+	}
+	defer sourceRows.Close()
+
+	for sourceRows.Next() {
+		var mergeId string
+		var source budget.MergedCategory
+		if err := sourceRows.Scan(&mergeId, &source.ID, &source.Name, &source.Limit, &source.Note); err != nil {
+			return fail("scan merged category", err)
+		}
+		if i, ok := position[mergeId]; ok {
+			merges[i].Sources = append(merges[i].Sources, source)
+		}
+	}
+	if err := sourceRows.Err(); err != nil {
+		return fail("iterate merged categories", err)
+	}
+	return merges, nil
 }
@@ -1,24 +1,34 @@
 package main
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"net/http"
 	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
 
 	"github.com/0xcafe-io/iz"
 	"github.com/fatali-fataliyev/budget_tracker/api"
+	"github.com/fatali-fataliyev/budget_tracker/internal/auth"
 	"github.com/fatali-fataliyev/budget_tracker/internal/budget"
+	"github.com/fatali-fataliyev/budget_tracker/internal/mail"
 	"github.com/fatali-fataliyev/budget_tracker/internal/storage"
 	"github.com/fatali-fataliyev/budget_tracker/logging"
 	"github.com/rs/cors"
 )
 
+const SHUTDOWN_TIMEOUT = 15 * time.Second
+
 func main() {
 
 	// CORS POLICY
 	var corsConf = cors.New(cors.Options{
 		AllowedOrigins:   []string{"*"},
-		AllowedMethods:   []string{"GET", "POST", "PUT", "DELETE", "OPTIONS"},
+		AllowedMethods:   []string{"GET", "POST", "PUT", "PATCH", "DELETE", "OPTIONS"},
 		AllowedHeaders:   []string{"Authorization", "Content-Type"},
 		AllowCredentials: true,
 	})
@@ -33,6 +43,18 @@ func main() {
 
 	logging.Logger.Info("application starting...")
 
+	// Password policy
+	if err := auth.InitPasswordPolicy(); err != nil {
+		logging.Logger.Errorf("failed to initialize password policy: %v", err)
+		return
+	}
+
+	// Account deletion
+	if err := budget.InitDeletionGracePeriod(); err != nil {
+		logging.Logger.Errorf("failed to initialize account deletion grace period: %v", err)
+		return
+	}
+
 	// Storage
 	db, err := storage.Init()
 	if err != nil {
@@ -46,25 +68,130 @@ func main() {
 		return
 	}
 
-	bt = budget.NewBudgetTracker(storageInstance)
+	// Mail
+	mailer, err := mail.Init()
+	if err != nil {
+		logging.Logger.Errorf("failed to initialize mailer: %v", err)
+		return
+	}
+
+	bt = budget.NewBudgetTracker(storageInstance, mailer)
+
+	// Background jobs run until SIGINT or SIGTERM, shutdown waits for the
+	// current run to finish.
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	var jobs sync.WaitGroup
+	jobs.Add(2)
+	go func() {
+		defer jobs.Done()
+		bt.RunDeletionPurger(ctx, time.Hour)
+	}()
+	go func() {
+		defer jobs.Done()
+		bt.RunRecurringScheduler(ctx, budget.RECURRING_SCHEDULER_TICK)
+	}()
 
 	server := http.NewServeMux()
 	api := api.NewApi(&bt)
 
 	// USER ENDPOINTS.
-	server.HandleFunc("POST /api/register", iz.Bind(api.SaveUserHandler))                         // Create User [OPEN]
-	server.HandleFunc("POST /api/login", iz.Bind(api.LoginUserHandler))                           // Login User  [OPEN]
-	server.Handle("GET /api/logout", iz.Bind(api.LogoutUserHandler))                              // Logout User [PROTECTED]
-	server.Handle("POST /api/remove-account", api.AuthMiddleware(iz.Bind(api.DeleteUserHandler))) // Remove User [PROTECTED]
-	server.HandleFunc("GET /api/download-user-data", api.DownloadUserData)                        // Download Data [PROTECTED]
-	server.Handle("GET /api/check-token", api.AuthMiddleware(iz.Bind(api.CheckToken)))            // Check User Token [PROTECTED]
-	server.Handle("GET /api/account", api.AuthMiddleware(iz.Bind(api.GetAccountInfo)))            // Account Info     [PROTECTED]
+	server.HandleFunc("POST /api/register", iz.Bind(api.SaveUserHandler))                                  // Create User [OPEN]
+	server.HandleFunc("POST /api/login", iz.Bind(api.LoginUserHandler))                                    // Login User  [OPEN]
+	server.Handle("GET /api/logout", iz.Bind(api.LogoutUserHandler))                                       // Logout User [PROTECTED]
+	server.HandleFunc("POST /api/restore-account", iz.Bind(api.RestoreUserHandler))                        // Restore User [OPEN]
+	server.HandleFunc("POST /api/password/forgot", iz.Bind(api.ForgotPasswordHandler))                     // Request Password Reset [OPEN]
+	server.HandleFunc("POST /api/password/reset", iz.Bind(api.ResetPasswordHandler))                       // Reset Password [OPEN]
+	server.Handle("POST /api/remove-account", api.AuthMiddleware(iz.Bind(api.DeleteUserHandler)))          // Remove User [PROTECTED]
+	server.HandleFunc("GET /api/download-user-data", api.DownloadUserData)                                 // Download Data [PROTECTED]
+	server.Handle("POST /api/import-user-data", api.AuthMiddleware(iz.Bind(api.ImportUserDataHandler)))    // Import Data [PROTECTED]
+	server.Handle("GET /api/export/journal", api.AuthMiddleware(iz.Bind(api.ExportJournalHandler)))        // Export beancount or ledger journal [PROTECTED]
+	server.Handle("GET /api/check-token", api.AuthMiddleware(iz.Bind(api.CheckToken)))                     // Check User Token [PROTECTED]
+	server.Handle("GET /api/account", api.AuthMiddleware(iz.Bind(api.GetAccountInfo)))                     // Account Info     [PROTECTED]
+	server.Handle("PATCH /api/account", api.AuthMiddleware(iz.Bind(api.UpdateAccountHandler)))             // Update Account [PROTECTED]
+	server.Handle("POST /api/account/confirm-email", api.AuthMiddleware(iz.Bind(api.ConfirmEmailHandler))) // Confirm Email Change [PROTECTED]
+	server.Handle("PUT /api/account/password", api.AuthMiddleware(iz.Bind(api.ChangePasswordHandler)))     // Change Password [PROTECTED]
 
 	// TRANSACTION ENDPOINTS.
-	server.Handle("POST /api/transaction", api.AuthMiddleware(iz.Bind(api.SaveTransactionHandler)))        // Create Transaction         [PROTECTED]
-	server.Handle("GET /api/transaction", api.AuthMiddleware(iz.Bind(api.GetFilteredTransactionsHandler))) // Get Transactions by filter [PROTECTED]
-	server.Handle("GET /api/transaction/{id}", api.AuthMiddleware(iz.Bind(api.GetTransactionByIdHandler))) // Get Transation by ID       [PROTECTED]
-	server.Handle("POST /api/image-process", api.AuthMiddleware(iz.Bind(api.ProcessImageHandler)))         // Image to Transaction       [PROTECTED]
+	server.Handle("POST /api/transaction", api.AuthMiddleware(iz.Bind(api.SaveTransactionHandler)))                 // Create Transaction         [PROTECTED]
+	server.Handle("GET /api/transaction", api.AuthMiddleware(iz.Bind(api.GetFilteredTransactionsHandler)))          // Get Transactions by filter [PROTECTED]
+	server.Handle("POST /api/transaction/suggest", api.AuthMiddleware(iz.Bind(api.SuggestCategoriesHandler)))       // Suggest Categories [PROTECTED]
+	server.Handle("GET /api/transaction/export", api.AuthMiddleware(iz.Bind(api.ExportTransactionsHandler)))        // Export Transactions as CSV or XLSX [PROTECTED]
+	server.Handle("GET /api/transaction/{id}", api.AuthMiddleware(iz.Bind(api.GetTransactionByIdHandler)))          // Get Transation by ID       [PROTECTED]
+	server.Handle("POST /api/image-process", api.AuthMiddleware(iz.Bind(api.ProcessImageHandler)))                  // Image to Transaction       [PROTECTED]
+	server.Handle("POST /api/transaction/import/csv", api.AuthMiddleware(iz.Bind(api.ImportCSVStatementHandler)))   // Import CSV Statement [PROTECTED]
+	server.Handle("POST /api/transaction/import/{format}", api.AuthMiddleware(iz.Bind(api.ImportStatementHandler))) // Import OFX, QFX, QIF, camt.053 or MT940 Statement [PROTECTED]
+
+	// RECURRING TRANSACTION ENDPOINTS.
+	server.Handle("POST /api/recurring", api.AuthMiddleware(iz.Bind(api.SaveRecurringTransactionHandler)))                 // Create Recurring Transaction [PROTECTED]
+	server.Handle("GET /api/recurring", api.AuthMiddleware(iz.Bind(api.GetRecurringTransactionsHandler)))                  // List Recurring Transactions [PROTECTED]
+	server.Handle("GET /api/recurring/upcoming", api.AuthMiddleware(iz.Bind(api.GetUpcomingTransactionsHandler)))          // Upcoming Transactions [PROTECTED]
+	server.Handle("POST /api/recurring/{id}/{action}", api.AuthMiddleware(iz.Bind(api.RecurringTransactionActionHandler))) // Pause, Resume or Skip [PROTECTED]
+	server.Handle("DELETE /api/recurring/{id}", api.AuthMiddleware(iz.Bind(api.DeleteRecurringTransactionHandler)))        // Delete Recurring Transaction [PROTECTED]
+
+	// SUBSCRIPTION ENDPOINTS.
+	server.Handle("GET /api/subscription", api.AuthMiddleware(iz.Bind(api.DetectSubscriptionsHandler)))                 // Detect Subscriptions [PROTECTED]
+	server.Handle("POST /api/subscription/{id}/recurring", api.AuthMiddleware(iz.Bind(api.ConvertSubscriptionHandler))) // Convert to Recurring Transaction [PROTECTED]
+
+	// WALLET ENDPOINTS.
+	server.Handle("POST /api/wallet", api.AuthMiddleware(iz.Bind(api.SaveWalletHandler)))                         // Create Wallet   [PROTECTED]
+	server.Handle("GET /api/wallet", api.AuthMiddleware(iz.Bind(api.GetWalletsHandler)))                          // List Wallets    [PROTECTED]
+	server.Handle("PUT /api/wallet/{id}", api.AuthMiddleware(iz.Bind(api.UpdateWalletHandler)))                   // Update Wallet   [PROTECTED]
+	server.Handle("DELETE /api/wallet/{id}", api.AuthMiddleware(iz.Bind(api.DeleteWalletHandler)))                // Delete Wallet   [PROTECTED]
+	server.Handle("GET /api/wallet/{id}/transactions", api.AuthMiddleware(iz.Bind(api.GetWalletRegisterHandler))) // Wallet Register [PROTECTED]
+
+	// RECONCILIATION ENDPOINTS.
+	server.Handle("PUT /api/wallet/{id}/reconciliation", api.AuthMiddleware(iz.Bind(api.StartReconciliationHandler)))          // Start or Update Reconciliation [PROTECTED]
+	server.Handle("GET /api/wallet/{id}/reconciliation", api.AuthMiddleware(iz.Bind(api.GetReconciliationHandler)))            // Unreconciled Transactions [PROTECTED]
+	server.Handle("POST /api/wallet/{id}/reconciliation/cleared", api.AuthMiddleware(iz.Bind(api.ClearTransactionsHandler)))   // Mark Transactions Cleared [PROTECTED]
+	server.Handle("POST /api/wallet/{id}/reconciliation/finish", api.AuthMiddleware(iz.Bind(api.FinishReconciliationHandler))) // Finish Reconciliation [PROTECTED]
+
+	// TAG ENDPOINTS.
+	server.Handle("POST /api/tag", api.AuthMiddleware(iz.Bind(api.SaveTagHandler)))                             // Create Tag [PROTECTED]
+	server.Handle("GET /api/tag", api.AuthMiddleware(iz.Bind(api.GetTagsHandler)))                              // List Tags [PROTECTED]
+	server.Handle("PUT /api/tag/{id}", api.AuthMiddleware(iz.Bind(api.UpdateTagHandler)))                       // Rename Tag [PROTECTED]
+	server.Handle("DELETE /api/tag/{id}", api.AuthMiddleware(iz.Bind(api.DeleteTagHandler)))                    // Delete Tag [PROTECTED]
+	server.Handle("PUT /api/transaction/{id}/tags", api.AuthMiddleware(iz.Bind(api.SetTransactionTagsHandler))) // Set Transaction Tags [PROTECTED]
+
+	// PAYEE ENDPOINTS.
+	server.Handle("POST /api/payee", api.AuthMiddleware(iz.Bind(api.SavePayeeHandler)))                           // Create Payee [PROTECTED]
+	server.Handle("GET /api/payee", api.AuthMiddleware(iz.Bind(api.GetPayeesHandler)))                            // List Payees [PROTECTED]
+	server.Handle("GET /api/payee/stats", api.AuthMiddleware(iz.Bind(api.GetPayeeStatsHandler)))                  // Payee Spending Stats [PROTECTED]
+	server.Handle("GET /api/payee/{id}", api.AuthMiddleware(iz.Bind(api.GetPayeeHandler)))                        // Get Payee [PROTECTED]
+	server.Handle("PUT /api/payee/{id}", api.AuthMiddleware(iz.Bind(api.UpdatePayeeHandler)))                     // Update Payee [PROTECTED]
+	server.Handle("DELETE /api/payee/{id}", api.AuthMiddleware(iz.Bind(api.DeletePayeeHandler)))                  // Delete Payee [PROTECTED]
+	server.Handle("POST /api/payee/{id}/link", api.AuthMiddleware(iz.Bind(api.LinkPayeeTransactionsHandler)))     // Link Matching Transactions [PROTECTED]
+	server.Handle("PUT /api/transaction/{id}/payee", api.AuthMiddleware(iz.Bind(api.SetTransactionPayeeHandler))) // Set Transaction Payee [PROTECTED]
+
+	// RULE ENDPOINTS.
+	server.Handle("POST /api/rule", api.AuthMiddleware(iz.Bind(api.SaveRuleHandler)))          // Create Rule [PROTECTED]
+	server.Handle("GET /api/rule", api.AuthMiddleware(iz.Bind(api.GetRulesHandler)))           // List Rules [PROTECTED]
+	server.Handle("POST /api/rule/test", api.AuthMiddleware(iz.Bind(api.TestRuleHandler)))     // Test Rule Against History [PROTECTED]
+	server.Handle("POST /api/rule/apply", api.AuthMiddleware(iz.Bind(api.ApplyRulesHandler)))  // Apply Rules Retroactively [PROTECTED]
+	server.Handle("GET /api/rule/{id}", api.AuthMiddleware(iz.Bind(api.GetRuleHandler)))       // Get Rule [PROTECTED]
+	server.Handle("PUT /api/rule/{id}", api.AuthMiddleware(iz.Bind(api.UpdateRuleHandler)))    // Update Rule [PROTECTED]
+	server.Handle("DELETE /api/rule/{id}", api.AuthMiddleware(iz.Bind(api.DeleteRuleHandler))) // Delete Rule [PROTECTED]
+
+	// SAVINGS GOAL ENDPOINTS.
+	server.Handle("POST /api/goal", api.AuthMiddleware(iz.Bind(api.SaveSavingsGoalHandler)))                          // Create Savings Goal [PROTECTED]
+	server.Handle("GET /api/goal", api.AuthMiddleware(iz.Bind(api.GetSavingsGoalsHandler)))                           // List Savings Goals [PROTECTED]
+	server.Handle("GET /api/goal/{id}", api.AuthMiddleware(iz.Bind(api.GetSavingsGoalHandler)))                       // Get Savings Goal [PROTECTED]
+	server.Handle("PUT /api/goal/{id}", api.AuthMiddleware(iz.Bind(api.UpdateSavingsGoalHandler)))                    // Update Savings Goal [PROTECTED]
+	server.Handle("DELETE /api/goal/{id}", api.AuthMiddleware(iz.Bind(api.DeleteSavingsGoalHandler)))                 // Delete Savings Goal [PROTECTED]
+	server.Handle("POST /api/goal/{id}/contribution", api.AuthMiddleware(iz.Bind(api.AddSavingsContributionHandler))) // Add Contribution or Withdrawal [PROTECTED]
+	server.Handle("GET /api/goal/{id}/history", api.AuthMiddleware(iz.Bind(api.GetSavingsGoalHistoryHandler)))        // Savings Goal History [PROTECTED]
+
+	// DEBT ENDPOINTS.
+	server.Handle("POST /api/debt", api.AuthMiddleware(iz.Bind(api.SaveDebtHandler)))                     // Create Debt [PROTECTED]
+	server.Handle("GET /api/debt", api.AuthMiddleware(iz.Bind(api.GetDebtsHandler)))                      // List Debts [PROTECTED]
+	server.Handle("POST /api/debt/plan", api.AuthMiddleware(iz.Bind(api.PlanDebtPayoffHandler)))          // Snowball or Avalanche Payoff Plan [PROTECTED]
+	server.Handle("GET /api/debt/{id}", api.AuthMiddleware(iz.Bind(api.GetDebtHandler)))                  // Get Debt [PROTECTED]
+	server.Handle("PUT /api/debt/{id}", api.AuthMiddleware(iz.Bind(api.UpdateDebtHandler)))               // Update Debt [PROTECTED]
+	server.Handle("DELETE /api/debt/{id}", api.AuthMiddleware(iz.Bind(api.DeleteDebtHandler)))            // Delete Debt [PROTECTED]
+	server.Handle("GET /api/debt/{id}/schedule", api.AuthMiddleware(iz.Bind(api.GetDebtScheduleHandler))) // Amortization Schedule [PROTECTED]
+	server.Handle("POST /api/debt/{id}/payment", api.AuthMiddleware(iz.Bind(api.AddDebtPaymentHandler)))  // Add Debt Payment [PROTECTED]
+	server.Handle("GET /api/debt/{id}/payment", api.AuthMiddleware(iz.Bind(api.GetDebtPaymentsHandler)))  // List Debt Payments [PROTECTED]
 
 	// EXPENSE CATEGORY ENDPOINTS.
 	server.Handle("POST /api/category/expense", api.AuthMiddleware(iz.Bind(api.SaveExpenseCategoryHandler)))          // Create Expense Category        [PROTECTED]
@@ -78,6 +205,16 @@ func main() {
 	server.Handle("PUT /api/category/income", api.AuthMiddleware(iz.Bind(api.UpdateIncomeCategoryHandler)))         // Update Income Category 		 [PROTECTED]
 	server.Handle("DELETE /api/category/income/{id}", api.AuthMiddleware(iz.Bind(api.DeleteIncomeCategoryHandler))) // Delete Income Category 		 [PROTECTED]
 
+	// CATEGORY TREE ENDPOINTS.
+	server.Handle("PUT /api/category/{type}/{id}/parent", api.AuthMiddleware(iz.Bind(api.MoveCategoryHandler))) // Move Expense or Income Category [PROTECTED]
+
+	// CATEGORY ARCHIVE ENDPOINTS.
+	server.Handle("PUT /api/category/{type}/{id}/archive", api.AuthMiddleware(iz.Bind(api.ArchiveCategoryHandler))) // Archive or Unarchive Expense or Income Category [PROTECTED]
+
+	// CATEGORY MERGE ENDPOINTS.
+	server.Handle("POST /api/category/{type}/merge", api.AuthMiddleware(iz.Bind(api.MergeCategoriesHandler)))  // Merge Expense or Income Categories [PROTECTED]
+	server.Handle("GET /api/category/{type}/merge", api.AuthMiddleware(iz.Bind(api.GetCategoryMergesHandler))) // Get Merge History of Categories [PROTECTED]
+
 	// STATISTICS ENDPOINTS.
 	server.Handle("GET /api/statistics/expense", api.AuthMiddleware(iz.Bind(api.GetExpenseCategoryStatsHandler))) // Get Statistics of expense categories [PROTECTED]
 	server.Handle("GET /api/statistics/income", api.AuthMiddleware(iz.Bind(api.GetIncomeCategoryStatsHandler)))   // Get Statistics of income categories  [PROTECTED]
@@ -90,9 +227,28 @@ func main() {
 	}
 	fmt.Println("Starting server on port: ", port)
 	handlerwithCors := corsConf.Handler(server)
-	err = http.ListenAndServe(":"+port, handlerwithCors) // Start the server
-	if err != nil {
-		logging.Logger.Errorf("failed to start server: %v", err)
-		return
+	httpServer := &http.Server{Addr: ":" + port, Handler: handlerwithCors}
+
+	serverErr := make(chan error, 1)
+	go func() {
+		serverErr <- httpServer.ListenAndServe() // Start the server
+	}()
+
+	select {
+	case err := <-serverErr:
+		if !errors.Is(err, http.ErrServerClosed) {
+			logging.Logger.Errorf("failed to start server: %v", err)
+		}
+	case <-ctx.Done():
+		logging.Logger.Info("shutting down...")
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), SHUTDOWN_TIMEOUT)
+		if err := httpServer.Shutdown(shutdownCtx); err != nil {
+			logging.Logger.Errorf("failed to shut down server: %v", err)
+		}
+		cancel()
 	}
+
+	stop()
+	jobs.Wait()
+	logging.Logger.Info("application stopped")
 }